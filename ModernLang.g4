@@ -17,6 +17,7 @@ STRUCT    : 'struct';
 ENUM      : 'enum';
 INTERFACE : 'interface';
 EXT       : 'ext';
+IMPL      : 'impl';
 MATCH     : 'match';
 IF        : 'if';
 ELSE      : 'else';
@@ -75,6 +76,9 @@ LBRACE         : '{';
 RBRACE         : '}';
 LBRACKET       : '[';
 RBRACKET       : ']';
+HASH_BRACE     : '#{';
+ELLIPSIS       : '...';
+AT             : '@';
 
 IDENTIFIER : LETTER (LETTER | DIGIT)* ;
 NUMBER     : DIGIT+ ('.' DIGIT+)? ;
@@ -138,8 +142,24 @@ declaration
     | structDecl
     | enumDecl
     | interfaceDecl
+    | implDecl
     | contractDecl
     | importDecl
+    | annotation+ annotatedDecl
+    ;
+
+annotatedDecl
+    : functionDecl
+    | extensionDecl
+    | classDecl
+    | structDecl
+    | enumDecl
+    | interfaceDecl
+    | contractDecl
+    ;
+
+annotation
+    : AT IDENTIFIER (LPAREN argumentList? RPAREN)?
     ;
 
 statement
@@ -174,7 +194,7 @@ block
 // ---------------- DECLARATIONS ----------------
 
 variableDecl
-    : (LET | VAR) IDENTIFIER (COLON type_)? ASSIGN expression SEMICOLON
+    : (LET | VAR) (IDENTIFIER (COLON type_)? | arrayPattern) ASSIGN expression SEMICOLON
     ;
 
 functionDecl
@@ -231,7 +251,7 @@ typeArgs
     ;
 
 classDecl
-    : CLASS IDENTIFIER parameterClause (COLON IDENTIFIER)? block?
+    : CLASS IDENTIFIER typeParams? parameterClause (COLON IDENTIFIER)? block?
     ;
 
 structDecl
@@ -251,7 +271,11 @@ interfaceDecl
     ;
 
 interfaceMember
-    : FN IDENTIFIER parameterClause returnType? SEMICOLON
+    : FN IDENTIFIER parameterClause returnType? (SEMICOLON | functionBody)
+    ;
+
+implDecl
+    : IMPL IDENTIFIER FOR IDENTIFIER block
     ;
 
 contractDecl
@@ -340,6 +364,7 @@ pattern
     | IDENTIFIER
     | structPattern
     | objectPattern
+    | arrayPattern
     ;
 
 literalPattern
@@ -360,6 +385,14 @@ objectPattern
     : LBRACE pairPattern (COMMA pairPattern)* RBRACE
     ;
 
+arrayPattern
+    : LBRACKET (pattern (COMMA pattern)* (COMMA restPattern)? | restPattern)? RBRACKET
+    ;
+
+restPattern
+    : ELLIPSIS IDENTIFIER?
+    ;
+
 pairPattern
     : (STRING | IDENTIFIER) COLON pattern
     ;
@@ -425,6 +458,7 @@ postfixPart
     | SAFE_DOT IDENTIFIER
     | LBRACKET expression RBRACKET
     | NON_NULL
+    | QUESTION
     | LPAREN argumentList? RPAREN
     ;
 
@@ -442,6 +476,7 @@ primary
     | NULL
     | IDENTIFIER
     | arrayLiteral
+    | setLiteral
     | objectLiteral
     | awaitExpr
     | LPAREN expression RPAREN
@@ -451,6 +486,10 @@ arrayLiteral
     : LBRACKET (expression (COMMA expression)*)? RBRACKET
     ;
 
+setLiteral
+    : HASH_BRACE (expression (COMMA expression)*)? RBRACE
+    ;
+
 objectLiteral
     : LBRACE (pair (COMMA pair)*)? RBRACE
     ;
@@ -1,34 +1,69 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	iofs "io/fs"
+	"math"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/cybellereaper/selenelang/internal/ast"
 	buildwindows "github.com/cybellereaper/selenelang/internal/build/windows"
+	"github.com/cybellereaper/selenelang/internal/cache"
 	"github.com/cybellereaper/selenelang/internal/examples"
+	"github.com/cybellereaper/selenelang/internal/explain"
 	"github.com/cybellereaper/selenelang/internal/format"
+	"github.com/cybellereaper/selenelang/internal/i18n"
 	"github.com/cybellereaper/selenelang/internal/jit"
 	"github.com/cybellereaper/selenelang/internal/lexer"
 	"github.com/cybellereaper/selenelang/internal/lsp"
+	"github.com/cybellereaper/selenelang/internal/perf"
+	"github.com/cybellereaper/selenelang/internal/plugin"
 	"github.com/cybellereaper/selenelang/internal/project"
 	"github.com/cybellereaper/selenelang/internal/runtime"
+	"github.com/cybellereaper/selenelang/internal/selfupdate"
 	"github.com/cybellereaper/selenelang/internal/token"
 	"github.com/cybellereaper/selenelang/internal/toolchain"
 	"github.com/cybellereaper/selenelang/internal/transpile"
 )
 
+// version is the release this binary was built from, set by release builds
+// with -ldflags "-X main.version=<version>".
+var version = "dev"
+
+// jsonOutput is set by the global --json flag, given before the command, and
+// makes the commands that support it print JSON instead of text. Those
+// commands also accept --json themselves.
+var jsonOutput bool
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "--json" {
+		jsonOutput = true
+		os.Args = slices.Delete(os.Args, 1, 2)
+	}
 	if len(os.Args) < 2 {
 		usage()
 		os.Exit(1)
 	}
+	if err := plugin.Load(plugin.Dirs()); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	if command := perfCommandName(os.Args[1]); command != "perf" {
+		perf.Start(command, version)
+	}
 
 	switch os.Args[1] {
 	case "run":
@@ -39,10 +74,18 @@ func main() {
 		if err := testCommand(os.Args[2:]); err != nil {
 			exitWithError(err)
 		}
+	case "examples":
+		if err := examplesCommand(os.Args[2:]); err != nil {
+			exitWithError(err)
+		}
 	case "tokens":
 		if err := tokensCommand(os.Args[2:]); err != nil {
 			exitWithError(err)
 		}
+	case "ast":
+		if err := astCommand(os.Args[2:]); err != nil {
+			exitWithError(err)
+		}
 	case "init":
 		if err := initCommand(os.Args[2:]); err != nil {
 			exitWithError(err)
@@ -59,6 +102,18 @@ func main() {
 		if err := fmtCommand(os.Args[2:]); err != nil {
 			exitWithError(err)
 		}
+	case "check":
+		if err := checkCommand(os.Args[2:]); err != nil {
+			exitWithError(err)
+		}
+	case "vet":
+		if err := vetCommand(os.Args[2:]); err != nil {
+			exitWithError(err)
+		}
+	case "explain":
+		if err := explainCommand(os.Args[2:]); err != nil {
+			exitWithError(err)
+		}
 	case "build":
 		if err := buildCommand(os.Args[2:]); err != nil {
 			exitWithError(err)
@@ -67,29 +122,136 @@ func main() {
 		if err := transpileCommand(os.Args[2:]); err != nil {
 			exitWithError(err)
 		}
+	case "cache":
+		if err := cacheCommand(os.Args[2:]); err != nil {
+			exitWithError(err)
+		}
+	case "self":
+		if err := selfCommand(os.Args[2:]); err != nil {
+			exitWithError(err)
+		}
+	case "perf":
+		if err := perfCommand(os.Args[2:]); err != nil {
+			exitWithError(err)
+		}
 	default:
-		if err := runCommand(os.Args[1:]); err != nil {
+		ran, err := externalCommand(os.Args[1], os.Args[2:])
+		if !ran {
+			err = runCommand(os.Args[1:])
+		}
+		if err != nil {
 			exitWithError(err)
 		}
 	}
+	finishPerf(false)
+}
+
+// perfCommandName is the command recorded for an invocation whose first
+// argument is arg: the built-in command it names, or run for a script.
+// Plugin and external commands are renamed once they are found.
+func perfCommandName(arg string) string {
+	for _, cmd := range usageCommands {
+		if strings.Fields(cmd.synopsis)[0] == arg {
+			return arg
+		}
+	}
+	return "run"
+}
+
+// finishPerf writes the performance record of this invocation, if one is
+// being kept.
+func finishPerf(failed bool) {
+	if err := perf.Finish(failed); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+}
+
+// externalCommand runs name as a command registered by a Go plugin or as a
+// selene-<name> executable on PATH, reporting whether it found one. A name
+// that is an existing file is always run as a script.
+func externalCommand(name string, args []string) (bool, error) {
+	if _, err := os.Stat(name); err == nil {
+		return false, nil
+	}
+	if cmd, ok := plugin.LookupCommand(name); ok {
+		perf.SetCommand("plugin")
+		return true, cmd.Run(args)
+	}
+	path, ok := plugin.LookupExecutable(name)
+	if !ok {
+		return false, nil
+	}
+	perf.SetCommand("external")
+	err := plugin.RunExecutable(name, path, args, version)
+	var exit *plugin.ExitError
+	if errors.As(err, &exit) {
+		// The command has reported its own failure; only pass on the status.
+		return true, &runtime.ExitError{Code: exit.Code}
+	}
+	return true, err
+}
+
+// usageCommands pairs each command's synopsis, which stays in English because
+// it is what users type, with its translated description.
+var usageCommands = []struct {
+	synopsis string
+	help     i18n.MessageID
+}{
+	{"run [--tokens|--vm [--trace|--step]|--jit|--tiered|--sandbox [--max-steps N] [--max-mem SIZE] [--timeout D] [--allow|--deny CAPS]|--race-check|--strict-math|--max-depth N|--contracts=false|--opt-level N|--profile] <file> [-- args...]", i18n.CLIHelpRun},
+	{"test [flags]", i18n.CLIHelpTest},
+	{"examples [--tag|--run]", i18n.CLIHelpExamples},
+	{"tokens [--json|--count] <file>", i18n.CLIHelpTokens},
+	{"ast [--json] <file>", i18n.CLIHelpAST},
+	{"init <module> [--name]", i18n.CLIHelpInit},
+	{"deps <subcommand>", i18n.CLIHelpDeps},
+	{"lsp [--log-file|--trace]", i18n.CLIHelpLSP},
+	{"fmt [flags] <files>", i18n.CLIHelpFmt},
+	{"check [--parallel] [files]", i18n.CLIHelpCheck},
+	{"vet [--format|--fail-on] [files]", i18n.CLIHelpVet},
+	{"explain [code]", i18n.CLIHelpExplain},
+	{"build [--out|--windows-exe] <file>", i18n.CLIHelpBuild},
+	{"transpile [flags] <file>", i18n.CLIHelpTranspile},
+	{"cache clean", i18n.CLIHelpCacheClean},
+	{"self update [--channel stable|nightly] [--check]", i18n.CLIHelpSelfUpdate},
+	{"perf report|path|clear", i18n.CLIHelpPerf},
 }
 
 func usage() {
-	fmt.Fprintln(os.Stderr, "usage: selene <command> [options]")
-	fmt.Fprintln(os.Stderr, "commands:")
-	fmt.Fprintln(os.Stderr, "  run [--tokens|--vm|--jit] <file> execute a Selene source file")
-	fmt.Fprintln(os.Stderr, "  test [flags]            execute all example scripts and report pass/fail status")
-	fmt.Fprintln(os.Stderr, "  tokens <file>           dump the token stream for a file")
-	fmt.Fprintln(os.Stderr, "  init <module> [--name]  create a new Selene project")
-	fmt.Fprintln(os.Stderr, "  deps <subcommand>       manage project dependencies (add, list, verify)")
-	fmt.Fprintln(os.Stderr, "  lsp                    start the Selene language server on stdio")
-	fmt.Fprintln(os.Stderr, "  fmt [flags] <files>    format Selene source files")
-	fmt.Fprintln(os.Stderr, "  build [--out|--windows-exe] <file>   compile Selene bytecode, emit listings, or build Windows executables")
-	fmt.Fprintln(os.Stderr, "  transpile [flags] <file>  convert Selene sources to another language")
+	fmt.Fprintln(os.Stderr, i18n.Sprintf(i18n.CLIUsage))
+	fmt.Fprintln(os.Stderr, i18n.Sprintf(i18n.CLICommands))
+	for _, cmd := range usageCommands {
+		fmt.Fprintf(os.Stderr, "  %-24s %s\n", cmd.synopsis, i18n.Sprintf(cmd.help))
+	}
+	commands, executables := plugin.Commands(), plugin.Executables()
+	if len(commands) == 0 && len(executables) == 0 {
+		return
+	}
+	fmt.Fprintln(os.Stderr, i18n.Sprintf(i18n.CLIPluginCommands))
+	for _, cmd := range commands {
+		synopsis := cmd.Synopsis
+		if synopsis == "" {
+			synopsis = cmd.Name
+		}
+		fmt.Fprintf(os.Stderr, "  %-24s %s\n", synopsis, cmd.Summary)
+	}
+	for _, name := range executables {
+		if _, registered := plugin.LookupCommand(name); !registered {
+			fmt.Fprintf(os.Stderr, "  %-24s %s\n", name, i18n.Sprintf(i18n.CLIHelpExternal, plugin.ExecutablePrefix+name))
+		}
+	}
 }
 
 func exitWithError(err error) {
-	fmt.Fprintln(os.Stderr, err)
+	var exit *runtime.ExitError
+	if errors.As(err, &exit) {
+		finishPerf(exit.Code != 0)
+		os.Exit(exit.Code)
+	}
+	finishPerf(true)
+	fmt.Fprintln(os.Stderr, runtime.FormatError(err))
+	if errors.Is(err, runtime.ErrInterrupted) {
+		os.Exit(130)
+	}
 	os.Exit(1)
 }
 
@@ -99,6 +261,26 @@ func runCommand(args []string) error {
 	vmFlag := fs.Bool("vm", false, "execute using the Selene virtual machine")
 	jitFlag := fs.Bool("jit", false, "execute using the Selene JIT engine")
 	disFlag := fs.Bool("disassemble", false, "dump bytecode before executing with --vm")
+	traceFlag := fs.Bool("trace", false, "print each instruction and the VM stack to stderr as --vm executes it")
+	stepFlag := fs.Bool("step", false, "debug the program interactively as --vm executes it")
+	sandboxFlag := fs.Bool("sandbox", false, "disable os.exec, os.setenv, os.chdir, and fs.write")
+	contractsFlag := fs.Bool("contracts", true, "check requires and ensures clauses and invariants")
+	optFlag := fs.Int("opt-level", 1, "optimization level for --vm: 0 compiles as written, 1 folds constants")
+	maxSteps := fs.Int64("max-steps", 0, "stop the program after this many loop iterations and function calls (0 disables)")
+	maxMem := fs.String("max-mem", "", "stop the program once the live heap exceeds this size, such as 256M")
+	timeout := fs.Duration("timeout", 0, "stop the program after it has run this long (0 disables)")
+	allowFlag := fs.String("allow", "", "comma-separated capabilities to enable, overriding [capabilities] in selene.toml")
+	denyFlag := fs.String("deny", "", "comma-separated capabilities to disable: fs, net, os.exec, env")
+	profileFlag := fs.String("profile", "", "apply the named [profiles.<name>] section from selene.toml")
+	shutdownFlag := fs.Duration("shutdown-timeout", time.Second, "how long to wait for spawned tasks after the program finishes")
+	failLeaks := fs.Bool("fail-on-leaks", false, "exit with an error if tasks, channels, or generators are still live after shutdown")
+	auditFlag := fs.String("audit-log", "", "append a JSON line for every fs and os builtin call to this file")
+	raceFlag := fs.Bool("race-check", false, "report variables that concurrent tasks assign without ordering and fail the run")
+	strictMath := fs.Bool("strict-math", false, "raise an error when arithmetic produces NaN or an infinity")
+	maxDepth := fs.Int("max-depth", runtime.DefaultMaxCallDepth, "fail calls nested deeper than this with a catchable error (0 disables)")
+	tieredFlag := fs.Bool("tiered", false, "interpret functions until they are hot, then switch them to the JIT")
+	tierThreshold := fs.Int("tier-threshold", 100, "calls after which --tiered compiles a function")
+	tierStats := fs.Bool("tier-stats", false, "print which functions --tiered promoted to stderr")
 	fs.SetOutput(os.Stderr)
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -108,39 +290,215 @@ func runCommand(args []string) error {
 	}
 	filename := fs.Arg(0)
 	if *tokensFlag {
-		return dumpTokens(filename)
+		return dumpTokens(filename, tokenDumpOptions{})
+	}
+	programArgs := scriptArgs(fs.Args()[1:])
+	maxHeap, err := parseByteSize(*maxMem)
+	if err != nil {
+		return fmt.Errorf("--max-mem: %w", err)
+	}
+	opts := runOptions{disassemble: *disFlag, trace: *traceFlag, step: *stepFlag, sandbox: *sandboxFlag, contracts: *contractsFlag, optimize: *optFlag}
+	if *jitFlag {
+		opts.backend = "jit"
+	} else if *vmFlag {
+		opts.backend = "vm"
+	}
+	if *profileFlag != "" {
+		explicit := make(map[string]bool)
+		fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+		profile, err := loadRunProfile(filename, *profileFlag)
+		if err != nil {
+			return err
+		}
+		if opts, err = opts.withProfile(profile, explicit); err != nil {
+			return err
+		}
+	}
+	if (opts.trace || opts.step) && opts.backend != "vm" {
+		return errors.New("--trace and --step require --vm")
+	}
+	if *tieredFlag && opts.backend != "" {
+		return errors.New("--tiered starts in the interpreter and cannot be combined with --vm or --jit")
+	}
+	if *tierStats && !*tieredFlag {
+		return errors.New("--tier-stats requires --tiered")
 	}
 	rt := runtime.New()
+	rt.SetArgs(programArgs)
+	if project.ModeOf(filename) == project.ScriptMode {
+		rt.UseScriptPrelude()
+	}
+	rt.SetSandboxed(opts.sandbox)
+	rt.SetContracts(opts.contracts)
+	if err := rt.SetOptimizationLevel(opts.optimize); err != nil {
+		return fmt.Errorf("--opt-level: %w", err)
+	}
+	rt.SetRaceCheck(*raceFlag)
+	rt.SetStrictMath(*strictMath)
+	rt.SetMaxCallDepth(*maxDepth)
+	if *tieredFlag {
+		rt.SetTiering(*tierThreshold, jit.CompileFunction)
+		if *tierStats {
+			defer printTierStats(os.Stderr, rt, *tierThreshold)
+		}
+	}
+	if *auditFlag != "" {
+		file, err := os.OpenFile(*auditFlag, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("open audit log: %w", err)
+		}
+		defer file.Close()
+		audit := runtime.NewAuditLog(file)
+		rt.SetAuditLog(audit)
+		defer func() {
+			if err := audit.Err(); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: audit log: %v\n", err)
+			}
+		}()
+	}
+	defer forwardSignals(rt)()
+	defer rt.Flush()
 	if err := toolchain.LoadDependencies(rt, filename); err != nil {
 		return err
 	}
-	if *jitFlag {
+	capabilities, err := runCapabilities(filename, *allowFlag, *denyFlag)
+	if err != nil {
+		return err
+	}
+	if *maxSteps != 0 || maxHeap != 0 || *timeout != 0 || capabilities != nil {
+		policy := runtime.Policy{MaxSteps: *maxSteps, MaxHeapBytes: maxHeap, Timeout: *timeout, Capabilities: capabilities}
+		if err := rt.SetPolicy(policy); err != nil {
+			return err
+		}
+	}
+	if err := executeProgram(rt, filename, opts); err != nil {
+		return err
+	}
+	leaks := rt.Shutdown(*shutdownFlag)
+	for _, leak := range leaks {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", leak)
+	}
+	if *failLeaks && len(leaks) > 0 {
+		return &runtime.LeakError{Leaks: leaks}
+	}
+	if races := rt.Races(); len(races) > 0 {
+		for _, race := range races {
+			fmt.Fprintf(os.Stderr, "race: %s\n", race)
+		}
+		return &runtime.RaceError{Races: races}
+	}
+	return nil
+}
+
+// parseByteSize parses a size such as 1048576, 512K, 256M, or 2G, where the
+// suffixes are powers of 1024. An empty string is zero.
+func parseByteSize(text string) (uint64, error) {
+	if text == "" {
+		return 0, nil
+	}
+	digits, shift := text, 0
+	switch strings.ToUpper(text[len(text)-1:]) {
+	case "K":
+		shift = 10
+	case "M":
+		shift = 20
+	case "G":
+		shift = 30
+	}
+	if shift > 0 {
+		digits = text[:len(text)-1]
+	}
+	n, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil || n > math.MaxUint64>>shift {
+		return 0, fmt.Errorf("invalid size %q", text)
+	}
+	return n << shift, nil
+}
+
+// forwardSignals delivers interrupt, terminate, and hangup signals to the
+// program until the returned function is called. Handlers registered with
+// os.onSignal run as tasks. Otherwise the first signal interrupts the program
+// so its finally blocks and using disposals run, and a second one exits at
+// once.
+func forwardSignals(rt *runtime.Runtime) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, runtime.Signals()...)
+	done := make(chan struct{})
+	go func() {
+		interrupted := false
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-signals:
+				if task := rt.HandleSignal(sig); task != nil {
+					go func() {
+						if _, err := task.Join(); err != nil {
+							fmt.Fprintf(os.Stderr, "warning: signal handler: %v\n", err)
+						}
+					}()
+					continue
+				}
+				if interrupted {
+					os.Exit(130)
+				}
+				interrupted = true
+				rt.Interrupt()
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+func executeProgram(rt *runtime.Runtime, filename string, opts runOptions) error {
+	switch opts.backend {
+	case "jit":
 		program, _, err := toolchain.ParseFile(filename)
 		if err != nil {
 			return err
 		}
+		compileStart := time.Now()
 		compiled, err := jit.Compile(program)
+		perf.Since(perf.PhaseCompile, compileStart)
 		if err != nil {
 			return err
 		}
+		defer perf.Since(perf.PhaseRun, time.Now())
 		if _, err := compiled.Run(rt); err != nil {
 			return fmt.Errorf("jit error: %w", err)
 		}
 		return nil
-	}
-	if *vmFlag {
-		program, _, err := toolchain.ParseFile(filename)
-		if err != nil {
-			return err
-		}
-		chunk, err := rt.Compile(program)
+	case "vm":
+		chunk, err := toolchain.CompileFile(rt, filename)
 		if err != nil {
 			return err
 		}
-		if *disFlag {
+		if opts.disassemble {
 			fmt.Println(chunk.Disassemble())
 		}
+		var debugger *vmDebugger
+		if opts.step {
+			debugger = newVMDebugger(rt, chunk, os.Stdin, os.Stderr)
+		}
+		if opts.trace || debugger != nil {
+			rt.SetVMHook(func(step runtime.VMStep) error {
+				if opts.trace {
+					fmt.Fprintln(os.Stderr, formatVMStep(step))
+				}
+				if debugger != nil {
+					return debugger.before(step)
+				}
+				return nil
+			})
+		}
+		defer perf.Since(perf.PhaseRun, time.Now())
 		if _, err := rt.RunChunk(chunk); err != nil {
+			if errors.Is(err, errDebuggerQuit) {
+				return nil
+			}
 			return fmt.Errorf("vm error: %w", err)
 		}
 		return nil
@@ -148,87 +506,523 @@ func runCommand(args []string) error {
 	return toolchain.ExecuteFile(rt, filename)
 }
 
+// printTierStats reports the functions a --tiered run called and which of
+// them reached threshold and were compiled.
+func printTierStats(w io.Writer, rt *runtime.Runtime, threshold int) {
+	stats := rt.TierStats()
+	promoted := 0
+	for _, stat := range stats {
+		if stat.Promoted {
+			promoted++
+		}
+	}
+	fmt.Fprintf(w, "tiering: %d of %d functions promoted after %d calls\n", promoted, len(stats), threshold)
+	for _, stat := range stats {
+		line := fmt.Sprintf("  %s (line %d): %d calls", stat.Name, stat.Line, stat.Calls)
+		switch {
+		case stat.Promoted:
+			line += ", promoted"
+		case stat.Err != nil:
+			line += ", compile failed: " + stat.Err.Error()
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// formatVMStep renders a traced instruction: the disassembled instruction,
+// its source line, and the VM stack.
+func formatVMStep(step runtime.VMStep) string {
+	stack := make([]string, len(step.Stack))
+	for i, val := range step.Stack {
+		stack[i] = val.Inspect()
+	}
+	text := step.Instruction
+	if step.Line > 0 {
+		text += fmt.Sprintf(" line %d", step.Line)
+	}
+	return text + " stack: [" + strings.Join(stack, ", ") + "]"
+}
+
+// errDebuggerQuit stops a run from the debugger's quit command.
+var errDebuggerQuit = errors.New("debugger quit")
+
+// vmDebugger drives `selene run --vm --step`. It pauses before the first
+// instruction, after each step, and at breakpoints, and reads commands from
+// in until told to continue. At the end of in it lets the program run on.
+type vmDebugger struct {
+	rt          *runtime.Runtime
+	chunk       *runtime.Chunk
+	in          *bufio.Scanner
+	out         io.Writer
+	builtins    map[string]bool
+	breakpoints map[int]bool
+	stepping    bool
+}
+
+func newVMDebugger(rt *runtime.Runtime, chunk *runtime.Chunk, in io.Reader, out io.Writer) *vmDebugger {
+	builtins := make(map[string]bool)
+	for name := range rt.Environment().Snapshot() {
+		builtins[name] = true
+	}
+	return &vmDebugger{rt: rt, chunk: chunk, in: bufio.NewScanner(in), out: out, builtins: builtins, breakpoints: make(map[int]bool), stepping: true}
+}
+
+func (d *vmDebugger) before(step runtime.VMStep) error {
+	if !d.stepping && !d.breakpoints[step.IP] {
+		return nil
+	}
+	fmt.Fprintln(d.out, formatVMStep(step))
+	for {
+		fmt.Fprint(d.out, "(selene) ")
+		if !d.in.Scan() {
+			fmt.Fprintln(d.out)
+			d.stepping = false
+			clear(d.breakpoints)
+			return nil
+		}
+		fields := strings.Fields(d.in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "s", "step":
+			d.stepping = true
+			return nil
+		case "c", "continue":
+			d.stepping = false
+			return nil
+		case "b", "break":
+			line, err := strconv.Atoi(strings.Join(fields[1:], ""))
+			if err != nil || line < 1 {
+				fmt.Fprintln(d.out, "usage: break <line>")
+				continue
+			}
+			ip, ok := d.breakpointFor(line)
+			if !ok {
+				fmt.Fprintf(d.out, "no instruction at or before line %d\n", line)
+				continue
+			}
+			d.breakpoints[ip] = true
+			text, _ := d.chunk.Instruction(ip)
+			fmt.Fprintf(d.out, "breakpoint at line %d: %s\n", line, text)
+		case "l", "locals":
+			d.printLocals()
+		case "p", "print":
+			if len(fields) != 2 {
+				fmt.Fprintln(d.out, "usage: print <name>")
+				continue
+			}
+			if val, ok := d.rt.Environment().Get(fields[1]); ok {
+				fmt.Fprintf(d.out, "%s = %s\n", fields[1], val.Inspect())
+			} else {
+				fmt.Fprintf(d.out, "%s is not defined\n", fields[1])
+			}
+		case "q", "quit":
+			return errDebuggerQuit
+		default:
+			fmt.Fprintln(d.out, "commands: step (s), continue (c), break <line> (b), locals (l), print <name> (p), quit (q)")
+		}
+	}
+}
+
+// breakpointFor returns the instruction that runs the code on line: the
+// last one starting at or before it. The VM executes a top-level item as
+// one instruction, so a breakpoint inside a function stops before the
+// declaration or statement that contains it.
+func (d *vmDebugger) breakpointFor(line int) (int, bool) {
+	code := d.chunk.Instructions()
+	found, ok := 0, false
+	for ip := 0; ip < len(code); {
+		if start := d.chunk.Line(ip); start > 0 && start <= line {
+			found, ok = ip, true
+		}
+		_, next := d.chunk.Instruction(ip)
+		if next <= ip {
+			break
+		}
+		ip = next
+	}
+	return found, ok
+}
+
+// printLocals lists the bindings the program has made so far, leaving out
+// the builtins every runtime starts with.
+func (d *vmDebugger) printLocals() {
+	bindings := d.rt.Environment().Snapshot()
+	names := make([]string, 0, len(bindings))
+	for name := range bindings {
+		if !d.builtins[name] {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(d.out, "no locals")
+		return
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(d.out, "%s = %s\n", name, bindings[name].Inspect())
+	}
+}
+
+// scriptArgs returns the arguments following the script name that are passed
+// to the program through os.args(). A leading "--" separator is dropped.
+func scriptArgs(rest []string) []string {
+	if len(rest) > 0 && rest[0] == "--" {
+		return rest[1:]
+	}
+	return rest
+}
+
+// runOptions captures the execution settings for `selene run` after flags and
+// any selected profile have been combined.
+type runOptions struct {
+	backend     string
+	disassemble bool
+	trace       bool
+	step        bool
+	sandbox     bool
+	contracts   bool
+	optimize    int
+}
+
+// withProfile fills in settings from profile that were not given explicitly on
+// the command line.
+func (o runOptions) withProfile(profile project.Profile, explicit map[string]bool) (runOptions, error) {
+	switch profile.Backend {
+	case "", "interp", "vm", "jit":
+	default:
+		return o, fmt.Errorf("profile backend must be interp, vm, or jit (got %q)", profile.Backend)
+	}
+	if !explicit["vm"] && !explicit["jit"] {
+		o.backend = profile.Backend
+		if o.backend == "interp" {
+			o.backend = ""
+		}
+	}
+	if !explicit["disassemble"] {
+		o.disassemble = profile.Disassemble
+	}
+	if !explicit["sandbox"] {
+		o.sandbox = profile.Sandbox
+	}
+	if !explicit["contracts"] {
+		o.contracts = !profile.NoContracts
+	}
+	if !explicit["opt-level"] && profile.SetsOptimize {
+		o.optimize = profile.Optimize
+	}
+	return o, nil
+}
+
+func loadRunProfile(filename, name string) (project.Profile, error) {
+	root, err := project.FindRoot(filename)
+	if err != nil {
+		if errors.Is(err, iofs.ErrNotExist) {
+			return project.Profile{}, fmt.Errorf("--profile %s requires a %s above %s", name, project.ManifestName, filename)
+		}
+		return project.Profile{}, err
+	}
+	manifest, err := project.LoadManifest(root)
+	if err != nil {
+		return project.Profile{}, err
+	}
+	return manifest.LookupProfile(name)
+}
+
+// runCapabilities combines the [capabilities] section of the manifest above
+// filename, if there is one, with --allow and --deny, which take precedence.
+// It returns nil when neither says anything.
+func runCapabilities(filename, allow, deny string) (map[runtime.Capability]bool, error) {
+	capabilities := make(map[runtime.Capability]bool)
+	root, err := project.FindRoot(filename)
+	switch {
+	case err == nil:
+		manifest, err := project.LoadManifest(root)
+		if err != nil {
+			return nil, err
+		}
+		for name, enabled := range manifest.Capabilities {
+			capabilities[runtime.Capability(name)] = enabled
+		}
+	case !errors.Is(err, iofs.ErrNotExist):
+		return nil, err
+	}
+	denied := splitCapabilities(deny)
+	for _, name := range denied {
+		capabilities[name] = false
+	}
+	for _, name := range splitCapabilities(allow) {
+		if slices.Contains(denied, name) {
+			return nil, fmt.Errorf("--allow and --deny both name %s", name)
+		}
+		capabilities[name] = true
+	}
+	if len(capabilities) == 0 {
+		return nil, nil
+	}
+	known := runtime.Capabilities()
+	for name := range capabilities {
+		if !slices.Contains(known, name) {
+			names := make([]string, len(known))
+			for i, capability := range known {
+				names[i] = string(capability)
+			}
+			return nil, fmt.Errorf("unknown capability %s (want one of %s)", name, strings.Join(names, ", "))
+		}
+	}
+	return capabilities, nil
+}
+
+func splitCapabilities(list string) []runtime.Capability {
+	var names []runtime.Capability
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, runtime.Capability(name))
+		}
+	}
+	return names
+}
+
 func testCommand(args []string) error {
 	fs := flag.NewFlagSet("test", flag.ContinueOnError)
 	modeFlag := fs.String("mode", "all", "execution mode: interp, vm, jit, comma-separated list, or all")
 	filter := fs.String("filter", "", "substring filter applied to example relative paths")
+	tagFlag := fs.String("tag", "", "comma-separated tags every selected example must carry")
 	list := fs.Bool("list", false, "list examples without executing them")
 	verbose := fs.Bool("v", false, "print script output for each example")
+	soak := fs.Duration("soak", 0, "loop the suite for this long, checking for heap growth and leaked goroutines")
+	ballastMB := fs.Int("ballast", 64, "megabytes of memory ballast held during --soak")
+	parallel := fs.Int("parallel", 1, "number of examples to run at once")
+	timeout := fs.Duration("timeout", 0, "fail any example that runs longer than this (0 disables)")
+	asJSON := fs.Bool("json", jsonOutput, "print the examples and their results as JSON")
+	failLeaks := fs.Bool("fail-on-leaks", false, "fail examples that leave tasks unjoined or channels unclosed")
 	fs.SetOutput(os.Stderr)
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
-	wd := mustGetwd()
-	root, err := project.FindRoot(wd)
+	scripts, err := discoverExamples(*filter, *tagFlag)
 	if err != nil {
-		if errors.Is(err, iofs.ErrNotExist) {
-			root = wd
-		} else {
-			return err
+		return err
+	}
+	if *list {
+		if *asJSON {
+			listed := make([]exampleListing, len(scripts))
+			for i, script := range scripts {
+				listed[i] = exampleListing{Path: filepath.ToSlash(script.Relative), Tags: script.Tags}
+			}
+			return writeJSON(os.Stdout, listed)
+		}
+		for _, script := range scripts {
+			fmt.Fprintln(os.Stdout, script.Relative)
 		}
+		return nil
 	}
-	exampleRoots, err := examples.ManifestRoots(root)
+	modes, err := parseModes(*modeFlag)
 	if err != nil {
 		return err
 	}
-	scripts, err := examples.Discover(root, exampleRoots)
-	if err != nil {
+	if *soak > 0 {
+		if *asJSON {
+			return errors.New("test --soak does not support --json")
+		}
+		return soakExamples(scripts, modes, *soak, *ballastMB)
+	}
+	opts := examples.SuiteOptions{Parallel: *parallel, Timeout: *timeout, CaptureOutput: *verbose}
+	if *asJSON {
+		return reportExamplesJSON(os.Stdout, scripts, modes, opts, *failLeaks)
+	}
+	return runExamples(scripts, modes, opts, *failLeaks)
+}
+
+// leakFailure is the error an example that passed but leaked fails with
+// under --fail-on-leaks, or nil.
+func leakFailure(result examples.Result, failLeaks bool) error {
+	if result.Err != nil || !failLeaks || len(result.Leaks) == 0 {
+		return nil
+	}
+	return &runtime.LeakError{Leaks: result.Leaks}
+}
+
+// exampleListing describes an example for test --list --json.
+type exampleListing struct {
+	Path string   `json:"path"`
+	Tags []string `json:"tags"`
+}
+
+// exampleReport is the JSON form of examples.Result.
+type exampleReport struct {
+	Example   string   `json:"example"`
+	Mode      string   `json:"mode"`
+	Passed    bool     `json:"passed"`
+	Error     string   `json:"error,omitempty"`
+	Output    string   `json:"output,omitempty"`
+	Leaks     []string `json:"leaks,omitempty"`
+	ElapsedMS float64  `json:"elapsedMs"`
+}
+
+// reportExamplesJSON runs the suite like runExamples, but prints a single
+// JSON document with every result once the suite is done.
+func reportExamplesJSON(w io.Writer, scripts []examples.Script, modes []examples.Mode, opts examples.SuiteOptions, failLeaks bool) error {
+	results := examples.RunSuite(scripts, modes, opts)
+	report := struct {
+		Passed  int             `json:"passed"`
+		Failed  int             `json:"failed"`
+		Results []exampleReport `json:"results"`
+	}{Results: make([]exampleReport, len(results))}
+	for i, result := range results {
+		if err := leakFailure(result, failLeaks); err != nil {
+			result.Err = err
+		}
+		entry := exampleReport{
+			Example:   filepath.ToSlash(result.Script.Relative),
+			Mode:      string(result.Mode),
+			Passed:    result.Err == nil,
+			Output:    result.Output,
+			ElapsedMS: float64(result.Elapsed) / float64(time.Millisecond),
+		}
+		for _, leak := range result.Leaks {
+			entry.Leaks = append(entry.Leaks, leak.String())
+		}
+		if result.Err != nil {
+			entry.Error = result.Err.Error()
+			report.Failed++
+		} else {
+			report.Passed++
+		}
+		report.Results[i] = entry
+	}
+	if err := writeJSON(w, report); err != nil {
 		return err
 	}
-	if *filter != "" {
-		filtered := make([]examples.Script, 0, len(scripts))
-		for _, script := range scripts {
-			if strings.Contains(script.Relative, *filter) {
-				filtered = append(filtered, script)
+	if report.Failed > 0 {
+		return fmt.Errorf("%d example(s) failed", report.Failed)
+	}
+	return nil
+}
+
+func soakExamples(scripts []examples.Script, modes []examples.Mode, duration time.Duration, ballastMB int) error {
+	result := examples.Soak(scripts, examples.SoakOptions{
+		Duration:     duration,
+		Modes:        modes,
+		BallastBytes: ballastMB << 20,
+		Report: func(it examples.SoakIteration) {
+			status := "OK"
+			if len(it.Failures) > 0 || len(it.Leaks) > 0 {
+				status = "FAIL"
+			}
+			fmt.Fprintf(os.Stdout, "[%s] soak iteration %d (%s): heap %.1f MiB, %d goroutines, %d failure(s)\n",
+				status, it.Index, it.Elapsed.Round(time.Second), float64(it.HeapAlloc)/(1<<20), it.Goroutines, len(it.Failures))
+			for _, err := range it.Failures {
+				fmt.Fprintf(os.Stderr, "    %v\n", err)
+			}
+			for _, leak := range it.Leaks {
+				fmt.Fprintf(os.Stderr, "    leak: %s\n", leak)
 			}
+		},
+	})
+	fmt.Fprintf(os.Stdout, "soak finished after %d iteration(s): baseline heap %.1f MiB, peak %.1f MiB\n",
+		result.Iterations, float64(result.BaselineHeap)/(1<<20), float64(result.PeakHeap)/(1<<20))
+	if len(result.Failures) > 0 || len(result.Leaks) > 0 {
+		return fmt.Errorf("soak found %d failure(s) and %d leak(s)", len(result.Failures), len(result.Leaks))
+	}
+	return nil
+}
+
+func examplesCommand(args []string) error {
+	fs := flag.NewFlagSet("examples", flag.ContinueOnError)
+	tagFlag := fs.String("tag", "", "comma-separated tags every selected example must carry")
+	filter := fs.String("filter", "", "substring filter applied to example relative paths")
+	run := fs.Bool("run", false, "execute the selected examples and print their output")
+	modeFlag := fs.String("mode", "interp", "execution mode used with --run: interp, vm, jit, comma-separated list, or all")
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("examples does not take positional arguments")
+	}
+	scripts, err := discoverExamples(*filter, *tagFlag)
+	if err != nil {
+		return err
+	}
+	if *run {
+		modes, err := parseModes(*modeFlag)
+		if err != nil {
+			return err
 		}
-		scripts = filtered
+		return runExamples(scripts, modes, examples.SuiteOptions{CaptureOutput: true}, false)
+	}
+	for _, script := range scripts {
+		fmt.Fprintf(os.Stdout, "%s\t%s\n", script.Relative, strings.Join(script.Tags, ", "))
 	}
-	if len(scripts) == 0 {
-		if *filter != "" {
-			return fmt.Errorf("no examples match filter %q", *filter)
+	return nil
+}
+
+// discoverExamples lists the examples of every workspace member, tagged from
+// the manifests and narrowed by the path filter and comma-separated tag list.
+func discoverExamples(filter, tagList string) ([]examples.Script, error) {
+	ws, err := workspaceOrWD()
+	if err != nil {
+		return nil, err
+	}
+	scripts, err := examples.DiscoverWorkspace(ws)
+	if err != nil {
+		return nil, err
+	}
+	var wanted []string
+	for _, tag := range strings.Split(tagList, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			wanted = append(wanted, tag)
 		}
-		return errors.New("no examples found")
 	}
-	if *list {
-		for _, script := range scripts {
-			fmt.Fprintln(os.Stdout, script.Relative)
+	filtered := make([]examples.Script, 0, len(scripts))
+	for _, script := range scripts {
+		if strings.Contains(script.Relative, filter) && script.HasTags(wanted) {
+			filtered = append(filtered, script)
 		}
-		return nil
 	}
-	modes, err := parseModes(*modeFlag)
-	if err != nil {
-		return err
+	if len(filtered) == 0 {
+		switch {
+		case filter != "" && len(wanted) > 0:
+			return nil, fmt.Errorf("no examples match filter %q with tags %s", filter, strings.Join(wanted, ", "))
+		case filter != "":
+			return nil, fmt.Errorf("no examples match filter %q", filter)
+		case len(wanted) > 0:
+			return nil, fmt.Errorf("no examples tagged %s", strings.Join(wanted, ", "))
+		}
+		return nil, errors.New("no examples found")
 	}
+	return filtered, nil
+}
+
+func runExamples(scripts []examples.Script, modes []examples.Mode, opts examples.SuiteOptions, failLeaks bool) error {
 	var failures int
-	for _, script := range scripts {
-		for _, mode := range modes {
-			var writer io.Writer
-			var buf *bytes.Buffer
-			if *verbose {
-				buf = bytes.NewBuffer(nil)
-				writer = buf
-			} else {
-				writer = io.Discard
-			}
-			if err := examples.Run(script, mode, writer); err != nil {
-				failures++
-				fmt.Fprintf(os.Stderr, "[FAIL] %s (%s): %v\n", script.Relative, mode, err)
-				continue
-			}
-			fmt.Fprintf(os.Stdout, "[OK] %s (%s)\n", script.Relative, mode)
-			if *verbose && buf.Len() > 0 {
-				lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
-				for _, line := range lines {
-					if line == "" {
-						continue
-					}
-					fmt.Fprintf(os.Stdout, "    %s\n", line)
+	opts.Report = func(result examples.Result) {
+		if err := leakFailure(result, failLeaks); err != nil {
+			result.Err = err
+		}
+		for _, leak := range result.Leaks {
+			fmt.Fprintf(os.Stderr, "warning: %s (%s): %s\n", result.Script.Relative, result.Mode, leak)
+		}
+		if result.Err != nil {
+			failures++
+			fmt.Fprintf(os.Stderr, "[FAIL] %s (%s): %v\n", result.Script.Relative, result.Mode, result.Err)
+			return
+		}
+		fmt.Fprintf(os.Stdout, "[OK] %s (%s)\n", result.Script.Relative, result.Mode)
+		if result.Output != "" {
+			lines := strings.Split(strings.TrimRight(result.Output, "\n"), "\n")
+			for _, line := range lines {
+				if line == "" {
+					continue
 				}
+				fmt.Fprintf(os.Stdout, "    %s\n", line)
 			}
 		}
 	}
+	examples.RunSuite(scripts, modes, opts)
 	if failures > 0 {
 		return fmt.Errorf("%d example(s) failed", failures)
 	}
@@ -237,6 +1031,8 @@ func testCommand(args []string) error {
 
 func tokensCommand(args []string) error {
 	fs := flag.NewFlagSet("tokens", flag.ContinueOnError)
+	asJSON := fs.Bool("json", jsonOutput, "print the tokens as a JSON array")
+	count := fs.Bool("count", false, "print how many tokens of each type the file holds instead of the tokens")
 	fs.SetOutput(os.Stderr)
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -244,7 +1040,38 @@ func tokensCommand(args []string) error {
 	if fs.NArg() == 0 {
 		return errors.New("tokens requires a source file")
 	}
-	return dumpTokens(fs.Arg(0))
+	return dumpTokens(fs.Arg(0), tokenDumpOptions{json: *asJSON, count: *count})
+}
+
+// astCommand prints the syntax tree of a file as an outline, or with --json
+// as JSON for tools that cannot link the Go packages.
+func astCommand(args []string) error {
+	fs := flag.NewFlagSet("ast", flag.ContinueOnError)
+	asJSON := fs.Bool("json", jsonOutput, "print the tree as JSON")
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("ast requires a source file")
+	}
+	root, err := projectRootOrWD()
+	if err != nil {
+		return err
+	}
+	resolved, err := resolvePathWithinRoot(root, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	program, _, err := toolchain.ParseFile(resolved)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return writeJSON(os.Stdout, json.RawMessage(ast.JSON(program)))
+	}
+	_, err = fmt.Fprint(os.Stdout, ast.Dump(program))
+	return err
 }
 
 func parseModes(input string) ([]examples.Mode, error) {
@@ -290,15 +1117,11 @@ func fmtCommand(args []string) error {
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
-	if fs.NArg() == 0 {
-		return errors.New("fmt requires at least one file")
-	}
-	root, err := projectRootOrWD()
+	root, files, err := sourceTargets("fmt", fs.Args())
 	if err != nil {
 		return err
 	}
-	for i := 0; i < fs.NArg(); i++ {
-		filename := fs.Arg(i)
+	for i, filename := range files {
 		resolved, err := resolvePathWithinRoot(root, filename)
 		if err != nil {
 			return err
@@ -325,7 +1148,7 @@ func fmtCommand(args []string) error {
 			}
 			continue
 		}
-		if fs.NArg() > 1 {
+		if len(files) > 1 {
 			if i > 0 {
 				fmt.Fprintln(os.Stdout)
 			}
@@ -336,16 +1159,324 @@ func fmtCommand(args []string) error {
 	return nil
 }
 
+// sourceTargets returns the files named on the command line, or every source
+// file of every workspace member when none are given.
+func sourceTargets(command string, args []string) (string, []string, error) {
+	if len(args) > 0 {
+		root, err := projectRootOrWD()
+		return root, args, err
+	}
+	root, err := project.FindWorkspaceRoot(mustGetwd())
+	if errors.Is(err, iofs.ErrNotExist) {
+		return "", nil, fmt.Errorf("%s requires at least one file outside a Selene project", command)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	ws, err := project.LoadWorkspace(root)
+	if err != nil {
+		return "", nil, err
+	}
+	files, err := ws.SourceFiles()
+	return ws.Root, files, err
+}
+
+func checkCommand(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	parallel := fs.Int("parallel", 0, "number of files to analyze at once (default one per CPU)")
+	asJSON := fs.Bool("json", jsonOutput, "print the diagnostics as a JSON array")
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	root, files, err := sourceTargets("check", fs.Args())
+	if err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(files))
+	for _, filename := range files {
+		resolved, err := resolvePathWithinRoot(root, filename)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, resolved)
+	}
+	// Diagnostics are printed in the order files were named, or path order
+	// for a workspace, however the analyses interleave.
+	failed := 0
+	findings := []diagnosticFinding{}
+	for _, file := range lsp.NewAnalyzer(nil).AnalyzeFiles(paths, *parallel) {
+		if file.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", relativeName(root, file.Path), file.Err)
+			failed++
+			continue
+		}
+		hasErrors := false
+		for _, f := range diagnosticFindings(root, file) {
+			severity := "warning"
+			if f.Severity == "error" {
+				severity = "error"
+				hasErrors = true
+			}
+			if *asJSON {
+				findings = append(findings, f)
+				continue
+			}
+			message := f.Message
+			if f.Code != "" {
+				message += " [" + f.Code + "]"
+			}
+			fmt.Fprintf(os.Stdout, "%s:%d:%d: %s: %s\n", f.File, f.Line, f.Column, severity, message)
+		}
+		if hasErrors {
+			failed++
+		}
+	}
+	if *asJSON {
+		if err := writeJSON(os.Stdout, findings); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("check failed for %d of %d files", failed, len(paths))
+	}
+	return nil
+}
+
+// vetSeverities are the severities vet reports, most severe first.
+var vetSeverities = []string{"error", "warning", "information", "hint"}
+
+// diagnosticFinding is a diagnostic reported by check or vet, located in a
+// file named relative to the project root with forward slashes.
+type diagnosticFinding struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Severity  string `json:"severity"`
+	Code      string `json:"code,omitempty"`
+	Message   string `json:"message"`
+}
+
+// vetCommand lints and type checks the whole workspace, or the named files,
+// for CI. Findings are printed as text, JSON, or SARIF, and the command fails
+// when any is at least as severe as --fail-on or a file cannot be analyzed.
+func vetCommand(args []string) error {
+	fs := flag.NewFlagSet("vet", flag.ContinueOnError)
+	format := fs.String("format", "text", "output format: text, json, or sarif")
+	asJSON := fs.Bool("json", jsonOutput, "shorthand for --format json")
+	failOn := fs.String("fail-on", "warning", "lowest severity that fails the command: error, warning, information, hint, or none")
+	parallel := fs.Int("parallel", 0, "number of files to analyze at once (default one per CPU)")
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *asJSON {
+		*format = "json"
+	}
+	switch *format {
+	case "text", "json", "sarif":
+	default:
+		return fmt.Errorf("unknown vet format %q; use text, json, or sarif", *format)
+	}
+	threshold := -1
+	if *failOn != "none" {
+		threshold = slices.Index(vetSeverities, *failOn)
+		if threshold < 0 {
+			return fmt.Errorf("unknown severity %q for --fail-on; use error, warning, information, hint, or none", *failOn)
+		}
+	}
+	root, files, err := sourceTargets("vet", fs.Args())
+	if err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(files))
+	for _, filename := range files {
+		resolved, err := resolvePathWithinRoot(root, filename)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, resolved)
+	}
+	var findings []diagnosticFinding
+	unreadable, failing := 0, 0
+	for _, file := range lsp.NewAnalyzer(nil).AnalyzeFiles(paths, *parallel) {
+		if file.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", relativeName(root, file.Path), file.Err)
+			unreadable++
+			continue
+		}
+		for _, finding := range diagnosticFindings(root, file) {
+			if slices.Index(vetSeverities, finding.Severity) <= threshold {
+				failing++
+			}
+			findings = append(findings, finding)
+		}
+	}
+	if err := writeVetReport(os.Stdout, *format, findings); err != nil {
+		return err
+	}
+	if unreadable > 0 {
+		return fmt.Errorf("vet could not analyze %d of %d files", unreadable, len(paths))
+	}
+	if failing > 0 {
+		return fmt.Errorf("vet failed: %d of %d findings are at or above %s severity", failing, len(findings), *failOn)
+	}
+	return nil
+}
+
+// diagnosticFindings returns the diagnostics of an analyzed file as findings.
+func diagnosticFindings(root string, file lsp.FileAnalysis) []diagnosticFinding {
+	name := relativeName(root, file.Path)
+	findings := make([]diagnosticFinding, 0, len(file.Result.Diagnostics))
+	for _, d := range file.Result.Diagnostics {
+		findings = append(findings, diagnosticFinding{
+			File:      name,
+			Line:      d.Range.Start.Line + 1,
+			Column:    d.Range.Start.Character + 1,
+			EndLine:   d.Range.End.Line + 1,
+			EndColumn: d.Range.End.Character + 1,
+			Severity:  d.SeverityName(),
+			Code:      d.Code,
+			Message:   d.Message,
+		})
+	}
+	return findings
+}
+
+// relativeName names path relative to root with forward slashes, or returns
+// it unchanged when it is not under root.
+func relativeName(root, path string) string {
+	if rel, err := filepath.Rel(root, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+func writeVetReport(w io.Writer, format string, findings []diagnosticFinding) error {
+	switch format {
+	case "json":
+		if findings == nil {
+			findings = []diagnosticFinding{}
+		}
+		return writeJSON(w, findings)
+	case "sarif":
+		return writeJSON(w, vetSARIF(findings))
+	}
+	for _, f := range findings {
+		message := f.Message
+		if f.Code != "" {
+			message += " [" + f.Code + "]"
+		}
+		if _, err := fmt.Fprintf(w, "%s:%d:%d: %s: %s\n", f.File, f.Line, f.Column, f.Severity, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vetSARIF renders findings as a SARIF 2.1.0 log, which code scanning
+// services read. Each diagnostic code becomes a rule described by its
+// selene explain summary.
+func vetSARIF(findings []diagnosticFinding) map[string]any {
+	var rules []map[string]any
+	ruleIndex := make(map[string]int)
+	results := make([]map[string]any, 0, len(findings))
+	for _, f := range findings {
+		level := f.Severity
+		if level == "information" || level == "hint" {
+			level = "note"
+		}
+		result := map[string]any{
+			"level":   level,
+			"message": map[string]string{"text": f.Message},
+			"locations": []map[string]any{{
+				"physicalLocation": map[string]any{
+					"artifactLocation": map[string]string{"uri": f.File},
+					"region": map[string]int{
+						"startLine":   f.Line,
+						"startColumn": f.Column,
+						"endLine":     f.EndLine,
+						"endColumn":   f.EndColumn,
+					},
+				},
+			}},
+		}
+		if f.Code != "" {
+			index, ok := ruleIndex[f.Code]
+			if !ok {
+				index = len(rules)
+				ruleIndex[f.Code] = index
+				rule := map[string]any{"id": f.Code}
+				if summary := explain.Summary(f.Code); summary != "" {
+					rule["shortDescription"] = map[string]string{"text": summary}
+				}
+				rules = append(rules, rule)
+			}
+			result["ruleId"] = f.Code
+			result["ruleIndex"] = index
+		}
+		results = append(results, result)
+	}
+	driver := map[string]any{
+		"name":           "selene",
+		"version":        version,
+		"informationUri": "https://github.com/cybellereaper/selenelang",
+	}
+	if len(rules) > 0 {
+		driver["rules"] = rules
+	}
+	return map[string]any{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []map[string]any{{
+			"tool":    map[string]any{"driver": driver},
+			"results": results,
+		}},
+	}
+}
+
+// explainCommand prints the extended description of a diagnostic code, or
+// lists the codes it knows when none is given.
+func explainCommand(args []string) error {
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch fs.NArg() {
+	case 0:
+		for _, code := range explain.Codes() {
+			fmt.Printf("%-28s %s\n", code, explain.Summary(code))
+		}
+		return nil
+	case 1:
+	default:
+		return errors.New("explain takes a single diagnostic code")
+	}
+	text, ok := explain.Lookup(fs.Arg(0))
+	if !ok {
+		return fmt.Errorf("no explanation for %q; run selene explain to list the known codes", fs.Arg(0))
+	}
+	fmt.Print(text)
+	return nil
+}
+
 func buildCommand(args []string) error {
 	fs := flag.NewFlagSet("build", flag.ContinueOnError)
 	out := fs.String("out", "", "write bytecode listing to the provided file")
 	windowsExe := fs.String("windows-exe", "", "produce a Windows executable that runs via the JIT engine")
+	asJSON := fs.Bool("json", jsonOutput, "describe what was built as JSON, with listings not written to a file")
 	fs.SetOutput(os.Stderr)
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 	if fs.NArg() == 0 {
-		return errors.New("build requires a source file")
+		if *windowsExe != "" {
+			return errors.New("--windows-exe requires a source file")
+		}
+		return buildWorkspace(*out, *asJSON)
 	}
 	root, err := projectRootOrWD()
 	if err != nil {
@@ -356,15 +1487,11 @@ func buildCommand(args []string) error {
 	if err != nil {
 		return err
 	}
-	rt := runtime.New()
-	program, source, err := toolchain.ParseFile(sourcePath)
-	if err != nil {
-		return err
-	}
-	chunk, err := rt.Compile(program)
+	listing, source, err := compileListing(sourcePath)
 	if err != nil {
 		return err
 	}
+	report := buildReport{Source: sourcePath}
 	if *windowsExe != "" {
 		exePath, err := resolvePathWithinRoot(root, *windowsExe)
 		if err != nil {
@@ -374,16 +1501,125 @@ func buildCommand(args []string) error {
 		if err := buildwindows.BuildExecutable(startDir, filepath.Base(sourcePath), source, exePath); err != nil {
 			return err
 		}
-	}
-	listing := chunk.Disassemble()
-	if *out != "" {
-		outPath, err := resolvePathWithinRoot(root, *out)
+		report.Executable = exePath
+	}
+	if *out != "" {
+		outPath, err := resolvePathWithinRoot(root, *out)
+		if err != nil {
+			return err
+		}
+		if err := writeFileSecure(outPath, []byte(listing)); err != nil {
+			return err
+		}
+		report.Output = outPath
+	} else {
+		report.Listing = listing
+	}
+	if *asJSON {
+		return writeJSON(os.Stdout, []buildReport{report})
+	}
+	if *out == "" {
+		fmt.Print(listing)
+	}
+	return nil
+}
+
+// buildReport describes one compiled entry point for build --json. Listing
+// holds the bytecode listing when it was not written to Output.
+type buildReport struct {
+	Member     string `json:"member,omitempty"`
+	Source     string `json:"source"`
+	Output     string `json:"output,omitempty"`
+	Executable string `json:"executable,omitempty"`
+	Listing    string `json:"listing,omitempty"`
+}
+
+func compileListing(sourcePath string) (string, string, error) {
+	program, source, err := toolchain.ParseFile(sourcePath)
+	if err != nil {
+		return "", "", err
+	}
+	chunk, err := runtime.New().Compile(program)
+	if err != nil {
+		return "", "", err
+	}
+	return chunk.Disassemble(), source, nil
+}
+
+// buildWorkspace compiles the entry point of every workspace member. With
+// out set, each listing is written to out/<member>.bc; otherwise the listings
+// are printed one after another. With asJSON, a buildReport is printed for
+// each member instead.
+func buildWorkspace(out string, asJSON bool) error {
+	root, err := project.FindWorkspaceRoot(mustGetwd())
+	if errors.Is(err, iofs.ErrNotExist) {
+		return errors.New("build requires a source file outside a Selene project")
+	}
+	if err != nil {
+		return err
+	}
+	ws, err := project.LoadWorkspace(root)
+	if err != nil {
+		return err
+	}
+	var outDir string
+	if out != "" {
+		if outDir, err = resolvePathWithinRoot(ws.Root, out); err != nil {
+			return err
+		}
+		if err := mkdirAllSecure(outDir); err != nil {
+			return err
+		}
+	}
+	built := 0
+	var reports []buildReport
+	for _, member := range ws.Members {
+		if member.Manifest.Project.Entry == "" {
+			continue
+		}
+		sourcePath, err := resolvePathWithinRoot(member.Dir, filepath.Join(member.Dir, member.Manifest.Project.Entry))
+		if err != nil {
+			return err
+		}
+		listing, _, err := compileListing(sourcePath)
+		if err != nil {
+			return fmt.Errorf("%s: %w", member.Path, err)
+		}
+		built++
+		report := buildReport{Member: member.Path, Source: sourcePath}
+		if outDir == "" {
+			if asJSON {
+				report.Listing = listing
+				reports = append(reports, report)
+				continue
+			}
+			if built > 1 {
+				fmt.Fprintln(os.Stdout)
+			}
+			fmt.Fprintf(os.Stdout, "// %s\n", sourcePath)
+			fmt.Fprint(os.Stdout, listing)
+			continue
+		}
+		name := member.Manifest.Project.Name
+		if name == "" {
+			name = filepath.Base(member.Dir)
+		}
+		outPath, err := project.ResolveUnderRoot(outDir, name+".bc")
 		if err != nil {
 			return err
 		}
-		return writeFileSecure(outPath, []byte(listing))
+		if err := writeFileSecure(outPath, []byte(listing)); err != nil {
+			return err
+		}
+		report.Output = outPath
+		reports = append(reports, report)
+	}
+	if built == 0 {
+		return errors.New("no workspace member declares an entry in selene.toml")
+	}
+	if asJSON {
+		return writeJSON(os.Stdout, reports)
 	}
-	fmt.Print(listing)
 	return nil
 }
 
@@ -411,10 +1647,16 @@ func transpileCommand(args []string) error {
 	if err != nil {
 		return err
 	}
-	var output string
+	var (
+		output string
+		header bool
+	)
 	switch strings.ToLower(*lang) {
 	case "go":
 		output, err = transpile.ToGo(program)
+	case "c":
+		output, err = transpile.ToC(program)
+		header = true
 	default:
 		return fmt.Errorf("unsupported target language %q", *lang)
 	}
@@ -426,6 +1668,13 @@ func transpileCommand(args []string) error {
 		if err != nil {
 			return err
 		}
+		if header {
+			// C output includes the runtime header from its own directory.
+			headerPath := filepath.Join(filepath.Dir(outPath), transpile.CHeaderName)
+			if err := writeFileSecure(headerPath, []byte(transpile.CHeader())); err != nil {
+				return err
+			}
+		}
 		return writeFileSecure(outPath, []byte(output))
 	}
 	fmt.Print(output)
@@ -459,14 +1708,14 @@ func initCommand(args []string) error {
 	manifest.Project.Name = projectName
 	manifest.Project.Version = "0.1.0"
 	manifest.Project.Module = modulePath
-	manifest.Project.Entry = filepath.ToSlash(filepath.Join("src", "main.selene"))
+	manifest.Project.Entry = filepath.ToSlash(filepath.Join(project.SourceDirectory, "main.selene"))
 	manifest.Docs.Paths = []string{"docs", "README.md"}
 	manifest.Examples.Roots = []string{"examples"}
 	manifest.Dependencies = make(map[string]project.Dependency)
 	if err := project.SaveManifest(cwd, manifest); err != nil {
 		return err
 	}
-	srcDir, err := project.ResolveUnderRoot(cwd, "src")
+	srcDir, err := project.ResolveUnderRoot(cwd, project.SourceDirectory)
 	if err != nil {
 		return err
 	}
@@ -488,7 +1737,7 @@ func initCommand(args []string) error {
 		return err
 	}
 	mainSource := "package main;\n\nfn main() {\n    print(\"Hello from " + projectName + "!\");\n}\n"
-	entryPath, err := project.ResolveUnderRoot(cwd, filepath.Join("src", "main.selene"))
+	entryPath, err := project.ResolveUnderRoot(cwd, filepath.Join(project.SourceDirectory, "main.selene"))
 	if err != nil {
 		return err
 	}
@@ -501,48 +1750,220 @@ func initCommand(args []string) error {
 
 func depsCommand(args []string) error {
 	if len(args) == 0 {
-		return errors.New("deps requires a subcommand: add, list, verify")
+		return errors.New("deps requires a subcommand: add, list, graph, verify, vendor, update, outdated")
 	}
 	switch args[0] {
 	case "add":
 		return depsAdd(args[1:])
 	case "list":
 		return depsList(args[1:])
+	case "graph":
+		return depsGraph(args[1:])
 	case "verify":
 		return depsVerify(args[1:])
+	case "vendor":
+		return depsVendor(args[1:])
+	case "update":
+		return depsUpdate(args[1:])
+	case "outdated":
+		return depsOutdated(args[1:])
 	default:
 		return fmt.Errorf("unknown deps subcommand %q", args[0])
 	}
 }
 
+func cacheCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("cache requires a subcommand: clean")
+	}
+	switch args[0] {
+	case "clean":
+		if len(args) != 1 {
+			return errors.New("cache clean does not take additional arguments")
+		}
+		root, err := projectRootOrWD()
+		if err != nil {
+			return err
+		}
+		if err := cache.Clean(root); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "removed %s\n", filepath.Join(root, cache.DirName))
+		return nil
+	default:
+		return fmt.Errorf("unknown cache subcommand %q", args[0])
+	}
+}
+
+func perfCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("perf requires a subcommand: report, path, or clear")
+	}
+	path, err := perf.Path()
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "report":
+		return perfReport(path, args[1:])
+	case "path":
+		fmt.Println(path)
+		return nil
+	case "clear":
+		if len(args) != 1 {
+			return errors.New("perf clear does not take additional arguments")
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, iofs.ErrNotExist) {
+			return err
+		}
+		fmt.Printf("removed %s\n", path)
+		return nil
+	default:
+		return fmt.Errorf("unknown perf subcommand %q", args[0])
+	}
+}
+
+func perfReport(path string, args []string) error {
+	fs := flag.NewFlagSet("perf report", flag.ContinueOnError)
+	command := fs.String("command", "", "only summarize this command")
+	since := fs.Duration("since", 0, "only summarize invocations this recent, such as 24h")
+	jsonOut := fs.Bool("json", jsonOutput, "print the summaries as JSON")
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New("perf report does not accept positional arguments")
+	}
+	records, err := perf.ReadRecords(path)
+	if err != nil {
+		return err
+	}
+	var cutoff time.Time
+	if *since > 0 {
+		cutoff = time.Now().Add(-*since)
+	}
+	summaries := perf.Summarize(perf.Filter(records, *command, cutoff))
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(summaries)
+	}
+	if len(records) == 0 && !perf.Enabled() {
+		fmt.Fprintf(os.Stderr, "recording is off; set %s=1 to record invocations in %s\n", perf.EnableVariable, path)
+	}
+	perf.WriteReport(os.Stdout, summaries)
+	return nil
+}
+
+func selfCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("self requires a subcommand: update")
+	}
+	switch args[0] {
+	case "update":
+		return selfUpdate(args[1:])
+	default:
+		return fmt.Errorf("unknown self subcommand %q", args[0])
+	}
+}
+
+func selfUpdate(args []string) error {
+	fs := flag.NewFlagSet("self update", flag.ContinueOnError)
+	channel := fs.String("channel", "stable", "release channel to install from (stable or nightly)")
+	check := fs.Bool("check", false, "report the latest release without installing it")
+	force := fs.Bool("force", false, "reinstall even when the latest release matches this binary")
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New("self update does not accept positional arguments")
+	}
+	// SELENE_UPDATE_URL points the updater at a mirror of the release channels.
+	updater := &selfupdate.Updater{Endpoint: os.Getenv("SELENE_UPDATE_URL")}
+	ctx := context.Background()
+	manifest, err := updater.Latest(ctx, *channel)
+	if err != nil {
+		return err
+	}
+	if manifest.Version == version && !*force {
+		fmt.Fprintf(os.Stdout, "selene %s is the latest %s release\n", version, *channel)
+		return nil
+	}
+	if *check {
+		fmt.Fprintf(os.Stdout, "selene %s is available on %s (current %s)\n", manifest.Version, *channel, version)
+		return nil
+	}
+	asset, err := updater.Asset(manifest)
+	if err != nil {
+		return err
+	}
+	target, err := selfupdate.Executable()
+	if err != nil {
+		return err
+	}
+	if err := updater.Install(ctx, asset, target); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "updated %s from %s to %s (%s)\n", target, version, manifest.Version, *channel)
+	return nil
+}
+
+// lspOptions configures `selene lsp`.
+type lspOptions struct {
+	// logFile is appended to; empty means stderr, which editors show in the
+	// language server's output channel.
+	logFile string
+	trace   lsp.TraceLevel
+}
+
 func lspCommand(args []string) error {
-	if err := validateLSPArgs(args); err != nil {
+	opts, err := parseLSPArgs(args)
+	if err != nil {
 		return err
 	}
 	server := lsp.NewServer(os.Stdin, os.Stdout)
+	server.SetVersion(version)
+	logOutput := io.Writer(os.Stderr)
+	if opts.logFile != "" {
+		file, err := os.OpenFile(opts.logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		logOutput = file
+	}
+	server.SetLog(logOutput, opts.trace)
 	return server.Run()
 }
 
-func validateLSPArgs(args []string) error {
+func parseLSPArgs(args []string) (lspOptions, error) {
 	fs := flag.NewFlagSet("lsp", flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
 	useStdio := fs.Bool("stdio", true, "communicate with the language client over stdio")
+	logFile := fs.String("log-file", "", "append the server log to this file instead of stderr")
+	trace := fs.String("trace", "off", "log JSON-RPC traffic: off, messages, or verbose")
 	if err := fs.Parse(args); err != nil {
-		return err
+		return lspOptions{}, err
 	}
 	if fs.NArg() > 0 {
-		return errors.New("lsp does not accept positional arguments")
+		return lspOptions{}, errors.New("lsp does not accept positional arguments")
 	}
 	if !*useStdio {
-		return errors.New("selene language server requires stdio transport")
+		return lspOptions{}, errors.New("selene language server requires stdio transport")
 	}
-	return nil
+	level, err := lsp.ParseTraceLevel(*trace)
+	if err != nil {
+		return lspOptions{}, err
+	}
+	return lspOptions{logFile: *logFile, trace: level}, nil
 }
 
 func depsAdd(args []string) error {
 	fs := flag.NewFlagSet("deps add", flag.ContinueOnError)
 	srcPath := fs.String("path", "", "path to dependency sources (optional when using --source)")
-	sourceURL := fs.String("source", "", "repository URL or module mirror used to fetch sources")
+	sourceURL := fs.String("source", "", "repository URL, or registry:<url> for a module registry, used to fetch sources")
 	fs.SetOutput(os.Stderr)
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -563,42 +1984,138 @@ func depsAdd(args []string) error {
 	if err != nil {
 		return err
 	}
-	dep, lockEntry, err := project.PrepareDependency(root, module, version, *sourceURL, *srcPath)
+	lockRoot, err := project.FindWorkspaceRoot(root)
+	if err != nil {
+		return err
+	}
+	resolved := version
+	if constraint, err := project.ParseConstraint(version); err == nil && constraint.IsRange() {
+		if *srcPath != "" {
+			return errors.New("--path needs an exact version; ranges are resolved against the tags of --source")
+		}
+		resolved, err = project.ResolveVersion(module, project.Dependency{Version: version, Source: *sourceURL})
+		if err != nil {
+			return err
+		}
+	}
+	dep, lockEntry, err := project.PrepareDependency(lockRoot, module, resolved, *sourceURL, *srcPath)
 	if err != nil {
 		return err
 	}
+	dep.Version = version
 	manifest.Dependencies[module] = dep
 	if err := project.SaveManifest(root, manifest); err != nil {
 		return err
 	}
-	lockfile, err := project.LoadLockfile(root)
+	lockfile, err := project.LoadLockfile(lockRoot)
 	if err != nil {
 		return err
 	}
 	lockfile.Set(lockEntry)
-	if err := project.SaveLockfile(root, lockfile); err != nil {
+	if err := project.SaveLockfile(lockRoot, lockfile); err != nil {
 		return err
 	}
-	fmt.Fprintf(os.Stdout, "added %s %s (checksum %s, vendor %s)\n", module, version, lockEntry.Checksum, lockEntry.Vendor)
+	fmt.Fprintf(os.Stdout, "added %s %s (checksum %s, vendor %s)\n", module, resolved, lockEntry.Checksum, lockEntry.Vendor)
 	return nil
 }
 
-func depsList(args []string) error {
-	if len(args) != 0 {
-		return errors.New("deps list does not take additional arguments")
+func depsUpdate(args []string) error {
+	fs := flag.NewFlagSet("deps update", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
 	}
-	root, err := project.FindRoot(mustGetwd())
+	if fs.NArg() > 1 {
+		return errors.New("deps update takes at most one module path")
+	}
+	root, manifest, lockfile, err := loadProjectDependencies()
 	if err != nil {
-		return fmt.Errorf("cannot locate selene.toml: %w", err)
+		return err
 	}
-	manifest, err := project.LoadManifest(root)
+	modules := project.SortedModules(manifest.Dependencies)
+	if fs.NArg() == 1 {
+		module := fs.Arg(0)
+		if _, ok := manifest.Dependencies[module]; !ok {
+			return fmt.Errorf("%s is not a dependency in selene.toml", module)
+		}
+		modules = []string{module}
+	}
+	for _, module := range modules {
+		from, to, err := project.UpdateDependency(root, module, manifest.Dependencies[module], lockfile)
+		if err != nil {
+			return err
+		}
+		switch {
+		case from == to:
+			fmt.Fprintf(os.Stdout, "%s is up to date (%s)\n", module, to)
+		case from == "":
+			fmt.Fprintf(os.Stdout, "locked %s %s\n", module, to)
+		default:
+			fmt.Fprintf(os.Stdout, "updated %s %s -> %s\n", module, from, to)
+		}
+	}
+	return project.SaveLockfile(root, lockfile)
+}
+
+func depsOutdated(args []string) error {
+	fs := flag.NewFlagSet("deps outdated", flag.ContinueOnError)
+	jsonFlag := fs.Bool("json", jsonOutput, "print the version status of every dependency as JSON")
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("deps outdated does not take additional arguments")
+	}
+	_, manifest, lockfile, err := loadProjectDependencies()
+	if err != nil {
+		return err
+	}
+	statuses, err := project.CheckVersions(manifest, lockfile)
 	if err != nil {
 		return err
 	}
-	lockfile, err := project.LoadLockfile(root)
+	if *jsonFlag {
+		return writeJSON(os.Stdout, statuses)
+	}
+	var outdated []project.VersionStatus
+	for _, status := range statuses {
+		if status.Outdated() {
+			outdated = append(outdated, status)
+		}
+	}
+	if len(outdated) == 0 {
+		fmt.Fprintln(os.Stdout, "(all dependencies are up to date)")
+		return nil
+	}
+	fmt.Fprintf(os.Stdout, "MODULE\tCONSTRAINT\tCURRENT\tWANTED\tLATEST\n")
+	for _, status := range outdated {
+		fmt.Fprintf(os.Stdout, "%s\t%s\t%s\t%s\t%s\n", status.Module, status.Constraint, status.Current, status.Wanted, status.Latest)
+	}
+	return nil
+}
+
+func depsList(args []string) error {
+	fs := flag.NewFlagSet("deps list", flag.ContinueOnError)
+	jsonFlag := fs.Bool("json", jsonOutput, "print dependencies, lock data, and checksum status as JSON")
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("deps list does not take additional arguments")
+	}
+	root, manifest, lockfile, err := loadProjectDependencies()
 	if err != nil {
 		return err
 	}
+	if *jsonFlag {
+		statuses, err := project.DescribeDependencies(root, manifest, lockfile)
+		if err != nil {
+			return err
+		}
+		return writeJSON(os.Stdout, statuses)
+	}
 	modules := project.SortedModules(manifest.Dependencies)
 	if len(modules) == 0 {
 		fmt.Fprintln(os.Stdout, "(no dependencies)")
@@ -613,19 +2130,88 @@ func depsList(args []string) error {
 	return nil
 }
 
-func depsVerify(args []string) error {
-	if len(args) != 0 {
-		return errors.New("deps verify does not take additional arguments")
+func depsGraph(args []string) error {
+	fs := flag.NewFlagSet("deps graph", flag.ContinueOnError)
+	dotFlag := fs.Bool("dot", false, "render the graph in Graphviz DOT format")
+	jsonFlag := fs.Bool("json", jsonOutput, "print the graph edges as JSON")
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
 	}
+	if fs.NArg() != 0 {
+		return errors.New("deps graph does not take additional arguments")
+	}
+	if *dotFlag && *jsonFlag {
+		return errors.New("deps graph accepts only one of --dot and --json")
+	}
+	root, manifest, lockfile, err := loadProjectDependencies()
+	if err != nil {
+		return err
+	}
+	graph, err := project.BuildDependencyGraph(root, manifest, lockfile)
+	if err != nil {
+		return err
+	}
+	switch {
+	case *dotFlag:
+		return graph.WriteDOT(os.Stdout)
+	case *jsonFlag:
+		return writeJSON(os.Stdout, graph)
+	}
+	if len(graph.Edges) == 0 {
+		fmt.Fprintln(os.Stdout, "(no dependencies)")
+		return nil
+	}
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(os.Stdout, "%s -> %s %s\n", edge.From, edge.To, edge.Version)
+	}
+	return nil
+}
+
+// loadProjectDependencies loads the current package's manifest along with the
+// lockfile it shares with its workspace, returning the directory that holds
+// the lockfile and vendor tree. At a workspace root the manifest carries the
+// combined requirements of every member.
+func loadProjectDependencies() (string, *project.Manifest, *project.Lockfile, error) {
 	root, err := project.FindRoot(mustGetwd())
 	if err != nil {
-		return fmt.Errorf("cannot locate selene.toml: %w", err)
+		return "", nil, nil, fmt.Errorf("cannot locate selene.toml: %w", err)
 	}
 	manifest, err := project.LoadManifest(root)
 	if err != nil {
-		return err
+		return "", nil, nil, err
+	}
+	lockRoot, err := project.FindWorkspaceRoot(root)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if len(manifest.Workspace.Members) > 0 {
+		ws, err := project.LoadWorkspace(lockRoot)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		if manifest.Dependencies, err = ws.Dependencies(); err != nil {
+			return "", nil, nil, err
+		}
+	}
+	lockfile, err := project.LoadLockfile(lockRoot)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return lockRoot, manifest, lockfile, nil
+}
+
+func writeJSON(w io.Writer, value any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(value)
+}
+
+func depsVerify(args []string) error {
+	if len(args) != 0 {
+		return errors.New("deps verify does not take additional arguments")
 	}
-	lockfile, err := project.LoadLockfile(root)
+	root, manifest, lockfile, err := loadProjectDependencies()
 	if err != nil {
 		return err
 	}
@@ -635,6 +2221,9 @@ func depsVerify(args []string) error {
 		if !ok {
 			return fmt.Errorf("dependency %s is missing from selene.lock", module)
 		}
+		if err := project.CheckLocked(module, manifest.Dependencies[module], locked); err != nil {
+			return fmt.Errorf("%w (run selene deps update)", err)
+		}
 		vendorPath, err := project.ResolveUnderRoot(root, locked.Vendor)
 		if err != nil {
 			return err
@@ -647,7 +2236,66 @@ func depsVerify(args []string) error {
 	return nil
 }
 
-func dumpTokens(filename string) error {
+func depsVendor(args []string) error {
+	fs := flag.NewFlagSet("deps vendor", flag.ContinueOnError)
+	jsonFlag := fs.Bool("json", jsonOutput, "print what was verified, restored, and removed as JSON")
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("deps vendor does not take additional arguments")
+	}
+	root, manifest, lockfile, err := loadProjectDependencies()
+	if err != nil {
+		return err
+	}
+	synced, err := project.SyncVendor(root, manifest.Dependencies, lockfile)
+	if err != nil {
+		return err
+	}
+	if *jsonFlag {
+		return writeJSON(os.Stdout, synced)
+	}
+	for _, module := range synced.Restored {
+		locked, _ := lockfile.Lookup(module)
+		fmt.Fprintf(os.Stdout, "restored %s@%s\n", module, locked.Version)
+	}
+	for _, path := range synced.Removed {
+		fmt.Fprintf(os.Stdout, "removed %s\n", path)
+	}
+	fmt.Fprintf(os.Stdout, "%s/ matches selene.lock (%d dependencies)\n", project.VendorDirectory, len(manifest.Dependencies))
+	return nil
+}
+
+// tokenDumpOptions selects what dumpTokens prints.
+type tokenDumpOptions struct {
+	json  bool
+	count bool
+}
+
+// tokenPosition is a token.Position as selene tokens --json prints it, in
+// the same shape as the positions of selene ast --json.
+type tokenPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+type tokenRecord struct {
+	Type    token.Type    `json:"type"`
+	Literal string        `json:"literal"`
+	Start   tokenPosition `json:"start"`
+	End     tokenPosition `json:"end"`
+}
+
+// tokenCount is one line of selene tokens --count.
+type tokenCount struct {
+	Type  token.Type `json:"type"`
+	Count int        `json:"count"`
+}
+
+func dumpTokens(filename string, opts tokenDumpOptions) error {
 	root, err := projectRootOrWD()
 	if err != nil {
 		return err
@@ -656,17 +2304,105 @@ func dumpTokens(filename string) error {
 	if err != nil {
 		return err
 	}
-	content, err := readFileSecure(resolved)
+	file, err := openFileSecure(resolved)
 	if err != nil {
 		return fmt.Errorf("failed to read %s: %w", resolved, err)
 	}
-	l := lexer.New(string(content))
-	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
-		fmt.Printf("%s\t%q\n", tok.Type, tok.Literal)
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", resolved, err)
+	}
+	var l *lexer.Lexer
+	if info.Size() > toolchain.StreamThreshold {
+		l = lexer.NewReader(file)
+	} else {
+		content, err := io.ReadAll(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", resolved, err)
+		}
+		l = lexer.New(string(content))
+	}
+	if err := writeTokens(os.Stdout, l, opts); err != nil {
+		return err
+	}
+	if err := l.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", resolved, err)
 	}
 	return nil
 }
 
+// writeTokens prints the tokens l produces, one per line with its type,
+// literal, span, and byte offsets, or with opts.json as a JSON array with
+// one token per line. Tokens are written as they are lexed, so large files
+// stream. With opts.count it prints how many tokens of each type there
+// are, most frequent first, followed by the total.
+func writeTokens(w io.Writer, l *lexer.Lexer, opts tokenDumpOptions) error {
+	out := bufio.NewWriter(w)
+	var record bytes.Buffer
+	enc := json.NewEncoder(&record)
+	enc.SetEscapeHTML(false)
+	counts := make(map[token.Type]int)
+	total := 0
+	if opts.json && !opts.count {
+		out.WriteString("[")
+	}
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch {
+		case opts.count:
+			counts[tok.Type]++
+		case opts.json:
+			record.Reset()
+			err := enc.Encode(tokenRecord{
+				Type:    tok.Type,
+				Literal: tok.Literal,
+				Start:   tokenPosition{tok.Pos.Line, tok.Pos.Column, tok.Pos.Offset},
+				End:     tokenPosition{tok.End.Line, tok.End.Column, tok.End.Offset},
+			})
+			if err != nil {
+				return err
+			}
+			if total > 0 {
+				out.WriteString(",")
+			}
+			out.WriteString("\n  ")
+			out.Write(bytes.TrimSuffix(record.Bytes(), []byte("\n")))
+		default:
+			fmt.Fprintf(out, "%s\t%q\t%s-%s\t%d-%d\n", tok.Type, tok.Literal, tok.Pos, tok.End, tok.Pos.Offset, tok.End.Offset)
+		}
+		total++
+	}
+	switch {
+	case opts.count:
+		summary := make([]tokenCount, 0, len(counts))
+		for typ, n := range counts {
+			summary = append(summary, tokenCount{typ, n})
+		}
+		slices.SortFunc(summary, func(a, b tokenCount) int {
+			return cmp.Or(b.Count-a.Count, strings.Compare(string(a.Type), string(b.Type)))
+		})
+		if opts.json {
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			return writeJSON(w, struct {
+				Total int          `json:"total"`
+				Types []tokenCount `json:"types"`
+			}{total, summary})
+		}
+		for _, c := range summary {
+			fmt.Fprintf(out, "%s\t%d\n", c.Type, c.Count)
+		}
+		fmt.Fprintf(out, "total\t%d\n", total)
+	case opts.json:
+		if total > 0 {
+			out.WriteString("\n")
+		}
+		out.WriteString("]\n")
+	}
+	return out.Flush()
+}
+
 func projectRootOrWD() (string, error) {
 	wd := mustGetwd()
 	root, err := project.FindRoot(wd)
@@ -679,6 +2415,21 @@ func projectRootOrWD() (string, error) {
 	return root, nil
 }
 
+// workspaceOrWD loads the workspace containing the working directory. Outside
+// any project it returns a workspace with a single, manifest-less member at
+// the working directory.
+func workspaceOrWD() (*project.Workspace, error) {
+	wd := mustGetwd()
+	root, err := project.FindWorkspaceRoot(wd)
+	if err != nil {
+		if errors.Is(err, iofs.ErrNotExist) {
+			return &project.Workspace{Root: wd, Members: []project.Member{{Dir: wd, Path: ".", Manifest: &project.Manifest{}}}}, nil
+		}
+		return nil, err
+	}
+	return project.LoadWorkspace(root)
+}
+
 func resolvePathWithinRoot(root, candidate string) (string, error) {
 	absRoot, err := filepath.Abs(root)
 	if err != nil {
@@ -704,6 +2455,11 @@ func readFileSecure(path string) ([]byte, error) {
 	return os.ReadFile(path)
 }
 
+func openFileSecure(path string) (*os.File, error) {
+	// #nosec G304 -- path is produced by resolvePathWithinRoot which constrains access to the project root.
+	return os.Open(path)
+}
+
 func writeFileSecure(path string, data []byte) error {
 	return os.WriteFile(path, data, 0o600)
 }
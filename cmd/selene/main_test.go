@@ -1,8 +1,24 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
 
-func TestValidateLSPArgs(t *testing.T) {
+	"github.com/cybellereaper/selenelang/internal/examples"
+	"github.com/cybellereaper/selenelang/internal/lexer"
+	"github.com/cybellereaper/selenelang/internal/parser"
+	"github.com/cybellereaper/selenelang/internal/project"
+	"github.com/cybellereaper/selenelang/internal/runtime"
+)
+
+func TestParseLSPArgs(t *testing.T) {
 	tests := []struct {
 		name    string
 		args    []string
@@ -13,18 +29,314 @@ func TestValidateLSPArgs(t *testing.T) {
 		{name: "stdio true", args: []string{"--stdio=true"}, wantErr: false},
 		{name: "stdio false", args: []string{"--stdio=false"}, wantErr: true},
 		{name: "positional", args: []string{"extra"}, wantErr: true},
+		{name: "log file and trace", args: []string{"--log-file", "lsp.log", "--trace", "verbose"}, wantErr: false},
+		{name: "unknown trace level", args: []string{"--trace", "loud"}, wantErr: true},
 	}
 
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			err := validateLSPArgs(tt.args)
+			_, err := parseLSPArgs(tt.args)
 			if tt.wantErr && err == nil {
-				t.Fatalf("validateLSPArgs(%v) = nil error, want error", tt.args)
+				t.Fatalf("parseLSPArgs(%v) = nil error, want error", tt.args)
 			}
 			if !tt.wantErr && err != nil {
-				t.Fatalf("validateLSPArgs(%v) unexpected error: %v", tt.args, err)
+				t.Fatalf("parseLSPArgs(%v) unexpected error: %v", tt.args, err)
 			}
 		})
 	}
 }
+
+func TestRunOptionsWithProfile(t *testing.T) {
+	release := project.Profile{Backend: "vm", Disassemble: true}
+	opts, err := runOptions{}.withProfile(release, nil)
+	if err != nil {
+		t.Fatalf("withProfile returned error: %v", err)
+	}
+	if opts.backend != "vm" || !opts.disassemble {
+		t.Fatalf("expected profile settings to apply, got %+v", opts)
+	}
+
+	explicit := map[string]bool{"jit": true, "disassemble": true}
+	opts, err = runOptions{backend: "jit"}.withProfile(release, explicit)
+	if err != nil {
+		t.Fatalf("withProfile returned error: %v", err)
+	}
+	if opts.backend != "jit" || opts.disassemble {
+		t.Fatalf("expected explicit flags to win over the profile, got %+v", opts)
+	}
+
+	if _, err := (runOptions{}).withProfile(project.Profile{Backend: "wasm"}, nil); err == nil {
+		t.Fatalf("expected unknown backend to be rejected")
+	}
+}
+
+func TestRunOptionsWithProfileContractsAndOptimize(t *testing.T) {
+	release := project.Profile{NoContracts: true, Optimize: 0, SetsOptimize: true}
+	defaults := runOptions{contracts: true, optimize: 1}
+	opts, err := defaults.withProfile(release, nil)
+	if err != nil {
+		t.Fatalf("withProfile returned error: %v", err)
+	}
+	if opts.contracts || opts.optimize != 0 {
+		t.Fatalf("expected profile settings to apply, got %+v", opts)
+	}
+
+	opts, err = defaults.withProfile(release, map[string]bool{"contracts": true, "opt-level": true})
+	if err != nil {
+		t.Fatalf("withProfile returned error: %v", err)
+	}
+	if !opts.contracts || opts.optimize != 1 {
+		t.Fatalf("expected explicit flags to win over the profile, got %+v", opts)
+	}
+
+	opts, err = defaults.withProfile(project.Profile{}, nil)
+	if err != nil {
+		t.Fatalf("withProfile returned error: %v", err)
+	}
+	if !opts.contracts || opts.optimize != 1 {
+		t.Fatalf("expected a profile without the settings to keep the defaults, got %+v", opts)
+	}
+}
+
+func TestScriptArgs(t *testing.T) {
+	tests := []struct {
+		rest []string
+		want string
+	}{
+		{rest: nil, want: ""},
+		{rest: []string{"--", "a", "--b"}, want: "a,--b"},
+		{rest: []string{"a", "--", "b"}, want: "a,--,b"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(scriptArgs(tt.rest), ","); got != tt.want {
+			t.Fatalf("scriptArgs(%v) = %q, want %q", tt.rest, got, tt.want)
+		}
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		text string
+		want uint64
+	}{
+		{text: "", want: 0},
+		{text: "4096", want: 4096},
+		{text: "512K", want: 512 << 10},
+		{text: "256m", want: 256 << 20},
+		{text: "2G", want: 2 << 30},
+	}
+	for _, tt := range tests {
+		if got, err := parseByteSize(tt.text); err != nil || got != tt.want {
+			t.Fatalf("parseByteSize(%q) = %d, %v, want %d", tt.text, got, err, tt.want)
+		}
+	}
+	for _, text := range []string{"M", "12Q", "-1K", "99999999999999999999G"} {
+		if _, err := parseByteSize(text); err == nil {
+			t.Fatalf("expected parseByteSize(%q) to fail", text)
+		}
+	}
+}
+
+func TestRunCapabilitiesLayersFlagsOverManifest(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, project.ManifestName), []byte("[project]\nname = \"demo\"\n\n[capabilities]\nfs = false\nenv = false\n"), 0o644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	script := filepath.Join(dir, "main.sel")
+	if err := os.WriteFile(script, []byte("print(1)\n"), 0o644); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	got, err := runCapabilities(script, "fs", "os.exec")
+	if err != nil {
+		t.Fatalf("runCapabilities returned error: %v", err)
+	}
+	want := map[runtime.Capability]bool{runtime.CapabilityFS: true, runtime.CapabilityEnv: false, runtime.CapabilityExec: false}
+	if !maps.Equal(got, want) {
+		t.Fatalf("runCapabilities = %v, want %v", got, want)
+	}
+	if _, err := runCapabilities(script, "net", "net"); err == nil {
+		t.Fatalf("expected a capability both allowed and denied to be rejected")
+	}
+	if _, err := runCapabilities(script, "", "gpu"); err == nil || !strings.Contains(err.Error(), "env, fs, net, os.exec") {
+		t.Fatalf("expected an unknown capability error listing the capabilities, got %v", err)
+	}
+	if got, err := runCapabilities(filepath.Join(t.TempDir(), "x.sel"), "", ""); err != nil || got != nil {
+		t.Fatalf("expected no capabilities without a manifest or flags, got %v, %v", got, err)
+	}
+}
+
+func TestVetReportFormats(t *testing.T) {
+	findings := []diagnosticFinding{
+		{File: "src/main.selene", Line: 2, Column: 1, EndLine: 2, EndColumn: 10, Severity: "warning", Code: "type.unused-import", Message: "fs is imported but never used"},
+		{File: "src/main.selene", Line: 4, Column: 7, EndLine: 4, EndColumn: 12, Severity: "hint", Code: "type.unused-import", Message: "os is imported but never used"},
+		{File: "src/util.selene", Line: 1, Column: 1, EndLine: 1, EndColumn: 2, Severity: "error", Message: "unexpected token"},
+	}
+	var text bytes.Buffer
+	if err := writeVetReport(&text, "text", findings[:1]); err != nil {
+		t.Fatalf("text report: %v", err)
+	}
+	if want := "src/main.selene:2:1: warning: fs is imported but never used [type.unused-import]\n"; text.String() != want {
+		t.Fatalf("unexpected text report %q", text.String())
+	}
+
+	var sarif bytes.Buffer
+	if err := writeVetReport(&sarif, "sarif", findings); err != nil {
+		t.Fatalf("sarif report: %v", err)
+	}
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				RuleIndex *int   `json:"ruleIndex"`
+				Level     string `json:"level"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(sarif.Bytes(), &log); err != nil {
+		t.Fatalf("decode sarif: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Tool.Driver.Rules) != 1 || len(log.Runs[0].Results) != 3 {
+		t.Fatalf("unexpected sarif log %s", sarif.String())
+	}
+	results := log.Runs[0].Results
+	if results[1].Level != "note" || results[1].RuleID != "type.unused-import" || *results[1].RuleIndex != 0 {
+		t.Fatalf("expected hints to be notes of the shared rule, got %+v", results[1])
+	}
+	if results[2].Level != "error" || results[2].RuleID != "" || results[2].RuleIndex != nil {
+		t.Fatalf("expected an uncoded error without a rule, got %+v", results[2])
+	}
+
+	var empty bytes.Buffer
+	if err := writeVetReport(&empty, "json", nil); err != nil || strings.TrimSpace(empty.String()) != "[]" {
+		t.Fatalf("expected an empty JSON array, got %q (%v)", empty.String(), err)
+	}
+}
+
+func TestWriteTokensFormats(t *testing.T) {
+	const src = "let x = a < 2;"
+	var text bytes.Buffer
+	if err := writeTokens(&text, lexer.New(src), tokenDumpOptions{}); err != nil {
+		t.Fatalf("text tokens: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(text.String()), "\n")
+	if len(lines) != 7 || lines[1] != "IDENT\t\"x\"\t1:5-1:6\t4-5" {
+		t.Fatalf("unexpected token lines %q", lines)
+	}
+
+	var records bytes.Buffer
+	if err := writeTokens(&records, lexer.New(src), tokenDumpOptions{json: true}); err != nil {
+		t.Fatalf("json tokens: %v", err)
+	}
+	var tokens []tokenRecord
+	if err := json.Unmarshal(records.Bytes(), &tokens); err != nil {
+		t.Fatalf("decode tokens: %v\n%s", err, records.String())
+	}
+	if len(tokens) != 7 || tokens[4].Literal != "<" || tokens[4].Start != (tokenPosition{Line: 1, Column: 11, Offset: 10}) || !strings.Contains(records.String(), `"<"`) {
+		t.Fatalf("unexpected tokens %s", records.String())
+	}
+
+	var counts bytes.Buffer
+	if err := writeTokens(&counts, lexer.New(src), tokenDumpOptions{count: true}); err != nil {
+		t.Fatalf("token counts: %v", err)
+	}
+	if want := "IDENT\t2\n;\t1\n<\t1\n=\t1\nNUMBER\t1\nlet\t1\ntotal\t7\n"; counts.String() != want {
+		t.Fatalf("unexpected counts %q", counts.String())
+	}
+}
+
+func TestReportExamplesJSON(t *testing.T) {
+	dir := t.TempDir()
+	scripts := make([]examples.Script, 0, 2)
+	for name, source := range map[string]string{"ok.selene": `print("hi");`, "bad.selene": `throw "boom";`} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		scripts = append(scripts, examples.Script{Path: path, Relative: name})
+	}
+	slices.SortFunc(scripts, func(a, b examples.Script) int { return strings.Compare(a.Relative, b.Relative) })
+	var out bytes.Buffer
+	err := reportExamplesJSON(&out, scripts, []examples.Mode{examples.ModeInterpreter}, examples.SuiteOptions{CaptureOutput: true}, false)
+	if err == nil {
+		t.Fatalf("expected the failing example to fail the run")
+	}
+	var report struct {
+		Passed  int             `json:"passed"`
+		Failed  int             `json:"failed"`
+		Results []exampleReport `json:"results"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("decode report: %v\n%s", err, out.String())
+	}
+	if report.Passed != 1 || report.Failed != 1 || len(report.Results) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	bad, ok := report.Results[0], report.Results[1]
+	if bad.Example != "bad.selene" || bad.Passed || !strings.Contains(bad.Error, "boom") {
+		t.Fatalf("unexpected failing result %+v", bad)
+	}
+	if ok.Mode != "interp" || !ok.Passed || ok.Output != "hi\n" {
+		t.Fatalf("unexpected passing result %+v", ok)
+	}
+}
+
+func TestReportExamplesJSONFailsOnLeaks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leak.selene")
+	if err := os.WriteFile(path, []byte("let ch = channel();\n"), 0o644); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+	scripts := []examples.Script{{Path: path, Relative: "leak.selene"}}
+	modes := []examples.Mode{examples.ModeInterpreter}
+	var out bytes.Buffer
+	if err := reportExamplesJSON(&out, scripts, modes, examples.SuiteOptions{}, false); err != nil {
+		t.Fatalf("expected leaks to only warn, got %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "channel created at "+path+":1:10 was never closed") {
+		t.Fatalf("expected the unclosed channel in the report, got %s", out.String())
+	}
+	out.Reset()
+	if err := reportExamplesJSON(&out, scripts, modes, examples.SuiteOptions{}, true); err == nil {
+		t.Fatalf("expected --fail-on-leaks to fail the run\n%s", out.String())
+	}
+}
+
+func TestVMDebuggerBreakpointsAndLocals(t *testing.T) {
+	p := parser.New(lexer.New("let x = 1;\nfn double(n: Int): Int {\n    return n * 2;\n}\nlet y = double(x);\nlet z = y + 1;\n"))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("unexpected parse errors: %v", errs)
+	}
+	rt := runtime.New()
+	chunk, err := rt.Compile(program)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	var out bytes.Buffer
+	debugger := newVMDebugger(rt, chunk, strings.NewReader("break 3\ncontinue\nlocals\nstep\nstep\nprint y\nquit\n"), &out)
+	rt.SetVMHook(debugger.before)
+	if _, err := rt.RunChunk(chunk); !errors.Is(err, errDebuggerQuit) {
+		t.Fatalf("expected quit to stop the run, got %v", err)
+	}
+	for _, want := range []string{
+		"breakpoint at line 3: 0003 OpEvalItem 1 (*ast.FunctionDeclaration)",
+		"0003 OpEvalItem 1 (*ast.FunctionDeclaration) line 2 stack: [1]",
+		"(selene) x = 1\n(selene) 0006",
+		"y = 2",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in debugger output:\n%s", want, out.String())
+		}
+	}
+	if _, ok := rt.Environment().Get("z"); ok {
+		t.Fatalf("expected quit to stop before z is bound")
+	}
+}
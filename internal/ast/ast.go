@@ -19,9 +19,13 @@ type ProgramItem interface {
 
 // Program is the root node for a parsed Selene module.
 type Program struct {
-	Items  []ProgramItem
-	Start  token.Position
-	Finish token.Position
+	Items []ProgramItem
+	// Comments lists every comment in the source in order. They are not
+	// attached to nodes; tools that reproduce source interleave them with
+	// Items by position.
+	Comments []token.Comment
+	Start    token.Position
+	Finish   token.Position
 }
 
 // Pos returns the position where the program begins.
@@ -81,12 +85,14 @@ func (n *NumberLiteral) expressionNode()     {}
 func (n *NumberLiteral) patternNode()        {}
 
 // StringLiteral captures an optionally raw or formatted string literal.
+// Heredoc holds the tag of a literal written as a heredoc, such as <<~SQL.
 type StringLiteral struct {
-	Value  string
-	Raw    bool
-	Format bool
-	Start  token.Position
-	Finish token.Position
+	Value   string
+	Raw     bool
+	Format  bool
+	Heredoc string
+	Start   token.Position
+	Finish  token.Position
 }
 
 // Pos returns the location where the string literal begins.
@@ -142,6 +148,20 @@ func (a *ArrayLiteral) Pos() token.Position { return a.Start }
 func (a *ArrayLiteral) End() token.Position { return a.Finish }
 func (a *ArrayLiteral) expressionNode()     {}
 
+// SetLiteral records a set literal, #{...}, and its elements.
+type SetLiteral struct {
+	Elements []Expression
+	Start    token.Position
+	Finish   token.Position
+}
+
+// Pos returns the location where the set literal begins.
+func (s *SetLiteral) Pos() token.Position { return s.Start }
+
+// End returns the location immediately after the set literal.
+func (s *SetLiteral) End() token.Position { return s.Finish }
+func (s *SetLiteral) expressionNode()     {}
+
 // ObjectPair links a literal object's key with its value expression.
 type ObjectPair struct {
 	Key             string
@@ -179,6 +199,37 @@ func (a *AwaitExpression) Pos() token.Position { return a.Start }
 func (a *AwaitExpression) End() token.Position { return a.Finish }
 func (a *AwaitExpression) expressionNode()     {}
 
+// OldExpression is old(expr) in a function's postcondition: the value expr
+// had when the call began, before the body ran. Index numbers it among the
+// Olds of its contract block.
+type OldExpression struct {
+	Value  Expression
+	Index  int
+	Start  token.Position
+	Finish token.Position
+}
+
+// Pos returns the location where the old expression begins.
+func (o *OldExpression) Pos() token.Position { return o.Start }
+
+// End returns the location immediately after the old expression.
+func (o *OldExpression) End() token.Position { return o.Finish }
+func (o *OldExpression) expressionNode()     {}
+
+// YieldExpression suspends a generator, handing Value to the caller of next().
+type YieldExpression struct {
+	Value  Expression
+	Start  token.Position
+	Finish token.Position
+}
+
+// Pos returns the location where the yield expression begins.
+func (y *YieldExpression) Pos() token.Position { return y.Start }
+
+// End returns the location immediately after the yield expression.
+func (y *YieldExpression) End() token.Position { return y.Finish }
+func (y *YieldExpression) expressionNode()     {}
+
 // PrefixExpression represents a unary operator applied to a right-hand expression.
 type PrefixExpression struct {
 	Operator string
@@ -226,6 +277,22 @@ func (a *AssignmentExpression) Pos() token.Position { return a.Start }
 func (a *AssignmentExpression) End() token.Position { return a.Finish }
 func (a *AssignmentExpression) expressionNode()     {}
 
+// IncrementExpression represents the postfix `++` and `--` operators, which
+// add or subtract one from an assignable target and yield its old value.
+type IncrementExpression struct {
+	Target   Expression
+	Operator string
+	Start    token.Position
+	Finish   token.Position
+}
+
+// Pos returns the location where the increment expression begins.
+func (i *IncrementExpression) Pos() token.Position { return i.Start }
+
+// End returns the location immediately after the increment expression.
+func (i *IncrementExpression) End() token.Position { return i.Finish }
+func (i *IncrementExpression) expressionNode()     {}
+
 // ElvisExpression represents the `?:` operator.
 type ElvisExpression struct {
 	Left   Expression
@@ -256,10 +323,12 @@ func (c *CallExpression) Pos() token.Position { return c.Start }
 func (c *CallExpression) End() token.Position { return c.Finish }
 func (c *CallExpression) expressionNode()     {}
 
-// IndexExpression models bracket indexing into a collection.
+// IndexExpression models bracket indexing into a collection, written
+// collection?[index] when Optional.
 type IndexExpression struct {
 	Collection Expression
 	Index      Expression
+	Optional   bool
 	Start      token.Position
 	Finish     token.Position
 }
@@ -301,6 +370,21 @@ func (n *NonNullAssertion) Pos() token.Position { return n.Start }
 func (n *NonNullAssertion) End() token.Position { return n.Finish }
 func (n *NonNullAssertion) expressionNode()     {}
 
+// PropagateExpression represents the postfix `?` operator, which unwraps an
+// Ok or Some value and returns an Err or None from the enclosing function.
+type PropagateExpression struct {
+	Expression Expression
+	Start      token.Position
+	Finish     token.Position
+}
+
+// Pos returns the location where the propagation begins.
+func (n *PropagateExpression) Pos() token.Position { return n.Start }
+
+// End returns the location immediately after the `?`.
+func (n *PropagateExpression) End() token.Position { return n.Finish }
+func (n *PropagateExpression) expressionNode()     {}
+
 // Statements
 
 // BlockStatement groups a series of statements within braces.
@@ -352,6 +436,8 @@ func (i *IfStatement) programItemNode()    {}
 
 // WhileStatement represents a looping construct evaluated before the body.
 type WhileStatement struct {
+	// Label is the name written label: in front of the loop, or "".
+	Label     string
 	Condition Expression
 	Body      Statement
 	Start     token.Position
@@ -366,8 +452,29 @@ func (w *WhileStatement) End() token.Position { return w.Finish }
 func (w *WhileStatement) statementNode()      {}
 func (w *WhileStatement) programItemNode()    {}
 
+// DoWhileStatement is a loop that runs its body before each test of
+// Condition, written do { ... } while condition;.
+type DoWhileStatement struct {
+	// Label is the name written label: in front of the loop, or "".
+	Label     string
+	Body      *BlockStatement
+	Condition Expression
+	Start     token.Position
+	Finish    token.Position
+}
+
+// Pos returns the location where the do-while statement begins.
+func (d *DoWhileStatement) Pos() token.Position { return d.Start }
+
+// End returns the location immediately after the do-while statement.
+func (d *DoWhileStatement) End() token.Position { return d.Finish }
+func (d *DoWhileStatement) statementNode()      {}
+func (d *DoWhileStatement) programItemNode()    {}
+
 // ForStatement encodes a three-part loop.
 type ForStatement struct {
+	// Label is the name written label: in front of the loop, or "".
+	Label     string
 	Init      Statement
 	Condition Expression
 	Post      Expression
@@ -384,6 +491,25 @@ func (f *ForStatement) End() token.Position { return f.Finish }
 func (f *ForStatement) statementNode()      {}
 func (f *ForStatement) programItemNode()    {}
 
+// ForInStatement iterates over the elements of an array, string, or generator.
+type ForInStatement struct {
+	// Label is the name written label: in front of the loop, or "".
+	Label    string
+	Binding  *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+	Start    token.Position
+	Finish   token.Position
+}
+
+// Pos returns the location where the for-in statement begins.
+func (f *ForInStatement) Pos() token.Position { return f.Start }
+
+// End returns the location immediately after the for-in statement.
+func (f *ForInStatement) End() token.Position { return f.Finish }
+func (f *ForInStatement) statementNode()      {}
+func (f *ForInStatement) programItemNode()    {}
+
 // ReturnStatement returns control to the caller with an optional value.
 type ReturnStatement struct {
 	Value  Expression
@@ -399,8 +525,10 @@ func (r *ReturnStatement) End() token.Position { return r.Finish }
 func (r *ReturnStatement) statementNode()      {}
 func (r *ReturnStatement) programItemNode()    {}
 
-// BreakStatement exits the nearest enclosing loop.
+// BreakStatement exits the nearest enclosing loop, or the enclosing loop
+// named Label when it has one.
 type BreakStatement struct {
+	Label  string
 	Start  token.Position
 	Finish token.Position
 }
@@ -413,8 +541,10 @@ func (b *BreakStatement) End() token.Position { return b.Finish }
 func (b *BreakStatement) statementNode()      {}
 func (b *BreakStatement) programItemNode()    {}
 
-// ContinueStatement skips to the next loop iteration.
+// ContinueStatement skips to the next iteration of the nearest enclosing
+// loop, or of the enclosing loop named Label when it has one.
 type ContinueStatement struct {
+	Label  string
 	Start  token.Position
 	Finish token.Position
 }
@@ -427,6 +557,21 @@ func (c *ContinueStatement) End() token.Position { return c.Finish }
 func (c *ContinueStatement) statementNode()      {}
 func (c *ContinueStatement) programItemNode()    {}
 
+// RethrowStatement raises the error the enclosing catch clause caught again,
+// unchanged.
+type RethrowStatement struct {
+	Start  token.Position
+	Finish token.Position
+}
+
+// Pos returns the location where the rethrow statement begins.
+func (r *RethrowStatement) Pos() token.Position { return r.Start }
+
+// End returns the location immediately after the rethrow statement.
+func (r *RethrowStatement) End() token.Position { return r.Finish }
+func (r *RethrowStatement) statementNode()      {}
+func (r *RethrowStatement) programItemNode()    {}
+
 // ThrowStatement raises an exception-like value.
 type ThrowStatement struct {
 	Value  Expression
@@ -459,10 +604,11 @@ func (u *UsingStatement) End() token.Position { return u.Finish }
 func (u *UsingStatement) statementNode()      {}
 func (u *UsingStatement) programItemNode()    {}
 
-// TryStatement models structured exception handling.
+// TryStatement models structured exception handling. Its catch clauses are
+// tried in order and the first that accepts the thrown value handles it.
 type TryStatement struct {
 	Body    *BlockStatement
-	Catch   *CatchClause
+	Catches []*CatchClause
 	Finally *BlockStatement
 	Start   token.Position
 	Finish  token.Position
@@ -476,9 +622,11 @@ func (t *TryStatement) End() token.Position { return t.Finish }
 func (t *TryStatement) statementNode()      {}
 func (t *TryStatement) programItemNode()    {}
 
-// CatchClause handles values thrown in a try block.
+// CatchClause handles values thrown in a try block. A clause with a Type,
+// catch (e: NetworkError), only handles values of that type.
 type CatchClause struct {
 	Identifier *Identifier
+	Type       *TypeAnnotation
 	Body       *BlockStatement
 	Start      token.Position
 	Finish     token.Position
@@ -520,14 +668,21 @@ func (c *ConditionStatement) End() token.Position { return c.Finish }
 func (c *ConditionStatement) statementNode()      {}
 func (c *ConditionStatement) programItemNode()    {}
 
-// VariableDeclaration introduces a new binding.
+// VariableDeclaration introduces a new binding. A destructuring declaration,
+// such as let [first, ...rest] = items;, has a Pattern instead of a Name and
+// binds the names the pattern does.
 type VariableDeclaration struct {
 	Mutable bool
 	Name    *Identifier
+	Pattern Pattern
+	Doc     string
 	Type    *TypeAnnotation
 	Value   Expression
-	Start   token.Position
-	Finish  token.Position
+	// Static marks a class-level constant or variable, declared with static
+	// in a class body.
+	Static bool
+	Start  token.Position
+	Finish token.Position
 }
 
 // Pos returns the location where the variable declaration begins.
@@ -542,6 +697,8 @@ func (v *VariableDeclaration) programItemNode()    {}
 type Parameter struct {
 	Name *Identifier
 	Type *TypeAnnotation
+	// Doc is set for struct and class fields written with /// comments.
+	Doc string
 }
 
 // TypeAnnotation records the declared type of an expression.
@@ -559,23 +716,73 @@ func (t *TypeAnnotation) Pos() token.Position { return t.Start }
 // End returns the location immediately after the type annotation.
 func (t *TypeAnnotation) End() token.Position { return t.Finish }
 
+// Annotation attaches metadata to the declaration that follows it, as in
+// @deprecated or @route("/users"). The runtime evaluates the arguments when
+// the declaration runs; the reflect builtin reads them.
+type Annotation struct {
+	Name      *Identifier
+	Arguments []Expression
+	Start     token.Position
+	Finish    token.Position
+}
+
+// Pos returns the location of the annotation's @.
+func (a *Annotation) Pos() token.Position { return a.Start }
+
+// End returns the location immediately after the annotation.
+func (a *Annotation) End() token.Position { return a.Finish }
+
+// Annotations returns the annotations of a function, class, struct, enum,
+// interface, or contract declaration, and nil for any other statement.
+func Annotations(stmt Statement) []*Annotation {
+	switch node := stmt.(type) {
+	case *FunctionDeclaration:
+		return node.Annotations
+	case *ClassDeclaration:
+		return node.Annotations
+	case *StructDeclaration:
+		return node.Annotations
+	case *EnumDeclaration:
+		return node.Annotations
+	case *InterfaceDeclaration:
+		return node.Annotations
+	case *ContractDeclaration:
+		return node.Annotations
+	}
+	return nil
+}
+
 // Functions and contracts
 
 // FunctionDeclaration declares a function or method.
 type FunctionDeclaration struct {
-	Name        *Identifier
+	Name *Identifier
+	// Doc is the text of the /// comments above the declaration.
+	Doc         string
+	Annotations []*Annotation
 	Receiver    *TypeAnnotation
 	TypeParams  []*Identifier
 	Params      []Parameter
 	ReturnType  *TypeAnnotation
 	Async       bool
+	Generator   bool
 	Contract    *ContractBlock
 	Body        *BlockStatement
 	BodyExpr    Expression
 	IsExprBody  bool
 	IsExtension bool
-	Start       token.Position
-	Finish      token.Position
+	// Accessor is "get" or "set" when the declaration is a class property
+	// accessor rather than a method.
+	Accessor string
+	// Static marks a static method of a class, which is called on the
+	// class rather than an instance.
+	Static bool
+	// Abstract marks a method with no body that subclasses must provide,
+	// and Override a method that replaces one of its superclass's.
+	Abstract bool
+	Override bool
+	Start    token.Position
+	Finish   token.Position
 }
 
 // Pos returns the location where the function declaration begins.
@@ -586,9 +793,11 @@ func (f *FunctionDeclaration) End() token.Position { return f.Finish }
 func (f *FunctionDeclaration) statementNode()      {}
 func (f *FunctionDeclaration) programItemNode()    {}
 
-// ContractBlock collects design-by-contract clauses.
+// ContractBlock collects design-by-contract clauses. Olds lists the old
+// expressions in its postconditions, in the order of their Index.
 type ContractBlock struct {
 	Clauses []ContractClause
+	Olds    []*OldExpression
 	Start   token.Position
 	Finish  token.Position
 }
@@ -599,8 +808,11 @@ func (c *ContractBlock) Pos() token.Position { return c.Start }
 // End returns the location immediately after the contract block.
 func (c *ContractBlock) End() token.Position { return c.Finish }
 
-// ContractClause defines a pre- or post-condition for a function.
+// ContractClause defines a pre- or post-condition for a function. Kind is
+// "returns" for returns(guard) => condition;, "requires" for a precondition,
+// or "ensures" for a postcondition; only a returns clause has a Guard.
 type ContractClause struct {
+	Kind      string
 	Guard     Expression
 	Condition Expression
 	Start     token.Position
@@ -615,12 +827,22 @@ func (c *ContractClause) End() token.Position { return c.Finish }
 
 // ClassDeclaration defines a class with optional inheritance.
 type ClassDeclaration struct {
-	Name       *Identifier
-	Params     []Parameter
-	SuperClass *Identifier
+	Name        *Identifier
+	Doc         string
+	Annotations []*Annotation
+	TypeParams  []*Identifier
+	Params      []Parameter
+	SuperClass  *Identifier
+	// Implements lists the contracts named after implements, which the
+	// class must fulfil.
+	Implements []*Identifier
 	Body       *BlockStatement
-	Start      token.Position
-	Finish     token.Position
+	Invariants []*ContractInvariant
+	// Abstract marks a class declared abstract class, which cannot be
+	// instantiated and may declare abstract methods.
+	Abstract bool
+	Start    token.Position
+	Finish   token.Position
 }
 
 // Pos returns the location where the class declaration begins.
@@ -633,10 +855,12 @@ func (c *ClassDeclaration) programItemNode()    {}
 
 // InterfaceDeclaration introduces an interface type.
 type InterfaceDeclaration struct {
-	Name    *Identifier
-	Methods []InterfaceMethod
-	Start   token.Position
-	Finish  token.Position
+	Name        *Identifier
+	Doc         string
+	Annotations []*Annotation
+	Methods     []InterfaceMethod
+	Start       token.Position
+	Finish      token.Position
 }
 
 // Pos returns the location where the interface declaration begins.
@@ -647,13 +871,18 @@ func (i *InterfaceDeclaration) End() token.Position { return i.Finish }
 func (i *InterfaceDeclaration) statementNode()      {}
 func (i *InterfaceDeclaration) programItemNode()    {}
 
-// InterfaceMethod describes a required method on an interface.
+// InterfaceMethod describes a method of an interface.
 type InterfaceMethod struct {
 	Name       *Identifier
+	Doc        string
 	Params     []Parameter
 	ReturnType *TypeAnnotation
-	Start      token.Position
-	Finish     token.Position
+	// Default is the implementation impl blocks inherit when they do not
+	// define the method, or nil for a method every impl must define. It
+	// shares Name, Params, and ReturnType with the method.
+	Default *FunctionDeclaration
+	Start   token.Position
+	Finish  token.Position
 }
 
 // Pos returns the location where the interface method begins.
@@ -662,13 +891,34 @@ func (m *InterfaceMethod) Pos() token.Position { return m.Start }
 // End returns the location immediately after the interface method.
 func (m *InterfaceMethod) End() token.Position { return m.Finish }
 
+// ImplDeclaration declares that a type implements an interface, defining the
+// interface's methods for it.
+type ImplDeclaration struct {
+	Interface *Identifier
+	Target    *Identifier
+	Doc       string
+	Body      *BlockStatement
+	Start     token.Position
+	Finish    token.Position
+}
+
+// Pos returns the location where the impl declaration begins.
+func (i *ImplDeclaration) Pos() token.Position { return i.Start }
+
+// End returns the location immediately after the impl declaration.
+func (i *ImplDeclaration) End() token.Position { return i.Finish }
+func (i *ImplDeclaration) statementNode()      {}
+func (i *ImplDeclaration) programItemNode()    {}
+
 // StructDeclaration defines a struct type.
 type StructDeclaration struct {
-	Name   *Identifier
-	Params []Parameter
-	Body   *BlockStatement
-	Start  token.Position
-	Finish token.Position
+	Name        *Identifier
+	Doc         string
+	Annotations []*Annotation
+	Params      []Parameter
+	Body        *BlockStatement
+	Start       token.Position
+	Finish      token.Position
 }
 
 // Pos returns the location where the struct declaration begins.
@@ -681,11 +931,16 @@ func (s *StructDeclaration) programItemNode()    {}
 
 // EnumDeclaration defines an enumeration type.
 type EnumDeclaration struct {
-	Name       *Identifier
-	TypeParams []*Identifier
-	Cases      []EnumCase
-	Start      token.Position
-	Finish     token.Position
+	Name        *Identifier
+	Doc         string
+	Annotations []*Annotation
+	TypeParams  []*Identifier
+	Cases       []EnumCase
+	// Methods are the functions declared in the enum body, which its
+	// instances can call.
+	Methods []*FunctionDeclaration
+	Start   token.Position
+	Finish  token.Position
 }
 
 // Pos returns the location where the enum declaration begins.
@@ -699,6 +954,7 @@ func (e *EnumDeclaration) programItemNode()    {}
 // EnumCase describes a single enumeration variant.
 type EnumCase struct {
 	Name   *Identifier
+	Doc    string
 	Params []Parameter
 	Start  token.Position
 	Finish token.Position
@@ -710,12 +966,17 @@ func (e *EnumCase) Pos() token.Position { return e.Start }
 // End returns the location immediately after the enum case.
 func (e *EnumCase) End() token.Position { return e.Finish }
 
-// ContractDeclaration defines a contract type.
+// ContractDeclaration defines a contract type. A function in the body
+// without a body is a requirement that implementing classes must define,
+// and the invariants must hold for every instance of them.
 type ContractDeclaration struct {
-	Name   *Identifier
-	Body   *BlockStatement
-	Start  token.Position
-	Finish token.Position
+	Name        *Identifier
+	Doc         string
+	Annotations []*Annotation
+	Body        *BlockStatement
+	Invariants  []*ContractInvariant
+	Start       token.Position
+	Finish      token.Position
 }
 
 // Pos returns the location where the contract declaration begins.
@@ -726,6 +987,22 @@ func (c *ContractDeclaration) End() token.Position { return c.Finish }
 func (c *ContractDeclaration) statementNode()      {}
 func (c *ContractDeclaration) programItemNode()    {}
 
+// ContractInvariant is an invariant condition inside a contract or class
+// declaration. A contract's is checked against each new instance of a class
+// that implements the contract, and a class's also after each call to one of
+// its public methods.
+type ContractInvariant struct {
+	Condition Expression
+	Start     token.Position
+	Finish    token.Position
+}
+
+// Pos returns the location where the invariant begins.
+func (c *ContractInvariant) Pos() token.Position { return c.Start }
+
+// End returns the location immediately after the invariant.
+func (c *ContractInvariant) End() token.Position { return c.Finish }
+
 // ImportDeclaration brings a module or package into scope.
 type ImportDeclaration struct {
 	Path        []*Identifier
@@ -760,6 +1037,7 @@ func (p *PackageDeclaration) programItemNode()    {}
 // ModuleDeclaration defines a module and its body.
 type ModuleDeclaration struct {
 	Name   *Identifier
+	Doc    string
 	Body   *BlockStatement
 	Start  token.Position
 	Finish token.Position
@@ -772,10 +1050,12 @@ func (m *ModuleDeclaration) Pos() token.Position { return m.Start }
 func (m *ModuleDeclaration) End() token.Position { return m.Finish }
 func (m *ModuleDeclaration) programItemNode()    {}
 
-// MatchStatement performs structural pattern matching.
+// MatchStatement performs structural pattern matching. Else, when present,
+// runs if no case matches.
 type MatchStatement struct {
 	Value  Expression
 	Cases  []MatchCase
+	Else   Statement
 	Start  token.Position
 	Finish token.Position
 }
@@ -788,12 +1068,13 @@ func (m *MatchStatement) End() token.Position { return m.Finish }
 func (m *MatchStatement) statementNode()      {}
 func (m *MatchStatement) programItemNode()    {}
 
-// MatchCase pairs a pattern with a body to execute.
+// MatchCase pairs one or more patterns with a body to execute when any of
+// them matches. Each pattern binds the same names.
 type MatchCase struct {
-	Pattern Pattern
-	Body    Statement
-	Start   token.Position
-	Finish  token.Position
+	Patterns []Pattern
+	Body     Statement
+	Start    token.Position
+	Finish   token.Position
 }
 
 // Pos returns the location where the match case begins.
@@ -838,6 +1119,59 @@ func (s *StructPattern) Pos() token.Position { return s.Start }
 func (s *StructPattern) End() token.Position { return s.Finish }
 func (s *StructPattern) patternNode()        {}
 
+// ArrayPattern matches arrays element by element. Without a rest element it
+// matches arrays of exactly len(Elements) elements; with one ([first, ...rest])
+// it matches arrays at least that long.
+type ArrayPattern struct {
+	Elements []Pattern
+	// HasRest marks a trailing rest element. Rest is the name it binds the
+	// remaining elements to, as a new array, or nil for a bare ... that
+	// ignores them.
+	HasRest bool
+	Rest    *Identifier
+	Start   token.Position
+	Finish  token.Position
+}
+
+// Pos returns the location where the array pattern begins.
+func (a *ArrayPattern) Pos() token.Position { return a.Start }
+
+// End returns the location immediately after the array pattern.
+func (a *ArrayPattern) End() token.Position { return a.Finish }
+func (a *ArrayPattern) patternNode()        {}
+
+// PatternBindings returns the identifiers pattern binds when it matches, in
+// source order.
+func PatternBindings(pattern Pattern) []*Identifier {
+	var ids []*Identifier
+	var walk func(Pattern)
+	walk = func(pattern Pattern) {
+		switch p := pattern.(type) {
+		case *IdentifierPattern:
+			if p.Identifier != nil {
+				ids = append(ids, p.Identifier)
+			}
+		case *ObjectPattern:
+			for _, pair := range p.Pairs {
+				walk(pair.Value)
+			}
+		case *StructPattern:
+			for _, field := range p.Fields {
+				walk(field)
+			}
+		case *ArrayPattern:
+			for _, el := range p.Elements {
+				walk(el)
+			}
+			if p.Rest != nil {
+				ids = append(ids, p.Rest)
+			}
+		}
+	}
+	walk(pattern)
+	return ids
+}
+
 // IdentifierPattern binds a matched value to a name.
 type IdentifierPattern struct {
 	Identifier *Identifier
@@ -850,7 +1184,8 @@ func (i *IdentifierPattern) Pos() token.Position { return i.Identifier.Pos() }
 func (i *IdentifierPattern) End() token.Position { return i.Identifier.End() }
 func (i *IdentifierPattern) patternNode()        {}
 
-// LiteralPattern matches against a literal expression.
+// LiteralPattern matches against a literal expression, or against the Numbers
+// a range of two number literals such as 1..=9 holds.
 type LiteralPattern struct {
 	Value Expression
 }
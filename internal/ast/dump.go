@@ -0,0 +1,184 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cybellereaper/selenelang/internal/token"
+)
+
+var (
+	nodeType     = reflect.TypeFor[Node]()
+	positionType = reflect.TypeFor[token.Position]()
+)
+
+// JSON encodes the tree rooted at node as JSON for tools written in other
+// languages. Each node is an object whose "kind" is its type name, such as
+// "CallExpression", with "start" and "end" positions giving the line,
+// column, and byte offset of its first character and of the character
+// after it. The node's fields follow in declaration order, named in
+// lowerCamelCase: child nodes as objects, lists as arrays, and values such
+// as names and operators as strings, numbers, or booleans. Fields holding
+// their zero value, such as an absent child or a false flag, are left out.
+func JSON(node Node) []byte {
+	var b bytes.Buffer
+	writeJSONValue(&b, reflect.ValueOf(&node).Elem())
+	return b.Bytes()
+}
+
+func writeJSONValue(b *bytes.Buffer, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			b.WriteString("null")
+			return
+		}
+		writeJSONValue(b, v.Elem())
+	case reflect.Pointer:
+		switch {
+		case v.IsNil():
+			b.WriteString("null")
+		case v.Type().Implements(nodeType):
+			writeJSONNode(b, v.Interface().(Node))
+		default:
+			writeJSONValue(b, v.Elem())
+		}
+	case reflect.Struct:
+		switch {
+		case v.Type() == positionType:
+			pos := v.Interface().(token.Position)
+			fmt.Fprintf(b, `{"line":%d,"column":%d,"offset":%d}`, pos.Line, pos.Column, pos.Offset)
+		case v.CanAddr() && v.Addr().Type().Implements(nodeType):
+			writeJSONNode(b, v.Addr().Interface().(Node))
+		default:
+			b.WriteByte('{')
+			writeJSONFields(b, v, true)
+			b.WriteByte('}')
+		}
+	case reflect.Slice:
+		b.WriteByte('[')
+		for i := range v.Len() {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeJSONValue(b, v.Index(i))
+		}
+		b.WriteByte(']')
+	case reflect.String:
+		writeJSONString(b, v.String())
+	case reflect.Bool:
+		b.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int:
+		b.WriteString(strconv.FormatInt(v.Int(), 10))
+	default:
+		panic(fmt.Sprintf("ast: cannot encode %s", v.Type()))
+	}
+}
+
+func writeJSONNode(b *bytes.Buffer, node Node) {
+	b.WriteString(`{"kind":`)
+	writeJSONString(b, nodeKind(node))
+	b.WriteString(`,"start":`)
+	writeJSONValue(b, reflect.ValueOf(node.Pos()))
+	b.WriteString(`,"end":`)
+	writeJSONValue(b, reflect.ValueOf(node.End()))
+	writeJSONFields(b, reflect.ValueOf(node).Elem(), false)
+	b.WriteByte('}')
+}
+
+// writeJSONFields writes the non-zero fields of the struct v, leaving out
+// the positions a node reports through Pos and End.
+func writeJSONFields(b *bytes.Buffer, v reflect.Value, first bool) {
+	for i := range v.NumField() {
+		field, value := v.Type().Field(i), v.Field(i)
+		if !field.IsExported() || dumpSkips(v, field) || isEmpty(value) {
+			continue
+		}
+		if !first {
+			b.WriteByte(',')
+		}
+		first = false
+		writeJSONString(b, lowerFirst(field.Name))
+		b.WriteByte(':')
+		writeJSONValue(b, value)
+	}
+}
+
+func writeJSONString(b *bytes.Buffer, s string) {
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	b.Write(bytes.TrimSuffix(out.Bytes(), []byte("\n")))
+}
+
+// Dump renders the tree rooted at node as an indented outline, one node per
+// line with its kind, the span it covers, and its names, operators, and
+// flags, for reading at a terminal. JSON gives the complete tree.
+//
+//	Program 1:1-1:13
+//	  VariableDeclaration 1:1-1:13
+//	    Identifier 1:5-1:6 name="x"
+//	    NumberLiteral 1:9-1:11 value="42"
+func Dump(node Node) string {
+	var b strings.Builder
+	depth := 0
+	Inspect(node, func(n Node) bool {
+		if n == nil {
+			depth--
+			return true
+		}
+		fmt.Fprintf(&b, "%s%s %s-%s", strings.Repeat("  ", depth), nodeKind(n), n.Pos(), n.End())
+		v := reflect.ValueOf(n).Elem()
+		for i := range v.NumField() {
+			field, value := v.Type().Field(i), v.Field(i)
+			if !field.IsExported() || dumpSkips(v, field) || isEmpty(value) {
+				continue
+			}
+			switch value.Kind() {
+			case reflect.String:
+				fmt.Fprintf(&b, " %s=%q", lowerFirst(field.Name), value.String())
+			case reflect.Bool:
+				fmt.Fprintf(&b, " %s", lowerFirst(field.Name))
+			case reflect.Int:
+				fmt.Fprintf(&b, " %s=%d", lowerFirst(field.Name), value.Int())
+			}
+		}
+		b.WriteByte('\n')
+		depth++
+		return true
+	})
+	return b.String()
+}
+
+// dumpSkips reports whether field of the struct v is left out of a dump:
+// the Start and Finish a node reports through Pos and End, and the Olds of
+// a contract block, which repeat expressions found in its clauses.
+func dumpSkips(v reflect.Value, field reflect.StructField) bool {
+	if field.Type == positionType && (field.Name == "Start" || field.Name == "Finish") {
+		return true
+	}
+	return v.Type() == reflect.TypeFor[ContractBlock]() && field.Name == "Olds"
+}
+
+func isEmpty(v reflect.Value) bool {
+	if v.Kind() == reflect.Slice {
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+func nodeKind(node Node) string {
+	return reflect.TypeOf(node).Elem().Name()
+}
+
+func lowerFirst(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[size:]
+}
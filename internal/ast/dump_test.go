@@ -0,0 +1,67 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+)
+
+func TestJSONEncodesKindsPositionsAndFields(t *testing.T) {
+	program := parse(t, "let label = \"<a & b>\";\nx += f(1, y?);\n")
+	var tree map[string]any
+	if err := json.Unmarshal(ast.JSON(program), &tree); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, ast.JSON(program))
+	}
+	if tree["kind"] != "Program" {
+		t.Fatalf("expected a Program, got %v", tree["kind"])
+	}
+	items := tree["items"].([]any)
+	decl := items[0].(map[string]any)
+	start := decl["start"].(map[string]any)
+	if decl["kind"] != "VariableDeclaration" || start["line"] != 1.0 || start["column"] != 1.0 {
+		t.Fatalf("unexpected declaration: %v", decl)
+	}
+	if _, ok := decl["mutable"]; ok {
+		t.Fatalf("expected false flags to be left out: %v", decl)
+	}
+	value := decl["value"].(map[string]any)
+	if value["kind"] != "StringLiteral" || value["value"] != "<a & b>" {
+		t.Fatalf("unexpected value: %v", value)
+	}
+	assign := items[1].(map[string]any)["expression"].(map[string]any)
+	if assign["kind"] != "AssignmentExpression" || assign["operator"] != "+=" {
+		t.Fatalf("unexpected assignment: %v", assign)
+	}
+	args := assign["value"].(map[string]any)["arguments"].([]any)
+	if len(args) != 2 || args[1].(map[string]any)["kind"] != "PropagateExpression" {
+		t.Fatalf("unexpected arguments: %v", args)
+	}
+	if !strings.Contains(string(ast.JSON(program)), `"<a & b>"`) {
+		t.Fatalf("expected strings without HTML escapes:\n%s", ast.JSON(program))
+	}
+}
+
+func TestJSONEncodesNodesHeldByValue(t *testing.T) {
+	program := parse(t, "match v { Some(x) => x; _ => 0; }\n")
+	encoded := string(ast.JSON(program))
+	for _, want := range []string{`"kind":"MatchStatement"`, `"cases":[{"kind":"MatchCase"`, `"kind":"StructPattern"`} {
+		if !strings.Contains(encoded, want) {
+			t.Fatalf("expected %s in:\n%s", want, encoded)
+		}
+	}
+}
+
+func TestDumpOutlinesTree(t *testing.T) {
+	program := parse(t, "var total = a.b;\n")
+	const expected = `Program 1:1-2:0
+  VariableDeclaration 1:1-2:0 mutable
+    Identifier 1:5-1:10 name="total"
+    MemberExpression 1:13-1:16 property="b"
+      Identifier 1:13-1:14 name="a"
+`
+	if dumped := ast.Dump(program); dumped != expected {
+		t.Fatalf("unexpected outline:\n--- got ---\n%s\n--- want ---\n%s", dumped, expected)
+	}
+}
@@ -0,0 +1,1328 @@
+package ast
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/cybellereaper/selenelang/internal/token"
+)
+
+// Print renders program as formatted Selene source that parses back to the
+// same tree. Comments in program.Comments are written where they appeared
+// relative to the code around them, and single blank lines between
+// statements are kept. Doc strings on nodes without a matching /// comment,
+// such as nodes a tool built rather than parsed, are written as /// lines.
+func Print(program *Program) string {
+	p := &printer{comments: program.Comments}
+	items := make([]Node, len(program.Items))
+	for i, item := range program.Items {
+		items[i] = item
+	}
+	p.lines(items, token.Position{Offset: math.MaxInt}, true)
+	return strings.TrimRight(p.b.String(), "\n") + "\n"
+}
+
+// PrintNode renders a single declaration, statement, expression, pattern, or
+// type annotation without comments, as a REPL echoes what it parsed.
+func PrintNode(node Node) string {
+	if program, ok := node.(*Program); ok {
+		return Print(program)
+	}
+	p := &printer{}
+	switch n := node.(type) {
+	case Expression:
+		p.expr(n, precLowest)
+	case Pattern:
+		p.pattern(n)
+	case *TypeAnnotation:
+		p.typeAnnotation(n)
+	default:
+		p.item(node)
+	}
+	return strings.TrimRight(p.b.String(), "\n")
+}
+
+// Precedence levels, lowest first, matching the parser's binding powers.
+const (
+	precLowest = iota + 1
+	precAssignment
+	precElvis
+	precOr
+	precAnd
+	precEquality
+	precComparison
+	precRange
+	precSum
+	precProduct
+	precPrefix
+	precCall
+	precPrimary
+)
+
+var infixPrecedence = map[string]int{
+	"||": precOr,
+	"&&": precAnd,
+	"==": precEquality, "!=": precEquality,
+	"<": precComparison, "<=": precComparison, ">": precComparison, ">=": precComparison,
+	"is": precComparison, "!is": precComparison, "in": precComparison,
+	"..": precRange, "..=": precRange,
+	"+": precSum, "-": precSum,
+	"*": precProduct, "/": precProduct, "%": precProduct,
+}
+
+func precedence(e Expression) int {
+	switch e := e.(type) {
+	case *AssignmentExpression, *YieldExpression:
+		return precAssignment
+	case *ElvisExpression:
+		return precElvis
+	case *InfixExpression:
+		if prec, ok := infixPrecedence[e.Operator]; ok {
+			return prec
+		}
+		return precLowest
+	case *PrefixExpression, *AwaitExpression:
+		return precPrefix
+	case *CallExpression, *IndexExpression, *MemberExpression, *NonNullAssertion, *PropagateExpression,
+		*IncrementExpression:
+		return precCall
+	}
+	return precPrimary
+}
+
+// printer writes Selene source. Comments are taken from the front of
+// comments as the positions of the nodes being written pass them, so every
+// comment is written exactly once and in source order.
+type printer struct {
+	b         strings.Builder
+	indent    int
+	lineStart bool
+	comments  []token.Comment
+	next      int
+	// line is the source line of the last node or comment written, used to
+	// keep blank lines and to find comments that end that line.
+	line int
+	// blockStart suppresses a blank line right after an opening brace.
+	blockStart bool
+	// docLine is the line of the last /// comment written, so a node's Doc
+	// is not written twice.
+	docLine int
+	// groupObject asks for the next object literal to be parenthesised.
+	groupObject bool
+}
+
+func (p *printer) write(s string) {
+	if p.lineStart {
+		p.b.WriteString(strings.Repeat("    ", p.indent))
+		p.lineStart = false
+	}
+	p.b.WriteString(s)
+}
+
+func (p *printer) newline() {
+	p.b.WriteByte('\n')
+	p.lineStart = true
+}
+
+func (p *printer) blank() {
+	if p.b.Len() > 0 && !strings.HasSuffix(p.b.String(), "\n\n") {
+		p.newline()
+	}
+}
+
+// lastLine returns the line holding the character before pos. The lexer
+// reports a position just past the end of a line as column 0 of the next.
+func lastLine(pos token.Position) int {
+	if pos.Column == 0 && pos.Line > 1 {
+		return pos.Line - 1
+	}
+	return pos.Line
+}
+
+func (p *printer) pending(pos token.Position) (token.Comment, bool) {
+	if p.next >= len(p.comments) || p.comments[p.next].Pos.Offset >= pos.Offset {
+		return token.Comment{}, false
+	}
+	return p.comments[p.next], true
+}
+
+func (p *printer) took(c token.Comment) {
+	p.next++
+	p.line = lastLine(c.End)
+	if strings.HasPrefix(c.Text, "///") {
+		p.docLine = c.Pos.Line
+	}
+}
+
+// leading writes the comments before pos on lines of their own. It is only
+// called at the start of a line.
+func (p *printer) leading(pos token.Position) {
+	for {
+		c, ok := p.pending(pos)
+		if !ok {
+			return
+		}
+		if !p.blockStart && p.line > 0 && c.Pos.Line > p.line+1 {
+			p.blank()
+		}
+		p.write(c.Text)
+		p.newline()
+		p.took(c)
+		p.blockStart = false
+	}
+}
+
+// trailing writes the comments that sit on line before limit at the end of
+// the current line. The caller ends the line.
+func (p *printer) trailing(line int, limit token.Position) {
+	for {
+		c, ok := p.pending(limit)
+		if !ok || c.Pos.Line != line {
+			return
+		}
+		p.write(" " + c.Text)
+		p.took(c)
+	}
+}
+
+// inline writes the comments before pos in the middle of a line, ending
+// the line after a // comment.
+func (p *printer) inline(pos token.Position) {
+	for {
+		c, ok := p.pending(pos)
+		if !ok {
+			return
+		}
+		if !p.lineStart && !strings.HasSuffix(p.b.String(), " ") && !strings.HasSuffix(p.b.String(), "(") && !strings.HasSuffix(p.b.String(), "[") {
+			p.write(" ")
+		}
+		p.write(c.Text)
+		p.took(c)
+		if strings.HasPrefix(c.Text, "//") {
+			p.newline()
+		} else {
+			p.write(" ")
+		}
+	}
+}
+
+// lines writes nodes one per line up to the closing position end, with the
+// comments between them.
+func (p *printer) lines(nodes []Node, end token.Position, topLevel bool) {
+	for i, n := range nodes {
+		start := n.Pos()
+		p.leading(start)
+		switch {
+		case p.blockStart:
+		case start.Line == 0:
+			if topLevel && i > 0 {
+				p.blank()
+			}
+		case p.line > 0 && start.Line > p.line+1:
+			p.blank()
+		}
+		p.blockStart = false
+		p.item(n)
+		if line := lastLine(n.End()); line > 0 {
+			p.line = line
+		}
+		limit := end
+		if i+1 < len(nodes) && nodes[i+1].Pos().Line > 0 {
+			limit = nodes[i+1].Pos()
+		}
+		p.trailing(p.line, limit)
+		p.newline()
+	}
+	p.leading(end)
+	p.blockStart = false
+}
+
+// listItem is one element of a comma-separated list.
+type listItem struct {
+	start, end token.Position
+	print      func()
+}
+
+// list writes items between open and close. A list whose source put an
+// item on a new line is written one item per line; otherwise it stays on
+// one line, with pad adding spaces inside the brackets.
+func (p *printer) list(open, close string, pad bool, after token.Position, items []listItem, end token.Position) {
+	if len(items) == 0 {
+		p.write(open + close)
+		return
+	}
+	multiline := false
+	line := lastLine(after)
+	for _, item := range items {
+		if line > 0 && item.start.Line > line {
+			multiline = true
+		}
+		if l := lastLine(item.end); l > 0 {
+			line = l
+		}
+	}
+	if !multiline {
+		p.write(open)
+		if pad {
+			p.write(" ")
+		}
+		for i, item := range items {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.inline(item.start)
+			item.print()
+		}
+		if pad {
+			p.write(" ")
+		}
+		p.write(close)
+		return
+	}
+	p.write(open)
+	p.indent++
+	p.trailing(lastLine(after), items[0].start)
+	p.newline()
+	p.blockStart = true
+	for i, item := range items {
+		p.leading(item.start)
+		p.blockStart = false
+		item.print()
+		limit := end
+		if i+1 < len(items) {
+			p.write(",")
+			limit = items[i+1].start
+		}
+		if l := lastLine(item.end); l > 0 {
+			p.line = l
+		}
+		p.trailing(p.line, limit)
+		p.newline()
+	}
+	p.leading(end)
+	p.indent--
+	p.write(close)
+}
+
+func (p *printer) doc(doc string, pos token.Position) {
+	if doc == "" || (p.docLine > 0 && p.docLine == pos.Line-1) {
+		return
+	}
+	for _, line := range strings.Split(doc, "\n") {
+		if line == "" {
+			p.write("///")
+		} else {
+			p.write("/// " + line)
+		}
+		p.newline()
+	}
+}
+
+// annotations writes each annotation of a declaration on its own line.
+func (p *printer) annotations(annotations []*Annotation) {
+	for _, a := range annotations {
+		p.write("@" + identName(a.Name))
+		if a.Arguments != nil {
+			items := make([]listItem, len(a.Arguments))
+			for i, arg := range a.Arguments {
+				arg := arg
+				items[i] = listItem{start: nodeStart(arg), end: nodeEnd(arg), print: func() { p.expr(arg, precLowest) }}
+			}
+			p.list("(", ")", false, nodeEnd(a.Name), items, a.Finish)
+		}
+		p.newline()
+	}
+}
+
+func (p *printer) block(b *BlockStatement) {
+	if b == nil {
+		p.write("{}")
+		return
+	}
+	p.inline(b.Start)
+	if _, ok := p.pending(b.Finish); !ok && len(b.Statements) == 0 {
+		p.write("{}")
+		return
+	}
+	p.write("{")
+	p.indent++
+	first := b.Finish
+	if len(b.Statements) > 0 {
+		first = b.Statements[0].Pos()
+	}
+	p.line = b.Start.Line
+	p.trailing(b.Start.Line, first)
+	p.newline()
+	p.blockStart = true
+	stmts := make([]Node, len(b.Statements))
+	for i, stmt := range b.Statements {
+		stmts[i] = stmt
+	}
+	p.lines(stmts, b.Finish, false)
+	p.indent--
+	p.write("}")
+}
+
+// body writes a statement that follows a keyword or arrow, usually a block.
+func (p *printer) body(stmt Statement) {
+	if b, ok := stmt.(*BlockStatement); ok {
+		p.block(b)
+		return
+	}
+	if stmt != nil {
+		p.inline(stmt.Pos())
+		p.item(stmt)
+	}
+}
+
+func (p *printer) item(node Node) {
+	switch n := node.(type) {
+	case *PackageDeclaration:
+		p.write("package " + identName(n.Name))
+	case *ModuleDeclaration:
+		p.doc(n.Doc, n.Start)
+		p.write("module " + identName(n.Name) + " ")
+		p.block(n.Body)
+	case *ImportDeclaration:
+		p.importDeclaration(n)
+	case *VariableDeclaration:
+		p.variableDeclaration(n)
+	case *FunctionDeclaration:
+		p.functionDeclaration(n)
+	case *ClassDeclaration:
+		p.doc(n.Doc, n.Start)
+		p.annotations(n.Annotations)
+		if n.Abstract {
+			p.write("abstract ")
+		}
+		p.write("class " + identName(n.Name))
+		p.typeParameters(n.TypeParams)
+		p.parameters(n.Params, n.Name)
+		if n.SuperClass != nil {
+			p.write(" : " + n.SuperClass.Name)
+		}
+		if len(n.Implements) > 0 {
+			if n.SuperClass == nil {
+				p.write(" :")
+			}
+			names := make([]string, len(n.Implements))
+			for i, name := range n.Implements {
+				names[i] = name.Name
+			}
+			p.write(" implements " + strings.Join(names, ", "))
+		}
+		switch {
+		case len(n.Invariants) > 0:
+			members := make([]Node, len(n.Body.Statements))
+			for i, stmt := range n.Body.Statements {
+				members[i] = stmt
+			}
+			p.write(" ")
+			p.braced(n.Body.Start, withInvariants(members, n.Invariants), n.Body.Finish)
+		case n.Body != nil:
+			p.write(" ")
+			p.block(n.Body)
+		}
+	case *StructDeclaration:
+		p.doc(n.Doc, n.Start)
+		p.annotations(n.Annotations)
+		p.write("struct " + identName(n.Name))
+		p.parameters(n.Params, n.Name)
+		if n.Body != nil {
+			p.write(" ")
+			p.block(n.Body)
+		}
+	case *InterfaceDeclaration:
+		p.doc(n.Doc, n.Start)
+		p.annotations(n.Annotations)
+		p.write("interface " + identName(n.Name) + " ")
+		methods := make([]Node, len(n.Methods))
+		for i := range n.Methods {
+			methods[i] = &n.Methods[i]
+		}
+		p.braced(n.Start, methods, n.Finish)
+	case *InterfaceMethod:
+		if n.Default != nil {
+			p.functionDeclaration(n.Default)
+			return
+		}
+		p.doc(n.Doc, n.Start)
+		p.write("fn " + identName(n.Name))
+		p.parameters(n.Params, n.Name)
+		p.returnType(n.ReturnType)
+		p.write(";")
+	case *ImplDeclaration:
+		p.doc(n.Doc, n.Start)
+		p.write("impl " + identName(n.Interface) + " for " + identName(n.Target) + " ")
+		p.block(n.Body)
+	case *EnumDeclaration:
+		p.doc(n.Doc, n.Start)
+		p.annotations(n.Annotations)
+		p.write("enum " + identName(n.Name))
+		p.typeParameters(n.TypeParams)
+		p.write(" ")
+		members := make([]Node, 0, len(n.Cases)+len(n.Methods))
+		for i := range n.Cases {
+			members = append(members, &n.Cases[i])
+		}
+		for _, method := range n.Methods {
+			members = append(members, method)
+		}
+		// Cases and methods are held apart, so put them back in source order.
+		slices.SortStableFunc(members, func(a, b Node) int {
+			return cmp.Or(cmp.Compare(a.Pos().Line, b.Pos().Line), cmp.Compare(a.Pos().Column, b.Pos().Column))
+		})
+		p.braced(n.Start, members, n.Finish)
+	case *EnumCase:
+		p.doc(n.Doc, n.Start)
+		p.write(identName(n.Name))
+		if len(n.Params) > 0 {
+			p.parameters(n.Params, n.Name)
+		}
+		p.write(";")
+	case *ContractDeclaration:
+		p.doc(n.Doc, n.Start)
+		p.annotations(n.Annotations)
+		p.write("contract " + identName(n.Name) + " ")
+		if n.Body == nil {
+			p.block(nil)
+			return
+		}
+		members := make([]Node, 0, len(n.Body.Statements))
+		for _, stmt := range n.Body.Statements {
+			if fn, ok := stmt.(*FunctionDeclaration); ok && fn.Body == nil && !fn.IsExprBody {
+				members = append(members, &contractRequirement{fn: fn})
+				continue
+			}
+			members = append(members, stmt)
+		}
+		p.braced(n.Body.Start, withInvariants(members, n.Invariants), n.Body.Finish)
+	case *contractRequirement:
+		p.functionDeclaration(n.fn)
+		p.write(";")
+	case *ContractInvariant:
+		p.write("invariant ")
+		p.expr(n.Condition, precLowest)
+		p.write(";")
+	case *BlockStatement:
+		p.block(n)
+	case *ExpressionStatement:
+		// A statement that opens with { would parse as a block, so its
+		// leading object literal, the first one written, is parenthesised.
+		p.groupObject = startsWithObject(n.Expression)
+		p.expr(n.Expression, precLowest)
+		p.groupObject = false
+		p.write(";")
+	case *IfStatement:
+		p.write("if ")
+		p.expr(n.Condition, precLowest)
+		p.write(" ")
+		p.body(n.Consequence)
+		if n.Alternative != nil {
+			p.write(" else ")
+			p.body(n.Alternative)
+		}
+	case *WhileStatement:
+		p.label(n.Label)
+		p.write("while ")
+		p.expr(n.Condition, precLowest)
+		p.write(" ")
+		p.body(n.Body)
+	case *DoWhileStatement:
+		p.label(n.Label)
+		p.write("do ")
+		p.block(n.Body)
+		p.write(" while ")
+		p.expr(n.Condition, precLowest)
+		p.write(";")
+	case *ForStatement:
+		p.forStatement(n)
+	case *ForInStatement:
+		p.label(n.Label)
+		p.write("for (" + identName(n.Binding) + " in ")
+		p.expr(n.Iterable, precLowest)
+		p.write(") ")
+		p.block(n.Body)
+	case *ReturnStatement:
+		if n.Value == nil {
+			p.write("return;")
+			return
+		}
+		p.write("return ")
+		p.expr(n.Value, precLowest)
+		p.write(";")
+	case *BreakStatement:
+		p.write(jump("break", n.Label))
+	case *ContinueStatement:
+		p.write(jump("continue", n.Label))
+	case *RethrowStatement:
+		p.write("rethrow;")
+	case *ThrowStatement:
+		p.write("throw ")
+		p.expr(n.Value, precLowest)
+		p.write(";")
+	case *UsingStatement:
+		p.write("using ")
+		if n.Name != nil {
+			p.write(n.Name.Name + " = ")
+		}
+		p.expr(n.Value, precLowest)
+		p.write(" ")
+		p.block(n.Body)
+	case *TryStatement:
+		p.write("try ")
+		p.block(n.Body)
+		for _, clause := range n.Catches {
+			p.write(" catch ")
+			if clause.Identifier != nil {
+				p.write("(" + clause.Identifier.Name)
+				if clause.Type != nil {
+					p.write(": ")
+					p.typeAnnotation(clause.Type)
+				}
+				p.write(") ")
+			}
+			p.block(clause.Body)
+		}
+		if n.Finally != nil {
+			p.write(" finally ")
+			p.block(n.Finally)
+		}
+	case *MatchStatement:
+		p.write("match ")
+		p.expr(n.Value, precLowest)
+		p.write(" ")
+		cases := make([]Node, 0, len(n.Cases)+1)
+		for i := range n.Cases {
+			cases = append(cases, &n.Cases[i])
+		}
+		if n.Else != nil {
+			cases = append(cases, &elseArm{body: n.Else})
+		}
+		p.braced(n.Start, cases, n.Finish)
+	case *MatchCase:
+		for i, pattern := range n.Patterns {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.pattern(pattern)
+		}
+		p.write(" => ")
+		p.body(n.Body)
+	case *ConditionStatement:
+		p.write("condition ")
+		clauses := make([]Node, 0, len(n.Clauses)+1)
+		for i := range n.Clauses {
+			clauses = append(clauses, &n.Clauses[i])
+		}
+		if n.Else != nil {
+			clauses = append(clauses, &elseArm{body: n.Else})
+		}
+		p.braced(n.Start, clauses, n.Finish)
+	case *ConditionClause:
+		p.write("when ")
+		p.expr(n.Test, precLowest)
+		p.write(" => ")
+		p.body(n.Body)
+	case *elseArm:
+		p.write("else => ")
+		p.body(n.body)
+	case *ContractClause:
+		if n.Kind == "requires" || n.Kind == "ensures" {
+			p.write(n.Kind + "(")
+			p.expr(n.Condition, precLowest)
+			p.write(");")
+			return
+		}
+		p.write("returns(")
+		if n.Guard != nil {
+			p.expr(n.Guard, precLowest)
+		}
+		p.write(") => ")
+		p.expr(n.Condition, precLowest)
+		p.write(";")
+	}
+}
+
+// withInvariants merges the invariants of a class or contract, which are
+// held apart, into its other members in source order.
+func withInvariants(members []Node, invariants []*ContractInvariant) []Node {
+	for _, invariant := range invariants {
+		members = append(members, invariant)
+	}
+	slices.SortStableFunc(members, func(a, b Node) int {
+		return cmp.Or(cmp.Compare(a.Pos().Line, b.Pos().Line), cmp.Compare(a.Pos().Column, b.Pos().Column))
+	})
+	return members
+}
+
+// contractRequirement stands in for a function a contract requires, which
+// is written with a ; in place of its body.
+type contractRequirement struct {
+	fn *FunctionDeclaration
+}
+
+func (c *contractRequirement) Pos() token.Position { return c.fn.Pos() }
+func (c *contractRequirement) End() token.Position { return c.fn.End() }
+
+// elseArm stands in for the else arm of a match or condition block, which
+// has no node of its own, so it can be laid out with the other arms.
+type elseArm struct {
+	body Statement
+}
+
+func (c *elseArm) Pos() token.Position { return c.body.Pos() }
+func (c *elseArm) End() token.Position { return c.body.End() }
+
+// braced writes nodes one per line inside braces, as the members of a
+// match, enum, interface, contract, or condition block.
+func (p *printer) braced(start token.Position, nodes []Node, end token.Position) {
+	if _, ok := p.pending(end); !ok && len(nodes) == 0 {
+		p.write("{}")
+		return
+	}
+	p.write("{")
+	p.indent++
+	first := end
+	if len(nodes) > 0 {
+		first = nodes[0].Pos()
+	}
+	p.line = lastLine(start)
+	p.trailing(p.line, first)
+	p.newline()
+	p.blockStart = true
+	p.lines(nodes, end, false)
+	p.indent--
+	p.write("}")
+}
+
+func (p *printer) importDeclaration(n *ImportDeclaration) {
+	p.write("import ")
+	if n.PathLiteral != "" {
+		if n.Alias != nil {
+			p.write(n.Alias.Name + " ")
+		}
+		p.write(quote(n.PathLiteral) + ";")
+		return
+	}
+	names := make([]string, len(n.Path))
+	for i, segment := range n.Path {
+		names[i] = identName(segment)
+	}
+	p.write(strings.Join(names, "."))
+	if n.Alias != nil {
+		p.write(" as " + n.Alias.Name)
+	}
+	p.write(";")
+}
+
+func (p *printer) variableDeclaration(n *VariableDeclaration) {
+	p.doc(n.Doc, n.Start)
+	if n.Static {
+		p.write("static ")
+	}
+	if n.Mutable {
+		p.write("var ")
+	} else {
+		p.write("let ")
+	}
+	if n.Pattern != nil {
+		p.pattern(n.Pattern)
+	} else {
+		p.write(identName(n.Name))
+	}
+	if n.Type != nil {
+		p.write(": ")
+		p.typeAnnotation(n.Type)
+	}
+	p.write(" = ")
+	p.expr(n.Value, precLowest)
+	p.write(";")
+}
+
+func (p *printer) functionDeclaration(n *FunctionDeclaration) {
+	p.doc(n.Doc, n.Start)
+	p.annotations(n.Annotations)
+	if n.IsExtension {
+		p.write("ext fn ")
+		if n.Receiver != nil {
+			p.typeAnnotation(n.Receiver)
+			p.write(".")
+		}
+	} else if n.Accessor != "" {
+		p.write(n.Accessor + " ")
+	} else if n.Static {
+		p.write("static fn ")
+	} else if n.Abstract {
+		p.write("abstract fn ")
+	} else if n.Override {
+		p.write("override fn ")
+	} else {
+		p.write("fn ")
+	}
+	p.write(identName(n.Name))
+	if n.Generator {
+		p.write("*")
+	}
+	p.typeParameters(n.TypeParams)
+	p.parameters(n.Params, n.Name)
+	p.returnType(n.ReturnType)
+	if n.Async {
+		p.write(" async")
+	}
+	if n.Contract != nil {
+		p.newline()
+		p.indent++
+		p.write("contract ")
+		clauses := make([]Node, len(n.Contract.Clauses))
+		for i := range n.Contract.Clauses {
+			clauses[i] = &n.Contract.Clauses[i]
+		}
+		p.braced(n.Contract.Start, clauses, n.Contract.Finish)
+		p.newline()
+		if n.IsExprBody {
+			p.write("=> ")
+			p.expr(n.BodyExpr, precLowest)
+			p.write(";")
+			p.indent--
+			return
+		}
+		p.indent--
+		if n.Body != nil {
+			p.block(n.Body)
+		}
+		return
+	}
+	switch {
+	case n.IsExprBody:
+		p.write(" => ")
+		p.expr(n.BodyExpr, precLowest)
+		p.write(";")
+	case n.Body != nil:
+		p.write(" ")
+		p.block(n.Body)
+	case n.Abstract:
+		p.write(";")
+	}
+}
+
+// label writes the label of a loop, if it has one.
+func (p *printer) label(name string) {
+	if name != "" {
+		p.write(name + ": ")
+	}
+}
+
+// jump renders a break or continue statement.
+func jump(keyword, label string) string {
+	if label == "" {
+		return keyword + ";"
+	}
+	return keyword + " " + label + ";"
+}
+
+func (p *printer) forStatement(n *ForStatement) {
+	p.label(n.Label)
+	p.write("for (")
+	switch init := n.Init.(type) {
+	case nil:
+		p.write(";")
+	case *VariableDeclaration:
+		p.variableDeclaration(init)
+	default:
+		p.item(init)
+	}
+	if n.Condition != nil {
+		p.write(" ")
+		p.expr(n.Condition, precLowest)
+	}
+	p.write(";")
+	if n.Post != nil {
+		p.write(" ")
+		p.expr(n.Post, precLowest)
+	}
+	p.write(") ")
+	p.body(n.Body)
+}
+
+// parameters writes a parenthesised parameter list. Fields with doc
+// comments, or lists the source spread over several lines, get a line each.
+func (p *printer) parameters(params []Parameter, name *Identifier) {
+	items := make([]listItem, len(params))
+	docs := false
+	for i, param := range params {
+		param := param
+		item := listItem{print: func() {
+			p.doc(param.Doc, identStart(param.Name))
+			p.write(identName(param.Name))
+			if param.Type != nil {
+				p.write(": ")
+				p.typeAnnotation(param.Type)
+			}
+		}}
+		if param.Name != nil {
+			item.start, item.end = param.Name.Start, param.Name.Finish
+		}
+		if param.Type != nil {
+			item.end = param.Type.Finish
+		}
+		if param.Doc != "" {
+			docs = true
+		}
+		items[i] = item
+	}
+	after := identEnd(name)
+	if docs && after.Line == 0 {
+		// A built tree has no lines to compare; force one field per line
+		// so the doc comments have somewhere to go.
+		after = token.Position{Line: 1, Column: 1}
+		for i := range items {
+			items[i].start.Line = i + 2
+		}
+	}
+	end := token.Position{}
+	if len(items) > 0 {
+		end = items[len(items)-1].end
+	}
+	p.list("(", ")", false, after, items, end)
+}
+
+func (p *printer) typeParameters(params []*Identifier) {
+	if len(params) == 0 {
+		return
+	}
+	names := make([]string, len(params))
+	for i, param := range params {
+		names[i] = identName(param)
+	}
+	p.write("<" + strings.Join(names, ", ") + ">")
+}
+
+func (p *printer) returnType(t *TypeAnnotation) {
+	if t != nil {
+		p.write(": ")
+		p.typeAnnotation(t)
+	}
+}
+
+func (p *printer) typeAnnotation(t *TypeAnnotation) {
+	if t == nil {
+		return
+	}
+	p.write(identName(t.Name))
+	if len(t.TypeArgs) > 0 {
+		p.write("<")
+		for i, arg := range t.TypeArgs {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.typeAnnotation(arg)
+		}
+		p.write(">")
+	}
+	if t.Nullable {
+		p.write("?")
+	}
+}
+
+// expr writes e, parenthesised when it binds more loosely than min.
+func (p *printer) expr(e Expression, min int) {
+	if e == nil {
+		return
+	}
+	p.inline(e.Pos())
+	if precedence(e) < min {
+		p.write("(")
+		p.expr(e, precLowest)
+		p.write(")")
+		return
+	}
+	switch e := e.(type) {
+	case *Identifier:
+		p.write(e.Name)
+	case *NumberLiteral:
+		p.write(e.Value)
+	case *StringLiteral:
+		if e.Heredoc != "" && !e.Format && heredocFits(e) {
+			p.heredoc(e)
+		} else {
+			p.write(stringLiteral(e))
+		}
+	case *BooleanLiteral:
+		if e.Value {
+			p.write("true")
+		} else {
+			p.write("false")
+		}
+	case *NullLiteral:
+		p.write("null")
+	case *ArrayLiteral:
+		items := make([]listItem, len(e.Elements))
+		for i, element := range e.Elements {
+			element := element
+			items[i] = listItem{start: nodeStart(element), end: nodeEnd(element), print: func() { p.expr(element, precLowest) }}
+		}
+		p.list("[", "]", false, e.Start, items, e.Finish)
+	case *SetLiteral:
+		items := make([]listItem, len(e.Elements))
+		for i, element := range e.Elements {
+			element := element
+			items[i] = listItem{start: nodeStart(element), end: nodeEnd(element), print: func() { p.expr(element, precLowest) }}
+		}
+		p.list("#{", "}", false, e.Start, items, e.Finish)
+	case *ObjectLiteral:
+		if p.groupObject {
+			p.groupObject = false
+			p.write("(")
+			defer p.write(")")
+		}
+		items := make([]listItem, len(e.Pairs))
+		for i, pair := range e.Pairs {
+			pair := pair
+			items[i] = listItem{start: nodeStart(pair.Value), end: nodeEnd(pair.Value), print: func() {
+				p.write(objectKey(pair.Key, pair.KeyIsIdentifier) + ": ")
+				p.expr(pair.Value, precLowest)
+			}}
+		}
+		p.list("{", "}", true, e.Start, items, e.Finish)
+	case *AwaitExpression:
+		p.write("await ")
+		p.expr(e.Expression, precPrefix)
+	case *OldExpression:
+		p.write("old(")
+		p.expr(e.Value, precLowest)
+		p.write(")")
+	case *YieldExpression:
+		p.write("yield")
+		if e.Value != nil {
+			p.write(" ")
+			p.expr(e.Value, precAssignment)
+		}
+	case *PrefixExpression:
+		p.write(e.Operator)
+		if _, nested := e.Right.(*PrefixExpression); nested {
+			// Without parentheses !!x and &&x would lex as one operator.
+			p.write("(")
+			p.expr(e.Right, precLowest)
+			p.write(")")
+			return
+		}
+		p.expr(e.Right, precPrefix)
+	case *InfixExpression:
+		prec := precedence(e)
+		p.expr(e.Left, prec)
+		if prec == precRange {
+			p.write(e.Operator)
+		} else {
+			p.write(" " + e.Operator + " ")
+		}
+		p.expr(e.Right, prec+1)
+	case *ElvisExpression:
+		p.expr(e.Left, precElvis+1)
+		p.write(" ?: ")
+		p.expr(e.Right, precElvis)
+	case *AssignmentExpression:
+		p.expr(e.Target, precAssignment+1)
+		p.write(" " + string(e.Operator) + " ")
+		p.expr(e.Value, precAssignment)
+	case *CallExpression:
+		p.postfixOperand(e.Callee)
+		items := make([]listItem, len(e.Arguments))
+		for i, arg := range e.Arguments {
+			arg := arg
+			items[i] = listItem{start: nodeStart(arg), end: nodeEnd(arg), print: func() { p.expr(arg, precLowest) }}
+		}
+		p.list("(", ")", false, nodeEnd(e.Callee), items, e.Finish)
+	case *IndexExpression:
+		if _, ok := e.Collection.(*PropagateExpression); ok && !e.Optional {
+			// x?[i] would read as an optional index.
+			p.write("(")
+			p.expr(e.Collection, precLowest)
+			p.write(")")
+		} else {
+			p.postfixOperand(e.Collection)
+		}
+		if e.Optional {
+			p.write("?[")
+		} else {
+			p.write("[")
+		}
+		p.expr(e.Index, precLowest)
+		p.write("]")
+	case *MemberExpression:
+		if _, ok := e.Object.(*PropagateExpression); ok && !e.Optional {
+			// x?.y would read as a safe member access.
+			p.write("(")
+			p.expr(e.Object, precLowest)
+			p.write(")")
+		} else {
+			p.postfixOperand(e.Object)
+		}
+		if e.Optional {
+			p.write("?.")
+		} else {
+			p.write(".")
+		}
+		p.write(e.Property)
+	case *NonNullAssertion:
+		p.postfixOperand(e.Expression)
+		p.write("!!")
+	case *PropagateExpression:
+		p.postfixOperand(e.Expression)
+		p.write("?")
+	case *IncrementExpression:
+		p.postfixOperand(e.Target)
+		p.write(e.Operator)
+	}
+}
+
+// postfixOperand writes the operand of a call, index, member access,
+// non-null assertion, propagation, or increment. Number literals are parenthesised so a following dot
+// is not read as a decimal point.
+func (p *printer) postfixOperand(e Expression) {
+	if _, ok := e.(*NumberLiteral); ok {
+		p.write("(")
+		p.expr(e, precLowest)
+		p.write(")")
+		return
+	}
+	p.expr(e, precCall)
+}
+
+func (p *printer) pattern(pattern Pattern) {
+	if pattern == nil {
+		return
+	}
+	p.inline(pattern.Pos())
+	switch n := pattern.(type) {
+	case *LiteralPattern:
+		p.expr(n.Value, precLowest)
+	case *IdentifierPattern:
+		p.write(identName(n.Identifier))
+	case *Identifier, *NumberLiteral, *StringLiteral, *BooleanLiteral, *NullLiteral:
+		p.expr(n.(Expression), precLowest)
+	case *StructPattern:
+		p.write(identName(n.Name) + "(")
+		for i, field := range n.Fields {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.pattern(field)
+		}
+		p.write(")")
+	case *ObjectPattern:
+		if len(n.Pairs) == 0 {
+			p.write("{}")
+			return
+		}
+		p.write("{ ")
+		for i, pair := range n.Pairs {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.write(objectKey(pair.Key, pair.KeyIsIdentifier) + ": ")
+			p.pattern(pair.Value)
+		}
+		p.write(" }")
+	case *ArrayPattern:
+		p.write("[")
+		for i, el := range n.Elements {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.pattern(el)
+		}
+		if n.HasRest {
+			if len(n.Elements) > 0 {
+				p.write(", ")
+			}
+			p.write("..." + identName(n.Rest))
+		}
+		p.write("]")
+	}
+}
+
+// startsWithObject reports whether e prints with an object literal first.
+func startsWithObject(e Expression) bool {
+	for {
+		switch n := e.(type) {
+		case *ObjectLiteral:
+			return true
+		case *InfixExpression:
+			if precedence(n.Left) < precedence(n) {
+				return false
+			}
+			e = n.Left
+		case *ElvisExpression:
+			if precedence(n.Left) <= precElvis {
+				return false
+			}
+			e = n.Left
+		case *AssignmentExpression:
+			e = n.Target
+		case *CallExpression:
+			e = n.Callee
+		case *IndexExpression:
+			e = n.Collection
+		case *MemberExpression:
+			e = n.Object
+		case *NonNullAssertion:
+			e = n.Expression
+		case *PropagateExpression:
+			e = n.Expression
+		case *IncrementExpression:
+			e = n.Target
+		default:
+			return false
+		}
+	}
+}
+
+// stringLiteral re-quotes a string literal. Values hold the source text
+// between the quotes, escapes included, so only the delimiters are chosen.
+func stringLiteral(s *StringLiteral) string {
+	switch {
+	case s.Raw && !strings.Contains(s.Value, `"`):
+		return `r"` + s.Value + `"`
+	case s.Raw && (strings.Contains(s.Value, `"""`) || strings.HasSuffix(s.Value, `"`)) && !strings.Contains(s.Value, "`"):
+		return "`" + s.Value + "`"
+	case s.Raw:
+		return `r"""` + s.Value + `"""`
+	case s.Format:
+		return "f" + quote(s.Value)
+	}
+	return quote(s.Value)
+}
+
+// heredoc writes s as a heredoc, its text indented one level past the
+// closing tag.
+func (p *printer) heredoc(s *StringLiteral) {
+	if s.Raw {
+		p.write("<<~'" + s.Heredoc + "'")
+	} else {
+		p.write("<<~" + s.Heredoc)
+	}
+	p.indent++
+	for _, line := range strings.Split(s.Value, "\n") {
+		p.newline()
+		if line != "" {
+			p.write(line)
+		}
+	}
+	p.indent--
+	p.newline()
+	p.write(s.Heredoc)
+}
+
+// heredocFits reports whether s can be written as a heredoc with its tag:
+// the tag must be an identifier and no line of the text may start with it.
+func heredocFits(s *StringLiteral) bool {
+	for i := 0; i < len(s.Heredoc); i++ {
+		if c := s.Heredoc[i]; !isIdentRune(c) || i == 0 && '0' <= c && c <= '9' {
+			return false
+		}
+	}
+	for _, line := range strings.Split(s.Value, "\n") {
+		rest, ok := strings.CutPrefix(strings.TrimLeft(line, " \t"), s.Heredoc)
+		if ok && (rest == "" || !isIdentRune(rest[0])) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdentRune(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// quote wraps escaped string text in quotes. Bare quotes, which only a
+// triple-quoted literal can hold, are escaped on a single line; text that
+// spans lines stays triple-quoted, with its quotes escaped only if one
+// would end the literal early.
+func quote(text string) string {
+	if !strings.Contains(text, "\n") && !hasBareQuote(text) {
+		return `"` + text + `"`
+	}
+	if strings.Contains(text, `"""`) || strings.HasSuffix(text, `"`) || !strings.Contains(text, "\n") {
+		text = escapeBareQuotes(text)
+	}
+	if !strings.Contains(text, "\n") {
+		return `"` + text + `"`
+	}
+	return `"""` + text + `"""`
+}
+
+func hasBareQuote(text string) bool {
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			return true
+		}
+	}
+	return false
+}
+
+func escapeBareQuotes(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			b.WriteByte(text[i])
+			if i+1 < len(text) {
+				i++
+				b.WriteByte(text[i])
+			}
+			continue
+		case '"':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+func objectKey(key string, isIdentifier bool) string {
+	if isIdentifier {
+		return key
+	}
+	return quote(key)
+}
+
+func identName(id *Identifier) string {
+	if id == nil {
+		return ""
+	}
+	return id.Name
+}
+
+func identStart(id *Identifier) token.Position {
+	if id == nil {
+		return token.Position{}
+	}
+	return id.Start
+}
+
+func identEnd(id *Identifier) token.Position {
+	if id == nil {
+		return token.Position{}
+	}
+	return id.Finish
+}
+
+func nodeStart(e Expression) token.Position {
+	if e == nil {
+		return token.Position{}
+	}
+	return e.Pos()
+}
+
+func nodeEnd(e Expression) token.Position {
+	if e == nil {
+		return token.Position{}
+	}
+	return e.End()
+}
@@ -0,0 +1,270 @@
+package ast_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/lexer"
+	"github.com/cybellereaper/selenelang/internal/parser"
+)
+
+func parse(t *testing.T, src string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parse errors: %v\n%s", errs, src)
+	}
+	return program
+}
+
+func TestPrintRendersCanonicalSource(t *testing.T) {
+	input := `package demo
+import math "github.com/selene-lang/richmath";
+/// A point.
+struct Point(
+    /// Horizontal position.
+    x: Number,
+    y: Number
+)
+enum Option<T> { Some(value: T); None; }
+interface Shape { fn area(): Number; fn describe(): String => "area " + this.area(); }
+impl Shape for Point { fn area(): Number = 0; }
+class Stack<T>(items: Array) : Shape
+/// Keeps v above zero.
+@route( "/clamp" ,"GET")
+@pure fn clamp(v: Number): Number contract { returns(r) => r >= 0; } {
+    var total = 0; // running sum
+    for (let i = 0; i < 3; i += 1) { total = (total + i) * 2; }
+
+    let [lo,hi,...] = [0, v, 1];
+    match v { 0 => return 0; Some(q) => { print(q); } [a, ...rest] => print(rest); other => print(other ?: "none"); }
+    try { throw "x"; } catch (e) { print(f"caught ${e}"); } finally { print(r"done"); }
+    return -(-v);
+}
+ext fn String.shout(): String = this + "!";
+`
+	const expected = `package demo
+import math "github.com/selene-lang/richmath";
+/// A point.
+struct Point(
+    /// Horizontal position.
+    x: Number,
+    y: Number
+)
+enum Option<T> {
+    Some(value: T);
+    None;
+}
+interface Shape {
+    fn area(): Number;
+    fn describe(): String => "area " + this.area();
+}
+impl Shape for Point {
+    fn area(): Number => 0;
+}
+class Stack<T>(items: Array) : Shape
+/// Keeps v above zero.
+@route("/clamp", "GET")
+@pure
+fn clamp(v: Number): Number
+    contract {
+        returns(r) => r >= 0;
+    }
+{
+    var total = 0; // running sum
+    for (let i = 0; i < 3; i += 1) {
+        total = (total + i) * 2;
+    }
+
+    let [lo, hi, ...] = [0, v, 1];
+    match v {
+        0 => return 0;
+        Some(q) => {
+            print(q);
+        }
+        [a, ...rest] => print(rest);
+        other => print(other ?: "none");
+    }
+    try {
+        throw "x";
+    } catch (e) {
+        print(f"caught ${e}");
+    } finally {
+        print(r"done");
+    }
+    return -(-v);
+}
+ext fn String.shout(): String => this + "!";
+`
+	printed := ast.Print(parse(t, input))
+	if printed != expected {
+		t.Fatalf("unexpected output:\n--- got ---\n%s\n--- want ---\n%s", printed, expected)
+	}
+	if again := ast.Print(parse(t, printed)); again != printed {
+		t.Fatalf("printing is not idempotent:\n%s", again)
+	}
+}
+
+func TestPrintKeepsLiteralsAndGrouping(t *testing.T) {
+	input := "let s = `raw \"q\"` + \"esc \\\"q\\\"\" + \"\"\"two\nlines\"\"\";\n" +
+		"let o = { a: 1, \"b c\": [1, 2] };\n" +
+		"let t = #{1, #{}};\n" +
+		"({ a: 1 }).a;\n" +
+		"*p += 1;\n" +
+		"a - (b - c);\n" +
+		"(-a).b;\n" +
+		"(1).toString();\n" +
+		"(load()?).name + parse(text)?;\n"
+	const expected = "let s = `raw \"q\"` + \"esc \\\"q\\\"\" + \"\"\"two\nlines\"\"\";\n" +
+		"let o = { a: 1, \"b c\": [1, 2] };\n" +
+		"let t = #{1, #{}};\n" +
+		"({ a: 1 }).a;\n" +
+		"*p += 1;\n" +
+		"a - (b - c);\n" +
+		"(-a).b;\n" +
+		"(1).toString();\n" +
+		"(load()?).name + parse(text)?;\n"
+	printed := ast.Print(parse(t, input))
+	if printed != expected {
+		t.Fatalf("unexpected output:\n--- got ---\n%s\n--- want ---\n%s", printed, expected)
+	}
+}
+
+func TestPrintKeepsHeredocs(t *testing.T) {
+	input := `fn query() {
+  return run(<<~SQL
+      select *
+        from t
+
+      SQL, <<~'RAW'
+  \d+
+  RAW);
+}
+`
+	const expected = `fn query() {
+    return run(<<~SQL
+        select *
+          from t
+
+    SQL, <<~'RAW'
+        \d+
+    RAW);
+}
+`
+	if printed := ast.Print(parse(t, input)); printed != expected {
+		t.Fatalf("unexpected output:\n--- got ---\n%s\n--- want ---\n%s", printed, expected)
+	}
+	built := &ast.StringLiteral{Value: "a\nEND", Heredoc: "END"}
+	if printed := ast.PrintNode(built); printed != "\"\"\"a\nEND\"\"\"" {
+		t.Fatalf("expected a heredoc holding its tag to be triple-quoted, got %s", printed)
+	}
+}
+
+func TestPrintNodeRendersBuiltTrees(t *testing.T) {
+	ident := func(name string) *ast.Identifier { return &ast.Identifier{Name: name} }
+	expr := &ast.InfixExpression{
+		Left:     &ast.InfixExpression{Left: ident("a"), Operator: "+", Right: ident("b")},
+		Operator: "*",
+		Right:    &ast.PrefixExpression{Operator: "!", Right: &ast.PrefixExpression{Operator: "!", Right: ident("c")}},
+	}
+	if got := ast.PrintNode(expr); got != "(a + b) * !(!c)" {
+		t.Fatalf("unexpected expression %q", got)
+	}
+
+	fn := &ast.FunctionDeclaration{
+		Name:       ident("area"),
+		Doc:        "Area of a circle.",
+		Params:     []ast.Parameter{{Name: ident("r"), Type: &ast.TypeAnnotation{Name: ident("Number")}}},
+		ReturnType: &ast.TypeAnnotation{Name: ident("Number")},
+		IsExprBody: true,
+		BodyExpr:   &ast.InfixExpression{Left: ident("r"), Operator: "*", Right: ident("r")},
+	}
+	const want = "/// Area of a circle.\nfn area(r: Number): Number => r * r;"
+	if got := ast.PrintNode(fn); got != want {
+		t.Fatalf("unexpected declaration:\n%s", got)
+	}
+	program := &ast.Program{Items: []ast.ProgramItem{fn, &ast.ExpressionStatement{
+		Expression: &ast.CallExpression{Callee: ident("print"), Arguments: []ast.Expression{&ast.CallExpression{Callee: ident("area"), Arguments: []ast.Expression{&ast.NumberLiteral{Value: "2"}}}}},
+	}}}
+	printed := ast.Print(program)
+	if printed != want+"\n\nprint(area(2));\n" {
+		t.Fatalf("unexpected program:\n%s", printed)
+	}
+	parse(t, printed)
+}
+
+func TestPrintRendersClassMemberModifiers(t *testing.T) {
+	input := `abstract class Shape(name: String) {
+    static let count = 0;
+    abstract fn area(): Number ;
+    get label(): String => self.name;
+    set label(v: String) { self.name = v; }
+    static fn unit(): Number => 1;
+}
+class Square(name: String) : Shape { override fn area(): Number => 1; }
+`
+	const expected = `abstract class Shape(name: String) {
+    static let count = 0;
+    abstract fn area(): Number;
+    get label(): String => self.name;
+    set label(v: String) {
+        self.name = v;
+    }
+    static fn unit(): Number => 1;
+}
+class Square(name: String) : Shape {
+    override fn area(): Number => 1;
+}
+`
+	if printed := ast.Print(parse(t, input)); printed != expected {
+		t.Fatalf("unexpected output:\n--- got ---\n%s\n--- want ---\n%s", printed, expected)
+	}
+}
+
+// shape lists the kinds of node in program in walk order, with the names and
+// values of leaves, so two trees can be compared regardless of positions.
+func shape(program *ast.Program) []string {
+	var nodes []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case nil:
+		case *ast.Identifier:
+			nodes = append(nodes, "ident "+node.Name)
+		case *ast.NumberLiteral:
+			nodes = append(nodes, "number "+node.Value)
+		case *ast.StringLiteral:
+			nodes = append(nodes, "string "+node.Value)
+		default:
+			nodes = append(nodes, fmt.Sprintf("%T", node))
+		}
+		return true
+	})
+	return nodes
+}
+
+func TestPrintRoundTripsExamples(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "examples", "*", "*.selene"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no examples found: %v", err)
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read %s: %v", file, err)
+		}
+		program := parse(t, string(src))
+		printed := ast.Print(program)
+		reparsed := parse(t, printed)
+		if !reflect.DeepEqual(shape(reparsed), shape(program)) {
+			t.Fatalf("%s: printed source parses to a different tree:\n%s", file, printed)
+		}
+		if again := ast.Print(reparsed); again != printed {
+			t.Fatalf("%s: printing is not stable:\n--- first ---\n%s\n--- second ---\n%s", file, printed, again)
+		}
+	}
+}
@@ -0,0 +1,406 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A Visitor's Visit method is called for each node Walk reaches. If it
+// returns a non-nil visitor w, Walk visits each of the node's children with
+// w and then calls w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree rooted at node in depth-first, source order,
+// starting with v.Visit(node). Nil children are skipped. Members that a
+// declaration holds apart, such as the cases and methods of an enum or the
+// invariants of a class, are visited one group after the other. A node held
+// by value in its parent, such as a match case or an enum case, is visited
+// through a pointer into the parent, and the Olds of a contract block are
+// reached through the clauses that contain them rather than visited again.
+func Walk(node Node, v Visitor) {
+	if isNil(node) {
+		return
+	}
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	children(node, func(child Node) Node {
+		Walk(child, v)
+		return child
+	})
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree rooted at node like Walk, calling fn for each
+// node and, after a node's children, with nil. It skips the children of a
+// node for which fn returns false.
+func Inspect(node Node, fn func(Node) bool) {
+	Walk(node, inspector(fn))
+}
+
+// Hook adapts fn, which handles one kind of node, for Inspect: fn is called
+// for every node of type T and decides whether its children are visited,
+// and the children of every other node are always visited.
+//
+//	ast.Inspect(program, ast.Hook(func(call *ast.CallExpression) bool {
+//		calls++
+//		return true
+//	}))
+func Hook[T Node](fn func(node T) bool) func(Node) bool {
+	return func(node Node) bool {
+		if n, ok := node.(T); ok {
+			return fn(n)
+		}
+		return true
+	}
+}
+
+// Hooks combines the hooks for several kinds of node into one function for
+// Inspect. A node's children are visited when every hook agrees.
+func Hooks(hooks ...func(Node) bool) func(Node) bool {
+	return func(node Node) bool {
+		descend := true
+		for _, hook := range hooks {
+			if !hook(node) {
+				descend = false
+			}
+		}
+		return descend
+	}
+}
+
+// Rewrite rebuilds the tree rooted at node from the bottom up, calling fn on
+// each node once its children have been rewritten and putting what fn
+// returns in the node's place. fn returns its argument to keep a node. A nil
+// result clears the field that held the node, or drops it from a list. The
+// replacement must fit where the node was, for example an Expression for an
+// argument or an *Identifier for a declaration's name, or Rewrite panics.
+// Rewrite returns the rewritten root.
+func Rewrite(node Node, fn func(Node) Node) Node {
+	if isNil(node) {
+		return node
+	}
+	children(node, func(child Node) Node {
+		return Rewrite(child, fn)
+	})
+	return fn(node)
+}
+
+// children calls f with each child of node in source order, storing what f
+// returns in place of any child it replaces. It never writes a slot whose
+// child f returns unchanged, so walking a tree does not modify it.
+func children(node Node, f func(Node) Node) {
+	switch n := node.(type) {
+	case *Program:
+		editList(&n.Items, f)
+	case *Identifier, *NumberLiteral, *StringLiteral, *BooleanLiteral, *NullLiteral,
+		*BreakStatement, *ContinueStatement, *RethrowStatement:
+	case *ArrayLiteral:
+		editList(&n.Elements, f)
+	case *SetLiteral:
+		editList(&n.Elements, f)
+	case *ObjectLiteral:
+		for i := range n.Pairs {
+			edit(&n.Pairs[i].Value, f)
+		}
+	case *AwaitExpression:
+		edit(&n.Expression, f)
+	case *OldExpression:
+		edit(&n.Value, f)
+	case *YieldExpression:
+		edit(&n.Value, f)
+	case *PrefixExpression:
+		edit(&n.Right, f)
+	case *InfixExpression:
+		edit(&n.Left, f)
+		edit(&n.Right, f)
+	case *AssignmentExpression:
+		edit(&n.Target, f)
+		edit(&n.Value, f)
+	case *ElvisExpression:
+		edit(&n.Left, f)
+		edit(&n.Right, f)
+	case *CallExpression:
+		edit(&n.Callee, f)
+		editList(&n.Arguments, f)
+	case *IndexExpression:
+		edit(&n.Collection, f)
+		edit(&n.Index, f)
+	case *MemberExpression:
+		edit(&n.Object, f)
+	case *NonNullAssertion:
+		edit(&n.Expression, f)
+	case *PropagateExpression:
+		edit(&n.Expression, f)
+	case *IncrementExpression:
+		edit(&n.Target, f)
+	case *BlockStatement:
+		editList(&n.Statements, f)
+	case *ExpressionStatement:
+		edit(&n.Expression, f)
+	case *IfStatement:
+		edit(&n.Condition, f)
+		edit(&n.Consequence, f)
+		edit(&n.Alternative, f)
+	case *WhileStatement:
+		edit(&n.Condition, f)
+		edit(&n.Body, f)
+	case *DoWhileStatement:
+		edit(&n.Body, f)
+		edit(&n.Condition, f)
+	case *ForStatement:
+		edit(&n.Init, f)
+		edit(&n.Condition, f)
+		edit(&n.Post, f)
+		edit(&n.Body, f)
+	case *ForInStatement:
+		edit(&n.Binding, f)
+		edit(&n.Iterable, f)
+		edit(&n.Body, f)
+	case *ReturnStatement:
+		edit(&n.Value, f)
+	case *ThrowStatement:
+		edit(&n.Value, f)
+	case *UsingStatement:
+		edit(&n.Name, f)
+		edit(&n.Value, f)
+		edit(&n.Body, f)
+	case *TryStatement:
+		edit(&n.Body, f)
+		editList(&n.Catches, f)
+		edit(&n.Finally, f)
+	case *CatchClause:
+		edit(&n.Identifier, f)
+		edit(&n.Type, f)
+		edit(&n.Body, f)
+	case *ConditionStatement:
+		editValues(&n.Clauses, f)
+		edit(&n.Else, f)
+	case *ConditionClause:
+		edit(&n.Test, f)
+		edit(&n.Body, f)
+	case *VariableDeclaration:
+		edit(&n.Name, f)
+		edit(&n.Pattern, f)
+		edit(&n.Type, f)
+		edit(&n.Value, f)
+	case *TypeAnnotation:
+		edit(&n.Name, f)
+		editList(&n.TypeArgs, f)
+	case *Annotation:
+		edit(&n.Name, f)
+		editList(&n.Arguments, f)
+	case *FunctionDeclaration:
+		editList(&n.Annotations, f)
+		edit(&n.Receiver, f)
+		edit(&n.Name, f)
+		editList(&n.TypeParams, f)
+		parameters(n.Params, f)
+		edit(&n.ReturnType, f)
+		edit(&n.Contract, f)
+		edit(&n.Body, f)
+		edit(&n.BodyExpr, f)
+	case *ContractBlock:
+		editValues(&n.Clauses, f)
+	case *ContractClause:
+		edit(&n.Guard, f)
+		edit(&n.Condition, f)
+	case *ClassDeclaration:
+		editList(&n.Annotations, f)
+		edit(&n.Name, f)
+		editList(&n.TypeParams, f)
+		parameters(n.Params, f)
+		edit(&n.SuperClass, f)
+		editList(&n.Implements, f)
+		edit(&n.Body, f)
+		editList(&n.Invariants, f)
+	case *InterfaceDeclaration:
+		editList(&n.Annotations, f)
+		edit(&n.Name, f)
+		editValues(&n.Methods, f)
+	case *InterfaceMethod:
+		// A method with a default shares its name and parameters with the
+		// default's declaration, so they are visited once, through it.
+		if n.Default != nil {
+			edit(&n.Default, f)
+			return
+		}
+		edit(&n.Name, f)
+		parameters(n.Params, f)
+		edit(&n.ReturnType, f)
+	case *ImplDeclaration:
+		edit(&n.Interface, f)
+		edit(&n.Target, f)
+		edit(&n.Body, f)
+	case *StructDeclaration:
+		editList(&n.Annotations, f)
+		edit(&n.Name, f)
+		parameters(n.Params, f)
+		edit(&n.Body, f)
+	case *EnumDeclaration:
+		editList(&n.Annotations, f)
+		edit(&n.Name, f)
+		editList(&n.TypeParams, f)
+		editValues(&n.Cases, f)
+		editList(&n.Methods, f)
+	case *EnumCase:
+		edit(&n.Name, f)
+		parameters(n.Params, f)
+	case *ContractDeclaration:
+		editList(&n.Annotations, f)
+		edit(&n.Name, f)
+		edit(&n.Body, f)
+		editList(&n.Invariants, f)
+	case *ContractInvariant:
+		edit(&n.Condition, f)
+	case *ImportDeclaration:
+		// The alias comes first in import alias "path"; and last in
+		// import a.b as alias;.
+		if n.PathLiteral != "" {
+			edit(&n.Alias, f)
+		}
+		editList(&n.Path, f)
+		if n.PathLiteral == "" {
+			edit(&n.Alias, f)
+		}
+	case *PackageDeclaration:
+		edit(&n.Name, f)
+	case *ModuleDeclaration:
+		edit(&n.Name, f)
+		edit(&n.Body, f)
+	case *MatchStatement:
+		edit(&n.Value, f)
+		editValues(&n.Cases, f)
+		edit(&n.Else, f)
+	case *MatchCase:
+		editList(&n.Patterns, f)
+		edit(&n.Body, f)
+	case *ObjectPattern:
+		for i := range n.Pairs {
+			edit(&n.Pairs[i].Value, f)
+		}
+	case *StructPattern:
+		edit(&n.Name, f)
+		editList(&n.Fields, f)
+	case *ArrayPattern:
+		editList(&n.Elements, f)
+		edit(&n.Rest, f)
+	case *IdentifierPattern:
+		edit(&n.Identifier, f)
+	case *LiteralPattern:
+		edit(&n.Value, f)
+	default:
+		panic(fmt.Sprintf("ast: unexpected node type %T", node))
+	}
+}
+
+// parameters visits the names and types of params, which are not nodes
+// themselves.
+func parameters(params []Parameter, f func(Node) Node) {
+	for i := range params {
+		edit(&params[i].Name, f)
+		edit(&params[i].Type, f)
+	}
+}
+
+// edit calls f with the child in slot, if there is one, and stores the
+// result if it differs.
+func edit[T Node](slot *T, f func(Node) Node) {
+	if isNil(*slot) {
+		return
+	}
+	if r := f(*slot); r != Node(*slot) {
+		*slot = replacement(*slot, r)
+	}
+}
+
+// editList calls f with each child in list, rebuilding the list only if
+// one of them is replaced or dropped.
+func editList[T Node](list *[]T, f func(Node) Node) {
+	var out []T
+	changed := false
+	for i, n := range *list {
+		if isNil(n) {
+			if changed {
+				out = append(out, n)
+			}
+			continue
+		}
+		r := f(n)
+		if !changed {
+			if r == Node(n) {
+				continue
+			}
+			out = append(make([]T, 0, len(*list)), (*list)[:i]...)
+			changed = true
+		}
+		if r != nil {
+			out = append(out, replacement(n, r))
+		}
+	}
+	if changed {
+		*list = out
+	}
+}
+
+// editValues is editList for a list of nodes held by value, such as the
+// cases of a match statement. A replacement is copied into the list.
+func editValues[T any, P interface {
+	*T
+	Node
+}](list *[]T, f func(Node) Node) {
+	var out []T
+	changed := false
+	for i := range *list {
+		n := P(&(*list)[i])
+		r := f(n)
+		if !changed {
+			if r == Node(n) {
+				continue
+			}
+			out = append(make([]T, 0, len(*list)), (*list)[:i]...)
+			changed = true
+		}
+		if r != nil {
+			out = append(out, *replacement(n, r))
+		}
+	}
+	if changed {
+		*list = out
+	}
+}
+
+// replacement converts what a rewrite returned for old to old's type. A nil
+// result becomes the zero value, clearing the slot.
+func replacement[T Node](old T, r Node) T {
+	var zero T
+	if r == nil {
+		return zero
+	}
+	t, ok := r.(T)
+	if !ok {
+		panic(fmt.Sprintf("ast: cannot replace %T with %T", old, r))
+	}
+	return t
+}
+
+// isNil reports whether n is nil or a nil pointer, as an unset child field
+// of a concrete node type is.
+func isNil(n Node) bool {
+	if n == nil {
+		return true
+	}
+	v := reflect.ValueOf(n)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
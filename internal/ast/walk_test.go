@@ -0,0 +1,130 @@
+package ast_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+)
+
+func TestWalkVisitsNodesInSourceOrder(t *testing.T) {
+	program := parse(t, `fn area(w: Number, h: Number): Number { return w * h; }
+let total = area(width, 2);
+`)
+	var names []string
+	depth, maxDepth := 0, 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			depth--
+			return true
+		}
+		depth++
+		maxDepth = max(maxDepth, depth)
+		if ident, ok := node.(*ast.Identifier); ok {
+			names = append(names, ident.Name)
+		}
+		return true
+	})
+	want := []string{"area", "w", "Number", "h", "Number", "Number", "w", "h", "total", "area", "width"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("unexpected identifiers: got %v, want %v", names, want)
+	}
+	if depth != 0 || maxDepth < 5 {
+		t.Fatalf("unbalanced walk: depth %d, max %d", depth, maxDepth)
+	}
+	if printed := ast.Print(program); !strings.Contains(printed, "return w * h;") {
+		t.Fatalf("walking modified the tree:\n%s", printed)
+	}
+}
+
+func TestHooksSelectNodeKinds(t *testing.T) {
+	program := parse(t, `fn outer() { inner(1); fn nested() { inner(2); } }
+outer();
+`)
+	calls, functions := 0, 0
+	ast.Inspect(program, ast.Hooks(
+		ast.Hook(func(call *ast.CallExpression) bool {
+			calls++
+			return true
+		}),
+		ast.Hook(func(fn *ast.FunctionDeclaration) bool {
+			functions++
+			return fn.Name.Name != "nested"
+		}),
+	))
+	if calls != 2 || functions != 2 {
+		t.Fatalf("expected 2 calls and 2 functions outside nested, got %d and %d", calls, functions)
+	}
+}
+
+func TestRewriteReplacesAndDropsNodes(t *testing.T) {
+	program := parse(t, `let limit = 10;
+debug(limit);
+print(limit + 1);
+`)
+	rewritten := ast.Rewrite(program, func(node ast.Node) ast.Node {
+		switch node := node.(type) {
+		case *ast.ExpressionStatement:
+			if call, ok := node.Expression.(*ast.CallExpression); ok && ast.PrintNode(call.Callee) == "debug" {
+				return nil
+			}
+		case *ast.Identifier:
+			if node.Name == "limit" {
+				return &ast.Identifier{Name: "max"}
+			}
+		}
+		return node
+	})
+	const expected = "let max = 10;\n\nprint(max + 1);\n"
+	if printed := ast.Print(rewritten.(*ast.Program)); printed != expected {
+		t.Fatalf("unexpected output:\n--- got ---\n%s\n--- want ---\n%s", printed, expected)
+	}
+}
+
+func TestRewriteRejectsMisplacedReplacements(t *testing.T) {
+	program := parse(t, "let limit = 10;\n")
+	defer func() {
+		r := recover()
+		if r == nil || !strings.Contains(r.(string), "cannot replace *ast.Identifier with *ast.NumberLiteral") {
+			t.Fatalf("expected a replacement panic, got %v", r)
+		}
+	}()
+	ast.Rewrite(program, func(node ast.Node) ast.Node {
+		if _, ok := node.(*ast.Identifier); ok {
+			return &ast.NumberLiteral{Value: "0"}
+		}
+		return node
+	})
+}
+
+func TestWalkCoversExamples(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "examples", "*", "*.selene"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no examples found: %v", err)
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read %s: %v", file, err)
+		}
+		program := parse(t, string(src))
+		before := ast.Print(program)
+		nodes := 0
+		ast.Inspect(program, func(node ast.Node) bool {
+			if node != nil {
+				nodes++
+			}
+			return true
+		})
+		if nodes == 0 {
+			t.Fatalf("%s: no nodes visited", file)
+		}
+		ast.Rewrite(program, func(node ast.Node) ast.Node { return node })
+		if after := ast.Print(program); after != before {
+			t.Fatalf("%s: identity rewrite changed the tree:\n%s", file, after)
+		}
+	}
+}
@@ -16,8 +16,11 @@ var stubTemplate = template.Must(template.New("seleneWindowsStub").Parse(`packag
 
 import (
     "encoding/base64"
+    "errors"
     "log"
+    "os"
     "strings"
+    "time"
 
     "github.com/cybellereaper/selenelang/internal/jit"
     "github.com/cybellereaper/selenelang/internal/lexer"
@@ -41,12 +44,20 @@ func main() {
         log.Fatalf("selene jit loader: parse error in %s:\n%s", embeddedSourceName, strings.Join(errs, "\n"))
     }
     rt := runtime.New()
+    rt.SetArgs(os.Args[1:])
     compiled, err := jit.Compile(program)
     if err != nil {
         log.Fatalf("selene jit compile error: %v", err)
     }
     if _, err := compiled.Run(rt); err != nil {
-        log.Fatalf("selene jit runtime error: %v", err)
+        var exit *runtime.ExitError
+        if errors.As(err, &exit) {
+            os.Exit(exit.Code)
+        }
+        log.Fatalf("selene jit runtime error: %s", runtime.FormatError(err))
+    }
+    for _, leak := range rt.Shutdown(time.Second) {
+        log.Printf("selene: warning: %s", leak)
     }
 }
 `))
@@ -1,8 +1,12 @@
 package windows
 
 import (
+	"bytes"
+	"encoding/base64"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -30,3 +34,39 @@ func TestFindModuleRootErrorsWhenMissing(t *testing.T) {
 		t.Fatalf("expected error when go.mod is missing")
 	}
 }
+
+func TestStubReportsRuntimeErrorsWithStackTrace(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs the stub")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not available")
+	}
+	root, err := findModuleRoot(".")
+	if err != nil {
+		t.Fatalf("findModuleRoot returned error: %v", err)
+	}
+	source := "fn fail() { throw \"boom\"; }\nfn main() { fail(); }\n"
+	var buf bytes.Buffer
+	data := stubData{SourceName: "boom.selene", EncodedSource: base64.StdEncoding.EncodeToString([]byte(source))}
+	if err := stubTemplate.Execute(&buf, data); err != nil {
+		t.Fatalf("render stub: %v", err)
+	}
+	workdir, err := os.MkdirTemp(root, "selene-win-test-")
+	if err != nil {
+		t.Fatalf("create workdir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(workdir) })
+	if err := os.WriteFile(filepath.Join(workdir, "main.go"), buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("write stub: %v", err)
+	}
+	cmd := exec.Command("go", "run", "./"+filepath.Base(workdir))
+	cmd.Dir = root
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected the stub to fail, got output:\n%s", out)
+	}
+	if !strings.Contains(string(out), "selene jit runtime error: boom\n    at fail (called from 2:13)\n    at main") {
+		t.Fatalf("expected the error with its stack trace, got:\n%s", out)
+	}
+}
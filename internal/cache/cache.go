@@ -0,0 +1,88 @@
+// Package cache manages the on-disk .selene-cache directory shared by the CLI
+// and language server. Entries are grouped into namespaces (for example
+// "bytecode") and addressed by content-derived keys so stale data is never
+// served after a source file changes.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/cybellereaper/selenelang/internal/project"
+)
+
+// DirName is the directory, relative to the project root, that holds cached artefacts.
+const DirName = ".selene-cache"
+
+// Key derives a stable cache key from the provided parts. Parts are length
+// separated so that ("ab", "c") and ("a", "bc") never collide.
+func Key(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		var size [8]byte
+		binary.BigEndian.PutUint64(size[:], uint64(len(part)))
+		h.Write(size[:])
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Path returns the location of an entry without touching the filesystem.
+func Path(root, namespace, key string) (string, error) {
+	return project.ResolveUnderRoot(root, DirName, namespace, key)
+}
+
+// Read loads a cached entry. A missing entry is reported as fs.ErrNotExist.
+func Read(root, namespace, key string) ([]byte, error) {
+	return project.ReadFile(root, DirName, namespace, key)
+}
+
+// Write stores an entry atomically so concurrent readers never observe a
+// partially written file.
+func Write(root, namespace, key string, data []byte) error {
+	target, err := Path(root, namespace, key)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(target)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, target); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+// Clean removes the cache directory under root. Cleaning a project without a
+// cache is not an error.
+func Clean(root string) error {
+	dir, err := project.ResolveUnderRoot(root, DirName)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeySeparatesParts(t *testing.T) {
+	t.Parallel()
+
+	if Key([]byte("ab"), []byte("c")) == Key([]byte("a"), []byte("bc")) {
+		t.Fatalf("expected differently split parts to produce distinct keys")
+	}
+	if Key([]byte("same")) != Key([]byte("same")) {
+		t.Fatalf("expected Key to be deterministic")
+	}
+}
+
+func TestWriteReadAndClean(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	key := Key([]byte("payload"))
+	if _, err := Read(root, "bytecode", key); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected missing entry to report fs.ErrNotExist, got %v", err)
+	}
+	if err := Write(root, "bytecode", key, []byte("chunk")); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	data, err := Read(root, "bytecode", key)
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	if string(data) != "chunk" {
+		t.Fatalf("unexpected cached data %q", data)
+	}
+	entries, err := os.ReadDir(filepath.Join(root, DirName, "bytecode"))
+	if err != nil {
+		t.Fatalf("failed to list cache directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the committed entry to remain, got %d files", len(entries))
+	}
+	if err := Clean(root); err != nil {
+		t.Fatalf("Clean returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, DirName)); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected cache directory to be removed, got %v", err)
+	}
+	if err := Clean(root); err != nil {
+		t.Fatalf("Clean on an absent cache returned error: %v", err)
+	}
+}
+
+func TestPathRejectsEscapingKeys(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Path(t.TempDir(), "bytecode", "../../../outside"); err == nil {
+		t.Fatalf("expected escaping key to be rejected")
+	}
+}
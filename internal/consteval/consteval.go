@@ -0,0 +1,377 @@
+// Package consteval evaluates Selene expressions whose value is known without
+// running the program: arithmetic, string concatenation, and boolean logic on
+// literals, plus `.length` of literal strings and arrays. The bytecode
+// compiler folds such expressions, the language server shows their values on
+// hover, and both report operations that can only fail.
+//
+// Eval follows the runtime's semantics exactly, so a folded expression yields
+// the value the interpreter would have computed and an Error carries the
+// message the interpreter would have raised.
+package consteval
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/lexer"
+)
+
+// Kind identifies the type of a constant value.
+type Kind int
+
+const (
+	// KindNull is the null value.
+	KindNull Kind = iota
+	// KindNumber is a float64 number.
+	KindNumber
+	// KindString is a string.
+	KindString
+	// KindBoolean is true or false.
+	KindBoolean
+)
+
+// Value is the result of evaluating a constant expression. Only the field
+// matching Kind is meaningful.
+type Value struct {
+	Kind   Kind
+	Number float64
+	String string
+	Bool   bool
+}
+
+// Number returns a constant number.
+func Number(v float64) Value { return Value{Kind: KindNumber, Number: v} }
+
+// String returns a constant string.
+func String(v string) Value { return Value{Kind: KindString, String: v} }
+
+// Boolean returns a constant boolean.
+func Boolean(v bool) Value { return Value{Kind: KindBoolean, Bool: v} }
+
+// Null is the constant null value.
+var Null = Value{Kind: KindNull}
+
+// Type returns the runtime type name of the value, such as "Number".
+func (v Value) Type() string {
+	switch v.Kind {
+	case KindNumber:
+		return "Number"
+	case KindString:
+		return "String"
+	case KindBoolean:
+		return "Boolean"
+	default:
+		return "Null"
+	}
+}
+
+// Inspect renders the value the way the runtime prints it, which is also the
+// text it contributes to a string concatenation.
+func (v Value) Inspect() string {
+	switch v.Kind {
+	case KindNumber:
+		return strconv.FormatFloat(v.Number, 'f', -1, 64)
+	case KindString:
+		return v.String
+	case KindBoolean:
+		return strconv.FormatBool(v.Bool)
+	default:
+		return "null"
+	}
+}
+
+// Source renders the value as a Selene literal that evaluates to it.
+func (v Value) Source() string {
+	if v.Kind == KindString {
+		return `"` + escape(v.String) + `"`
+	}
+	return v.Inspect()
+}
+
+func (v Value) truthy() bool {
+	switch v.Kind {
+	case KindNumber:
+		return v.Number != 0
+	case KindString:
+		return v.String != ""
+	case KindBoolean:
+		return v.Bool
+	default:
+		return false
+	}
+}
+
+// ErrNotConstant reports that an expression depends on something only known
+// when the program runs.
+var ErrNotConstant = errors.New("expression is not constant")
+
+// ErrorKind classifies why a constant expression cannot be evaluated.
+type ErrorKind int
+
+const (
+	// DivisionByZero is a `/` whose divisor is zero.
+	DivisionByZero ErrorKind = iota
+	// ModuloByZero is a `%` whose divisor is zero.
+	ModuloByZero
+	// Overflow is arithmetic on finite numbers whose result is infinite. The
+	// runtime does not fail here unless strict math is on; it carries on
+	// with an infinite Number.
+	Overflow
+	// InvalidOperation is an operator applied to operands it does not
+	// accept, such as negating a string.
+	InvalidOperation
+)
+
+// Error describes a constant expression that cannot produce a value. Node is
+// the innermost expression at fault.
+type Error struct {
+	Kind    ErrorKind
+	Node    ast.Node
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Lookup resolves an identifier to a constant value. It reports false for
+// names that are not constants.
+type Lookup func(id *ast.Identifier) (Value, bool)
+
+// Eval computes the value of expr. It returns ErrNotConstant when expr
+// depends on values only known at run time and an *Error when evaluating it
+// would fail. Identifiers are resolved with lookup, which may be nil to treat
+// every identifier as unknown.
+func Eval(expr ast.Expression, lookup Lookup) (Value, error) {
+	switch node := expr.(type) {
+	case *ast.NumberLiteral:
+		num, err := strconv.ParseFloat(node.Value, 64)
+		if err != nil {
+			return Value{}, &Error{Kind: InvalidOperation, Node: node, Message: fmt.Sprintf("invalid number literal %q", node.Value)}
+		}
+		return Number(num), nil
+	case *ast.StringLiteral:
+		return evalString(node)
+	case *ast.BooleanLiteral:
+		return Boolean(node.Value), nil
+	case *ast.NullLiteral:
+		return Null, nil
+	case *ast.Identifier:
+		if lookup != nil {
+			if v, ok := lookup(node); ok {
+				return v, nil
+			}
+		}
+	case *ast.PrefixExpression:
+		if node.Operator != "!" && node.Operator != "-" && node.Operator != "+" {
+			return Value{}, ErrNotConstant
+		}
+		right, err := Eval(node.Right, lookup)
+		if err != nil {
+			return Value{}, err
+		}
+		return evalPrefix(node, right)
+	case *ast.InfixExpression:
+		if !foldable[node.Operator] {
+			return Value{}, ErrNotConstant
+		}
+		left, err := Eval(node.Left, lookup)
+		if err != nil {
+			return Value{}, err
+		}
+		if node.Operator == "&&" || node.Operator == "||" {
+			// As at runtime, the right operand is only evaluated when the
+			// left one does not settle the result.
+			if left.truthy() == (node.Operator == "||") {
+				return left, nil
+			}
+			return Eval(node.Right, lookup)
+		}
+		right, err := Eval(node.Right, lookup)
+		if err != nil {
+			return Value{}, err
+		}
+		return evalInfix(node, left, right)
+	case *ast.ElvisExpression:
+		left, err := Eval(node.Left, lookup)
+		if err != nil {
+			return Value{}, err
+		}
+		if left.truthy() {
+			return left, nil
+		}
+		return Eval(node.Right, lookup)
+	case *ast.MemberExpression:
+		if node.Property != "length" || node.Optional {
+			return Value{}, ErrNotConstant
+		}
+		if array, ok := node.Object.(*ast.ArrayLiteral); ok {
+			for _, element := range array.Elements {
+				if _, err := Eval(element, lookup); err != nil {
+					return Value{}, err
+				}
+			}
+			return Number(float64(len(array.Elements))), nil
+		}
+		object, err := Eval(node.Object, lookup)
+		if err != nil {
+			return Value{}, err
+		}
+		if object.Kind == KindString {
+			return Number(float64(len(object.String))), nil
+		}
+	}
+	return Value{}, ErrNotConstant
+}
+
+// foldable lists the infix operators Eval understands. The rest, such as
+// `is`, depend on runtime types.
+var foldable = map[string]bool{
+	"+": true, "-": true, "*": true, "/": true, "%": true,
+	"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+	"&&": true, "||": true,
+}
+
+// evalString decodes a string literal without interpolation the way the
+// runtime renders it. A `${` placeholder makes the literal non-constant.
+func evalString(lit *ast.StringLiteral) (Value, error) {
+	raw := lit.Value
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '\\' {
+			i++
+			continue
+		}
+		if raw[i] == '$' && i+1 < len(raw) && raw[i+1] == '{' {
+			return Value{}, ErrNotConstant
+		}
+	}
+	if lit.Raw {
+		return String(strings.ReplaceAll(raw, "\\$", "$")), nil
+	}
+	text, err := lexer.Unescape(raw)
+	if err != nil {
+		// The parser reports invalid escapes; a built tree holding one
+		// fails when it runs.
+		return Value{}, ErrNotConstant
+	}
+	return String(text), nil
+}
+
+// escape is the inverse of evalString for a plain string literal. Control
+// characters other than tabs and line breaks are written as \u{...}.
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' || c == '"' || c == '$':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c == 0:
+			b.WriteString(`\0`)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, `\u{%x}`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func evalPrefix(node *ast.PrefixExpression, right Value) (Value, error) {
+	switch node.Operator {
+	case "!":
+		return Boolean(!right.truthy()), nil
+	case "-":
+		if right.Kind != KindNumber {
+			return Value{}, invalid(node, "cannot negate %s", right.Type())
+		}
+		return Number(-right.Number), nil
+	default:
+		if right.Kind != KindNumber {
+			return Value{}, invalid(node, "cannot apply unary + to %s", right.Type())
+		}
+		return right, nil
+	}
+}
+
+func evalInfix(node *ast.InfixExpression, left, right Value) (Value, error) {
+	op := node.Operator
+	switch op {
+	case "+":
+		switch {
+		case left.Kind == KindNumber && right.Kind == KindNumber:
+			return arithmetic(node, left.Number+right.Number)
+		case left.Kind == KindNumber:
+			return Value{}, invalid(node, "cannot add %s to Number", right.Type())
+		case left.Kind == KindString || right.Kind == KindString:
+			return String(left.Inspect() + right.Inspect()), nil
+		default:
+			return Value{}, invalid(node, "operator + not supported for %s", left.Type())
+		}
+	case "-", "*", "/", "%":
+		if left.Kind != KindNumber {
+			return Value{}, invalid(node, "operator %s not supported for %s", op, left.Type())
+		}
+		if right.Kind != KindNumber {
+			return Value{}, invalid(node, "operator %s requires Number on right, got %s", op, right.Type())
+		}
+		l, r := left.Number, right.Number
+		switch op {
+		case "-":
+			return arithmetic(node, l-r)
+		case "*":
+			return arithmetic(node, l*r)
+		case "/":
+			if r == 0 {
+				return Value{}, &Error{Kind: DivisionByZero, Node: node, Message: "division by zero"}
+			}
+			return arithmetic(node, l/r)
+		default:
+			if r == 0 {
+				return Value{}, &Error{Kind: ModuloByZero, Node: node, Message: "modulo by zero"}
+			}
+			return arithmetic(node, math.Mod(l, r))
+		}
+	case "==":
+		return Boolean(left == right), nil
+	case "!=":
+		return Boolean(left != right), nil
+	case "<", "<=", ">", ">=":
+		if left.Kind != KindNumber || right.Kind != KindNumber {
+			return Value{}, invalid(node, "operator %s requires Number operands", op)
+		}
+		l, r := left.Number, right.Number
+		switch op {
+		case "<":
+			return Boolean(l < r), nil
+		case "<=":
+			return Boolean(l <= r), nil
+		case ">":
+			return Boolean(l > r), nil
+		default:
+			return Boolean(l >= r), nil
+		}
+	}
+	return Value{}, ErrNotConstant
+}
+
+// arithmetic checks that a result computed from finite operands is finite.
+// Literals cannot spell an infinity, so every operand Eval sees is finite.
+func arithmetic(node ast.Node, result float64) (Value, error) {
+	if math.IsInf(result, 0) {
+		return Value{}, &Error{Kind: Overflow, Node: node, Message: "constant expression overflows the range of Number"}
+	}
+	return Number(result), nil
+}
+
+func invalid(node ast.Node, format string, args ...any) *Error {
+	return &Error{Kind: InvalidOperation, Node: node, Message: fmt.Sprintf(format, args...)}
+}
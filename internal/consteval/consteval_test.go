@@ -0,0 +1,113 @@
+package consteval_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/consteval"
+	"github.com/cybellereaper/selenelang/internal/lexer"
+	"github.com/cybellereaper/selenelang/internal/parser"
+	"github.com/cybellereaper/selenelang/internal/printer"
+)
+
+func parse(t *testing.T, src string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parse errors: %v\n%s", errs, src)
+	}
+	return program
+}
+
+func expression(t *testing.T, src string) ast.Expression {
+	t.Helper()
+	program := parse(t, src+";")
+	stmt, ok := program.Items[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("%s is not an expression statement", src)
+	}
+	return stmt.Expression
+}
+
+func TestEvalMatchesRuntimeSemantics(t *testing.T) {
+	cases := map[string]string{
+		`1 + 2 * 3`:               "7",
+		`7 % 3 - 0.5`:             "0.5",
+		`"n=" + 1.5`:              `"n=1.5"`,
+		`null + "!"`:              `"null!"`,
+		`"a\tb\${x}"`:             `"a\tb\${x}"`,
+		`"\u{1F600}\u00e9\x41\0"`: `"😀éA\0"`,
+		`"\x07" + "\u007f"`:       `"\u{7}\u{7f}"`,
+		`r"\n\${x}"`:              `"\\n\${x}"`,
+		`!0 && "x" || false`:      `"x"`,
+		`null && 1 / 0`:           "null",
+		`0 || "" || "last"`:       `"last"`,
+		`1 == 1.0`:                "true",
+		`"1" != 1`:                "true",
+		`-(2 - 5) >= 3`:           "true",
+		`null ?: "fallback"`:      `"fallback"`,
+		`"héllo".length`:          "6",
+		`[1, 2 + 2, "x"].length`:  "3",
+	}
+	for src, want := range cases {
+		v, err := consteval.Eval(expression(t, src), nil)
+		if err != nil {
+			t.Fatalf("Eval(%s) failed: %v", src, err)
+		}
+		if got := v.Source(); got != want {
+			t.Fatalf("Eval(%s) = %s, want %s", src, got, want)
+		}
+	}
+}
+
+func TestEvalReportsFailuresAndUnknowns(t *testing.T) {
+	failures := map[string]consteval.ErrorKind{
+		`1 + 4 / (2 - 2)`: consteval.DivisionByZero,
+		`5 % 0`:           consteval.ModuloByZero,
+		`-"x"`:            consteval.InvalidOperation,
+		`true + 1`:        consteval.InvalidOperation,
+		`1 < "2"`:         consteval.InvalidOperation,
+	}
+	for src, kind := range failures {
+		_, err := consteval.Eval(expression(t, src), nil)
+		var evalErr *consteval.Error
+		if !errors.As(err, &evalErr) || evalErr.Kind != kind {
+			t.Fatalf("Eval(%s) = %v, want error kind %d", src, err, kind)
+		}
+	}
+	for _, src := range []string{`x + 1`, `f"${1}"`, `[x].length`, `1 is Number`, `(1 + 2).toString()`} {
+		if _, err := consteval.Eval(expression(t, src), nil); !errors.Is(err, consteval.ErrNotConstant) {
+			t.Fatalf("Eval(%s) = %v, want ErrNotConstant", src, err)
+		}
+	}
+
+	lookup := func(id *ast.Identifier) (consteval.Value, bool) {
+		return consteval.Number(4), id.Name == "four"
+	}
+	if v, err := consteval.Eval(expression(t, "four * four"), lookup); err != nil || v.Number != 16 {
+		t.Fatalf("expected lookup to supply identifiers, got %v, %v", v, err)
+	}
+}
+
+func TestFoldAndCheckWalkWholePrograms(t *testing.T) {
+	program := parse(t, `
+fn main() {
+    let x = 2 * 3 + y;
+    print(-(4 - 1), "a" + "b", 1 / 0);
+}
+`)
+	if errs := consteval.Check(program); len(errs) != 1 || errs[0].Kind != consteval.DivisionByZero {
+		t.Fatalf("expected one division by zero, got %v", errs)
+	}
+	consteval.Fold(program)
+	const want = `fn main() {
+    let x = 6 + y;
+    print(-3, "ab", 1 / 0);
+}
+`
+	if got := printer.Print(program); got != want {
+		t.Fatalf("unexpected folded program:\n%s", got)
+	}
+}
@@ -0,0 +1,241 @@
+package consteval
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/token"
+)
+
+// Fold replaces every constant expression in program with the literal it
+// evaluates to, modifying the tree in place. Expressions that would fail or
+// overflow are left alone so the runtime still raises, or computes, exactly
+// what it would have without folding.
+func Fold(program *ast.Program) {
+	walkProgram(program, func(slot *ast.Expression) bool {
+		if isLiteral(*slot) {
+			return false
+		}
+		v, err := Eval(*slot, nil)
+		if err != nil {
+			return errors.Is(err, ErrNotConstant)
+		}
+		*slot = literal(v, (*slot).Pos(), (*slot).End())
+		return false
+	})
+}
+
+// Check reports every constant expression in program that cannot be
+// evaluated. Each failing expression is reported once, at its innermost
+// fault.
+func Check(program *ast.Program) []*Error {
+	var errs []*Error
+	walkProgram(program, func(slot *ast.Expression) bool {
+		_, err := Eval(*slot, nil)
+		var evalErr *Error
+		if errors.As(err, &evalErr) {
+			errs = append(errs, evalErr)
+			return false
+		}
+		return err != nil
+	})
+	return errs
+}
+
+func isLiteral(expr ast.Expression) bool {
+	switch expr.(type) {
+	case *ast.NumberLiteral, *ast.StringLiteral, *ast.BooleanLiteral, *ast.NullLiteral:
+		return true
+	}
+	return false
+}
+
+// literal builds the expression a parser would produce for v, spanning start
+// to end. Negative numbers become a negated literal.
+func literal(v Value, start, end token.Position) ast.Expression {
+	switch v.Kind {
+	case KindNumber:
+		if math.Signbit(v.Number) {
+			return &ast.PrefixExpression{Operator: "-", Right: literal(Number(-v.Number), start, end), Start: start, Finish: end}
+		}
+		return &ast.NumberLiteral{Value: strconv.FormatFloat(v.Number, 'f', -1, 64), Start: start, Finish: end}
+	case KindString:
+		return &ast.StringLiteral{Value: escape(v.String), Start: start, Finish: end}
+	case KindBoolean:
+		return &ast.BooleanLiteral{Value: v.Bool, Start: start, Finish: end}
+	default:
+		return &ast.NullLiteral{Start: start, Finish: end}
+	}
+}
+
+// walkProgram calls visit for every expression in program, outermost first,
+// passing the field that holds it so visit can replace it. Subexpressions are
+// visited only when visit returns true.
+func walkProgram(program *ast.Program, visit func(*ast.Expression) bool) {
+	w := walker{visit: visit}
+	for _, item := range program.Items {
+		w.item(item)
+	}
+}
+
+type walker struct {
+	visit func(*ast.Expression) bool
+}
+
+func (w walker) item(item ast.ProgramItem) {
+	switch node := item.(type) {
+	case ast.Statement:
+		w.stmt(node)
+	case *ast.ModuleDeclaration:
+		w.block(node.Body)
+	}
+}
+
+func (w walker) block(block *ast.BlockStatement) {
+	if block == nil {
+		return
+	}
+	for _, stmt := range block.Statements {
+		w.stmt(stmt)
+	}
+}
+
+func (w walker) stmt(stmt ast.Statement) {
+	for _, a := range ast.Annotations(stmt) {
+		for i := range a.Arguments {
+			w.expr(&a.Arguments[i])
+		}
+	}
+	switch node := stmt.(type) {
+	case *ast.BlockStatement:
+		w.block(node)
+	case *ast.ExpressionStatement:
+		w.expr(&node.Expression)
+	case *ast.IfStatement:
+		w.expr(&node.Condition)
+		w.stmt(node.Consequence)
+		w.stmt(node.Alternative)
+	case *ast.WhileStatement:
+		w.expr(&node.Condition)
+		w.stmt(node.Body)
+	case *ast.DoWhileStatement:
+		w.block(node.Body)
+		w.expr(&node.Condition)
+	case *ast.ForStatement:
+		w.stmt(node.Init)
+		w.expr(&node.Condition)
+		w.expr(&node.Post)
+		w.stmt(node.Body)
+	case *ast.ForInStatement:
+		w.expr(&node.Iterable)
+		w.block(node.Body)
+	case *ast.ReturnStatement:
+		w.expr(&node.Value)
+	case *ast.ThrowStatement:
+		w.expr(&node.Value)
+	case *ast.UsingStatement:
+		w.expr(&node.Value)
+		w.block(node.Body)
+	case *ast.TryStatement:
+		w.block(node.Body)
+		for _, clause := range node.Catches {
+			w.block(clause.Body)
+		}
+		w.block(node.Finally)
+	case *ast.ConditionStatement:
+		for i := range node.Clauses {
+			w.expr(&node.Clauses[i].Test)
+			w.stmt(node.Clauses[i].Body)
+		}
+		w.stmt(node.Else)
+	case *ast.VariableDeclaration:
+		w.expr(&node.Value)
+	case *ast.FunctionDeclaration:
+		if node.Contract != nil {
+			for i := range node.Contract.Clauses {
+				w.expr(&node.Contract.Clauses[i].Guard)
+				w.expr(&node.Contract.Clauses[i].Condition)
+			}
+		}
+		w.block(node.Body)
+		w.expr(&node.BodyExpr)
+	case *ast.ClassDeclaration:
+		w.block(node.Body)
+	case *ast.StructDeclaration:
+		w.block(node.Body)
+	case *ast.InterfaceDeclaration:
+		for _, method := range node.Methods {
+			if method.Default != nil {
+				w.stmt(method.Default)
+			}
+		}
+	case *ast.ImplDeclaration:
+		w.block(node.Body)
+	case *ast.ContractDeclaration:
+		w.block(node.Body)
+		for _, invariant := range node.Invariants {
+			w.expr(&invariant.Condition)
+		}
+	case *ast.MatchStatement:
+		w.expr(&node.Value)
+		for i := range node.Cases {
+			w.stmt(node.Cases[i].Body)
+		}
+		w.stmt(node.Else)
+	}
+}
+
+func (w walker) expr(slot *ast.Expression) {
+	if *slot == nil || !w.visit(slot) {
+		return
+	}
+	switch node := (*slot).(type) {
+	case *ast.ArrayLiteral:
+		for i := range node.Elements {
+			w.expr(&node.Elements[i])
+		}
+	case *ast.SetLiteral:
+		for i := range node.Elements {
+			w.expr(&node.Elements[i])
+		}
+	case *ast.ObjectLiteral:
+		for i := range node.Pairs {
+			w.expr(&node.Pairs[i].Value)
+		}
+	case *ast.AwaitExpression:
+		w.expr(&node.Expression)
+	case *ast.OldExpression:
+		w.expr(&node.Value)
+	case *ast.YieldExpression:
+		w.expr(&node.Value)
+	case *ast.PrefixExpression:
+		w.expr(&node.Right)
+	case *ast.InfixExpression:
+		w.expr(&node.Left)
+		w.expr(&node.Right)
+	case *ast.AssignmentExpression:
+		w.expr(&node.Target)
+		w.expr(&node.Value)
+	case *ast.ElvisExpression:
+		w.expr(&node.Left)
+		w.expr(&node.Right)
+	case *ast.CallExpression:
+		w.expr(&node.Callee)
+		for i := range node.Arguments {
+			w.expr(&node.Arguments[i])
+		}
+	case *ast.IndexExpression:
+		w.expr(&node.Collection)
+		w.expr(&node.Index)
+	case *ast.MemberExpression:
+		w.expr(&node.Object)
+	case *ast.NonNullAssertion:
+		w.expr(&node.Expression)
+	case *ast.PropagateExpression:
+		w.expr(&node.Expression)
+	case *ast.IncrementExpression:
+		w.expr(&node.Target)
+	}
+}
@@ -4,14 +4,18 @@ package examples
 import (
 	"bytes"
 	"cmp"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cybellereaper/selenelang/internal/jit"
 	"github.com/cybellereaper/selenelang/internal/project"
@@ -33,10 +37,25 @@ const (
 type Script struct {
 	Path     string
 	Relative string
+	// Tags categorise the script. Discover derives one from the directory
+	// directly beneath the example root; ApplyTags adds manifest tags.
+	Tags []string
+}
+
+// HasTags reports whether the script carries every tag in tags.
+func (s Script) HasTags(tags []string) bool {
+	for _, tag := range tags {
+		if !slices.Contains(s.Tags, tag) {
+			return false
+		}
+	}
+	return true
 }
 
 // Discover walks the provided example roots (relative to the repository root)
-// and returns a stable, deduplicated list of runnable scripts.
+// and returns a stable, deduplicated list of runnable scripts. Scripts inside a
+// subdirectory of an example root are tagged with that subdirectory's name, so
+// examples/runtime/errors.selene is tagged "runtime".
 func Discover(root string, roots []string) ([]Script, error) {
 	if len(roots) == 0 {
 		roots = []string{"examples"}
@@ -69,7 +88,13 @@ func Discover(root string, roots []string) ([]Script, error) {
 				rel = path
 			}
 			seen[path] = struct{}{}
-			scripts = append(scripts, Script{Path: path, Relative: filepath.ToSlash(rel)})
+			script := Script{Path: path, Relative: filepath.ToSlash(rel)}
+			if inRoot, relErr := filepath.Rel(base, path); relErr == nil {
+				if dir, _, nested := strings.Cut(filepath.ToSlash(inRoot), "/"); nested {
+					script.Tags = []string{dir}
+				}
+			}
+			scripts = append(scripts, script)
 			return nil
 		}); err != nil {
 			return nil, err
@@ -81,44 +106,84 @@ func Discover(root string, roots []string) ([]Script, error) {
 	return scripts, nil
 }
 
-// Run executes a script using the selected mode. Output produced through the
-// builtin `print` function is redirected to the provided writer when non-nil.
+// Epoch is the time reported by the time module while examples run.
+var Epoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// WorkDir is the working directory os.cwd reports while examples run.
+const WorkDir = "/"
+
+// Run executes a script using the selected mode. Output written with `print`
+// or the stdout module is redirected to the provided writer when non-nil.
+// Scripts run hermetically: stdin is empty, the fs module sees an empty
+// in-memory filesystem and the time module a clock that starts at Epoch and
+// only advances when the script sleeps. The os module sees no environment
+// variables and a working directory of WorkDir, neither shared with the
+// host, and os.exec throws a PermissionError. Tasks or channels still live once the script finishes and
+// ShutdownTimeout has passed fail the run with a *runtime.LeakError.
 func Run(script Script, mode Mode, stdout io.Writer) error {
-	program, _, err := toolchain.ParseFile(script.Path)
-	if err != nil {
-		return err
-	}
+	return RunContext(context.Background(), script, mode, stdout)
+}
+
+// RunContext is Run with cancellation: once ctx is done the script stops at
+// its next loop iteration, function call, or blocking operation, and
+// RunContext returns a *runtime.CancelledError without waiting for it.
+func RunContext(ctx context.Context, script Script, mode Mode, stdout io.Writer) error {
+	_, err := runContext(ctx, script, mode, stdout)
+	return err
+}
+
+// runContext is RunContext that also returns, for a run that succeeds, the
+// tasks the script spawned but never joined and the channels it never
+// closed (see runtime.Runtime.Unreleased). The script's context is cancelled
+// when it returns, so tasks left blocked stop rather than piling up over a
+// suite.
+func runContext(ctx context.Context, script Script, mode Mode, stdout io.Writer) ([]runtime.Leak, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	rt := runtime.New()
-	if stdout != nil {
-		rt.Environment().Set("print", runtime.NewBuiltin("print", func(args []runtime.Value) (runtime.Value, error) {
-			for i, arg := range args {
-				if i > 0 {
-					if _, err := io.WriteString(stdout, " "); err != nil {
-						return nil, err
-					}
-				}
-				if _, err := io.WriteString(stdout, arg.Inspect()); err != nil {
-					return nil, err
-				}
-			}
-			if _, err := io.WriteString(stdout, "\n"); err != nil {
-				return nil, err
-			}
-			return runtime.NullValue, nil
-		}))
+	rt.SetContext(ctx)
+	rt.SetFileSystem(runtime.NewMemoryFileSystem(nil))
+	rt.SetClock(runtime.NewFixedClock(Epoch))
+	rt.SetHostEnv(runtime.NewMemoryHostEnv(nil, WorkDir))
+	if err := rt.SetPolicy(runtime.Policy{Capabilities: map[runtime.Capability]bool{runtime.CapabilityExec: false}}); err != nil {
+		return nil, err
+	}
+	rt.SetStdio(strings.NewReader(""), stdout, nil)
+	done := make(chan error, 1)
+	go func() { done <- execute(rt, script, mode) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, err
+		}
+		return rt.Unreleased(), nil
+	case <-ctx.Done():
+		return nil, &runtime.CancelledError{Err: ctx.Err()}
 	}
+}
+
+func execute(rt *runtime.Runtime, script Script, mode Mode) error {
+	defer rt.Flush()
 	if err := toolchain.LoadDependencies(rt, script.Path); err != nil {
 		return err
 	}
+	if mode == ModeVM {
+		chunk, err := toolchain.CompileFile(rt, script.Path)
+		if err != nil {
+			return err
+		}
+		if _, err := rt.RunChunk(chunk); err != nil {
+			return err
+		}
+		return shutdown(rt)
+	}
+	program, _, err := toolchain.ParseFile(script.Path)
+	if err != nil {
+		return err
+	}
 	switch mode {
 	case ModeInterpreter:
 		_, err = rt.Run(program)
-	case ModeVM:
-		chunk, cerr := rt.Compile(program)
-		if cerr != nil {
-			return cerr
-		}
-		_, err = rt.RunChunk(chunk)
 	case ModeJIT:
 		compiled, cerr := jit.Compile(program)
 		if cerr != nil {
@@ -128,22 +193,141 @@ func Run(script Script, mode Mode, stdout io.Writer) error {
 	default:
 		err = fmt.Errorf("unknown execution mode %q", mode)
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	return shutdown(rt)
 }
 
-// RunAll executes each script using every requested mode. It returns a slice of
-// accumulated errors (rather than failing fast) so tooling can report the full
-// set of failing examples.
-func RunAll(scripts []Script, modes []Mode) []error {
+// ShutdownTimeout bounds how long Run waits for tasks a script spawned but
+// did not await before reporting them as leaked.
+const ShutdownTimeout = 2 * time.Second
+
+func shutdown(rt *runtime.Runtime) error {
+	if leaks := rt.Shutdown(ShutdownTimeout); len(leaks) > 0 {
+		return &runtime.LeakError{Leaks: leaks}
+	}
+	return nil
+}
+
+// SuiteOptions configures RunSuite.
+type SuiteOptions struct {
+	// Parallel is the number of scripts run at once. Values below one run the
+	// suite serially.
+	Parallel int
+	// Timeout bounds each script run. Zero means no limit.
+	Timeout time.Duration
+	// CaptureOutput records what each script prints in Result.Output.
+	CaptureOutput bool
+	// Report, when set, is called with each result in suite order as soon as
+	// it and every result before it are complete.
+	Report func(Result)
+}
+
+// Result is the outcome of running one script in one mode.
+type Result struct {
+	Script Script
+	Mode   Mode
+	Output string
+	Err    error
+	// Leaks lists the tasks a script that passed spawned but never joined
+	// and the channels it never closed. They do not fail the run.
+	Leaks   []runtime.Leak
+	Elapsed time.Duration
+}
+
+// RunSuite executes each script in every mode on a pool of opts.Parallel
+// workers. Results come back ordered by script and then mode regardless of
+// the order in which runs finish, so output stays deterministic.
+func RunSuite(scripts []Script, modes []Mode, opts SuiteOptions) []Result {
 	if len(modes) == 0 {
 		modes = []Mode{ModeInterpreter}
 	}
-	errs := make([]error, 0, len(scripts)*len(modes))
+	results := make([]Result, 0, len(scripts)*len(modes))
 	for _, script := range scripts {
 		for _, mode := range modes {
-			if err := Run(script, mode, io.Discard); err != nil {
-				errs = append(errs, fmt.Errorf("%s [%s]: %w", script.Relative, mode, err))
+			results = append(results, Result{Script: script, Mode: mode})
+		}
+	}
+	workers := min(max(opts.Parallel, 1), max(len(results), 1))
+	jobs := make(chan int)
+	finished := make([]chan struct{}, len(results))
+	for i := range finished {
+		finished[i] = make(chan struct{})
+	}
+	for range workers {
+		go func() {
+			for i := range jobs {
+				runResult(&results[i], opts)
+				close(finished[i])
 			}
+		}()
+	}
+	go func() {
+		for i := range results {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+	for i := range results {
+		<-finished[i]
+		if opts.Report != nil {
+			opts.Report(results[i])
+		}
+	}
+	return results
+}
+
+func runResult(result *Result, opts SuiteOptions) {
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	// A script that times out may keep printing until it notices, so the
+	// buffer must tolerate writes after RunContext has returned.
+	output := &lockedBuffer{}
+	writer := io.Discard
+	if opts.CaptureOutput {
+		writer = output
+	}
+	start := time.Now()
+	leaks, err := runContext(ctx, result.Script, result.Mode, writer)
+	result.Elapsed = time.Since(start)
+	result.Leaks = leaks
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", opts.Timeout)
+	}
+	result.Err = err
+	result.Output = output.String()
+}
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// RunAll executes each script using every requested mode. It returns a slice of
+// accumulated errors (rather than failing fast) so tooling can report the full
+// set of failing examples.
+func RunAll(scripts []Script, modes []Mode) []error {
+	var errs []error
+	for _, result := range RunSuite(scripts, modes, SuiteOptions{}) {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("%s [%s]: %w", result.Script.Relative, result.Mode, result.Err))
 		}
 	}
 	return errs
@@ -165,6 +349,76 @@ func ManifestRoots(root string) ([]string, error) {
 	return slices.Clone(manifest.Examples.Roots), nil
 }
 
+// ApplyTags adds manifest tags to the scripts they cover. Each key in tags is
+// a script path or a directory, relative to the project root and written with
+// forward slashes.
+func ApplyTags(scripts []Script, tags map[string][]string) {
+	for i := range scripts {
+		for path, extra := range tags {
+			path = strings.TrimSuffix(path, "/")
+			if scripts[i].Relative != path && !strings.HasPrefix(scripts[i].Relative, path+"/") {
+				continue
+			}
+			for _, tag := range extra {
+				if !slices.Contains(scripts[i].Tags, tag) {
+					scripts[i].Tags = append(scripts[i].Tags, tag)
+				}
+			}
+		}
+		slices.Sort(scripts[i].Tags)
+	}
+}
+
+// ManifestTags returns the [examples.tags] table from the project manifest,
+// or nil when the project has no manifest.
+func ManifestTags(root string) (map[string][]string, error) {
+	manifest, err := project.LoadManifest(root)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return manifest.Examples.Tags, nil
+}
+
+// DiscoverWorkspace discovers and tags the examples of every workspace
+// member using each member's own manifest. Relative paths, and therefore
+// filters, are relative to the workspace root.
+func DiscoverWorkspace(ws *project.Workspace) ([]Script, error) {
+	seen := make(map[string]bool)
+	var scripts []Script
+	for _, member := range ws.Members {
+		roots := member.Manifest.Examples.Roots
+		if len(roots) == 0 {
+			roots = []string{"examples"}
+		}
+		prefixed := make([]string, len(roots))
+		for i, root := range roots {
+			prefixed[i] = filepath.Join(member.Dir, root)
+		}
+		found, err := Discover(ws.Root, prefixed)
+		if err != nil {
+			return nil, err
+		}
+		tags := make(map[string][]string, len(member.Manifest.Examples.Tags))
+		for key, values := range member.Manifest.Examples.Tags {
+			tags[path.Join(member.Path, key)] = values
+		}
+		ApplyTags(found, tags)
+		for _, script := range found {
+			if !seen[script.Path] {
+				seen[script.Path] = true
+				scripts = append(scripts, script)
+			}
+		}
+	}
+	slices.SortFunc(scripts, func(a, b Script) int {
+		return cmp.Compare(a.Relative, b.Relative)
+	})
+	return scripts, nil
+}
+
 // Capture executes the script using the interpreter and returns everything the
 // program printed. It is a convenience helper for documentation tooling.
 func Capture(script Script) (string, error) {
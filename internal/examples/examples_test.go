@@ -6,11 +6,17 @@ import (
 	"io"
 	iofs "io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/cybellereaper/selenelang/internal/examples"
+	"github.com/cybellereaper/selenelang/internal/lexer"
+	"github.com/cybellereaper/selenelang/internal/parser"
+	"github.com/cybellereaper/selenelang/internal/printer"
 	"github.com/cybellereaper/selenelang/internal/project"
+	"github.com/cybellereaper/selenelang/internal/runtime"
 )
 
 func TestExamplesRunAcrossBackends(t *testing.T) {
@@ -30,6 +36,51 @@ func TestExamplesRunAcrossBackends(t *testing.T) {
 	}
 }
 
+// TestExamplesSurvivePrinting prints every example back to source with
+// printer.Print and checks that the result parses, prints identically again,
+// and behaves like the original.
+func TestExamplesSurvivePrinting(t *testing.T) {
+	for _, script := range discoverScripts(t) {
+		script := script
+		t.Run(strings.ReplaceAll(script.Relative, "/", "_"), func(t *testing.T) {
+			data, err := os.ReadFile(script.Path)
+			if err != nil {
+				t.Fatalf("read %s: %v", script.Relative, err)
+			}
+			printed := printSource(t, string(data))
+			if again := printSource(t, printed); again != printed {
+				t.Fatalf("printing is not idempotent:\n--- first ---\n%s\n--- second ---\n%s", printed, again)
+			}
+			want, err := examples.Capture(script)
+			if err != nil {
+				t.Fatalf("run %s: %v", script.Relative, err)
+			}
+			copyPath := filepath.Join(filepath.Dir(script.Path), ".printed-"+filepath.Base(script.Path))
+			if err := os.WriteFile(copyPath, []byte(printed), 0o644); err != nil {
+				t.Fatalf("write printed copy: %v", err)
+			}
+			defer os.Remove(copyPath)
+			got, err := examples.Capture(examples.Script{Path: copyPath, Relative: script.Relative})
+			if err != nil {
+				t.Fatalf("run printed %s: %v\n%s", script.Relative, err, printed)
+			}
+			if got != want {
+				t.Fatalf("printed %s behaves differently:\n--- got ---\n%s\n--- want ---\n%s", script.Relative, got, want)
+			}
+		})
+	}
+}
+
+func printSource(t *testing.T, src string) string {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parse errors: %v\n%s", errs, src)
+	}
+	return printer.Print(program)
+}
+
 func discoverScripts(t *testing.T) []examples.Script {
 	t.Helper()
 	wd, err := os.Getwd()
@@ -57,3 +108,193 @@ func discoverScripts(t *testing.T) []examples.Script {
 	}
 	return scripts
 }
+
+func TestDiscoverTagsScriptsByDirectoryAndManifest(t *testing.T) {
+	root := t.TempDir()
+	for _, rel := range []string{"examples/top.selene", "examples/runtime/tasks.selene", "examples/runtime/nested/deep.selene"} {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte("print(1);\n"), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", rel, err)
+		}
+	}
+	scripts, err := examples.Discover(root, []string{"examples"})
+	if err != nil {
+		t.Fatalf("Discover returned error: %v", err)
+	}
+	examples.ApplyTags(scripts, map[string][]string{
+		"examples/runtime/tasks.selene": {"concurrency"},
+		"examples/runtime/nested/":      {"deep"},
+	})
+	got := make(map[string]string, len(scripts))
+	for _, script := range scripts {
+		got[script.Relative] = strings.Join(script.Tags, ",")
+	}
+	want := map[string]string{
+		"examples/top.selene":                 "",
+		"examples/runtime/tasks.selene":       "concurrency,runtime",
+		"examples/runtime/nested/deep.selene": "deep,runtime",
+	}
+	for rel, tags := range want {
+		if got[rel] != tags {
+			t.Fatalf("tags for %s = %q, want %q", rel, got[rel], tags)
+		}
+	}
+	if !scripts[1].HasTags([]string{"runtime", "concurrency"}) || scripts[1].HasTags([]string{"deep"}) {
+		t.Fatalf("HasTags mismatch for %+v", scripts[1])
+	}
+}
+
+func TestRunIsHermetic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hermetic.selene")
+	source := `
+let start = time.now();
+time.sleep(1500);
+fs.write("notes.txt", "hello");
+print(start, time.now() - start, fs.read("notes.txt"), fs.exists("` + filepath.ToSlash(path) + `"));
+`
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	var out strings.Builder
+	if err := examples.Run(examples.Script{Path: path, Relative: "hermetic.selene"}, examples.ModeInterpreter, &out); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	want := fmt.Sprintf("%d 1500 hello false\n", examples.Epoch.UnixMilli())
+	if out.String() != want {
+		t.Fatalf("unexpected output %q, want %q", out.String(), want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); !errors.Is(err, iofs.ErrNotExist) {
+		t.Fatalf("expected fs.write to stay in memory, stat returned %v", err)
+	}
+}
+
+func TestSoakLoopsSuiteWithoutLeaks(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "loop.selene")
+	source := "fn send(out: Channel) { out.send(1); }\nlet ch = channel();\nspawn(send, ch);\nprint(ch.recv());\n"
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	script := examples.Script{Path: path, Relative: "loop.selene"}
+	var reported int
+	result := examples.Soak([]examples.Script{script}, examples.SoakOptions{
+		Modes:        []examples.Mode{examples.ModeInterpreter, examples.ModeVM},
+		BallastBytes: 1 << 20,
+		Report:       func(examples.SoakIteration) { reported++ },
+	})
+	if result.Iterations < 2 || reported != result.Iterations {
+		t.Fatalf("expected at least two reported iterations, got %d (reported %d)", result.Iterations, reported)
+	}
+	if len(result.Failures) != 0 || len(result.Leaks) != 0 {
+		t.Fatalf("unexpected soak problems: failures=%v leaks=%v", result.Failures, result.Leaks)
+	}
+}
+
+func TestRunFailsOnLeakedTasks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leak.selene")
+	source := "fn wait(ch: Channel) { ch.recv(); }\nlet ch = channel();\nspawn(wait, ch);\n"
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	err := examples.Run(examples.Script{Path: path, Relative: "leak.selene"}, examples.ModeInterpreter, io.Discard)
+	var leakErr *runtime.LeakError
+	if !errors.As(err, &leakErr) || len(leakErr.Leaks) != 2 {
+		t.Fatalf("expected a leaked task and channel, got %v", err)
+	}
+}
+
+func TestRunKeepsScriptsAwayFromHostEnvAndProcesses(t *testing.T) {
+	t.Setenv("SELENE_EXAMPLE_SECRET", "leaked")
+	path := filepath.Join(t.TempDir(), "host.selene")
+	source := `print(os.env("SELENE_EXAMPLE_SECRET"));
+os.setenv("SELENE_EXAMPLE_SECRET", "changed");
+print(os.env("SELENE_EXAMPLE_SECRET"));
+os.chdir("work");
+print(os.cwd(), path.abs("notes.txt"));
+try {
+    os.exec("echo", ["hi"]);
+} catch (err: PermissionError) {
+    print(err.capability);
+}
+`
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	var out strings.Builder
+	if err := examples.Run(examples.Script{Path: path, Relative: "host.selene"}, examples.ModeInterpreter, &out); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	want := "null\nchanged\n" + filepath.Join(examples.WorkDir, "work") + " " + filepath.Join(examples.WorkDir, "work", "notes.txt") + "\nos.exec\n"
+	if out.String() != want {
+		t.Fatalf("expected %q, got %q", want, out.String())
+	}
+	if got := os.Getenv("SELENE_EXAMPLE_SECRET"); got != "leaked" {
+		t.Fatalf("expected the host environment to be untouched, got %q", got)
+	}
+}
+
+func TestRunSuiteReportsUnjoinedTasks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "unjoined.selene")
+	source := "fn quick(n: Number) { return n; }\nspawn(quick, 1);\n"
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	scripts := []examples.Script{{Path: path, Relative: "unjoined.selene"}}
+	results := examples.RunSuite(scripts, []examples.Mode{examples.ModeInterpreter}, examples.SuiteOptions{})
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("expected the script to pass, got %+v", results)
+	}
+	leaks := results[0].Leaks
+	if len(leaks) != 1 || leaks[0].String() != "task created at "+path+":2:1 (<fn quick>) was never joined" {
+		t.Fatalf("expected the unjoined task to be reported, got %v", leaks)
+	}
+}
+
+func TestRunSuiteRunsInParallelWithTimeoutsInOrder(t *testing.T) {
+	root := t.TempDir()
+	sources := map[string]string{
+		"a.selene":    "print(\"a\");\n",
+		"spin.selene": "while true { let x = 1; }\n",
+		"c.selene":    "print(\"c\");\n",
+	}
+	var scripts []examples.Script
+	for _, name := range []string{"a.selene", "spin.selene", "c.selene"} {
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, []byte(sources[name]), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		scripts = append(scripts, examples.Script{Path: path, Relative: name})
+	}
+	modes := []examples.Mode{examples.ModeInterpreter, examples.ModeVM, examples.ModeJIT}
+	var reported []string
+	results := examples.RunSuite(scripts, modes, examples.SuiteOptions{
+		Parallel:      4,
+		Timeout:       100 * time.Millisecond,
+		CaptureOutput: true,
+		Report: func(r examples.Result) {
+			reported = append(reported, fmt.Sprintf("%s/%s", r.Script.Relative, r.Mode))
+		},
+	})
+	if len(results) != 9 || len(reported) != 9 {
+		t.Fatalf("expected 9 results, got %d (reported %d)", len(results), len(reported))
+	}
+	for i, result := range results {
+		want := fmt.Sprintf("%s/%s", scripts[i/3].Relative, modes[i%3])
+		if reported[i] != want {
+			t.Fatalf("result %d reported as %s, want %s", i, reported[i], want)
+		}
+		if result.Script.Relative == "spin.selene" {
+			if result.Err == nil || !strings.Contains(result.Err.Error(), "timed out") {
+				t.Fatalf("expected %s to time out, got %v", want, result.Err)
+			}
+			continue
+		}
+		if result.Err != nil || strings.TrimSpace(result.Output) != strings.TrimSuffix(result.Script.Relative, ".selene") {
+			t.Fatalf("unexpected result for %s: output %q, err %v", want, result.Output, result.Err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package examples
+
+import (
+	"fmt"
+	"io"
+	goruntime "runtime"
+	"time"
+)
+
+// SoakOptions configures a soak run of the example suite.
+type SoakOptions struct {
+	// Duration is how long to keep looping the suite. At least two iterations
+	// always run: the first establishes the heap and goroutine baseline.
+	Duration time.Duration
+	Modes    []Mode
+	// BallastBytes is held live for the whole run so the collector runs less
+	// often and heap measurements reflect retained memory, not GC pacing.
+	BallastBytes int
+	// HeapGrowthLimit is the tolerated fractional growth of the live heap over
+	// the baseline before an iteration is reported as leaking. Zero means 0.5.
+	HeapGrowthLimit float64
+	// SettleTimeout is how long to wait for goroutines started by an iteration
+	// to exit before they are reported as leaked. Zero means two seconds.
+	SettleTimeout time.Duration
+	// Report, when set, is called after every iteration.
+	Report func(SoakIteration)
+}
+
+// SoakIteration summarises one pass over the suite.
+type SoakIteration struct {
+	Index   int
+	Elapsed time.Duration
+	// HeapAlloc is the live heap after a full collection, excluding ballast.
+	HeapAlloc  uint64
+	Goroutines int
+	Failures   []error
+	// Leaks describes heap growth or goroutines that outlived the pass.
+	Leaks []string
+}
+
+// SoakResult is the outcome of Soak.
+type SoakResult struct {
+	Iterations         int
+	BaselineHeap       uint64
+	PeakHeap           uint64
+	BaselineGoroutines int
+	Failures           []error
+	Leaks              []string
+}
+
+// minHeapSlack keeps tiny suites from tripping the growth limit on noise.
+const minHeapSlack = 4 << 20
+
+// Soak repeatedly runs every script in every mode until opts.Duration has
+// elapsed, checking after each pass that the live heap has not grown beyond
+// the limit and that no goroutines were left behind.
+func Soak(scripts []Script, opts SoakOptions) SoakResult {
+	if len(opts.Modes) == 0 {
+		opts.Modes = []Mode{ModeInterpreter}
+	}
+	if opts.HeapGrowthLimit <= 0 {
+		opts.HeapGrowthLimit = 0.5
+	}
+	if opts.SettleTimeout <= 0 {
+		opts.SettleTimeout = 2 * time.Second
+	}
+	ballast := make([]byte, opts.BallastBytes)
+	defer goruntime.KeepAlive(ballast)
+
+	var result SoakResult
+	start := time.Now()
+	for index := 0; index < 2 || time.Since(start) < opts.Duration; index++ {
+		before := goruntime.NumGoroutine()
+		iteration := SoakIteration{Index: index}
+		for _, script := range scripts {
+			for _, mode := range opts.Modes {
+				if err := Run(script, mode, io.Discard); err != nil {
+					iteration.Failures = append(iteration.Failures, fmt.Errorf("%s [%s]: %w", script.Relative, mode, err))
+				}
+			}
+		}
+		iteration.Goroutines = settleGoroutines(before, opts.SettleTimeout)
+		iteration.HeapAlloc = liveHeap(uint64(len(ballast)))
+		iteration.Elapsed = time.Since(start)
+
+		if index == 0 {
+			result.BaselineHeap = iteration.HeapAlloc
+			result.BaselineGoroutines = iteration.Goroutines
+		}
+		if leaked := iteration.Goroutines - before; leaked > 0 {
+			iteration.Leaks = append(iteration.Leaks, fmt.Sprintf("iteration %d leaked %d goroutine(s)", index, leaked))
+		}
+		limit := result.BaselineHeap + max(uint64(float64(result.BaselineHeap)*opts.HeapGrowthLimit), minHeapSlack)
+		if index > 0 && iteration.HeapAlloc > limit {
+			iteration.Leaks = append(iteration.Leaks, fmt.Sprintf("iteration %d heap %d bytes exceeds baseline %d by more than %.0f%%",
+				index, iteration.HeapAlloc, result.BaselineHeap, opts.HeapGrowthLimit*100))
+		}
+		result.PeakHeap = max(result.PeakHeap, iteration.HeapAlloc)
+		result.Failures = append(result.Failures, iteration.Failures...)
+		result.Leaks = append(result.Leaks, iteration.Leaks...)
+		result.Iterations++
+		if opts.Report != nil {
+			opts.Report(iteration)
+		}
+	}
+	return result
+}
+
+// settleGoroutines waits up to timeout for the goroutine count to fall back
+// to baseline and returns the last observed count.
+func settleGoroutines(baseline int, timeout time.Duration) int {
+	deadline := time.Now().Add(timeout)
+	for {
+		count := goruntime.NumGoroutine()
+		if count <= baseline || time.Now().After(deadline) {
+			return count
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func liveHeap(ballast uint64) uint64 {
+	goruntime.GC()
+	var stats goruntime.MemStats
+	goruntime.ReadMemStats(&stats)
+	if stats.HeapAlloc < ballast {
+		return 0
+	}
+	return stats.HeapAlloc - ballast
+}
@@ -0,0 +1,55 @@
+// Package explain holds the extended descriptions of diagnostic codes that
+// `selene explain` prints: what each diagnostic means, an example that
+// triggers it, and the usual fixes. The texts are Markdown files embedded in
+// the binary, one per code, named after the code.
+package explain
+
+import (
+	"embed"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+//go:embed explanations/*.md
+var explanations embed.FS
+
+// Lookup returns the explanation of a diagnostic code such as
+// "lint.unused-variable". Codes are matched without regard to case.
+func Lookup(code string) (string, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" || strings.ContainsAny(code, `/\`) {
+		return "", false
+	}
+	data, err := explanations.ReadFile("explanations/" + code + ".md")
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Codes lists every code that has an explanation, in sorted order.
+func Codes() []string {
+	entries, err := fs.ReadDir(explanations, "explanations")
+	if err != nil {
+		return nil
+	}
+	codes := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		codes = append(codes, strings.TrimSuffix(entry.Name(), ".md"))
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Summary returns the one-line title of code's explanation, the text after
+// the code on its first line.
+func Summary(code string) string {
+	text, ok := Lookup(code)
+	if !ok {
+		return ""
+	}
+	first, _, _ := strings.Cut(text, "\n")
+	_, title, _ := strings.Cut(first, ": ")
+	return title
+}
@@ -0,0 +1,52 @@
+package explain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cybellereaper/selenelang/internal/i18n"
+)
+
+func TestEveryDiagnosticCodeIsExplained(t *testing.T) {
+	codes := []i18n.MessageID{
+		i18n.LintTrailingWhitespace,
+		i18n.LintLongLine,
+		i18n.LintMissingFinalNewline,
+		i18n.LintTodoComment,
+		i18n.LintUnusedVariable,
+		i18n.LintEmptyFunction,
+		i18n.LintMissingPackage,
+		i18n.LexIllegalToken,
+		i18n.ConstDivisionByZero,
+		i18n.ConstModuloByZero,
+		i18n.ConstOverflow,
+		i18n.ConstInvalidOperation,
+		i18n.TypeArgumentCount,
+		i18n.TypeMismatch,
+		i18n.TypeUndefined,
+		i18n.TypeArity,
+		i18n.TypeUnreachableArm,
+		i18n.TypeUnusedImport,
+		i18n.TypeAbstract,
+		i18n.TypeOverride,
+		i18n.TypeContract,
+	}
+	for _, code := range codes {
+		text, ok := Lookup(string(code))
+		if !ok {
+			t.Fatalf("no explanation for %s", code)
+		}
+		if !strings.HasPrefix(text, "# "+string(code)+": ") || !strings.Contains(text, "## Fixes") {
+			t.Fatalf("explanation for %s does not follow the layout:\n%s", code, text)
+		}
+	}
+	if len(Codes()) != len(codes) {
+		t.Fatalf("expected %d explained codes, got %v", len(codes), Codes())
+	}
+	if got := Summary("LINT.Unused-Variable"); got != "variable declared but never used" {
+		t.Fatalf("unexpected summary %q", got)
+	}
+	if _, ok := Lookup("../explain.go"); ok {
+		t.Fatalf("expected lookups to stay inside the explanations")
+	}
+}
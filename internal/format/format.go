@@ -3,18 +3,24 @@ package format
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/cybellereaper/selenelang/internal/lexer"
+	"github.com/cybellereaper/selenelang/internal/parser"
+	"github.com/cybellereaper/selenelang/internal/printer"
 	"github.com/cybellereaper/selenelang/internal/token"
 )
 
 var noSpaceAfter = map[token.Type]bool{
-	token.LPAREN:   true,
-	token.LBRACKET: true,
-	token.DOT:      true,
-	token.SAFE_DOT: true,
-	token.LBRACE:   true,
+	token.LPAREN:     true,
+	token.LBRACKET:   true,
+	token.SAFE_INDEX: true,
+	token.DOT:        true,
+	token.SAFE_DOT:   true,
+	token.LBRACE:     true,
+	token.DOTDOT:     true,
+	token.DOTDOT_EQ:  true,
 }
 
 var noSpaceBefore = map[token.Type]bool{
@@ -27,6 +33,11 @@ var noSpaceBefore = map[token.Type]bool{
 	token.SAFE_DOT:  true,
 	token.COLON:     true,
 	token.NON_NULL:  true,
+	token.QUESTION:  true,
+	token.INCREMENT: true,
+	token.DECREMENT: true,
+	token.DOTDOT:    true,
+	token.DOTDOT_EQ: true,
 }
 
 var surroundWithSpaces = map[token.Type]bool{
@@ -55,8 +66,24 @@ var surroundWithSpaces = map[token.Type]bool{
 	token.ELVIS:          true,
 }
 
-// Source formats Selene source code into a canonical layout.
+// Source formats Selene source code into a canonical layout. Source that
+// parses is printed from its syntax tree with printer.Print; source with syntax
+// errors, such as a file mid-edit, is laid out token by token instead.
+// Either way comments keep their place: a comment that ends a line stays at
+// the end of that line, one on its own line stays on its own line, and one
+// between tokens on a line stays between them.
 func Source(src string) (string, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		return printer.Print(program), nil
+	}
+	return tokenLayout(src)
+}
+
+// tokenLayout formats src without parsing it, adjusting only the
+// whitespace between tokens.
+func tokenLayout(src string) (string, error) {
 	lex := lexer.New(src)
 	tokens := make([]token.Token, 0, len(src)/4)
 	for {
@@ -69,67 +96,186 @@ func Source(src string) (string, error) {
 			break
 		}
 	}
-	var b strings.Builder
-	indent := 0
-	newLine := true
+	f := &formatter{source: src, tokens: tokens, comments: lex.Comments(), newLine: true}
+	return f.format(), nil
+}
+
+type formatter struct {
+	source   string
+	tokens   []token.Token
+	comments []token.Comment
+	// nextComment indexes the first comment not yet written.
+	nextComment int
+	lineStarts  []int
+	b           strings.Builder
+	indent      int
+	newLine     bool
+	// lastOffset is the source offset just past the last token or comment
+	// written.
+	lastOffset int
+	// hug suppresses the space before the next token because an inline
+	// comment already wrote one.
+	hug  bool
+	prev token.Type
+}
+
+func (f *formatter) format() string {
 	var prev token.Token
-	for i := 0; i < len(tokens); i++ {
-		tok := tokens[i]
+	for i := 0; i < len(f.tokens); i++ {
+		tok := f.tokens[i]
 		if tok.Type == token.EOF {
 			break
 		}
+		f.leadingComments(tok)
 		if tok.Type == token.RBRACE {
-			if indent > 0 {
-				indent--
+			if f.indent > 0 {
+				f.indent--
 			}
-			if !newLine {
-				b.WriteByte('\n')
+			if !f.newLine {
+				f.b.WriteByte('\n')
 			}
-			writeIndent(&b, indent)
-			newLine = false
-		} else if newLine {
-			writeIndent(&b, indent)
-			newLine = false
-		} else if needsSpace(prev.Type, tok.Type) {
-			b.WriteByte(' ')
+			writeIndent(&f.b, f.indent)
+			f.newLine = false
+		} else if f.newLine {
+			writeIndent(&f.b, f.indent)
+			f.newLine = false
+		} else if !f.hug && needsSpace(prev.Type, tok.Type) && !isGeneratorMarker(f.tokens, i) {
+			f.b.WriteByte(' ')
 		}
+		f.hug = false
 
-		b.WriteString(tok.Literal)
+		f.b.WriteString(f.text(tok))
+		f.lastOffset = tok.End.Offset
+		f.prev = tok.Type
+		if isGeneratorMarker(f.tokens, i) {
+			prev = tok
+			continue
+		}
 
-		switch tok.Type {
-		case token.LBRACE:
-			b.WriteByte('\n')
-			indent++
-			newLine = true
-		case token.RBRACE:
-			if next := nextToken(tokens, i); next != nil && next.Type == token.ELSE {
-				b.WriteByte(' ')
-				newLine = false
-			} else {
-				b.WriteByte('\n')
-				newLine = true
-			}
-		case token.SEMICOLON:
-			b.WriteByte('\n')
-			newLine = true
-		case token.COMMA:
-			b.WriteByte(' ')
-			newLine = false
-		case token.COLON:
-			b.WriteByte(' ')
-			newLine = false
-		case token.ARROW, token.ELVIS, token.ASSIGN, token.PLUS_ASSIGN, token.MINUS_ASSIGN, token.STAR_ASSIGN, token.SLASH_ASSIGN, token.PERCENT_ASSIGN,
-			token.PLUS, token.MINUS, token.ASTERISK, token.SLASH, token.PERCENT,
-			token.EQ, token.NOT_EQ, token.LT, token.LTE, token.GT, token.GTE, token.OR, token.AND, token.IS, token.NOT_IS:
-			b.WriteByte(' ')
-			newLine = false
-		default:
-			newLine = false
+		after, breaks := spacingAfter(f.tokens, i)
+		if f.trailingComments(f.tokens[i+1]) {
+			after, breaks = "\n", true
 		}
+		if tok.Type == token.LBRACE {
+			f.indent++
+		}
+		f.b.WriteString(after)
+		f.newLine = breaks
 		prev = tok
 	}
-	out := strings.TrimRight(b.String(), "\n") + "\n"
-	return out, nil
+	if tok := f.tokens[len(f.tokens)-1]; tok.Type == token.EOF {
+		f.leadingComments(tok)
+	}
+	return strings.TrimRight(f.b.String(), "\n") + "\n"
+}
+
+// spacingAfter returns what follows the token at i and whether it ends the
+// line.
+func spacingAfter(tokens []token.Token, i int) (string, bool) {
+	switch tokens[i].Type {
+	case token.LBRACE, token.SEMICOLON:
+		return "\n", true
+	case token.RBRACE:
+		if next := nextToken(tokens, i); next != nil && (next.Type == token.ELSE || next.Type == token.WHILE && closesDo(tokens, i)) {
+			return " ", false
+		}
+		return "\n", true
+	case token.COMMA, token.COLON:
+		return " ", false
+	case token.ARROW, token.ELVIS, token.ASSIGN, token.PLUS_ASSIGN, token.MINUS_ASSIGN, token.STAR_ASSIGN, token.SLASH_ASSIGN, token.PERCENT_ASSIGN,
+		token.PLUS, token.MINUS, token.ASTERISK, token.SLASH, token.PERCENT,
+		token.EQ, token.NOT_EQ, token.LT, token.LTE, token.GT, token.GTE, token.OR, token.AND, token.IS, token.NOT_IS:
+		return " ", false
+	}
+	return "", false
+}
+
+// text returns the token as written. Literals come from the source so
+// strings keep their quotes and escapes.
+func (f *formatter) text(tok token.Token) string {
+	if isLiteral(tok.Type) && tok.End.Offset > tok.Pos.Offset && tok.End.Offset <= len(f.source) {
+		return f.source[tok.Pos.Offset:tok.End.Offset]
+	}
+	return tok.Literal
+}
+
+// trailingComments writes the comments that end the line of the token just
+// written and reports whether it wrote any, in which case the line must end.
+func (f *formatter) trailingComments(next token.Token) bool {
+	wrote := false
+	line := f.lineAt(f.lastOffset - 1)
+	for f.nextComment < len(f.comments) {
+		c := f.comments[f.nextComment]
+		if c.Pos.Offset >= next.Pos.Offset || f.lineAt(c.Pos.Offset) != line {
+			break
+		}
+		if next.Type != token.EOF && f.lineAt(c.End.Offset-1) == f.lineAt(next.Pos.Offset) {
+			// The next token shares the comment's line, so the comment
+			// sits between tokens and is written before the next one.
+			break
+		}
+		f.b.WriteByte(' ')
+		f.b.WriteString(c.Text)
+		f.lastOffset = c.End.Offset
+		f.nextComment++
+		wrote = true
+	}
+	return wrote
+}
+
+// leadingComments writes the comments that come before tok.
+func (f *formatter) leadingComments(tok token.Token) {
+	for f.nextComment < len(f.comments) {
+		c := f.comments[f.nextComment]
+		if c.Pos.Offset >= tok.Pos.Offset {
+			return
+		}
+		f.nextComment++
+		gap := f.newlinesBetween(f.lastOffset, c.Pos.Offset)
+		if f.b.Len() == 0 || gap > 0 || f.newLine {
+			if !f.newLine {
+				f.b.WriteByte('\n')
+			}
+			if gap > 1 && f.b.Len() > 0 {
+				f.b.WriteByte('\n')
+			}
+			writeIndent(&f.b, f.indent)
+		} else if !noSpaceAfter[f.prev] {
+			f.b.WriteByte(' ')
+		}
+		f.b.WriteString(c.Text)
+		f.lastOffset = c.End.Offset
+		if strings.HasPrefix(c.Text, "//") || f.newlinesBetween(c.End.Offset, tok.Pos.Offset) > 0 {
+			f.b.WriteByte('\n')
+			f.newLine = true
+			continue
+		}
+		if !noSpaceBefore[tok.Type] {
+			f.b.WriteByte(' ')
+		}
+		f.newLine = false
+		f.hug = true
+	}
+}
+
+func (f *formatter) newlinesBetween(start, end int) int {
+	if start < 0 || end <= start {
+		return 0
+	}
+	return f.lineAt(end) - f.lineAt(start)
+}
+
+// lineAt returns the zero-based line of the byte at offset.
+func (f *formatter) lineAt(offset int) int {
+	if f.lineStarts == nil {
+		f.lineStarts = []int{0}
+		for i, r := range f.source {
+			if r == '\n' {
+				f.lineStarts = append(f.lineStarts, i+1)
+			}
+		}
+	}
+	return sort.Search(len(f.lineStarts), func(i int) bool { return f.lineStarts[i] > offset }) - 1
 }
 
 func needsSpace(prev, curr token.Type) bool {
@@ -178,9 +324,9 @@ func isKeyword(t token.Type) bool {
 	switch t {
 	case token.LET, token.VAR, token.FN, token.ASYNC, token.CONTRACT, token.RETURNS, token.CLASS,
 		token.STRUCT, token.ENUM, token.MATCH, token.MODULE, token.IMPORT, token.AS, token.PACKAGE,
-		token.INTERFACE, token.IF, token.ELSE, token.WHILE, token.FOR, token.RETURN, token.BREAK,
+		token.INTERFACE, token.IF, token.ELSE, token.DO, token.WHILE, token.FOR, token.RETURN, token.BREAK,
 		token.CONTINUE, token.AWAIT, token.TRY, token.CATCH, token.FINALLY, token.THROW, token.USING,
-		token.EXT, token.CONDITION, token.WHEN:
+		token.EXT, token.CONDITION, token.WHEN, token.YIELD, token.IN:
 		return true
 	}
 	return false
@@ -194,12 +340,35 @@ func isLiteral(t token.Type) bool {
 	return false
 }
 
+// isGeneratorMarker reports whether the token at i is the `*` in `fn name*()`,
+// which hugs the function name rather than acting as a binary operator.
+func isGeneratorMarker(tokens []token.Token, i int) bool {
+	return i >= 2 && tokens[i].Type == token.ASTERISK &&
+		tokens[i-1].Type == token.IDENT && tokens[i-2].Type == token.FN
+}
+
 func writeIndent(b *strings.Builder, indent int) {
 	for i := 0; i < indent; i++ {
 		b.WriteString("    ")
 	}
 }
 
+// closesDo reports whether the brace at index closes the body of a do loop.
+func closesDo(tokens []token.Token, index int) bool {
+	depth := 0
+	for i := index; i >= 0; i-- {
+		switch tokens[i].Type {
+		case token.RBRACE:
+			depth++
+		case token.LBRACE:
+			if depth--; depth == 0 {
+				return i > 0 && tokens[i-1].Type == token.DO
+			}
+		}
+	}
+	return false
+}
+
 func nextToken(tokens []token.Token, index int) *token.Token {
 	if index+1 >= len(tokens) {
 		return nil
@@ -1,6 +1,9 @@
 package format
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestSourceFormatsConsistently(t *testing.T) {
 	input := "fn main(){let value=1+2*3;if value>0 {return value!!;} else {return 0;}}"
@@ -21,3 +24,85 @@ func TestSourceFormatsConsistently(t *testing.T) {
 		t.Fatalf("unexpected formatted output:\n--- got ---\n%q\n--- want ---\n%q", formatted, expected)
 	}
 }
+
+func TestSourceKeepsGeneratorMarkerAttached(t *testing.T) {
+	formatted, err := Source("fn count*(n){for(x in items){yield x*n;}}")
+	if err != nil {
+		t.Fatalf("Source returned error: %v", err)
+	}
+	const expected = `fn count*(n) {
+    for(x in items) {
+        yield x * n;
+    }
+}
+`
+	if formatted != expected {
+		t.Fatalf("unexpected formatted output:\n--- got ---\n%q\n--- want ---\n%q", formatted, expected)
+	}
+}
+
+func TestSourcePreservesComments(t *testing.T) {
+	input := "// header\nfn main(){ // opens\nlet s=\"a b\"; // trailing\n\n/* own line */\nprint(/* inline */ s);\n// before close\n}\n// end\n"
+	formatted, err := Source(input)
+	if err != nil {
+		t.Fatalf("Source returned error: %v", err)
+	}
+	const expected = `// header
+fn main() { // opens
+    let s = "a b"; // trailing
+
+    /* own line */
+    print(/* inline */ s);
+    // before close
+}
+// end
+`
+	if formatted != expected {
+		t.Fatalf("unexpected formatted output:\n--- got ---\n%q\n--- want ---\n%q", formatted, expected)
+	}
+	again, err := Source(formatted)
+	if err != nil || again != formatted {
+		t.Fatalf("formatting is not idempotent:\n%q", again)
+	}
+}
+
+func TestSourceKeepsShebangLine(t *testing.T) {
+	formatted, err := Source("#!/usr/bin/env -S selene run\nprint( args );\n")
+	if err != nil {
+		t.Fatalf("Source returned error: %v", err)
+	}
+	const expected = "#!/usr/bin/env -S selene run\nprint(args);\n"
+	if formatted != expected {
+		t.Fatalf("unexpected formatted output:\n--- got ---\n%q\n--- want ---\n%q", formatted, expected)
+	}
+}
+
+func TestRangeFormatsOnlyTheEnclosingItem(t *testing.T) {
+	src := "let   a=1;\n\n\n// adds\nfn add(x:Number,y:Number):Number{return x+y;} // sum\nlet   b=2;\n"
+	start := strings.Index(src, "return")
+	edit, err := Range(src, start, start)
+	if err != nil {
+		t.Fatalf("Range returned error: %v", err)
+	}
+	got := src[:edit.Start] + edit.Text + src[edit.End:]
+	const expected = "let   a=1;\n\n\n// adds\nfn add(x: Number, y: Number): Number {\n    return x + y;\n} // sum\nlet   b=2;\n"
+	if got != expected {
+		t.Fatalf("unexpected output:\n--- got ---\n%q\n--- want ---\n%q", got, expected)
+	}
+	if edit, err := Range(src, 11, 11); err != nil || edit.Start != 11 || edit.End != 11 || edit.Text != "" {
+		t.Fatalf("expected an empty edit between items, got %+v (%v)", edit, err)
+	}
+}
+
+func TestRangeLaysOutItemsThatDoNotParse(t *testing.T) {
+	src := "fn ok(){return 1;}\nfn broken(){let x=;}\n"
+	edit, err := Range(src, len(src)-3, len(src)-3)
+	if err != nil {
+		t.Fatalf("Range returned error: %v", err)
+	}
+	got := src[:edit.Start] + edit.Text + src[edit.End:]
+	const expected = "fn ok(){return 1;}\nfn broken() {\n    let x = ;\n}\n"
+	if got != expected {
+		t.Fatalf("unexpected output:\n--- got ---\n%q\n--- want ---\n%q", got, expected)
+	}
+}
@@ -0,0 +1,103 @@
+package format
+
+import (
+	"strings"
+
+	"github.com/cybellereaper/selenelang/internal/lexer"
+	"github.com/cybellereaper/selenelang/internal/parser"
+)
+
+// Edit replaces the bytes of a source file from Start up to End with Text.
+type Edit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// Range formats only the top-level items of src that overlap the byte range
+// from start to end, such as the declaration an editor just changed, and
+// returns the edit that splices them back in. Each item takes its leading
+// comments and the comment ending its last line with it. The text covering
+// those items is re-parsed on its own and formatted like Source, so the rest
+// of the file, including the blank lines between items, stays byte for byte
+// as it was. A range that touches no item yields an empty edit at start.
+func Range(src string, start, end int) (Edit, error) {
+	start = max(0, min(start, len(src)))
+	end = max(start, min(end, len(src)))
+	spans := itemSpans(src)
+	first, last := -1, -1
+	for i, span := range spans {
+		if span.start <= end && start <= span.end {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return Edit{Start: start, End: start}, nil
+	}
+	edit := Edit{Start: lineStart(src, spans[first].start), End: spans[last].end}
+	formatted, err := Source(src[edit.Start:edit.End])
+	if err != nil {
+		return Edit{}, err
+	}
+	edit.Text = strings.TrimSuffix(formatted, "\n")
+	return edit, nil
+}
+
+type span struct {
+	start, end int
+}
+
+// itemSpans returns the byte ranges of the top-level items of src, widened
+// over the comments that belong to each. Items the parser recovered from
+// errors are included as it delimited them; Source lays such text out token
+// by token.
+func itemSpans(src string) []span {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	spans := make([]span, len(program.Items))
+	comments := program.Comments
+	next := 0
+	prevEnd, prevLine := 0, 0
+	for i, item := range program.Items {
+		pos, finish := item.Pos(), item.End()
+		s := span{start: pos.Offset, end: finish.Offset}
+		for ; next < len(comments) && comments[next].Pos.Offset < s.start; next++ {
+			c := comments[next]
+			if c.Pos.Offset < prevEnd {
+				continue
+			}
+			if i > 0 && c.Pos.Line == prevLine {
+				// A comment ending the previous item's last line stays with it.
+				spans[i-1].end = c.Pos.Offset + len(c.Text)
+				continue
+			}
+			s.start = min(s.start, c.Pos.Offset)
+		}
+		spans[i] = s
+		prevEnd, prevLine = s.end, finish.Line
+	}
+	if n := len(spans); n > 0 {
+		for ; next < len(comments); next++ {
+			if c := comments[next]; c.Pos.Line == prevLine && c.Pos.Offset >= prevEnd {
+				spans[n-1].end = c.Pos.Offset + len(c.Text)
+			}
+		}
+	}
+	return spans
+}
+
+// lineStart moves offset back to the start of its line when only blanks
+// precede it there, so an indented item is re-indented too.
+func lineStart(src string, offset int) int {
+	i := offset
+	for i > 0 && (src[i-1] == ' ' || src[i-1] == '\t') {
+		i--
+	}
+	if i == 0 || src[i-1] == '\n' {
+		return i
+	}
+	return offset
+}
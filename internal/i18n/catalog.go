@@ -0,0 +1,93 @@
+package i18n
+
+var english = map[MessageID]string{
+	LintTrailingWhitespace:  "trailing whitespace",
+	LintLongLine:            "line exceeds %d characters (%d)",
+	LintMissingFinalNewline: "file does not end with a newline",
+	LintTodoComment:         "TODO comment",
+	LintUnusedVariable:      "variable %q declared but never used",
+	LintEmptyFunction:       "function %q has no implementation",
+	LintMissingPackage:      "project file has no package declaration",
+	LexIllegalToken:         "illegal token %q",
+	ConstDivisionByZero:     "constant expression divides by zero",
+	ConstModuloByZero:       "constant expression takes a modulo by zero",
+	ConstOverflow:           "constant expression overflows to infinity",
+	ConstInvalidOperation:   "constant expression always fails: %s",
+	TypeArgumentCount:       "wrong number of type arguments for %s: want %d, got %d",
+	TypeMismatch:            "generic type mismatch: %s",
+	TypeUndefined:           "undefined identifier %s",
+	TypeArity:               "wrong number of arguments to %s: want %d, got %d",
+	TypeUnreachableArm:      "unreachable match arm: %s",
+	TypeUnusedImport:        "%s is imported but never used",
+	TypeAbstract:            "abstract class misuse: %s",
+	TypeOverride:            "invalid override: %s",
+	TypeContract:            "contract not fulfilled: %s",
+
+	CLIUsage:          "usage: selene [--json] <command> [options]",
+	CLICommands:       "commands:",
+	CLIHelpRun:        "execute a Selene source file",
+	CLIHelpTest:       "execute all example scripts and report pass/fail status",
+	CLIHelpExamples:   "list examples with their tags, or run a tagged subset",
+	CLIHelpTokens:     "dump the token stream for a file",
+	CLIHelpAST:        "dump the syntax tree for a file",
+	CLIHelpInit:       "create a new Selene project",
+	CLIHelpDeps:       "manage project dependencies (add, list, graph, verify, vendor, update, outdated)",
+	CLIHelpLSP:        "start the Selene language server on stdio",
+	CLIHelpFmt:        "format Selene source files",
+	CLIHelpCheck:      "report diagnostics for every source file in the project",
+	CLIHelpVet:        "lint and type check the project, reporting findings as text, JSON, or SARIF",
+	CLIHelpExplain:    "describe a diagnostic code, with examples and common fixes",
+	CLIHelpBuild:      "compile Selene bytecode, emit listings, or build Windows executables",
+	CLIHelpTranspile:  "convert Selene sources to another language",
+	CLIHelpCacheClean: "remove cached bytecode and indexes under .selene-cache",
+	CLIHelpSelfUpdate: "replace selene with the latest verified release",
+	CLIHelpPerf:       "summarize the local performance records SELENE_PERF=1 keeps",
+	CLIPluginCommands: "plugin commands:",
+	CLIHelpExternal:   "run %s from PATH",
+}
+
+var spanish = map[MessageID]string{
+	LintTrailingWhitespace:  "espacios en blanco al final de la línea",
+	LintLongLine:            "la línea supera los %d caracteres (%d)",
+	LintMissingFinalNewline: "el archivo no termina con un salto de línea",
+	LintTodoComment:         "comentario TODO",
+	LintUnusedVariable:      "la variable %q se declara pero nunca se usa",
+	LintEmptyFunction:       "la función %q no tiene implementación",
+	LintMissingPackage:      "el archivo del proyecto no declara un paquete",
+	LexIllegalToken:         "token no válido %q",
+	ConstDivisionByZero:     "la expresión constante divide entre cero",
+	ConstModuloByZero:       "la expresión constante calcula un módulo entre cero",
+	ConstOverflow:           "la expresión constante desborda hasta el infinito",
+	ConstInvalidOperation:   "la expresión constante siempre falla: %s",
+	TypeArgumentCount:       "número incorrecto de argumentos de tipo para %s: se esperaban %d, hay %d",
+	TypeMismatch:            "tipos genéricos incompatibles: %s",
+	TypeUndefined:           "identificador no definido %s",
+	TypeArity:               "número incorrecto de argumentos para %s: se esperaban %d, hay %d",
+	TypeUnreachableArm:      "brazo de match inalcanzable: %s",
+	TypeUnusedImport:        "%s se importa pero nunca se usa",
+	TypeAbstract:            "uso incorrecto de clase abstracta: %s",
+	TypeOverride:            "sobrescritura no válida: %s",
+	TypeContract:            "contrato no cumplido: %s",
+
+	CLIUsage:          "uso: selene [--json] <comando> [opciones]",
+	CLICommands:       "comandos:",
+	CLIHelpRun:        "ejecuta un archivo fuente de Selene",
+	CLIHelpTest:       "ejecuta todos los scripts de ejemplo e informa si pasan o fallan",
+	CLIHelpExamples:   "lista los ejemplos con sus etiquetas o ejecuta un subconjunto etiquetado",
+	CLIHelpTokens:     "muestra el flujo de tokens de un archivo",
+	CLIHelpAST:        "muestra el árbol sintáctico de un archivo",
+	CLIHelpInit:       "crea un nuevo proyecto de Selene",
+	CLIHelpDeps:       "gestiona las dependencias del proyecto (add, list, graph, verify, vendor, update, outdated)",
+	CLIHelpLSP:        "inicia el servidor de lenguaje de Selene por stdio",
+	CLIHelpFmt:        "da formato a archivos fuente de Selene",
+	CLIHelpCheck:      "informa los diagnósticos de cada archivo fuente del proyecto",
+	CLIHelpVet:        "analiza y comprueba los tipos del proyecto, con los hallazgos en texto, JSON o SARIF",
+	CLIHelpExplain:    "describe un código de diagnóstico, con ejemplos y soluciones habituales",
+	CLIHelpBuild:      "compila bytecode de Selene, genera listados o crea ejecutables de Windows",
+	CLIHelpTranspile:  "convierte fuentes de Selene a otro lenguaje",
+	CLIHelpCacheClean: "elimina el bytecode y los índices en caché de .selene-cache",
+	CLIHelpSelfUpdate: "reemplaza selene por la última versión verificada",
+	CLIHelpPerf:       "resume los registros de rendimiento locales que guarda SELENE_PERF=1",
+	CLIPluginCommands: "comandos de complementos:",
+	CLIHelpExternal:   "ejecuta %s desde PATH",
+}
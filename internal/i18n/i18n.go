@@ -0,0 +1,137 @@
+// Package i18n holds the message catalog for CLI and diagnostic text. Code
+// refers to messages by MessageID and formats them with a Printer, so adding
+// a language means adding a catalog rather than touching the call sites.
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// MessageID names one translatable message. IDs are stable: diagnostics
+// report them as their code, so editors and scripts can match on them
+// whatever the language.
+type MessageID string
+
+// Diagnostic messages reported by the language server.
+const (
+	LintTrailingWhitespace  MessageID = "lint.trailing-whitespace"
+	LintLongLine            MessageID = "lint.long-line"
+	LintMissingFinalNewline MessageID = "lint.missing-final-newline"
+	LintTodoComment         MessageID = "lint.todo-comment"
+	LintUnusedVariable      MessageID = "lint.unused-variable"
+	LintEmptyFunction       MessageID = "lint.empty-function"
+	LintMissingPackage      MessageID = "lint.missing-package"
+	LexIllegalToken         MessageID = "lex.illegal-token"
+	ConstDivisionByZero     MessageID = "const.division-by-zero"
+	ConstModuloByZero       MessageID = "const.modulo-by-zero"
+	ConstOverflow           MessageID = "const.overflow"
+	ConstInvalidOperation   MessageID = "const.invalid-operation"
+	TypeArgumentCount       MessageID = "type.argument-count"
+	TypeMismatch            MessageID = "type.mismatch"
+	TypeUndefined           MessageID = "type.undefined"
+	TypeArity               MessageID = "type.arity"
+	TypeUnreachableArm      MessageID = "type.unreachable-arm"
+	TypeUnusedImport        MessageID = "type.unused-import"
+	TypeAbstract            MessageID = "type.abstract"
+	TypeOverride            MessageID = "type.override"
+	TypeContract            MessageID = "type.contract"
+)
+
+// CLI usage text.
+const (
+	CLIUsage          MessageID = "cli.usage"
+	CLICommands       MessageID = "cli.commands"
+	CLIHelpRun        MessageID = "cli.help.run"
+	CLIHelpTest       MessageID = "cli.help.test"
+	CLIHelpExamples   MessageID = "cli.help.examples"
+	CLIHelpTokens     MessageID = "cli.help.tokens"
+	CLIHelpAST        MessageID = "cli.help.ast"
+	CLIHelpInit       MessageID = "cli.help.init"
+	CLIHelpDeps       MessageID = "cli.help.deps"
+	CLIHelpLSP        MessageID = "cli.help.lsp"
+	CLIHelpFmt        MessageID = "cli.help.fmt"
+	CLIHelpCheck      MessageID = "cli.help.check"
+	CLIHelpVet        MessageID = "cli.help.vet"
+	CLIHelpExplain    MessageID = "cli.help.explain"
+	CLIHelpBuild      MessageID = "cli.help.build"
+	CLIHelpTranspile  MessageID = "cli.help.transpile"
+	CLIHelpCacheClean MessageID = "cli.help.cache-clean"
+	CLIHelpSelfUpdate MessageID = "cli.help.self-update"
+	CLIHelpPerf       MessageID = "cli.help.perf"
+	CLIPluginCommands MessageID = "cli.plugin-commands"
+	CLIHelpExternal   MessageID = "cli.help.external"
+)
+
+// DefaultLanguage is the language every message is written in first. Other
+// catalogs fall back to it for messages they do not translate yet.
+const DefaultLanguage = "en"
+
+var catalogs = map[string]map[MessageID]string{
+	"en": english,
+	"es": spanish,
+}
+
+// Languages lists the languages with a catalog.
+func Languages() []string {
+	return []string{"en", "es"}
+}
+
+// Printer formats messages in one language.
+type Printer struct {
+	lang string
+}
+
+// NewPrinter returns a printer for lang, which may be a bare language such as
+// "es" or a POSIX locale such as "es_MX.UTF-8". Unknown languages print in
+// DefaultLanguage.
+func NewPrinter(lang string) *Printer {
+	return &Printer{lang: matchLanguage(lang)}
+}
+
+// Language reports the catalog the printer uses.
+func (p *Printer) Language() string {
+	return p.lang
+}
+
+// Sprintf formats the message id with args, like fmt.Sprintf with the
+// catalog entry as the format.
+func (p *Printer) Sprintf(id MessageID, args ...any) string {
+	format, ok := catalogs[p.lang][id]
+	if !ok {
+		format, ok = english[id]
+	}
+	if !ok {
+		// A missing entry is a bug, but the ID is still more useful than
+		// an empty message.
+		format = string(id)
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+var defaultPrinter = sync.OnceValue(func() *Printer {
+	return NewPrinter(os.Getenv("SELENE_LANG"))
+})
+
+// Default returns the printer for the language named by SELENE_LANG.
+func Default() *Printer {
+	return defaultPrinter()
+}
+
+// Sprintf formats id with the Default printer.
+func Sprintf(id MessageID, args ...any) string {
+	return Default().Sprintf(id, args...)
+}
+
+func matchLanguage(lang string) string {
+	lang = strings.ToLower(lang)
+	if i := strings.IndexAny(lang, "_-.@"); i >= 0 {
+		lang = lang[:i]
+	}
+	if _, ok := catalogs[lang]; ok {
+		return lang
+	}
+	return DefaultLanguage
+}
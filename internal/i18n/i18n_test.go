@@ -0,0 +1,46 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCatalogsTranslateEveryMessage(t *testing.T) {
+	for _, lang := range Languages() {
+		catalog, ok := catalogs[lang]
+		if !ok {
+			t.Fatalf("language %q has no catalog", lang)
+		}
+		for id, format := range english {
+			translated, ok := catalog[id]
+			if !ok {
+				t.Fatalf("%s catalog is missing %s", lang, id)
+			}
+			if strings.Count(translated, "%") != strings.Count(format, "%") {
+				t.Fatalf("%s catalog entry for %s takes different arguments: %q vs %q", lang, id, translated, format)
+			}
+		}
+	}
+}
+
+func TestPrinterMatchesLocales(t *testing.T) {
+	tests := map[string]string{
+		"":            "en",
+		"es":          "es",
+		"es_MX.UTF-8": "es",
+		"ES-es":       "es",
+		"fr_FR":       "en",
+		"C":           "en",
+	}
+	for locale, want := range tests {
+		if got := NewPrinter(locale).Language(); got != want {
+			t.Fatalf("NewPrinter(%q).Language() = %q, want %q", locale, got, want)
+		}
+	}
+	if got := NewPrinter("es").Sprintf(LintLongLine, 120, 130); got != "la línea supera los 120 caracteres (130)" {
+		t.Fatalf("unexpected Spanish message: %q", got)
+	}
+	if got := NewPrinter("es").Sprintf(MessageID("missing.id")); got != "missing.id" {
+		t.Fatalf("expected unknown IDs to print as themselves, got %q", got)
+	}
+}
@@ -61,6 +61,43 @@ func compileProgramItem(item ast.ProgramItem) compiledStep {
 	}
 }
 
+// CompileFunction compiles the body of a function declaration into a closure
+// per statement. It is the compiler behind tiered execution, which promotes
+// a function once the interpreter has called it often enough; see
+// runtime.SetTiering.
+func CompileFunction(decl *ast.FunctionDeclaration) (runtime.CompiledBody, error) {
+	if decl == nil {
+		return nil, fmt.Errorf("jit: function cannot be nil")
+	}
+	if decl.IsExprBody {
+		expr := decl.BodyExpr
+		if expr == nil {
+			return func(*runtime.Environment) (runtime.Value, error) { return runtime.NullValue, nil }, nil
+		}
+		return func(env *runtime.Environment) (runtime.Value, error) {
+			return runtime.EvaluateExpression(expr, env)
+		}, nil
+	}
+	if decl.Body == nil {
+		return nil, fmt.Errorf("jit: function has no body")
+	}
+	steps := make([]compiledStep, 0, len(decl.Body.Statements))
+	for _, stmt := range decl.Body.Statements {
+		steps = append(steps, compileProgramItem(stmt))
+	}
+	return func(env *runtime.Environment) (runtime.Value, error) {
+		var last runtime.Value = runtime.NullValue
+		for _, step := range steps {
+			val, err := step.run(env)
+			if err != nil {
+				return val, err
+			}
+			last = val
+		}
+		return last, nil
+	}, nil
+}
+
 // Run executes the compiled program within the supplied runtime instance.
 func (p *Program) Run(rt *runtime.Runtime) (runtime.Value, error) {
 	if p == nil {
@@ -72,6 +109,9 @@ func (p *Program) Run(rt *runtime.Runtime) (runtime.Value, error) {
 	env := rt.Environment()
 	var last runtime.Value = runtime.NullValue
 	for _, step := range p.steps {
+		if err := env.Err(); err != nil {
+			return nil, err
+		}
 		val, err := step.run(env)
 		if err != nil {
 			return nil, err
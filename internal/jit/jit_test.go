@@ -1,8 +1,10 @@
 package jit
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/cybellereaper/selenelang/internal/ast"
 	"github.com/cybellereaper/selenelang/internal/lexer"
 	"github.com/cybellereaper/selenelang/internal/parser"
 	"github.com/cybellereaper/selenelang/internal/runtime"
@@ -54,10 +56,13 @@ fn main() {
 
 	rt := runtime.New()
 	var calls int
-	rt.Environment().Set("record", runtime.NewBuiltin("record", func(args []runtime.Value) (runtime.Value, error) {
+	err := rt.Register(runtime.BuiltinSpec{Name: "record", Call: func(runtime.BuiltinCall) (runtime.Value, error) {
 		calls++
 		return runtime.NullValue, nil
-	}))
+	}})
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
 
 	compiled, err := Compile(program)
 	if err != nil {
@@ -70,3 +75,47 @@ fn main() {
 		t.Fatalf("expected record to be called once, got %d", calls)
 	}
 }
+
+func TestTieringPromotesHotFunctions(t *testing.T) {
+	source := `
+fn fib(n: Int): Int {
+    if n < 2 { return n; }
+    return fib(n - 1) + fib(n - 2);
+}
+fn once(): Int { return 1; }
+fn twice(x: Int): Int => x * 2;
+let total = 0;
+for (let i in [1, 2, 3, 4, 5]) { total = total + twice(i); }
+fib(12) + once() + total;
+`
+	l := lexer.New(source)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("unexpected parse errors: %v", errs)
+	}
+
+	rt := runtime.New()
+	var compiled []string
+	rt.SetTiering(5, func(decl *ast.FunctionDeclaration) (runtime.CompiledBody, error) {
+		compiled = append(compiled, decl.Name.Name)
+		return CompileFunction(decl)
+	})
+	value, err := rt.Run(program)
+	if err != nil {
+		t.Fatalf("execution failed: %v", err)
+	}
+	if value.Inspect() != "175" {
+		t.Fatalf("expected 175, got %s", value.Inspect())
+	}
+	if strings.Join(compiled, ",") != "twice,fib" {
+		t.Fatalf("expected twice and fib to be compiled once each, got %v", compiled)
+	}
+	stats := rt.TierStats()
+	if len(stats) != 3 || stats[0].Name != "fib" || stats[0].Calls != 465 || !stats[0].Promoted {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if stats[1].Name != "twice" || !stats[1].Promoted || stats[2].Name != "once" || stats[2].Promoted {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,138 @@
+package lexer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// EscapeError reports an invalid escape sequence in string text.
+type EscapeError struct {
+	// Offset is the byte offset of the backslash in the text.
+	Offset int
+	// Sequence is the escape as written, or as much of it as was found.
+	Sequence string
+	Reason   string
+}
+
+func (e *EscapeError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("invalid escape sequence %s", e.Sequence)
+	}
+	return fmt.Sprintf("invalid escape sequence %s: %s", e.Sequence, e.Reason)
+}
+
+// Unescape decodes the escape sequences in the text of a quoted string:
+// \n, \r, \t, and \0; \\, \", \', \`, and \$ for the characters themselves;
+// \xNN for an ASCII character; and \uNNNN or \u{N...} for any Unicode code
+// point other than a surrogate. Any other backslash is an *EscapeError.
+func Unescape(text string) (string, error) {
+	if !strings.Contains(text, `\`) {
+		return text, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' {
+			b.WriteByte(text[i])
+			continue
+		}
+		r, size, err := unescapeAt(text, i)
+		if err != nil {
+			return "", err
+		}
+		b.WriteRune(r)
+		i += size - 1
+	}
+	return b.String(), nil
+}
+
+// ValidateEscapes reports the first invalid escape sequence in the text of
+// a quoted string literal, or nil. Placeholders are skipped: the code in
+// ${...} is checked when it is parsed.
+func ValidateEscapes(text string) error {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch {
+		case depth > 0 && text[i] == '\\':
+			i++
+		case depth > 0 && text[i] == '{':
+			depth++
+		case depth > 0 && text[i] == '}':
+			depth--
+		case depth > 0:
+		case text[i] == '$' && i+1 < len(text) && text[i+1] == '{':
+			depth = 1
+			i++
+		case text[i] == '\\':
+			_, size, err := unescapeAt(text, i)
+			if err != nil {
+				return err
+			}
+			i += size - 1
+		}
+	}
+	return nil
+}
+
+// unescapeAt decodes the escape sequence starting with the backslash at
+// text[i], returning the character and the length of the sequence.
+func unescapeAt(text string, i int) (rune, int, error) {
+	if i+1 >= len(text) {
+		return 0, 0, escapeError(text, i, i+1, "unterminated escape sequence")
+	}
+	switch c := text[i+1]; c {
+	case 'n':
+		return '\n', 2, nil
+	case 'r':
+		return '\r', 2, nil
+	case 't':
+		return '\t', 2, nil
+	case '0':
+		return 0, 2, nil
+	case '\\', '"', '\'', '`', '$':
+		return rune(c), 2, nil
+	case 'x':
+		digits := text[i+2 : min(i+4, len(text))]
+		n, err := strconv.ParseUint(digits, 16, 8)
+		if len(digits) < 2 || err != nil {
+			return 0, 0, escapeError(text, i, i+4, "\\x takes two hex digits")
+		}
+		if n > 0x7f {
+			return 0, 0, escapeError(text, i, i+4, "\\x escapes stop at 7f; use \\u{...} beyond ASCII")
+		}
+		return rune(n), 4, nil
+	case 'u':
+		end := i + 6
+		digits := text[i+2 : min(end, len(text))]
+		reason := "\\u takes four hex digits, or one to six in \\u{...}"
+		braced := i+2 < len(text) && text[i+2] == '{'
+		if braced {
+			closing := strings.IndexByte(text[i+3:], '}')
+			if closing < 0 {
+				return 0, 0, escapeError(text, i, i+3, "missing } after \\u{")
+			}
+			end = i + 4 + closing
+			digits = text[i+3 : end-1]
+			reason = "\\u{...} takes one to six hex digits"
+		}
+		n, err := strconv.ParseUint(digits, 16, 32)
+		if err != nil || len(digits) == 0 || len(digits) > 6 || !braced && len(digits) != 4 {
+			return 0, 0, escapeError(text, i, end, reason)
+		}
+		switch r := rune(n); {
+		case n > utf8.MaxRune:
+			return 0, 0, escapeError(text, i, end, "code point out of range")
+		case 0xd800 <= r && r <= 0xdfff:
+			return 0, 0, escapeError(text, i, end, "surrogate halves are not characters")
+		}
+		return rune(n), end - i, nil
+	default:
+		_, size := utf8.DecodeRuneInString(text[i+1:])
+		return 0, 0, escapeError(text, i, i+1+size, "")
+	}
+}
+
+func escapeError(text string, start, end int, reason string) *EscapeError {
+	return &EscapeError{Offset: start, Sequence: text[start:min(end, len(text))], Reason: reason}
+}
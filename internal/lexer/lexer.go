@@ -2,31 +2,81 @@
 package lexer
 
 import (
+	"bufio"
+	"errors"
+	"io"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/cybellereaper/selenelang/internal/token"
 )
 
-// Lexer produces tokens from Selene source text.
+// Lexer produces tokens from Selene source text. Runes are decoded as the
+// lexer reaches them, so lexing never holds more than the current token and a
+// few runes of lookahead beyond the source itself. Token offsets count bytes
+// from the start of the source; columns count runes.
 type Lexer struct {
-	input        []rune
+	input  string
+	reader *bufio.Reader
+	err    error
+	// file is recorded in every position the lexer reports.
+	file string
+	// ahead holds runes decoded past ch for lookahead.
+	ahead []decoded
+	// position is the byte offset of ch and readPosition the offset just
+	// past it.
 	position     int
 	readPosition int
 	ch           rune
 	line         int
 	column       int
+	// capturing is set while the runes being consumed are collected into
+	// text, which becomes a token literal or comment.
+	capturing bool
+	text      strings.Builder
+	doc       []string
+	comments  []token.Comment
+}
+
+type decoded struct {
+	ch   rune
+	size int
 }
 
 // New creates a lexer for the provided source string.
 func New(input string) *Lexer {
 	l := &Lexer{
-		input: []rune(input),
+		input: input,
 		line:  1,
 	}
 	l.readRune()
 	return l
 }
 
+// NewReader creates a lexer that reads source from r as it is needed, for
+// input too large to hold in memory. A read error ends the input as if it
+// were the end of the file; check Err once lexing is done.
+func NewReader(r io.Reader) *Lexer {
+	l := &Lexer{
+		reader: bufio.NewReader(r),
+		line:   1,
+	}
+	l.readRune()
+	return l
+}
+
+// SetFile names the source file the lexer reads, so the positions of the
+// tokens and comments it produces carry the name.
+func (l *Lexer) SetFile(name string) {
+	l.file = name
+}
+
+// Err returns the first error encountered reading the input of a lexer made
+// by NewReader.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
 // NextToken returns the next token from the input.
 func (l *Lexer) NextToken() token.Token {
 	l.skipWhitespaceAndComments()
@@ -36,8 +86,10 @@ func (l *Lexer) NextToken() token.Token {
 	startColumn := l.column
 
 	tok := token.Token{
-		Pos: token.Position{Offset: startOffset, Line: startLine, Column: startColumn},
+		Pos: token.Position{File: l.file, Offset: startOffset, Line: startLine, Column: startColumn},
+		Doc: strings.Join(l.doc, "\n"),
 	}
+	l.doc = nil
 
 	switch l.ch {
 	case 0:
@@ -51,6 +103,11 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Literal = "+="
 			l.readRune()
 			l.readRune()
+		} else if l.peekRune() == '+' {
+			tok.Type = token.INCREMENT
+			tok.Literal = "++"
+			l.readRune()
+			l.readRune()
 		} else {
 			tok.Type = token.PLUS
 			tok.Literal = "+"
@@ -62,6 +119,11 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Literal = "-="
 			l.readRune()
 			l.readRune()
+		} else if l.peekRune() == '-' {
+			tok.Type = token.DECREMENT
+			tok.Literal = "--"
+			l.readRune()
+			l.readRune()
 		} else {
 			tok.Type = token.MINUS
 			tok.Literal = "-"
@@ -113,6 +175,21 @@ func (l *Lexer) NextToken() token.Token {
 		tok.Type = token.RBRACE
 		tok.Literal = "}"
 		l.readRune()
+	case '@':
+		tok.Type = token.AT
+		tok.Literal = "@"
+		l.readRune()
+	case '#':
+		if l.peekRune() == '{' {
+			tok.Type = token.HASH_BRACE
+			tok.Literal = "#{"
+			l.readRune()
+			l.readRune()
+		} else {
+			tok.Type = token.ILLEGAL
+			tok.Literal = string(l.ch)
+			l.readRune()
+		}
 	case '[':
 		tok.Type = token.LBRACKET
 		tok.Literal = "["
@@ -122,8 +199,25 @@ func (l *Lexer) NextToken() token.Token {
 		tok.Literal = "]"
 		l.readRune()
 	case '.':
-		tok.Type = token.DOT
-		tok.Literal = "."
+		switch {
+		case l.peekRune() == '.' && l.peekRuneN(2) == '.':
+			tok.Type = token.ELLIPSIS
+			tok.Literal = "..."
+			l.readRune()
+			l.readRune()
+		case l.peekRune() == '.' && l.peekRuneN(2) == '=':
+			tok.Type = token.DOTDOT_EQ
+			tok.Literal = "..="
+			l.readRune()
+			l.readRune()
+		case l.peekRune() == '.':
+			tok.Type = token.DOTDOT
+			tok.Literal = ".."
+			l.readRune()
+		default:
+			tok.Type = token.DOT
+			tok.Literal = "."
+		}
 		l.readRune()
 	case ':':
 		tok.Type = token.COLON
@@ -141,6 +235,11 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Literal = "?."
 			l.readRune()
 			l.readRune()
+		case '[':
+			tok.Type = token.SAFE_INDEX
+			tok.Literal = "?["
+			l.readRune()
+			l.readRune()
 		default:
 			tok.Type = token.QUESTION
 			tok.Literal = "?"
@@ -194,6 +293,8 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Literal = "<="
 			l.readRune()
 			l.readRune()
+		} else if l.peekRune() == '<' && l.peekRuneN(2) == '~' {
+			l.readHeredoc(&tok)
 		} else {
 			tok.Type = token.LT
 			tok.Literal = "<"
@@ -294,19 +395,22 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	}
 
-	tok.End = token.Position{Offset: l.position, Line: l.line, Column: l.column}
+	tok.End = token.Position{File: l.file, Offset: l.position, Line: l.line, Column: l.column}
 	return tok
 }
 
 func (l *Lexer) readRune() {
-	if l.readPosition >= len(l.input) {
-		l.position = len(l.input)
+	if l.capturing && l.readPosition > l.position {
+		l.text.WriteRune(l.ch)
+	}
+	l.position = l.readPosition
+	next, ok := l.decode()
+	if !ok {
 		l.ch = 0
 		return
 	}
-	l.position = l.readPosition
-	l.ch = l.input[l.readPosition]
-	l.readPosition++
+	l.ch = next.ch
+	l.readPosition += next.size
 	if l.ch == '\n' {
 		l.line++
 		l.column = 0
@@ -315,88 +419,119 @@ func (l *Lexer) readRune() {
 	}
 }
 
-func (l *Lexer) peekRune() rune {
-	if l.readPosition >= len(l.input) {
-		return 0
+// decode returns the rune after ch, taking it from the lookahead buffer when
+// it has already been decoded.
+func (l *Lexer) decode() (decoded, bool) {
+	if len(l.ahead) > 0 {
+		next := l.ahead[0]
+		l.ahead = l.ahead[1:]
+		return next, true
+	}
+	return l.decodeAt(l.readPosition)
+}
+
+// decodeAt decodes the rune at byte offset from the source. For a reader,
+// offset must be just past everything decoded so far.
+func (l *Lexer) decodeAt(offset int) (decoded, bool) {
+	if l.reader == nil {
+		if offset >= len(l.input) {
+			return decoded{}, false
+		}
+		ch, size := utf8.DecodeRuneInString(l.input[offset:])
+		return decoded{ch, size}, true
+	}
+	if l.err != nil {
+		return decoded{}, false
+	}
+	ch, size, err := l.reader.ReadRune()
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			l.err = err
+		}
+		return decoded{}, false
 	}
-	return l.input[l.readPosition]
+	return decoded{ch, size}, true
 }
 
+func (l *Lexer) peekRune() rune {
+	return l.peekRuneN(1)
+}
+
+// peekRuneN returns the nth rune after ch, or 0 past the end of the input.
 func (l *Lexer) peekRuneN(n int) rune {
-	index := l.readPosition + n - 1
-	if index < 0 || index >= len(l.input) {
-		return 0
+	for len(l.ahead) < n {
+		offset := l.readPosition
+		for _, d := range l.ahead {
+			offset += d.size
+		}
+		next, ok := l.decodeAt(offset)
+		if !ok {
+			return 0
+		}
+		l.ahead = append(l.ahead, next)
 	}
-	return l.input[index]
+	return l.ahead[n-1].ch
 }
 
 func (l *Lexer) peekWord(word string) bool {
 	if len(word) == 0 {
 		return false
 	}
-	if l.peekRune() != rune(word[0]) {
-		return false
-	}
-	if l.readPosition+len(word) > len(l.input) {
-		return false
-	}
-	for i, r := range word {
-		if l.readPosition+i >= len(l.input) {
-			return false
-		}
-		if l.input[l.readPosition+i] != r {
-			return false
-		}
-	}
-	nextIndex := l.readPosition + len(word)
-	if nextIndex < len(l.input) {
-		next := l.input[nextIndex]
-		if isLetter(next) || isDigit(next) || next == '_' {
+	n := 0
+	for _, r := range word {
+		n++
+		if l.peekRuneN(n) != r {
 			return false
 		}
 	}
-	return true
+	next := l.peekRuneN(n + 1)
+	return !isLetter(next) && !isDigit(next) && next != '_'
+}
+
+// startText begins collecting the runes consumed from ch onward.
+func (l *Lexer) startText() {
+	l.text.Reset()
+	l.capturing = true
+}
+
+// takeText stops collecting and returns the runes consumed since startText.
+func (l *Lexer) takeText() string {
+	l.capturing = false
+	return l.text.String()
 }
 
 func (l *Lexer) readIdentifier() string {
-	start := l.position
+	l.startText()
 	for isLetter(l.ch) || isDigit(l.ch) || l.ch == '_' {
 		l.readRune()
 	}
-	return string(l.input[start:l.position])
+	return l.takeText()
 }
 
 func (l *Lexer) readNumber() string {
-	start := l.position
+	l.startText()
 	for isDigit(l.ch) {
 		l.readRune()
 	}
-	if l.ch == '.' {
-		dotPos := l.position
+	if l.ch == '.' && isDigit(l.peekRune()) {
 		l.readRune()
-		if isDigit(l.ch) {
-			for isDigit(l.ch) {
-				l.readRune()
-			}
-		} else {
-			l.position = dotPos
-			l.readPosition = dotPos
-			l.ch = '.'
+		for isDigit(l.ch) {
+			l.readRune()
 		}
 	}
-	return string(l.input[start:l.position])
+	return l.takeText()
 }
 
 func (l *Lexer) readString() string {
 	l.readRune() // consume opening quote
-	start := l.position
+	l.startText()
 	for l.ch != '"' && l.ch != 0 {
 		if l.ch == '\\' {
 			l.readRune()
 		}
 		l.readRune()
 	}
-	literal := string(l.input[start:l.position])
+	literal := l.takeText()
 	if l.ch == '"' {
 		l.readRune() // consume closing quote
 	}
@@ -408,10 +543,10 @@ func (l *Lexer) readTripleQuotedString() string {
 	l.readRune()
 	l.readRune()
 	l.readRune()
-	start := l.position
+	l.startText()
 	for {
 		if l.ch == 0 {
-			return string(l.input[start:l.position])
+			return l.takeText()
 		}
 		if l.ch == '\\' {
 			l.readRune()
@@ -422,7 +557,7 @@ func (l *Lexer) readTripleQuotedString() string {
 			continue
 		}
 		if l.ch == '"' && l.peekRune() == '"' && l.peekRuneN(2) == '"' {
-			literal := string(l.input[start:l.position])
+			literal := l.takeText()
 			l.readRune()
 			l.readRune()
 			l.readRune()
@@ -430,20 +565,20 @@ func (l *Lexer) readTripleQuotedString() string {
 		}
 		l.readRune()
 	}
-	return string(l.input[start:l.position])
+	return l.takeText()
 }
 
 func (l *Lexer) readRawTripleQuotedString() string {
 	l.readRune()
 	l.readRune()
 	l.readRune()
-	start := l.position
+	l.startText()
 	for {
 		if l.ch == 0 {
-			return string(l.input[start:l.position])
+			return l.takeText()
 		}
 		if l.ch == '"' && l.peekRune() == '"' && l.peekRuneN(2) == '"' {
-			literal := string(l.input[start:l.position])
+			literal := l.takeText()
 			l.readRune()
 			l.readRune()
 			l.readRune()
@@ -453,13 +588,110 @@ func (l *Lexer) readRawTripleQuotedString() string {
 	}
 }
 
+// readHeredoc reads a heredoc string such as <<~SQL, whose text starts on
+// the line after the tag and runs up to a line starting with the tag
+// again, after any indentation; code may carry on after the closing tag.
+// The indentation every nonblank line of the text shares is removed, and
+// blank lines are emptied. The text takes escapes like a quoted string,
+// unless the tag is quoted, as in <<~'SQL', which makes it raw like r"...".
+// The opening tag must end its line.
+func (l *Lexer) readHeredoc(tok *token.Token) {
+	l.readRune()
+	l.readRune()
+	l.readRune()
+	raw := l.ch == '\''
+	if raw {
+		l.readRune()
+	}
+	tag := ""
+	if isLetter(l.ch) {
+		tag = l.readIdentifier()
+	}
+	if raw && l.ch == '\'' {
+		l.readRune()
+	} else if raw {
+		tag = ""
+	}
+	for l.ch == ' ' || l.ch == '\t' || l.ch == '\r' {
+		l.readRune()
+	}
+	if tag == "" || l.ch != '\n' {
+		tok.Type = token.ILLEGAL
+		tok.Literal = "<<~"
+		return
+	}
+	l.readRune()
+	var lines []string
+	for l.ch != 0 {
+		l.startText()
+		for l.ch == ' ' || l.ch == '\t' {
+			l.readRune()
+		}
+		indent := l.takeText()
+		if l.atTag(tag) {
+			for range tag {
+				l.readRune()
+			}
+			break
+		}
+		l.startText()
+		for l.ch != '\n' && l.ch != 0 {
+			l.readRune()
+		}
+		lines = append(lines, indent+strings.TrimSuffix(l.takeText(), "\r"))
+		if l.ch == '\n' {
+			l.readRune()
+		}
+	}
+	tok.Type = token.STRING
+	if raw {
+		tok.Type = token.RAWSTRING
+	}
+	tok.Literal = dedent(lines)
+	tok.Heredoc = tag
+}
+
+// atTag reports whether the input at ch is tag, not followed by more of an
+// identifier.
+func (l *Lexer) atTag(tag string) bool {
+	n := 0
+	for _, r := range tag {
+		if n == 0 && l.ch != r || n > 0 && l.peekRuneN(n) != r {
+			return false
+		}
+		n++
+	}
+	next := l.peekRuneN(n)
+	return !isLetter(next) && !isDigit(next)
+}
+
+// dedent joins lines after removing the leading spaces and tabs every
+// nonblank line shares and emptying lines that hold only whitespace.
+func dedent(lines []string) string {
+	margin := -1
+	for _, line := range lines {
+		text := strings.TrimLeft(line, " \t")
+		if n := len(line) - len(text); text != "" && (margin < 0 || n < margin) {
+			margin = n
+		}
+	}
+	for i, line := range lines {
+		if strings.TrimLeft(line, " \t") == "" {
+			lines[i] = ""
+		} else {
+			lines[i] = line[margin:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 func (l *Lexer) readRawString(delim rune) string {
 	l.readRune() // consume opening delimiter
-	start := l.position
+	l.startText()
 	for l.ch != delim && l.ch != 0 {
 		l.readRune()
 	}
-	literal := string(l.input[start:l.position])
+	literal := l.takeText()
 	if l.ch == delim {
 		l.readRune() // consume closing delimiter
 	}
@@ -467,17 +699,46 @@ func (l *Lexer) readRawString(delim rune) string {
 }
 
 func (l *Lexer) skipWhitespaceAndComments() {
+	l.doc = nil
+	newlines := 0
 	for {
 		for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+			if l.ch == '\n' {
+				newlines++
+			}
 			l.readRune()
 		}
+		if newlines > 1 {
+			l.doc = nil
+		}
+		if l.ch == '#' && l.position == 0 && l.peekRune() == '!' {
+			// A #! line starting the file lets a script run as an executable.
+			start := l.currentPosition()
+			l.startText()
+			l.consumeLineComment()
+			l.recordComment(start)
+			continue
+		}
 		if l.ch == '/' {
 			switch l.peekRune() {
 			case '/':
+				start := l.currentPosition()
+				l.startText()
 				l.consumeLineComment()
+				if doc, ok := docText(l.recordComment(start)); ok {
+					l.doc = append(l.doc, doc)
+				} else {
+					l.doc = nil
+				}
+				newlines = 0
 				continue
 			case '*':
+				start := l.currentPosition()
+				l.startText()
 				l.consumeBlockComment()
+				l.recordComment(start)
+				l.doc = nil
+				newlines = 0
 				continue
 			}
 		}
@@ -485,6 +746,24 @@ func (l *Lexer) skipWhitespaceAndComments() {
 	}
 }
 
+// Comments returns the comments skipped so far, in source order.
+func (l *Lexer) Comments() []token.Comment {
+	return l.comments
+}
+
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{File: l.file, Offset: l.position, Line: l.line, Column: l.column}
+}
+
+// recordComment records the comment collected since startText, which began
+// at start, and returns its text.
+func (l *Lexer) recordComment(start token.Position) string {
+	text := strings.TrimSuffix(l.takeText(), "\r")
+	l.comments = append(l.comments, token.Comment{Text: text, Pos: start, End: l.currentPosition()})
+	return text
+}
+
+// consumeLineComment skips a // comment, or the #! line of a script.
 func (l *Lexer) consumeLineComment() {
 	l.readRune() // consume first '/'
 	l.readRune() // consume second '/'
@@ -493,6 +772,17 @@ func (l *Lexer) consumeLineComment() {
 	}
 }
 
+// docText returns the text after the marker of a /// doc comment and reports
+// whether comment is one; //// and longer runs of slashes are ordinary
+// comments.
+func docText(comment string) (string, bool) {
+	text := strings.TrimPrefix(comment, "//")
+	if !strings.HasPrefix(text, "/") || strings.HasPrefix(text, "//") {
+		return "", false
+	}
+	return strings.TrimPrefix(text[1:], " "), true
+}
+
 func (l *Lexer) consumeBlockComment() {
 	l.readRune() // consume first '/'
 	l.readRune() // consume '*'
@@ -509,6 +799,21 @@ func (l *Lexer) consumeBlockComment() {
 	}
 }
 
+// IsIdentifier reports whether name can be written as a bare Selene
+// identifier: it must follow the lexer's identifier rules and must not be a
+// reserved keyword.
+func IsIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, ch := range name {
+		if !isLetter(ch) && (i == 0 || !isDigit(ch)) {
+			return false
+		}
+	}
+	return token.LookupIdent(name) == token.IDENT
+}
+
 func isLetter(ch rune) bool {
 	return ch == '_' || strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ", ch)
 }
@@ -1,7 +1,12 @@
 package lexer
 
 import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/cybellereaper/selenelang/internal/token"
 )
@@ -9,11 +14,12 @@ import (
 func TestLexerRecognizesCoreTokens(t *testing.T) {
 	input := `
 package module import as
-let var fn async contract returns class struct enum interface ext match if else while for using try catch finally throw return break continue condition when await
+let var fn async contract returns class struct enum interface ext match if else while for using try catch finally throw return break continue condition when await yield in
 true false null
 is !is
-+= -= *= /= %= ?: ?. !! && || == != < <= > >= =>
++= -= *= /= %= ++ -- ?: ?. ?[ !! && || == != < <= > >= =>
 & + - * / % = . , ; ( ) { } [ ]
+0..10 1..=2 ...
 `
 
 	tests := []struct {
@@ -51,6 +57,8 @@ is !is
 		{token.CONDITION, "condition"},
 		{token.WHEN, "when"},
 		{token.AWAIT, "await"},
+		{token.YIELD, "yield"},
+		{token.IN, "in"},
 		{token.TRUE, "true"},
 		{token.FALSE, "false"},
 		{token.NULL, "null"},
@@ -61,8 +69,11 @@ is !is
 		{token.STAR_ASSIGN, "*="},
 		{token.SLASH_ASSIGN, "/="},
 		{token.PERCENT_ASSIGN, "%="},
+		{token.INCREMENT, "++"},
+		{token.DECREMENT, "--"},
 		{token.ELVIS, "?:"},
 		{token.SAFE_DOT, "?."},
+		{token.SAFE_INDEX, "?["},
 		{token.NON_NULL, "!!"},
 		{token.AND, "&&"},
 		{token.OR, "||"},
@@ -89,6 +100,13 @@ is !is
 		{token.RBRACE, "}"},
 		{token.LBRACKET, "["},
 		{token.RBRACKET, "]"},
+		{token.NUMBER, "0"},
+		{token.DOTDOT, ".."},
+		{token.NUMBER, "10"},
+		{token.NUMBER, "1"},
+		{token.DOTDOT_EQ, "..="},
+		{token.NUMBER, "2"},
+		{token.ELLIPSIS, "..."},
 	}
 
 	l := New(input)
@@ -133,3 +151,153 @@ func TestLexerParsesStringVariants(t *testing.T) {
 		t.Fatalf("expected backtick raw string, got %s (%q)", tok.Type, tok.Literal)
 	}
 }
+
+func TestIsIdentifier(t *testing.T) {
+	cases := map[string]bool{
+		"richmath":   true,
+		"my_lib":     true,
+		"v2":         true,
+		"_hidden":    true,
+		"":           false,
+		"my-lib":     false,
+		"2fa":        false,
+		"github.com": false,
+		"match":      false,
+	}
+	for input, want := range cases {
+		if got := IsIdentifier(input); got != want {
+			t.Fatalf("IsIdentifier(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
+
+func TestLexerRecordsComments(t *testing.T) {
+	l := New("let a = 1 // one\n/* two\n   lines */ let b = 2\n/// doc\n")
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+	comments := l.Comments()
+	want := []string{"// one", "/* two\n   lines */", "/// doc"}
+	if len(comments) != len(want) {
+		t.Fatalf("expected %d comments, got %+v", len(want), comments)
+	}
+	for i, text := range want {
+		if comments[i].Text != text {
+			t.Fatalf("comment %d: expected %q, got %q", i, text, comments[i].Text)
+		}
+	}
+	if comments[0].Pos.Line != 1 || comments[0].Pos.Column != 11 || comments[1].Pos.Line != 2 {
+		t.Fatalf("unexpected comment positions: %+v", comments)
+	}
+}
+
+func TestLexerSkipsLeadingShebang(t *testing.T) {
+	l := New("#!/usr/bin/env -S selene run\nprint(1);\n")
+	tok := l.NextToken()
+	if tok.Type != token.IDENT || tok.Literal != "print" || tok.Pos.Line != 2 {
+		t.Fatalf("expected the shebang line to be skipped, got %+v", tok)
+	}
+	for ; tok.Type != token.EOF; tok = l.NextToken() {
+	}
+	if comments := l.Comments(); len(comments) != 1 || comments[0].Text != "#!/usr/bin/env -S selene run" {
+		t.Fatalf("expected the shebang to be kept as a comment, got %+v", comments)
+	}
+}
+
+func TestLexerReadsHeredocs(t *testing.T) {
+	input := "q(<<~SQL\n    select *\n      from t\n  \n    where ${x}\r\n    SQL, <<~'RAW'\n\t\\n${y}\n\tRAW);\n<<~ SQL\n"
+
+	l := New(input)
+	l.NextToken()
+	l.NextToken()
+	tok := l.NextToken()
+	if tok.Type != token.STRING || tok.Heredoc != "SQL" || tok.Literal != "select *\n  from t\n\nwhere ${x}" {
+		t.Fatalf("expected a dedented heredoc, got %s %q (%q)", tok.Type, tok.Heredoc, tok.Literal)
+	}
+	if next := l.NextToken(); next.Type != token.COMMA || next.Pos.Line != 6 {
+		t.Fatalf("expected lexing to resume after the closing tag, got %+v", next)
+	}
+	tok = l.NextToken()
+	if tok.Type != token.RAWSTRING || tok.Heredoc != "RAW" || tok.Literal != "\\n${y}" {
+		t.Fatalf("expected a raw heredoc, got %s %q (%q)", tok.Type, tok.Heredoc, tok.Literal)
+	}
+	l.NextToken()
+	l.NextToken()
+	if tok := l.NextToken(); tok.Type != token.ILLEGAL {
+		t.Fatalf("expected a malformed heredoc tag to be illegal, got %+v", tok)
+	}
+}
+
+func TestUnescapeDecodesAndRejectsEscapes(t *testing.T) {
+	decoded := map[string]string{
+		`a\tb\n\r\0`:             "a\tb\n\r\x00",
+		"\\\"\\'\\\\\\$\\`":      "\"'\\$`",
+		`\x41\x7f`:               "A\x7f",
+		`\u00e9 \u{1F600} \u{9}`: "é 😀 \t",
+	}
+	for text, want := range decoded {
+		if got, err := Unescape(text); err != nil || got != want {
+			t.Fatalf("Unescape(%s) = %q, %v; want %q", text, got, err, want)
+		}
+	}
+	invalid := map[string]string{
+		`ok \q`:      `invalid escape sequence \q`,
+		`\x4`:        `invalid escape sequence \x4: \x takes two hex digits`,
+		`\xff`:       `invalid escape sequence \xff: \x escapes stop at 7f; use \u{...} beyond ASCII`,
+		`\u12`:       `invalid escape sequence \u12: \u takes four hex digits, or one to six in \u{...}`,
+		`\u{}`:       `invalid escape sequence \u{}: \u{...} takes one to six hex digits`,
+		`\u{12`:      `invalid escape sequence \u{: missing } after \u{`,
+		`\u{110000}`: `invalid escape sequence \u{110000}: code point out of range`,
+		`\uDC00`:     `invalid escape sequence \uDC00: surrogate halves are not characters`,
+		`trailing \`: `invalid escape sequence \: unterminated escape sequence`,
+	}
+	for text, want := range invalid {
+		_, err := Unescape(text)
+		var escape *EscapeError
+		if !errors.As(err, &escape) || err.Error() != want || text[escape.Offset] != '\\' {
+			t.Fatalf("Unescape(%s) = %v; want %s", text, err, want)
+		}
+	}
+	if err := ValidateEscapes(`${f("\q")} \n`); err != nil {
+		t.Fatalf("expected placeholders to be skipped, got %v", err)
+	}
+	if err := ValidateEscapes(`${x} \q`); err == nil {
+		t.Fatalf("expected an escape after a placeholder to be checked")
+	}
+}
+
+func TestReaderLexerMatchesStringLexer(t *testing.T) {
+	input := "/// Größe.\nlet größe = f\"${x}é\" + 1.5; // ünïcode\nx.y !is T; 3.\n\"\"\"a\n\"b\"\"\"\n<<~END\n  é\n  END;\n"
+	want := New(input)
+	got := NewReader(iotest.OneByteReader(strings.NewReader(input)))
+	for {
+		w, g := want.NextToken(), got.NextToken()
+		if g != w {
+			t.Fatalf("reader lexer produced %+v, want %+v", g, w)
+		}
+		if w.Type == token.EOF {
+			break
+		}
+	}
+	if !reflect.DeepEqual(got.Comments(), want.Comments()) {
+		t.Fatalf("reader lexer recorded %+v, want %+v", got.Comments(), want.Comments())
+	}
+
+	l := New("é x")
+	l.NextToken()
+	if tok := l.NextToken(); tok.Literal != "x" || tok.Pos.Offset != 3 || tok.Pos.Column != 3 {
+		t.Fatalf("expected byte offset 3 and rune column 3 for x, got %+v", tok.Pos)
+	}
+}
+
+func TestReaderLexerReportsReadErrors(t *testing.T) {
+	failure := errors.New("disk on fire")
+	l := NewReader(io.MultiReader(strings.NewReader("let x"), iotest.ErrReader(failure)))
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+	if !errors.Is(l.Err(), failure) {
+		t.Fatalf("expected read error, got %v", l.Err())
+	}
+	if err := New("let x").Err(); err != nil {
+		t.Fatalf("string lexer reported %v", err)
+	}
+}
@@ -3,11 +3,19 @@ package lsp
 
 import (
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/i18n"
 	"github.com/cybellereaper/selenelang/internal/lexer"
 	"github.com/cybellereaper/selenelang/internal/parser"
+	"github.com/cybellereaper/selenelang/internal/project"
+	"github.com/cybellereaper/selenelang/internal/runtime"
 	"github.com/cybellereaper/selenelang/internal/token"
+	"github.com/cybellereaper/selenelang/internal/typecheck"
 )
 
 const diagnosticSource = "selene"
@@ -23,8 +31,34 @@ type AnalysisResult struct {
 // Analyzer coordinates lexical, syntactic, and linting passes.
 type Analyzer struct {
 	linter *Linter
+
+	mu sync.RWMutex
+	// severities overrides the severity of diagnostics by code. A code
+	// mapped to severityOff is not reported.
+	severities map[string]int
+}
+
+// severityOff marks a diagnostic code the user has turned off.
+const severityOff = 0
+
+// severityLevels are the names SetSeverities accepts.
+var severityLevels = map[string]int{
+	"error":       severityError,
+	"warning":     severityWarning,
+	"information": severityInformation,
+	"hint":        severityHint,
+	"off":         severityOff,
 }
 
+// scriptGlobals are the names a script may use without declaring them: the
+// builtins and modules of a fresh runtime, including registered modules,
+// and the script prelude.
+var scriptGlobals = sync.OnceValue(func() []string {
+	rt := runtime.New()
+	rt.UseScriptPrelude()
+	return slices.Sorted(maps.Keys(rt.Environment().Snapshot()))
+})
+
 // NewAnalyzer constructs an Analyzer, defaulting to a fresh linter when nil.
 func NewAnalyzer(linter *Linter) *Analyzer {
 	if linter == nil {
@@ -33,15 +67,96 @@ func NewAnalyzer(linter *Linter) *Analyzer {
 	return &Analyzer{linter: linter}
 }
 
+// SetSeverities replaces the severity overrides, which map a diagnostic code
+// to "error", "warning", "information", "hint", or "off". Codes given any
+// other level keep their default severity, and are listed in the error.
+func (a *Analyzer) SetSeverities(levels map[string]string) error {
+	severities := make(map[string]int, len(levels))
+	var unknown []string
+	for code, level := range levels {
+		severity, ok := severityLevels[strings.ToLower(level)]
+		if !ok {
+			unknown = append(unknown, fmt.Sprintf("%s: %q", code, level))
+			continue
+		}
+		severities[code] = severity
+	}
+	a.mu.Lock()
+	a.severities = severities
+	a.mu.Unlock()
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return fmt.Errorf("unknown severity for %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// applySeverities overrides the severity of diagnostics whose code the user
+// configured, dropping those turned off.
+func (a *Analyzer) applySeverities(diagnostics []Diagnostic) []Diagnostic {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if len(a.severities) == 0 {
+		return diagnostics
+	}
+	kept := diagnostics[:0]
+	for _, diag := range diagnostics {
+		if severity, ok := a.severities[diag.Code]; ok && diag.Code != "" {
+			if severity == severityOff {
+				continue
+			}
+			diag.Severity = severity
+		}
+		kept = append(kept, diag)
+	}
+	return kept
+}
+
+// AnalyzeMode analyzes text as a file in the given mode. Files in a project
+// are also checked for the package declaration projects expect. Scripts are
+// checked for identifiers that neither they nor the runtime declare; files
+// in a project are not, since they share names with their package's other
+// files and its dependencies. Diagnostics turned off by a //selene:disable
+// comment are left out.
+func (a *Analyzer) AnalyzeMode(text string, mode project.Mode) AnalysisResult {
+	var types typecheck.Config
+	if mode == project.ScriptMode {
+		types.Globals = scriptGlobals()
+	}
+	result := a.analyze(text, types)
+	if mode == project.ProjectMode {
+		result.Diagnostics = append(result.Diagnostics, a.linter.missingPackage(result.Program)...)
+	}
+	result.Diagnostics = a.applySeverities(suppress(text, result.Diagnostics))
+	return result
+}
+
+// analyzeURI analyzes a document in the mode of the file its URI names.
+// Documents that are not files are analyzed as scripts.
+func (a *Analyzer) analyzeURI(uri, text string) AnalysisResult {
+	mode := project.ScriptMode
+	if path, ok := uriToPath(uri); ok {
+		mode = project.ModeOf(path)
+	}
+	return a.AnalyzeMode(text, mode)
+}
+
 // Analyze runs the lexer, parser, and linter to produce diagnostics and symbols.
+// It applies no mode-specific checks.
 func (a *Analyzer) Analyze(text string) AnalysisResult {
+	result := a.analyze(text, typecheck.Config{})
+	result.Diagnostics = a.applySeverities(suppress(text, result.Diagnostics))
+	return result
+}
+
+func (a *Analyzer) analyze(text string, types typecheck.Config) AnalysisResult {
 	tokens, lexDiagnostics := lexDocument(text)
 	program, parseDiagnostics := parseDocument(text)
 	symbols := buildSymbolIndex(program, tokens)
 
 	diagnostics := append([]Diagnostic{}, lexDiagnostics...)
 	diagnostics = append(diagnostics, parseDiagnostics...)
-	diagnostics = append(diagnostics, a.linter.Lint(text, program, tokens, symbols)...)
+	diagnostics = append(diagnostics, a.linter.lint(text, program, tokens, symbols, types)...)
 
 	return AnalysisResult{
 		Tokens:      tokens,
@@ -63,7 +178,8 @@ func lexDocument(source string) ([]token.Token, []Diagnostic) {
 				Range:    rangeFromToken(tok),
 				Severity: severityError,
 				Source:   diagnosticSource,
-				Message:  fmt.Sprintf("illegal token %q", tok.Literal),
+				Code:     string(i18n.LexIllegalToken),
+				Message:  i18n.Sprintf(i18n.LexIllegalToken, tok.Literal),
 			})
 		}
 		if tok.Type == token.EOF {
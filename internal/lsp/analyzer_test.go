@@ -1,8 +1,14 @@
 package lsp
 
 import (
+	"bytes"
+	"encoding/json"
+	"slices"
 	"strings"
 	"testing"
+
+	"github.com/cybellereaper/selenelang/internal/i18n"
+	"github.com/cybellereaper/selenelang/internal/project"
 )
 
 func TestAnalyzerProducesDiagnostics(t *testing.T) {
@@ -23,6 +29,20 @@ func TestAnalyzerProducesDiagnostics(t *testing.T) {
 	}
 }
 
+func TestLinterCountsUsesInsideInterpolation(t *testing.T) {
+	text := "fn main() {\n    let name = \"Selene\";\n    let sum = 3;\n    let idle = 0;\n    print(\"Hello, ${name}!\");\n    print(f\"total ${sum:%.1f} \\${idle}\");\n}\n"
+	result := NewAnalyzer(NewLinter()).Analyze(text)
+	var unused []string
+	for _, d := range result.Diagnostics {
+		if d.Code == string(i18n.LintUnusedVariable) {
+			unused = append(unused, d.Message)
+		}
+	}
+	if len(unused) != 1 || !strings.Contains(unused[0], `"idle"`) {
+		t.Fatalf("expected only idle to be unused, got %v", unused)
+	}
+}
+
 func containsDiagnostic(diags []Diagnostic, substr string) bool {
 	for _, d := range diags {
 		if strings.Contains(d.Message, substr) {
@@ -31,3 +51,151 @@ func containsDiagnostic(diags []Diagnostic, substr string) bool {
 	}
 	return false
 }
+
+func TestLinterTranslatesMessagesAndKeepsCodes(t *testing.T) {
+	text := "let foo = 1  \nlet unusedVar = 42"
+	analyzer := NewAnalyzer(&Linter{messages: i18n.NewPrinter("es")})
+	result := analyzer.Analyze(text)
+	codes := make(map[string]string)
+	for _, d := range result.Diagnostics {
+		codes[d.Code] = d.Message
+	}
+	if codes[string(i18n.LintTrailingWhitespace)] != "espacios en blanco al final de la línea" {
+		t.Fatalf("expected a Spanish trailing whitespace diagnostic, got %v", result.Diagnostics)
+	}
+	if !strings.Contains(codes[string(i18n.LintUnusedVariable)], `"unusedVar"`) {
+		t.Fatalf("expected a Spanish unused variable diagnostic, got %v", result.Diagnostics)
+	}
+	if _, ok := codes[string(i18n.LintMissingFinalNewline)]; !ok {
+		t.Fatalf("expected a missing newline diagnostic, got %v", result.Diagnostics)
+	}
+}
+
+func TestLinterReportsFailingConstantExpressions(t *testing.T) {
+	text := "fn main() {\n    print(10 + 4 / (2 - 2));\n    print(-\"x\");\n    print(" + strings.Repeat("9", 200) + " * " + strings.Repeat("9", 200) + ");\n}\n"
+	result := NewAnalyzer(NewLinter()).Analyze(text)
+	found := make(map[string]Diagnostic)
+	for _, d := range result.Diagnostics {
+		found[d.Code] = d
+	}
+	div, ok := found[string(i18n.ConstDivisionByZero)]
+	if !ok || div.Severity != severityError {
+		t.Fatalf("expected a division by zero error, got %v", result.Diagnostics)
+	}
+	if div.Range != (Range{Start: Position{Line: 1, Character: 15}, End: Position{Line: 1, Character: 25}}) {
+		t.Fatalf("expected the division to be highlighted, got %+v", div.Range)
+	}
+	if !strings.Contains(found[string(i18n.ConstInvalidOperation)].Message, "cannot negate String") {
+		t.Fatalf("expected an invalid operation diagnostic, got %v", result.Diagnostics)
+	}
+	if d, ok := found[string(i18n.ConstOverflow)]; !ok || d.Severity != severityWarning {
+		t.Fatalf("expected an overflow warning, got %v", result.Diagnostics)
+	}
+}
+
+func TestProjectFilesWithoutPackageAreReported(t *testing.T) {
+	analyzer := NewAnalyzer(NewLinter())
+	script := "print(\"hi\");\n"
+	result := analyzer.AnalyzeMode(script, project.ProjectMode)
+	if !containsDiagnostic(result.Diagnostics, "no package declaration") {
+		t.Fatalf("expected a missing package diagnostic, got %v", result.Diagnostics)
+	}
+	result = analyzer.AnalyzeMode(script, project.ScriptMode)
+	if containsDiagnostic(result.Diagnostics, "no package declaration") {
+		t.Fatalf("expected scripts to need no package, got %v", result.Diagnostics)
+	}
+	result = analyzer.AnalyzeMode("package demo\n\nprint(\"hi\");\n", project.ProjectMode)
+	if containsDiagnostic(result.Diagnostics, "no package declaration") {
+		t.Fatalf("expected a declared package to satisfy the check, got %v", result.Diagnostics)
+	}
+}
+
+func TestScriptsReportSemanticErrors(t *testing.T) {
+	text := "import path;\n\nfn area(width: Number, height: Number): Number => width * height;\n\nprint(area(2));\nprint(widht);\n\nmatch area(2, 3) {\n    n => print(n);\n    6 => print(\"six\");\n}\n"
+	analyzer := NewAnalyzer(NewLinter())
+	found := make(map[string]Diagnostic)
+	for _, d := range analyzer.AnalyzeMode(text, project.ScriptMode).Diagnostics {
+		found[d.Code] = d
+	}
+	want := map[i18n.MessageID]struct {
+		line     int
+		severity int
+		message  string
+	}{
+		i18n.TypeUnusedImport:   {0, severityWarning, "path is imported but never used"},
+		i18n.TypeArity:          {4, severityError, "wrong number of arguments to area: want 2, got 1"},
+		i18n.TypeUndefined:      {5, severityError, "undefined identifier widht"},
+		i18n.TypeUnreachableArm: {9, severityWarning, "unreachable match arm: the arm on line 9 already matches every value"},
+	}
+	for code, w := range want {
+		d, ok := found[string(code)]
+		if !ok || d.Range.Start.Line != w.line || d.Severity != w.severity || d.Message != w.message {
+			t.Fatalf("expected %s %q on line %d, got %+v", code, w.message, w.line, d)
+		}
+	}
+	result := analyzer.AnalyzeMode("package demo\n\n"+text, project.ProjectMode)
+	if containsDiagnostic(result.Diagnostics, "undefined identifier") {
+		t.Fatalf("expected project files to leave names to the runtime, got %v", result.Diagnostics)
+	}
+}
+
+func TestDisableCommentsSuppressDiagnostics(t *testing.T) {
+	text := "import path; //selene:disable type.unused-import\nimport fs;\n//selene:disable type.undefined, type.arity\nprint(missing);\nprint(other); //selene:disabled type.undefined\n"
+	var lines []int
+	for _, d := range NewAnalyzer(NewLinter()).AnalyzeMode(text, project.ScriptMode).Diagnostics {
+		lines = append(lines, d.Range.Start.Line)
+	}
+	slices.Sort(lines)
+	if len(lines) != 2 || lines[0] != 1 || lines[1] != 4 {
+		t.Fatalf("expected diagnostics only on lines 1 and 4, got %v", lines)
+	}
+}
+
+func TestServerAppliesConfiguredSeverities(t *testing.T) {
+	open, _ := json.Marshal(map[string]any{"textDocument": map[string]any{
+		"uri": "untitled:demo", "version": 1, "text": "import path;\nprint(nope);\n",
+	}})
+	input := frame(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":`+string(open)+`}`) +
+		frame(`{"jsonrpc":"2.0","method":"workspace/didChangeConfiguration","params":{"settings":{"selene":{"diagnostics":{"severity":{"type.unused-import":"off","type.undefined":"Warning","lint.todo-comment":"loud"}}}}}}`)
+	var out, log bytes.Buffer
+	server := NewServer(strings.NewReader(input), &out)
+	server.SetLog(&log, TraceOff)
+	if err := server.Run(); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	var published [][]Diagnostic
+	for _, framed := range strings.Split(out.String(), "Content-Length: ")[1:] {
+		var note struct {
+			Method string `json:"method"`
+			Params struct {
+				Diagnostics []Diagnostic `json:"diagnostics"`
+			} `json:"params"`
+		}
+		if err := json.Unmarshal([]byte(framed[strings.Index(framed, "{"):]), &note); err != nil {
+			t.Fatalf("decode notification: %v", err)
+		}
+		if note.Method == "textDocument/publishDiagnostics" {
+			published = append(published, note.Params.Diagnostics)
+		}
+	}
+	if len(published) != 2 {
+		t.Fatalf("expected diagnostics on open and after the change, got %q", out.String())
+	}
+	severities := func(diags []Diagnostic) map[string]int {
+		found := make(map[string]int)
+		for _, d := range diags {
+			found[d.Code] = d.Severity
+		}
+		return found
+	}
+	before, after := severities(published[0]), severities(published[1])
+	if before[string(i18n.TypeUnusedImport)] != severityWarning || before[string(i18n.TypeUndefined)] != severityError {
+		t.Fatalf("unexpected default severities %v", published[0])
+	}
+	if _, ok := after[string(i18n.TypeUnusedImport)]; ok || after[string(i18n.TypeUndefined)] != severityWarning {
+		t.Fatalf("expected the configured severities, got %v", published[1])
+	}
+	if !strings.Contains(log.String(), `lint.todo-comment: "loud"`) {
+		t.Fatalf("expected the unknown severity to be logged, got %q", log.String())
+	}
+}
@@ -0,0 +1,86 @@
+package lsp
+
+import (
+	"fmt"
+	"os"
+	goruntime "runtime"
+	"sort"
+	"sync"
+
+	"github.com/cybellereaper/selenelang/internal/project"
+)
+
+// FileAnalysis is the outcome of analyzing one file with AnalyzeFiles.
+type FileAnalysis struct {
+	Path   string
+	Result AnalysisResult
+	// Err is set when the file could not be read or its analysis failed.
+	// Other files are unaffected.
+	Err error
+}
+
+// AnalyzeFiles reads and analyzes each file on a pool of workers, one per
+// CPU when workers is below one. Results come back in the order of paths,
+// each with its diagnostics sorted by position, regardless of the order in
+// which analyses finish.
+func (a *Analyzer) AnalyzeFiles(paths []string, workers int) []FileAnalysis {
+	results := make([]FileAnalysis, len(paths))
+	forEachParallel(len(paths), workers, func(i int) {
+		results[i] = a.analyzeFile(paths[i])
+	})
+	return results
+}
+
+func (a *Analyzer) analyzeFile(path string) (result FileAnalysis) {
+	result.Path = path
+	defer func() {
+		if r := recover(); r != nil {
+			result.Result = AnalysisResult{}
+			result.Err = fmt.Errorf("analysis failed: %v", r)
+		}
+	}()
+	// #nosec G304 -- callers pass files they resolved under the workspace.
+	content, err := os.ReadFile(path)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Result = a.AnalyzeMode(string(content), project.ModeOf(path))
+	sortDiagnostics(result.Result.Diagnostics)
+	return result
+}
+
+func sortDiagnostics(diagnostics []Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i].Range.Start, diagnostics[j].Range.Start
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Character < b.Character
+	})
+}
+
+// forEachParallel calls fn with every index below n on a pool of workers, one
+// per CPU when workers is below one, and returns once all calls are done.
+func forEachParallel(n, workers int, fn func(int)) {
+	if workers < 1 {
+		workers = goruntime.GOMAXPROCS(0)
+	}
+	workers = min(workers, n)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for range workers {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,44 @@
+package lsp
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestAnalyzeFilesKeepsInputOrderAndIsolatesFailures(t *testing.T) {
+	root := t.TempDir()
+	var paths []string
+	for i := range 40 {
+		path := filepath.Join(root, fmt.Sprintf("f%02d.selene", i))
+		writeWorkspaceFile(t, path, fmt.Sprintf("fn f%d() { let unused%d = 1 / 0; }\nlet = ;\n", i, i))
+		paths = append(paths, path)
+	}
+	missing := filepath.Join(root, "missing.selene")
+	paths = append(paths[:20], append([]string{missing}, paths[20:]...)...)
+
+	results := NewAnalyzer(nil).AnalyzeFiles(paths, 4)
+	if len(results) != len(paths) {
+		t.Fatalf("expected %d results, got %d", len(paths), len(results))
+	}
+	for i, result := range results {
+		if result.Path != paths[i] {
+			t.Fatalf("result %d is for %s, want %s", i, result.Path, paths[i])
+		}
+		if result.Path == missing {
+			if result.Err == nil {
+				t.Fatal("expected an error for the missing file")
+			}
+			continue
+		}
+		if result.Err != nil || len(result.Result.Diagnostics) < 2 {
+			t.Fatalf("expected diagnostics for %s, got %v, %+v", result.Path, result.Err, result.Result.Diagnostics)
+		}
+		for j := 1; j < len(result.Result.Diagnostics); j++ {
+			prev, curr := result.Result.Diagnostics[j-1].Range.Start, result.Result.Diagnostics[j].Range.Start
+			if curr.Line < prev.Line || curr.Line == prev.Line && curr.Character < prev.Character {
+				t.Fatalf("diagnostics for %s are out of order: %+v", result.Path, result.Result.Diagnostics)
+			}
+		}
+	}
+}
@@ -9,10 +9,12 @@ import (
 // Completer provides completion suggestions for Selene source.
 type Completer struct {
 	keywordItems []CompletionItem
+	snippetItems []CompletionItem
 	builtinItems []CompletionItem
 }
 
-// NewCompleter builds a completer with keyword and builtin suggestions.
+// NewCompleter builds a completer with keyword, snippet, and builtin
+// suggestions.
 func NewCompleter() *Completer {
 	keywords := []CompletionItem{
 		{Label: "let", Kind: completionItemKeyword, Detail: "keyword"},
@@ -30,9 +32,11 @@ func NewCompleter() *Completer {
 		{Label: "as", Kind: completionItemKeyword, Detail: "keyword"},
 		{Label: "package", Kind: completionItemKeyword, Detail: "keyword"},
 		{Label: "interface", Kind: completionItemKeyword, Detail: "keyword"},
+		{Label: "impl", Kind: completionItemKeyword, Detail: "keyword"},
 		{Label: "if", Kind: completionItemKeyword, Detail: "keyword"},
 		{Label: "else", Kind: completionItemKeyword, Detail: "keyword"},
 		{Label: "while", Kind: completionItemKeyword, Detail: "keyword"},
+		{Label: "do", Kind: completionItemKeyword, Detail: "keyword"},
 		{Label: "for", Kind: completionItemKeyword, Detail: "keyword"},
 		{Label: "return", Kind: completionItemKeyword, Detail: "keyword"},
 		{Label: "break", Kind: completionItemKeyword, Detail: "keyword"},
@@ -46,20 +50,52 @@ func NewCompleter() *Completer {
 		{Label: "ext", Kind: completionItemKeyword, Detail: "keyword"},
 		{Label: "condition", Kind: completionItemKeyword, Detail: "keyword"},
 		{Label: "when", Kind: completionItemKeyword, Detail: "keyword"},
+		{Label: "yield", Kind: completionItemKeyword, Detail: "keyword"},
+		{Label: "in", Kind: completionItemKeyword, Detail: "keyword"},
 		{Label: "true", Kind: completionItemKeyword, Detail: "boolean"},
 		{Label: "false", Kind: completionItemKeyword, Detail: "boolean"},
 		{Label: "null", Kind: completionItemKeyword, Detail: "null"},
 	}
+	// Snippets expand a keyword into the construct it starts, with tab stops
+	// for the parts to fill in.
+	snippets := []CompletionItem{
+		{Label: "fn", Detail: "function declaration", InsertText: "fn ${1:name}(${2}) {\n\t$0\n}"},
+		{Label: "class", Detail: "class declaration", InsertText: "class ${1:Name}(${2}) {\n\t$0\n}"},
+		{Label: "match", Detail: "match expression", InsertText: "match ${1:value} {\n\t${2:pattern} => ${3:result};\n\t${4:other} => ${5:fallback};\n}"},
+		{Label: "try", Detail: "try/catch/finally", InsertText: "try {\n\t$1\n} catch (${2:err}) {\n\t$3\n} finally {\n\t$0\n}"},
+		{Label: "for", Detail: "for loop", InsertText: "for (${1:item} in ${2:items}) {\n\t$0\n}"},
+		{Label: "using", Detail: "using block", InsertText: "using ${1:resource} = ${2:value} {\n\t$0\n}"},
+	}
+	for i := range snippets {
+		snippets[i].Kind = completionItemSnippet
+		snippets[i].InsertTextFormat = insertTextSnippet
+	}
 	builtins := []CompletionItem{
 		{Label: "print", Kind: completionItemFunction, Detail: "builtin"},
 		{Label: "spawn", Kind: completionItemFunction, Detail: "builtin"},
 		{Label: "channel", Kind: completionItemFunction, Detail: "builtin"},
+		{Label: "scope", Kind: completionItemFunction, Detail: "builtin"},
+		{Label: "set", Kind: completionItemFunction, Detail: "builtin"},
+		{Label: "freeze", Kind: completionItemFunction, Detail: "builtin"},
+		{Label: "isFrozen", Kind: completionItemFunction, Detail: "builtin"},
+		{Label: "regex", Kind: completionItemModule, Detail: "builtin module"},
+		{Label: "os", Kind: completionItemModule, Detail: "builtin module"},
+		{Label: "fs", Kind: completionItemModule, Detail: "builtin module"},
+		{Label: "time", Kind: completionItemModule, Detail: "builtin module"},
+		{Label: "tasks", Kind: completionItemModule, Detail: "builtin module"},
+		{Label: "reflect", Kind: completionItemModule, Detail: "builtin module"},
+		{Label: "Result", Kind: completionItemEnum, Detail: "builtin enum"},
+		{Label: "Option", Kind: completionItemEnum, Detail: "builtin enum"},
+		{Label: "Error", Kind: completionItemClass, Detail: "builtin class"},
+		{Label: "PermissionError", Kind: completionItemClass, Detail: "builtin class"},
 	}
-	return &Completer{keywordItems: keywords, builtinItems: builtins}
+	return &Completer{keywordItems: keywords, snippetItems: snippets, builtinItems: builtins}
 }
 
-// Completion returns completion candidates for the provided snapshot and position.
-func (c *Completer) Completion(doc *DocumentSnapshot, pos Position) CompletionList {
+// Completion returns completion candidates for the provided snapshot and
+// position. Auto-import suggestions in imports are offered after the
+// document's own symbols, unless the position follows a member access.
+func (c *Completer) Completion(doc *DocumentSnapshot, pos Position, imports ...CompletionItem) CompletionList {
 	if doc == nil || doc.Symbols == nil {
 		return CompletionList{IsIncomplete: false, Items: nil}
 	}
@@ -147,9 +183,19 @@ func (c *Completer) Completion(doc *DocumentSnapshot, pos Position) CompletionLi
 	}
 
 	if prev != '.' {
+		for _, item := range imports {
+			add(item)
+		}
 		for _, item := range c.keywordItems {
 			add(item)
 		}
+		// Snippets share their labels with keywords, so they are not
+		// deduplicated against them.
+		for _, item := range c.snippetItems {
+			if prefixMatches(lowerPrefix, item.Label) {
+				suggestions = append(suggestions, item)
+			}
+		}
 	}
 	for _, item := range c.builtinItems {
 		add(item)
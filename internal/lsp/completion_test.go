@@ -30,6 +30,34 @@ func TestCompletionSuggestsVariablesFunctionsAndParameters(t *testing.T) {
 	}
 }
 
+func TestCompletionOffersConstructSnippets(t *testing.T) {
+	source := "tr\nvalue.fo\n"
+	snapshot := NewDocumentStore(NewAnalyzer(NewLinter())).Open("file:///test.sel", 1, source)
+	completer := NewCompleter()
+	list := completer.Completion(snapshot, Position{Line: 0, Character: 2})
+	var keyword, snippet bool
+	for _, item := range list.Items {
+		if item.Label != "try" {
+			continue
+		}
+		switch item.Kind {
+		case completionItemKeyword:
+			keyword = true
+		case completionItemSnippet:
+			snippet = item.InsertTextFormat == insertTextSnippet &&
+				item.InsertText == "try {\n\t$1\n} catch (${2:err}) {\n\t$3\n} finally {\n\t$0\n}"
+		}
+	}
+	if !keyword || !snippet {
+		t.Fatalf("expected the try keyword and its snippet, got %#v", list.Items)
+	}
+	for _, item := range completer.Completion(snapshot, Position{Line: 1, Character: 8}).Items {
+		if item.Kind == completionItemSnippet {
+			t.Fatalf("expected no snippets after a member access, got %#v", item)
+		}
+	}
+}
+
 func hasCompletion(list CompletionList, label string) bool {
 	for _, item := range list.Items {
 		if item.Label == label {
@@ -1,7 +1,8 @@
 package lsp
 
 import (
-	"sort"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
 
@@ -47,7 +48,7 @@ func NewDocumentStore(analyzer *Analyzer) *DocumentStore {
 
 // Open records a newly opened document and analyzes its contents.
 func (ds *DocumentStore) Open(uri string, version int, text string) *DocumentSnapshot {
-	analysis := ds.analyzer.Analyze(text)
+	analysis := ds.analyzer.analyzeURI(uri, text)
 	state := &documentState{uri: uri, version: version, text: text, analysis: analysis}
 	ds.mu.Lock()
 	ds.docs[uri] = state
@@ -57,7 +58,7 @@ func (ds *DocumentStore) Open(uri string, version int, text string) *DocumentSna
 
 // Update replaces the stored document contents and re-runs analysis.
 func (ds *DocumentStore) Update(uri string, version int, text string) *DocumentSnapshot {
-	analysis := ds.analyzer.Analyze(text)
+	analysis := ds.analyzer.analyzeURI(uri, text)
 	ds.mu.Lock()
 	state := &documentState{uri: uri, version: version, text: text, analysis: analysis}
 	ds.docs[uri] = state
@@ -70,6 +71,22 @@ func (ds *DocumentStore) Save(uri string, version int, text string) *DocumentSna
 	return ds.Update(uri, version, text)
 }
 
+// Reanalyze re-runs analysis for every tracked document, as after the
+// analyzer's settings change, and returns the new snapshots sorted by URI.
+func (ds *DocumentStore) Reanalyze() []*DocumentSnapshot {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	uris := slices.Sorted(maps.Keys(ds.docs))
+	result := make([]*DocumentSnapshot, 0, len(uris))
+	for _, uri := range uris {
+		old := ds.docs[uri]
+		state := &documentState{uri: uri, version: old.version, text: old.text, analysis: ds.analyzer.analyzeURI(uri, old.text)}
+		ds.docs[uri] = state
+		result = append(result, state.snapshot())
+	}
+	return result
+}
+
 // Close removes a document from the store.
 func (ds *DocumentStore) Close(uri string) {
 	ds.mu.Lock()
@@ -116,18 +133,7 @@ func (ds *DocumentStore) WorkspaceSymbols(query string) []SymbolInformation {
 			}
 		}
 	}
-	sort.Slice(infos, func(i, j int) bool {
-		if infos[i].Name == infos[j].Name {
-			if infos[i].Location.URI == infos[j].Location.URI {
-				if infos[i].Location.Range.Start.Line == infos[j].Location.Range.Start.Line {
-					return infos[i].Location.Range.Start.Character < infos[j].Location.Range.Start.Character
-				}
-				return infos[i].Location.Range.Start.Line < infos[j].Location.Range.Start.Line
-			}
-			return infos[i].Location.URI < infos[j].Location.URI
-		}
-		return infos[i].Name < infos[j].Name
-	})
+	sortSymbolInformation(infos)
 	return infos
 }
 
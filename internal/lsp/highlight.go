@@ -341,10 +341,10 @@ func isKeywordToken(t token.Type) bool {
 	switch t {
 	case token.LET, token.VAR, token.FN, token.ASYNC, token.CONTRACT, token.RETURNS,
 		token.CLASS, token.STRUCT, token.ENUM, token.MATCH, token.MODULE, token.IMPORT,
-		token.AS, token.PACKAGE, token.INTERFACE, token.IF, token.ELSE, token.WHILE,
+		token.AS, token.PACKAGE, token.INTERFACE, token.IMPL, token.IF, token.ELSE, token.DO, token.WHILE,
 		token.FOR, token.RETURN, token.BREAK, token.CONTINUE, token.AWAIT, token.TRY,
 		token.CATCH, token.FINALLY, token.THROW, token.USING, token.EXT, token.CONDITION,
-		token.WHEN, token.TRUE, token.FALSE, token.NULL:
+		token.WHEN, token.YIELD, token.IN, token.TRUE, token.FALSE, token.NULL:
 		return true
 	default:
 		return false
@@ -355,9 +355,9 @@ func isOperatorToken(t token.Type) bool {
 	switch t {
 	case token.ASSIGN, token.PLUS, token.MINUS, token.ASTERISK, token.SLASH, token.PERCENT,
 		token.PLUS_ASSIGN, token.MINUS_ASSIGN, token.STAR_ASSIGN, token.SLASH_ASSIGN, token.PERCENT_ASSIGN,
-		token.BANG, token.QUESTION, token.COLON, token.ELVIS, token.SAFE_DOT, token.NON_NULL,
+		token.BANG, token.QUESTION, token.COLON, token.ELVIS, token.SAFE_DOT, token.SAFE_INDEX, token.NON_NULL,
 		token.AMPERSAND, token.EQ, token.NOT_EQ, token.LT, token.LTE, token.GT, token.GTE,
-		token.OR, token.AND, token.ARROW, token.IS, token.NOT_IS:
+		token.OR, token.AND, token.ARROW, token.IS, token.NOT_IS, token.DOTDOT, token.DOTDOT_EQ:
 		return true
 	default:
 		return false
@@ -0,0 +1,415 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/consteval"
+	"github.com/cybellereaper/selenelang/internal/typecheck"
+)
+
+// maxInferenceDepth bounds how many variables inferType and constant follow
+// through initializers such as `let b = a`.
+const maxInferenceDepth = 16
+
+func buildHover(doc *DocumentSnapshot, pos Position) (Hover, bool) {
+	name, rng := identifierAt(doc.Text, pos)
+	if name == "" {
+		return Hover{}, false
+	}
+	if value, ok := hoverFromDeclarations(doc, name, pos); ok {
+		return Hover{Contents: MarkupContent{Kind: "markdown", Value: value}, Range: &rng}, true
+	}
+	var content strings.Builder
+	if doc.Symbols != nil {
+		for _, fn := range doc.Symbols.FunctionSymbols {
+			if fn.Name == name {
+				content.WriteString(fmt.Sprintf("**function** `%s`\n\n", fn.Detail))
+				return Hover{Contents: MarkupContent{Kind: "markdown", Value: content.String()}, Range: &rng}, true
+			}
+		}
+		for _, param := range collectParameters(doc.Symbols) {
+			if param.Name == name {
+				content.WriteString(fmt.Sprintf("**parameter** `%s`", param.Name))
+				return Hover{Contents: MarkupContent{Kind: "markdown", Value: content.String()}, Range: &rng}, true
+			}
+		}
+		for _, variable := range doc.Symbols.VariableSymbols {
+			if variable.Name == name {
+				detail := "immutable"
+				if variable.Mutable {
+					detail = "mutable"
+				}
+				content.WriteString(fmt.Sprintf("**variable** `%s` (%s)", variable.Name, detail))
+				return Hover{Contents: MarkupContent{Kind: "markdown", Value: content.String()}, Range: &rng}, true
+			}
+		}
+		for _, t := range doc.Symbols.TypeSymbols {
+			if t.Name == name {
+				content.WriteString(fmt.Sprintf("**%s** `%s`", strings.ToLower(t.Detail), t.Name))
+				return Hover{Contents: MarkupContent{Kind: "markdown", Value: content.String()}, Range: &rng}, true
+			}
+		}
+	}
+	if content.Len() == 0 {
+		content.WriteString(fmt.Sprintf("`%s`", name))
+	}
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: content.String()}, Range: &rng}, true
+}
+
+// hoverFromDeclarations renders the declaration the identifier at pos
+// resolves to. Member names such as `p.x` or `Status.Active` are not bound by
+// the resolver, so they fall back to the first field or enum case declared
+// with that name. When the identifier names a generic declaration a call
+// instantiates, the type arguments the call infers follow the signature.
+func hoverFromDeclarations(doc *DocumentSnapshot, name string, pos Position) (string, bool) {
+	if doc.Program == nil {
+		return "", false
+	}
+	info := typecheck.Check(doc.Program)
+	h := &hoverRenderer{bindings: make(map[Range]*binding), types: info.Types, instance: instanceAt(info, name, pos)}
+	occurrences := resolveOccurrences(doc.Program)
+	var target *binding
+	for _, occ := range occurrences {
+		h.bindings[occ.rng] = occ.target
+		if target == nil && rangeContains(occ.rng, pos) {
+			target = occ.target
+		}
+	}
+	if target != nil && target.decl != nil {
+		return h.render(target.decl), true
+	}
+	if decl := memberDeclaration(doc.Program, name); decl != nil {
+		return h.render(decl), true
+	}
+	return "", false
+}
+
+func memberDeclaration(program *ast.Program, name string) any {
+	for _, item := range program.Items {
+		switch node := item.(type) {
+		case *ast.StructDeclaration:
+			for _, param := range node.Params {
+				if param.Name != nil && param.Name.Name == name {
+					return parameterDecl{param: param, owner: node}
+				}
+			}
+		case *ast.ClassDeclaration:
+			for _, param := range node.Params {
+				if param.Name != nil && param.Name.Name == name {
+					return parameterDecl{param: param, owner: node}
+				}
+			}
+		case *ast.EnumDeclaration:
+			for i := range node.Cases {
+				if c := &node.Cases[i]; c.Name != nil && c.Name.Name == name {
+					return enumCaseDecl{enumCase: c, enum: node}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hoverRenderer formats declarations as hover markdown: a kind and name
+// heading, a Selene code block with the signature, and the doc comment.
+type hoverRenderer struct {
+	// bindings maps identifier ranges to what they resolve to, so inferred
+	// types can follow names back to their declarations.
+	bindings map[Range]*binding
+	// types are the expression types the type checker inferred, and
+	// instance the type arguments of the call whose callee is hovered.
+	types    map[ast.Expression]*typecheck.Type
+	instance *typecheck.Instance
+}
+
+// instanceAt returns the type arguments of the generic call whose callee,
+// named name, is at pos.
+func instanceAt(info *typecheck.Info, name string, pos Position) *typecheck.Instance {
+	for call, inst := range info.Instances {
+		switch callee := call.Callee.(type) {
+		case *ast.Identifier:
+			if callee.Name == name && rangeContains(rangeFromIdentifier(callee), pos) {
+				return inst
+			}
+		case *ast.MemberExpression:
+			if callee.Property == name && rangeContains(rangeFromPositions(callee.Object.End(), callee.End()), pos) {
+				return inst
+			}
+		}
+	}
+	return nil
+}
+
+// typeArguments formats the inferred type arguments of inst, leaving out
+// those that could not be inferred.
+func typeArguments(inst *typecheck.Instance) string {
+	if inst == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(inst.Params))
+	for i, param := range inst.Params {
+		if arg := inst.Args[i]; arg != nil {
+			parts = append(parts, fmt.Sprintf("`%s = %s`", param, arg))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (h *hoverRenderer) render(decl any) string {
+	var heading, signature, doc string
+	switch node := decl.(type) {
+	case *ast.FunctionDeclaration:
+		heading = fmt.Sprintf("**function** `%s`", identifierName(node.Name))
+		signature, doc = functionHoverSignature(node), node.Doc
+	case *ast.InterfaceMethod:
+		heading = fmt.Sprintf("**method** `%s`", identifierName(node.Name))
+		signature = fmt.Sprintf("fn %s(%s)%s", identifierName(node.Name), parameterList(node.Params), returnSuffix(node.ReturnType))
+		doc = node.Doc
+	case *ast.VariableDeclaration:
+		keyword := "let"
+		if node.Mutable {
+			keyword = "var"
+		}
+		heading = fmt.Sprintf("**variable** `%s`", identifierName(node.Name))
+		signature = fmt.Sprintf("%s %s", keyword, identifierName(node.Name))
+		if t := h.variableType(node, 0); t != "" {
+			signature += ": " + t
+		}
+		if !node.Mutable {
+			if v, err := h.constant(node.Value, 0); err == nil {
+				signature += " = " + v.Source()
+			}
+		}
+		doc = node.Doc
+	case parameterDecl:
+		name := identifierName(node.param.Name)
+		signature = name
+		if node.param.Type != nil {
+			signature += ": " + formatTypeAnnotation(node.param.Type)
+		}
+		switch owner := node.owner.(type) {
+		case *ast.StructDeclaration:
+			heading = fmt.Sprintf("**field** `%s` of `%s`", name, identifierName(owner.Name))
+		case *ast.ClassDeclaration:
+			heading = fmt.Sprintf("**field** `%s` of `%s`", name, identifierName(owner.Name))
+		default:
+			heading = fmt.Sprintf("**parameter** `%s`", name)
+		}
+		doc = node.param.Doc
+	case enumCaseDecl:
+		name := identifierName(node.enumCase.Name)
+		heading = fmt.Sprintf("**enum case** `%s` of `%s`", name, identifierName(node.enum.Name))
+		signature = name
+		if len(node.enumCase.Params) > 0 {
+			signature += "(" + parameterList(node.enumCase.Params) + ")"
+		}
+		doc = node.enumCase.Doc
+	case *ast.StructDeclaration:
+		heading = fmt.Sprintf("**struct** `%s`", identifierName(node.Name))
+		signature = fmt.Sprintf("struct %s(%s)", identifierName(node.Name), parameterList(node.Params))
+		doc = node.Doc
+	case *ast.ClassDeclaration:
+		heading = fmt.Sprintf("**class** `%s`", identifierName(node.Name))
+		signature = fmt.Sprintf("class %s%s(%s)", identifierName(node.Name), typeParameterList(node.TypeParams), parameterList(node.Params))
+		if node.SuperClass != nil {
+			signature += " : " + node.SuperClass.Name
+		}
+		doc = node.Doc
+	case *ast.EnumDeclaration:
+		heading = fmt.Sprintf("**enum** `%s`", identifierName(node.Name))
+		signature = "enum " + identifierName(node.Name) + typeParameterList(node.TypeParams)
+		doc = node.Doc
+	case *ast.InterfaceDeclaration:
+		heading = fmt.Sprintf("**interface** `%s`", identifierName(node.Name))
+		signature, doc = "interface "+identifierName(node.Name), node.Doc
+	case *ast.ContractDeclaration:
+		heading = fmt.Sprintf("**contract** `%s`", identifierName(node.Name))
+		signature, doc = "contract "+identifierName(node.Name), node.Doc
+	}
+	var b strings.Builder
+	b.WriteString(heading)
+	if signature != "" {
+		fmt.Fprintf(&b, "\n\n```selene\n%s\n```", signature)
+	}
+	if args := typeArguments(h.instance); args != "" {
+		b.WriteString("\n\n")
+		b.WriteString(args)
+	}
+	if doc != "" {
+		b.WriteString("\n\n")
+		b.WriteString(doc)
+	}
+	return b.String()
+}
+
+func functionHoverSignature(fn *ast.FunctionDeclaration) string {
+	var b strings.Builder
+	if fn.IsExtension {
+		b.WriteString("ext ")
+	}
+	if fn.Async {
+		b.WriteString("async ")
+	}
+	b.WriteString("fn")
+	if fn.Generator {
+		b.WriteString("*")
+	}
+	b.WriteString(" ")
+	if fn.Receiver != nil {
+		b.WriteString(formatTypeAnnotation(fn.Receiver))
+		b.WriteString(".")
+	}
+	b.WriteString(identifierName(fn.Name))
+	b.WriteString(typeParameterList(fn.TypeParams))
+	fmt.Fprintf(&b, "(%s)%s", parameterList(fn.Params), returnSuffix(fn.ReturnType))
+	return b.String()
+}
+
+func parameterList(params []ast.Parameter) string {
+	parts := make([]string, 0, len(params))
+	for _, param := range params {
+		if param.Name == nil {
+			continue
+		}
+		part := param.Name.Name
+		if param.Type != nil {
+			part += ": " + formatTypeAnnotation(param.Type)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func typeParameterList(params []*ast.Identifier) string {
+	if len(params) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(params))
+	for _, param := range params {
+		names = append(names, identifierName(param))
+	}
+	return "<" + strings.Join(names, ", ") + ">"
+}
+
+func returnSuffix(t *ast.TypeAnnotation) string {
+	if t == nil {
+		return ""
+	}
+	return ": " + formatTypeAnnotation(t)
+}
+
+func identifierName(id *ast.Identifier) string {
+	if id == nil {
+		return "_"
+	}
+	return id.Name
+}
+
+// variableType returns the declared type of a variable or, failing that, the
+// type its initializer evidently has. It returns "" when neither is known.
+func (h *hoverRenderer) variableType(decl *ast.VariableDeclaration, depth int) string {
+	if decl.Type != nil {
+		return formatTypeAnnotation(decl.Type)
+	}
+	return h.inferType(decl.Value, depth)
+}
+
+func (h *hoverRenderer) inferType(expr ast.Expression, depth int) string {
+	if depth > maxInferenceDepth {
+		return ""
+	}
+	if t := h.types[expr]; t != nil {
+		return t.String()
+	}
+	switch node := expr.(type) {
+	case *ast.NumberLiteral:
+		return "Number"
+	case *ast.StringLiteral:
+		return "String"
+	case *ast.BooleanLiteral:
+		return "Boolean"
+	case *ast.NullLiteral:
+		return "Null"
+	case *ast.ArrayLiteral:
+		return "Array"
+	case *ast.SetLiteral:
+		return "Set"
+	case *ast.ObjectLiteral:
+		return "Object"
+	case *ast.Identifier:
+		switch decl := h.resolve(node).(type) {
+		case *ast.VariableDeclaration:
+			return h.variableType(decl, depth+1)
+		case parameterDecl:
+			if decl.param.Type != nil {
+				return formatTypeAnnotation(decl.param.Type)
+			}
+		}
+	case *ast.CallExpression:
+		callee, ok := node.Callee.(*ast.Identifier)
+		if !ok {
+			return ""
+		}
+		switch decl := h.resolve(callee).(type) {
+		case *ast.StructDeclaration:
+			return identifierName(decl.Name)
+		case *ast.ClassDeclaration:
+			return identifierName(decl.Name)
+		case *ast.FunctionDeclaration:
+			if decl.ReturnType != nil {
+				return formatTypeAnnotation(decl.ReturnType)
+			}
+		}
+	case *ast.MemberExpression:
+		if object, ok := node.Object.(*ast.Identifier); ok {
+			if enum, ok := h.resolve(object).(*ast.EnumDeclaration); ok {
+				return identifierName(enum.Name)
+			}
+		}
+	case *ast.PrefixExpression:
+		if node.Operator == "!" {
+			return "Boolean"
+		}
+		if node.Operator == "-" {
+			return "Number"
+		}
+	case *ast.InfixExpression:
+		switch node.Operator {
+		case "==", "!=", "<", "<=", ">", ">=", "&&", "||":
+			return "Boolean"
+		case "-", "*", "/", "%":
+			return "Number"
+		case "+":
+			left, right := h.inferType(node.Left, depth+1), h.inferType(node.Right, depth+1)
+			if left == "String" || right == "String" {
+				return "String"
+			}
+			if left == "Number" && right == "Number" {
+				return "Number"
+			}
+		}
+	}
+	return ""
+}
+
+// constant evaluates expr, following names bound by immutable `let`
+// declarations to their initializers.
+func (h *hoverRenderer) constant(expr ast.Expression, depth int) (consteval.Value, error) {
+	return consteval.Eval(expr, func(id *ast.Identifier) (consteval.Value, bool) {
+		decl, ok := h.resolve(id).(*ast.VariableDeclaration)
+		if !ok || decl.Mutable || depth >= maxInferenceDepth {
+			return consteval.Value{}, false
+		}
+		v, err := h.constant(decl.Value, depth+1)
+		return v, err == nil
+	})
+}
+
+func (h *hoverRenderer) resolve(id *ast.Identifier) any {
+	if b := h.bindings[rangeFromIdentifier(id)]; b != nil {
+		return b.decl
+	}
+	return nil
+}
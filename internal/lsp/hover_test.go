@@ -21,3 +21,136 @@ func TestBuildHoverForFunction(t *testing.T) {
 		t.Fatalf("unexpected hover contents: %s", hover.Contents.Value)
 	}
 }
+
+func TestBuildHoverShowsDocsSignaturesAndTypes(t *testing.T) {
+	source := strings.Join([]string{
+		"/// A point on the plane.",
+		"struct Point(",
+		"    /// Distance from the y axis.",
+		"    x: Number,",
+		"    y: Number",
+		")",
+		"enum Status {",
+		"    /// Still running.",
+		"    Active;",
+		"    Done;",
+		"}",
+		"/// Adds two numbers.",
+		"/// Both must be finite.",
+		"fn add(a: Number, b: Number): Number {",
+		"    return a + b;",
+		"}",
+		"let origin = Point(0, 0);",
+		"let total = add(1, 2);",
+		"let state = Status.Active;",
+		"let copy = origin;",
+		"let label = \"n\" + total;",
+		"let first = origin.x;",
+	}, "\n") + "\n"
+	docs := NewDocumentStore(NewAnalyzer(NewLinter()))
+	snapshot := docs.Open("file:///hover_docs.sel", 1, source)
+	for _, d := range snapshot.Diagnostics {
+		if d.Severity == severityError {
+			t.Fatalf("unexpected error diagnostic: %+v", d)
+		}
+	}
+
+	cases := []struct {
+		pos  Position
+		want []string
+	}{
+		{Position{Line: 13, Character: 4}, []string{"**function** `add`", "fn add(a: Number, b: Number): Number", "Adds two numbers.\nBoth must be finite."}},
+		{Position{Line: 1, Character: 8}, []string{"**struct** `Point`", "struct Point(x: Number, y: Number)", "A point on the plane."}},
+		{Position{Line: 3, Character: 4}, []string{"**field** `x` of `Point`", "x: Number", "Distance from the y axis."}},
+		{Position{Line: 21, Character: 20}, []string{"**field** `x` of `Point`", "Distance from the y axis."}},
+		{Position{Line: 18, Character: 20}, []string{"**enum case** `Active` of `Status`", "Still running."}},
+		{Position{Line: 16, Character: 5}, []string{"let origin: Point"}},
+		{Position{Line: 17, Character: 5}, []string{"let total: Number"}},
+		{Position{Line: 18, Character: 5}, []string{"let state: Status"}},
+		{Position{Line: 19, Character: 5}, []string{"let copy: Point"}},
+		{Position{Line: 20, Character: 5}, []string{"let label: String"}},
+		{Position{Line: 14, Character: 11}, []string{"**parameter** `a`", "a: Number"}},
+	}
+	for _, tc := range cases {
+		hover, ok := buildHover(snapshot, tc.pos)
+		if !ok {
+			t.Fatalf("expected hover at %+v", tc.pos)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(hover.Contents.Value, want) {
+				t.Fatalf("hover at %+v missing %q:\n%s", tc.pos, want, hover.Contents.Value)
+			}
+		}
+	}
+}
+
+func TestBuildHoverShowsConstantValues(t *testing.T) {
+	source := strings.Join([]string{
+		"let width = 8;",
+		"let area = width * (width - 2);",
+		"let banner = \"area: \" + area;",
+		"var count = 1;",
+		"let next = count + 1;",
+	}, "\n") + "\n"
+	docs := NewDocumentStore(NewAnalyzer(NewLinter()))
+	snapshot := docs.Open("file:///hover_const.sel", 1, source)
+	cases := []struct {
+		pos  Position
+		want string
+	}{
+		{Position{Line: 1, Character: 5}, "let area: Number = 48\n"},
+		{Position{Line: 2, Character: 5}, "let banner: String = \"area: 48\"\n"},
+		{Position{Line: 3, Character: 5}, "var count: Number\n"},
+		{Position{Line: 4, Character: 5}, "let next: Number\n"},
+	}
+	for _, tc := range cases {
+		hover, ok := buildHover(snapshot, tc.pos)
+		if !ok {
+			t.Fatalf("expected hover at %+v", tc.pos)
+		}
+		if !strings.Contains(hover.Contents.Value, tc.want) {
+			t.Fatalf("hover at %+v missing %q:\n%s", tc.pos, tc.want, hover.Contents.Value)
+		}
+	}
+}
+
+func TestBuildHoverShowsInferredTypeArguments(t *testing.T) {
+	source := strings.Join([]string{
+		"enum Box<T> { Full(value: T); Empty; }",
+		"fn identity<T>(value: T): T => value;",
+		"let box = Box.Full(\"x\");",
+		"let n = identity(5);",
+		"let bad: Box<Number> = Box.Full(\"y\");",
+	}, "\n") + "\n"
+	docs := NewDocumentStore(NewAnalyzer(NewLinter()))
+	snapshot := docs.Open("file:///hover_generic.sel", 1, source)
+	cases := []struct {
+		pos  Position
+		want []string
+	}{
+		{Position{Line: 2, Character: 5}, []string{"let box: Box<String>"}},
+		{Position{Line: 3, Character: 5}, []string{"let n: Number"}},
+		{Position{Line: 3, Character: 10}, []string{"fn identity<T>(value: T): T", "`T = Number`"}},
+		{Position{Line: 2, Character: 15}, []string{"**enum case** `Full` of `Box`", "`T = String`"}},
+	}
+	for _, tc := range cases {
+		hover, ok := buildHover(snapshot, tc.pos)
+		if !ok {
+			t.Fatalf("expected hover at %+v", tc.pos)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(hover.Contents.Value, want) {
+				t.Fatalf("hover at %+v missing %q:\n%s", tc.pos, want, hover.Contents.Value)
+			}
+		}
+	}
+	var found bool
+	for _, d := range snapshot.Diagnostics {
+		if d.Code == "type.mismatch" && d.Range.Start.Line == 4 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected a type.mismatch diagnostic on line 5, got %+v", snapshot.Diagnostics)
+	}
+}
@@ -0,0 +1,297 @@
+package lsp
+
+import (
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/lexer"
+	"github.com/cybellereaper/selenelang/internal/parser"
+	"github.com/cybellereaper/selenelang/internal/printer"
+	"github.com/cybellereaper/selenelang/internal/project"
+	"github.com/cybellereaper/selenelang/internal/typecheck"
+)
+
+// organizeImports returns the edit that sorts the top-level imports of text,
+// drops duplicates and imports the type checker reports as unused, and
+// gathers what is left where the first import was. It returns nil when the
+// imports are already organized or the text does not parse.
+func organizeImports(text string) []TextEdit {
+	p := parser.New(lexer.New(text))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil
+	}
+	var imports []*ast.ImportDeclaration
+	for _, item := range program.Items {
+		if imp, ok := item.(*ast.ImportDeclaration); ok {
+			imports = append(imports, imp)
+		}
+	}
+	if len(imports) == 0 {
+		return nil
+	}
+	unused := make(map[ast.Node]bool)
+	for _, err := range typecheck.Check(program).Errors {
+		if err.Kind == typecheck.UnusedImport {
+			unused[err.Node] = true
+		}
+	}
+	type line struct{ key, text string }
+	var lines []line
+	seen := make(map[string]bool)
+	for _, imp := range imports {
+		printed := printer.Node(imp)
+		if unused[imp] || seen[printed] {
+			continue
+		}
+		seen[printed] = true
+		lines = append(lines, line{key: importSortKey(imp), text: printed})
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].key < lines[j].key })
+	block := make([]string, len(lines))
+	for i, l := range lines {
+		block[i] = l.text
+	}
+
+	spans := importSpans(text, imports)
+	var b strings.Builder
+	b.WriteString(strings.Join(block, "\n"))
+	if len(block) > 0 && strings.HasSuffix(text[spans[0][0]:spans[0][1]], "\n") {
+		b.WriteString("\n")
+	}
+	for i := 1; i < len(spans); i++ {
+		b.WriteString(text[spans[i-1][1]:spans[i][0]])
+	}
+	start, end := spans[0][0], spans[len(spans)-1][1]
+	replacement := b.String()
+	if replacement == text[start:end] {
+		return nil
+	}
+	return []TextEdit{{
+		Range:   Range{Start: positionForByteOffset(text, start), End: positionForByteOffset(text, end)},
+		NewText: replacement,
+	}}
+}
+
+// importSpans returns the byte ranges organizeImports removes for each
+// import: the whole line when the import is alone on it, and the blank
+// lines that separate it from the next import.
+func importSpans(text string, imports []*ast.ImportDeclaration) [][2]int {
+	spans := make([][2]int, 0, len(imports))
+	for _, imp := range imports {
+		start, end := imp.Start.Offset, imp.Finish.Offset
+		lineStart := strings.LastIndexByte(text[:start], '\n') + 1
+		lineEnd := len(text)
+		if i := strings.IndexByte(text[end:], '\n'); i >= 0 {
+			lineEnd = end + i + 1
+		}
+		if strings.TrimSpace(text[lineStart:start]) == "" && strings.TrimSpace(text[end:lineEnd]) == "" {
+			start, end = lineStart, lineEnd
+		}
+		if n := len(spans); n > 0 && strings.TrimSpace(text[spans[n-1][1]:start]) == "" {
+			spans[n-1][1] = end
+			continue
+		}
+		spans = append(spans, [2]int{start, end})
+	}
+	return spans
+}
+
+func importSortKey(imp *ast.ImportDeclaration) string {
+	path := imp.PathLiteral
+	if path == "" {
+		names := make([]string, len(imp.Path))
+		for i, segment := range imp.Path {
+			names[i] = segment.Name
+		}
+		path = strings.Join(names, "/")
+	}
+	if imp.Alias != nil {
+		path += " " + imp.Alias.Name
+	}
+	return path
+}
+
+// autoImports suggests the exports of other files in the document's
+// project, and of the modules it vendors, that the document does not import
+// yet. Accepting a suggestion adds its import. Documents outside a project
+// get none, and neither do the files of the document's own directory, which
+// share its package.
+func autoImports(doc *DocumentSnapshot, index *WorkspaceIndex) []CompletionItem {
+	if doc == nil || doc.Program == nil || index == nil {
+		return nil
+	}
+	path, ok := uriToPath(doc.URI)
+	if !ok {
+		return nil
+	}
+	modules := importableModules(filepath.Dir(path))
+	if len(modules) == 0 {
+		return nil
+	}
+	imported := make(map[string]bool)
+	for _, item := range doc.Program.Items {
+		if imp, ok := item.(*ast.ImportDeclaration); ok {
+			imported[importedName(imp)] = true
+		}
+	}
+	var items []CompletionItem
+	for _, export := range index.Exports(pathToURI(path)) {
+		file, ok := uriToPath(export.Location.URI)
+		if !ok || imported[export.Name] || filepath.Dir(file) == filepath.Dir(path) {
+			continue
+		}
+		segments, ok := importSegments(modules, file)
+		if !ok {
+			continue
+		}
+		statement := importStatement(append(segments, export.Name))
+		items = append(items, CompletionItem{
+			Label:               export.Name,
+			Kind:                completionKindForSymbol(export.Kind),
+			Detail:              statement,
+			AdditionalTextEdits: []TextEdit{importEdit(doc, statement)},
+		})
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Detail < items[j].Detail })
+	return items
+}
+
+// importableModule maps the Selene files under dir to the import path
+// segments of the module they make up. Project sources are modules of their
+// own, one per file, while a vendored dependency is a single module.
+type importableModule struct {
+	dir      string
+	segments []string
+	perFile  bool
+}
+
+// importableModules returns the modules a file in dir may import: the
+// sources of its project when the manifest names a module path, and the
+// dependencies pinned in the workspace lockfile.
+func importableModules(dir string) []importableModule {
+	root, err := project.FindRoot(dir)
+	if err != nil {
+		return nil
+	}
+	manifest, err := project.LoadManifest(root)
+	if err != nil {
+		return nil
+	}
+	var modules []importableModule
+	if manifest.Project.Module != "" {
+		modules = append(modules, importableModule{
+			dir:      filepath.Join(root, project.SourceDirectory),
+			segments: strings.Split(manifest.Project.Module, "/"),
+			perFile:  true,
+		})
+	}
+	if len(manifest.Dependencies) == 0 {
+		return modules
+	}
+	lockRoot, err := project.FindWorkspaceRoot(root)
+	if err != nil {
+		return modules
+	}
+	lock, err := project.LoadLockfile(lockRoot)
+	if err != nil {
+		return modules
+	}
+	for _, module := range project.SortedModules(manifest.Dependencies) {
+		locked, ok := lock.Lookup(module)
+		if !ok || locked.Vendor == "" {
+			continue
+		}
+		vendor, err := project.ResolveUnderRoot(lockRoot, locked.Vendor)
+		if err != nil {
+			continue
+		}
+		modules = append(modules, importableModule{dir: vendor, segments: strings.Split(module, "/")})
+	}
+	return modules
+}
+
+// importSegments returns the import path of the module file belongs to.
+func importSegments(modules []importableModule, file string) ([]string, bool) {
+	for _, module := range modules {
+		rel, err := filepath.Rel(module.dir, file)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		segments := slices.Clone(module.segments)
+		if module.perFile {
+			segments = append(segments, strings.Split(filepath.ToSlash(strings.TrimSuffix(rel, ".selene")), "/")...)
+		}
+		return segments, true
+	}
+	return nil, false
+}
+
+// importStatement renders an import of segments, using a string path when a
+// segment is not an identifier.
+func importStatement(segments []string) string {
+	for _, segment := range segments {
+		if !lexer.IsIdentifier(segment) {
+			return "import " + strconv.Quote(strings.Join(segments, "/")) + ";"
+		}
+	}
+	return "import " + strings.Join(segments, ".") + ";"
+}
+
+// importEdit inserts statement after the last top-level import of doc, or
+// after its package declaration when it has no imports.
+func importEdit(doc *DocumentSnapshot, statement string) TextEdit {
+	var last, pkg ast.Node
+	for _, item := range doc.Program.Items {
+		switch node := item.(type) {
+		case *ast.ImportDeclaration:
+			last = node
+		case *ast.PackageDeclaration:
+			pkg = node
+		}
+	}
+	switch {
+	case last != nil:
+		pos := positionForByteOffset(doc.Text, last.End().Offset)
+		return TextEdit{Range: Range{Start: pos, End: pos}, NewText: "\n" + statement}
+	case pkg != nil:
+		pos := positionForByteOffset(doc.Text, pkg.End().Offset)
+		return TextEdit{Range: Range{Start: pos, End: pos}, NewText: "\n\n" + statement}
+	default:
+		return TextEdit{NewText: statement + "\n"}
+	}
+}
+
+func importedName(imp *ast.ImportDeclaration) string {
+	if imp.Alias != nil {
+		return imp.Alias.Name
+	}
+	if imp.PathLiteral != "" {
+		return imp.PathLiteral[strings.LastIndexByte(imp.PathLiteral, '/')+1:]
+	}
+	if len(imp.Path) == 0 {
+		return ""
+	}
+	return imp.Path[len(imp.Path)-1].Name
+}
+
+func completionKindForSymbol(kind int) int {
+	switch kind {
+	case symbolKindFunction:
+		return completionItemFunction
+	case symbolKindClass:
+		return completionItemClass
+	case symbolKindInterface:
+		return completionItemInterface
+	case symbolKindEnum:
+		return completionItemEnum
+	case symbolKindModule:
+		return completionItemModule
+	default:
+		return completionItemVariable
+	}
+}
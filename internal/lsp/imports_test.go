@@ -0,0 +1,142 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCodeActionOrganizesImports(t *testing.T) {
+	source := `package main
+
+import time;
+import fs as files;
+
+import path;
+import fs as files;
+
+fn main() {
+    print(path.join("a", files.exists("b")));
+}
+`
+	open, _ := json.Marshal(map[string]any{"textDocument": map[string]any{"uri": "untitled:demo", "version": 1, "text": source}})
+	input := frame(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":`+string(open)+`}`) +
+		frame(`{"jsonrpc":"2.0","id":1,"method":"textDocument/codeAction","params":{"textDocument":{"uri":"untitled:demo"},"context":{"diagnostics":[],"only":["source"]}}}`) +
+		frame(`{"jsonrpc":"2.0","id":2,"method":"textDocument/codeAction","params":{"textDocument":{"uri":"untitled:demo"},"context":{"diagnostics":[],"only":["quickfix"]}}}`)
+	var out bytes.Buffer
+	if err := NewServer(strings.NewReader(input), &out).Run(); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	// The first message published the diagnostics of the opened document.
+	replies := strings.Split(out.String(), "Content-Length: ")[2:]
+	if len(replies) != 2 {
+		t.Fatalf("expected two replies, got %q", out.String())
+	}
+	var actions []CodeAction
+	decodeResult(t, replies[0], &actions)
+	if len(actions) != 1 || actions[0].Kind != codeActionOrganizeImports || actions[0].Edit == nil {
+		t.Fatalf("expected an organize imports action, got %+v", actions)
+	}
+	want := `package main
+
+import fs as files;
+import path;
+
+fn main() {
+    print(path.join("a", files.exists("b")));
+}
+`
+	if got := applyEdits(t, source, actions[0].Edit.Changes["untitled:demo"]); got != want {
+		t.Fatalf("unexpected organized source:\n%s", got)
+	}
+	if edits := organizeImports(want); edits != nil {
+		t.Fatalf("expected organized imports to be left alone, got %+v", edits)
+	}
+	var none []CodeAction
+	decodeResult(t, replies[1], &none)
+	if len(none) != 0 {
+		t.Fatalf("expected no quick fixes, got %+v", none)
+	}
+}
+
+func TestCompletionSuggestsAutoImports(t *testing.T) {
+	root := t.TempDir()
+	writeWorkspaceFile(t, filepath.Join(root, "selene.toml"), `[project]
+name = "demo"
+version = "0.1.0"
+module = "example.com/demo"
+
+[dependencies]
+"acme/strs" = { version = "v1.0.0" }
+`)
+	writeWorkspaceFile(t, filepath.Join(root, "selene.lock"), `[[dependency]]
+module = "acme/strs"
+version = "v1.0.0"
+checksum = "abc123"
+vendor = "vendor/acme/strs"
+`)
+	writeWorkspaceFile(t, filepath.Join(root, "src", "utils", "math.selene"), "package utils;\n\nfn square(x: Number): Number => x * x;\nfn main() {}\n")
+	writeWorkspaceFile(t, filepath.Join(root, "src", "sibling.selene"), "package main;\n\nfn shared() => 1;\n")
+	writeWorkspaceFile(t, filepath.Join(root, "vendor", "acme", "strs", "lib.selene"), "fn upper(s: String): String => s;\nfn squash(s: String): String => s;\n")
+	mainPath := filepath.Join(root, "src", "main.selene")
+	source := "package main;\n\nlet x = sq\n"
+	writeWorkspaceFile(t, mainPath, source)
+
+	analyzer := NewAnalyzer(NewLinter())
+	index := NewWorkspaceIndex(analyzer)
+	if _, err := index.Refresh(root); err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	snapshot := NewDocumentStore(analyzer).Open(pathToURI(mainPath), 1, source)
+	list := NewCompleter().Completion(snapshot, Position{Line: 2, Character: 10}, autoImports(snapshot, index)...)
+	details := make(map[string]CompletionItem)
+	for _, item := range list.Items {
+		if strings.HasPrefix(item.Detail, "import ") {
+			details[item.Label] = item
+		}
+	}
+	labels := make([]string, 0, len(details))
+	for label := range details {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	if strings.Join(labels, ",") != "square,squash" {
+		t.Fatalf("expected squash and square to be suggested, got %v", labels)
+	}
+	if got := details["square"].Detail; got != `import "example.com/demo/utils/math/square";` {
+		t.Fatalf("unexpected project import %s", got)
+	}
+	if got := details["squash"].Detail; got != "import acme.strs.squash;" {
+		t.Fatalf("unexpected vendored import %s", got)
+	}
+	want := "package main;\n\nimport acme.strs.squash;\n\nlet x = sq\n"
+	if got := applyEdits(t, source, details["squash"].AdditionalTextEdits); got != want {
+		t.Fatalf("unexpected source after accepting the suggestion:\n%s", got)
+	}
+
+	imported := "package main;\n\nimport acme.strs.squash;\n\nlet x = sq\n"
+	snapshot = NewDocumentStore(analyzer).Open(pathToURI(mainPath), 2, imported)
+	items := autoImports(snapshot, index)
+	if len(items) != 2 || items[1].Label != "upper" || items[1].AdditionalTextEdits[0].NewText != "\nimport acme.strs.upper;" {
+		t.Fatalf("expected only the imports not yet made, after the existing import, got %+v", items)
+	}
+}
+
+// applyEdits applies non-overlapping edits to text.
+func applyEdits(t *testing.T, text string, edits []TextEdit) string {
+	t.Helper()
+	sorted := append([]TextEdit(nil), edits...)
+	sort.Slice(sorted, func(i, j int) bool { return comparePosition(sorted[i].Range.Start, sorted[j].Range.Start) > 0 })
+	for _, edit := range sorted {
+		start, ok := byteOffsetForPosition(text, edit.Range.Start)
+		end, ok2 := byteOffsetForPosition(text, edit.Range.End)
+		if !ok || !ok2 {
+			t.Fatalf("edit %+v is outside the document", edit)
+		}
+		text = text[:start] + edit.NewText + text[end:]
+	}
+	return text
+}
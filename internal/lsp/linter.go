@@ -1,24 +1,36 @@
 package lsp
 
 import (
-	"fmt"
 	"strings"
 	"unicode"
 
 	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/consteval"
+	"github.com/cybellereaper/selenelang/internal/i18n"
+	"github.com/cybellereaper/selenelang/internal/lexer"
 	"github.com/cybellereaper/selenelang/internal/token"
+	"github.com/cybellereaper/selenelang/internal/typecheck"
 )
 
 // Linter performs lightweight static checks on documents.
-type Linter struct{}
+type Linter struct {
+	messages *i18n.Printer
+}
 
-// NewLinter constructs a linter with default checks enabled.
+// NewLinter constructs a linter with default checks enabled. Its messages are
+// in the language SELENE_LANG selects.
 func NewLinter() *Linter {
-	return &Linter{}
+	return &Linter{messages: i18n.Default()}
 }
 
 // Lint executes the linter against the provided program, returning diagnostics.
+// It does not check identifiers, which needs to know how the file is run.
 func (l *Linter) Lint(text string, program *ast.Program, tokens []token.Token, symbols *SymbolIndex) []Diagnostic {
+	return l.lint(text, program, tokens, symbols, typecheck.Config{})
+}
+
+// lint is Lint with the type checker configured by types.
+func (l *Linter) lint(text string, program *ast.Program, tokens []token.Token, symbols *SymbolIndex, types typecheck.Config) []Diagnostic {
 	diagnostics := make([]Diagnostic, 0)
 	diagnostics = append(diagnostics, l.trailingWhitespace(text)...)
 	diagnostics = append(diagnostics, l.longLines(text)...)
@@ -26,6 +38,8 @@ func (l *Linter) Lint(text string, program *ast.Program, tokens []token.Token, s
 	diagnostics = append(diagnostics, l.todoComments(text)...)
 	diagnostics = append(diagnostics, l.unusedVariables(tokens, symbols)...)
 	diagnostics = append(diagnostics, l.functionsWithoutBody(symbols)...)
+	diagnostics = append(diagnostics, l.constantErrors(program)...)
+	diagnostics = append(diagnostics, l.typeErrors(program, types)...)
 	return diagnostics
 }
 
@@ -44,7 +58,8 @@ func (l *Linter) trailingWhitespace(text string) []Diagnostic {
 				},
 				Severity: severityWarning,
 				Source:   diagnosticSource,
-				Message:  "trailing whitespace",
+				Code:     string(i18n.LintTrailingWhitespace),
+				Message:  l.messages.Sprintf(i18n.LintTrailingWhitespace),
 			})
 		}
 	}
@@ -65,7 +80,8 @@ func (l *Linter) longLines(text string) []Diagnostic {
 				},
 				Severity: severityWarning,
 				Source:   diagnosticSource,
-				Message:  fmt.Sprintf("line exceeds %d characters (%d)", limit, runeCount),
+				Code:     string(i18n.LintLongLine),
+				Message:  l.messages.Sprintf(i18n.LintLongLine, limit, runeCount),
 			})
 		}
 	}
@@ -85,7 +101,8 @@ func (l *Linter) missingFinalNewline(text string) []Diagnostic {
 		Range:    Range{Start: pos, End: pos},
 		Severity: severityWarning,
 		Source:   diagnosticSource,
-		Message:  "file does not end with a newline",
+		Code:     string(i18n.LintMissingFinalNewline),
+		Message:  l.messages.Sprintf(i18n.LintMissingFinalNewline),
 	}}
 }
 
@@ -108,7 +125,8 @@ func (l *Linter) todoComments(text string) []Diagnostic {
 					},
 					Severity: severityWarning,
 					Source:   diagnosticSource,
-					Message:  "TODO comment",
+					Code:     string(i18n.LintTodoComment),
+					Message:  l.messages.Sprintf(i18n.LintTodoComment),
 				})
 			}
 		}
@@ -121,11 +139,7 @@ func (l *Linter) unusedVariables(tokens []token.Token, symbols *SymbolIndex) []D
 		return nil
 	}
 	counts := make(map[string]int)
-	for _, tok := range tokens {
-		if tok.Type == token.IDENT {
-			counts[tok.Literal]++
-		}
-	}
+	countIdentifiers(tokens, counts)
 	diags := make([]Diagnostic, 0)
 	for _, variable := range symbols.VariableSymbols {
 		name := variable.Name
@@ -140,13 +154,71 @@ func (l *Linter) unusedVariables(tokens []token.Token, symbols *SymbolIndex) []D
 				Range:    variable.Range,
 				Severity: severityWarning,
 				Source:   diagnosticSource,
-				Message:  fmt.Sprintf("variable %q declared but never used", name),
+				Code:     string(i18n.LintUnusedVariable),
+				Message:  l.messages.Sprintf(i18n.LintUnusedVariable, name),
 			})
 		}
 	}
 	return diags
 }
 
+// countIdentifiers counts the identifiers in tokens, including those in the
+// ${...} placeholders of string literals, which the runtime parses only when
+// the string is evaluated.
+func countIdentifiers(tokens []token.Token, counts map[string]int) {
+	for _, tok := range tokens {
+		switch tok.Type {
+		case token.IDENT:
+			counts[tok.Literal]++
+		case token.STRING, token.FORMATSTRING, token.RAWSTRING:
+			for _, placeholder := range placeholders(tok.Literal) {
+				var inner []token.Token
+				lex := lexer.New(placeholder)
+				for t := lex.NextToken(); t.Type != token.EOF; t = lex.NextToken() {
+					inner = append(inner, t)
+				}
+				countIdentifiers(inner, counts)
+			}
+		}
+	}
+}
+
+// placeholders returns the contents of the ${...} placeholders in the text
+// of a string literal. Escaped dollar signs do not start one.
+func placeholders(text string) []string {
+	var found []string
+	for i := 0; i+1 < len(text); i++ {
+		if text[i] == '\\' {
+			i++
+			continue
+		}
+		if text[i] != '$' || text[i+1] != '{' {
+			continue
+		}
+		depth := 1
+		for j := i + 2; j < len(text); j++ {
+			switch text[j] {
+			case '\\':
+				j++
+				continue
+			case '{':
+				depth++
+			case '}':
+				depth--
+			}
+			if depth == 0 {
+				found = append(found, text[i+2:j])
+				i = j
+				break
+			}
+		}
+		if depth > 0 {
+			break
+		}
+	}
+	return found
+}
+
 func (l *Linter) functionsWithoutBody(symbols *SymbolIndex) []Diagnostic {
 	if symbols == nil {
 		return nil
@@ -160,7 +232,104 @@ func (l *Linter) functionsWithoutBody(symbols *SymbolIndex) []Diagnostic {
 			Range:    fn.Range,
 			Severity: severityWarning,
 			Source:   diagnosticSource,
-			Message:  fmt.Sprintf("function %q has no implementation", fn.Name),
+			Code:     string(i18n.LintEmptyFunction),
+			Message:  l.messages.Sprintf(i18n.LintEmptyFunction, fn.Name),
+		})
+	}
+	return diags
+}
+
+// missingPackage reports a project file whose items do not start with a
+// package declaration, on its first item. An empty file is left alone.
+func (l *Linter) missingPackage(program *ast.Program) []Diagnostic {
+	if program == nil || len(program.Items) == 0 {
+		return nil
+	}
+	first := program.Items[0]
+	if _, ok := first.(*ast.PackageDeclaration); ok {
+		return nil
+	}
+	return []Diagnostic{{
+		Range:    rangeFromPositions(first.Pos(), first.Pos()),
+		Severity: severityWarning,
+		Source:   diagnosticSource,
+		Code:     string(i18n.LintMissingPackage),
+		Message:  l.messages.Sprintf(i18n.LintMissingPackage),
+	}}
+}
+
+// constantErrors reports constant expressions that fail whenever they run.
+// Overflow is only a warning because the runtime carries on with infinity.
+func (l *Linter) constantErrors(program *ast.Program) []Diagnostic {
+	if program == nil {
+		return nil
+	}
+	diags := make([]Diagnostic, 0)
+	for _, err := range consteval.Check(program) {
+		severity := severityError
+		var id i18n.MessageID
+		var args []any
+		switch err.Kind {
+		case consteval.DivisionByZero:
+			id = i18n.ConstDivisionByZero
+		case consteval.ModuloByZero:
+			id = i18n.ConstModuloByZero
+		case consteval.Overflow:
+			id, severity = i18n.ConstOverflow, severityWarning
+		default:
+			id, args = i18n.ConstInvalidOperation, []any{err.Message}
+		}
+		diags = append(diags, Diagnostic{
+			Range:    rangeFromNode(err.Node),
+			Severity: severity,
+			Source:   diagnosticSource,
+			Code:     string(id),
+			Message:  l.messages.Sprintf(id, args...),
+		})
+	}
+	return diags
+}
+
+// typeErrors reports what the type checker finds: generic types given the
+// wrong number of type arguments, calls whose arguments contradict the type
+// parameters they bind or do not match the callee's parameters, misused
+// abstract classes, bad overrides, undefined identifiers when types checks
+// them, and, as warnings, unreachable match arms and unused imports.
+func (l *Linter) typeErrors(program *ast.Program, types typecheck.Config) []Diagnostic {
+	if program == nil {
+		return nil
+	}
+	diags := make([]Diagnostic, 0)
+	for _, err := range types.Check(program).Errors {
+		severity := severityError
+		var id i18n.MessageID
+		var args []any
+		switch err.Kind {
+		case typecheck.TypeArgumentCount:
+			id, args = i18n.TypeArgumentCount, []any{err.Type, err.Want, err.Got}
+		case typecheck.Undefined:
+			id, args = i18n.TypeUndefined, []any{err.Name}
+		case typecheck.ArgumentCount:
+			id, args = i18n.TypeArity, []any{err.Name, err.Want, err.Got}
+		case typecheck.UnreachableArm:
+			id, args, severity = i18n.TypeUnreachableArm, []any{err.Message}, severityWarning
+		case typecheck.UnusedImport:
+			id, args, severity = i18n.TypeUnusedImport, []any{err.Name}, severityWarning
+		case typecheck.Abstract:
+			id, args = i18n.TypeAbstract, []any{err.Message}
+		case typecheck.Override:
+			id, args = i18n.TypeOverride, []any{err.Message}
+		case typecheck.Contract:
+			id, args = i18n.TypeContract, []any{err.Message}
+		default:
+			id, args = i18n.TypeMismatch, []any{err.Message}
+		}
+		diags = append(diags, Diagnostic{
+			Range:    rangeFromNode(err.Node),
+			Severity: severity,
+			Source:   diagnosticSource,
+			Code:     string(id),
+			Message:  l.messages.Sprintf(id, args...),
 		})
 	}
 	return diags
@@ -0,0 +1,478 @@
+package lsp
+
+import (
+	"slices"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+)
+
+const (
+	documentHighlightText  = 1
+	documentHighlightRead  = 2
+	documentHighlightWrite = 3
+)
+
+// DocumentHighlight marks one occurrence of the symbol under the cursor.
+type DocumentHighlight struct {
+	Range Range `json:"range"`
+	Kind  int   `json:"kind,omitempty"`
+}
+
+// binding is one declared name. Occurrences that resolve to the same binding
+// refer to the same symbol, however many other symbols share its name.
+type binding struct {
+	name string
+	// decl is the declaring node for hover: a declaration statement, an
+	// interface method, a parameterDecl, or an enumCaseDecl. It is nil for
+	// imports, loop and catch bindings, implicit names, and undeclared names.
+	decl any
+}
+
+// parameterDecl is a function parameter or a struct or class field.
+type parameterDecl struct {
+	param ast.Parameter
+	// owner is the declaring *ast.FunctionDeclaration, *ast.InterfaceMethod,
+	// *ast.StructDeclaration, or *ast.ClassDeclaration.
+	owner ast.Node
+}
+
+type enumCaseDecl struct {
+	enumCase *ast.EnumCase
+	enum     *ast.EnumDeclaration
+}
+
+type occurrence struct {
+	rng    Range
+	kind   int
+	target *binding
+}
+
+type occurrenceScope struct {
+	parent *occurrenceScope
+	names  map[string]*binding
+}
+
+func newOccurrenceScope(parent *occurrenceScope) *occurrenceScope {
+	return &occurrenceScope{parent: parent, names: make(map[string]*binding)}
+}
+
+// occurrenceResolver binds every identifier of a program to its declaration
+// following the evaluator's scoping rules: blocks, functions, loops, catch
+// clauses, and match cases open scopes, and a name resolves to the nearest
+// declaration that encloses it. Function bodies are resolved after the rest
+// of the program because they look names up when called, so they see
+// declarations that follow them. Names declared nowhere in the document, such
+// as builtins, share one binding per name.
+type occurrenceResolver struct {
+	occurrences []occurrence
+	globals     map[string]*binding
+	deferred    []func()
+}
+
+func resolveOccurrences(program *ast.Program) []occurrence {
+	if program == nil {
+		return nil
+	}
+	r := &occurrenceResolver{globals: make(map[string]*binding)}
+	scope := newOccurrenceScope(nil)
+	for _, item := range program.Items {
+		r.item(item, scope)
+	}
+	for len(r.deferred) > 0 {
+		next := r.deferred[0]
+		r.deferred = r.deferred[1:]
+		next()
+	}
+	return r.occurrences
+}
+
+// documentHighlights returns the occurrences of the symbol at pos in document
+// order, or nil when pos is not on an identifier.
+func documentHighlights(doc *DocumentSnapshot, pos Position) []DocumentHighlight {
+	if doc == nil {
+		return nil
+	}
+	occurrences := resolveOccurrences(doc.Program)
+	var target *binding
+	for _, occ := range occurrences {
+		if rangeContains(occ.rng, pos) {
+			target = occ.target
+			break
+		}
+	}
+	if target == nil {
+		return nil
+	}
+	highlights := make([]DocumentHighlight, 0)
+	for _, occ := range occurrences {
+		if occ.target == target {
+			highlights = append(highlights, DocumentHighlight{Range: occ.rng, Kind: occ.kind})
+		}
+	}
+	slices.SortFunc(highlights, func(a, b DocumentHighlight) int { return comparePosition(a.Range.Start, b.Range.Start) })
+	return highlights
+}
+
+func (r *occurrenceResolver) declare(id *ast.Identifier, decl any, scope *occurrenceScope) {
+	if id == nil || id.Name == "" {
+		return
+	}
+	b := &binding{name: id.Name, decl: decl}
+	scope.names[id.Name] = b
+	r.occurrences = append(r.occurrences, occurrence{rng: rangeFromIdentifier(id), kind: documentHighlightWrite, target: b})
+}
+
+// declareImplicit binds a name the evaluator introduces without an
+// identifier in the source, such as this inside a class body.
+func (r *occurrenceResolver) declareImplicit(name string, scope *occurrenceScope) {
+	scope.names[name] = &binding{name: name}
+}
+
+func (r *occurrenceResolver) reference(id *ast.Identifier, kind int, scope *occurrenceScope) {
+	if id == nil || id.Name == "" {
+		return
+	}
+	r.occurrences = append(r.occurrences, occurrence{rng: rangeFromIdentifier(id), kind: kind, target: r.lookup(id.Name, scope)})
+}
+
+func (r *occurrenceResolver) lookup(name string, scope *occurrenceScope) *binding {
+	for s := scope; s != nil; s = s.parent {
+		if b, ok := s.names[name]; ok {
+			return b
+		}
+	}
+	b, ok := r.globals[name]
+	if !ok {
+		b = &binding{name: name}
+		r.globals[name] = b
+	}
+	return b
+}
+
+func (r *occurrenceResolver) item(item ast.ProgramItem, scope *occurrenceScope) {
+	switch node := item.(type) {
+	case *ast.ImportDeclaration:
+		if node.Alias != nil {
+			r.declare(node.Alias, nil, scope)
+		} else if len(node.Path) > 0 && node.PathLiteral == "" {
+			r.declare(node.Path[len(node.Path)-1], nil, scope)
+		}
+	case *ast.ModuleDeclaration:
+		r.declare(node.Name, node, scope)
+		if node.Body != nil {
+			r.statements(node.Body.Statements, newOccurrenceScope(scope))
+		}
+	case *ast.PackageDeclaration:
+	case ast.Statement:
+		r.statement(node, scope)
+	}
+}
+
+func (r *occurrenceResolver) statements(stmts []ast.Statement, scope *occurrenceScope) {
+	for _, stmt := range stmts {
+		r.statement(stmt, scope)
+	}
+}
+
+func (r *occurrenceResolver) block(block *ast.BlockStatement, scope *occurrenceScope) {
+	if block != nil {
+		r.statements(block.Statements, newOccurrenceScope(scope))
+	}
+}
+
+func (r *occurrenceResolver) body(stmt ast.Statement, scope *occurrenceScope) {
+	if block, ok := stmt.(*ast.BlockStatement); ok {
+		r.block(block, scope)
+		return
+	}
+	if stmt != nil {
+		r.statement(stmt, newOccurrenceScope(scope))
+	}
+}
+
+func (r *occurrenceResolver) statement(stmt ast.Statement, scope *occurrenceScope) {
+	for _, a := range ast.Annotations(stmt) {
+		for _, arg := range a.Arguments {
+			r.expression(arg, scope)
+		}
+	}
+	switch node := stmt.(type) {
+	case *ast.BlockStatement:
+		r.block(node, scope)
+	case *ast.ExpressionStatement:
+		r.expression(node.Expression, scope)
+	case *ast.VariableDeclaration:
+		r.typeAnnotation(node.Type, scope)
+		r.expression(node.Value, scope)
+		if node.Pattern != nil {
+			r.pattern(node.Pattern, scope)
+		} else {
+			r.declare(node.Name, node, scope)
+		}
+	case *ast.FunctionDeclaration:
+		r.function(node, scope)
+	case *ast.IfStatement:
+		r.expression(node.Condition, scope)
+		r.body(node.Consequence, scope)
+		r.body(node.Alternative, scope)
+	case *ast.WhileStatement:
+		r.expression(node.Condition, scope)
+		r.body(node.Body, scope)
+	case *ast.DoWhileStatement:
+		r.block(node.Body, scope)
+		r.expression(node.Condition, scope)
+	case *ast.ForStatement:
+		loop := newOccurrenceScope(scope)
+		if node.Init != nil {
+			r.statement(node.Init, loop)
+		}
+		r.expression(node.Condition, loop)
+		r.expression(node.Post, loop)
+		r.body(node.Body, loop)
+	case *ast.ForInStatement:
+		r.expression(node.Iterable, scope)
+		loop := newOccurrenceScope(scope)
+		r.declare(node.Binding, nil, loop)
+		r.block(node.Body, loop)
+	case *ast.ReturnStatement:
+		r.expression(node.Value, scope)
+	case *ast.ThrowStatement:
+		r.expression(node.Value, scope)
+	case *ast.UsingStatement:
+		r.expression(node.Value, scope)
+		inner := newOccurrenceScope(scope)
+		r.declare(node.Name, nil, inner)
+		r.block(node.Body, inner)
+	case *ast.TryStatement:
+		r.block(node.Body, scope)
+		for _, clause := range node.Catches {
+			r.typeAnnotation(clause.Type, scope)
+			catch := newOccurrenceScope(scope)
+			r.declare(clause.Identifier, nil, catch)
+			r.block(clause.Body, catch)
+		}
+		r.block(node.Finally, scope)
+	case *ast.ConditionStatement:
+		for _, clause := range node.Clauses {
+			r.expression(clause.Test, scope)
+			r.body(clause.Body, scope)
+		}
+		r.body(node.Else, scope)
+	case *ast.MatchStatement:
+		r.expression(node.Value, scope)
+		for _, c := range node.Cases {
+			arm := newOccurrenceScope(scope)
+			for i, pattern := range c.Patterns {
+				if i == 0 {
+					r.pattern(pattern, arm)
+				} else {
+					r.alternative(pattern, arm)
+				}
+			}
+			r.body(c.Body, arm)
+		}
+		r.body(node.Else, scope)
+	case *ast.ClassDeclaration:
+		r.declare(node.Name, node, scope)
+		r.reference(node.SuperClass, documentHighlightRead, scope)
+		inner := newOccurrenceScope(scope)
+		for _, param := range node.TypeParams {
+			r.declare(param, nil, inner)
+		}
+		r.typeBody(node, node.Params, node.Body, inner)
+	case *ast.StructDeclaration:
+		r.declare(node.Name, node, scope)
+		r.typeBody(node, node.Params, node.Body, scope)
+	case *ast.EnumDeclaration:
+		r.declare(node.Name, node, scope)
+		inner := newOccurrenceScope(scope)
+		for _, param := range node.TypeParams {
+			r.declare(param, nil, inner)
+		}
+		for i := range node.Cases {
+			c := &node.Cases[i]
+			r.declare(c.Name, enumCaseDecl{enumCase: c, enum: node}, inner)
+			for _, param := range c.Params {
+				r.typeAnnotation(param.Type, inner)
+			}
+		}
+	case *ast.InterfaceDeclaration:
+		r.declare(node.Name, node, scope)
+		for i := range node.Methods {
+			method := &node.Methods[i]
+			inner := newOccurrenceScope(scope)
+			if method.Default != nil {
+				r.declareImplicit("this", inner)
+				r.function(method.Default, inner)
+				continue
+			}
+			r.declare(method.Name, method, inner)
+			for _, param := range method.Params {
+				r.typeAnnotation(param.Type, inner)
+				r.declare(param.Name, parameterDecl{param: param, owner: method}, inner)
+			}
+			r.typeAnnotation(method.ReturnType, inner)
+		}
+	case *ast.ImplDeclaration:
+		r.reference(node.Interface, documentHighlightRead, scope)
+		r.reference(node.Target, documentHighlightRead, scope)
+		r.typeBody(node, nil, node.Body, scope)
+	case *ast.ContractDeclaration:
+		r.declare(node.Name, node, scope)
+		r.block(node.Body, scope)
+	}
+}
+
+func (r *occurrenceResolver) typeBody(owner ast.Node, params []ast.Parameter, body *ast.BlockStatement, scope *occurrenceScope) {
+	inner := newOccurrenceScope(scope)
+	r.declareImplicit("this", inner)
+	for _, param := range params {
+		r.typeAnnotation(param.Type, inner)
+		r.declare(param.Name, parameterDecl{param: param, owner: owner}, inner)
+	}
+	if body != nil {
+		r.statements(body.Statements, inner)
+	}
+}
+
+func (r *occurrenceResolver) function(fn *ast.FunctionDeclaration, scope *occurrenceScope) {
+	inner := newOccurrenceScope(scope)
+	if fn.IsExtension {
+		// Extensions are attached to the receiver type rather than bound by
+		// name, so they do not resolve from or shadow plain identifiers.
+		r.typeAnnotation(fn.Receiver, scope)
+		r.declare(fn.Name, fn, newOccurrenceScope(scope))
+		r.declareImplicit("this", inner)
+	} else {
+		r.declare(fn.Name, fn, scope)
+	}
+	for _, param := range fn.TypeParams {
+		r.declare(param, nil, inner)
+	}
+	for _, param := range fn.Params {
+		r.typeAnnotation(param.Type, inner)
+		r.declare(param.Name, parameterDecl{param: param, owner: fn}, inner)
+	}
+	r.typeAnnotation(fn.ReturnType, inner)
+	r.deferred = append(r.deferred, func() {
+		if fn.Contract != nil {
+			contract := newOccurrenceScope(inner)
+			r.declareImplicit("result", contract)
+			for _, clause := range fn.Contract.Clauses {
+				r.expression(clause.Guard, contract)
+				r.expression(clause.Condition, contract)
+			}
+		}
+		if fn.Body != nil {
+			r.statements(fn.Body.Statements, inner)
+		}
+		r.expression(fn.BodyExpr, inner)
+	})
+}
+
+func (r *occurrenceResolver) typeAnnotation(t *ast.TypeAnnotation, scope *occurrenceScope) {
+	if t == nil {
+		return
+	}
+	r.reference(t.Name, documentHighlightRead, scope)
+	for _, arg := range t.TypeArgs {
+		r.typeAnnotation(arg, scope)
+	}
+}
+
+func (r *occurrenceResolver) pattern(p ast.Pattern, scope *occurrenceScope) {
+	switch node := p.(type) {
+	case *ast.IdentifierPattern:
+		r.declare(node.Identifier, nil, scope)
+	case *ast.LiteralPattern:
+		r.expression(node.Value, scope)
+	case *ast.ObjectPattern:
+		for _, pair := range node.Pairs {
+			r.pattern(pair.Value, scope)
+		}
+	case *ast.StructPattern:
+		r.reference(node.Name, documentHighlightRead, scope)
+		for _, field := range node.Fields {
+			r.pattern(field, scope)
+		}
+	case *ast.ArrayPattern:
+		for _, el := range node.Elements {
+			r.pattern(el, scope)
+		}
+		r.declare(node.Rest, nil, scope)
+	}
+}
+
+// alternative resolves a later pattern of a match arm, whose names are the
+// ones the arm's first pattern declared in scope.
+func (r *occurrenceResolver) alternative(p ast.Pattern, scope *occurrenceScope) {
+	start := len(r.occurrences)
+	alt := newOccurrenceScope(scope)
+	r.pattern(p, alt)
+	for i := start; i < len(r.occurrences); i++ {
+		b := r.occurrences[i].target
+		if shared, ok := scope.names[b.name]; ok && alt.names[b.name] == b {
+			r.occurrences[i].target = shared
+		}
+	}
+}
+
+func (r *occurrenceResolver) expression(expr ast.Expression, scope *occurrenceScope) {
+	switch node := expr.(type) {
+	case *ast.Identifier:
+		r.reference(node, documentHighlightRead, scope)
+	case *ast.ArrayLiteral:
+		for _, element := range node.Elements {
+			r.expression(element, scope)
+		}
+	case *ast.SetLiteral:
+		for _, element := range node.Elements {
+			r.expression(element, scope)
+		}
+	case *ast.ObjectLiteral:
+		for _, pair := range node.Pairs {
+			r.expression(pair.Value, scope)
+		}
+	case *ast.AwaitExpression:
+		r.expression(node.Expression, scope)
+	case *ast.OldExpression:
+		r.expression(node.Value, scope)
+	case *ast.YieldExpression:
+		r.expression(node.Value, scope)
+	case *ast.PrefixExpression:
+		r.expression(node.Right, scope)
+	case *ast.InfixExpression:
+		r.expression(node.Left, scope)
+		r.expression(node.Right, scope)
+	case *ast.AssignmentExpression:
+		r.expression(node.Value, scope)
+		if target, ok := node.Target.(*ast.Identifier); ok {
+			r.reference(target, documentHighlightWrite, scope)
+		} else {
+			r.expression(node.Target, scope)
+		}
+	case *ast.ElvisExpression:
+		r.expression(node.Left, scope)
+		r.expression(node.Right, scope)
+	case *ast.CallExpression:
+		r.expression(node.Callee, scope)
+		for _, arg := range node.Arguments {
+			r.expression(arg, scope)
+		}
+	case *ast.IndexExpression:
+		r.expression(node.Collection, scope)
+		r.expression(node.Index, scope)
+	case *ast.MemberExpression:
+		r.expression(node.Object, scope)
+	case *ast.NonNullAssertion:
+		r.expression(node.Expression, scope)
+	case *ast.PropagateExpression:
+		r.expression(node.Expression, scope)
+	case *ast.IncrementExpression:
+		if target, ok := node.Target.(*ast.Identifier); ok {
+			r.reference(target, documentHighlightWrite, scope)
+		} else {
+			r.expression(node.Target, scope)
+		}
+	}
+}
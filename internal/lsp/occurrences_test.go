@@ -0,0 +1,44 @@
+package lsp
+
+import "testing"
+
+func TestDocumentHighlightsResolveScopes(t *testing.T) {
+	source := "var count = 0;\n" +
+		"fn bump(step: Number) {\n" +
+		"    count = count + step;\n" +
+		"    let count = 10;\n" +
+		"    print(count);\n" +
+		"}\n" +
+		"fn other(count: Number) {\n" +
+		"    print(count);\n" +
+		"}\n"
+	docs := NewDocumentStore(NewAnalyzer(NewLinter()))
+	snapshot := docs.Open("file:///highlight.sel", 1, source)
+
+	highlights := documentHighlights(snapshot, Position{Line: 0, Character: 5})
+	want := []DocumentHighlight{
+		{Range: Range{Start: Position{Line: 0, Character: 4}, End: Position{Line: 0, Character: 9}}, Kind: documentHighlightWrite},
+		{Range: Range{Start: Position{Line: 2, Character: 4}, End: Position{Line: 2, Character: 9}}, Kind: documentHighlightWrite},
+		{Range: Range{Start: Position{Line: 2, Character: 12}, End: Position{Line: 2, Character: 17}}, Kind: documentHighlightRead},
+	}
+	if len(highlights) != len(want) {
+		t.Fatalf("expected %d highlights for the global, got %v", len(want), highlights)
+	}
+	for i := range want {
+		if highlights[i] != want[i] {
+			t.Fatalf("highlight %d: expected %v, got %v", i, want[i], highlights[i])
+		}
+	}
+
+	local := documentHighlights(snapshot, Position{Line: 4, Character: 11})
+	if len(local) != 2 || local[0].Range.Start.Line != 3 || local[1].Range.Start.Line != 4 {
+		t.Fatalf("expected the shadowing local and its use, got %v", local)
+	}
+	param := documentHighlights(snapshot, Position{Line: 7, Character: 11})
+	if len(param) != 2 || param[0].Range.Start.Line != 6 || param[0].Kind != documentHighlightWrite || param[1].Kind != documentHighlightRead {
+		t.Fatalf("expected the parameter and its use, got %v", param)
+	}
+	if got := documentHighlights(snapshot, Position{Line: 1, Character: 0}); got != nil {
+		t.Fatalf("expected no highlights off an identifier, got %v", got)
+	}
+}
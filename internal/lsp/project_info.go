@@ -0,0 +1,166 @@
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/cybellereaper/selenelang/internal/project"
+)
+
+// methodProjectInfo is a Selene extension to the protocol. Editor extensions
+// call it to build project views and run commands from the same manifest data
+// the CLI uses, rather than parsing selene.toml themselves.
+const methodProjectInfo = "selene/projectInfo"
+
+// ProjectInfo is the result of a selene/projectInfo request. Outside a
+// project only ToolchainVersion is set.
+type ProjectInfo struct {
+	ToolchainVersion string `json:"toolchainVersion"`
+	// Root is the workspace root: the directory holding selene.lock and
+	// vendor/, which for a single package is its own directory.
+	Root     string        `json:"root,omitempty"`
+	RootURI  string        `json:"rootUri,omitempty"`
+	Packages []PackageInfo `json:"packages"`
+	// Dependencies combines the requirements of every package with what
+	// selene.lock pinned for them.
+	Dependencies []DependencyInfo `json:"dependencies"`
+}
+
+// PackageInfo describes one package of the workspace.
+type PackageInfo struct {
+	// Path is the package directory relative to the root, with forward
+	// slashes, or "." for the root package.
+	Path    string `json:"path"`
+	URI     string `json:"uri"`
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+	Module  string `json:"module,omitempty"`
+	Entry   string `json:"entry,omitempty"`
+	// EntryURI locates Entry, which the manifest gives relative to the
+	// package directory.
+	EntryURI string `json:"entryUri,omitempty"`
+	// ExampleRoots are the directories selene test and selene examples
+	// search, relative to the package directory.
+	ExampleRoots []string `json:"exampleRoots"`
+	Profiles     []string `json:"profiles"`
+	// Requires lists the modules this package's manifest depends on.
+	Requires []string `json:"requires"`
+}
+
+// DependencyInfo describes one required module.
+type DependencyInfo struct {
+	Module  string `json:"module"`
+	Version string `json:"version"`
+	Source  string `json:"source,omitempty"`
+	// Locked, Checksum, and Vendor come from selene.lock and are empty for
+	// requirements that were never locked.
+	Locked   string `json:"locked,omitempty"`
+	Checksum string `json:"checksum,omitempty"`
+	Vendor   string `json:"vendor,omitempty"`
+}
+
+// SetVersion records the toolchain version selene/projectInfo reports.
+func (s *Server) SetVersion(version string) {
+	s.version = version
+}
+
+func (s *Server) handleProjectInfo(msg requestMessage) error {
+	var params struct {
+		// URI optionally names a document whose project is wanted instead
+		// of the one the session was started in.
+		URI string `json:"uri"`
+	}
+	if len(msg.Params) > 0 && json.Unmarshal(msg.Params, &params) != nil {
+		return s.conn.ReplyError(msg.ID, -32602, "invalid params")
+	}
+	start := s.root
+	if path, ok := uriToPath(params.URI); ok {
+		start = path
+	}
+	info, err := loadProjectInfo(start)
+	if err != nil {
+		return s.conn.ReplyError(msg.ID, -32603, err.Error())
+	}
+	info.ToolchainVersion = s.version
+	return s.conn.Reply(msg.ID, info)
+}
+
+// loadProjectInfo describes the workspace enclosing start. A start outside
+// any project yields an empty description.
+func loadProjectInfo(start string) (ProjectInfo, error) {
+	info := ProjectInfo{Packages: []PackageInfo{}, Dependencies: []DependencyInfo{}}
+	if start == "" {
+		return info, nil
+	}
+	root, err := project.FindWorkspaceRoot(start)
+	if errors.Is(err, fs.ErrNotExist) {
+		return info, nil
+	}
+	if err != nil {
+		return info, err
+	}
+	ws, err := project.LoadWorkspace(root)
+	if err != nil {
+		return info, err
+	}
+	deps, err := ws.Dependencies()
+	if err != nil {
+		return info, err
+	}
+	lock, err := project.LoadLockfile(ws.Root)
+	if err != nil {
+		return info, err
+	}
+	info.Root, info.RootURI = ws.Root, pathToURI(ws.Root)
+	for _, member := range ws.Members {
+		info.Packages = append(info.Packages, packageInfo(member))
+	}
+	locked := make(map[string]project.LockedDependency, len(lock.Dependencies))
+	for _, entry := range lock.Dependencies {
+		locked[entry.Module] = entry
+	}
+	for _, module := range project.SortedModules(deps) {
+		dep := deps[module]
+		entry := locked[module]
+		info.Dependencies = append(info.Dependencies, DependencyInfo{
+			Module:   module,
+			Version:  dep.Version,
+			Source:   dep.Source,
+			Locked:   entry.Version,
+			Checksum: entry.Checksum,
+			Vendor:   entry.Vendor,
+		})
+	}
+	return info, nil
+}
+
+func packageInfo(member project.Member) PackageInfo {
+	manifest := member.Manifest
+	pkg := PackageInfo{
+		Path:     member.Path,
+		URI:      pathToURI(member.Dir),
+		Name:     manifest.Project.Name,
+		Version:  manifest.Project.Version,
+		Module:   manifest.Project.Module,
+		Entry:    manifest.Project.Entry,
+		Requires: project.SortedModules(manifest.Dependencies),
+	}
+	if pkg.Entry != "" {
+		pkg.EntryURI = pathToURI(filepath.Join(member.Dir, filepath.FromSlash(pkg.Entry)))
+	}
+	// Like selene test, fall back to examples/ when the manifest names no roots.
+	pkg.ExampleRoots = slices.Clone(manifest.Examples.Roots)
+	if len(pkg.ExampleRoots) == 0 {
+		pkg.ExampleRoots = []string{"examples"}
+	}
+	pkg.Profiles = make([]string, 0, len(manifest.Profiles))
+	for name := range manifest.Profiles {
+		pkg.Profiles = append(pkg.Profiles, name)
+	}
+	sort.Strings(pkg.Profiles)
+	return pkg
+}
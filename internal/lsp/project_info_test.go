@@ -0,0 +1,84 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProjectInfoDescribesTheWorkspace(t *testing.T) {
+	root := t.TempDir()
+	writeWorkspaceFile(t, filepath.Join(root, "selene.toml"), `[project]
+name = "demo"
+version = "0.1.0"
+module = "example.com/demo"
+entry = "src/main.selene"
+
+[examples]
+roots = ["samples"]
+
+[profiles.release]
+backend = "vm"
+
+[dependencies]
+"example.com/math" = { version = "v1.0.0" }
+`)
+	writeWorkspaceFile(t, filepath.Join(root, "selene.lock"), `[[dependency]]
+module = "example.com/math"
+version = "v1.0.0"
+checksum = "abc123"
+vendor = "vendor/example.com/math"
+`)
+	writeWorkspaceFile(t, filepath.Join(root, "src", "main.selene"), "package main\n")
+	params, _ := json.Marshal(map[string]string{"uri": pathToURI(filepath.Join(root, "src", "main.selene"))})
+	input := frame(`{"jsonrpc":"2.0","id":1,"method":"selene/projectInfo","params":`+string(params)+`}`) +
+		frame(`{"jsonrpc":"2.0","id":2,"method":"selene/projectInfo"}`)
+	var out bytes.Buffer
+	server := NewServer(strings.NewReader(input), &out)
+	server.SetVersion("v1.2.3")
+	if err := server.Run(); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	replies := strings.Split(out.String(), "Content-Length: ")[1:]
+	if len(replies) != 2 {
+		t.Fatalf("expected two replies, got %q", out.String())
+	}
+	var info ProjectInfo
+	decodeResult(t, replies[0], &info)
+	if info.ToolchainVersion != "v1.2.3" || info.RootURI != pathToURI(root) || len(info.Packages) != 1 {
+		t.Fatalf("unexpected project info %+v", info)
+	}
+	pkg := info.Packages[0]
+	if pkg.Name != "demo" || pkg.Path != "." || pkg.EntryURI != pathToURI(filepath.Join(root, "src", "main.selene")) {
+		t.Fatalf("unexpected package %+v", pkg)
+	}
+	if strings.Join(pkg.ExampleRoots, ",") != "samples" || strings.Join(pkg.Profiles, ",") != "release" || strings.Join(pkg.Requires, ",") != "example.com/math" {
+		t.Fatalf("unexpected package %+v", pkg)
+	}
+	if len(info.Dependencies) != 1 || info.Dependencies[0].Locked != "v1.0.0" || info.Dependencies[0].Checksum != "abc123" {
+		t.Fatalf("unexpected dependencies %+v", info.Dependencies)
+	}
+
+	// Without initialize or a document the session has no project.
+	var empty ProjectInfo
+	decodeResult(t, replies[1], &empty)
+	if empty.ToolchainVersion != "v1.2.3" || empty.Root != "" || len(empty.Packages) != 0 {
+		t.Fatalf("expected no project, got %+v", empty)
+	}
+}
+
+func decodeResult(t *testing.T, framed string, into any) {
+	t.Helper()
+	var reply responseMessage
+	if err := json.Unmarshal([]byte(framed[strings.Index(framed, "{"):]), &reply); err != nil {
+		t.Fatalf("decode reply: %v", err)
+	}
+	if reply.Error != nil || reply.Result == nil {
+		t.Fatalf("expected a result, got %+v", reply)
+	}
+	if err := json.Unmarshal(*reply.Result, into); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+}
@@ -12,19 +12,44 @@ type Range struct {
 	End   Position `json:"end"`
 }
 
-// Diagnostic describes a problem detected in a document.
+// Diagnostic describes a problem detected in a document. Code, when set, is
+// the i18n message ID of Message and stays the same in every language.
 type Diagnostic struct {
 	Range    Range  `json:"range"`
 	Severity int    `json:"severity,omitempty"`
+	Code     string `json:"code,omitempty"`
 	Source   string `json:"source,omitempty"`
 	Message  string `json:"message"`
 }
 
 const (
-	severityError   = 1
-	severityWarning = 2
+	severityError       = 1
+	severityWarning     = 2
+	severityInformation = 3
+	severityHint        = 4
 )
 
+// IsError reports whether d has error severity rather than being a warning
+// or hint.
+func (d Diagnostic) IsError() bool {
+	return d.Severity == severityError
+}
+
+// SeverityName returns the severity of d as "error", "warning",
+// "information", or "hint".
+func (d Diagnostic) SeverityName() string {
+	switch d.Severity {
+	case severityError:
+		return "error"
+	case severityInformation:
+		return "information"
+	case severityHint:
+		return "hint"
+	default:
+		return "warning"
+	}
+}
+
 // CompletionItem represents a single completion suggestion.
 type CompletionItem struct {
 	Label            string `json:"label"`
@@ -32,6 +57,9 @@ type CompletionItem struct {
 	Detail           string `json:"detail,omitempty"`
 	InsertText       string `json:"insertText,omitempty"`
 	InsertTextFormat int    `json:"insertTextFormat,omitempty"`
+	// AdditionalTextEdits are applied alongside the completion, such as the
+	// import an auto-import suggestion adds.
+	AdditionalTextEdits []TextEdit `json:"additionalTextEdits,omitempty"`
 }
 
 // CompletionList is returned from completion requests.
@@ -79,6 +107,21 @@ type TextEdit struct {
 	NewText string `json:"newText"`
 }
 
+// WorkspaceEdit groups text edits by the URI of the document they change.
+type WorkspaceEdit struct {
+	Changes map[string][]TextEdit `json:"changes"`
+}
+
+// CodeAction is a command the client can offer for a document, carried out
+// by applying Edit.
+type CodeAction struct {
+	Title string         `json:"title"`
+	Kind  string         `json:"kind,omitempty"`
+	Edit  *WorkspaceEdit `json:"edit,omitempty"`
+}
+
+const codeActionOrganizeImports = "source.organizeImports"
+
 // DocumentSymbol describes a named construct and its hierarchy within a document.
 type DocumentSymbol struct {
 	Name           string           `json:"name"`
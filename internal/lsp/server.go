@@ -9,43 +9,62 @@ import (
 	"sync/atomic"
 
 	"github.com/cybellereaper/selenelang/internal/format"
+	"github.com/cybellereaper/selenelang/internal/project"
 )
 
 // Server implements the Selene language server protocol surface.
 type Server struct {
 	conn         *jsonRPCConnection
+	analyzer     *Analyzer
 	documents    *DocumentStore
 	completer    *Completer
 	highlighter  *Highlighter
+	index        *WorkspaceIndex
+	log          *serverLog
 	shuttingDown int32
+	// root is the directory the session indexes, from initialize.
+	root string
+	// version is the toolchain version reported to clients.
+	version string
 }
 
 // NewServer wires together the JSON-RPC transport and language features.
 func NewServer(r io.Reader, w io.Writer) *Server {
 	analyzer := NewAnalyzer(nil)
+	log := &serverLog{}
+	conn := newJSONRPCConnection(r, w)
+	conn.log = log
 	return &Server{
-		conn:        newJSONRPCConnection(r, w),
+		conn:        conn,
+		analyzer:    analyzer,
 		documents:   NewDocumentStore(analyzer),
 		completer:   NewCompleter(),
 		highlighter: NewHighlighter(),
+		index:       NewWorkspaceIndex(analyzer),
+		log:         log,
+		version:     "dev",
 	}
 }
 
 // Run processes incoming requests until the client disconnects.
 func (s *Server) Run() error {
+	s.log.printf("selene language server started")
 	for {
 		msg, err := s.conn.Read()
 		if err != nil {
 			if errors.Is(err, io.EOF) {
+				s.log.printf("client closed the connection")
 				return nil
 			}
 			var syntaxErr *json.SyntaxError
 			if errors.As(err, &syntaxErr) {
+				s.log.printf("skipping malformed message: %v", err)
 				continue
 			}
 			if errors.Is(err, errClientExit) {
 				return nil
 			}
+			s.log.printf("stopping: %v", err)
 			return err
 		}
 		if msg.Method == "" {
@@ -53,8 +72,10 @@ func (s *Server) Run() error {
 		}
 		if err := s.dispatch(msg); err != nil {
 			if errors.Is(err, errClientExit) {
+				s.log.printf("client requested exit")
 				return nil
 			}
+			s.log.printf("stopping after %s: %v", msg.Method, err)
 			return err
 		}
 	}
@@ -71,16 +92,21 @@ const (
 	methodDidSave                = "textDocument/didSave"
 	methodCompletion             = "textDocument/completion"
 	methodHover                  = "textDocument/hover"
+	methodDocumentHighlight      = "textDocument/documentHighlight"
 	methodDocumentSymbol         = "textDocument/documentSymbol"
 	methodWorkspaceSymbol        = "workspace/symbol"
 	methodDocumentFormat         = "textDocument/formatting"
+	methodRangeFormat            = "textDocument/rangeFormatting"
+	methodOnTypeFormat           = "textDocument/onTypeFormatting"
 	methodSemanticTokensFull     = "textDocument/semanticTokens/full"
 	methodSemanticTokensRange    = "textDocument/semanticTokens/range"
 	methodDidChangeConfiguration = "workspace/didChangeConfiguration"
 	methodDidChangeWatchedFiles  = "workspace/didChangeWatchedFiles"
+	methodCodeAction             = "textDocument/codeAction"
 )
 
-func (s *Server) dispatch(msg requestMessage) error {
+func (s *Server) dispatch(msg requestMessage) (err error) {
+	defer s.recoverHandler(msg, &err)
 	switch msg.Method {
 	case methodInitialize:
 		return s.handleInitialize(msg)
@@ -105,18 +131,30 @@ func (s *Server) dispatch(msg requestMessage) error {
 		return s.handleCompletion(msg)
 	case methodHover:
 		return s.handleHover(msg)
+	case methodDocumentHighlight:
+		return s.handleDocumentHighlight(msg)
 	case methodDocumentSymbol:
 		return s.handleDocumentSymbol(msg)
 	case methodWorkspaceSymbol:
 		return s.handleWorkspaceSymbol(msg)
 	case methodDocumentFormat:
 		return s.handleDocumentFormatting(msg)
+	case methodRangeFormat:
+		return s.handleRangeFormatting(msg)
+	case methodOnTypeFormat:
+		return s.handleOnTypeFormatting(msg)
 	case methodSemanticTokensFull:
 		return s.handleSemanticTokensFull(msg)
 	case methodSemanticTokensRange:
 		return s.handleSemanticTokensRange(msg)
-	case methodDidChangeConfiguration, methodDidChangeWatchedFiles:
+	case methodDidChangeConfiguration:
+		return s.handleDidChangeConfiguration(msg)
+	case methodDidChangeWatchedFiles:
 		return nil
+	case methodCodeAction:
+		return s.handleCodeAction(msg)
+	case methodProjectInfo:
+		return s.handleProjectInfo(msg)
 	default:
 		if len(msg.ID) > 0 {
 			return s.conn.ReplyError(msg.ID, -32601, fmt.Sprintf("method %s not found", msg.Method))
@@ -127,10 +165,25 @@ func (s *Server) dispatch(msg requestMessage) error {
 
 func (s *Server) handleInitialize(msg requestMessage) error {
 	var params struct {
-		Capabilities map[string]any `json:"capabilities"`
-		ClientInfo   map[string]any `json:"clientInfo"`
+		Capabilities     map[string]any `json:"capabilities"`
+		ClientInfo       map[string]any `json:"clientInfo"`
+		RootURI          string         `json:"rootUri"`
+		RootPath         string         `json:"rootPath"`
+		WorkspaceFolders []struct {
+			URI string `json:"uri"`
+		} `json:"workspaceFolders"`
 	}
 	_ = json.Unmarshal(msg.Params, &params)
+	candidates := []string{params.RootURI}
+	for _, folder := range params.WorkspaceFolders {
+		candidates = append(candidates, folder.URI)
+	}
+	if root := workspaceRoot(params.RootPath, candidates); root != "" {
+		s.root = root
+		// A broken or unwritable cache must not prevent the session from starting;
+		// workspace symbols simply fall back to whatever could be indexed.
+		_, _ = s.index.Refresh(root)
+	}
 	tokenTypes, tokenModifiers := s.highlighter.Legend()
 	result := map[string]any{
 		"capabilities": map[string]any{
@@ -144,10 +197,19 @@ func (s *Server) handleInitialize(msg requestMessage) error {
 			"completionProvider": map[string]any{
 				"triggerCharacters": []string{".", ":", "@", "(", ">"},
 			},
-			"hoverProvider":              true,
-			"documentSymbolProvider":     true,
-			"workspaceSymbolProvider":    true,
-			"documentFormattingProvider": true,
+			"hoverProvider":                   true,
+			"documentHighlightProvider":       true,
+			"documentSymbolProvider":          true,
+			"workspaceSymbolProvider":         true,
+			"documentFormattingProvider":      true,
+			"documentRangeFormattingProvider": true,
+			"codeActionProvider": map[string]any{
+				"codeActionKinds": []string{codeActionOrganizeImports},
+			},
+			"documentOnTypeFormattingProvider": map[string]any{
+				"firstTriggerCharacter": "}",
+				"moreTriggerCharacter":  []string{";"},
+			},
 			"semanticTokensProvider": map[string]any{
 				"legend": map[string]any{
 					"tokenTypes":     tokenTypes,
@@ -229,6 +291,9 @@ func (s *Server) handleDidSave(msg requestMessage) error {
 	}
 	snapshot = s.documents.Save(params.TextDocument.URI, version, text)
 	s.publishDiagnostics(params.TextDocument.URI, snapshot.Diagnostics)
+	if path, ok := uriToPath(params.TextDocument.URI); ok {
+		_ = s.index.Update(pathToURI(path), text)
+	}
 	return nil
 }
 
@@ -246,6 +311,32 @@ func (s *Server) handleDidClose(msg requestMessage) error {
 	return nil
 }
 
+// handleDidChangeConfiguration applies the client's selene settings. The
+// severity of each diagnostic code can be overridden with
+// selene.diagnostics.severity, such as {"type.unused-import": "off"}; the
+// open documents are analyzed again so the change shows at once.
+func (s *Server) handleDidChangeConfiguration(msg requestMessage) error {
+	var params struct {
+		Settings struct {
+			Selene struct {
+				Diagnostics struct {
+					Severity map[string]string `json:"severity"`
+				} `json:"diagnostics"`
+			} `json:"selene"`
+		} `json:"settings"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return nil
+	}
+	if err := s.analyzer.SetSeverities(params.Settings.Selene.Diagnostics.Severity); err != nil {
+		s.log.printf("diagnostic settings: %v", err)
+	}
+	for _, snapshot := range s.documents.Reanalyze() {
+		s.publishDiagnostics(snapshot.URI, snapshot.Diagnostics)
+	}
+	return nil
+}
+
 func (s *Server) handleCompletion(msg requestMessage) error {
 	var params struct {
 		TextDocument struct {
@@ -260,7 +351,7 @@ func (s *Server) handleCompletion(msg requestMessage) error {
 	if !ok {
 		return s.conn.Reply(msg.ID, CompletionList{IsIncomplete: false, Items: nil})
 	}
-	list := s.completer.Completion(snapshot, params.Position)
+	list := s.completer.Completion(snapshot, params.Position, autoImports(snapshot, s.index)...)
 	return s.conn.Reply(msg.ID, list)
 }
 
@@ -285,6 +376,27 @@ func (s *Server) handleHover(msg requestMessage) error {
 	return s.conn.Reply(msg.ID, hover)
 }
 
+func (s *Server) handleDocumentHighlight(msg requestMessage) error {
+	var params struct {
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+		Position Position `json:"position"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return s.conn.ReplyError(msg.ID, -32602, "invalid params")
+	}
+	snapshot, ok := s.documents.Snapshot(params.TextDocument.URI)
+	if !ok {
+		return s.conn.Reply(msg.ID, nil)
+	}
+	highlights := documentHighlights(snapshot, params.Position)
+	if len(highlights) == 0 {
+		return s.conn.Reply(msg.ID, nil)
+	}
+	return s.conn.Reply(msg.ID, highlights)
+}
+
 func (s *Server) handleDocumentSymbol(msg requestMessage) error {
 	var params struct {
 		TextDocument struct {
@@ -309,6 +421,15 @@ func (s *Server) handleWorkspaceSymbol(msg requestMessage) error {
 		return s.conn.ReplyError(msg.ID, -32602, "invalid params")
 	}
 	infos := s.documents.WorkspaceSymbols(params.Query)
+	open := make(map[string]struct{})
+	for _, snapshot := range s.documents.AllSnapshots() {
+		open[snapshot.URI] = struct{}{}
+		if path, ok := uriToPath(snapshot.URI); ok {
+			open[pathToURI(path)] = struct{}{}
+		}
+	}
+	infos = append(infos, s.index.Symbols(params.Query, open)...)
+	sortSymbolInformation(infos)
 	return s.conn.Reply(msg.ID, infos)
 }
 
@@ -336,6 +457,99 @@ func (s *Server) handleDocumentFormatting(msg requestMessage) error {
 	return s.conn.Reply(msg.ID, []TextEdit{edit})
 }
 
+// handleCodeAction offers to organize the imports of a document when the
+// client asks for source actions of that kind.
+func (s *Server) handleCodeAction(msg requestMessage) error {
+	var params struct {
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+		Context struct {
+			Only []string `json:"only"`
+		} `json:"context"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return s.conn.ReplyError(msg.ID, -32602, "invalid params")
+	}
+	actions := []CodeAction{}
+	snapshot, ok := s.documents.Snapshot(params.TextDocument.URI)
+	if !ok || !codeActionKindRequested(params.Context.Only, codeActionOrganizeImports) {
+		return s.conn.Reply(msg.ID, actions)
+	}
+	if edits := organizeImports(snapshot.Text); len(edits) > 0 {
+		actions = append(actions, CodeAction{
+			Title: "Organize imports",
+			Kind:  codeActionOrganizeImports,
+			Edit:  &WorkspaceEdit{Changes: map[string][]TextEdit{snapshot.URI: edits}},
+		})
+	}
+	return s.conn.Reply(msg.ID, actions)
+}
+
+// codeActionKindRequested reports whether kind falls under one of the kinds a
+// client restricted a code action request to. Kinds are hierarchical, so
+// "source" covers "source.organizeImports".
+func codeActionKindRequested(only []string, kind string) bool {
+	if len(only) == 0 {
+		return true
+	}
+	for _, requested := range only {
+		if kind == requested || strings.HasPrefix(kind, requested+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleRangeFormatting(msg requestMessage) error {
+	var params struct {
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+		Range Range `json:"range"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return s.conn.ReplyError(msg.ID, -32602, "invalid params")
+	}
+	return s.replyPartialFormat(msg, params.TextDocument.URI, params.Range)
+}
+
+// handleOnTypeFormatting reformats the top-level item the character was typed
+// into.
+func (s *Server) handleOnTypeFormatting(msg requestMessage) error {
+	var params struct {
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+		Position Position `json:"position"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return s.conn.ReplyError(msg.ID, -32602, "invalid params")
+	}
+	return s.replyPartialFormat(msg, params.TextDocument.URI, Range{Start: params.Position, End: params.Position})
+}
+
+func (s *Server) replyPartialFormat(msg requestMessage, uri string, rng Range) error {
+	snapshot, ok := s.documents.Snapshot(uri)
+	if !ok {
+		return s.conn.Reply(msg.ID, []TextEdit{})
+	}
+	start, _ := byteOffsetForPosition(snapshot.Text, rng.Start)
+	end, _ := byteOffsetForPosition(snapshot.Text, rng.End)
+	edit, err := format.Range(snapshot.Text, start, end)
+	if err != nil {
+		return s.conn.ReplyError(msg.ID, -32603, err.Error())
+	}
+	if edit.Text == snapshot.Text[edit.Start:edit.End] {
+		return s.conn.Reply(msg.ID, []TextEdit{})
+	}
+	replaced := Range{
+		Start: positionForByteOffset(snapshot.Text, edit.Start),
+		End:   positionForByteOffset(snapshot.Text, edit.End),
+	}
+	return s.conn.Reply(msg.ID, []TextEdit{{Range: replaced, NewText: edit.Text}})
+}
+
 func (s *Server) handleSemanticTokensFull(msg requestMessage) error {
 	var params struct {
 		TextDocument struct {
@@ -371,6 +585,25 @@ func (s *Server) handleSemanticTokensRange(msg requestMessage) error {
 	return s.conn.Reply(msg.ID, tokens)
 }
 
+// workspaceRoot picks the directory to index from the initialize parameters,
+// preferring the enclosing Selene project when one exists.
+func workspaceRoot(rootPath string, uris []string) string {
+	dir := rootPath
+	for _, uri := range uris {
+		if path, ok := uriToPath(uri); ok {
+			dir = path
+			break
+		}
+	}
+	if dir == "" {
+		return ""
+	}
+	if root, err := project.FindRoot(dir); err == nil {
+		return root
+	}
+	return dir
+}
+
 func (s *Server) publishDiagnostics(uri string, diagnostics []Diagnostic) {
 	params := map[string]any{
 		"uri":         uri,
@@ -379,48 +612,6 @@ func (s *Server) publishDiagnostics(uri string, diagnostics []Diagnostic) {
 	_ = s.conn.Notify("textDocument/publishDiagnostics", params)
 }
 
-func buildHover(doc *DocumentSnapshot, pos Position) (Hover, bool) {
-	name, rng := identifierAt(doc.Text, pos)
-	if name == "" {
-		return Hover{}, false
-	}
-	var content strings.Builder
-	if doc.Symbols != nil {
-		for _, fn := range doc.Symbols.FunctionSymbols {
-			if fn.Name == name {
-				content.WriteString(fmt.Sprintf("**function** `%s`\n\n", fn.Detail))
-				return Hover{Contents: MarkupContent{Kind: "markdown", Value: content.String()}, Range: &rng}, true
-			}
-		}
-		for _, param := range collectParameters(doc.Symbols) {
-			if param.Name == name {
-				content.WriteString(fmt.Sprintf("**parameter** `%s`", param.Name))
-				return Hover{Contents: MarkupContent{Kind: "markdown", Value: content.String()}, Range: &rng}, true
-			}
-		}
-		for _, variable := range doc.Symbols.VariableSymbols {
-			if variable.Name == name {
-				detail := "immutable"
-				if variable.Mutable {
-					detail = "mutable"
-				}
-				content.WriteString(fmt.Sprintf("**variable** `%s` (%s)", variable.Name, detail))
-				return Hover{Contents: MarkupContent{Kind: "markdown", Value: content.String()}, Range: &rng}, true
-			}
-		}
-		for _, t := range doc.Symbols.TypeSymbols {
-			if t.Name == name {
-				content.WriteString(fmt.Sprintf("**%s** `%s`", strings.ToLower(t.Detail), t.Name))
-				return Hover{Contents: MarkupContent{Kind: "markdown", Value: content.String()}, Range: &rng}, true
-			}
-		}
-	}
-	if content.Len() == 0 {
-		content.WriteString(fmt.Sprintf("`%s`", name))
-	}
-	return Hover{Contents: MarkupContent{Kind: "markdown", Value: content.String()}, Range: &rng}, true
-}
-
 func collectParameters(index *SymbolIndex) []ParameterSymbol {
 	params := make([]ParameterSymbol, 0)
 	if index == nil {
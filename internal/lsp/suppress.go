@@ -0,0 +1,60 @@
+package lsp
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/cybellereaper/selenelang/internal/lexer"
+	"github.com/cybellereaper/selenelang/internal/token"
+)
+
+// suppressionDirective starts a line comment that turns off diagnostics by
+// code. At the end of a line it applies to that line; on a line of its own
+// it applies to the next one:
+//
+//	let unused = 1; //selene:disable lint.unused-variable
+//	//selene:disable type.undefined, type.arity
+//	legacyCall(1, 2);
+const suppressionDirective = "//selene:disable"
+
+// suppress drops the diagnostics that a //selene:disable comment in text
+// turns off. Diagnostics without a code cannot be suppressed.
+func suppress(text string, diagnostics []Diagnostic) []Diagnostic {
+	if !strings.Contains(text, suppressionDirective) {
+		return diagnostics
+	}
+	disabled := suppressions(text)
+	if len(disabled) == 0 {
+		return diagnostics
+	}
+	kept := diagnostics[:0]
+	for _, diag := range diagnostics {
+		if diag.Code != "" && slices.Contains(disabled[diag.Range.Start.Line], diag.Code) {
+			continue
+		}
+		kept = append(kept, diag)
+	}
+	return kept
+}
+
+// suppressions maps zero-based lines to the codes disabled on them.
+func suppressions(text string) map[int][]string {
+	lex := lexer.New(text)
+	for lex.NextToken().Type != token.EOF {
+	}
+	disabled := make(map[int][]string)
+	for _, comment := range lex.Comments() {
+		rest, ok := strings.CutPrefix(comment.Text, suppressionDirective)
+		if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+			continue
+		}
+		codes := strings.FieldsFunc(rest, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+		line := comment.Pos.Line - 1
+		lineStart := strings.LastIndexByte(text[:comment.Pos.Offset], '\n') + 1
+		if strings.TrimSpace(text[lineStart:comment.Pos.Offset]) == "" {
+			line++
+		}
+		disabled[line] = append(disabled[line], codes...)
+	}
+	return disabled
+}
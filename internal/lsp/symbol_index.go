@@ -73,18 +73,42 @@ func buildSymbolIndex(program *ast.Program, tokens []token.Token) *SymbolIndex {
 		VariableSymbols: make([]VariableSymbol, 0),
 	}
 	if program != nil {
-		for _, item := range program.Items {
-			if sym, ok := index.symbolFromItem(item); ok {
-				index.DocumentSymbols = append(index.DocumentSymbols, sym)
-			}
-		}
+		collector := &symbolCollector{index: index}
+		ast.Walk(program, collector)
+		index.DocumentSymbols = append(index.DocumentSymbols, collector.symbols...)
 	}
 	index.VariableSymbols = append(index.VariableSymbols, extractVariableSymbols(tokens)...)
 	return index
 }
 
-func (i *SymbolIndex) symbolFromItem(item ast.ProgramItem) (DocumentSymbol, bool) {
-	switch node := item.(type) {
+// symbolCollector gathers the document symbols declared directly in a
+// program or module body, descending into nested modules for their children.
+type symbolCollector struct {
+	index   *SymbolIndex
+	body    *ast.BlockStatement
+	symbols []DocumentSymbol
+}
+
+func (c *symbolCollector) Visit(node ast.Node) ast.Visitor {
+	switch node := node.(type) {
+	case *ast.Program:
+		return c
+	case *ast.BlockStatement:
+		if node == c.body {
+			return c
+		}
+		return nil
+	case nil:
+		return nil
+	}
+	if sym, ok := c.index.symbolFor(node); ok {
+		c.symbols = append(c.symbols, sym)
+	}
+	return nil
+}
+
+func (i *SymbolIndex) symbolFor(node ast.Node) (DocumentSymbol, bool) {
+	switch node := node.(type) {
 	case *ast.PackageDeclaration:
 		if node.Name == nil {
 			return DocumentSymbol{}, false
@@ -108,10 +132,9 @@ func (i *SymbolIndex) symbolFromItem(item ast.ProgramItem) (DocumentSymbol, bool
 			SelectionRange: rangeFromIdentifier(node.Name),
 		}
 		if node.Body != nil {
-			children := i.symbolsFromStatements(node.Body.Statements)
-			if len(children) > 0 {
-				sym.Children = append(sym.Children, children...)
-			}
+			children := &symbolCollector{index: i, body: node.Body}
+			ast.Walk(node.Body, children)
+			sym.Children = append(sym.Children, children.symbols...)
 		}
 		return sym, true
 	case *ast.FunctionDeclaration:
@@ -238,6 +261,17 @@ func (i *SymbolIndex) symbolFromItem(item ast.ProgramItem) (DocumentSymbol, bool
 		}
 		i.TypeSymbols = append(i.TypeSymbols, TypeSymbol{Name: node.Name.Name, Kind: symbolKindEnum, Detail: "enum", Range: sym.Range})
 		return sym, true
+	case *ast.ImplDeclaration:
+		if node.Interface == nil || node.Target == nil {
+			return DocumentSymbol{}, false
+		}
+		return DocumentSymbol{
+			Name:           node.Interface.Name + " for " + node.Target.Name,
+			Detail:         "impl",
+			Kind:           symbolKindClass,
+			Range:          rangeFromNode(node),
+			SelectionRange: rangeFromIdentifier(node.Target),
+		}, true
 	case *ast.ContractDeclaration:
 		if node.Name == nil {
 			return DocumentSymbol{}, false
@@ -256,45 +290,6 @@ func (i *SymbolIndex) symbolFromItem(item ast.ProgramItem) (DocumentSymbol, bool
 	}
 }
 
-func (i *SymbolIndex) symbolsFromStatements(stmts []ast.Statement) []DocumentSymbol {
-	if len(stmts) == 0 {
-		return nil
-	}
-	symbols := make([]DocumentSymbol, 0)
-	for _, stmt := range stmts {
-		if stmt == nil {
-			continue
-		}
-		if sym, ok := i.symbolFromStatement(stmt); ok {
-			symbols = append(symbols, sym)
-		}
-	}
-	return symbols
-}
-
-func (i *SymbolIndex) symbolFromStatement(stmt ast.Statement) (DocumentSymbol, bool) {
-	switch node := stmt.(type) {
-	case *ast.FunctionDeclaration:
-		return i.symbolFromItem(node)
-	case *ast.VariableDeclaration:
-		return i.symbolFromItem(node)
-	case *ast.ClassDeclaration:
-		return i.symbolFromItem(node)
-	case *ast.StructDeclaration:
-		return i.symbolFromItem(node)
-	case *ast.EnumDeclaration:
-		return i.symbolFromItem(node)
-	case *ast.InterfaceDeclaration:
-		return i.symbolFromItem(node)
-	case *ast.ContractDeclaration:
-		return i.symbolFromItem(node)
-	case *ast.BlockStatement:
-		return DocumentSymbol{}, false
-	default:
-		return DocumentSymbol{}, false
-	}
-}
-
 func extractVariableSymbols(tokens []token.Token) []VariableSymbol {
 	vars := make([]VariableSymbol, 0)
 	for i := 0; i < len(tokens)-1; i++ {
@@ -1,6 +1,9 @@
 package lsp
 
-import "unicode"
+import (
+	"unicode"
+	"unicode/utf8"
+)
 
 func runeOffsetForPosition(text string, pos Position) (int, bool) {
 	if pos.Line < 0 || pos.Character < 0 {
@@ -48,6 +51,22 @@ func positionForRuneOffset(text string, offset int) Position {
 	return Position{Line: line, Character: character}
 }
 
+// byteOffsetForPosition is runeOffsetForPosition measured in bytes.
+func byteOffsetForPosition(text string, pos Position) (int, bool) {
+	runes, ok := runeOffsetForPosition(text, pos)
+	offset := 0
+	for ; runes > 0 && offset < len(text); runes-- {
+		_, size := utf8.DecodeRuneInString(text[offset:])
+		offset += size
+	}
+	return offset, ok
+}
+
+func positionForByteOffset(text string, offset int) Position {
+	offset = max(0, min(offset, len(text)))
+	return positionForRuneOffset(text, utf8.RuneCountInString(text[:offset]))
+}
+
 func identifierPrefixAt(text string, pos Position) (string, Position) {
 	runes := []rune(text)
 	idx, ok := runeOffsetForPosition(text, pos)
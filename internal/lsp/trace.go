@@ -0,0 +1,119 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceLevel selects how much JSON-RPC traffic the server writes to its log.
+type TraceLevel int32
+
+const (
+	// TraceOff logs only server events such as startup and panics.
+	TraceOff TraceLevel = iota
+	// TraceMessages adds one line per message with its method and ID.
+	TraceMessages
+	// TraceVerbose adds the JSON body of every message.
+	TraceVerbose
+)
+
+// ParseTraceLevel parses "off", "messages", or "verbose".
+func ParseTraceLevel(name string) (TraceLevel, error) {
+	switch name {
+	case "off":
+		return TraceOff, nil
+	case "messages":
+		return TraceMessages, nil
+	case "verbose":
+		return TraceVerbose, nil
+	}
+	return TraceOff, fmt.Errorf("unknown trace level %q (expected off, messages, or verbose)", name)
+}
+
+// serverLog writes timestamped lines to the log the host configured. The
+// zero value, and a log without a writer, discard everything.
+type serverLog struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level atomic.Int32
+}
+
+func (l *serverLog) printf(format string, args ...any) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.w == nil {
+		return
+	}
+	fmt.Fprintf(l.w, "[%s] %s\n", time.Now().Format("15:04:05.000"), fmt.Sprintf(format, args...))
+}
+
+func (l *serverLog) tracing() TraceLevel {
+	if l == nil {
+		return TraceOff
+	}
+	return TraceLevel(l.level.Load())
+}
+
+// message traces one JSON-RPC payload in the given direction, "received" or
+// "sent".
+func (l *serverLog) message(direction string, payload []byte) {
+	level := l.tracing()
+	if level == TraceOff {
+		return
+	}
+	var msg struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Error  *responseError  `json:"error"`
+	}
+	_ = json.Unmarshal(payload, &msg)
+	var line strings.Builder
+	fmt.Fprintf(&line, "%s ", direction)
+	switch {
+	case msg.Method != "" && len(msg.ID) > 0:
+		fmt.Fprintf(&line, "request %s (id %s)", msg.Method, msg.ID)
+	case msg.Method != "":
+		fmt.Fprintf(&line, "notification %s", msg.Method)
+	case msg.Error != nil:
+		fmt.Fprintf(&line, "error response (id %s): %s", msg.ID, msg.Error.Message)
+	default:
+		fmt.Fprintf(&line, "response (id %s)", msg.ID)
+	}
+	if level == TraceVerbose {
+		fmt.Fprintf(&line, "\n%s", payload)
+	}
+	l.printf("%s", line.String())
+}
+
+// SetLog directs the server's log to w, tracing JSON-RPC traffic at level.
+// Panics in request handlers are always logged with their stack, whatever
+// the level. Pass a nil writer to stop logging.
+func (s *Server) SetLog(w io.Writer, level TraceLevel) {
+	s.log.mu.Lock()
+	s.log.w = w
+	s.log.mu.Unlock()
+	s.log.level.Store(int32(level))
+}
+
+// recoverHandler turns a panic in the handler for msg into a logged stack
+// trace and, for requests, an internal error reply, so one bad request does
+// not take down the editor's language server.
+func (s *Server) recoverHandler(msg requestMessage, err *error) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	s.log.printf("panic handling %s: %v\n%s", msg.Method, recovered, debug.Stack())
+	if len(msg.ID) > 0 {
+		*err = s.conn.ReplyError(msg.ID, -32603, fmt.Sprintf("internal error handling %s: %v", msg.Method, recovered))
+	}
+}
@@ -0,0 +1,70 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func frame(body string) string {
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func TestServerTracesMessagesAndSurvivesPanics(t *testing.T) {
+	input := frame(`{"jsonrpc":"2.0","id":1,"method":"shutdown"}`) +
+		frame(`{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///a.sel"},"position":{"line":0,"character":0}}}`) +
+		frame(`{"jsonrpc":"2.0","method":"exit"}`)
+	var out, log bytes.Buffer
+	server := NewServer(strings.NewReader(input), &out)
+	server.SetLog(&log, TraceVerbose)
+	// A store-less server panics on hover, standing in for a handler bug.
+	server.documents = nil
+	if err := server.Run(); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	logged := log.String()
+	for _, want := range []string{
+		"received request shutdown (id 1)",
+		"sent response (id 1)",
+		"received request textDocument/hover (id 2)",
+		`"method":"textDocument/hover"`,
+		"panic handling textDocument/hover",
+		"sent error response (id 2): internal error handling textDocument/hover",
+		"received notification exit",
+	} {
+		if !strings.Contains(logged, want) {
+			t.Fatalf("log missing %q:\n%s", want, logged)
+		}
+	}
+	if !strings.Contains(logged, "goroutine") {
+		t.Fatalf("expected the panic to be logged with its stack:\n%s", logged)
+	}
+
+	replies := strings.Split(out.String(), "Content-Length: ")
+	last := replies[len(replies)-1]
+	var reply responseMessage
+	if err := json.Unmarshal([]byte(last[strings.Index(last, "{"):]), &reply); err != nil {
+		t.Fatalf("decode reply: %v", err)
+	}
+	if reply.Error == nil || reply.Error.Code != -32603 {
+		t.Fatalf("expected an internal error reply, got %+v", reply)
+	}
+}
+
+func TestTraceMessagesOmitsBodies(t *testing.T) {
+	var log bytes.Buffer
+	server := NewServer(strings.NewReader(frame(`{"jsonrpc":"2.0","method":"initialized","params":{}}`)), &bytes.Buffer{})
+	server.SetLog(&log, TraceMessages)
+	if err := server.Run(); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if !strings.Contains(log.String(), "received notification initialized") || strings.Contains(log.String(), `"params"`) {
+		t.Fatalf("unexpected messages-level log:\n%s", log.String())
+	}
+	if _, err := ParseTraceLevel("loud"); err == nil {
+		t.Fatalf("expected an unknown trace level to be rejected")
+	}
+}
@@ -38,6 +38,7 @@ type jsonRPCConnection struct {
 	reader  *bufio.Reader
 	writer  *bufio.Writer
 	writeMu sync.Mutex
+	log     *serverLog
 }
 
 func newJSONRPCConnection(r io.Reader, w io.Writer) *jsonRPCConnection {
@@ -52,6 +53,7 @@ func (c *jsonRPCConnection) Read() (requestMessage, error) {
 	if err != nil {
 		return requestMessage{}, err
 	}
+	c.log.message("received", payload)
 	var msg requestMessage
 	if err := json.Unmarshal(payload, &msg); err != nil {
 		return requestMessage{}, err
@@ -127,6 +129,7 @@ func (c *jsonRPCConnection) writeMessage(v interface{}) error {
 	if err != nil {
 		return err
 	}
+	c.log.message("sent", data)
 	header := fmt.Sprintf("Content-Length: %d\r\n\r\n", len(data))
 	c.writeMu.Lock()
 	defer c.writeMu.Unlock()
@@ -0,0 +1,280 @@
+package lsp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cybellereaper/selenelang/internal/cache"
+	"github.com/cybellereaper/selenelang/internal/perf"
+	"github.com/cybellereaper/selenelang/internal/project"
+)
+
+const (
+	workspaceIndexNamespace = "lsp-index"
+	workspaceIndexKey       = "workspace.json"
+	workspaceIndexVersion   = 2
+)
+
+// WorkspaceIndex records the symbols declared in every Selene file under the
+// workspace root, including files the client never opened. The index is
+// persisted to .selene-cache/lsp-index so a restart only re-analyzes files
+// whose contents changed since the previous session.
+type WorkspaceIndex struct {
+	mu       sync.RWMutex
+	analyzer *Analyzer
+	root     string
+	files    map[string]indexedFile
+	dirty    bool
+}
+
+type indexedFile struct {
+	Hash    string              `json:"hash"`
+	Symbols []SymbolInformation `json:"symbols"`
+	// Exports are the top-level declarations other files can import.
+	Exports []SymbolInformation `json:"exports,omitempty"`
+}
+
+type persistedIndex struct {
+	Version int                    `json:"version"`
+	Files   map[string]indexedFile `json:"files"`
+}
+
+// IndexStats summarises the work performed by WorkspaceIndex.Refresh.
+type IndexStats struct {
+	Files     int
+	Reindexed int
+	Removed   int
+}
+
+// NewWorkspaceIndex constructs an empty index backed by the provided analyzer.
+func NewWorkspaceIndex(analyzer *Analyzer) *WorkspaceIndex {
+	if analyzer == nil {
+		analyzer = NewAnalyzer(nil)
+	}
+	return &WorkspaceIndex{analyzer: analyzer, files: make(map[string]indexedFile)}
+}
+
+// Refresh scans root for Selene sources, reusing persisted entries whose
+// content hash still matches and analyzing the rest. The updated index is
+// written back to the cache when anything changed.
+func (w *WorkspaceIndex) Refresh(root string) (IndexStats, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.root != root {
+		w.root = root
+		w.files = w.loadLocked()
+	}
+	paths, err := project.ListSeleneFiles(root)
+	if err != nil {
+		return IndexStats{}, err
+	}
+	paths = slices.DeleteFunc(paths, func(path string) bool { return isUnderCacheDir(root, path) })
+	stats := IndexStats{Files: len(paths)}
+	// Files are read and analyzed concurrently; w.files is only read until
+	// every worker is done.
+	updates := make([]*indexedFile, len(paths))
+	forEachParallel(len(paths), 0, func(i int) {
+		updates[i] = w.refreshFile(paths[i])
+	})
+	seen := make(map[string]struct{}, len(paths))
+	for i, path := range paths {
+		uri := pathToURI(path)
+		seen[uri] = struct{}{}
+		if updates[i] != nil {
+			w.files[uri] = *updates[i]
+			w.dirty = true
+			stats.Reindexed++
+		}
+	}
+	for uri := range w.files {
+		if _, ok := seen[uri]; !ok {
+			delete(w.files, uri)
+			w.dirty = true
+			stats.Removed++
+		}
+	}
+	return stats, w.persistLocked()
+}
+
+// refreshFile returns the new entry for path, or nil when the file cannot be
+// read, is unchanged, or fails to analyze.
+func (w *WorkspaceIndex) refreshFile(path string) (file *indexedFile) {
+	defer func() {
+		if recover() != nil {
+			file = nil
+		}
+	}()
+	// #nosec G304 -- path was produced by walking the workspace root.
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	uri := pathToURI(path)
+	hash := contentHash(string(content))
+	if existing, ok := w.files[uri]; ok && existing.Hash == hash {
+		perf.Hit(perf.IndexCache)
+		return nil
+	}
+	perf.Miss(perf.IndexCache)
+	analyzed := w.analyze(uri, string(content), hash)
+	return &analyzed
+}
+
+// Update replaces the entry for a single document, typically after it was
+// saved by the client.
+func (w *WorkspaceIndex) Update(uri, text string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.root == "" {
+		return nil
+	}
+	hash := contentHash(text)
+	if existing, ok := w.files[uri]; ok && existing.Hash == hash {
+		return nil
+	}
+	w.files[uri] = w.analyze(uri, text, hash)
+	w.dirty = true
+	return w.persistLocked()
+}
+
+// Symbols returns indexed symbols matching query, skipping URIs in exclude so
+// callers can substitute fresher data for documents open in the editor.
+func (w *WorkspaceIndex) Symbols(query string, exclude map[string]struct{}) []SymbolInformation {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	lower := strings.ToLower(query)
+	infos := make([]SymbolInformation, 0)
+	for uri, file := range w.files {
+		if _, skip := exclude[uri]; skip {
+			continue
+		}
+		for _, info := range file.Symbols {
+			if lower == "" || strings.Contains(strings.ToLower(info.Name), lower) {
+				infos = append(infos, info)
+			}
+		}
+	}
+	return infos
+}
+
+// Exports returns the importable declarations of every indexed file except
+// the one at skip, ordered by file and then by position.
+func (w *WorkspaceIndex) Exports(skip string) []SymbolInformation {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	var exports []SymbolInformation
+	for uri, file := range w.files {
+		if uri != skip {
+			exports = append(exports, file.Exports...)
+		}
+	}
+	sort.SliceStable(exports, func(i, j int) bool {
+		if exports[i].Location.URI != exports[j].Location.URI {
+			return exports[i].Location.URI < exports[j].Location.URI
+		}
+		return exports[i].Location.Range.Start.Line < exports[j].Location.Range.Start.Line
+	})
+	return exports
+}
+
+func (w *WorkspaceIndex) analyze(uri, text, hash string) indexedFile {
+	analysis := w.analyzer.Analyze(text)
+	var symbols, exports []SymbolInformation
+	if analysis.Symbols != nil {
+		symbols = flattenDocumentSymbols(uri, analysis.Symbols.DocumentSymbols)
+		for _, sym := range analysis.Symbols.DocumentSymbols {
+			// A package declaration names the file's package, and init and
+			// main are entry points rather than APIs.
+			if sym.Kind == symbolKindPackage || sym.Name == "init" || sym.Name == "main" {
+				continue
+			}
+			exports = append(exports, SymbolInformation{
+				Name:     sym.Name,
+				Kind:     sym.Kind,
+				Detail:   sym.Detail,
+				Location: Location{URI: uri, Range: sym.Range},
+			})
+		}
+	}
+	return indexedFile{Hash: hash, Symbols: symbols, Exports: exports}
+}
+
+func (w *WorkspaceIndex) loadLocked() map[string]indexedFile {
+	files := make(map[string]indexedFile)
+	data, err := cache.Read(w.root, workspaceIndexNamespace, workspaceIndexKey)
+	if err != nil {
+		return files
+	}
+	var persisted persistedIndex
+	if err := json.Unmarshal(data, &persisted); err != nil || persisted.Version != workspaceIndexVersion {
+		return files
+	}
+	for uri, file := range persisted.Files {
+		files[uri] = file
+	}
+	return files
+}
+
+func (w *WorkspaceIndex) persistLocked() error {
+	if !w.dirty {
+		return nil
+	}
+	data, err := json.Marshal(persistedIndex{Version: workspaceIndexVersion, Files: w.files})
+	if err != nil {
+		return err
+	}
+	if err := cache.Write(w.root, workspaceIndexNamespace, workspaceIndexKey, data); err != nil {
+		return err
+	}
+	w.dirty = false
+	return nil
+}
+
+func sortSymbolInformation(infos []SymbolInformation) {
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Name == infos[j].Name {
+			if infos[i].Location.URI == infos[j].Location.URI {
+				if infos[i].Location.Range.Start.Line == infos[j].Location.Range.Start.Line {
+					return infos[i].Location.Range.Start.Character < infos[j].Location.Range.Start.Character
+				}
+				return infos[i].Location.Range.Start.Line < infos[j].Location.Range.Start.Line
+			}
+			return infos[i].Location.URI < infos[j].Location.URI
+		}
+		return infos[i].Name < infos[j].Name
+	})
+}
+
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+func isUnderCacheDir(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	first, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+	return first == cache.DirName
+}
+
+func pathToURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+func uriToPath(uri string) (string, bool) {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" || parsed.Path == "" {
+		return "", false
+	}
+	return filepath.FromSlash(parsed.Path), true
+}
@@ -0,0 +1,82 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspaceIndexReusesPersistedEntries(t *testing.T) {
+	root := t.TempDir()
+	writeWorkspaceFile(t, filepath.Join(root, "src", "alpha.selene"), "fn alpha() {}\n")
+	writeWorkspaceFile(t, filepath.Join(root, "src", "beta.selene"), "fn beta() {}\n")
+
+	first := NewWorkspaceIndex(nil)
+	stats, err := first.Refresh(root)
+	if err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	if stats.Files != 2 || stats.Reindexed != 2 {
+		t.Fatalf("expected both files to be indexed, got %+v", stats)
+	}
+	if results := first.Symbols("alp", nil); len(results) != 1 || results[0].Name != "alpha" {
+		t.Fatalf("expected alpha symbol from index, got %+v", results)
+	}
+
+	writeWorkspaceFile(t, filepath.Join(root, "src", "beta.selene"), "fn gamma() {}\n")
+	if err := os.Remove(filepath.Join(root, "src", "alpha.selene")); err != nil {
+		t.Fatalf("failed to remove alpha.selene: %v", err)
+	}
+
+	second := NewWorkspaceIndex(nil)
+	stats, err = second.Refresh(root)
+	if err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	if stats.Files != 1 || stats.Reindexed != 1 || stats.Removed != 1 {
+		t.Fatalf("expected only the edited file to be re-indexed, got %+v", stats)
+	}
+	if results := second.Symbols("", nil); len(results) != 1 || results[0].Name != "gamma" {
+		t.Fatalf("expected only gamma after refresh, got %+v", results)
+	}
+
+	third := NewWorkspaceIndex(nil)
+	stats, err = third.Refresh(root)
+	if err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	if stats.Reindexed != 0 {
+		t.Fatalf("expected unchanged workspace to load entirely from cache, got %+v", stats)
+	}
+}
+
+func TestWorkspaceIndexSymbolsHonoursExclusions(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "main.selene")
+	writeWorkspaceFile(t, path, "fn stale() {}\n")
+
+	index := NewWorkspaceIndex(nil)
+	if _, err := index.Refresh(root); err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	uri := pathToURI(path)
+	if results := index.Symbols("stale", map[string]struct{}{uri: {}}); len(results) != 0 {
+		t.Fatalf("expected excluded document to be skipped, got %+v", results)
+	}
+	if err := index.Update(uri, "fn fresh() {}\n"); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+	if results := index.Symbols("fresh", nil); len(results) != 1 || results[0].Location.URI != uri {
+		t.Fatalf("expected updated symbol for %s, got %+v", uri, results)
+	}
+}
+
+func writeWorkspaceFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("failed to create directory %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("failed to write file %s: %v", path, err)
+	}
+}
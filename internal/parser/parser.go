@@ -2,8 +2,12 @@
 package parser
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/cybellereaper/selenelang/internal/ast"
 	"github.com/cybellereaper/selenelang/internal/lexer"
@@ -33,6 +37,19 @@ type Parser struct {
 
 	prefixParseFns map[token.Type]prefixParseFn
 	infixParseFns  map[token.Type]infixParseFn
+
+	// inGenerator reports whether the innermost enclosing function was
+	// declared with `fn name*()` and may therefore contain yield expressions.
+	inGenerator bool
+	// inCatch reports whether the parser is inside a catch clause of the
+	// innermost enclosing function, where rethrow is a statement.
+	inCatch bool
+	// labels are the labels of the loops the parser is inside within the
+	// innermost enclosing function, outermost first.
+	labels []string
+	// olds, while a postcondition is parsed, is the contract block whose
+	// old(expr) snapshots it collects. Elsewhere old is an ordinary name.
+	olds *ast.ContractBlock
 }
 
 const (
@@ -44,6 +61,7 @@ const (
 	AND
 	EQUALITY
 	COMPARISON
+	RANGE
 	SUM
 	PRODUCT
 	PREFIX
@@ -68,6 +86,9 @@ var precedences = map[token.Type]int{
 	token.GTE:            COMPARISON,
 	token.IS:             COMPARISON,
 	token.NOT_IS:         COMPARISON,
+	token.IN:             COMPARISON,
+	token.DOTDOT:         RANGE,
+	token.DOTDOT_EQ:      RANGE,
 	token.PLUS:           SUM,
 	token.MINUS:          SUM,
 	token.ASTERISK:       PRODUCT,
@@ -77,7 +98,11 @@ var precedences = map[token.Type]int{
 	token.LBRACKET:       CALL,
 	token.DOT:            CALL,
 	token.SAFE_DOT:       CALL,
+	token.SAFE_INDEX:     CALL,
 	token.NON_NULL:       CALL,
+	token.INCREMENT:      CALL,
+	token.DECREMENT:      CALL,
+	token.QUESTION:       CALL,
 }
 
 // New constructs a parser bound to the provided lexer.
@@ -106,8 +131,10 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.ASTERISK, p.parsePrefixExpression)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.HASH_BRACE, p.parseSetLiteral)
 	p.registerPrefix(token.LBRACE, p.parseObjectLiteral)
 	p.registerPrefix(token.AWAIT, p.parseAwaitExpression)
+	p.registerPrefix(token.YIELD, p.parseYieldExpression)
 
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
@@ -124,6 +151,9 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.AND, p.parseInfixExpression)
 	p.registerInfix(token.IS, p.parseInfixExpression)
 	p.registerInfix(token.NOT_IS, p.parseInfixExpression)
+	p.registerInfix(token.IN, p.parseInfixExpression)
+	p.registerInfix(token.DOTDOT, p.parseInfixExpression)
+	p.registerInfix(token.DOTDOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.ELVIS, p.parseElvisExpression)
 	p.registerInfix(token.ASSIGN, p.parseAssignmentExpression)
 	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignmentExpression)
@@ -135,7 +165,11 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 	p.registerInfix(token.DOT, p.parseMemberExpression)
 	p.registerInfix(token.SAFE_DOT, p.parseMemberExpression)
+	p.registerInfix(token.SAFE_INDEX, p.parseIndexExpression)
 	p.registerInfix(token.NON_NULL, p.parseNonNullAssertion)
+	p.registerInfix(token.QUESTION, p.parsePropagateExpression)
+	p.registerInfix(token.INCREMENT, p.parseIncrementExpression)
+	p.registerInfix(token.DECREMENT, p.parseIncrementExpression)
 
 	return p
 }
@@ -195,6 +229,7 @@ func (p *Parser) ParseProgram() *ast.Program {
 	}
 
 	program.Finish = p.curToken.Pos
+	program.Comments = p.l.Comments()
 	return program
 }
 
@@ -202,6 +237,8 @@ func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET, token.VAR:
 		return p.parseVariableDeclaration()
+	case token.AT:
+		return p.parseAnnotatedDeclaration()
 	case token.FN:
 		return p.parseFunctionDeclaration()
 	case token.CLASS:
@@ -214,6 +251,8 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseContractDeclaration()
 	case token.INTERFACE:
 		return p.parseInterfaceDeclaration()
+	case token.IMPL:
+		return p.parseImplDeclaration()
 	case token.IMPORT:
 		return p.parseImportDeclaration()
 	case token.EXT:
@@ -222,6 +261,8 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseIfStatement()
 	case token.WHILE:
 		return p.parseWhileStatement()
+	case token.DO:
+		return p.parseDoWhileStatement()
 	case token.FOR:
 		return p.parseForStatement()
 	case token.RETURN:
@@ -242,13 +283,76 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseConditionStatement()
 	case token.LBRACE:
 		return p.parseBlockStatement()
+	case token.IDENT:
+		if p.curToken.Literal == "abstract" && p.peekTokenIs(token.CLASS) {
+			return p.parseAbstractClassDeclaration()
+		}
+		if p.curToken.Literal == "rethrow" && (p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RBRACE)) {
+			return p.parseRethrowStatement()
+		}
+		if p.peekTokenIs(token.COLON) {
+			return p.parseLabeledLoop()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
+// parseAnnotatedDeclaration parses the annotations in front of a
+// declaration and attaches them to it, moving the declaration's start back
+// to the first @. Doc comments may come before the annotations or between
+// them and the declaration.
+func (p *Parser) parseAnnotatedDeclaration() ast.Statement {
+	start, doc := p.curToken.Pos, p.curToken.Doc
+	var annotations []*ast.Annotation
+	for p.curTokenIs(token.AT) {
+		annotation := p.parseAnnotation()
+		if annotation == nil {
+			return nil
+		}
+		annotations = append(annotations, annotation)
+		p.nextToken()
+	}
+	stmt := p.parseStatement()
+	switch decl := stmt.(type) {
+	case *ast.FunctionDeclaration:
+		decl.Start, decl.Annotations, decl.Doc = start, annotations, cmp.Or(decl.Doc, doc)
+	case *ast.ClassDeclaration:
+		decl.Start, decl.Annotations, decl.Doc = start, annotations, cmp.Or(decl.Doc, doc)
+	case *ast.StructDeclaration:
+		decl.Start, decl.Annotations, decl.Doc = start, annotations, cmp.Or(decl.Doc, doc)
+	case *ast.EnumDeclaration:
+		decl.Start, decl.Annotations, decl.Doc = start, annotations, cmp.Or(decl.Doc, doc)
+	case *ast.InterfaceDeclaration:
+		decl.Start, decl.Annotations, decl.Doc = start, annotations, cmp.Or(decl.Doc, doc)
+	case *ast.ContractDeclaration:
+		decl.Start, decl.Annotations, decl.Doc = start, annotations, cmp.Or(decl.Doc, doc)
+	case nil:
+	default:
+		p.addError(start, "annotations must precede a function, class, struct, enum, interface, or contract declaration")
+	}
+	return stmt
+}
+
+// parseAnnotation parses @name or @name(arguments), leaving the current
+// token on its last token.
+func (p *Parser) parseAnnotation() *ast.Annotation {
+	annotation := &ast.Annotation{Start: p.curToken.Pos}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	annotation.Name = p.currentIdentifier()
+	if p.peekTokenIs(token.LPAREN) {
+		p.nextToken()
+		annotation.Arguments = p.parseExpressionList(token.RPAREN)
+	}
+	annotation.Finish = p.curToken.End
+	return annotation
+}
+
 func (p *Parser) parseModuleDeclaration() ast.ProgramItem {
-	module := &ast.ModuleDeclaration{Start: p.curToken.Pos}
+	module := &ast.ModuleDeclaration{Start: p.curToken.Pos, Doc: p.curToken.Doc}
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
@@ -282,10 +386,38 @@ func (p *Parser) parsePackageDeclaration() ast.ProgramItem {
 }
 
 func (p *Parser) parseVariableDeclaration() ast.Statement {
-	stmt := &ast.VariableDeclaration{Start: p.curToken.Pos, Mutable: p.curToken.Type == token.VAR}
+	stmt := &ast.VariableDeclaration{Start: p.curToken.Pos, Doc: p.curToken.Doc, Mutable: p.curToken.Type == token.VAR}
+	if p.peekTokenIs(token.LBRACKET) {
+		p.nextToken()
+		return p.parseDestructuringRest(stmt)
+	}
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
+	return p.parseVariableDeclarationRest(stmt)
+}
+
+// parseDestructuringRest finishes a declaration whose pattern starts at the
+// current token.
+func (p *Parser) parseDestructuringRest(stmt *ast.VariableDeclaration) ast.Statement {
+	stmt.Pattern = p.parsePattern()
+	if stmt.Pattern == nil || !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value != nil {
+		stmt.Finish = stmt.Value.End()
+	}
+	if !p.expectPeek(token.SEMICOLON) {
+		return stmt
+	}
+	stmt.Finish = p.curToken.End
+	return stmt
+}
+
+// parseVariableDeclarationRest finishes a declaration whose name is the current token.
+func (p *Parser) parseVariableDeclarationRest(stmt *ast.VariableDeclaration) ast.Statement {
 	stmt.Name = p.currentIdentifier()
 
 	if p.peekTokenIs(token.COLON) {
@@ -312,12 +444,17 @@ func (p *Parser) parseVariableDeclaration() ast.Statement {
 }
 
 func (p *Parser) parseFunctionDeclaration() ast.Statement {
-	fn := &ast.FunctionDeclaration{Start: p.curToken.Pos}
+	fn := &ast.FunctionDeclaration{Start: p.curToken.Pos, Doc: p.curToken.Doc}
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 	fn.Name = p.currentIdentifier()
 
+	if p.peekTokenIs(token.ASTERISK) {
+		p.nextToken()
+		fn.Generator = true
+	}
+
 	if p.peekTokenIs(token.LT) {
 		fn.TypeParams = p.parseTypeParameters()
 	}
@@ -328,6 +465,10 @@ func (p *Parser) parseFunctionDeclaration() ast.Statement {
 	p.nextToken()
 	fn.Params = p.parseParameterList(token.RPAREN)
 
+	outerGenerator, outerCatch, outerLabels := p.inGenerator, p.inCatch, p.labels
+	p.inGenerator, p.inCatch, p.labels = fn.Generator, false, nil
+	defer func() { p.inGenerator, p.inCatch, p.labels = outerGenerator, outerCatch, outerLabels }()
+
 	if p.peekTokenIs(token.COLON) {
 		p.nextToken()
 		p.nextToken()
@@ -370,7 +511,7 @@ func (p *Parser) parseFunctionDeclaration() ast.Statement {
 }
 
 func (p *Parser) parseExtensionFunctionDeclaration() ast.Statement {
-	fn := &ast.FunctionDeclaration{Start: p.curToken.Pos, IsExtension: true}
+	fn := &ast.FunctionDeclaration{Start: p.curToken.Pos, Doc: p.curToken.Doc, IsExtension: true}
 	if !p.expectPeek(token.FN) {
 		return nil
 	}
@@ -408,6 +549,10 @@ func (p *Parser) parseExtensionFunctionDeclaration() ast.Statement {
 		fn.ReturnType = p.parseTypeAnnotation()
 	}
 
+	outerGenerator, outerCatch, outerLabels := p.inGenerator, p.inCatch, p.labels
+	p.inGenerator, p.inCatch, p.labels = false, false, nil
+	defer func() { p.inGenerator, p.inCatch, p.labels = outerGenerator, outerCatch, outerLabels }()
+
 	if p.peekTokenIs(token.ASYNC) {
 		p.nextToken()
 		fn.Async = true
@@ -444,12 +589,16 @@ func (p *Parser) parseExtensionFunctionDeclaration() ast.Statement {
 }
 
 func (p *Parser) parseClassDeclaration() ast.Statement {
-	class := &ast.ClassDeclaration{Start: p.curToken.Pos}
+	class := &ast.ClassDeclaration{Start: p.curToken.Pos, Doc: p.curToken.Doc}
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 	class.Name = p.currentIdentifier()
 
+	if p.peekTokenIs(token.LT) {
+		class.TypeParams = p.parseTypeParameters()
+	}
+
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
@@ -461,23 +610,140 @@ func (p *Parser) parseClassDeclaration() ast.Statement {
 		if !p.expectPeek(token.IDENT) {
 			return class
 		}
-		class.SuperClass = p.currentIdentifier()
+		if p.curToken.Literal != "implements" {
+			class.SuperClass = p.currentIdentifier()
+			if p.peekTokenIs(token.IDENT) && p.peekToken.Literal == "implements" {
+				p.nextToken()
+			}
+		}
+		if p.curToken.Literal == "implements" {
+			if !p.parseImplementsList(class) {
+				return class
+			}
+		}
 	}
 
 	if p.peekTokenIs(token.LBRACE) {
 		p.nextToken()
-		class.Body = p.parseBlockStatement()
-		if class.Body != nil {
-			class.Finish = class.Body.End()
-		}
+		class.Body = p.parseClassBody(class)
+		class.Finish = class.Body.End()
 	} else {
 		class.Finish = p.curToken.End
 	}
 	return class
 }
 
+// parseImplementsList parses the comma-separated contract names after
+// implements.
+func (p *Parser) parseImplementsList(class *ast.ClassDeclaration) bool {
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return false
+		}
+		class.Implements = append(class.Implements, p.currentIdentifier())
+		if !p.peekTokenIs(token.COMMA) {
+			return true
+		}
+		p.nextToken()
+	}
+}
+
+// parseAbstractClassDeclaration parses abstract class, moving the class's
+// start back to abstract.
+func (p *Parser) parseAbstractClassDeclaration() ast.Statement {
+	start, doc := p.curToken.Pos, p.curToken.Doc
+	p.nextToken()
+	class, ok := p.parseClassDeclaration().(*ast.ClassDeclaration)
+	if !ok {
+		return nil
+	}
+	class.Abstract, class.Start, class.Doc = true, start, cmp.Or(class.Doc, doc)
+	return class
+}
+
+// parseClassBody parses a class body like a block, except that a member may
+// also be a property accessor, written get name() { ... } or
+// set name(value) { ... } in place of fn, or carry a modifier: static on a
+// method, constant, or variable, and abstract or override on a method. The
+// body may also state invariants, as a contract body does.
+func (p *Parser) parseClassBody(class *ast.ClassDeclaration) *ast.BlockStatement {
+	block := &ast.BlockStatement{Start: p.curToken.Pos}
+	p.nextToken()
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		var stmt ast.Statement
+		switch {
+		case p.isInvariant():
+			if invariant := p.parseContractInvariant(); invariant != nil {
+				class.Invariants = append(class.Invariants, invariant)
+			}
+		case p.curTokenIs(token.IDENT) && (p.curToken.Literal == "get" || p.curToken.Literal == "set") && p.peekTokenIs(token.IDENT):
+			stmt = p.parseAccessorDeclaration()
+		case p.curTokenIs(token.IDENT) && p.curToken.Literal == "static" && (p.peekTokenIs(token.FN) || p.peekTokenIs(token.LET) || p.peekTokenIs(token.VAR)),
+			p.curTokenIs(token.IDENT) && (p.curToken.Literal == "abstract" || p.curToken.Literal == "override") && p.peekTokenIs(token.FN):
+			stmt = p.parseModifiedMember()
+		default:
+			stmt = p.parseStatement()
+		}
+		if stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+		p.nextToken()
+	}
+	block.Finish = p.curToken.End
+	return block
+}
+
+// parseModifiedMember parses a class member after its modifier, moving the
+// member's start back to the modifier.
+func (p *Parser) parseModifiedMember() ast.Statement {
+	modifier, start, doc := p.curToken.Literal, p.curToken.Pos, p.curToken.Doc
+	p.nextToken()
+	switch decl := p.parseStatement().(type) {
+	case *ast.VariableDeclaration:
+		decl.Static, decl.Start, decl.Doc = true, start, cmp.Or(decl.Doc, doc)
+		return decl
+	case *ast.FunctionDeclaration:
+		decl.Start, decl.Doc = start, cmp.Or(decl.Doc, doc)
+		switch modifier {
+		case "static":
+			decl.Static = true
+		case "override":
+			decl.Override = true
+		case "abstract":
+			decl.Abstract = true
+			if decl.Body != nil || decl.IsExprBody {
+				p.addError(start, fmt.Sprintf("abstract method %s cannot have a body", decl.Name.Name))
+			}
+			if p.peekTokenIs(token.SEMICOLON) {
+				p.nextToken()
+				decl.Finish = p.curToken.End
+			}
+		}
+		return decl
+	}
+	return nil
+}
+
+func (p *Parser) parseAccessorDeclaration() ast.Statement {
+	kind, pos := p.curToken.Literal, p.curToken.Pos
+	fn, ok := p.parseFunctionDeclaration().(*ast.FunctionDeclaration)
+	if !ok {
+		return nil
+	}
+	fn.Accessor = kind
+	switch {
+	case fn.Generator || fn.Async:
+		p.addError(pos, fmt.Sprintf("%s accessor %s cannot be async or a generator", kind, fn.Name.Name))
+	case kind == "get" && len(fn.Params) != 0:
+		p.addError(pos, fmt.Sprintf("getter %s must not take parameters", fn.Name.Name))
+	case kind == "set" && len(fn.Params) != 1:
+		p.addError(pos, fmt.Sprintf("setter %s must take exactly one parameter", fn.Name.Name))
+	}
+	return fn
+}
+
 func (p *Parser) parseStructDeclaration() ast.Statement {
-	st := &ast.StructDeclaration{Start: p.curToken.Pos}
+	st := &ast.StructDeclaration{Start: p.curToken.Pos, Doc: p.curToken.Doc}
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
@@ -502,7 +768,7 @@ func (p *Parser) parseStructDeclaration() ast.Statement {
 }
 
 func (p *Parser) parseInterfaceDeclaration() ast.Statement {
-	iface := &ast.InterfaceDeclaration{Start: p.curToken.Pos}
+	iface := &ast.InterfaceDeclaration{Start: p.curToken.Pos, Doc: p.curToken.Doc}
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
@@ -538,32 +804,52 @@ func (p *Parser) parseInterfaceDeclaration() ast.Statement {
 	return iface
 }
 
+// parseInterfaceMethod parses a method signature, which may carry a default
+// implementation written like a function body.
 func (p *Parser) parseInterfaceMethod() *ast.InterfaceMethod {
-	method := &ast.InterfaceMethod{Start: p.curToken.Pos}
-	if !p.expectPeek(token.IDENT) {
+	fn, ok := p.parseFunctionDeclaration().(*ast.FunctionDeclaration)
+	if !ok {
 		return nil
 	}
-	method.Name = p.currentIdentifier()
-
-	if !p.expectPeek(token.LPAREN) {
-		return method
+	method := &ast.InterfaceMethod{
+		Name:       fn.Name,
+		Doc:        fn.Doc,
+		Params:     fn.Params,
+		ReturnType: fn.ReturnType,
+		Start:      fn.Start,
+		Finish:     fn.Finish,
 	}
-
-	p.nextToken()
-	method.Params = p.parseParameterList(token.RPAREN)
-
-	if p.peekTokenIs(token.COLON) {
-		p.nextToken()
-		p.nextToken()
-		method.ReturnType = p.parseTypeAnnotation()
+	if fn.Body != nil || fn.IsExprBody {
+		method.Default = fn
 	}
-
-	method.Finish = p.curToken.End
 	return method
 }
 
+func (p *Parser) parseImplDeclaration() ast.Statement {
+	impl := &ast.ImplDeclaration{Start: p.curToken.Pos, Doc: p.curToken.Doc}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	impl.Interface = p.currentIdentifier()
+	if !p.expectPeek(token.FOR) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	impl.Target = p.currentIdentifier()
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	impl.Body = p.parseBlockStatement()
+	if impl.Body != nil {
+		impl.Finish = impl.Body.End()
+	}
+	return impl
+}
+
 func (p *Parser) parseEnumDeclaration() ast.Statement {
-	enumNode := &ast.EnumDeclaration{Start: p.curToken.Pos}
+	enumNode := &ast.EnumDeclaration{Start: p.curToken.Pos, Doc: p.curToken.Doc}
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
@@ -578,6 +864,19 @@ func (p *Parser) parseEnumDeclaration() ast.Statement {
 	}
 	p.nextToken()
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		if p.curTokenIs(token.FN) || p.curTokenIs(token.AT) {
+			pos := p.curToken.Pos
+			switch stmt := p.parseStatement().(type) {
+			case *ast.FunctionDeclaration:
+				enumNode.Methods = append(enumNode.Methods, stmt)
+				enumNode.Finish = stmt.End()
+			case nil:
+			default:
+				p.addError(pos, "an enum body may only declare cases and methods")
+			}
+			p.nextToken()
+			continue
+		}
 		caseNode := p.parseEnumCase()
 		if caseNode != nil {
 			enumNode.Cases = append(enumNode.Cases, *caseNode)
@@ -592,7 +891,7 @@ func (p *Parser) parseEnumDeclaration() ast.Statement {
 }
 
 func (p *Parser) parseEnumCase() *ast.EnumCase {
-	caseNode := &ast.EnumCase{Start: p.curToken.Pos}
+	caseNode := &ast.EnumCase{Start: p.curToken.Pos, Doc: p.curToken.Doc}
 	if p.curToken.Type != token.IDENT {
 		p.addError(p.curToken.Pos, fmt.Sprintf("expected enum case identifier, got %s", p.curToken.Type))
 		return nil
@@ -613,7 +912,7 @@ func (p *Parser) parseEnumCase() *ast.EnumCase {
 }
 
 func (p *Parser) parseContractDeclaration() ast.Statement {
-	contract := &ast.ContractDeclaration{Start: p.curToken.Pos}
+	contract := &ast.ContractDeclaration{Start: p.curToken.Pos, Doc: p.curToken.Doc}
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
@@ -622,13 +921,67 @@ func (p *Parser) parseContractDeclaration() ast.Statement {
 	if !p.expectPeek(token.LBRACE) {
 		return contract
 	}
-	contract.Body = p.parseBlockStatement()
-	if contract.Body != nil {
-		contract.Finish = contract.Body.End()
-	}
+	contract.Body = p.parseContractBody(contract)
+	contract.Finish = contract.Body.End()
 	return contract
 }
 
+// parseContractBody parses a contract body like a block, except that it
+// may also hold invariant conditions, written invariant expression;, and
+// required functions, written as a signature ending in ; with no body.
+func (p *Parser) parseContractBody(contract *ast.ContractDeclaration) *ast.BlockStatement {
+	block := &ast.BlockStatement{Start: p.curToken.Pos}
+	p.nextToken()
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		if p.isInvariant() {
+			if invariant := p.parseContractInvariant(); invariant != nil {
+				contract.Invariants = append(contract.Invariants, invariant)
+			}
+			p.nextToken()
+			continue
+		}
+		stmt := p.parseStatement()
+		if fn, ok := stmt.(*ast.FunctionDeclaration); ok && fn.Body == nil && !fn.IsExprBody && p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+			fn.Finish = p.curToken.End
+		}
+		if stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+		p.nextToken()
+	}
+	block.Finish = p.curToken.End
+	return block
+}
+
+// isInvariant reports whether the current token starts an invariant rather
+// than an expression that uses a binding named invariant.
+func (p *Parser) isInvariant() bool {
+	if !p.curTokenIs(token.IDENT) || p.curToken.Literal != "invariant" {
+		return false
+	}
+	switch p.peekToken.Type {
+	case token.ASSIGN, token.PLUS_ASSIGN, token.MINUS_ASSIGN, token.STAR_ASSIGN, token.SLASH_ASSIGN, token.PERCENT_ASSIGN,
+		token.DOT, token.SAFE_DOT, token.LBRACKET, token.SAFE_INDEX, token.SEMICOLON, token.RBRACE, token.EOF:
+		return false
+	}
+	return true
+}
+
+func (p *Parser) parseContractInvariant() *ast.ContractInvariant {
+	invariant := &ast.ContractInvariant{Start: p.curToken.Pos}
+	p.nextToken()
+	invariant.Condition = p.parseExpression(LOWEST)
+	if invariant.Condition == nil {
+		return nil
+	}
+	if !p.expectPeek(token.SEMICOLON) {
+		return nil
+	}
+	invariant.Finish = p.curToken.End
+	return invariant
+}
+
 func (p *Parser) parseImportDeclaration() ast.Statement {
 	imp := &ast.ImportDeclaration{Start: p.curToken.Pos}
 
@@ -654,6 +1007,9 @@ func (p *Parser) parseImportDeclaration() ast.Statement {
 			}
 			imp.Path = append(imp.Path, &ast.Identifier{Name: segment, Start: p.curToken.Pos, Finish: p.curToken.End})
 		}
+		if len(imp.Path) == 0 {
+			p.addError(p.curToken.Pos, "import path cannot be empty")
+		}
 	case token.IDENT:
 		imp.Path = append(imp.Path, p.currentIdentifier())
 		for p.peekTokenIs(token.DOT) {
@@ -683,6 +1039,12 @@ func (p *Parser) parseImportDeclaration() ast.Statement {
 		imp.Alias = p.currentIdentifier()
 	}
 
+	if imp.PathLiteral != "" && imp.Alias == nil && len(imp.Path) > 0 {
+		if last := imp.Path[len(imp.Path)-1].Name; !lexer.IsIdentifier(last) {
+			p.addError(imp.Start, fmt.Sprintf("import path %q ends in %q, which is not a valid identifier; add an alias (import name %q;)", imp.PathLiteral, last, imp.PathLiteral))
+		}
+	}
+
 	if !p.expectPeek(token.SEMICOLON) {
 		return imp
 	}
@@ -733,6 +1095,30 @@ func (p *Parser) parseWhileStatement() ast.Statement {
 	return stmt
 }
 
+func (p *Parser) parseDoWhileStatement() ast.Statement {
+	stmt := &ast.DoWhileStatement{Start: p.curToken.Pos, Finish: p.curToken.End}
+	if !p.expectPeek(token.LBRACE) {
+		return stmt
+	}
+	stmt.Body = p.parseBlockStatement()
+	if stmt.Body != nil {
+		stmt.Finish = stmt.Body.End()
+	}
+	if !p.expectPeek(token.WHILE) {
+		return stmt
+	}
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+	if stmt.Condition != nil {
+		stmt.Finish = stmt.Condition.End()
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+		stmt.Finish = p.curToken.End
+	}
+	return stmt
+}
+
 func (p *Parser) parseForStatement() ast.Statement {
 	stmt := &ast.ForStatement{Start: p.curToken.Pos}
 	if !p.expectPeek(token.LPAREN) {
@@ -740,9 +1126,20 @@ func (p *Parser) parseForStatement() ast.Statement {
 	}
 	p.nextToken()
 
+	if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.IN) {
+		return p.parseForInStatement(stmt.Start)
+	}
+
 	if !p.curTokenIs(token.SEMICOLON) {
 		if p.curToken.Type == token.LET || p.curToken.Type == token.VAR {
-			init := p.parseVariableDeclaration()
+			decl := &ast.VariableDeclaration{Start: p.curToken.Pos, Mutable: p.curToken.Type == token.VAR}
+			if !p.expectPeek(token.IDENT) {
+				return stmt
+			}
+			if p.peekTokenIs(token.IN) {
+				return p.parseForInStatement(stmt.Start)
+			}
+			init := p.parseVariableDeclarationRest(decl)
 			stmt.Init = init
 			if init != nil {
 				stmt.Finish = init.End()
@@ -796,6 +1193,27 @@ func (p *Parser) parseForStatement() ast.Statement {
 	return stmt
 }
 
+// parseForInStatement parses `for (name in iterable) { ... }` with the binding
+// name as the current token.
+func (p *Parser) parseForInStatement(start token.Position) ast.Statement {
+	stmt := &ast.ForInStatement{Start: start, Binding: p.currentIdentifier()}
+	p.nextToken()
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return stmt
+	}
+	stmt.Finish = p.curToken.End
+	if !p.expectPeek(token.LBRACE) {
+		return stmt
+	}
+	stmt.Body = p.parseBlockStatement()
+	if stmt.Body != nil {
+		stmt.Finish = stmt.Body.End()
+	}
+	return stmt
+}
+
 func (p *Parser) parseUsingStatement() ast.Statement {
 	stmt := &ast.UsingStatement{Start: p.curToken.Pos}
 	p.nextToken()
@@ -858,8 +1276,65 @@ func (p *Parser) parseReturnStatement() ast.Statement {
 	return stmt
 }
 
+// parseLabeledLoop parses label: and the for or while loop it names.
+func (p *Parser) parseLabeledLoop() ast.Statement {
+	label, start := p.curToken.Literal, p.curToken.Pos
+	p.nextToken()
+	if !p.peekTokenIs(token.FOR) && !p.peekTokenIs(token.WHILE) && !p.peekTokenIs(token.DO) {
+		p.addError(p.peekToken.Pos, fmt.Sprintf("label %s must be followed by a for, while, or do loop", label))
+		return nil
+	}
+	if slices.Contains(p.labels, label) {
+		p.addError(start, fmt.Sprintf("label %s is already used by an enclosing loop", label))
+	}
+	p.nextToken()
+	p.labels = append(p.labels, label)
+	stmt := p.parseStatement()
+	p.labels = p.labels[:len(p.labels)-1]
+	switch loop := stmt.(type) {
+	case *ast.WhileStatement:
+		loop.Label, loop.Start = label, start
+	case *ast.DoWhileStatement:
+		loop.Label, loop.Start = label, start
+	case *ast.ForStatement:
+		loop.Label, loop.Start = label, start
+	case *ast.ForInStatement:
+		loop.Label, loop.Start = label, start
+	}
+	return stmt
+}
+
+// parseJumpLabel parses the label after break or continue, which must be on
+// the same line and name an enclosing loop.
+func (p *Parser) parseJumpLabel(keyword string) (string, token.Position) {
+	if !p.peekTokenIs(token.IDENT) || p.peekToken.Pos.Line != p.curToken.Pos.Line {
+		return "", p.curToken.End
+	}
+	p.nextToken()
+	label := p.curToken.Literal
+	if !slices.Contains(p.labels, label) {
+		p.addError(p.curToken.Pos, fmt.Sprintf("%s %s names no enclosing loop", keyword, label))
+	}
+	return label, p.curToken.End
+}
+
 func (p *Parser) parseBreakStatement() ast.Statement {
-	stmt := &ast.BreakStatement{Start: p.curToken.Pos, Finish: p.curToken.End}
+	stmt := &ast.BreakStatement{Start: p.curToken.Pos}
+	stmt.Label, stmt.Finish = p.parseJumpLabel("break")
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+		stmt.Finish = p.curToken.End
+	}
+	return stmt
+}
+
+// parseRethrowStatement parses rethrow, which is only a statement in a catch
+// clause; elsewhere rethrow is an ordinary name.
+func (p *Parser) parseRethrowStatement() ast.Statement {
+	stmt := &ast.RethrowStatement{Start: p.curToken.Pos, Finish: p.curToken.End}
+	if !p.inCatch {
+		p.addError(stmt.Start, "rethrow is only allowed in a catch clause")
+	}
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 		stmt.Finish = p.curToken.End
@@ -868,7 +1343,8 @@ func (p *Parser) parseBreakStatement() ast.Statement {
 }
 
 func (p *Parser) parseContinueStatement() ast.Statement {
-	stmt := &ast.ContinueStatement{Start: p.curToken.Pos, Finish: p.curToken.End}
+	stmt := &ast.ContinueStatement{Start: p.curToken.Pos}
+	stmt.Label, stmt.Finish = p.parseJumpLabel("continue")
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 		stmt.Finish = p.curToken.End
@@ -885,6 +1361,22 @@ func (p *Parser) parseMatchStatement() ast.Statement {
 	}
 	p.nextToken()
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		if match.Else != nil {
+			p.addError(p.curToken.Pos, "the else arm must be the last arm of a match")
+			return match
+		}
+		if p.curTokenIs(token.ELSE) {
+			if !p.expectPeek(token.ARROW) {
+				return match
+			}
+			p.nextToken()
+			match.Else = p.parseStatement()
+			if match.Else != nil {
+				match.Finish = match.Else.End()
+			}
+			p.nextToken()
+			continue
+		}
 		caseNode := p.parseMatchCase()
 		if caseNode != nil {
 			match.Cases = append(match.Cases, *caseNode)
@@ -898,10 +1390,21 @@ func (p *Parser) parseMatchStatement() ast.Statement {
 	return match
 }
 
+// parseMatchCase parses an arm such as `1, 2 => ...`, whose patterns must
+// bind the same names so that the body can use them whichever one matched.
 func (p *Parser) parseMatchCase() *ast.MatchCase {
 	caseNode := &ast.MatchCase{Start: p.curToken.Pos}
-	pattern := p.parsePattern()
-	caseNode.Pattern = pattern
+	caseNode.Patterns = append(caseNode.Patterns, p.parsePattern())
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		pos := p.curToken.Pos
+		pattern := p.parsePattern()
+		if pattern != nil && caseNode.Patterns[0] != nil && !sameBindings(caseNode.Patterns[0], pattern) {
+			p.addError(pos, "each pattern of a match arm must bind the same names")
+		}
+		caseNode.Patterns = append(caseNode.Patterns, pattern)
+	}
 
 	if !p.expectPeek(token.ARROW) {
 		return caseNode
@@ -915,6 +1418,18 @@ func (p *Parser) parseMatchCase() *ast.MatchCase {
 	return caseNode
 }
 
+func sameBindings(a, b ast.Pattern) bool {
+	names := func(pattern ast.Pattern) []string {
+		var out []string
+		for _, id := range ast.PatternBindings(pattern) {
+			out = append(out, id.Name)
+		}
+		slices.Sort(out)
+		return out
+	}
+	return slices.Equal(names(a), names(b))
+}
+
 func (p *Parser) parseTryStatement() ast.Statement {
 	stmt := &ast.TryStatement{Start: p.curToken.Pos}
 	if !p.expectPeek(token.LBRACE) {
@@ -925,12 +1440,11 @@ func (p *Parser) parseTryStatement() ast.Statement {
 		stmt.Finish = stmt.Body.End()
 	}
 
-	if p.peekTokenIs(token.CATCH) {
+	for p.peekTokenIs(token.CATCH) {
 		p.nextToken()
-		stmt.Catch = p.parseCatchClause()
-		if stmt.Catch != nil {
-			stmt.Finish = stmt.Catch.End()
-		}
+		clause := p.parseCatchClause()
+		stmt.Catches = append(stmt.Catches, clause)
+		stmt.Finish = clause.End()
 	}
 
 	if p.peekTokenIs(token.FINALLY) {
@@ -957,6 +1471,11 @@ func (p *Parser) parseCatchClause() *ast.CatchClause {
 		if p.peekTokenIs(token.IDENT) {
 			p.nextToken()
 			clause.Identifier = p.currentIdentifier()
+			if p.peekTokenIs(token.COLON) {
+				p.nextToken()
+				p.nextToken()
+				clause.Type = p.parseTypeAnnotation()
+			}
 		}
 		if !p.expectPeek(token.RPAREN) {
 			return clause
@@ -966,7 +1485,10 @@ func (p *Parser) parseCatchClause() *ast.CatchClause {
 	if !p.expectPeek(token.LBRACE) {
 		return clause
 	}
+	outerCatch := p.inCatch
+	p.inCatch = true
 	clause.Body = p.parseBlockStatement()
+	p.inCatch = outerCatch
 	if clause.Body != nil {
 		clause.Finish = clause.Body.End()
 	}
@@ -1094,7 +1616,7 @@ func (p *Parser) parseParameterList(end token.Type) []ast.Parameter {
 }
 
 func (p *Parser) parseParameter() ast.Parameter {
-	param := ast.Parameter{}
+	param := ast.Parameter{Doc: p.curToken.Doc}
 	if p.curToken.Type != token.IDENT {
 		p.addError(p.curToken.Pos, fmt.Sprintf("expected parameter name, got %s", p.curToken.Type))
 		return param
@@ -1150,7 +1672,7 @@ func (p *Parser) parseContractBlock() *ast.ContractBlock {
 	}
 	p.nextToken()
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		clause := p.parseContractClause()
+		clause := p.parseContractClause(block)
 		if clause != nil {
 			block.Clauses = append(block.Clauses, *clause)
 		}
@@ -1160,15 +1682,34 @@ func (p *Parser) parseContractBlock() *ast.ContractBlock {
 	return block
 }
 
-func (p *Parser) parseContractClause() *ast.ContractClause {
-	clause := &ast.ContractClause{Start: p.curToken.Pos}
-	if p.curToken.Type != token.RETURNS {
-		p.addError(p.curToken.Pos, fmt.Sprintf("expected 'returns' in contract clause, got %s", p.curToken.Type))
+// parseContractClause parses returns(guard) => condition;,
+// requires(condition);, or ensures(condition);. The postconditions, returns
+// and ensures, may refer to old(expr).
+func (p *Parser) parseContractClause(block *ast.ContractBlock) *ast.ContractClause {
+	clause := &ast.ContractClause{Start: p.curToken.Pos, Kind: p.curToken.Literal}
+	switch {
+	case p.curTokenIs(token.RETURNS):
+	case p.curTokenIs(token.IDENT) && (p.curToken.Literal == "requires" || p.curToken.Literal == "ensures"):
+	default:
+		p.addError(p.curToken.Pos, fmt.Sprintf("expected 'returns', 'requires', or 'ensures' in contract clause, got %s", p.curToken.Type))
 		return nil
 	}
+	if clause.Kind != "requires" {
+		p.olds = block
+		defer func() { p.olds = nil }()
+	}
 	if !p.expectPeek(token.LPAREN) {
 		return clause
 	}
+	if clause.Kind != "returns" {
+		p.nextToken()
+		clause.Condition = p.parseExpression(LOWEST)
+		if !p.expectPeek(token.RPAREN) || !p.expectPeek(token.SEMICOLON) {
+			return clause
+		}
+		clause.Finish = p.curToken.End
+		return clause
+	}
 	if !p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
 		clause.Guard = p.parseExpression(LOWEST)
@@ -1192,10 +1733,12 @@ func (p *Parser) parsePattern() ast.Pattern {
 	switch p.curToken.Type {
 	case token.NUMBER:
 		node := &ast.NumberLiteral{Value: p.curToken.Literal, Start: p.curToken.Pos, Finish: p.curToken.End}
+		if p.peekTokenIs(token.DOTDOT) || p.peekTokenIs(token.DOTDOT_EQ) {
+			return p.parseRangePattern(node)
+		}
 		return &ast.LiteralPattern{Value: node}
 	case token.STRING:
-		node := &ast.StringLiteral{Value: p.curToken.Literal, Start: p.curToken.Pos, Finish: p.curToken.End}
-		return &ast.LiteralPattern{Value: node}
+		return &ast.LiteralPattern{Value: p.parseStringLiteral()}
 	case token.TRUE, token.FALSE:
 		node := &ast.BooleanLiteral{Value: p.curToken.Type == token.TRUE, Start: p.curToken.Pos, Finish: p.curToken.End}
 		return &ast.LiteralPattern{Value: node}
@@ -1210,12 +1753,27 @@ func (p *Parser) parsePattern() ast.Pattern {
 		return &ast.IdentifierPattern{Identifier: ident}
 	case token.LBRACE:
 		return p.parseObjectPattern()
+	case token.LBRACKET:
+		return p.parseArrayPattern()
 	default:
 		p.addError(p.curToken.Pos, fmt.Sprintf("unexpected token in pattern: %s", p.curToken.Type))
 		return nil
 	}
 }
 
+// parseRangePattern parses the rest of a pattern such as 1..10 or 1..=10,
+// which matches the Numbers the range holds.
+func (p *Parser) parseRangePattern(from *ast.NumberLiteral) ast.Pattern {
+	p.nextToken()
+	rng := &ast.InfixExpression{Left: from, Operator: p.curToken.Literal, Start: from.Pos()}
+	if !p.expectPeek(token.NUMBER) {
+		return nil
+	}
+	rng.Right = &ast.NumberLiteral{Value: p.curToken.Literal, Start: p.curToken.Pos, Finish: p.curToken.End}
+	rng.Finish = p.curToken.End
+	return &ast.LiteralPattern{Value: rng}
+}
+
 func (p *Parser) parseStructPattern(name *ast.Identifier) ast.Pattern {
 	pattern := &ast.StructPattern{Name: name, Start: name.Pos()}
 	if !p.expectPeek(token.LPAREN) {
@@ -1259,6 +1817,35 @@ func (p *Parser) parseObjectPattern() ast.Pattern {
 	return pattern
 }
 
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Start: p.curToken.Pos}
+	p.nextToken()
+	for !p.curTokenIs(token.RBRACKET) {
+		if p.curTokenIs(token.ELLIPSIS) {
+			pattern.HasRest = true
+			if p.peekTokenIs(token.IDENT) {
+				p.nextToken()
+				pattern.Rest = p.currentIdentifier()
+			}
+			if !p.expectPeek(token.RBRACKET) {
+				return pattern
+			}
+			break
+		}
+		pattern.Elements = append(pattern.Elements, p.parsePattern())
+		if p.peekTokenIs(token.RBRACKET) {
+			p.nextToken()
+			break
+		}
+		if !p.expectPeek(token.COMMA) {
+			return pattern
+		}
+		p.nextToken()
+	}
+	pattern.Finish = p.curToken.End
+	return pattern
+}
+
 func (p *Parser) parsePatternPair() ast.PatternPair {
 	pair := ast.PatternPair{}
 	keyToken := p.curToken
@@ -1277,25 +1864,81 @@ func (p *Parser) parsePatternPair() ast.PatternPair {
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
+	if p.olds != nil && p.curToken.Literal == "old" && p.peekTokenIs(token.LPAREN) {
+		return p.parseOldExpression()
+	}
 	return p.currentIdentifier()
 }
 
+func (p *Parser) parseOldExpression() ast.Expression {
+	old := &ast.OldExpression{Start: p.curToken.Pos, Index: len(p.olds.Olds)}
+	p.olds.Olds = append(p.olds.Olds, old)
+	p.nextToken()
+	p.nextToken()
+	old.Value = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return old
+	}
+	old.Finish = p.curToken.End
+	return old
+}
+
 func (p *Parser) parseNumberLiteral() ast.Expression {
 	lit := &ast.NumberLiteral{Value: p.curToken.Literal, Start: p.curToken.Pos, Finish: p.curToken.End}
 	return lit
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
-	lit := &ast.StringLiteral{Value: p.curToken.Literal, Start: p.curToken.Pos, Finish: p.curToken.End}
+	lit := &ast.StringLiteral{Value: p.curToken.Literal, Heredoc: p.curToken.Heredoc, Start: p.curToken.Pos, Finish: p.curToken.End}
 	switch p.curToken.Type {
 	case token.RAWSTRING:
 		lit.Raw = true
 	case token.FORMATSTRING:
 		lit.Format = true
 	}
+	if !lit.Raw {
+		var escape *lexer.EscapeError
+		if errors.As(lexer.ValidateEscapes(lit.Value), &escape) {
+			p.addError(escapePosition(p.curToken, escape.Offset), escape.Error())
+		}
+	}
 	return lit
 }
 
+// escapePosition locates the byte at offset in the text of the string token
+// tok. A heredoc's indentation is gone from its text, so its escapes are
+// reported at the start of the token.
+func escapePosition(tok token.Token, offset int) token.Position {
+	pos := tok.Pos
+	if tok.Heredoc != "" {
+		return pos
+	}
+	text := tok.Literal
+	delimiters := tok.End.Offset - tok.Pos.Offset - len(text)
+	if tok.Type == token.FORMATSTRING {
+		pos.Offset++
+		pos.Column++
+		delimiters--
+	}
+	if delimiters >= 3 {
+		pos.Offset += 3
+		pos.Column += 3
+	} else {
+		pos.Offset++
+		pos.Column++
+	}
+	for _, r := range text[:offset] {
+		pos.Offset += utf8.RuneLen(r)
+		if r == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+	return pos
+}
+
 func (p *Parser) parseBooleanLiteral() ast.Expression {
 	return &ast.BooleanLiteral{Value: p.curToken.Type == token.TRUE, Start: p.curToken.Pos, Finish: p.curToken.End}
 }
@@ -1346,6 +1989,27 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 	return array
 }
 
+func (p *Parser) parseSetLiteral() ast.Expression {
+	set := &ast.SetLiteral{Start: p.curToken.Pos}
+	if p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		set.Finish = p.curToken.End
+		return set
+	}
+	p.nextToken()
+	set.Elements = append(set.Elements, p.parseExpression(LOWEST))
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		set.Elements = append(set.Elements, p.parseExpression(LOWEST))
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return set
+	}
+	set.Finish = p.curToken.End
+	return set
+}
+
 func (p *Parser) parseObjectLiteral() ast.Expression {
 	obj := &ast.ObjectLiteral{Start: p.curToken.Pos}
 	if p.peekTokenIs(token.RBRACE) {
@@ -1394,6 +2058,23 @@ func (p *Parser) parseAwaitExpression() ast.Expression {
 	return expr
 }
 
+func (p *Parser) parseYieldExpression() ast.Expression {
+	expr := &ast.YieldExpression{Start: p.curToken.Pos, Finish: p.curToken.End}
+	if !p.inGenerator {
+		p.addError(p.curToken.Pos, "yield is only allowed inside generator functions declared with fn name*()")
+	}
+	if p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RPAREN) || p.peekTokenIs(token.RBRACE) ||
+		p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.EOF) {
+		return expr
+	}
+	p.nextToken()
+	expr.Value = p.parseExpression(ASSIGNMENT)
+	if expr.Value != nil {
+		expr.Finish = expr.Value.End()
+	}
+	return expr
+}
+
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	expr := &ast.InfixExpression{Left: left, Operator: p.curToken.Literal, Start: left.Pos()}
 	precedence := p.curPrecedence()
@@ -1436,7 +2117,7 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Collection: left, Start: left.Pos()}
+	exp := &ast.IndexExpression{Collection: left, Optional: p.curToken.Type == token.SAFE_INDEX, Start: left.Pos()}
 	p.nextToken()
 	exp.Index = p.parseExpression(LOWEST)
 	if !p.expectPeek(token.RBRACKET) {
@@ -1448,7 +2129,10 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 
 func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
 	member := &ast.MemberExpression{Object: object, Optional: p.curToken.Type == token.SAFE_DOT, Start: object.Pos()}
-	if !p.expectPeek(token.IDENT) {
+	if p.peekToken.Type != token.IDENT && token.LookupIdent(p.peekToken.Literal) == p.peekToken.Type {
+		// Keywords are valid property names after a dot, as in pattern.match(text).
+		p.nextToken()
+	} else if !p.expectPeek(token.IDENT) {
 		return member
 	}
 	member.Property = p.curToken.Literal
@@ -1461,6 +2145,14 @@ func (p *Parser) parseNonNullAssertion(left ast.Expression) ast.Expression {
 	return assertion
 }
 
+func (p *Parser) parsePropagateExpression(left ast.Expression) ast.Expression {
+	return &ast.PropagateExpression{Expression: left, Start: left.Pos(), Finish: p.curToken.End}
+}
+
+func (p *Parser) parseIncrementExpression(target ast.Expression) ast.Expression {
+	return &ast.IncrementExpression{Target: target, Operator: p.curToken.Literal, Start: target.Pos(), Finish: p.curToken.End}
+}
+
 func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
 	list := []ast.Expression{}
 	if p.peekTokenIs(end) {
@@ -1,10 +1,12 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/cybellereaper/selenelang/internal/ast"
 	"github.com/cybellereaper/selenelang/internal/lexer"
+	"github.com/cybellereaper/selenelang/internal/printer"
 )
 
 func parseProgram(t *testing.T, src string) *ast.Program {
@@ -154,6 +156,100 @@ contract Loggable {
 	}
 }
 
+func TestParserParsesInterfaceDefaultsAndImplBlocks(t *testing.T) {
+	source := `
+interface Runnable {
+    fn run(): Void;
+    fn runTwice(): Void {
+        this.run();
+        this.run();
+    }
+}
+impl Runnable for Pair {
+    fn run(): Void => print(this.left);
+}
+`
+
+	program := parseProgram(t, source)
+	if len(program.Items) != 2 {
+		t.Fatalf("expected 2 declarations, got %d", len(program.Items))
+	}
+
+	ifaceDecl, ok := program.Items[0].(*ast.InterfaceDeclaration)
+	if !ok || len(ifaceDecl.Methods) != 2 {
+		t.Fatalf("expected interface with two methods, got %T", program.Items[0])
+	}
+	if ifaceDecl.Methods[0].Default != nil {
+		t.Fatalf("expected run to be required")
+	}
+	if def := ifaceDecl.Methods[1].Default; def == nil || def.Body == nil || len(def.Body.Statements) != 2 {
+		t.Fatalf("expected runTwice to carry a default body, got %#v", def)
+	}
+
+	impl, ok := program.Items[1].(*ast.ImplDeclaration)
+	if !ok || impl.Interface.Name != "Runnable" || impl.Target.Name != "Pair" {
+		t.Fatalf("expected impl Runnable for Pair, got %T", program.Items[1])
+	}
+	if impl.Body == nil || len(impl.Body.Statements) != 1 {
+		t.Fatalf("expected impl body with one method")
+	}
+}
+
+func TestParserParsesGenericClassDeclarations(t *testing.T) {
+	program := parseProgram(t, "class Point<T>(x: T, y: T): Shape {}\nclass Plain(x: Number) {}\n")
+	if len(program.Items) != 2 {
+		t.Fatalf("expected 2 declarations, got %d", len(program.Items))
+	}
+	generic, ok := program.Items[0].(*ast.ClassDeclaration)
+	if !ok || generic.SuperClass.Name != "Shape" {
+		t.Fatalf("expected class declaration with superclass Shape, got %T", program.Items[0])
+	}
+	if len(generic.TypeParams) != 1 || generic.TypeParams[0].Name != "T" {
+		t.Fatalf("expected class type parameter T, got %v", generic.TypeParams)
+	}
+	if plain, ok := program.Items[1].(*ast.ClassDeclaration); !ok || len(plain.TypeParams) != 0 {
+		t.Fatalf("expected a class without type parameters, got %#v", program.Items[1])
+	}
+}
+
+func TestParserAttachesDocComments(t *testing.T) {
+	source := `
+/// A point on the plane.
+/// Coordinates are in pixels.
+struct Point(
+    /// Distance from the left edge.
+    x: Number,
+    y: Number
+) {}
+
+/// Dropped by the blank line.
+
+// An ordinary comment in between also drops it.
+fn plain() {}
+
+//// Four slashes are an ordinary comment.
+enum Status {
+    /// Waiting to start.
+    Ready;
+}
+`
+	program := parseProgram(t, source)
+	point := program.Items[0].(*ast.StructDeclaration)
+	if point.Doc != "A point on the plane.\nCoordinates are in pixels." {
+		t.Fatalf("unexpected struct doc %q", point.Doc)
+	}
+	if point.Params[0].Doc != "Distance from the left edge." || point.Params[1].Doc != "" {
+		t.Fatalf("unexpected field docs %q and %q", point.Params[0].Doc, point.Params[1].Doc)
+	}
+	if plain := program.Items[1].(*ast.FunctionDeclaration); plain.Doc != "" {
+		t.Fatalf("expected no doc on plain, got %q", plain.Doc)
+	}
+	status := program.Items[2].(*ast.EnumDeclaration)
+	if status.Doc != "" || status.Cases[0].Doc != "Waiting to start." {
+		t.Fatalf("unexpected enum docs %q and %q", status.Doc, status.Cases[0].Doc)
+	}
+}
+
 func TestParserParsesControlFlow(t *testing.T) {
 	source := `
 fn main() {
@@ -200,7 +296,7 @@ fn main() {
 	if usingStmt, ok := stmts[3].(*ast.UsingStatement); !ok || usingStmt.Name.Name != "resource" {
 		t.Fatalf("expected using statement with resource binding, got %T", stmts[3])
 	}
-	if tryStmt, ok := stmts[4].(*ast.TryStatement); !ok || tryStmt.Catch == nil || tryStmt.Finally == nil {
+	if tryStmt, ok := stmts[4].(*ast.TryStatement); !ok || len(tryStmt.Catches) == 0 || tryStmt.Finally == nil {
 		t.Fatalf("expected try statement with catch and finally, got %T", stmts[4])
 	}
 	if condStmt, ok := stmts[5].(*ast.ConditionStatement); !ok || len(condStmt.Clauses) != 1 || condStmt.Else == nil {
@@ -211,6 +307,45 @@ fn main() {
 	}
 }
 
+func TestParserParsesArrayPatterns(t *testing.T) {
+	source := `
+let [first, [inner], ...rest] = items;
+match items {
+    [] => return;
+    [only, ...] => return;
+}
+`
+	program := parseProgram(t, source)
+	decl, ok := program.Items[0].(*ast.VariableDeclaration)
+	if !ok || decl.Name != nil {
+		t.Fatalf("expected destructuring declaration, got %T", program.Items[0])
+	}
+	pattern, ok := decl.Pattern.(*ast.ArrayPattern)
+	if !ok || len(pattern.Elements) != 2 || !pattern.HasRest || pattern.Rest == nil || pattern.Rest.Name != "rest" {
+		t.Fatalf("expected [first, [inner], ...rest], got %#v", decl.Pattern)
+	}
+	if _, ok := pattern.Elements[1].(*ast.ArrayPattern); !ok {
+		t.Fatalf("expected nested array pattern, got %T", pattern.Elements[1])
+	}
+	names := []string{}
+	for _, id := range ast.PatternBindings(pattern) {
+		names = append(names, id.Name)
+	}
+	if strings.Join(names, ",") != "first,inner,rest" {
+		t.Fatalf("unexpected bindings %v", names)
+	}
+
+	match := program.Items[1].(*ast.MatchStatement)
+	empty, ok := match.Cases[0].Patterns[0].(*ast.ArrayPattern)
+	if !ok || len(empty.Elements) != 0 || empty.HasRest {
+		t.Fatalf("expected empty array pattern, got %#v", match.Cases[0].Patterns[0])
+	}
+	bare, ok := match.Cases[1].Patterns[0].(*ast.ArrayPattern)
+	if !ok || len(bare.Elements) != 1 || !bare.HasRest || bare.Rest != nil {
+		t.Fatalf("expected [only, ...], got %#v", match.Cases[1].Patterns[0])
+	}
+}
+
 func TestParserParsesExpressionFeatures(t *testing.T) {
 	source := `
 let result = await compute()?.value!! ?: 0;
@@ -249,3 +384,531 @@ let composite = { label: "ok", count: [1, 2, 3][0] };
 		t.Fatalf("expected index expression in object value, got %T", obj.Pairs[1].Value)
 	}
 }
+
+func TestParserParsesSetLiterals(t *testing.T) {
+	program := parseProgram(t, `let tags = #{"a", "b", #{}};`)
+	tagsDecl := program.Items[0].(*ast.VariableDeclaration)
+	set, ok := tagsDecl.Value.(*ast.SetLiteral)
+	if !ok || len(set.Elements) != 3 {
+		t.Fatalf("expected set literal with three elements, got %T", tagsDecl.Value)
+	}
+	if inner, ok := set.Elements[2].(*ast.SetLiteral); !ok || len(inner.Elements) != 0 {
+		t.Fatalf("expected an empty nested set, got %T", set.Elements[2])
+	}
+}
+
+func TestParserParsesPropagation(t *testing.T) {
+	source := `
+let port = parse(text)?.value + 1;
+let count: Number? = items()? ?: 0;
+`
+
+	program := parseProgram(t, source)
+	if len(program.Items) != 2 {
+		t.Fatalf("expected two declarations, got %d", len(program.Items))
+	}
+
+	portDecl := program.Items[0].(*ast.VariableDeclaration)
+	sum, ok := portDecl.Value.(*ast.InfixExpression)
+	if !ok {
+		t.Fatalf("expected infix expression, got %T", portDecl.Value)
+	}
+	if member, ok := sum.Left.(*ast.MemberExpression); !ok || !member.Optional {
+		t.Fatalf("expected ?. to stay a safe member access, got %T", sum.Left)
+	}
+
+	countDecl := program.Items[1].(*ast.VariableDeclaration)
+	if countDecl.Type == nil || !countDecl.Type.Nullable {
+		t.Fatalf("expected a nullable type annotation")
+	}
+	fallback, ok := countDecl.Value.(*ast.ElvisExpression)
+	if !ok {
+		t.Fatalf("expected elvis expression, got %T", countDecl.Value)
+	}
+	if _, ok := fallback.Left.(*ast.PropagateExpression); !ok {
+		t.Fatalf("expected propagation on the left of ?:, got %T", fallback.Left)
+	}
+}
+
+func TestParserAcceptsKeywordPropertyNames(t *testing.T) {
+	program := parseProgram(t, `pattern.match(text);`)
+	stmt := program.Items[0].(*ast.ExpressionStatement)
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("expected call expression, got %T", stmt.Expression)
+	}
+	member, ok := call.Callee.(*ast.MemberExpression)
+	if !ok || member.Property != "match" {
+		t.Fatalf("expected member access to match, got %#v", call.Callee)
+	}
+}
+
+func TestParserParsesGeneratorsAndForIn(t *testing.T) {
+	source := `
+fn numbers*(limit: Number) {
+    for (let n in [1, 2, 3]) {
+        yield n;
+    }
+    yield;
+}
+
+for (value in numbers(3)) {
+    print(value);
+}
+`
+
+	program := parseProgram(t, source)
+	if len(program.Items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(program.Items))
+	}
+	fnDecl, ok := program.Items[0].(*ast.FunctionDeclaration)
+	if !ok || !fnDecl.Generator {
+		t.Fatalf("expected generator function declaration, got %T", program.Items[0])
+	}
+	inner, ok := fnDecl.Body.Statements[0].(*ast.ForInStatement)
+	if !ok || inner.Binding.Name != "n" {
+		t.Fatalf("expected for-in over n, got %T", fnDecl.Body.Statements[0])
+	}
+	yieldStmt, ok := inner.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("expected yield expression statement, got %T", inner.Body.Statements[0])
+	}
+	if y, ok := yieldStmt.Expression.(*ast.YieldExpression); !ok || y.Value == nil {
+		t.Fatalf("expected yield with value, got %T", yieldStmt.Expression)
+	}
+	bare := fnDecl.Body.Statements[1].(*ast.ExpressionStatement)
+	if y, ok := bare.Expression.(*ast.YieldExpression); !ok || y.Value != nil {
+		t.Fatalf("expected bare yield, got %T", bare.Expression)
+	}
+	if loop, ok := program.Items[1].(*ast.ForInStatement); !ok || loop.Binding.Name != "value" {
+		t.Fatalf("expected top-level for-in, got %T", program.Items[1])
+	}
+}
+
+func TestParserAttachesAnnotations(t *testing.T) {
+	program := parseProgram(t, `
+/// Lists the users.
+@route("/users", "GET")
+@deprecated
+fn users() => [];
+
+@entity
+class User(name: String) {
+    @test
+    fn named() => this.name != "";
+}
+`)
+	users := program.Items[0].(*ast.FunctionDeclaration)
+	if len(users.Annotations) != 2 || users.Doc != "Lists the users." || users.Start.Line != 3 {
+		t.Fatalf("unexpected function annotations %v, doc %q, start %v", users.Annotations, users.Doc, users.Start)
+	}
+	route := users.Annotations[0]
+	if route.Name.Name != "route" || len(route.Arguments) != 2 || route.Arguments[0].(*ast.StringLiteral).Value != "/users" {
+		t.Fatalf("unexpected route annotation %+v", route)
+	}
+	if deprecated := users.Annotations[1]; deprecated.Name.Name != "deprecated" || deprecated.Arguments != nil {
+		t.Fatalf("unexpected deprecated annotation %+v", deprecated)
+	}
+	user := program.Items[1].(*ast.ClassDeclaration)
+	if len(user.Annotations) != 1 || user.Annotations[0].Name.Name != "entity" {
+		t.Fatalf("unexpected class annotations %v", user.Annotations)
+	}
+	if method := user.Body.Statements[0].(*ast.FunctionDeclaration); len(method.Annotations) != 1 || method.Annotations[0].Name.Name != "test" {
+		t.Fatalf("unexpected method annotations %v", method.Annotations)
+	}
+
+	p := New(lexer.New("@test let x = 1;"))
+	p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 1 || !strings.Contains(errs[0], "annotations must precede") {
+		t.Fatalf("expected an annotated variable to be rejected, got %v", errs)
+	}
+}
+
+func TestParserRejectsYieldOutsideGenerator(t *testing.T) {
+	p := New(lexer.New("fn plain() { yield 1; }"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected yield outside a generator to be rejected")
+	}
+}
+
+func TestParserImportPathLiterals(t *testing.T) {
+	program := parseProgram(t, `
+import "github.com/user/lib2";
+import mylib "github.com/user/my-lib";
+import "github.com/user/other-lib" as other;
+`)
+	if len(program.Items) != 3 {
+		t.Fatalf("expected 3 imports, got %d", len(program.Items))
+	}
+	plain := program.Items[0].(*ast.ImportDeclaration)
+	if plain.PathLiteral != "github.com/user/lib2" || len(plain.Path) != 3 || plain.Alias != nil {
+		t.Fatalf("unexpected plain import %+v", plain)
+	}
+	aliased := program.Items[1].(*ast.ImportDeclaration)
+	if aliased.Alias == nil || aliased.Alias.Name != "mylib" || aliased.Path[2].Name != "my-lib" {
+		t.Fatalf("unexpected aliased import %+v", aliased)
+	}
+	trailing := program.Items[2].(*ast.ImportDeclaration)
+	if trailing.Alias == nil || trailing.Alias.Name != "other" {
+		t.Fatalf("unexpected trailing alias import %+v", trailing)
+	}
+
+	for _, src := range []string{`import "github.com/user/my-lib";`, `import "example.com/2024";`, `import "";`} {
+		p := New(lexer.New(src))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Fatalf("expected %s to require an alias", src)
+		}
+	}
+}
+
+func TestParserParsesClassAccessors(t *testing.T) {
+	program := parseProgram(t, `class Box(raw: Number) {
+    get value(): Number => self.raw;
+    set value(v: Number) { self.raw = v; }
+    fn get(): Number => self.raw;
+}`)
+	class := program.Items[0].(*ast.ClassDeclaration)
+	var kinds []string
+	for _, stmt := range class.Body.Statements {
+		fn := stmt.(*ast.FunctionDeclaration)
+		kinds = append(kinds, fn.Accessor+" "+fn.Name.Name)
+	}
+	if got := strings.Join(kinds, ","); got != "get value,set value, get" {
+		t.Fatalf("unexpected members %q", got)
+	}
+
+	for src, want := range map[string]string{
+		`class A(x: Number) { get y(a: Number) => a; }`: "getter y must not take parameters",
+		`class A(x: Number) { set y() {} }`:             "setter y must take exactly one parameter",
+	} {
+		p := New(lexer.New(src))
+		p.ParseProgram()
+		if errs := p.Errors(); len(errs) != 1 || errs[0] != want {
+			t.Fatalf("expected %q for %s, got %v", want, src, errs)
+		}
+	}
+}
+
+func TestParserMarksStaticClassMembers(t *testing.T) {
+	program := parseProgram(t, `class Counter(count: Number) {
+    /// Where counting starts.
+    static let origin = 0;
+    static fn zero(): Counter => Counter(origin);
+    let step = 1;
+    fn static(): Number => step;
+}`)
+	class := program.Items[0].(*ast.ClassDeclaration)
+	origin := class.Body.Statements[0].(*ast.VariableDeclaration)
+	if !origin.Static || origin.Doc != "Where counting starts." {
+		t.Fatalf("expected a documented static constant, got %+v", origin)
+	}
+	if zero := class.Body.Statements[1].(*ast.FunctionDeclaration); !zero.Static {
+		t.Fatalf("expected zero to be static")
+	}
+	if step := class.Body.Statements[2].(*ast.VariableDeclaration); step.Static {
+		t.Fatalf("expected step to stay a body local")
+	}
+	if method := class.Body.Statements[3].(*ast.FunctionDeclaration); method.Static || method.Name.Name != "static" {
+		t.Fatalf("expected a method named static, got %+v", method)
+	}
+}
+
+func TestParserParsesAbstractClassesAndOverrides(t *testing.T) {
+	program := parseProgram(t, `/// A shape.
+abstract class Shape(name: String) {
+    abstract fn area(): Number;
+    override fn describe(): String => self.name;
+}
+let abstract = 1;`)
+	class := program.Items[0].(*ast.ClassDeclaration)
+	if !class.Abstract || class.Doc != "A shape." || class.Start.Line != 2 {
+		t.Fatalf("expected a documented abstract class, got %+v", class)
+	}
+	area := class.Body.Statements[0].(*ast.FunctionDeclaration)
+	describe := class.Body.Statements[1].(*ast.FunctionDeclaration)
+	if len(class.Body.Statements) != 2 || !area.Abstract || area.Body != nil || !describe.Override {
+		t.Fatalf("unexpected members %+v", class.Body.Statements)
+	}
+	if _, ok := program.Items[1].(*ast.VariableDeclaration); !ok {
+		t.Fatalf("expected abstract to stay usable as a name, got %T", program.Items[1])
+	}
+
+	p := New(lexer.New(`abstract class A(x: Number) { abstract fn f(): Number => 1; }`))
+	p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 1 || errs[0] != "abstract method f cannot have a body" {
+		t.Fatalf("expected a body on an abstract method to be rejected, got %v", errs)
+	}
+}
+
+func TestParserParsesEnumMethods(t *testing.T) {
+	program := parseProgram(t, `enum Color {
+    Red;
+    fn hex(): String => "#f00";
+    @pure fn dark(): Boolean => false;
+    Green;
+}`)
+	enum := program.Items[0].(*ast.EnumDeclaration)
+	if len(enum.Cases) != 2 || len(enum.Methods) != 2 || enum.Methods[1].Name.Name != "dark" || len(enum.Methods[1].Annotations) != 1 {
+		t.Fatalf("unexpected enum %+v", enum)
+	}
+	if printed := printer.Print(program); !strings.Contains(printed, "    Red;\n    fn hex(): String => \"#f00\";\n    @pure\n    fn dark(): Boolean => false;\n    Green;\n") {
+		t.Fatalf("expected members to print in source order, got:\n%s", printed)
+	}
+
+	p := New(lexer.New(`enum E { A; let x = 1; fn f() {} }`))
+	p.ParseProgram()
+	if errs := p.Errors(); len(errs) == 0 {
+		t.Fatalf("expected a variable in an enum body to be rejected")
+	}
+}
+
+func TestParserParsesContractRequirementsAndImplements(t *testing.T) {
+	program := parseProgram(t, `contract Transferable {
+    fn transfer(amount: Number): Boolean;
+    invariant self.balance >= 0;
+    fn describe(): String => "transferable";
+    let invariant = 1;
+}
+class Account(balance: Number) : Base implements Transferable, Named {}
+class Wallet(balance: Number) : implements Transferable {}`)
+	contract := program.Items[0].(*ast.ContractDeclaration)
+	if len(contract.Body.Statements) != 3 || len(contract.Invariants) != 1 {
+		t.Fatalf("unexpected contract %+v", contract)
+	}
+	if fn := contract.Body.Statements[0].(*ast.FunctionDeclaration); fn.Body != nil || fn.IsExprBody {
+		t.Fatalf("expected transfer to be a requirement, got %+v", fn)
+	}
+	account := program.Items[1].(*ast.ClassDeclaration)
+	if account.SuperClass == nil || account.SuperClass.Name != "Base" || len(account.Implements) != 2 || account.Implements[1].Name != "Named" {
+		t.Fatalf("unexpected class %+v", account)
+	}
+	wallet := program.Items[2].(*ast.ClassDeclaration)
+	if wallet.SuperClass != nil || len(wallet.Implements) != 1 {
+		t.Fatalf("unexpected class %+v", wallet)
+	}
+	printed := printer.Print(program)
+	for _, want := range []string{
+		"    fn transfer(amount: Number): Boolean;\n    invariant self.balance >= 0;\n    fn describe()",
+		"class Account(balance: Number) : Base implements Transferable, Named {}",
+		"class Wallet(balance: Number) : implements Transferable {}",
+	} {
+		if !strings.Contains(printed, want) {
+			t.Fatalf("expected %q in:\n%s", want, printed)
+		}
+	}
+}
+
+func TestParserParsesPreconditionsOldValuesAndClassInvariants(t *testing.T) {
+	program := parseProgram(t, `fn take(stack: Array, old: Number): Number
+    contract {
+        requires(stack.length > old);
+        ensures(stack.length == old(stack.length) - 1);
+        returns(result) => result != old(old);
+    }
+{
+    return stack.pop();
+}
+class Counter(count: Number) {
+    invariant self.count >= 0;
+    fn add(step: Number) {}
+}`)
+	fn := program.Items[0].(*ast.FunctionDeclaration)
+	clauses := fn.Contract.Clauses
+	if len(clauses) != 3 || clauses[0].Kind != "requires" || clauses[1].Kind != "ensures" || clauses[2].Kind != "returns" {
+		t.Fatalf("unexpected clauses %+v", clauses)
+	}
+	if _, ok := clauses[0].Condition.(*ast.InfixExpression).Right.(*ast.Identifier); !ok {
+		t.Fatalf("expected old to be a name in a precondition, got %T", clauses[0].Condition.(*ast.InfixExpression).Right)
+	}
+	if len(fn.Contract.Olds) != 2 || fn.Contract.Olds[1].Index != 1 {
+		t.Fatalf("expected two old expressions, got %+v", fn.Contract.Olds)
+	}
+	class := program.Items[1].(*ast.ClassDeclaration)
+	if len(class.Invariants) != 1 || len(class.Body.Statements) != 1 {
+		t.Fatalf("unexpected class %+v", class)
+	}
+	printed := printer.Print(program)
+	for _, want := range []string{
+		"        requires(stack.length > old);\n        ensures(stack.length == old(stack.length) - 1);\n        returns(result) => result != old(old);\n",
+		"    invariant self.count >= 0;\n    fn add(step: Number) {}\n",
+	} {
+		if !strings.Contains(printed, want) {
+			t.Fatalf("expected %q in:\n%s", want, printed)
+		}
+	}
+}
+
+func TestParserReportsInvalidEscapesWhereTheyAppear(t *testing.T) {
+	p := New(lexer.New("let a = \"ok \\u{1F600}\";\nlet b = \"\"\"x\n  \\q\"\"\";\nlet c = f\"${a} \\xff\";\nlet d = r\"\\q\";\n"))
+	p.ParseProgram()
+	details := p.ErrorDetails()
+	if len(details) != 2 {
+		t.Fatalf("expected two escape errors, got %v", p.Errors())
+	}
+	if details[0].Message != `invalid escape sequence \q` || details[0].Position.Line != 3 || details[0].Position.Column != 3 {
+		t.Fatalf("unexpected first error %+v", details[0])
+	}
+	if !strings.HasPrefix(details[1].Message, `invalid escape sequence \xff`) || details[1].Position.Line != 4 || details[1].Position.Column != 16 {
+		t.Fatalf("unexpected second error %+v", details[1])
+	}
+}
+
+func TestParserParsesIncrementsAndElementAssignments(t *testing.T) {
+	program := parseProgram(t, `for (var i = 0; i < n; i++) { grid[i][0] += -x--; self.count--; }`)
+	loop := program.Items[0].(*ast.ForStatement)
+	if post, ok := loop.Post.(*ast.IncrementExpression); !ok || post.Operator != "++" {
+		t.Fatalf("expected i++ as the loop's post statement, got %T", loop.Post)
+	}
+	body := loop.Body.(*ast.BlockStatement).Statements
+	assign := body[0].(*ast.ExpressionStatement).Expression.(*ast.AssignmentExpression)
+	if _, ok := assign.Target.(*ast.IndexExpression); !ok {
+		t.Fatalf("expected an index target, got %T", assign.Target)
+	}
+	if neg, ok := assign.Value.(*ast.PrefixExpression); !ok || neg.Right.(*ast.IncrementExpression).Operator != "--" {
+		t.Fatalf("expected -(x--), got %s", printer.Node(assign.Value))
+	}
+	const expected = "for (var i = 0; i < n; i++) {\n    grid[i][0] += -x--;\n    self.count--;\n}\n"
+	if printed := printer.Print(program); printed != expected {
+		t.Fatalf("unexpected output:\n--- got ---\n%s\n--- want ---\n%s", printed, expected)
+	}
+}
+
+func TestParserParsesRangesAndMembership(t *testing.T) {
+	program := parseProgram(t, `let ok = x in 0..n + 1 && y in 1..=9;
+match score { 90..=100 => "A"; 0..90 => "B"; }`)
+	decl := program.Items[0].(*ast.VariableDeclaration)
+	and := decl.Value.(*ast.InfixExpression)
+	in := and.Left.(*ast.InfixExpression)
+	if in.Operator != "in" {
+		t.Fatalf("expected && to join two in checks, got %s", printer.Node(and))
+	}
+	rng, ok := in.Right.(*ast.InfixExpression)
+	if !ok || rng.Operator != ".." || printer.Node(rng.Right) != "n + 1" {
+		t.Fatalf("expected the range 0..(n + 1), got %s", printer.Node(in.Right))
+	}
+	match := program.Items[1].(*ast.MatchStatement)
+	pattern, ok := match.Cases[0].Patterns[0].(*ast.LiteralPattern)
+	if !ok || pattern.Value.(*ast.InfixExpression).Operator != "..=" {
+		t.Fatalf("expected a range pattern, got %T", match.Cases[0].Patterns[0])
+	}
+	const expected = "let ok = x in 0..n + 1 && y in 1..=9;\nmatch score {\n    90..=100 => \"A\";\n    0..90 => \"B\";\n}\n"
+	if printed := printer.Print(program); printed != expected {
+		t.Fatalf("unexpected output:\n--- got ---\n%s\n--- want ---\n%s", printed, expected)
+	}
+}
+
+func TestParserParsesOptionalIndexing(t *testing.T) {
+	program := parseProgram(t, "rows?[0]?.cells[i].trim();\n(result?)[0];\n")
+	call := program.Items[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	cells := call.Callee.(*ast.MemberExpression).Object.(*ast.IndexExpression).Collection.(*ast.MemberExpression)
+	if first, ok := cells.Object.(*ast.IndexExpression); !ok || !first.Optional || !cells.Optional {
+		t.Fatalf("expected rows?[0]?.cells, got %s", printer.Node(cells))
+	}
+	index := program.Items[1].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression)
+	if _, ok := index.Collection.(*ast.PropagateExpression); !ok || index.Optional {
+		t.Fatalf("expected an index of a propagation, got %s", printer.Node(index))
+	}
+	const expected = "rows?[0]?.cells[i].trim();\n(result?)[0];\n"
+	if printed := printer.Print(program); printed != expected {
+		t.Fatalf("unexpected output:\n--- got ---\n%s\n--- want ---\n%s", printed, expected)
+	}
+}
+
+func TestParserParsesTypedCatchClausesAndRethrow(t *testing.T) {
+	program := parseProgram(t, "try { f(); } catch (e: NetworkError) { rethrow; } catch (e) { print(e); }\nlet rethrow = 1;\n")
+	tryStmt := program.Items[0].(*ast.TryStatement)
+	if len(tryStmt.Catches) != 2 || tryStmt.Catches[0].Type == nil || tryStmt.Catches[0].Type.Name.Name != "NetworkError" || tryStmt.Catches[1].Type != nil {
+		t.Fatalf("expected a typed and an untyped catch clause, got %s", printer.Node(tryStmt))
+	}
+	if _, ok := tryStmt.Catches[0].Body.Statements[0].(*ast.RethrowStatement); !ok {
+		t.Fatalf("expected rethrow in the first clause, got %T", tryStmt.Catches[0].Body.Statements[0])
+	}
+	if _, ok := program.Items[1].(*ast.VariableDeclaration); !ok {
+		t.Fatalf("expected rethrow to stay usable as a name, got %T", program.Items[1])
+	}
+	const expected = "try {\n    f();\n} catch (e: NetworkError) {\n    rethrow;\n} catch (e) {\n    print(e);\n}\nlet rethrow = 1;\n"
+	if printed := printer.Print(program); printed != expected {
+		t.Fatalf("unexpected output:\n--- got ---\n%s\n--- want ---\n%s", printed, expected)
+	}
+
+	p := New(lexer.New(`fn f() { try { g(); } catch (e) { fn h() { rethrow; } } }`))
+	p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 1 || errs[0] != "rethrow is only allowed in a catch clause" {
+		t.Fatalf("expected rethrow outside a catch clause to be rejected, got %v", errs)
+	}
+}
+
+func TestParserParsesLabelledLoopsAndJumps(t *testing.T) {
+	program := parseProgram(t, "outer: for (i in xs) {\n    inner: while (true) {\n        continue outer;\n    }\n    break\n    f();\n}\n")
+	loop, ok := program.Items[0].(*ast.ForInStatement)
+	if !ok || loop.Label != "outer" || loop.Pos().Column != 1 {
+		t.Fatalf("expected a for-in loop labelled outer, got %s", printer.Node(program.Items[0]))
+	}
+	inner := loop.Body.Statements[0].(*ast.WhileStatement)
+	if jump := inner.Body.(*ast.BlockStatement).Statements[0].(*ast.ContinueStatement); inner.Label != "inner" || jump.Label != "outer" {
+		t.Fatalf("expected continue outer inside the loop labelled inner, got %s", printer.Node(inner))
+	}
+	if jump := loop.Body.Statements[1].(*ast.BreakStatement); jump.Label != "" {
+		t.Fatalf("expected a label on the next line to be left alone, got %q", jump.Label)
+	}
+	const expected = "outer: for (i in xs) {\n    inner: while true {\n        continue outer;\n    }\n    break;\n    f();\n}\n"
+	if printed := printer.Print(program); printed != expected {
+		t.Fatalf("unexpected output:\n--- got ---\n%s\n--- want ---\n%s", printed, expected)
+	}
+
+	for src, want := range map[string]string{
+		"outer: f();":                   "label outer must be followed by a for, while, or do loop",
+		"while (true) { break outer; }": "break outer names no enclosing loop",
+		"outer: while (true) { fn f() { continue outer; } }": "continue outer names no enclosing loop",
+		"l: while (true) { l: while (true) {} }":             "label l is already used by an enclosing loop",
+	} {
+		p := New(lexer.New(src))
+		p.ParseProgram()
+		if errs := p.Errors(); len(errs) == 0 || errs[0] != want {
+			t.Fatalf("%s: expected %q, got %v", src, want, errs)
+		}
+	}
+}
+
+func TestParserParsesDoWhileLoops(t *testing.T) {
+	program := parseProgram(t, "again: do {\n    x++;\n} while (x < 3)\ndo { f(); } while ready;\n")
+	loop, ok := program.Items[0].(*ast.DoWhileStatement)
+	if !ok || loop.Label != "again" || loop.Pos().Column != 1 || len(loop.Body.Statements) != 1 {
+		t.Fatalf("expected a do loop labelled again, got %s", printer.Node(program.Items[0]))
+	}
+	const expected = "again: do {\n    x++;\n} while x < 3;\ndo {\n    f();\n} while ready;\n"
+	if printed := printer.Print(program); printed != expected {
+		t.Fatalf("unexpected output:\n--- got ---\n%s\n--- want ---\n%s", printed, expected)
+	}
+
+	p := New(lexer.New("do { f(); } until ready;"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected an error for a do body without while")
+	}
+}
+
+func TestParserParsesMatchArmsWithSeveralPatternsAndElse(t *testing.T) {
+	program := parseProgram(t, "match v {\n    0, 1 => small();\n    [x, 0], [0, x] => axis(x);\n    else => other();\n}\n")
+	match := program.Items[0].(*ast.MatchStatement)
+	if len(match.Cases) != 2 || len(match.Cases[0].Patterns) != 2 || len(match.Cases[1].Patterns) != 2 || match.Else == nil {
+		t.Fatalf("expected two arms of two patterns and an else arm, got %s", printer.Node(match))
+	}
+	const expected = "match v {\n    0, 1 => small();\n    [x, 0], [0, x] => axis(x);\n    else => other();\n}\n"
+	if printed := printer.Print(program); printed != expected {
+		t.Fatalf("unexpected output:\n--- got ---\n%s\n--- want ---\n%s", printed, expected)
+	}
+
+	for src, want := range map[string]string{
+		"match v { [x, 0], [0, y] => f(); }": "each pattern of a match arm must bind the same names",
+		"match v { else => f(); 1 => g(); }": "the else arm must be the last arm of a match",
+	} {
+		p := New(lexer.New(src))
+		p.ParseProgram()
+		if errs := p.Errors(); len(errs) == 0 || errs[0] != want {
+			t.Fatalf("%s: expected %q, got %v", src, want, errs)
+		}
+	}
+}
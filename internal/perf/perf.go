@@ -0,0 +1,229 @@
+// Package perf records local, anonymous performance counters for the selene
+// CLI. Recording is opt-in: when SELENE_PERF=1 is set, each invocation
+// appends one JSON line to a metrics file with the command, its duration,
+// the time spent in each toolchain phase, and cache hits and misses. Records
+// never contain paths, arguments, or source text, and nothing leaves the
+// machine; selene perf report summarizes the file so it can be attached to a
+// performance issue.
+package perf
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EnableVariable turns recording on when set to 1, true, or on.
+const EnableVariable = "SELENE_PERF"
+
+// FileVariable overrides the location of the metrics file.
+const FileVariable = "SELENE_PERF_FILE"
+
+// Phases and counters the toolchain reports.
+const (
+	PhaseParse   = "parse"
+	PhaseCompile = "compile"
+	PhaseRun     = "run"
+
+	BytecodeCache = "bytecode-cache"
+	IndexCache    = "index-cache"
+)
+
+// Record is one invocation of the CLI.
+type Record struct {
+	Time    time.Time `json:"time"`
+	Version string    `json:"version"`
+	GOOS    string    `json:"goos"`
+	GOARCH  string    `json:"goarch"`
+	Command string    `json:"command"`
+	// DurationMS is the wall time of the whole invocation.
+	DurationMS float64 `json:"durationMs"`
+	Failed     bool    `json:"failed,omitempty"`
+	// PhasesMS holds the time spent in each phase, summed over the files
+	// the command processed.
+	PhasesMS map[string]float64 `json:"phasesMs,omitempty"`
+	// Counters holds event counts such as "bytecode-cache.hit".
+	Counters map[string]int64 `json:"counters,omitempty"`
+}
+
+type recorder struct {
+	mu       sync.Mutex
+	start    time.Time
+	command  string
+	version  string
+	phases   map[string]time.Duration
+	counters map[string]int64
+}
+
+// current is the invocation being recorded, or nil when recording is off so
+// that Since, Hit, and Miss cost one atomic load.
+var current atomic.Pointer[recorder]
+
+// Enabled reports whether EnableVariable asks for recording.
+func Enabled() bool {
+	switch os.Getenv(EnableVariable) {
+	case "1", "true", "on":
+		return true
+	}
+	return false
+}
+
+// Path returns the metrics file: FileVariable when set, otherwise
+// selene/perf.jsonl under the user cache directory.
+func Path() (string, error) {
+	if path := os.Getenv(FileVariable); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("locate metrics file: %w", err)
+	}
+	return filepath.Join(dir, "selene", "perf.jsonl"), nil
+}
+
+// Start begins recording an invocation of command when recording is
+// enabled. Call Finish when the command completes.
+func Start(command, version string) {
+	if !Enabled() {
+		return
+	}
+	current.Store(&recorder{
+		start:    time.Now(),
+		command:  command,
+		version:  version,
+		phases:   make(map[string]time.Duration),
+		counters: make(map[string]int64),
+	})
+}
+
+// SetCommand renames the invocation being recorded, for commands that are
+// only identified after Start.
+func SetCommand(command string) {
+	if r := current.Load(); r != nil {
+		r.mu.Lock()
+		r.command = command
+		r.mu.Unlock()
+	}
+}
+
+// Since adds the time elapsed since start to phase. It is meant to be
+// deferred: defer perf.Since(perf.PhaseParse, time.Now()).
+func Since(phase string, start time.Time) {
+	r := current.Load()
+	if r == nil {
+		return
+	}
+	elapsed := time.Since(start)
+	r.mu.Lock()
+	r.phases[phase] += elapsed
+	r.mu.Unlock()
+}
+
+// Hit and Miss count a lookup in cache.
+func Hit(cache string)  { count(cache + ".hit") }
+func Miss(cache string) { count(cache + ".miss") }
+
+func count(counter string) {
+	r := current.Load()
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.counters[counter]++
+	r.mu.Unlock()
+}
+
+// Finish ends the invocation started by Start and appends its record to the
+// metrics file. failed marks invocations that ended in an error. It does
+// nothing when recording is off.
+func Finish(failed bool) error {
+	r := current.Swap(nil)
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	record := Record{
+		Time:       r.start.UTC(),
+		Version:    r.version,
+		GOOS:       goruntime.GOOS,
+		GOARCH:     goruntime.GOARCH,
+		Command:    r.command,
+		DurationMS: milliseconds(time.Since(r.start)),
+		Failed:     failed,
+	}
+	if len(r.phases) > 0 {
+		record.PhasesMS = make(map[string]float64, len(r.phases))
+		for phase, d := range r.phases {
+			record.PhasesMS[phase] = milliseconds(d)
+		}
+	}
+	if len(r.counters) > 0 {
+		record.Counters = r.counters
+	}
+	r.mu.Unlock()
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	return Append(path, record)
+}
+
+// Append writes record to the metrics file at path as one JSON line.
+func Append(path string, record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("write metrics: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("write metrics: %w", err)
+	}
+	// A single write keeps lines whole when invocations finish together.
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write metrics: %w", err)
+	}
+	return nil
+}
+
+// ReadRecords loads the metrics file at path. A missing file holds no
+// records. Lines that do not decode, such as one cut short by a crash, are
+// skipped.
+func ReadRecords(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var record Record
+		if json.Unmarshal(scanner.Bytes(), &record) == nil && record.Command != "" {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
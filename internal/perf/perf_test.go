@@ -0,0 +1,101 @@
+package perf
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFinishAppendsRecordsWhenEnabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "perf.jsonl")
+	t.Setenv(FileVariable, path)
+
+	t.Setenv(EnableVariable, "")
+	Start("run", "dev")
+	Hit(BytecodeCache)
+	if err := Finish(false); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected no metrics file while recording is off, got %v", err)
+	}
+
+	t.Setenv(EnableVariable, "1")
+	Start("run", "v1.2.0")
+	Since(PhaseParse, time.Now().Add(-2*time.Millisecond))
+	Hit(BytecodeCache)
+	Miss(BytecodeCache)
+	Miss(BytecodeCache)
+	if err := Finish(true); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	Start("lsp", "v1.2.0")
+	SetCommand("external")
+	if err := Finish(false); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+
+	records, err := ReadRecords(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected two records, got %+v", records)
+	}
+	run := records[0]
+	if run.Command != "run" || !run.Failed || run.Version != "v1.2.0" || run.PhasesMS[PhaseParse] < 2 {
+		t.Fatalf("unexpected run record %+v", run)
+	}
+	if run.Counters["bytecode-cache.hit"] != 1 || run.Counters["bytecode-cache.miss"] != 2 {
+		t.Fatalf("unexpected counters %v", run.Counters)
+	}
+	if records[1].Command != "external" {
+		t.Fatalf("expected the renamed command, got %q", records[1].Command)
+	}
+}
+
+func TestSummarizeReportsPercentilesAndHitRates(t *testing.T) {
+	day := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	var records []Record
+	for i := 1; i <= 20; i++ {
+		records = append(records, Record{
+			Time:       day.Add(time.Duration(i) * time.Hour),
+			Version:    "v1",
+			Command:    "run",
+			DurationMS: float64(i),
+			Failed:     i == 20,
+			PhasesMS:   map[string]float64{PhaseParse: 1},
+			Counters:   map[string]int64{"bytecode-cache.hit": 3, "bytecode-cache.miss": 1},
+		})
+	}
+	records = append(records, Record{Time: day, Version: "v0", Command: "check", DurationMS: 7, Counters: map[string]int64{"index-cache.miss": 2}})
+
+	summaries := Summarize(records)
+	if len(summaries) != 2 || summaries[0].Command != "check" {
+		t.Fatalf("unexpected summaries %+v", summaries)
+	}
+	run := summaries[1]
+	if run.Runs != 20 || run.Failures != 1 || run.MeanMS != 10.5 || run.P50MS != 10 || run.P95MS != 19 || run.MaxMS != 20 {
+		t.Fatalf("unexpected run summary %+v", run)
+	}
+	if run.PhasesMS[PhaseParse] != 1 || run.HitRates[BytecodeCache] != 0.75 {
+		t.Fatalf("unexpected phases %v or hit rates %v", run.PhasesMS, run.HitRates)
+	}
+	if rate, ok := summaries[0].HitRates[IndexCache]; !ok || rate != 0 {
+		t.Fatalf("expected a zero hit rate for a cache that only missed, got %v", summaries[0].HitRates)
+	}
+
+	recent := Filter(records, "run", day.Add(18*time.Hour))
+	if len(recent) != 3 {
+		t.Fatalf("expected three recent run records, got %d", len(recent))
+	}
+
+	var out bytes.Buffer
+	WriteReport(&out, summaries)
+	if !strings.Contains(out.String(), "bytecode-cache") || !strings.Contains(out.String(), "75% hit rate") {
+		t.Fatalf("unexpected report:\n%s", out.String())
+	}
+}
@@ -0,0 +1,153 @@
+package perf
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary aggregates the records of one command.
+type Summary struct {
+	Command  string `json:"command"`
+	Runs     int    `json:"runs"`
+	Failures int    `json:"failures"`
+	// MeanMS, P50MS, P95MS, and MaxMS describe the invocation durations.
+	MeanMS float64 `json:"meanMs"`
+	P50MS  float64 `json:"p50Ms"`
+	P95MS  float64 `json:"p95Ms"`
+	MaxMS  float64 `json:"maxMs"`
+	// PhasesMS holds the mean time per run spent in each phase.
+	PhasesMS map[string]float64 `json:"phasesMs,omitempty"`
+	// HitRates holds, for each cache looked up at least once, the fraction
+	// of lookups that hit.
+	HitRates map[string]float64 `json:"hitRates,omitempty"`
+	// Versions lists the toolchain versions the runs used, oldest first.
+	Versions []string `json:"versions"`
+}
+
+// Filter returns the records made at or after since that ran command. A
+// zero since or an empty command matches everything.
+func Filter(records []Record, command string, since time.Time) []Record {
+	var kept []Record
+	for _, record := range records {
+		if (command == "" || record.Command == command) && !record.Time.Before(since) {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
+
+// Summarize groups records by command, sorted by command name.
+func Summarize(records []Record) []Summary {
+	groups := make(map[string][]Record)
+	for _, record := range records {
+		groups[record.Command] = append(groups[record.Command], record)
+	}
+	summaries := make([]Summary, 0, len(groups))
+	for command, group := range groups {
+		summaries = append(summaries, summarize(command, group))
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Command < summaries[j].Command })
+	return summaries
+}
+
+func summarize(command string, records []Record) Summary {
+	s := Summary{Command: command, Runs: len(records)}
+	durations := make([]float64, 0, len(records))
+	phases := make(map[string]float64)
+	counters := make(map[string]int64)
+	seen := make(map[string]bool)
+	var total float64
+	for _, record := range records {
+		if record.Failed {
+			s.Failures++
+		}
+		durations = append(durations, record.DurationMS)
+		total += record.DurationMS
+		for phase, ms := range record.PhasesMS {
+			phases[phase] += ms
+		}
+		for counter, n := range record.Counters {
+			counters[counter] += n
+		}
+		if !seen[record.Version] {
+			seen[record.Version] = true
+			s.Versions = append(s.Versions, record.Version)
+		}
+	}
+	sort.Float64s(durations)
+	s.MeanMS = round(total / float64(len(records)))
+	s.P50MS = percentile(durations, 0.50)
+	s.P95MS = percentile(durations, 0.95)
+	s.MaxMS = durations[len(durations)-1]
+	if len(phases) > 0 {
+		s.PhasesMS = make(map[string]float64, len(phases))
+		for phase, ms := range phases {
+			s.PhasesMS[phase] = round(ms / float64(len(records)))
+		}
+	}
+	for counter := range counters {
+		cache, ok := strings.CutSuffix(counter, ".hit")
+		if !ok {
+			cache, ok = strings.CutSuffix(counter, ".miss")
+		}
+		if !ok {
+			continue
+		}
+		hits, misses := counters[cache+".hit"], counters[cache+".miss"]
+		if hits+misses > 0 {
+			if s.HitRates == nil {
+				s.HitRates = make(map[string]float64)
+			}
+			s.HitRates[cache] = float64(hits) / float64(hits+misses)
+		}
+	}
+	return s
+}
+
+// percentile returns the nearest-rank percentile of sorted.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
+
+func round(ms float64) float64 {
+	return math.Round(ms*1000) / 1000
+}
+
+// WriteReport prints summaries as a plain-text table followed by the phase
+// and cache figures of each command.
+func WriteReport(w io.Writer, summaries []Summary) {
+	if len(summaries) == 0 {
+		fmt.Fprintln(w, "no performance records")
+		return
+	}
+	fmt.Fprintf(w, "%-12s %6s %6s %10s %10s %10s %10s\n", "command", "runs", "failed", "mean ms", "p50 ms", "p95 ms", "max ms")
+	for _, s := range summaries {
+		fmt.Fprintf(w, "%-12s %6d %6d %10.1f %10.1f %10.1f %10.1f\n", s.Command, s.Runs, s.Failures, s.MeanMS, s.P50MS, s.P95MS, s.MaxMS)
+	}
+	for _, s := range summaries {
+		if len(s.PhasesMS) == 0 && len(s.HitRates) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s (versions %s)\n", s.Command, strings.Join(s.Versions, ", "))
+		for _, phase := range sortedKeys(s.PhasesMS) {
+			fmt.Fprintf(w, "  %-16s %10.1f ms per run\n", phase, s.PhasesMS[phase])
+		}
+		for _, cache := range sortedKeys(s.HitRates) {
+			fmt.Fprintf(w, "  %-16s %9.0f%% hit rate\n", cache, s.HitRates[cache]*100)
+		}
+	}
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
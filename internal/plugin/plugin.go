@@ -0,0 +1,210 @@
+// Package plugin extends the selene CLI without changes to this repository.
+//
+// There are two kinds of extension. External commands are executables named
+// selene-<name> on PATH: "selene <name> args..." runs them, git-style, when
+// <name> is neither a built-in command nor a source file. Go plugins are
+// packages built with -buildmode=plugin and dropped into a plugin directory;
+// the CLI opens them at startup, and their init functions call
+// RegisterCommand to add subcommands and runtime.RegisterModule to add
+// modules every script can use.
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goplugin "plugin"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ExecutablePrefix starts the name of every external command.
+const ExecutablePrefix = "selene-"
+
+// PathVariable names the environment variable listing plugin directories,
+// separated like PATH. When it is unset, DefaultDirs are searched.
+const PathVariable = "SELENE_PLUGIN_PATH"
+
+// Command is a subcommand contributed by a Go plugin.
+type Command struct {
+	// Name is what users type after selene.
+	Name string
+	// Synopsis and Summary describe the command in selene's usage text.
+	Synopsis string
+	Summary  string
+	// Run executes the command with the arguments that follow its name. An
+	// error it returns is reported the way built-in commands report theirs.
+	Run func(args []string) error
+}
+
+var registry = struct {
+	mu       sync.Mutex
+	commands map[string]Command
+}{commands: make(map[string]Command)}
+
+// RegisterCommand adds cmd to the commands the CLI dispatches. Built-in
+// commands are matched first, so a plugin cannot replace one.
+func RegisterCommand(cmd Command) error {
+	if cmd.Name == "" || strings.ContainsAny(cmd.Name, " \t/\\") || strings.HasPrefix(cmd.Name, "-") {
+		return fmt.Errorf("invalid command name %q", cmd.Name)
+	}
+	if cmd.Run == nil {
+		return fmt.Errorf("command %s has no Run", cmd.Name)
+	}
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if _, exists := registry.commands[cmd.Name]; exists {
+		return fmt.Errorf("command %s is already registered", cmd.Name)
+	}
+	registry.commands[cmd.Name] = cmd
+	return nil
+}
+
+// LookupCommand returns the registered command called name.
+func LookupCommand(name string) (Command, bool) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	cmd, ok := registry.commands[name]
+	return cmd, ok
+}
+
+// Commands returns the registered commands sorted by name.
+func Commands() []Command {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	commands := make([]Command, 0, len(registry.commands))
+	for _, cmd := range registry.commands {
+		commands = append(commands, cmd)
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	return commands
+}
+
+// DefaultDirs returns the plugin directories searched when PathVariable is
+// unset: selene/plugins under the user's configuration directory.
+func DefaultDirs() []string {
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return nil
+	}
+	return []string{filepath.Join(config, "selene", "plugins")}
+}
+
+// Dirs returns the plugin directories to search.
+func Dirs() []string {
+	if list, ok := os.LookupEnv(PathVariable); ok {
+		var dirs []string
+		for _, dir := range filepath.SplitList(list) {
+			if dir != "" {
+				dirs = append(dirs, dir)
+			}
+		}
+		return dirs
+	}
+	return DefaultDirs()
+}
+
+// Load opens every .so file in dirs, in name order within each directory,
+// so that the plugins' init functions register their commands and modules.
+// Missing directories are skipped. A plugin that fails to open does not stop
+// the others from loading; the failures are joined into the returned error.
+//
+// Go plugins must be built by the same toolchain, from the same version of
+// this module, as the selene binary that opens them, and are only supported
+// where the plugin package is (Linux, FreeBSD, and macOS, with cgo).
+func Load(dirs []string) error {
+	var errs []error
+	for _, dir := range dirs {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.so"))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sort.Strings(matches)
+		for _, path := range matches {
+			if _, err := goplugin.Open(path); err != nil {
+				errs = append(errs, fmt.Errorf("load plugin %s: %w", path, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LookupExecutable finds the external command for name on PATH.
+func LookupExecutable(name string) (string, bool) {
+	if name == "" || strings.ContainsAny(name, "/\\") || strings.HasPrefix(name, "-") {
+		return "", false
+	}
+	path, err := exec.LookPath(ExecutablePrefix + name)
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// Executables returns the names, without ExecutablePrefix, of the external
+// commands on PATH, sorted and without duplicates.
+func Executables() []string {
+	var names []string
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), ExecutablePrefix)
+			if !ok || entry.IsDir() {
+				continue
+			}
+			if ext := filepath.Ext(name); ext == ".exe" {
+				name = strings.TrimSuffix(name, ext)
+			}
+			// LookupExecutable applies PATH's own rules, such as
+			// requiring the executable bit.
+			if _, found := LookupExecutable(name); found {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return slices.Compact(names)
+}
+
+// ExitError reports an external command that exited with a non-zero status.
+type ExitError struct {
+	Name string
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("%s%s exited with status %d", ExecutablePrefix, e.Name, e.Code)
+}
+
+// RunExecutable runs the external command at path with args, connected to
+// the CLI's standard streams. The command inherits the environment plus
+// SELENE_BIN, the path of the running selene binary, and SELENE_VERSION.
+func RunExecutable(name, path string, args []string, version string) error {
+	cmd := exec.Command(path, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = os.Environ()
+	if self, err := os.Executable(); err == nil {
+		cmd.Env = append(cmd.Env, "SELENE_BIN="+self)
+	}
+	cmd.Env = append(cmd.Env, "SELENE_VERSION="+version)
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		return &ExitError{Name: name, Code: exit.ExitCode()}
+	}
+	if err != nil {
+		return fmt.Errorf("run %s%s: %w", ExecutablePrefix, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"slices"
+	"testing"
+)
+
+func TestRegisterCommandRejectsDuplicatesAndBadNames(t *testing.T) {
+	run := func(args []string) error { return nil }
+	if err := RegisterCommand(Command{Name: "hello", Summary: "say hello", Run: run}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := RegisterCommand(Command{Name: "hello", Run: run}); err == nil {
+		t.Fatalf("expected a duplicate registration to fail")
+	}
+	for _, name := range []string{"", "-x", "a/b", "two words"} {
+		if err := RegisterCommand(Command{Name: name, Run: run}); err == nil {
+			t.Fatalf("expected %q to be rejected", name)
+		}
+	}
+	if err := RegisterCommand(Command{Name: "norun"}); err == nil {
+		t.Fatalf("expected a command without Run to fail")
+	}
+	if cmd, ok := LookupCommand("hello"); !ok || cmd.Summary != "say hello" {
+		t.Fatalf("expected hello to be registered, got %+v", cmd)
+	}
+	if !slices.ContainsFunc(Commands(), func(cmd Command) bool { return cmd.Name == "hello" }) {
+		t.Fatalf("expected Commands to list hello")
+	}
+}
+
+func TestExecutablesAreFoundOnPath(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\nexit \"$1\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "selene-lint"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "selene-notes.txt"), []byte("not a command"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	if got := Executables(); !slices.Equal(got, []string{"lint"}) {
+		t.Fatalf("unexpected executables %v", got)
+	}
+	path, ok := LookupExecutable("lint")
+	if !ok {
+		t.Fatalf("expected selene-lint to be found")
+	}
+	if _, ok := LookupExecutable("../lint"); ok {
+		t.Fatalf("expected a name with a separator to be refused")
+	}
+	if err := RunExecutable("lint", path, []string{"0"}, "dev"); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	var exit *ExitError
+	if err := RunExecutable("lint", path, []string{"3"}, "dev"); !errors.As(err, &exit) || exit.Code != 3 {
+		t.Fatalf("expected exit status 3, got %v", err)
+	}
+}
+
+func TestDirsFollowPathVariable(t *testing.T) {
+	t.Setenv(PathVariable, "one"+string(os.PathListSeparator)+string(os.PathListSeparator)+"two")
+	if got := Dirs(); !slices.Equal(got, []string{"one", "two"}) {
+		t.Fatalf("unexpected dirs %v", got)
+	}
+	if err := Load([]string{filepath.Join(t.TempDir(), "missing")}); err != nil {
+		t.Fatalf("expected a missing directory to be skipped, got %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+// Package printer renders syntax trees back to Selene source. It is the
+// inverse of the parser: printing a parsed program and parsing the output
+// gives back the same tree. The formatter, code actions, and codemods build
+// on it rather than editing source text. The rendering itself lives in the
+// ast package, as ast.Print and ast.PrintNode, so the tree and its printer
+// change together; this package is the entry point tools share.
+package printer
+
+import "github.com/cybellereaper/selenelang/internal/ast"
+
+// Print renders program as formatted Selene source that parses back to the
+// same tree, keeping its comments and single blank lines between statements.
+// It is ast.Print.
+func Print(program *ast.Program) string {
+	return ast.Print(program)
+}
+
+// Node renders a single declaration, statement, expression, pattern, or
+// type annotation without comments. It is ast.PrintNode.
+func Node(node ast.Node) string {
+	return ast.PrintNode(node)
+}
@@ -0,0 +1,26 @@
+package printer_test
+
+import (
+	"testing"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/lexer"
+	"github.com/cybellereaper/selenelang/internal/parser"
+	"github.com/cybellereaper/selenelang/internal/printer"
+)
+
+func TestPrintMatchesASTPrint(t *testing.T) {
+	src := "// totals\nfn area(w: Number, h: Number): Number { return w*h; }\narea(3, 2);\n"
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parse errors: %v\n%s", errs, src)
+	}
+	if got, want := printer.Print(program), ast.Print(program); got != want {
+		t.Fatalf("printer.Print differs from ast.Print:\n--- got ---\n%s\n--- want ---\n%s", got, want)
+	}
+	call := program.Items[1].(*ast.ExpressionStatement).Expression
+	if got := printer.Node(call); got != "area(3, 2)" {
+		t.Fatalf("unexpected node rendering %q", got)
+	}
+}
@@ -0,0 +1,130 @@
+package project
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// DependencyStatus combines a manifest requirement with its lockfile entry and
+// the state of its vendored copy.
+type DependencyStatus struct {
+	Module        string `json:"module"`
+	Version       string `json:"version"`
+	Source        string `json:"source,omitempty"`
+	Locked        bool   `json:"locked"`
+	LockedVersion string `json:"lockedVersion,omitempty"`
+	Checksum      string `json:"checksum,omitempty"`
+	Vendor        string `json:"vendor,omitempty"`
+	// Vendored reports whether the vendor directory recorded in the lock exists.
+	Vendored bool `json:"vendored"`
+	// Verified reports whether the vendored tree still hashes to Checksum.
+	Verified bool `json:"verified"`
+}
+
+// DescribeDependencies reports the status of every manifest dependency in
+// lexical module order.
+func DescribeDependencies(root string, manifest *Manifest, lock *Lockfile) ([]DependencyStatus, error) {
+	modules := SortedModules(manifest.Dependencies)
+	statuses := make([]DependencyStatus, 0, len(modules))
+	for _, module := range modules {
+		dep := manifest.Dependencies[module]
+		status := DependencyStatus{Module: module, Version: dep.Version, Source: dep.Source}
+		if locked, ok := lock.Lookup(module); ok {
+			status.Locked = true
+			status.LockedVersion = locked.Version
+			status.Checksum = locked.Checksum
+			status.Vendor = locked.Vendor
+			vendorPath, err := ResolveUnderRoot(root, locked.Vendor)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", module, err)
+			}
+			if info, err := os.Stat(vendorPath); err == nil && info.IsDir() {
+				status.Vendored = true
+				status.Verified = locked.Checksum != "" && VerifyChecksum(vendorPath, locked.Checksum) == nil
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// DependencyEdge records that From requires To at Version.
+type DependencyEdge struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Version string `json:"version"`
+}
+
+// DependencyGraph is the requirement graph rooted at the project.
+type DependencyGraph struct {
+	Root  string           `json:"root"`
+	Edges []DependencyEdge `json:"edges"`
+}
+
+// BuildDependencyGraph walks the project's requirements and, for every
+// dependency vendored according to the lockfile, the requirements declared
+// by its own selene.toml.
+func BuildDependencyGraph(root string, manifest *Manifest, lock *Lockfile) (*DependencyGraph, error) {
+	graph := &DependencyGraph{Root: projectLabel(manifest)}
+	visited := map[string]bool{graph.Root: true}
+	var walk func(from string, deps map[string]Dependency) error
+	walk = func(from string, deps map[string]Dependency) error {
+		for _, module := range SortedModules(deps) {
+			graph.Edges = append(graph.Edges, DependencyEdge{From: from, To: module, Version: deps[module].Version})
+			if visited[module] {
+				continue
+			}
+			visited[module] = true
+			locked, ok := lock.Lookup(module)
+			if !ok {
+				continue
+			}
+			vendorPath, err := ResolveUnderRoot(root, locked.Vendor)
+			if err != nil {
+				return fmt.Errorf("%s: %w", module, err)
+			}
+			nested, err := LoadManifest(vendorPath)
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				return fmt.Errorf("%s: %w", module, err)
+			}
+			if err := walk(module, nested.Dependencies); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(graph.Root, manifest.Dependencies); err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
+
+// WriteDOT renders the graph in Graphviz DOT format.
+func (g *DependencyGraph) WriteDOT(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "digraph dependencies {")
+	fmt.Fprintf(out, "  %q [shape=box];\n", g.Root)
+	for _, edge := range g.Edges {
+		fmt.Fprintf(out, "  %q -> %q [label=%q];\n", edge.From, edge.To, edge.Version)
+	}
+	fmt.Fprintln(out, "}")
+	return out.Flush()
+}
+
+func projectLabel(manifest *Manifest) string {
+	switch {
+	case manifest.Project.Module != "":
+		return manifest.Project.Module
+	case manifest.Project.Name != "":
+		return manifest.Project.Name
+	default:
+		return "."
+	}
+}
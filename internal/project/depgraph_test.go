@@ -0,0 +1,84 @@
+package project
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDescribeDependenciesReportsLockAndVendorState(t *testing.T) {
+	root := t.TempDir()
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "lib.selene"), []byte("fn lib() {}\n"), 0o644); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+	dep, locked, err := PrepareDependency(root, "example.com/lib", "v1.0.0", "", src)
+	if err != nil {
+		t.Fatalf("PrepareDependency returned error: %v", err)
+	}
+	manifest := &Manifest{Dependencies: map[string]Dependency{
+		"example.com/lib":     dep,
+		"example.com/missing": {Version: "v0.1.0"},
+	}}
+	lock := &Lockfile{}
+	lock.Set(locked)
+
+	statuses, err := DescribeDependencies(root, manifest, lock)
+	if err != nil {
+		t.Fatalf("DescribeDependencies returned error: %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("expected 2 statuses, got %d", len(statuses))
+	}
+	lib := statuses[0]
+	if lib.Module != "example.com/lib" || !lib.Locked || !lib.Vendored || !lib.Verified || lib.Checksum != locked.Checksum {
+		t.Fatalf("unexpected status for vendored dependency: %+v", lib)
+	}
+	if missing := statuses[1]; missing.Locked || missing.Vendored || missing.Verified {
+		t.Fatalf("unexpected status for unlocked dependency: %+v", missing)
+	}
+}
+
+func TestBuildDependencyGraphFollowsVendoredManifests(t *testing.T) {
+	root := t.TempDir()
+	src := t.TempDir()
+	nested := "[project]\nname = \"lib\"\n\n[dependencies]\n\"example.com/util\" = { version = \"v0.2.0\" }\n"
+	if err := os.WriteFile(filepath.Join(src, ManifestName), []byte(nested), 0o644); err != nil {
+		t.Fatalf("write nested manifest: %v", err)
+	}
+	dep, locked, err := PrepareDependency(root, "example.com/lib", "v1.0.0", "", src)
+	if err != nil {
+		t.Fatalf("PrepareDependency returned error: %v", err)
+	}
+	manifest := &Manifest{Dependencies: map[string]Dependency{"example.com/lib": dep}}
+	manifest.Project.Module = "example.com/app"
+	lock := &Lockfile{}
+	lock.Set(locked)
+
+	graph, err := BuildDependencyGraph(root, manifest, lock)
+	if err != nil {
+		t.Fatalf("BuildDependencyGraph returned error: %v", err)
+	}
+	want := []DependencyEdge{
+		{From: "example.com/app", To: "example.com/lib", Version: "v1.0.0"},
+		{From: "example.com/lib", To: "example.com/util", Version: "v0.2.0"},
+	}
+	if len(graph.Edges) != len(want) {
+		t.Fatalf("unexpected edges: %+v", graph.Edges)
+	}
+	for i, edge := range want {
+		if graph.Edges[i] != edge {
+			t.Fatalf("edge %d = %+v, want %+v", i, graph.Edges[i], edge)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := graph.WriteDOT(&buf); err != nil {
+		t.Fatalf("WriteDOT returned error: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"example.com/lib" -> "example.com/util" [label="v0.2.0"];`) {
+		t.Fatalf("unexpected DOT output:\n%s", buf.String())
+	}
+}
@@ -64,6 +64,9 @@ func fetchDependencySource(module, version, source string) (string, func(), erro
 	if repo == "" {
 		repo = module
 	}
+	if base, ok := registryURL(repo); ok {
+		return fetchRegistrySource(base, module, version)
+	}
 	tmpDir, err := os.MkdirTemp("", "selene-dep-*")
 	if err != nil {
 		return "", nil, err
@@ -84,7 +87,7 @@ func fetchDependencySource(module, version, source string) (string, func(), erro
 		}
 		_ = os.RemoveAll(dest)
 		// Retry with a full clone followed by a checkout so tags and commit hashes work.
-		if err := runGit("clone", repo, dest); err != nil {
+		if err := runGit("clone", "--", repo, dest); err != nil {
 			cleanup()
 			return "", nil, err
 		}
@@ -97,15 +100,45 @@ func fetchDependencySource(module, version, source string) (string, func(), erro
 }
 
 func runGit(args ...string) error {
+	_, err := gitOutput(args...)
+	return err
+}
+
+func gitOutput(args ...string) (string, error) {
 	cmd := exec.Command("git", args...)
-	var stderr bytes.Buffer
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
 		msg := strings.TrimSpace(stderr.String())
 		if msg != "" {
-			return fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+			return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+		}
+		return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return stdout.String(), nil
+}
+
+// ListVersions returns the versions source publishes for module: the tags
+// of a git repository, or the version list of a registry.
+func ListVersions(module, source string) ([]string, error) {
+	if base, ok := registryURL(source); ok {
+		return registryVersions(base, module)
+	}
+	// The -- keeps a source starting with "-" from being read as an option.
+	out, err := gitOutput("ls-remote", "--tags", "--refs", "--", source)
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for _, line := range strings.Split(out, "\n") {
+		_, ref, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if !ok {
+			continue
+		}
+		if tag, ok := strings.CutPrefix(ref, "refs/tags/"); ok {
+			tags = append(tags, tag)
 		}
-		return fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
 	}
-	return nil
+	return tags, nil
 }
@@ -83,3 +83,150 @@ func runGitCmd(t *testing.T, dir string, args ...string) {
 		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, out)
 	}
 }
+
+func TestUpdateDependencyResolvesCaretRange(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available in PATH")
+	}
+	repoDir := t.TempDir()
+	runGitCmd(t, repoDir, "init")
+	runGitCmd(t, repoDir, "config", "user.email", "ci@example.com")
+	runGitCmd(t, repoDir, "config", "user.name", "CI")
+	for _, tag := range []string{"v1.0.0", "v1.1.0", "v2.0.0"} {
+		if err := os.WriteFile(filepath.Join(repoDir, "lib.selene"), []byte("// "+tag+"\n"), 0o644); err != nil {
+			t.Fatalf("write repo file: %v", err)
+		}
+		runGitCmd(t, repoDir, "add", ".")
+		runGitCmd(t, repoDir, "commit", "-m", tag)
+		runGitCmd(t, repoDir, "tag", tag)
+	}
+
+	root := t.TempDir()
+	module := "github.com/example/fixture"
+	dep := Dependency{Version: "^1.0.0", Source: repoDir}
+	lock := &Lockfile{}
+	_, entry, err := PrepareDependency(root, module, "v1.0.0", repoDir, "")
+	if err != nil {
+		t.Fatalf("PrepareDependency returned error: %v", err)
+	}
+	lock.Set(entry)
+
+	manifest := &Manifest{Dependencies: map[string]Dependency{module: dep}}
+	statuses, err := CheckVersions(manifest, lock)
+	if err != nil {
+		t.Fatalf("CheckVersions returned error: %v", err)
+	}
+	want := VersionStatus{Module: module, Constraint: "^1.0.0", Current: "v1.0.0", Wanted: "v1.1.0", Latest: "v2.0.0"}
+	if len(statuses) != 1 || statuses[0] != want || !statuses[0].Outdated() {
+		t.Fatalf("unexpected version status: %+v", statuses)
+	}
+
+	from, to, err := UpdateDependency(root, module, dep, lock)
+	if err != nil {
+		t.Fatalf("UpdateDependency returned error: %v", err)
+	}
+	if from != "v1.0.0" || to != "v1.1.0" {
+		t.Fatalf("expected update v1.0.0 -> v1.1.0, got %s -> %s", from, to)
+	}
+	locked, _ := lock.Lookup(module)
+	data, err := os.ReadFile(filepath.Join(root, locked.Vendor, "lib.selene"))
+	if err != nil || !strings.Contains(string(data), "v1.1.0") {
+		t.Fatalf("expected v1.1.0 to be vendored, got %q (%v)", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, entry.Vendor)); !os.IsNotExist(err) {
+		t.Fatalf("expected stale vendor directory to be removed, got %v", err)
+	}
+	if err := CheckLocked(module, Dependency{Version: "~1.0.0"}, locked); err == nil {
+		t.Fatalf("expected v1.1.0 to violate ~1.0.0")
+	}
+}
+
+func TestSyncVendorRestoresLockedDependencies(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available in PATH")
+	}
+	repoDir := t.TempDir()
+	runGitCmd(t, repoDir, "init")
+	runGitCmd(t, repoDir, "config", "user.email", "ci@example.com")
+	runGitCmd(t, repoDir, "config", "user.name", "CI")
+	if err := os.WriteFile(filepath.Join(repoDir, "lib.selene"), []byte("// dependency fixture\n"), 0o644); err != nil {
+		t.Fatalf("write repo file: %v", err)
+	}
+	runGitCmd(t, repoDir, "add", ".")
+	runGitCmd(t, repoDir, "commit", "-m", "initial commit")
+	runGitCmd(t, repoDir, "tag", "v1.0.0")
+
+	root := t.TempDir()
+	module := "github.com/example/fixture"
+	dep, entry, err := PrepareDependency(root, module, "v1.0.0", repoDir, "")
+	if err != nil {
+		t.Fatalf("PrepareDependency returned error: %v", err)
+	}
+	lock := &Lockfile{}
+	lock.Set(entry)
+	deps := map[string]Dependency{module: dep}
+
+	synced, err := SyncVendor(root, deps, lock)
+	if err != nil {
+		t.Fatalf("SyncVendor returned error: %v", err)
+	}
+	if len(synced.Verified) != 1 || len(synced.Restored) != 0 || len(synced.Removed) != 0 {
+		t.Fatalf("expected an in-sync vendor tree to be left alone, got %+v", synced)
+	}
+
+	if err := os.RemoveAll(filepath.Join(root, entry.Vendor)); err != nil {
+		t.Fatalf("remove vendored copy: %v", err)
+	}
+	stray := filepath.Join(root, VendorDirectory, "github.com", "example", "stale@v0.1.0")
+	if err := os.MkdirAll(stray, 0o755); err != nil {
+		t.Fatalf("create stray directory: %v", err)
+	}
+	synced, err = SyncVendor(root, deps, lock)
+	if err != nil {
+		t.Fatalf("SyncVendor returned error: %v", err)
+	}
+	if len(synced.Restored) != 1 || synced.Restored[0] != module {
+		t.Fatalf("expected %s to be restored, got %+v", module, synced)
+	}
+	if len(synced.Removed) != 1 || synced.Removed[0] != "vendor/github.com/example/stale@v0.1.0" {
+		t.Fatalf("expected the stray directory to be removed, got %+v", synced.Removed)
+	}
+	if err := VerifyChecksum(filepath.Join(root, entry.Vendor), entry.Checksum); err != nil {
+		t.Fatalf("restored copy does not match the lockfile: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, entry.Vendor, ".git")); !os.IsNotExist(err) {
+		t.Fatalf("expected the clone's .git directory to be left out, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(repoDir, "lib.selene"), []byte("// retagged\n"), 0o644); err != nil {
+		t.Fatalf("write repo file: %v", err)
+	}
+	runGitCmd(t, repoDir, "commit", "-am", "retag")
+	runGitCmd(t, repoDir, "tag", "-f", "v1.0.0")
+	if err := os.WriteFile(filepath.Join(root, entry.Vendor, "lib.selene"), []byte("// edited\n"), 0o644); err != nil {
+		t.Fatalf("edit vendored file: %v", err)
+	}
+	if _, err := SyncVendor(root, deps, lock); err == nil || !strings.Contains(err.Error(), "do not match selene.lock") {
+		t.Fatalf("expected a retagged source to be rejected, got %v", err)
+	}
+}
+
+func TestListVersionsTreatsSourceAsRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available in PATH")
+	}
+	// Run from a clone, so a source read as an option would act on its
+	// origin remote.
+	upstream := t.TempDir()
+	runGitCmd(t, upstream, "init")
+	clone := t.TempDir()
+	runGitCmd(t, clone, "clone", upstream, ".")
+	t.Chdir(clone)
+	marker := filepath.Join(t.TempDir(), "ran")
+	if _, err := ListVersions("github.com/example/fixture", "--upload-pack=touch "+marker); err == nil {
+		t.Fatalf("expected an option-like source to fail as a repository")
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatalf("expected the source not to run as a git option, got %v", err)
+	}
+}
@@ -28,6 +28,8 @@ func EnsureVendorTree(root string) (string, error) {
 }
 
 // CopyIntoVendor copies the contents of src into the destination directory.
+// A .git directory is left behind: its contents differ between clones of
+// the same commit and would make the vendored checksum unreproducible.
 func CopyIntoVendor(src, dest string) error {
 	absSrc, err := filepath.Abs(src)
 	if err != nil {
@@ -57,6 +59,9 @@ func CopyIntoVendor(src, dest string) error {
 			return err
 		}
 		if d.IsDir() {
+			if d.Name() == ".git" && rel != "." {
+				return filepath.SkipDir
+			}
 			if rel == "." {
 				return os.MkdirAll(absDest, 0o750)
 			}
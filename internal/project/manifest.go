@@ -8,7 +8,9 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -19,6 +21,9 @@ const (
 	LockName = "selene.lock"
 	// VendorDirectory is the root directory for vendored dependencies.
 	VendorDirectory = "vendor"
+	// SourceDirectory holds the project's own modules, which import
+	// declarations resolve relative to the manifest module path.
+	SourceDirectory = "src"
 )
 
 // Manifest represents the contents of a selene.toml file.
@@ -34,16 +39,63 @@ type Manifest struct {
 	}
 	Examples struct {
 		Roots []string
+		// Tags maps an example file or directory, relative to the project
+		// root, to the tags applied to every script it covers.
+		Tags map[string][]string
 	}
 	Dependencies map[string]Dependency
+	// Profiles holds the [profiles.<name>] sections keyed by profile name.
+	Profiles map[string]Profile
+	// Capabilities holds the [capabilities] section, which switches builtin
+	// groups such as fs or os.exec on or off for `selene run`. Groups it
+	// does not mention stay enabled.
+	Capabilities map[string]bool
+	// Workspace lists the member packages when this manifest is the root of
+	// a workspace. See LoadWorkspace.
+	Workspace struct {
+		Members []string
+	}
+	// Include lists manifests, relative to the project root, that are merged
+	// over this one at load time. Missing includes are skipped so files such
+	// as selene.local.toml can stay out of version control.
+	Include []string
+
+	// origin remembers the committed, unexpanded manifest so SaveManifest
+	// does not write environment values or included settings back to disk.
+	origin *manifestOrigin
+}
+
+type manifestOrigin struct {
+	base   *Manifest
+	loaded *Manifest
 }
 
+// maxIncludeDepth bounds nested includes so a cycle fails loudly.
+const maxIncludeDepth = 8
+
 // Dependency describes a module requirement recorded in the manifest.
 type Dependency struct {
 	Version string
 	Source  string
 }
 
+// Profile bundles the run options selected with `selene run --profile`.
+type Profile struct {
+	// Backend is one of "interp", "vm", or "jit". Empty means the interpreter.
+	Backend     string
+	Disassemble bool
+	// Sandbox disables the os builtins that run processes or mutate the
+	// process environment.
+	Sandbox bool
+	// NoContracts turns off checking of requires and ensures clauses and
+	// invariants, as contracts = false does.
+	NoContracts bool
+	// Optimize is the optimization level, 0 or 1, when SetsOptimize is
+	// true. Profiles that leave optimize out use the default level.
+	Optimize     int
+	SetsOptimize bool
+}
+
 // LockedDependency captures an entry in selene.lock.
 type LockedDependency struct {
 	Module   string
@@ -80,13 +132,59 @@ func FindRoot(start string) (string, error) {
 	}
 }
 
-// LoadManifest reads and decodes the selene.toml located at root.
+// Mode is how the toolchain treats a source file.
+type Mode int
+
+const (
+	// ProjectMode applies to files inside a project, which by convention
+	// each start with a package declaration.
+	ProjectMode Mode = iota
+	// ScriptMode applies to standalone files with no selene.toml above
+	// them. A script needs no package declaration, may start with a #! line,
+	// and runs with the script prelude.
+	ScriptMode
+)
+
+// ModeOf reports the mode of the source file at path, which need not exist.
+func ModeOf(path string) Mode {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ProjectMode
+	}
+	if _, err := FindRoot(filepath.Dir(abs)); errors.Is(err, fs.ErrNotExist) {
+		return ScriptMode
+	}
+	return ProjectMode
+}
+
+// LoadManifest reads and decodes the selene.toml located at root. Files named
+// by include are merged in order, each overriding the values before it, and
+// ${VAR} or ${VAR:-default} references in string values are then expanded
+// from the environment.
 func LoadManifest(root string) (*Manifest, error) {
 	data, err := ReadFile(root, ManifestName)
 	if err != nil {
 		return nil, err
 	}
-	manifest := &Manifest{Dependencies: make(map[string]Dependency)}
+	base, err := decodeManifest(data)
+	if err != nil {
+		return nil, err
+	}
+	merged := base.clone()
+	if err := mergeIncludes(root, merged, base.Include, []string{ManifestName}); err != nil {
+		return nil, err
+	}
+	manifest, err := expandManifest(merged)
+	if err != nil {
+		return nil, err
+	}
+	manifest.origin = &manifestOrigin{base: base, loaded: manifest.clone()}
+	return manifest, nil
+}
+
+func decodeManifest(data []byte) (*Manifest, error) {
+	manifest := &Manifest{Dependencies: make(map[string]Dependency), Profiles: make(map[string]Profile), Capabilities: make(map[string]bool)}
+	manifest.Examples.Tags = make(map[string][]string)
 	scanner := bufio.NewScanner(bytes.NewReader(data))
 	section := ""
 	for scanner.Scan() {
@@ -96,9 +194,29 @@ func LoadManifest(root string) (*Manifest, error) {
 		}
 		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
 			section = strings.Trim(line, "[]")
+			if name, ok := strings.CutPrefix(section, "profiles."); ok {
+				if name == "" {
+					return nil, errors.New("profile sections must be named, as in [profiles.dev]")
+				}
+				manifest.Profiles[name] = manifest.Profiles[name]
+			}
+			continue
+		}
+		if name, ok := strings.CutPrefix(section, "profiles."); ok {
+			if err := parseProfileLine(manifest.Profiles, name, line); err != nil {
+				return nil, err
+			}
 			continue
 		}
 		switch section {
+		case "":
+			if key, value, ok := splitKeyValue(line); ok && key == "include" {
+				includes, err := parseStringArray(value)
+				if err != nil {
+					return nil, fmt.Errorf("include: %w", err)
+				}
+				manifest.Include = includes
+			}
 		case "project":
 			if err := parseProjectLine(&manifest.Project, line); err != nil {
 				return nil, err
@@ -111,10 +229,26 @@ func LoadManifest(root string) (*Manifest, error) {
 			if err := parseArrayLine(&manifest.Examples.Roots, line); err != nil {
 				return nil, err
 			}
+		case "examples.tags":
+			if err := parseTagLine(manifest.Examples.Tags, line); err != nil {
+				return nil, err
+			}
+		case "workspace":
+			if key, value, ok := splitKeyValue(line); ok && key == "members" {
+				members, err := parseStringArray(value)
+				if err != nil {
+					return nil, fmt.Errorf("workspace members: %w", err)
+				}
+				manifest.Workspace.Members = members
+			}
 		case "dependencies":
 			if err := parseDependencyLine(manifest.Dependencies, line); err != nil {
 				return nil, err
 			}
+		case "capabilities":
+			if err := parseCapabilityLine(manifest.Capabilities, line); err != nil {
+				return nil, err
+			}
 		}
 	}
 	if err := scanner.Err(); err != nil {
@@ -123,6 +257,217 @@ func LoadManifest(root string) (*Manifest, error) {
 	return manifest, nil
 }
 
+// mergeIncludes layers each included manifest over target. chain holds the
+// files currently being included and is used to report cycles.
+func mergeIncludes(root string, target *Manifest, includes []string, chain []string) error {
+	for _, include := range includes {
+		name, err := expandEnv(include)
+		if err != nil {
+			return fmt.Errorf("include %q: %w", include, err)
+		}
+		name = filepath.Clean(filepath.FromSlash(name))
+		for _, seen := range chain {
+			if seen == name {
+				return fmt.Errorf("include cycle: %s -> %s", strings.Join(chain, " -> "), name)
+			}
+		}
+		if len(chain) > maxIncludeDepth {
+			return fmt.Errorf("include %q: nested too deeply", include)
+		}
+		data, err := ReadFile(root, name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("include %q: %w", include, err)
+		}
+		layer, err := decodeManifest(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		target.overlay(layer)
+		if err := mergeIncludes(root, target, layer.Include, append(chain, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// overlay copies every value set in layer over m. Dependencies are merged per
+// module, so an include may override the source of a single requirement,
+// while an included profile replaces the profile of the same name.
+func (m *Manifest) overlay(layer *Manifest) {
+	overlayString(&m.Project.Name, layer.Project.Name)
+	overlayString(&m.Project.Version, layer.Project.Version)
+	overlayString(&m.Project.Module, layer.Project.Module)
+	overlayString(&m.Project.Entry, layer.Project.Entry)
+	if layer.Docs.Paths != nil {
+		m.Docs.Paths = append([]string(nil), layer.Docs.Paths...)
+	}
+	if layer.Examples.Roots != nil {
+		m.Examples.Roots = append([]string(nil), layer.Examples.Roots...)
+	}
+	if layer.Workspace.Members != nil {
+		m.Workspace.Members = append([]string(nil), layer.Workspace.Members...)
+	}
+	for module, dep := range layer.Dependencies {
+		existing := m.Dependencies[module]
+		overlayString(&existing.Version, dep.Version)
+		overlayString(&existing.Source, dep.Source)
+		m.Dependencies[module] = existing
+	}
+	for name, profile := range layer.Profiles {
+		m.Profiles[name] = profile
+	}
+	for name, enabled := range layer.Capabilities {
+		m.Capabilities[name] = enabled
+	}
+	for path, tags := range layer.Examples.Tags {
+		m.Examples.Tags[path] = cloneStrings(tags)
+	}
+}
+
+func overlayString(target *string, value string) {
+	if value != "" {
+		*target = value
+	}
+}
+
+func (m *Manifest) clone() *Manifest {
+	out := &Manifest{
+		Dependencies: make(map[string]Dependency, len(m.Dependencies)),
+		Profiles:     make(map[string]Profile, len(m.Profiles)),
+		Capabilities: make(map[string]bool, len(m.Capabilities)),
+	}
+	out.Project = m.Project
+	out.Docs.Paths = cloneStrings(m.Docs.Paths)
+	out.Examples.Roots = cloneStrings(m.Examples.Roots)
+	out.Workspace.Members = cloneStrings(m.Workspace.Members)
+	out.Include = cloneStrings(m.Include)
+	for module, dep := range m.Dependencies {
+		out.Dependencies[module] = dep
+	}
+	for name, profile := range m.Profiles {
+		out.Profiles[name] = profile
+	}
+	for name, enabled := range m.Capabilities {
+		out.Capabilities[name] = enabled
+	}
+	out.Examples.Tags = make(map[string][]string, len(m.Examples.Tags))
+	for path, tags := range m.Examples.Tags {
+		out.Examples.Tags[path] = cloneStrings(tags)
+	}
+	return out
+}
+
+func cloneStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string{}, values...)
+}
+
+func expandManifest(m *Manifest) (*Manifest, error) {
+	out := m.clone()
+	fields := []*string{&out.Project.Name, &out.Project.Version, &out.Project.Module, &out.Project.Entry}
+	for i := range out.Docs.Paths {
+		fields = append(fields, &out.Docs.Paths[i])
+	}
+	for i := range out.Examples.Roots {
+		fields = append(fields, &out.Examples.Roots[i])
+	}
+	for i := range out.Workspace.Members {
+		fields = append(fields, &out.Workspace.Members[i])
+	}
+	for _, field := range fields {
+		expanded, err := expandEnv(*field)
+		if err != nil {
+			return nil, err
+		}
+		*field = expanded
+	}
+	for module, dep := range out.Dependencies {
+		version, err := expandEnv(dep.Version)
+		if err != nil {
+			return nil, fmt.Errorf("dependency %s: %w", module, err)
+		}
+		source, err := expandEnv(dep.Source)
+		if err != nil {
+			return nil, fmt.Errorf("dependency %s: %w", module, err)
+		}
+		out.Dependencies[module] = Dependency{Version: version, Source: source}
+	}
+	for name, profile := range out.Profiles {
+		backend, err := expandEnv(profile.Backend)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		profile.Backend = backend
+		out.Profiles[name] = profile
+	}
+	return out, nil
+}
+
+// expandEnv replaces ${VAR} and ${VAR:-default} references in value. A
+// reference to an unset variable without a default is an error, so a missing
+// credential is reported instead of silently becoming an empty string. "$$"
+// produces a literal dollar sign.
+func expandEnv(value string) (string, error) {
+	if !strings.Contains(value, "$") {
+		return value, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c != '$' {
+			b.WriteByte(c)
+			continue
+		}
+		if i+1 < len(value) && value[i+1] == '$' {
+			b.WriteByte('$')
+			i++
+			continue
+		}
+		if i+1 >= len(value) || value[i+1] != '{' {
+			b.WriteByte(c)
+			continue
+		}
+		end := strings.IndexByte(value[i+2:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated variable reference in %q", value)
+		}
+		ref := value[i+2 : i+2+end]
+		name, fallback, hasDefault := strings.Cut(ref, ":-")
+		if !isEnvName(name) {
+			return "", fmt.Errorf("invalid variable name %q in %q", name, value)
+		}
+		if env, ok := os.LookupEnv(name); ok && (env != "" || !hasDefault) {
+			b.WriteString(env)
+		} else if hasDefault {
+			b.WriteString(fallback)
+		} else {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		i += end + 2
+	}
+	return b.String(), nil
+}
+
+func isEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func parseProjectLine(project *struct {
 	Name    string
 	Version string
@@ -202,6 +547,86 @@ func parseDependencyLine(deps map[string]Dependency, line string) error {
 	return nil
 }
 
+func parseTagLine(tags map[string][]string, line string) error {
+	key, value, ok := splitKeyValue(line)
+	if !ok {
+		return nil
+	}
+	path, err := parseString(key)
+	if err != nil {
+		return fmt.Errorf("example tag keys must be quoted paths: %w", err)
+	}
+	values, err := parseStringArray(value)
+	if err != nil {
+		return fmt.Errorf("tags for %s: %w", path, err)
+	}
+	tags[path] = values
+	return nil
+}
+
+func parseProfileLine(profiles map[string]Profile, name, line string) error {
+	key, value, ok := splitKeyValue(line)
+	if !ok {
+		return nil
+	}
+	profile := profiles[name]
+	switch key {
+	case "backend":
+		parsed, err := parseString(value)
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+		profile.Backend = parsed
+	case "disassemble":
+		parsed, err := parseBool(value)
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+		profile.Disassemble = parsed
+	case "sandbox":
+		parsed, err := parseBool(value)
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+		profile.Sandbox = parsed
+	case "contracts":
+		parsed, err := parseBool(value)
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+		profile.NoContracts = !parsed
+	case "optimize":
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 || parsed > 1 {
+			return fmt.Errorf("profile %s: optimize must be 0 or 1, got %q", name, value)
+		}
+		profile.Optimize, profile.SetsOptimize = parsed, true
+	default:
+		return fmt.Errorf("profile %s: unknown option %q", name, key)
+	}
+	profiles[name] = profile
+	return nil
+}
+
+// parseCapabilityLine reads a line such as fs = false. Names with a dot,
+// such as os.exec, may be written bare or quoted.
+func parseCapabilityLine(capabilities map[string]bool, line string) error {
+	key, value, ok := splitKeyValue(line)
+	if !ok {
+		return nil
+	}
+	name := strings.Trim(key, "\"")
+	if name == "" {
+		return errors.New("capabilities: expected a capability name")
+	}
+	enabled, err := parseBool(value)
+	if err != nil {
+		return fmt.Errorf("capability %s: %w", name, err)
+	}
+	capabilities[name] = enabled
+	return nil
+}
+
 func splitKeyValue(line string) (string, string, bool) {
 	key, value, ok := strings.Cut(line, "=")
 	if !ok {
@@ -218,6 +643,17 @@ func parseString(value string) (string, error) {
 	return value[1 : len(value)-1], nil
 }
 
+func parseBool(value string) (bool, error) {
+	switch strings.TrimSpace(value) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("expected true or false, got %q", value)
+	}
+}
+
 func parseStringArray(value string) ([]string, error) {
 	value = strings.TrimSpace(value)
 	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
@@ -239,33 +675,63 @@ func parseStringArray(value string) ([]string, error) {
 	return result, nil
 }
 
-// SaveManifest writes the manifest back to selene.toml in root.
+// SaveManifest writes the manifest back to selene.toml in root. For a
+// manifest returned by LoadManifest, values that were not changed since
+// loading keep their original unexpanded form, and values that came only from
+// included files are left out.
 func SaveManifest(root string, manifest *Manifest) error {
 	if manifest.Dependencies == nil {
 		manifest.Dependencies = make(map[string]Dependency)
 	}
+	out := manifest
+	if manifest.origin != nil {
+		out = manifest.origin.restore(manifest)
+	}
 	var buf bytes.Buffer
+	if len(out.Include) > 0 {
+		writeStringArray(&buf, "include", out.Include)
+		buf.WriteString("\n")
+	}
 	buf.WriteString("[project]\n")
-	fmt.Fprintf(&buf, "name = \"%s\"\n", manifest.Project.Name)
-	fmt.Fprintf(&buf, "version = \"%s\"\n", manifest.Project.Version)
-	if manifest.Project.Module != "" {
-		fmt.Fprintf(&buf, "module = \"%s\"\n", manifest.Project.Module)
+	fmt.Fprintf(&buf, "name = \"%s\"\n", out.Project.Name)
+	fmt.Fprintf(&buf, "version = \"%s\"\n", out.Project.Version)
+	if out.Project.Module != "" {
+		fmt.Fprintf(&buf, "module = \"%s\"\n", out.Project.Module)
 	}
-	fmt.Fprintf(&buf, "entry = \"%s\"\n\n", manifest.Project.Entry)
+	fmt.Fprintf(&buf, "entry = \"%s\"\n\n", out.Project.Entry)
 
 	buf.WriteString("[docs]\n")
-	writeStringArray(&buf, "paths", manifest.Docs.Paths)
+	writeStringArray(&buf, "paths", out.Docs.Paths)
 	buf.WriteString("\n")
 
 	buf.WriteString("[examples]\n")
-	writeStringArray(&buf, "roots", manifest.Examples.Roots)
+	writeStringArray(&buf, "roots", out.Examples.Roots)
 	buf.WriteString("\n")
 
-	if len(manifest.Dependencies) > 0 {
+	if len(out.Workspace.Members) > 0 {
+		buf.WriteString("[workspace]\n")
+		writeStringArray(&buf, "members", out.Workspace.Members)
+		buf.WriteString("\n")
+	}
+
+	if len(out.Examples.Tags) > 0 {
+		buf.WriteString("[examples.tags]\n")
+		paths := make([]string, 0, len(out.Examples.Tags))
+		for path := range out.Examples.Tags {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			writeStringArray(&buf, fmt.Sprintf("\"%s\"", path), out.Examples.Tags[path])
+		}
+		buf.WriteString("\n")
+	}
+
+	if len(out.Dependencies) > 0 {
 		buf.WriteString("[dependencies]\n")
-		modules := SortedModules(manifest.Dependencies)
+		modules := SortedModules(out.Dependencies)
 		for _, module := range modules {
-			dep := manifest.Dependencies[module]
+			dep := out.Dependencies[module]
 			fmt.Fprintf(&buf, "\"%s\" = { version = \"%s\"", module, dep.Version)
 			if dep.Source != "" {
 				fmt.Fprintf(&buf, ", source = \"%s\"", dep.Source)
@@ -274,11 +740,125 @@ func SaveManifest(root string, manifest *Manifest) error {
 		}
 	}
 
+	if len(out.Capabilities) > 0 {
+		if !bytes.HasSuffix(buf.Bytes(), []byte("\n\n")) {
+			buf.WriteString("\n")
+		}
+		buf.WriteString("[capabilities]\n")
+		names := make([]string, 0, len(out.Capabilities))
+		for name := range out.Capabilities {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&buf, "%s = %t\n", name, out.Capabilities[name])
+		}
+	}
+
+	for _, name := range sortedProfiles(out.Profiles) {
+		profile := out.Profiles[name]
+		if !bytes.HasSuffix(buf.Bytes(), []byte("\n\n")) {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "[profiles.%s]\n", name)
+		if profile.Backend != "" {
+			fmt.Fprintf(&buf, "backend = \"%s\"\n", profile.Backend)
+		}
+		if profile.Disassemble {
+			buf.WriteString("disassemble = true\n")
+		}
+		if profile.Sandbox {
+			buf.WriteString("sandbox = true\n")
+		}
+		if profile.NoContracts {
+			buf.WriteString("contracts = false\n")
+		}
+		if profile.SetsOptimize {
+			fmt.Fprintf(&buf, "optimize = %d\n", profile.Optimize)
+		}
+	}
+
 	path, err := ResolveUnderRoot(root, ManifestName)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, buf.Bytes(), 0o600)
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+	if manifest.origin != nil {
+		manifest.origin = &manifestOrigin{base: out.clone(), loaded: manifest.clone()}
+	}
+	return nil
+}
+
+// restore maps current back onto the committed manifest: anything unchanged
+// since loading takes its value from base, anything edited is written as is.
+func (o *manifestOrigin) restore(current *Manifest) *Manifest {
+	out := &Manifest{Dependencies: make(map[string]Dependency), Profiles: make(map[string]Profile), Capabilities: make(map[string]bool)}
+	out.Project.Name = pickString(current.Project.Name, o.loaded.Project.Name, o.base.Project.Name)
+	out.Project.Version = pickString(current.Project.Version, o.loaded.Project.Version, o.base.Project.Version)
+	out.Project.Module = pickString(current.Project.Module, o.loaded.Project.Module, o.base.Project.Module)
+	out.Project.Entry = pickString(current.Project.Entry, o.loaded.Project.Entry, o.base.Project.Entry)
+	out.Docs.Paths = pickStrings(current.Docs.Paths, o.loaded.Docs.Paths, o.base.Docs.Paths)
+	out.Examples.Roots = pickStrings(current.Examples.Roots, o.loaded.Examples.Roots, o.base.Examples.Roots)
+	out.Workspace.Members = pickStrings(current.Workspace.Members, o.loaded.Workspace.Members, o.base.Workspace.Members)
+	out.Include = cloneStrings(current.Include)
+	for module, dep := range current.Dependencies {
+		loaded, wasLoaded := o.loaded.Dependencies[module]
+		if !wasLoaded || loaded != dep {
+			out.Dependencies[module] = dep
+			continue
+		}
+		if committed, ok := o.base.Dependencies[module]; ok {
+			out.Dependencies[module] = committed
+		}
+	}
+	for name, profile := range current.Profiles {
+		loaded, wasLoaded := o.loaded.Profiles[name]
+		if !wasLoaded || loaded != profile {
+			out.Profiles[name] = profile
+			continue
+		}
+		if committed, ok := o.base.Profiles[name]; ok {
+			out.Profiles[name] = committed
+		}
+	}
+	for name, enabled := range current.Capabilities {
+		loaded, wasLoaded := o.loaded.Capabilities[name]
+		if !wasLoaded || loaded != enabled {
+			out.Capabilities[name] = enabled
+			continue
+		}
+		if committed, ok := o.base.Capabilities[name]; ok {
+			out.Capabilities[name] = committed
+		}
+	}
+	out.Examples.Tags = make(map[string][]string)
+	for path, tags := range current.Examples.Tags {
+		loaded, wasLoaded := o.loaded.Examples.Tags[path]
+		if !wasLoaded || !slices.Equal(loaded, tags) {
+			out.Examples.Tags[path] = cloneStrings(tags)
+			continue
+		}
+		if committed, ok := o.base.Examples.Tags[path]; ok {
+			out.Examples.Tags[path] = cloneStrings(committed)
+		}
+	}
+	return out
+}
+
+func pickString(current, loaded, base string) string {
+	if current == loaded {
+		return base
+	}
+	return current
+}
+
+func pickStrings(current, loaded, base []string) []string {
+	if slices.Equal(current, loaded) {
+		return cloneStrings(base)
+	}
+	return cloneStrings(current)
 }
 
 func writeStringArray(buf *bytes.Buffer, key string, values []string) {
@@ -388,6 +968,28 @@ func VendorPath(module, version string) string {
 	return filepath.Join(VendorDirectory, modulePath)
 }
 
+// LookupProfile returns the named profile, listing the defined profiles when
+// it does not exist.
+func (m *Manifest) LookupProfile(name string) (Profile, error) {
+	if profile, ok := m.Profiles[name]; ok {
+		return profile, nil
+	}
+	defined := sortedProfiles(m.Profiles)
+	if len(defined) == 0 {
+		return Profile{}, fmt.Errorf("unknown profile %q: %s defines no profiles", name, ManifestName)
+	}
+	return Profile{}, fmt.Errorf("unknown profile %q (defined: %s)", name, strings.Join(defined, ", "))
+}
+
+func sortedProfiles(profiles map[string]Profile) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // SortedModules returns the manifest dependency keys in lexical order.
 func SortedModules(deps map[string]Dependency) []string {
 	modules := make([]string, 0, len(deps))
@@ -1,6 +1,7 @@
 package project
 
 import (
+	"maps"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -30,6 +31,23 @@ func TestFindRootLocatesManifest(t *testing.T) {
 	}
 }
 
+func TestModeOfDistinguishesScriptsFromProjectFiles(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "app")
+	if err := os.MkdirAll(filepath.Join(project, "src"), 0o755); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(project, ManifestName), []byte("[project]\nname = \"demo\"\n"), 0o644); err != nil {
+		t.Fatalf("failed to create manifest: %v", err)
+	}
+	if mode := ModeOf(filepath.Join(project, "src", "unsaved.selene")); mode != ProjectMode {
+		t.Fatalf("expected a file under the manifest to be in project mode, got %v", mode)
+	}
+	if mode := ModeOf(filepath.Join(root, "tool.selene")); mode != ScriptMode {
+		t.Fatalf("expected a file outside any project to be a script, got %v", mode)
+	}
+}
+
 func TestLoadManifestParsesSections(t *testing.T) {
 	dir := t.TempDir()
 	manifest := `[project]
@@ -95,6 +113,216 @@ func TestSaveManifestOrdersDependencies(t *testing.T) {
 	}
 }
 
+func TestLoadManifestMergesIncludesAndExpandsEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SELENE_TEST_REGISTRY", "https://registry.internal")
+	manifest := `include = ["selene.local.toml", "missing.toml"]
+
+[project]
+name = "demo"
+version = "0.1.0"
+entry = "${SELENE_TEST_ENTRY:-main.selene}"
+
+[dependencies]
+"lib/math" = { version = "1.0.0", source = "https://modules" }
+"lib/json" = { version = "0.2.0" }
+`
+	local := `[dependencies]
+"lib/math" = { source = "${SELENE_TEST_REGISTRY}/math" }
+`
+	if err := os.WriteFile(filepath.Join(dir, ManifestName), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "selene.local.toml"), []byte(local), 0o644); err != nil {
+		t.Fatalf("failed to write include: %v", err)
+	}
+	loaded, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatalf("LoadManifest returned error: %v", err)
+	}
+	if loaded.Project.Entry != "main.selene" {
+		t.Fatalf("expected default entry, got %q", loaded.Project.Entry)
+	}
+	dep := loaded.Dependencies["lib/math"]
+	if dep.Version != "1.0.0" || dep.Source != "https://registry.internal/math" {
+		t.Fatalf("expected include to override dependency source, got %+v", dep)
+	}
+
+	loaded.Dependencies["lib/json"] = Dependency{Version: "0.3.0"}
+	if err := SaveManifest(dir, loaded); err != nil {
+		t.Fatalf("SaveManifest returned error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	contents := string(data)
+	for _, want := range []string{`include = ["selene.local.toml", "missing.toml"]`, `${SELENE_TEST_ENTRY:-main.selene}`, `source = "https://modules"`, `"lib/json" = { version = "0.3.0" }`} {
+		if !strings.Contains(contents, want) {
+			t.Fatalf("saved manifest missing %s:\n%s", want, contents)
+		}
+	}
+	if strings.Contains(contents, "registry.internal") {
+		t.Fatalf("saved manifest leaked included values:\n%s", contents)
+	}
+}
+
+func TestLoadManifestRejectsUnsetVariablesAndCycles(t *testing.T) {
+	dir := t.TempDir()
+	manifest := "[project]\nname = \"${SELENE_TEST_UNSET_VARIABLE}\"\n"
+	if err := os.WriteFile(filepath.Join(dir, ManifestName), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	if _, err := LoadManifest(dir); err == nil || !strings.Contains(err.Error(), "SELENE_TEST_UNSET_VARIABLE") {
+		t.Fatalf("expected unset variable error, got %v", err)
+	}
+
+	cyclic := t.TempDir()
+	if err := os.WriteFile(filepath.Join(cyclic, ManifestName), []byte("include = [\"a.toml\"]\n"), 0o644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(cyclic, "a.toml"), []byte("include = [\"selene.toml\"]\n"), 0o644); err != nil {
+		t.Fatalf("failed to write include: %v", err)
+	}
+	if _, err := LoadManifest(cyclic); err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Fatalf("expected include cycle error, got %v", err)
+	}
+}
+
+func TestLoadManifestParsesProfiles(t *testing.T) {
+	dir := t.TempDir()
+	manifest := `[project]
+name = "demo"
+
+[profiles.dev]
+backend = "interp"
+
+[profiles.release]
+backend = "vm"
+disassemble = true
+`
+	if err := os.WriteFile(filepath.Join(dir, ManifestName), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	loaded, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatalf("LoadManifest returned error: %v", err)
+	}
+	release, err := loaded.LookupProfile("release")
+	if err != nil {
+		t.Fatalf("LookupProfile returned error: %v", err)
+	}
+	if release.Backend != "vm" || !release.Disassemble {
+		t.Fatalf("unexpected release profile: %+v", release)
+	}
+	if _, err := loaded.LookupProfile("ci"); err == nil || !strings.Contains(err.Error(), "dev, release") {
+		t.Fatalf("expected unknown profile error listing profiles, got %v", err)
+	}
+
+	if err := SaveManifest(dir, loaded); err != nil {
+		t.Fatalf("SaveManifest returned error: %v", err)
+	}
+	reloaded, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatalf("LoadManifest after save returned error: %v", err)
+	}
+	if len(reloaded.Profiles) != 2 || reloaded.Profiles["release"] != release {
+		t.Fatalf("profiles did not round-trip: %+v", reloaded.Profiles)
+	}
+
+	bad := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bad, ManifestName), []byte("[profiles.dev]\nturbo = true\n"), 0o644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	if _, err := LoadManifest(bad); err == nil {
+		t.Fatalf("expected unknown profile option to be rejected")
+	}
+}
+
+func TestLoadManifestParsesProfileContractsAndOptimize(t *testing.T) {
+	dir := t.TempDir()
+	manifest := `[profiles.dev]
+optimize = 0
+
+[profiles.release]
+contracts = false
+optimize = 1
+`
+	if err := os.WriteFile(filepath.Join(dir, ManifestName), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	loaded, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatalf("LoadManifest returned error: %v", err)
+	}
+	dev, release := loaded.Profiles["dev"], loaded.Profiles["release"]
+	if dev.NoContracts || !dev.SetsOptimize || dev.Optimize != 0 {
+		t.Fatalf("unexpected dev profile: %+v", dev)
+	}
+	if !release.NoContracts || !release.SetsOptimize || release.Optimize != 1 {
+		t.Fatalf("unexpected release profile: %+v", release)
+	}
+	if err := SaveManifest(dir, loaded); err != nil {
+		t.Fatalf("SaveManifest returned error: %v", err)
+	}
+	reloaded, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatalf("LoadManifest after save returned error: %v", err)
+	}
+	if reloaded.Profiles["dev"] != dev || reloaded.Profiles["release"] != release {
+		t.Fatalf("profiles did not round-trip: %+v", reloaded.Profiles)
+	}
+
+	bad := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bad, ManifestName), []byte("[profiles.dev]\noptimize = 3\n"), 0o644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	if _, err := LoadManifest(bad); err == nil || !strings.Contains(err.Error(), "optimize must be 0 or 1") {
+		t.Fatalf("expected an out-of-range optimize level to be rejected, got %v", err)
+	}
+}
+
+func TestLoadManifestParsesCapabilities(t *testing.T) {
+	dir := t.TempDir()
+	manifest := `[project]
+name = "demo"
+
+[capabilities]
+fs = true
+"os.exec" = false
+env = false
+`
+	if err := os.WriteFile(filepath.Join(dir, ManifestName), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	loaded, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatalf("LoadManifest returned error: %v", err)
+	}
+	want := map[string]bool{"fs": true, "os.exec": false, "env": false}
+	if !maps.Equal(loaded.Capabilities, want) {
+		t.Fatalf("unexpected capabilities: %+v", loaded.Capabilities)
+	}
+	if err := SaveManifest(dir, loaded); err != nil {
+		t.Fatalf("SaveManifest returned error: %v", err)
+	}
+	reloaded, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatalf("LoadManifest after save returned error: %v", err)
+	}
+	if !maps.Equal(reloaded.Capabilities, want) {
+		t.Fatalf("capabilities did not round-trip: %+v", reloaded.Capabilities)
+	}
+
+	bad := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bad, ManifestName), []byte("[capabilities]\nfs = maybe\n"), 0o644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	if _, err := LoadManifest(bad); err == nil {
+		t.Fatalf("expected a capability that is not true or false to be rejected")
+	}
+}
+
 func TestLockfileSetAndLookup(t *testing.T) {
 	lock := &Lockfile{}
 	lock.Set(LockedDependency{Module: "lib/math", Version: "1.0.0"})
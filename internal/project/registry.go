@@ -0,0 +1,218 @@
+package project
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RegistryPrefix marks a dependency source as a module registry rather than
+// a git repository, as in "registry:https://selene.example.com".
+const RegistryPrefix = "registry:"
+
+// RegistryTokenEnv names the environment variable whose value, when set, is
+// sent to registries as a bearer token.
+const RegistryTokenEnv = "SELENE_REGISTRY_TOKEN"
+
+// A registry serves three files per module version below its base URL, with
+// the module path as the leading path segments:
+//
+//	GET <base>/<module>/@v/list              versions, one per line
+//	GET <base>/<module>/@v/<version>.tar.gz  gzipped tarball of the sources
+//	GET <base>/<module>/@v/<version>.sha256  hex SHA-256 of the tarball
+var registryClient = &http.Client{Timeout: 2 * time.Minute}
+
+// registryURL returns the base URL of a registry source.
+func registryURL(source string) (string, bool) {
+	base, ok := strings.CutPrefix(source, RegistryPrefix)
+	if !ok {
+		return "", false
+	}
+	return strings.TrimSuffix(base, "/"), true
+}
+
+// registryVersions lists the versions a registry publishes for module.
+func registryVersions(base, module string) ([]string, error) {
+	body, err := registryGet(base, module, "list")
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var versions []string
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		if version := strings.TrimSpace(scanner.Text()); version != "" {
+			versions = append(versions, version)
+		}
+	}
+	return versions, scanner.Err()
+}
+
+// fetchRegistrySource downloads and unpacks the tarball of module at version
+// into a temporary directory, after checking it against the checksum the
+// registry publishes.
+func fetchRegistrySource(base, module, version string) (string, func(), error) {
+	if version == "" {
+		return "", nil, fmt.Errorf("%s: a registry dependency needs a version", module)
+	}
+	sumBody, err := registryGet(base, module, version+".sha256")
+	if err != nil {
+		return "", nil, err
+	}
+	sum, err := io.ReadAll(io.LimitReader(sumBody, 1024))
+	sumBody.Close()
+	if err != nil {
+		return "", nil, err
+	}
+	fields := strings.Fields(string(sum))
+	if len(fields) == 0 {
+		return "", nil, fmt.Errorf("registry returned an empty checksum for %s@%s", module, version)
+	}
+	want := strings.ToLower(fields[0])
+
+	tmpDir, err := os.MkdirTemp("", "selene-dep-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() {
+		_ = os.RemoveAll(tmpDir)
+	}
+	archive := filepath.Join(tmpDir, "source.tar.gz")
+	got, err := downloadRegistryFile(base, module, version+".tar.gz", archive)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	if got != want {
+		cleanup()
+		return "", nil, fmt.Errorf("%s@%s: tarball checksum mismatch: registry published %s, downloaded %s", module, version, want, got)
+	}
+	dest := filepath.Join(tmpDir, "repo")
+	if err := extractTarball(archive, dest); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("%s@%s: %w", module, version, err)
+	}
+	return dest, cleanup, nil
+}
+
+// downloadRegistryFile saves a registry file to path and returns its hex
+// SHA-256 digest.
+func downloadRegistryFile(base, module, name, path string) (string, error) {
+	body, err := registryGet(base, module, name)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, hash), body); err != nil {
+		file.Close()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func registryGet(base, module, name string) (io.ReadCloser, error) {
+	segments := strings.Split(module, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	target := base + "/" + strings.Join(segments, "/") + "/@v/" + url.PathEscape(name)
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv(RegistryTokenEnv); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := registryClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return nil, fmt.Errorf("GET %s: %s (set %s to authenticate)", target, resp.Status, RegistryTokenEnv)
+		}
+		return nil, fmt.Errorf("GET %s: %s", target, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// extractTarball unpacks the regular files and directories of a gzipped
+// tarball into dest. Entries that would land outside dest, and links or
+// devices, are rejected.
+func extractTarball(archive, dest string) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	if err := os.MkdirAll(dest, 0o750); err != nil {
+		return err
+	}
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if filepath.IsAbs(header.Name) {
+			return fmt.Errorf("tarball entry %s is an absolute path", header.Name)
+		}
+		target, err := ResolveUnderRoot(dest, filepath.FromSlash(header.Name))
+		if err != nil {
+			return fmt.Errorf("tarball entry %s: %w", header.Name, err)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o750); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+			if err != nil {
+				return err
+			}
+			// #nosec G110 -- the tarball matched the checksum its registry published.
+			if _, err := io.Copy(out, reader); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		case tar.TypeXGlobalHeader:
+		default:
+			return fmt.Errorf("tarball entry %s is not a regular file or directory", header.Name)
+		}
+	}
+}
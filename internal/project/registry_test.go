@@ -0,0 +1,123 @@
+package project
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func tarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, body := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("write tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatalf("write tar entry: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("close gzip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestPrepareDependencyFromRegistry(t *testing.T) {
+	archives := map[string][]byte{
+		"v1.0.0": tarball(t, map[string]string{"lib.selene": "// v1.0.0\n"}),
+		"v1.2.0": tarball(t, map[string]string{"lib.selene": "// v1.2.0\n", "util/strs.selene": "// util\n"}),
+		"v1.3.0": tarball(t, map[string]string{"../escape.selene": "// outside\n"}),
+	}
+	digest := func(data []byte) string {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+	sums := make(map[string]string)
+	for version, data := range archives {
+		sums[version] = digest(data)
+	}
+	sums["v1.3.0"] = sums["v1.0.0"]
+	mux := http.NewServeMux()
+	mux.HandleFunc("/acme.internal/strs/@v/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/acme.internal/strs/@v/")
+		switch {
+		case name == "list":
+			w.Write([]byte("v1.0.0\nv1.2.0\nv2.0.0\n"))
+		case strings.HasSuffix(name, ".tar.gz"):
+			w.Write(archives[strings.TrimSuffix(name, ".tar.gz")])
+		case strings.HasSuffix(name, ".sha256"):
+			w.Write([]byte(sums[strings.TrimSuffix(name, ".sha256")] + "  source.tar.gz\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	module := "acme.internal/strs"
+	source := RegistryPrefix + server.URL
+
+	t.Setenv(RegistryTokenEnv, "")
+	if _, err := ListVersions(module, source); err == nil || !strings.Contains(err.Error(), RegistryTokenEnv) {
+		t.Fatalf("expected an unauthenticated request to mention %s, got %v", RegistryTokenEnv, err)
+	}
+	t.Setenv(RegistryTokenEnv, "s3cret")
+
+	version, err := ResolveVersion(module, Dependency{Version: "^1.0.0", Source: source})
+	if err != nil {
+		t.Fatalf("ResolveVersion returned error: %v", err)
+	}
+	if version != "v1.2.0" {
+		t.Fatalf("expected ^1.0.0 to resolve to v1.2.0, got %s", version)
+	}
+	root := t.TempDir()
+	dep, entry, err := PrepareDependency(root, module, version, source, "")
+	if err != nil {
+		t.Fatalf("PrepareDependency returned error: %v", err)
+	}
+	if dep.Source != source {
+		t.Fatalf("expected the registry source to be recorded, got %s", dep.Source)
+	}
+	data, err := os.ReadFile(filepath.Join(root, entry.Vendor, "util", "strs.selene"))
+	if err != nil || string(data) != "// util\n" {
+		t.Fatalf("expected the tarball to be vendored, got %q (%v)", data, err)
+	}
+
+	lock := &Lockfile{}
+	lock.Set(entry)
+	if err := os.RemoveAll(filepath.Join(root, VendorDirectory)); err != nil {
+		t.Fatalf("remove vendor tree: %v", err)
+	}
+	synced, err := SyncVendor(root, map[string]Dependency{module: dep}, lock)
+	if err != nil || len(synced.Restored) != 1 {
+		t.Fatalf("expected the registry dependency to be restored, got %+v (%v)", synced, err)
+	}
+
+	if _, _, err := PrepareDependency(root, module, "v1.3.0", source, ""); err == nil || !strings.Contains(err.Error(), "tarball checksum mismatch") {
+		t.Fatalf("expected a tampered tarball to be rejected, got %v", err)
+	}
+	sums["v1.3.0"] = digest(archives["v1.3.0"])
+	if _, _, err := PrepareDependency(root, module, "v1.3.0", source, ""); err == nil || !strings.Contains(err.Error(), "escapes root") {
+		t.Fatalf("expected a tarball entry outside the module to be rejected, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, VendorDirectory, "escape.selene")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing to be written outside the module, got %v", err)
+	}
+}
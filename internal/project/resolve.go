@@ -0,0 +1,151 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// dependencySource is the repository a dependency is fetched from.
+func dependencySource(module string, dep Dependency) string {
+	if dep.Source != "" {
+		return dep.Source
+	}
+	return module
+}
+
+// dependencyConstraint parses a manifest version. Versions that are not
+// ranges and not semantic versions, such as branch names or commit hashes,
+// are exact pins and report ok == false.
+func dependencyConstraint(version string) (Constraint, bool, error) {
+	c, err := ParseConstraint(version)
+	switch {
+	case err == nil:
+		return c, true, nil
+	case strings.HasPrefix(version, "^") || strings.HasPrefix(version, "~"):
+		return Constraint{}, false, err
+	default:
+		return Constraint{}, false, nil
+	}
+}
+
+// ResolveVersion returns the version of module to vendor: dep.Version itself
+// when it is exact, or the highest tag of the source within a caret or tilde
+// range.
+func ResolveVersion(module string, dep Dependency) (string, error) {
+	c, ok, err := dependencyConstraint(dep.Version)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", module, err)
+	}
+	if !ok || !c.IsRange() {
+		return dep.Version, nil
+	}
+	available, err := ListVersions(module, dependencySource(module, dep))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", module, err)
+	}
+	best, found := c.Highest(available)
+	if !found {
+		return "", fmt.Errorf("%s: no published version matches %s", module, dep.Version)
+	}
+	return best, nil
+}
+
+// CheckLocked reports an error when the locked version of module no longer
+// satisfies the manifest, for example after the constraint was edited by hand.
+func CheckLocked(module string, dep Dependency, locked LockedDependency) error {
+	c, ok, err := dependencyConstraint(dep.Version)
+	if err != nil {
+		return fmt.Errorf("%s: %w", module, err)
+	}
+	if !ok {
+		if locked.Version != dep.Version {
+			return fmt.Errorf("%s: selene.lock has %s but selene.toml requires %s", module, locked.Version, dep.Version)
+		}
+		return nil
+	}
+	v, err := ParseVersion(locked.Version)
+	if err != nil || !c.Allows(v) {
+		return fmt.Errorf("%s: locked version %s does not satisfy %s", module, locked.Version, dep.Version)
+	}
+	return nil
+}
+
+// UpdateDependency re-resolves module against its source and, when a newer
+// version satisfies the manifest, vendors it and records it in lock. It
+// returns the previously locked version and the version now locked.
+func UpdateDependency(root, module string, dep Dependency, lock *Lockfile) (string, string, error) {
+	previous, hadLock := lock.Lookup(module)
+	resolved, err := ResolveVersion(module, dep)
+	if err != nil {
+		return "", "", err
+	}
+	if hadLock && previous.Version == resolved {
+		return previous.Version, resolved, nil
+	}
+	_, entry, err := PrepareDependency(root, module, resolved, dependencySource(module, dep), "")
+	if err != nil {
+		return "", "", err
+	}
+	lock.Set(entry)
+	if hadLock && previous.Vendor != "" && previous.Vendor != entry.Vendor {
+		stale, err := ResolveUnderRoot(root, previous.Vendor)
+		if err != nil {
+			return "", "", err
+		}
+		if err := os.RemoveAll(stale); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", "", err
+		}
+	}
+	return previous.Version, resolved, nil
+}
+
+// VersionStatus compares a dependency's locked version with what its source
+// offers.
+type VersionStatus struct {
+	Module     string `json:"module"`
+	Constraint string `json:"constraint"`
+	// Current is the locked version, empty when the dependency is not locked.
+	Current string `json:"current,omitempty"`
+	// Wanted is the highest version the manifest constraint allows.
+	Wanted string `json:"wanted,omitempty"`
+	// Latest is the highest release published by the source.
+	Latest string `json:"latest,omitempty"`
+}
+
+// Outdated reports whether a newer version is available, either within the
+// constraint or beyond it.
+func (s VersionStatus) Outdated() bool {
+	return s.Current != s.Wanted || (s.Latest != "" && s.Current != s.Latest)
+}
+
+// CheckVersions reports the version status of every manifest dependency in
+// lexical module order.
+func CheckVersions(manifest *Manifest, lock *Lockfile) ([]VersionStatus, error) {
+	modules := SortedModules(manifest.Dependencies)
+	statuses := make([]VersionStatus, 0, len(modules))
+	for _, module := range modules {
+		dep := manifest.Dependencies[module]
+		status := VersionStatus{Module: module, Constraint: dep.Version, Wanted: dep.Version}
+		if locked, ok := lock.Lookup(module); ok {
+			status.Current = locked.Version
+		}
+		c, ok, err := dependencyConstraint(dep.Version)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", module, err)
+		}
+		if ok {
+			available, err := ListVersions(module, dependencySource(module, dep))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", module, err)
+			}
+			if c.IsRange() {
+				status.Wanted, _ = c.Highest(available)
+			}
+			status.Latest, _ = LatestVersion(available)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
@@ -13,3 +13,14 @@ func ReadFile(root string, elements ...string) ([]byte, error) {
 	// #nosec G304 -- resolved path is guaranteed to stay within the provided root.
 	return os.ReadFile(resolved)
 }
+
+// Open resolves the provided path elements under root like ReadFile and opens
+// the file for reading.
+func Open(root string, elements ...string) (*os.File, error) {
+	resolved, err := ResolveUnderRoot(root, elements...)
+	if err != nil {
+		return nil, err
+	}
+	// #nosec G304 -- resolved path is guaranteed to stay within the provided root.
+	return os.Open(resolved)
+}
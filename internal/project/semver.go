@@ -0,0 +1,171 @@
+package project
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version such as v1.4.2 or 2.0.0-rc.1.
+type Version struct {
+	Major, Minor, Patch int
+	Prerelease          string
+	// Raw is the text the version was parsed from, which is what tags and
+	// vendor directories use.
+	Raw string
+}
+
+// ParseVersion parses MAJOR.MINOR.PATCH with an optional "v" prefix and
+// "-prerelease" suffix. Build metadata after "+" is ignored.
+func ParseVersion(raw string) (Version, error) {
+	text := strings.TrimPrefix(raw, "v")
+	text, _, _ = strings.Cut(text, "+")
+	text, pre, _ := strings.Cut(text, "-")
+	parts := strings.Split(text, ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("invalid version %q: want MAJOR.MINOR.PATCH", raw)
+	}
+	var nums [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (len(part) > 1 && part[0] == '0') {
+			return Version{}, fmt.Errorf("invalid version %q: bad component %q", raw, part)
+		}
+		nums[i] = n
+	}
+	return Version{Major: nums[0], Minor: nums[1], Patch: nums[2], Prerelease: pre, Raw: raw}, nil
+}
+
+// Compare orders versions by precedence. A prerelease sorts before the
+// release it precedes, and prereleases compare their dot-separated
+// identifiers in turn, as in SemVer 2.0.0: numerically when both are
+// numeric, a numeric identifier below an alphanumeric one, and lexically
+// otherwise, with a prefix sorting below the longer list.
+func (v Version) Compare(other Version) int {
+	if c := cmp.Compare(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+	switch {
+	case v.Prerelease == other.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case other.Prerelease == "":
+		return -1
+	}
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+func comparePrerelease(a, b string) int {
+	left, right := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(left) && i < len(right); i++ {
+		x, xErr := strconv.ParseUint(left[i], 10, 64)
+		y, yErr := strconv.ParseUint(right[i], 10, 64)
+		var c int
+		switch {
+		case xErr == nil && yErr == nil:
+			c = cmp.Compare(x, y)
+		case xErr == nil:
+			c = -1
+		case yErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(left[i], right[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(left), len(right))
+}
+
+// Constraint is a manifest version requirement: an exact version, a caret
+// range (^1.2.3 allows >=1.2.3 <2.0.0), or a tilde range (~1.2.3 allows
+// >=1.2.3 <1.3.0). Caret ranges below 1.0.0 only allow changes to the
+// rightmost non-zero component, matching Cargo and npm.
+type Constraint struct {
+	Op   byte // 0 for an exact version, '^', or '~'
+	Base Version
+}
+
+// ParseConstraint parses a manifest version string.
+func ParseConstraint(raw string) (Constraint, error) {
+	text := strings.TrimSpace(raw)
+	var op byte
+	if strings.HasPrefix(text, "^") || strings.HasPrefix(text, "~") {
+		op, text = text[0], text[1:]
+	}
+	base, err := ParseVersion(text)
+	if err != nil {
+		return Constraint{}, err
+	}
+	return Constraint{Op: op, Base: base}, nil
+}
+
+// IsRange reports whether the constraint can match more than one version.
+func (c Constraint) IsRange() bool { return c.Op != 0 }
+
+// Allows reports whether v satisfies the constraint. Ranges never match
+// prereleases unless the range itself names one on the same version.
+func (c Constraint) Allows(v Version) bool {
+	if c.Op == 0 {
+		return v.Compare(c.Base) == 0
+	}
+	if v.Compare(c.Base) < 0 {
+		return false
+	}
+	if v.Prerelease != "" && (v.Major != c.Base.Major || v.Minor != c.Base.Minor || v.Patch != c.Base.Patch) {
+		return false
+	}
+	switch {
+	case c.Op == '~':
+		return v.Major == c.Base.Major && v.Minor == c.Base.Minor
+	case c.Base.Major > 0:
+		return v.Major == c.Base.Major
+	case c.Base.Minor > 0:
+		return v.Major == 0 && v.Minor == c.Base.Minor
+	default:
+		return v.Major == 0 && v.Minor == 0 && v.Patch == c.Base.Patch
+	}
+}
+
+// Highest returns the greatest of available that satisfies the constraint.
+// Entries that are not semantic versions, such as branch names, are skipped.
+func (c Constraint) Highest(available []string) (string, bool) {
+	var best Version
+	found := false
+	for _, raw := range available {
+		v, err := ParseVersion(raw)
+		if err != nil || !c.Allows(v) {
+			continue
+		}
+		if !found || v.Compare(best) > 0 {
+			best, found = v, true
+		}
+	}
+	return best.Raw, found
+}
+
+// LatestVersion returns the greatest release (non-prerelease) version in
+// available.
+func LatestVersion(available []string) (string, bool) {
+	var best Version
+	found := false
+	for _, raw := range available {
+		v, err := ParseVersion(raw)
+		if err != nil || v.Prerelease != "" {
+			continue
+		}
+		if !found || v.Compare(best) > 0 {
+			best, found = v, true
+		}
+	}
+	return best.Raw, found
+}
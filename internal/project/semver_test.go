@@ -0,0 +1,78 @@
+package project
+
+import "testing"
+
+func TestConstraintAllows(t *testing.T) {
+	cases := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"v1.2.3", "v1.2.3", true},
+		{"v1.2.3", "v1.2.4", false},
+		{"^1.2.3", "v1.9.0", true},
+		{"^1.2.3", "v1.2.2", false},
+		{"^1.2.3", "v2.0.0", false},
+		{"^1.2.3", "v1.3.0-beta.1", false},
+		{"^0.2.3", "v0.2.9", true},
+		{"^0.2.3", "v0.3.0", false},
+		{"^0.0.3", "v0.0.4", false},
+		{"~1.2.3", "v1.2.9", true},
+		{"~1.2.3", "v1.3.0", false},
+		{"^v1.2.0-rc.1", "v1.2.0-rc.2", true},
+	}
+	for _, tc := range cases {
+		c, err := ParseConstraint(tc.constraint)
+		if err != nil {
+			t.Fatalf("ParseConstraint(%q) returned error: %v", tc.constraint, err)
+		}
+		v, err := ParseVersion(tc.version)
+		if err != nil {
+			t.Fatalf("ParseVersion(%q) returned error: %v", tc.version, err)
+		}
+		if got := c.Allows(v); got != tc.want {
+			t.Fatalf("%s allows %s = %v, want %v", tc.constraint, tc.version, got, tc.want)
+		}
+	}
+}
+
+func TestConstraintHighestSkipsNonVersions(t *testing.T) {
+	available := []string{"v1.0.0", "main", "v1.4.0", "v1.10.1", "v2.0.0", "v1.11.0-rc.1"}
+	c, err := ParseConstraint("^1.0.0")
+	if err != nil {
+		t.Fatalf("ParseConstraint returned error: %v", err)
+	}
+	if got, ok := c.Highest(available); !ok || got != "v1.10.1" {
+		t.Fatalf("Highest = %q, %v; want v1.10.1", got, ok)
+	}
+	if got, ok := LatestVersion(available); !ok || got != "v2.0.0" {
+		t.Fatalf("LatestVersion = %q, %v; want v2.0.0", got, ok)
+	}
+	if _, err := ParseVersion("v1.02.0"); err == nil {
+		t.Fatalf("expected leading zeros to be rejected")
+	}
+}
+
+func TestVersionComparePrereleaseIdentifiers(t *testing.T) {
+	ordered := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0-rc.2", "1.0.0-rc.10", "1.0.0"}
+	for i := 1; i < len(ordered); i++ {
+		lower, err := ParseVersion(ordered[i-1])
+		if err != nil {
+			t.Fatalf("ParseVersion(%q) returned error: %v", ordered[i-1], err)
+		}
+		higher, err := ParseVersion(ordered[i])
+		if err != nil {
+			t.Fatalf("ParseVersion(%q) returned error: %v", ordered[i], err)
+		}
+		if lower.Compare(higher) >= 0 || higher.Compare(lower) <= 0 {
+			t.Fatalf("expected %s to sort below %s", ordered[i-1], ordered[i])
+		}
+	}
+	c, err := ParseConstraint("^1.0.0-rc.1")
+	if err != nil {
+		t.Fatalf("ParseConstraint returned error: %v", err)
+	}
+	if got, ok := c.Highest([]string{"v1.0.0-rc.2", "v1.0.0-rc.10", "v1.0.0-rc.9"}); !ok || got != "v1.0.0-rc.10" {
+		t.Fatalf("Highest = %q, %v; want v1.0.0-rc.10", got, ok)
+	}
+}
@@ -0,0 +1,129 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VendorSync lists what SyncVendor did, with vendor paths relative to the
+// root and forward slashes.
+type VendorSync struct {
+	// Verified are the dependencies whose vendored copy already matched
+	// selene.lock.
+	Verified []string `json:"verified"`
+	// Restored are the dependencies fetched again because their copy was
+	// missing or did not match its checksum.
+	Restored []string `json:"restored"`
+	// Removed are the paths under vendor/ that no locked dependency owns.
+	Removed []string `json:"removed"`
+}
+
+// SyncVendor makes the vendor tree under root hold exactly the dependencies
+// in deps at the versions lock pins. A dependency whose copy is missing or
+// fails its checksum is fetched from its source again and must then match
+// the recorded checksum; anything else under vendor/ is deleted. Lockfile
+// entries are never changed, so a fresh clone rebuilds the exact set that
+// was locked.
+func SyncVendor(root string, deps map[string]Dependency, lock *Lockfile) (VendorSync, error) {
+	var result VendorSync
+	vendorRoot, err := ResolveUnderRoot(root, VendorDirectory)
+	if err != nil {
+		return result, err
+	}
+	owned := make(map[string]bool)
+	for _, module := range SortedModules(deps) {
+		dep := deps[module]
+		locked, ok := lock.Lookup(module)
+		if !ok {
+			return result, fmt.Errorf("dependency %s is missing from selene.lock (run selene deps update)", module)
+		}
+		if err := CheckLocked(module, dep, locked); err != nil {
+			return result, fmt.Errorf("%w (run selene deps update)", err)
+		}
+		vendorPath, err := ResolveUnderRoot(root, locked.Vendor)
+		if err != nil {
+			return result, err
+		}
+		if rel, err := filepath.Rel(vendorRoot, vendorPath); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return result, fmt.Errorf("%s: vendor path %s is not inside %s", module, locked.Vendor, VendorDirectory)
+		}
+		owned[vendorPath] = true
+		if VerifyChecksum(vendorPath, locked.Checksum) == nil {
+			result.Verified = append(result.Verified, module)
+			continue
+		}
+		if err := restoreVendored(module, dep, locked, vendorPath); err != nil {
+			return result, err
+		}
+		result.Restored = append(result.Restored, module)
+	}
+	removed, err := removeStrayVendored(root, vendorRoot, owned)
+	result.Removed = removed
+	return result, err
+}
+
+func restoreVendored(module string, dep Dependency, locked LockedDependency, vendorPath string) error {
+	src, cleanup, err := fetchDependencySource(module, locked.Version, dependencySource(module, dep))
+	if err != nil {
+		return fmt.Errorf("%s: %w", module, err)
+	}
+	defer cleanup()
+	if err := CopyIntoVendor(src, vendorPath); err != nil {
+		return fmt.Errorf("%s: %w", module, err)
+	}
+	if err := VerifyChecksum(vendorPath, locked.Checksum); err != nil {
+		_ = os.RemoveAll(vendorPath)
+		return fmt.Errorf("%s@%s: fetched sources do not match selene.lock: %w", module, locked.Version, err)
+	}
+	return nil
+}
+
+// removeStrayVendored deletes everything under vendorRoot that is neither an
+// owned vendor directory nor one of their parents.
+func removeStrayVendored(root, vendorRoot string, owned map[string]bool) ([]string, error) {
+	var removed []string
+	err := filepath.WalkDir(vendorRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == vendorRoot {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if path == vendorRoot {
+			return nil
+		}
+		if owned[path] {
+			return fs.SkipDir
+		}
+		if d.IsDir() && holdsOwned(owned, path) {
+			return nil
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		removed = append(removed, filepath.ToSlash(rel))
+		if d.IsDir() {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	return removed, err
+}
+
+func holdsOwned(owned map[string]bool, dir string) bool {
+	prefix := dir + string(filepath.Separator)
+	for path := range owned {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Workspace is a set of packages that share the selene.lock and vendor
+// directory of the workspace root. The root manifest lists its members in a
+// [workspace] section; the root is itself a member when it declares a
+// [project].
+type Workspace struct {
+	Root    string
+	Members []Member
+}
+
+// Member is one package of a workspace.
+type Member struct {
+	// Dir is the absolute directory holding the member's selene.toml.
+	Dir string
+	// Path is Dir relative to the workspace root, with forward slashes, or
+	// "." for the root package.
+	Path     string
+	Manifest *Manifest
+}
+
+// LoadWorkspace loads the manifest at root and the manifests of its
+// workspace members. Member entries are directories relative to root and may
+// be glob patterns such as "packages/*"; matches without a selene.toml are
+// skipped, while a named directory without one is an error. A manifest with
+// no [workspace] section yields a workspace whose only member is root.
+func LoadWorkspace(root string) (*Workspace, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := LoadManifest(absRoot)
+	if err != nil {
+		return nil, err
+	}
+	ws := &Workspace{Root: absRoot}
+	if len(manifest.Workspace.Members) == 0 || manifest.Project.Name != "" || manifest.Project.Entry != "" {
+		ws.Members = append(ws.Members, Member{Dir: absRoot, Path: ".", Manifest: manifest})
+	}
+	seen := map[string]bool{absRoot: true}
+	for _, pattern := range manifest.Workspace.Members {
+		dirs, err := memberDirs(absRoot, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range dirs {
+			if seen[dir] {
+				continue
+			}
+			seen[dir] = true
+			member, err := LoadManifest(dir)
+			if err != nil {
+				return nil, fmt.Errorf("workspace member %s: %w", pattern, err)
+			}
+			rel, err := filepath.Rel(absRoot, dir)
+			if err != nil {
+				return nil, err
+			}
+			if len(member.Workspace.Members) > 0 {
+				return nil, fmt.Errorf("workspace member %s declares its own [workspace]", filepath.ToSlash(rel))
+			}
+			ws.Members = append(ws.Members, Member{Dir: dir, Path: filepath.ToSlash(rel), Manifest: member})
+		}
+	}
+	slices.SortFunc(ws.Members, func(a, b Member) int { return strings.Compare(a.Path, b.Path) })
+	return ws, nil
+}
+
+func memberDirs(root, pattern string) ([]string, error) {
+	if !strings.ContainsAny(pattern, "*?[") {
+		dir, err := ResolveUnderRoot(root, filepath.FromSlash(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("workspace member %s: %w", pattern, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, ManifestName)); err != nil {
+			return nil, fmt.Errorf("workspace member %s: %w", pattern, err)
+		}
+		return []string{dir}, nil
+	}
+	matches, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(pattern)))
+	if err != nil {
+		return nil, fmt.Errorf("workspace member %s: %w", pattern, err)
+	}
+	dirs := make([]string, 0, len(matches))
+	for _, match := range matches {
+		rel, err := filepath.Rel(root, match)
+		if err != nil {
+			return nil, err
+		}
+		dir, err := ResolveUnderRoot(root, rel)
+		if err != nil {
+			return nil, fmt.Errorf("workspace member %s: %w", pattern, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, ManifestName)); err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
+// FindWorkspaceRoot locates the nearest package from start, as FindRoot
+// does, then returns the root of the workspace that lists it as a member.
+// A package outside any workspace is its own root. The dependency lockfile
+// and vendor directory live at the returned root.
+func FindWorkspaceRoot(start string) (string, error) {
+	pkg, err := FindRoot(start)
+	if err != nil {
+		return "", err
+	}
+	pkg, err = filepath.Abs(pkg)
+	if err != nil {
+		return "", err
+	}
+	dir := pkg
+	for {
+		candidate, err := FindRoot(dir)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return pkg, nil
+			}
+			return "", err
+		}
+		manifest, err := LoadManifest(candidate)
+		if err != nil {
+			return "", err
+		}
+		if len(manifest.Workspace.Members) > 0 {
+			if candidate == pkg {
+				return pkg, nil
+			}
+			ws, err := LoadWorkspace(candidate)
+			if err != nil {
+				return "", err
+			}
+			if ws.Member(pkg) != nil {
+				return ws.Root, nil
+			}
+		}
+		parent := filepath.Dir(candidate)
+		if parent == candidate {
+			return pkg, nil
+		}
+		dir = parent
+	}
+}
+
+// Member returns the member whose directory is dir, or nil.
+func (w *Workspace) Member(dir string) *Member {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	for i := range w.Members {
+		if w.Members[i].Dir == abs {
+			return &w.Members[i]
+		}
+	}
+	return nil
+}
+
+// Dependencies merges the requirements of every member. Members sharing a
+// lockfile must agree on each module's version and source.
+func (w *Workspace) Dependencies() (map[string]Dependency, error) {
+	deps := make(map[string]Dependency)
+	owners := make(map[string]string)
+	for _, member := range w.Members {
+		for _, module := range SortedModules(member.Manifest.Dependencies) {
+			dep := member.Manifest.Dependencies[module]
+			if existing, ok := deps[module]; ok && existing != dep {
+				return nil, fmt.Errorf("workspace members %s and %s require different versions of %s (%s and %s)",
+					owners[module], member.Path, module, existing.Version, dep.Version)
+			}
+			deps[module] = dep
+			owners[module] = member.Path
+		}
+	}
+	return deps, nil
+}
+
+// SourceFiles lists the .selene files of every member, sorted and without
+// duplicates. Vendored dependencies and hidden directories are skipped.
+func (w *Workspace) SourceFiles() ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	for _, member := range w.Members {
+		err := filepath.WalkDir(member.Dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != member.Dir && (d.Name() == VendorDirectory || strings.HasPrefix(d.Name(), ".")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(path) == ".selene" && !seen[path] {
+				seen[path] = true
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
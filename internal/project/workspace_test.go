@@ -0,0 +1,78 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeWorkspaceFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestLoadWorkspaceResolvesMembers(t *testing.T) {
+	root := t.TempDir()
+	writeWorkspaceFile(t, filepath.Join(root, ManifestName), "[workspace]\nmembers = [\"packages/*\", \"tools/cli\"]\n")
+	writeWorkspaceFile(t, filepath.Join(root, "packages", "core", ManifestName), "[project]\nname = \"core\"\n\n[dependencies]\n\"lib/log\" = { version = \"^1.2.0\" }\n")
+	writeWorkspaceFile(t, filepath.Join(root, "packages", "web", ManifestName), "[project]\nname = \"web\"\n\n[dependencies]\n\"lib/log\" = { version = \"^1.2.0\" }\n")
+	writeWorkspaceFile(t, filepath.Join(root, "packages", "notes", "README.md"), "not a package\n")
+	writeWorkspaceFile(t, filepath.Join(root, "tools", "cli", ManifestName), "[project]\nname = \"cli\"\n")
+	writeWorkspaceFile(t, filepath.Join(root, "tools", "cli", "main.selene"), "print(1);\n")
+	writeWorkspaceFile(t, filepath.Join(root, "tools", "cli", VendorDirectory, "dep", "dep.selene"), "print(2);\n")
+
+	ws, err := LoadWorkspace(root)
+	if err != nil {
+		t.Fatalf("LoadWorkspace returned error: %v", err)
+	}
+	var paths []string
+	for _, member := range ws.Members {
+		paths = append(paths, member.Path)
+	}
+	if got := strings.Join(paths, ","); got != "packages/core,packages/web,tools/cli" {
+		t.Fatalf("unexpected members %s", got)
+	}
+	deps, err := ws.Dependencies()
+	if err != nil || len(deps) != 1 || deps["lib/log"].Version != "^1.2.0" {
+		t.Fatalf("unexpected merged dependencies %v (%v)", deps, err)
+	}
+	files, err := ws.SourceFiles()
+	if err != nil || len(files) != 1 || files[0] != filepath.Join(root, "tools", "cli", "main.selene") {
+		t.Fatalf("unexpected source files %v (%v)", files, err)
+	}
+
+	for _, start := range []string{filepath.Join(root, "tools", "cli"), filepath.Join(root, "packages", "web"), root} {
+		got, err := FindWorkspaceRoot(start)
+		if err != nil || got != root {
+			t.Fatalf("FindWorkspaceRoot(%s) = %s (%v), want %s", start, got, err, root)
+		}
+	}
+	outside := filepath.Join(root, "packages", "notes", "extra")
+	writeWorkspaceFile(t, filepath.Join(outside, ManifestName), "[project]\nname = \"extra\"\n")
+	if got, err := FindWorkspaceRoot(outside); err != nil || got != outside {
+		t.Fatalf("expected a non-member package to be its own root, got %s (%v)", got, err)
+	}
+
+	writeWorkspaceFile(t, filepath.Join(root, "packages", "web", ManifestName), "[project]\nname = \"web\"\n\n[dependencies]\n\"lib/log\" = { version = \"^2.0.0\" }\n")
+	ws, err = LoadWorkspace(root)
+	if err != nil {
+		t.Fatalf("LoadWorkspace returned error: %v", err)
+	}
+	if _, err := ws.Dependencies(); err == nil || !strings.Contains(err.Error(), "require different versions of lib/log") {
+		t.Fatalf("expected conflicting requirements to be rejected, got %v", err)
+	}
+}
+
+func TestLoadWorkspaceRejectsMissingMember(t *testing.T) {
+	root := t.TempDir()
+	writeWorkspaceFile(t, filepath.Join(root, ManifestName), "[workspace]\nmembers = [\"missing\"]\n")
+	if _, err := LoadWorkspace(root); err == nil || !strings.Contains(err.Error(), "workspace member missing") {
+		t.Fatalf("expected missing member error, got %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/token"
+)
+
+// place is a location an assignment can store into: a binding, the target
+// of a pointer, a property, or an array element.
+type place struct {
+	get func() (Value, error)
+	set func(Value) error
+}
+
+// evalAssignment evaluates target op= value. The target's operands are
+// evaluated first, then the value, and only then is the target read.
+func evalAssignment(node *ast.AssignmentExpression, env *Environment) (Value, error) {
+	var update func(current, value Value) (Value, error)
+	if node.Operator != token.ASSIGN {
+		update = func(current, value Value) (Value, error) {
+			return applyAugmentedAssignment(node.Operator, current, value, env)
+		}
+	}
+	return storeInto(node.Target, env, func() (Value, error) { return evalExpression(node.Value, env) }, update)
+}
+
+// evalIncrement evaluates target++ or target--, which store one more or one
+// less than the target's Number and yield the Number it held before.
+func evalIncrement(node *ast.IncrementExpression, env *Environment) (Value, error) {
+	op := token.PLUS_ASSIGN
+	if node.Operator == "--" {
+		op = token.MINUS_ASSIGN
+	}
+	var old Value
+	_, err := storeInto(node.Target, env, func() (Value, error) { return NewNumber(1), nil }, func(current, one Value) (Value, error) {
+		if _, ok := current.(*Number); !ok {
+			return nil, fmt.Errorf("operator %s requires a Number, got %s", node.Operator, current.Type())
+		}
+		old = current
+		return applyAugmentedAssignment(op, current, one, env)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return old, nil
+}
+
+// storeInto evaluates value and stores it, or update's combination of the
+// target's current value with it, into target, returning what was stored.
+func storeInto(target ast.Expression, env *Environment, value func() (Value, error), update func(current, value Value) (Value, error)) (Value, error) {
+	dest, err := evalPlace(target, env)
+	if err != nil {
+		return nil, err
+	}
+	result, err := value()
+	if err != nil {
+		return nil, err
+	}
+	if update != nil {
+		current, err := dest.get()
+		if err != nil {
+			return nil, err
+		}
+		if result, err = update(current, result); err != nil {
+			return nil, err
+		}
+	}
+	if err := dest.set(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// evalPlace evaluates the operands of an assignment target. Arrays and
+// Objects are values, so storing into an element or property of one stores
+// a copy with that element or property replaced into the place the Array or
+// Object came from: a[i] = v rebinds a, and other references to the old
+// Array are unaffected. Class instances are changed in place.
+func evalPlace(target ast.Expression, env *Environment) (place, error) {
+	switch target := target.(type) {
+	case *ast.Identifier:
+		return place{
+			get: func() (Value, error) {
+				current, ok := env.getIdentifier(target)
+				if !ok {
+					return nil, fmt.Errorf("undefined variable %s", target.Name)
+				}
+				return current, nil
+			},
+			set: func(val Value) error {
+				_, err := env.assignIdentifier(target, val)
+				return err
+			},
+		}, nil
+	case *ast.PrefixExpression:
+		if target.Operator != "*" {
+			return place{}, errors.New("unsupported assignment target")
+		}
+		ptrRef, err := evalExpression(target.Right, env)
+		if err != nil {
+			return place{}, err
+		}
+		pointer, ok := ptrRef.(*Pointer)
+		if !ok {
+			return place{}, errors.New("assignment target is not a pointer")
+		}
+		return place{get: pointer.get, set: pointer.set}, nil
+	case *ast.MemberExpression:
+		if target.Optional {
+			return place{}, errors.New("cannot assign through ?.")
+		}
+		object, replace, err := evalContainer(target.Object, env)
+		if err != nil {
+			return place{}, err
+		}
+		return memberPlace(object, target.Property, replace), nil
+	case *ast.IndexExpression:
+		if target.Optional {
+			return place{}, errors.New("cannot assign through ?[")
+		}
+		collection, replace, err := evalContainer(target.Collection, env)
+		if err != nil {
+			return place{}, err
+		}
+		index, err := evalExpression(target.Index, env)
+		if err != nil {
+			return place{}, err
+		}
+		return place{
+			get: func() (Value, error) { return evalIndexExpression(collection, index) },
+			set: func(val Value) error {
+				array, ok := collection.(*Array)
+				if !ok {
+					return fmt.Errorf("cannot assign to an index of %s", collection.Type())
+				}
+				if _, err := evalIndexExpression(array, index); err != nil {
+					return err
+				}
+				if array.frozen {
+					return errors.New("cannot assign to an element of a frozen Array")
+				}
+				if replace == nil {
+					return errors.New("cannot assign to an element of a temporary Array")
+				}
+				elements := slices.Clone(array.Elements)
+				elements[int(index.(*Number).Value)] = val
+				return replace(&Array{Elements: elements})
+			},
+		}, nil
+	default:
+		return place{}, errors.New("unsupported assignment target")
+	}
+}
+
+// evalContainer evaluates the value an element or property is assigned in.
+// When expr is itself assignable, replace stores a changed copy of the value
+// back into it; otherwise replace is nil.
+func evalContainer(expr ast.Expression, env *Environment) (Value, func(Value) error, error) {
+	if !assignable(expr) {
+		val, err := evalExpression(expr, env)
+		return val, nil, err
+	}
+	dest, err := evalPlace(expr, env)
+	if err != nil {
+		return nil, nil, err
+	}
+	val, err := dest.get()
+	if err != nil {
+		return nil, nil, err
+	}
+	return val, dest.set, nil
+}
+
+func assignable(expr ast.Expression) bool {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		return true
+	case *ast.IndexExpression:
+		return !expr.Optional
+	case *ast.MemberExpression:
+		return !expr.Optional
+	case *ast.PrefixExpression:
+		return expr.Operator == "*"
+	}
+	return false
+}
+
+// memberPlace is the place for property of object: a field or accessor of a
+// class instance, a static of a class, or a property of an Object. Other
+// values can be read through it but not assigned.
+func memberPlace(object Value, property string, replace func(Value) error) place {
+	get := func() (Value, error) { return evalMemberExpression(object, property, false) }
+	switch obj := object.(type) {
+	case *ClassInstance:
+		return place{get: get, set: func(val Value) error { return setInstanceProperty(obj, property, val) }}
+	case *ClassType:
+		return place{get: get, set: func(val Value) error {
+			owner := obj.staticOwner(property)
+			if owner == nil || owner.body == nil || !owner.body.assignLocal(property, val) {
+				return fmt.Errorf("%s has no static %s", obj.Name, property)
+			}
+			return nil
+		}}
+	case *Object:
+		return place{get: get, set: func(val Value) error {
+			if obj.frozen {
+				return fmt.Errorf("cannot assign to property %s of a frozen Object", property)
+			}
+			if replace == nil {
+				return fmt.Errorf("cannot assign to property %s of a temporary Object", property)
+			}
+			props := make([]Property, 0, len(obj.Properties)+1)
+			for _, key := range obj.Keys() {
+				props = append(props, Property{Key: key, Value: obj.Properties[key]})
+			}
+			return replace(NewObject(append(props, Property{Key: property, Value: val})...))
+		}}
+	default:
+		return place{get: get, set: func(Value) error {
+			return fmt.Errorf("cannot assign to property %s of %s", property, object.Type())
+		}}
+	}
+}
@@ -0,0 +1,129 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/cybellereaper/selenelang/internal/token"
+)
+
+// AuditEntry records one host-affecting builtin call.
+type AuditEntry struct {
+	// Time is read from the runtime's Clock, so hermetic runs log
+	// reproducible timestamps.
+	Time time.Time `json:"time"`
+	// Op is the builtin, such as "fs.write" or "os.exec".
+	Op   string   `json:"op"`
+	Args []string `json:"args"`
+	// Site is the line and column of the call, or "<unknown>" when the
+	// builtin was called indirectly.
+	Site string `json:"site"`
+	// Error is set when the call failed or the sandbox or policy refused it.
+	Error string `json:"error,omitempty"`
+}
+
+// AuditLog collects AuditEntry values for an embedder and optionally streams
+// them as JSON lines.
+type AuditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+	enc     *json.Encoder
+	err     error
+}
+
+// NewAuditLog returns an audit log that also writes each entry to w as a
+// line of JSON. w may be nil to keep entries in memory only.
+func NewAuditLog(w io.Writer) *AuditLog {
+	log := &AuditLog{}
+	if w != nil {
+		log.enc = json.NewEncoder(w)
+	}
+	return log
+}
+
+// Entries returns the recorded entries in call order.
+func (l *AuditLog) Entries() []AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]AuditEntry(nil), l.entries...)
+}
+
+// Err reports the first error encountered while writing entries.
+func (l *AuditLog) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+func (l *AuditLog) record(entry AuditEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+	if l.enc != nil && l.err == nil {
+		l.err = l.enc.Encode(entry)
+	}
+}
+
+// SetAuditLog records every host-affecting fs and os builtin call the program
+// makes, and every path.glob, including calls the sandbox, policy, or a
+// denied capability refuses.
+// Pass nil to stop auditing.
+func (r *Runtime) SetAuditLog(log *AuditLog) {
+	r.audit = log
+}
+
+// auditedOps lists the module members that touch the host.
+var auditedOps = map[string][]string{
+	"fs":   {"read", "readBytes", "write", "exists", "tempFile", "tempDir"},
+	"os":   {"env", "setenv", "exec", "cwd", "chdir", "exit"},
+	"path": {"glob"},
+}
+
+// installAudit wraps the host-affecting members of the builtin modules so
+// each call is reported to r.audit when one is set, and refused when the
+// policy denies the capability that covers it.
+func (r *Runtime) installAudit() {
+	for name, ops := range auditedOps {
+		mod, ok := r.env.store[name].(*Module)
+		if !ok {
+			continue
+		}
+		for _, op := range ops {
+			fn, ok := mod.Exports[op].(*Function)
+			if !ok {
+				continue
+			}
+			mod.Exports[op] = r.audited(name+"."+op, fn)
+		}
+	}
+}
+
+func (r *Runtime) audited(op string, fn *Function) Value {
+	return newSitedBuiltin(fn.Name, func(site token.Position, args []Value) (Value, error) {
+		call := fn.Builtin
+		if fn.sited != nil {
+			call = func(args []Value) (Value, error) { return fn.sited(site, args) }
+		}
+		if err := r.checkCapability(op); err != nil {
+			call = func([]Value) (Value, error) { return nil, err }
+		}
+		log := r.audit
+		if log == nil {
+			return call(args)
+		}
+		entry := AuditEntry{Time: r.clock.Now(), Op: op, Args: make([]string, len(args)), Site: formatSite(site)}
+		for i, arg := range args {
+			entry.Args[i] = arg.Inspect()
+		}
+		val, err := call(args)
+		var exit *ExitError
+		if err != nil && !errors.As(err, &exit) {
+			entry.Error = err.Error()
+		}
+		log.record(entry)
+		return val, err
+	})
+}
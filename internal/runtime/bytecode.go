@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/consteval"
 )
 
 // OpCode represents a single virtual machine instruction.
@@ -24,6 +25,9 @@ const (
 type Chunk struct {
 	code  []byte
 	items []ast.ProgramItem
+	// decoded marks chunks restored by UnmarshalBinary, which RunChunk
+	// verifies before running.
+	decoded bool
 }
 
 // Instructions returns the raw bytecode instructions.
@@ -47,34 +51,72 @@ func (c *Chunk) ProgramItem(index int) (ast.ProgramItem, bool) {
 // Disassemble renders a human-readable listing of the chunk.
 func (c *Chunk) Disassemble() string {
 	var b strings.Builder
-	ip := 0
-	for ip < len(c.code) {
-		op := OpCode(c.code[ip])
-		switch op {
-		case OpEvalItem:
-			if ip+2 >= len(c.code) {
-				fmt.Fprintf(&b, "%04d ERROR truncated OpEvalItem\n", ip)
-				return b.String()
-			}
-			index := int(binary.BigEndian.Uint16(c.code[ip+1 : ip+3]))
-			fmt.Fprintf(&b, "%04d OpEvalItem %d", ip, index)
-			if item, ok := c.ProgramItem(index); ok {
-				fmt.Fprintf(&b, " (%T)\n", item)
-			} else {
-				b.WriteString(" <missing>\n")
-			}
-			ip += 3
-		case OpReturn:
-			fmt.Fprintf(&b, "%04d OpReturn\n", ip)
-			ip++
-		default:
-			fmt.Fprintf(&b, "%04d UNKNOWN %d\n", ip, op)
-			ip++
+	for ip := 0; ip < len(c.code); {
+		text, next := c.Instruction(ip)
+		b.WriteString(text)
+		b.WriteByte('\n')
+		if next <= ip {
+			break
 		}
+		ip = next
 	}
 	return b.String()
 }
 
+// Instruction renders the instruction at ip the way Disassemble lists it and
+// returns the offset of the one after it, or ip itself when the chunk ends
+// in a truncated instruction.
+func (c *Chunk) Instruction(ip int) (string, int) {
+	switch op := OpCode(c.code[ip]); op {
+	case OpEvalItem:
+		if ip+2 >= len(c.code) {
+			return fmt.Sprintf("%04d ERROR truncated OpEvalItem", ip), ip
+		}
+		index := int(binary.BigEndian.Uint16(c.code[ip+1 : ip+3]))
+		if item, ok := c.ProgramItem(index); ok {
+			return fmt.Sprintf("%04d OpEvalItem %d (%T)", ip, index, item), ip + 3
+		}
+		return fmt.Sprintf("%04d OpEvalItem %d <missing>", ip, index), ip + 3
+	case OpReturn:
+		return fmt.Sprintf("%04d OpReturn", ip), ip + 1
+	default:
+		return fmt.Sprintf("%04d UNKNOWN %d", ip, op), ip + 1
+	}
+}
+
+// Line reports the source line of the program item the instruction at ip
+// evaluates, or 0 for instructions without one.
+func (c *Chunk) Line(ip int) int {
+	if OpCode(c.code[ip]) != OpEvalItem || ip+2 >= len(c.code) {
+		return 0
+	}
+	item, ok := c.ProgramItem(int(binary.BigEndian.Uint16(c.code[ip+1 : ip+3])))
+	if !ok {
+		return 0
+	}
+	return item.Pos().Line
+}
+
+// VMStep describes the instruction the VM is about to execute.
+type VMStep struct {
+	// IP is the offset of the instruction in the chunk.
+	IP int
+	// Instruction is the instruction as Disassemble lists it.
+	Instruction string
+	// Line is the source line of the instruction, or 0 when it has none.
+	Line int
+	// Stack holds the values on the VM's stack, bottom first. The VM keeps
+	// a single value there: the result of the last program item.
+	Stack []Value
+}
+
+// SetVMHook installs a function RunChunk calls before every instruction it
+// executes. An error from hook stops the run and RunChunk returns it. Call
+// it before running code.
+func (r *Runtime) SetVMHook(hook func(VMStep) error) {
+	r.vmHook = hook
+}
+
 func (c *Chunk) addItem(item ast.ProgramItem) int {
 	c.items = append(c.items, item)
 	return len(c.items) - 1
@@ -116,15 +158,45 @@ func (c *compiler) emitEvalItem(item ast.ProgramItem) error {
 	return nil
 }
 
+// SetOptimizationLevel sets how much Compile optimizes: at level 0 programs
+// are compiled as written, and at level 1, the default, constant
+// expressions are folded into literals first.
+func (r *Runtime) SetOptimizationLevel(level int) error {
+	if level != 0 && level != 1 {
+		return fmt.Errorf("optimization level must be 0 or 1, got %d", level)
+	}
+	r.noFolding = level == 0
+	return nil
+}
+
+// OptimizationLevel returns the level set with SetOptimizationLevel.
+func (r *Runtime) OptimizationLevel() int {
+	if r.noFolding {
+		return 0
+	}
+	return 1
+}
+
 // Compile converts a parsed program into bytecode that can be executed by the Selene VM.
+// Unless the optimization level is 0, constant expressions are folded into
+// literals first, which rewrites program in place.
 func (r *Runtime) Compile(program *ast.Program) (*Chunk, error) {
+	if !r.noFolding {
+		consteval.Fold(program)
+	}
 	comp := newCompiler()
 	return comp.compile(program)
 }
 
 // RunChunk executes compiled bytecode within the runtime's environment.
+// Chunks restored with UnmarshalBinary are checked with VerifyChunk first.
 func (r *Runtime) RunChunk(chunk *Chunk) (Value, error) {
-	vm := &vm{chunk: chunk, env: r.env}
+	if chunk.decoded {
+		if err := r.VerifyChunk(chunk); err != nil {
+			return nil, err
+		}
+	}
+	vm := &vm{chunk: chunk, env: r.env, hook: r.vmHook}
 	result, err := vm.run()
 	if err != nil {
 		return nil, err
@@ -138,15 +210,26 @@ type vm struct {
 	chunk *Chunk
 	env   *Environment
 	ip    int
+	hook  func(VMStep) error
 }
 
 func (v *vm) run() (Value, error) {
 	var last Value = NullValue
 	for v.ip < len(v.chunk.code) {
+		if v.hook != nil {
+			text, _ := v.chunk.Instruction(v.ip)
+			step := VMStep{IP: v.ip, Instruction: text, Line: v.chunk.Line(v.ip), Stack: []Value{last}}
+			if err := v.hook(step); err != nil {
+				return nil, err
+			}
+		}
 		op := OpCode(v.chunk.code[v.ip])
 		v.ip++
 		switch op {
 		case OpEvalItem:
+			if err := v.env.Err(); err != nil {
+				return nil, err
+			}
 			if v.ip+1 >= len(v.chunk.code) {
 				return nil, fmt.Errorf("truncated OpEvalItem at %d", v.ip-1)
 			}
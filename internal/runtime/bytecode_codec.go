@@ -0,0 +1,88 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+)
+
+// CompilerVersion identifies the bytecode layout produced by Compile. It is
+// part of every cache key so chunks written by an older toolchain are ignored
+// rather than misinterpreted.
+const CompilerVersion = "selene-bytecode/4"
+
+var registerNodesOnce sync.Once
+
+// registerNodes teaches gob about every concrete AST node that can appear
+// behind the ast.Statement, ast.Expression, ast.Pattern, and ast.ProgramItem
+// interfaces referenced by a chunk.
+func registerNodes() {
+	registerNodesOnce.Do(func() {
+		for _, node := range []any{
+			&ast.Identifier{}, &ast.NumberLiteral{}, &ast.StringLiteral{}, &ast.BooleanLiteral{},
+			&ast.NullLiteral{}, &ast.ArrayLiteral{}, &ast.SetLiteral{}, &ast.ObjectLiteral{}, &ast.AwaitExpression{}, &ast.OldExpression{},
+			&ast.PrefixExpression{}, &ast.InfixExpression{}, &ast.AssignmentExpression{},
+			&ast.ElvisExpression{}, &ast.CallExpression{}, &ast.IndexExpression{},
+			&ast.MemberExpression{}, &ast.NonNullAssertion{}, &ast.PropagateExpression{}, &ast.IncrementExpression{},
+			&ast.YieldExpression{}, &ast.BlockStatement{},
+			&ast.ExpressionStatement{}, &ast.IfStatement{}, &ast.WhileStatement{}, &ast.DoWhileStatement{},
+			&ast.ForStatement{}, &ast.ForInStatement{}, &ast.ReturnStatement{}, &ast.BreakStatement{},
+			&ast.ContinueStatement{}, &ast.RethrowStatement{}, &ast.ThrowStatement{}, &ast.UsingStatement{},
+			&ast.TryStatement{}, &ast.ConditionStatement{}, &ast.VariableDeclaration{},
+			&ast.FunctionDeclaration{}, &ast.ClassDeclaration{}, &ast.InterfaceDeclaration{}, &ast.ImplDeclaration{},
+			&ast.StructDeclaration{}, &ast.EnumDeclaration{}, &ast.ContractDeclaration{},
+			&ast.ImportDeclaration{}, &ast.PackageDeclaration{}, &ast.ModuleDeclaration{},
+			&ast.MatchStatement{}, &ast.ObjectPattern{}, &ast.StructPattern{}, &ast.ArrayPattern{},
+			&ast.IdentifierPattern{}, &ast.LiteralPattern{},
+		} {
+			gob.Register(node)
+		}
+	})
+}
+
+type encodedChunk struct {
+	Version string
+	Code    []byte
+	Items   []ast.ProgramItem
+}
+
+// MarshalBinary serialises the chunk, including the program items it
+// references, so it can be stored in the bytecode cache.
+func (c *Chunk) MarshalBinary() ([]byte, error) {
+	registerNodes()
+	var buf bytes.Buffer
+	payload := encodedChunk{Version: CompilerVersion, Code: c.code, Items: c.items}
+	if err := gob.NewEncoder(&buf).Encode(&payload); err != nil {
+		return nil, fmt.Errorf("encode chunk: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary restores a chunk produced by MarshalBinary. Chunks written
+// by a different compiler version are rejected.
+func (c *Chunk) UnmarshalBinary(data []byte) error {
+	registerNodes()
+	var payload encodedChunk
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&payload); err != nil {
+		return fmt.Errorf("decode chunk: %w", err)
+	}
+	if payload.Version != CompilerVersion {
+		return fmt.Errorf("chunk compiled by %q, expected %q", payload.Version, CompilerVersion)
+	}
+	for i, item := range payload.Items {
+		if item == nil {
+			return fmt.Errorf("decode chunk: program item %d is empty", i)
+		}
+	}
+	if len(payload.Code) == 0 {
+		return errors.New("decode chunk: no instructions")
+	}
+	c.code = payload.Code
+	c.items = payload.Items
+	c.decoded = true
+	return nil
+}
@@ -0,0 +1,140 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Bytes is an immutable sequence of bytes, for data that is not text. Like
+// String it compares by value; indexing yields the byte at that offset as a
+// Number, and + concatenates.
+type Bytes struct {
+	Value []byte
+}
+
+// NewBytes wraps data, which the Bytes value then owns.
+func NewBytes(data []byte) *Bytes {
+	return &Bytes{Value: data}
+}
+
+// Type implements the Value interface for Bytes.
+func (b *Bytes) Type() string { return "Bytes" }
+
+// Inspect renders the bytes as a quoted literal with \x escapes for bytes
+// that are not printable UTF-8.
+func (b *Bytes) Inspect() string {
+	return "b" + strconv.Quote(string(b.Value))
+}
+
+// builtinBytes implements bytes(value), which converts a String to its UTF-8
+// encoding, an Array of Numbers from 0 to 255 to those bytes, or copies a
+// Bytes value. With no argument it returns empty Bytes.
+func builtinBytes(args []Value) (Value, error) {
+	switch len(args) {
+	case 0:
+		return NewBytes(nil), nil
+	case 1:
+	default:
+		return nil, errors.New("bytes expects at most one String, Array, or Bytes")
+	}
+	switch v := args[0].(type) {
+	case *String:
+		return NewBytes([]byte(v.Value)), nil
+	case *Bytes:
+		return NewBytes(bytes.Clone(v.Value)), nil
+	case *Array:
+		data := make([]byte, len(v.Elements))
+		for i, el := range v.Elements {
+			num, ok := el.(*Number)
+			if !ok || num.Value != float64(byte(num.Value)) {
+				return nil, fmt.Errorf("bytes: element %d is %s, not a Number from 0 to 255", i, el.Inspect())
+			}
+			data[i] = byte(num.Value)
+		}
+		return NewBytes(data), nil
+	default:
+		return nil, fmt.Errorf("bytes cannot convert %s", args[0].Type())
+	}
+}
+
+// bytesProperty resolves the members of a Bytes value: length and its
+// methods.
+func bytesProperty(b *Bytes, property string) (Value, bool, error) {
+	switch property {
+	case "length":
+		return NewNumber(float64(len(b.Value))), true, nil
+	case "slice":
+		return newBuiltin("slice", func(args []Value) (Value, error) {
+			if len(args) < 1 || len(args) > 2 {
+				return nil, errors.New("slice expects a start and an optional end")
+			}
+			start, err := bytesOffset("slice start", args[0], len(b.Value))
+			if err != nil {
+				return nil, err
+			}
+			end := len(b.Value)
+			if len(args) == 2 {
+				if end, err = bytesOffset("slice end", args[1], len(b.Value)); err != nil {
+					return nil, err
+				}
+			}
+			if start > end {
+				return nil, fmt.Errorf("slice start %d is after end %d", start, end)
+			}
+			return NewBytes(b.Value[start:end:end]), nil
+		}), true, nil
+	case "toString":
+		return newBuiltin("toString", func(args []Value) (Value, error) {
+			if len(args) != 0 {
+				return nil, errors.New("toString takes no arguments")
+			}
+			if !utf8.Valid(b.Value) {
+				return nil, errors.New("bytes are not valid UTF-8")
+			}
+			return NewString(string(b.Value)), nil
+		}), true, nil
+	case "toArray":
+		return newBuiltin("toArray", func(args []Value) (Value, error) {
+			if len(args) != 0 {
+				return nil, errors.New("toArray takes no arguments")
+			}
+			elements := make([]Value, len(b.Value))
+			for i, c := range b.Value {
+				elements[i] = NewNumber(float64(c))
+			}
+			return &Array{Elements: elements}, nil
+		}), true, nil
+	case "hex":
+		return newBuiltin("hex", func(args []Value) (Value, error) {
+			if len(args) != 0 {
+				return nil, errors.New("hex takes no arguments")
+			}
+			return NewString(hex.EncodeToString(b.Value)), nil
+		}), true, nil
+	}
+	if fn, ok := lookupExtension(b.Type(), property); ok {
+		return bindMethod(fn, b), true, nil
+	}
+	return nil, false, fmt.Errorf("unknown bytes property %s", property)
+}
+
+// bytesOffset validates an offset into Bytes of length n; n itself is the
+// offset just past the end.
+func bytesOffset(what string, val Value, n int) (int, error) {
+	num, ok := val.(*Number)
+	if !ok {
+		return 0, fmt.Errorf("%s must be Number, got %s", what, val.Type())
+	}
+	offset := int(num.Value)
+	if float64(offset) != num.Value {
+		return 0, fmt.Errorf("%s must be integer", what)
+	}
+	if offset < 0 || offset > n {
+		return 0, fmt.Errorf("%s %d out of range", what, offset)
+	}
+	return offset, nil
+}
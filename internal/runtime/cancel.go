@@ -0,0 +1,194 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInterrupted is the cause of the *CancelledError reported after
+// Interrupt, typically because the user pressed Ctrl-C.
+var ErrInterrupted = errors.New("interrupted")
+
+// CancelledError is returned when the context given to SetContext is done
+// while a program is running. Like ExitError it unwinds through catch
+// clauses, so scripts cannot swallow a timeout.
+type CancelledError struct {
+	Err error
+}
+
+// Error implements the error interface for CancelledError.
+func (e *CancelledError) Error() string { return "execution cancelled: " + e.Err.Error() }
+
+// Unwrap exposes the context error, so errors.Is(err, context.DeadlineExceeded)
+// works on timeouts.
+func (e *CancelledError) Unwrap() error { return e.Err }
+
+// runControl carries a runtime's context to every environment, task, and
+// channel derived from it. Evaluation polls it at loop iterations and
+// function calls, and blocking operations select on its Done channel. It
+// also carries the runtime's Importer to import declarations and the program
+// arguments to main.
+type runControl struct {
+	ctx      atomic.Pointer[controlContext]
+	budget   *budget
+	importer Importer
+	args     []string
+	// interrupted is set by Interrupt. While it is set and cleanups is
+	// nonzero, cancellation is suspended so finally blocks and using
+	// disposals can finish.
+	interrupted atomic.Bool
+	cleanups    atomic.Int32
+	// frames caches the slot layout of each function declaration that
+	// has run, keyed by *ast.FunctionDeclaration.
+	frames sync.Map
+	// literals caches the value of each constant literal that has been
+	// evaluated, keyed by its AST node, and strings interns the text of
+	// constant string literals.
+	literals sync.Map
+	strings  sync.Map
+	// race is set by SetRaceCheck.
+	race *raceDetector
+	// strictMath is set by SetStrictMath.
+	strictMath bool
+	// tracker is the runtime's resource tracker, through which generators
+	// register bodies that are suspended at a yield.
+	tracker *resourceTracker
+	// skipContracts is set by SetContracts(false).
+	skipContracts bool
+	// maxDepth is set by SetMaxCallDepth, and unknownCalls counts the
+	// calls in progress that applyFunction made without a caller depth.
+	maxDepth     int32
+	unknownCalls atomic.Int32
+	// tiering is set by SetTiering.
+	tiering *tiering
+	// annotations records annotated declarations for the reflect module.
+	annotations annotationRegistry
+}
+
+type controlContext struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+}
+
+func (c *runControl) argsArray() *Array {
+	var args []string
+	if c != nil {
+		args = c.args
+	}
+	elements := make([]Value, len(args))
+	for i, arg := range args {
+		elements[i] = NewString(arg)
+	}
+	return &Array{Elements: elements}
+}
+
+func (c *runControl) context() context.Context {
+	if c != nil {
+		if ctx := c.ctx.Load(); ctx != nil {
+			return ctx.ctx
+		}
+	}
+	return context.Background()
+}
+
+// done returns nil, which blocks forever in a select, while interrupted
+// cleanup runs.
+func (c *runControl) done() <-chan struct{} {
+	if c.cleaningUp() {
+		return nil
+	}
+	return c.context().Done()
+}
+
+func (c *runControl) err() error {
+	ctx := c.context()
+	if ctx.Err() == nil || c.cleaningUp() {
+		return nil
+	}
+	return &CancelledError{Err: context.Cause(ctx)}
+}
+
+func (c *runControl) cleaningUp() bool {
+	return c != nil && c.interrupted.Load() && c.cleanups.Load() > 0
+}
+
+// cleanup marks the start of a finally block or using disposal and returns
+// the function that marks its end.
+func (c *runControl) cleanup() func() {
+	if c == nil {
+		return func() {}
+	}
+	c.cleanups.Add(1)
+	return func() { c.cleanups.Add(-1) }
+}
+
+// sleep waits for d on clock, returning early when the context is done.
+// Only the system clock really blocks; other clocks are left to their own
+// Sleep, which is expected to return promptly.
+func (c *runControl) sleep(clock Clock, d time.Duration) error {
+	if _, ok := clock.(systemClock); !ok {
+		clock.Sleep(d)
+		return c.err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-c.done():
+		return c.err()
+	}
+}
+
+// SetContext makes evaluation in this runtime stop with a *CancelledError
+// once ctx is done. It applies to every backend and to tasks the program
+// spawned, including ones still running after Run returns.
+func (r *Runtime) SetContext(ctx context.Context) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	r.control.ctx.Store(&controlContext{ctx: ctx, cancel: cancel})
+}
+
+// setDeadline makes the runtime's context expire after d, with a time
+// *BudgetError as the cause.
+func (r *Runtime) setDeadline(d time.Duration) {
+	ctx, stop := context.WithTimeoutCause(r.control.context(), d, &BudgetError{Resource: "time", Limit: uint64(d)})
+	ctx, cancel := context.WithCancelCause(ctx)
+	r.control.ctx.Store(&controlContext{ctx: ctx, cancel: func(cause error) {
+		cancel(cause)
+		stop()
+	}})
+}
+
+// Interrupt cancels the program as a done context would, with ErrInterrupted
+// as the cause, but lets cleanup code finish: finally blocks and using
+// disposals that are running or start while the program unwinds run to
+// completion, calling functions and blocking as usual. Hosts call it when
+// the user asks the program to stop, and may terminate the process on a
+// second request in case a cleanup never finishes.
+func (r *Runtime) Interrupt() {
+	if r.control.ctx.Load() == nil {
+		r.SetContext(context.Background())
+	}
+	r.control.interrupted.Store(true)
+	r.control.ctx.Load().cancel(ErrInterrupted)
+}
+
+// Err reports a *CancelledError once the context of the runtime that owns e
+// is done, or a *BudgetError once its Policy budget is spent, and nil
+// otherwise. Each call counts as one step. Backends that drive evaluation
+// themselves call it between steps.
+func (e *Environment) Err() error {
+	if e.control == nil {
+		return nil
+	}
+	if err := e.control.err(); err != nil {
+		return err
+	}
+	return e.control.budget.step()
+}
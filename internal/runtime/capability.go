@@ -0,0 +1,95 @@
+package runtime
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Capability names a group of host-affecting builtins that a Policy can
+// switch off for a run.
+type Capability string
+
+const (
+	// CapabilityFS covers the fs module and path.glob.
+	CapabilityFS Capability = "fs"
+	// CapabilityNet covers network access. No builtin reaches the network
+	// yet, so denying it restricts nothing today; manifests may still name it.
+	CapabilityNet Capability = "net"
+	// CapabilityExec covers os.exec.
+	CapabilityExec Capability = "os.exec"
+	// CapabilityEnv covers os.env and os.setenv.
+	CapabilityEnv Capability = "env"
+)
+
+// capabilityBuiltins lists the module members each capability covers.
+var capabilityBuiltins = map[Capability][]string{
+	CapabilityFS:   {"fs.read", "fs.readBytes", "fs.write", "fs.exists", "fs.tempFile", "fs.tempDir", "path.glob"},
+	CapabilityNet:  nil,
+	CapabilityExec: {"os.exec"},
+	CapabilityEnv:  {"os.env", "os.setenv"},
+}
+
+// Capabilities returns the capability names a Policy accepts, sorted.
+func Capabilities() []Capability {
+	names := make([]Capability, 0, len(capabilityBuiltins))
+	for name := range capabilityBuiltins {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// permissionErrorClass is the builtin PermissionError class, bound as the
+// global PermissionError. Calling a builtin whose capability the policy
+// denies throws one, with capability set to the capability's name, so
+// scripts can catch (e: PermissionError) and carry on without it.
+var permissionErrorClass = &ClassType{
+	Name:    "PermissionError",
+	Fields:  []string{"capability", "message"},
+	Methods: make(map[string]*Function),
+	Static:  make(map[string]Value),
+	Super:   errorClass,
+}
+
+// builtinCapability returns the capability that covers op, a builtin such as
+// "fs.read", or "" when no capability does.
+func builtinCapability(op string) Capability {
+	for name, ops := range capabilityBuiltins {
+		if slices.Contains(ops, op) {
+			return name
+		}
+	}
+	return ""
+}
+
+// checkCapability returns the PermissionError op raises when the policy
+// denies the capability that covers it.
+func (r *Runtime) checkCapability(op string) error {
+	capability := builtinCapability(op)
+	if capability == "" || r.policy.allows(capability) {
+		return nil
+	}
+	fields := map[string]Value{
+		"capability": NewString(string(capability)),
+		"message":    NewString(fmt.Sprintf("%s needs the %s capability, which this run denies", op, capability)),
+	}
+	errorFields(fields)
+	inst := &ClassInstance{Definition: permissionErrorClass, Fields: fields}
+	return &runtimeError{value: toErrorValue(inst), thrown: inst}
+}
+
+// allows reports whether p leaves capability enabled. Capabilities the
+// policy does not mention are enabled.
+func (p Policy) allows(capability Capability) bool {
+	enabled, ok := p.Capabilities[capability]
+	return !ok || enabled
+}
+
+func checkCapabilities(capabilities map[Capability]bool) error {
+	for name := range capabilities {
+		if _, ok := capabilityBuiltins[name]; !ok {
+			return fmt.Errorf("policy names unknown capability %s", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package runtime
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+)
+
+// declareAccessors records the get and set accessors in a class body. They
+// close over the body's environment like the class's methods do.
+func declareAccessors(classType *ClassType, body *ast.BlockStatement, env *Environment) error {
+	if body == nil {
+		return nil
+	}
+	for _, stmt := range body.Statements {
+		decl, ok := stmt.(*ast.FunctionDeclaration)
+		if !ok || decl.Accessor == "" || decl.Name == nil {
+			continue
+		}
+		name := decl.Name.Name
+		if slices.Contains(classType.Fields, name) {
+			return fmt.Errorf("%s.%s is a field and cannot also have a %s accessor", classType.Name, name, decl.Accessor)
+		}
+		accessors := &classType.Getters
+		if decl.Accessor == "set" {
+			accessors = &classType.Setters
+		}
+		if *accessors == nil {
+			*accessors = make(map[string]*Function)
+		}
+		if _, ok := (*accessors)[name]; ok {
+			return fmt.Errorf("%s.%s has more than one %s accessor", classType.Name, name, decl.Accessor)
+		}
+		(*accessors)[name] = &Function{Declaration: decl, Env: env, Name: name}
+	}
+	return nil
+}
+
+// staticNames lists the methods, constants, and variables a class body
+// declares static.
+func staticNames(body *ast.BlockStatement) map[string]bool {
+	names := make(map[string]bool)
+	if body == nil {
+		return names
+	}
+	for _, stmt := range body.Statements {
+		switch decl := stmt.(type) {
+		case *ast.FunctionDeclaration:
+			if decl.Static {
+				names[decl.Name.Name] = true
+			}
+		case *ast.VariableDeclaration:
+			if decl.Static {
+				collectDeclarations(decl, func(name string) { names[name] = true })
+			}
+		}
+	}
+	return names
+}
+
+// declareAbstractMethods records the abstract methods classType declares
+// or inherits without implementing, which only an abstract class may have.
+func declareAbstractMethods(classType *ClassType, decl *ast.ClassDeclaration) error {
+	classType.Abstract = decl.Abstract
+	if classType.Super != nil {
+		for _, name := range classType.Super.abstract {
+			if _, ok := classType.Methods[name]; !ok {
+				classType.abstract = append(classType.abstract, name)
+			}
+		}
+	}
+	if decl.Body != nil {
+		for _, stmt := range decl.Body.Statements {
+			fn, ok := stmt.(*ast.FunctionDeclaration)
+			if !ok || !fn.Abstract || fn.Name == nil {
+				continue
+			}
+			if !decl.Abstract {
+				return fmt.Errorf("%s.%s is abstract, so %s must be an abstract class", classType.Name, fn.Name.Name, classType.Name)
+			}
+			if !slices.Contains(classType.abstract, fn.Name.Name) {
+				classType.abstract = append(classType.abstract, fn.Name.Name)
+			}
+		}
+	}
+	if !decl.Abstract && len(classType.abstract) > 0 {
+		return fmt.Errorf("%s must implement the abstract method %s it inherits", classType.Name, classType.abstract[0])
+	}
+	return nil
+}
+
+// lookupAccessor finds the getter or setter, as kind says, for a property
+// of c or one of its superclasses.
+func (c *ClassType) lookupAccessor(name, kind string) (*Function, bool) {
+	for ; c != nil; c = c.Super {
+		accessors := c.Getters
+		if kind == "set" {
+			accessors = c.Setters
+		}
+		if fn, ok := accessors[name]; ok {
+			return fn, true
+		}
+	}
+	return nil, false
+}
+
+// setInstanceProperty assigns through the property's setter when it has one
+// and otherwise to the field of that name. A property with a getter but no
+// setter is read-only, and so is every property of a frozen instance.
+func setInstanceProperty(instance *ClassInstance, property string, value Value) error {
+	name := instance.Definition.Name
+	if instance.frozen {
+		return fmt.Errorf("cannot assign to property %s of a frozen %s", property, name)
+	}
+	if setter, ok := instance.Definition.lookupAccessor(property, "set"); ok {
+		_, err := applyFunction(bindMethod(setter, instance), []Value{value})
+		return err
+	}
+	if _, ok := instance.Definition.lookupAccessor(property, "get"); ok {
+		return fmt.Errorf("%s.%s has a getter but no setter", name, property)
+	}
+	if _, ok := instance.Fields[property]; !ok {
+		return fmt.Errorf("%s has no field %s", name, property)
+	}
+	instance.replaceField(property, value)
+	return nil
+}
+
+// replaceField sets the field name of c to value. Tasks may be reading the
+// instance without a lock, so the field map is copied and swapped rather
+// than written in place.
+func (c *ClassInstance) replaceField(name string, value Value) {
+	fields := maps.Clone(c.Fields)
+	fields[name] = value
+	c.Fields = fields
+}
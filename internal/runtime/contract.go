@@ -0,0 +1,147 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/printer"
+)
+
+// contractRequirements lists the functions a contract body declares
+// without a body, which classes implementing the contract must define.
+func contractRequirements(body *ast.BlockStatement) []*ast.FunctionDeclaration {
+	if body == nil {
+		return nil
+	}
+	var required []*ast.FunctionDeclaration
+	for _, stmt := range body.Statements {
+		fn, ok := stmt.(*ast.FunctionDeclaration)
+		if ok && fn.Name != nil && fn.Body == nil && !fn.IsExprBody {
+			required = append(required, fn)
+		}
+	}
+	return required
+}
+
+// declareContracts resolves the contracts a class declaration names after
+// implements.
+func declareContracts(classType *ClassType, decl *ast.ClassDeclaration, env *Environment) error {
+	for _, name := range decl.Implements {
+		val, ok := env.Get(name.Name)
+		if !ok {
+			return fmt.Errorf("unknown contract %s", name.Name)
+		}
+		contract, ok := val.(*Contract)
+		if !ok {
+			return fmt.Errorf("%s is not a contract", name.Name)
+		}
+		classType.Contracts = append(classType.Contracts, contract)
+	}
+	return nil
+}
+
+// SetContracts turns checking of requires and ensures clauses and of
+// invariants on or off. Contracts are checked unless it is called with
+// false; the methods a contract requires are checked either way. Call it
+// before running code.
+func (r *Runtime) SetContracts(enabled bool) {
+	r.control.skipContracts = !enabled
+}
+
+// contractsEnabled reports whether code in env checks contract clauses and
+// invariants.
+func (e *Environment) contractsEnabled() bool {
+	return e == nil || e.control == nil || !e.control.skipContracts
+}
+
+// checkContracts verifies a new instance against the contracts its class
+// and their superclasses implement: every required function must be a
+// method taking as many parameters, and, when invariants is set, every
+// invariant must hold. Methods may come from impl blocks run after the
+// class was declared, so this happens on instantiation rather than
+// declaration.
+func checkContracts(instance *ClassInstance, invariants bool) error {
+	classType := instance.Definition
+	for owner := classType; owner != nil; owner = owner.Super {
+		for _, contract := range owner.Contracts {
+			for _, required := range contract.requires {
+				name := required.Name.Name
+				method, ok := classType.lookupMethod(name)
+				if !ok {
+					return fmt.Errorf("%s does not fulfil contract %s: missing method %s", classType.Name, contract.Name, name)
+				}
+				if method.Declaration != nil && len(method.Declaration.Params) != len(required.Params) {
+					return fmt.Errorf("%s.%s takes %d parameters, but contract %s requires %d", classType.Name, name, len(method.Declaration.Params), contract.Name, len(required.Params))
+				}
+			}
+			if !invariants {
+				continue
+			}
+			invariant, err := brokenInvariant(contract.invariants, contract.env, instance)
+			if err != nil {
+				return err
+			}
+			if invariant != nil {
+				return fmt.Errorf("%s violates invariant of contract %s: %s", classType.Name, contract.Name, printer.Node(invariant.Condition))
+			}
+		}
+	}
+	return nil
+}
+
+// checkInvariants verifies the invariants instance's class and its
+// superclasses declare. after names the method just called, or is empty
+// when the instance has just been constructed.
+func checkInvariants(instance *ClassInstance, after string) error {
+	for owner := instance.Definition; owner != nil; owner = owner.Super {
+		invariant, err := brokenInvariant(owner.invariants, owner.body, instance)
+		if err != nil {
+			return err
+		}
+		if invariant == nil {
+			continue
+		}
+		if after == "" {
+			return fmt.Errorf("%s violates invariant of %s: %s", instance.Definition.Name, owner.Name, printer.Node(invariant.Condition))
+		}
+		return fmt.Errorf("%s.%s broke invariant of %s: %s", instance.Definition.Name, after, owner.Name, printer.Node(invariant.Condition))
+	}
+	return nil
+}
+
+// brokenInvariant evaluates invariants with self bound to instance in a
+// scope enclosed by env, and returns the first that does not hold.
+func brokenInvariant(invariants []*ast.ContractInvariant, env *Environment, instance *ClassInstance) (*ast.ContractInvariant, error) {
+	for _, invariant := range invariants {
+		invariantEnv := NewEnclosedEnvironment(env)
+		invariantEnv.Set("self", instance)
+		invariantEnv.Set("this", instance)
+		holds, err := evalExpression(invariant.Condition, invariantEnv)
+		if err != nil {
+			return nil, err
+		}
+		if !isTruthy(holds) {
+			return invariant, nil
+		}
+	}
+	return nil, nil
+}
+
+// hasInvariants reports whether c or a superclass declares an invariant.
+func (c *ClassType) hasInvariants() bool {
+	for ; c != nil; c = c.Super {
+		if len(c.invariants) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// guardsInvariants reports whether a call to the method name must leave
+// the invariants of its instance holding. init builds the instance, which
+// is checked once construction finishes, and a name starting with _ marks
+// a private helper that may briefly break them.
+func guardsInvariants(name string) bool {
+	return name != "init" && !strings.HasPrefix(name, "_")
+}
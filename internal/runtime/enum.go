@@ -0,0 +1,57 @@
+package runtime
+
+import (
+	"fmt"
+	"slices"
+)
+
+// enumTypeProperty implements the builtins every enum type has besides its
+// case constructors: cases() lists the case names in declaration order, and
+// parse(name) returns Option.Some of the case called name, or Option.None
+// when there is no such case or it carries values.
+func enumTypeProperty(enum *EnumType, property string) (Value, bool, error) {
+	switch property {
+	case "cases":
+		return newBuiltin("cases", func(args []Value) (Value, error) {
+			if len(args) != 0 {
+				return nil, fmt.Errorf("%s.cases takes no arguments", enum.Name)
+			}
+			names := make([]Value, len(enum.CaseOrder))
+			for i, name := range enum.CaseOrder {
+				names[i] = NewString(name)
+			}
+			return &Array{Elements: names}, nil
+		}), true, nil
+	case "parse":
+		return newBuiltin("parse", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("%s.parse expects a case name", enum.Name)
+			}
+			name, ok := args[0].(*String)
+			if !ok {
+				return nil, fmt.Errorf("%s.parse expects a String, got %s", enum.Name, args[0].Type())
+			}
+			if params, ok := enum.Cases[name.Value]; !ok || len(params) > 0 {
+				return None(), nil
+			}
+			return Some(&EnumInstance{Enum: enum, Case: name.Value}), nil
+		}), true, nil
+	}
+	return nil, false, nil
+}
+
+// enumInstanceProperty implements name, the instance's case name, and
+// ordinal, the position of its case in the declaration counting from zero.
+func enumInstanceProperty(inst *EnumInstance, property string) (Value, bool, error) {
+	switch property {
+	case "name":
+		return NewString(inst.Case), true, nil
+	case "ordinal":
+		ordinal := slices.Index(inst.Enum.CaseOrder, inst.Case)
+		if ordinal < 0 {
+			return nil, false, fmt.Errorf("%s does not record the order of its cases", inst.Enum.Name)
+		}
+		return NewNumber(float64(ordinal)), true, nil
+	}
+	return nil, false, nil
+}
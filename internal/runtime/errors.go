@@ -0,0 +1,104 @@
+package runtime
+
+// errorClass is the builtin Error class, bound as the global Error.
+// Error(message) and Error(message, cause) construct one directly, and a
+// class declared class Name(params) : Error, or below a class that is,
+// constructs errors of its own kind. Besides the fields its class declares,
+// every such instance has a message, a cause, and a stack: a parameter or
+// init can set the first two, which default to "" and null, and the stack
+// is filled in with the calls the error unwound through when a catch clause
+// catches it. Errors the runtime raises itself are ErrorValues, which count
+// as Errors for is and catch clauses and expose the same three fields.
+var errorClass = &ClassType{
+	Name:    "Error",
+	Fields:  []string{"message", "cause"},
+	Methods: make(map[string]*Function),
+	Static:  make(map[string]Value),
+}
+
+// isError reports whether c is Error or one of its subclasses.
+func (c *ClassType) isError() bool {
+	for ; c != nil; c = c.Super {
+		if c == errorClass {
+			return true
+		}
+	}
+	return false
+}
+
+// errorFields adds the message, cause, and stack an error instance has
+// whether or not its class declares them to fields.
+func errorFields(fields map[string]Value) {
+	for name, val := range map[string]Value{"message": NewString(""), "cause": NullValue, "stack": &Array{}} {
+		if _, ok := fields[name]; !ok {
+			fields[name] = val
+		}
+	}
+}
+
+// errorText is the message of an error instance, prefixed with its class
+// unless that is Error itself: "NetworkError: timed out".
+func errorText(inst *ClassInstance) string {
+	message := toString(inst.Fields["message"])
+	switch {
+	case inst.Definition == errorClass:
+		return message
+	case message == "":
+		return inst.Definition.Name
+	default:
+		return inst.Definition.Name + ": " + message
+	}
+}
+
+// inspectError renders an error instance the way ErrorValue.Inspect renders
+// the errors the runtime raises.
+func inspectError(inst *ClassInstance) string {
+	b := borrowBuilder()
+	b.WriteString("<error ")
+	b.WriteString(errorText(inst))
+	if cause := inst.Fields["cause"]; cause != nil && cause != NullValue {
+		b.WriteString(" : ")
+		b.WriteString(cause.Inspect())
+	}
+	b.WriteByte('>')
+	return finishBuilder(b)
+}
+
+// errorValueProperty reads the message, cause, or stack of an ErrorValue.
+func errorValueProperty(e *ErrorValue, property string) (Value, bool, error) {
+	switch property {
+	case "message":
+		return NewString(e.Message), true, nil
+	case "cause":
+		if e.Cause == nil {
+			return NullValue, true, nil
+		}
+		return e.Cause, true, nil
+	case "stack":
+		return stackArray(e.Stack), true, nil
+	}
+	return nil, false, nil
+}
+
+func stackArray(frames []StackFrame) *Array {
+	elements := make([]Value, len(frames))
+	for i, frame := range frames {
+		elements[i] = NewString(frame.String())
+	}
+	return &Array{Elements: elements}
+}
+
+// withStack returns the error a catch clause binds, with its stack set to
+// frames. ErrorValues are copied, since the same one can be caught more than
+// once; an error instance has its stack field replaced.
+func withStack(val Value, frames []StackFrame) Value {
+	switch v := val.(type) {
+	case *ErrorValue:
+		return &ErrorValue{Message: v.Message, Cause: v.Cause, Stack: frames}
+	case *ClassInstance:
+		if v.Definition.isError() {
+			v.replaceField("stack", stackArray(frames))
+		}
+	}
+	return val
+}
@@ -0,0 +1,241 @@
+package runtime
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// formatSpec is a parsed format specification, the text after the colon in
+// ${value:spec} or in a {:spec} placeholder of format():
+//
+//	[[fill]align][sign][#][0][width][grouping][.precision][type]
+//
+// align is < (left), > (right), ^ (centre), or = (pad between the sign and
+// the digits); sign is +, -, or a space; # adds a 0b, 0o, or 0x prefix; a
+// leading 0 pads numbers with zeros; grouping is , or _; and type is one of
+// s, d, b, o, x, X, e, E, f, F, g, G, or %.
+type formatSpec struct {
+	text      string
+	fill      rune
+	align     byte
+	sign      byte
+	alternate bool
+	zero      bool
+	width     int
+	grouping  byte
+	precision int
+	verb      byte
+}
+
+func parseFormatSpec(text string) (formatSpec, error) {
+	spec := formatSpec{text: text, fill: ' ', precision: -1}
+	s := text
+	if r, size := utf8.DecodeRuneInString(s); size > 0 && size < len(s) && isAlign(s[size]) {
+		spec.fill, spec.align = r, s[size]
+		s = s[size+1:]
+	} else if s != "" && isAlign(s[0]) {
+		spec.align = s[0]
+		s = s[1:]
+	}
+	if s != "" && strings.IndexByte("+- ", s[0]) >= 0 {
+		spec.sign = s[0]
+		s = s[1:]
+	}
+	if strings.HasPrefix(s, "#") {
+		spec.alternate = true
+		s = s[1:]
+	}
+	if strings.HasPrefix(s, "0") && spec.align == 0 {
+		spec.zero = true
+	}
+	spec.width, s = leadingInt(s)
+	if s != "" && (s[0] == ',' || s[0] == '_') {
+		spec.grouping = s[0]
+		s = s[1:]
+	}
+	if strings.HasPrefix(s, ".") {
+		if spec.precision, s = leadingInt(s[1:]); spec.precision < 0 {
+			return spec, fmt.Errorf("format specifier %q is missing a precision after .", text)
+		}
+	}
+	if len(s) == 1 && strings.IndexByte("sdboxXeEfFgG%", s[0]) >= 0 {
+		spec.verb = s[0]
+		s = ""
+	}
+	if s != "" {
+		return spec, fmt.Errorf("unknown format specifier %q", text)
+	}
+	return spec, nil
+}
+
+func isAlign(c byte) bool {
+	return c == '<' || c == '>' || c == '^' || c == '='
+}
+
+// leadingInt parses the decimal digits at the start of s, returning -1 when
+// there are none.
+func leadingInt(s string) (int, string) {
+	end := 0
+	for end < len(s) && '0' <= s[end] && s[end] <= '9' {
+		end++
+	}
+	if end == 0 {
+		return -1, s
+	}
+	n, err := strconv.Atoi(s[:end])
+	if err != nil {
+		return math.MaxInt, s[end:]
+	}
+	return n, s[end:]
+}
+
+// apply formats val. Numbers are right-aligned and everything else is
+// formatted as its string form, left-aligned.
+func (spec formatSpec) apply(val Value) (string, error) {
+	num, isNumber := val.(*Number)
+	if spec.verb == 's' || spec.verb == 0 && !isNumber {
+		if spec.sign != 0 || spec.alternate || spec.grouping != 0 || spec.align == '=' {
+			return "", fmt.Errorf("format specifier %q needs a Number, got %s", spec.text, val.Type())
+		}
+		text := toString(val)
+		if spec.precision >= 0 && utf8.RuneCountInString(text) > spec.precision {
+			text = string([]rune(text)[:spec.precision])
+		}
+		return spec.pad("", text, spec.fill, '<'), nil
+	}
+	if !isNumber {
+		return "", fmt.Errorf("format specifier %q needs a Number, got %s", spec.text, val.Type())
+	}
+	sign := ""
+	switch {
+	case math.Signbit(num.Value) && !math.IsNaN(num.Value):
+		sign = "-"
+	case spec.sign == '+' || spec.sign == ' ':
+		sign = string(spec.sign)
+	}
+	prefix, digits, err := spec.digits(math.Abs(num.Value))
+	if err != nil {
+		return "", err
+	}
+	if spec.zero {
+		return spec.pad(sign+prefix, digits, '0', '='), nil
+	}
+	return spec.pad(sign+prefix, digits, spec.fill, '>'), nil
+}
+
+// digits formats n, which is not negative, returning any base prefix apart
+// so that zero padding goes between it and the digits.
+func (spec formatSpec) digits(n float64) (string, string, error) {
+	switch spec.verb {
+	case 'd', 'b', 'o', 'x', 'X':
+		if spec.precision >= 0 {
+			return "", "", fmt.Errorf("format specifier %q cannot give a precision for an integer", spec.text)
+		}
+		if !isInteger(n) || n >= 1<<64 {
+			return "", "", fmt.Errorf("format specifier %q needs an integer, got %s", spec.text, formatNumber(n))
+		}
+		base, prefix, group := 10, "", 3
+		switch spec.verb {
+		case 'b':
+			base, prefix, group = 2, "0b", 4
+		case 'o':
+			base, prefix, group = 8, "0o", 4
+		case 'x', 'X':
+			base, prefix, group = 16, "0x", 4
+		}
+		digits := spec.group(strconv.FormatUint(uint64(n), base), group)
+		if !spec.alternate {
+			prefix = ""
+		}
+		if spec.verb == 'X' {
+			return strings.ToUpper(prefix), strings.ToUpper(digits), nil
+		}
+		return prefix, digits, nil
+	}
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		text := "Inf"
+		if math.IsNaN(n) {
+			text = "NaN"
+		}
+		if strings.IndexByte("EFG", spec.verb) >= 0 {
+			text = strings.ToUpper(text)
+		}
+		return "", text, nil
+	}
+	precision := spec.precision
+	var text string
+	switch spec.verb {
+	case 0:
+		if precision < 0 {
+			text = formatNumber(n)
+		} else {
+			text = strconv.FormatFloat(n, 'g', max(precision, 1), 64)
+		}
+	case '%':
+		if precision < 0 {
+			precision = 6
+		}
+		text = strconv.FormatFloat(n*100, 'f', precision, 64) + "%"
+	case 'g', 'G':
+		if precision == 0 {
+			precision = 1
+		}
+		text = strconv.FormatFloat(n, spec.verb, precision, 64)
+	default:
+		if precision < 0 {
+			precision = 6
+		}
+		text = strconv.FormatFloat(n, spec.verb|0x20, precision, 64)
+		if spec.verb == 'E' {
+			text = strings.ToUpper(text)
+		}
+	}
+	whole := strings.IndexFunc(text, func(r rune) bool { return r < '0' || r > '9' })
+	if whole < 0 {
+		whole = len(text)
+	}
+	return "", spec.group(text[:whole], 3) + text[whole:], nil
+}
+
+// group separates digits into groups of size from the right when the spec
+// asks for grouping.
+func (spec formatSpec) group(digits string, size int) string {
+	if spec.grouping == 0 || len(digits) <= size {
+		return digits
+	}
+	var b strings.Builder
+	for i := range len(digits) {
+		if i > 0 && (len(digits)-i)%size == 0 {
+			b.WriteByte(spec.grouping)
+		}
+		b.WriteByte(digits[i])
+	}
+	return b.String()
+}
+
+// pad fills head+body out to the spec's width with fill, aligning by
+// fallback when the spec gives no alignment.
+func (spec formatSpec) pad(head, body string, fill rune, fallback byte) string {
+	space := spec.width - utf8.RuneCountInString(head) - utf8.RuneCountInString(body)
+	if space <= 0 {
+		return head + body
+	}
+	align := spec.align
+	if align == 0 {
+		align = fallback
+	}
+	repeat := func(n int) string { return strings.Repeat(string(fill), n) }
+	switch align {
+	case '<':
+		return head + body + repeat(space)
+	case '^':
+		return repeat(space/2) + head + body + repeat(space-space/2)
+	case '=':
+		return head + repeat(space) + body
+	default:
+		return repeat(space) + head + body
+	}
+}
@@ -0,0 +1,85 @@
+package runtime
+
+import "errors"
+
+// builtinFreeze implements freeze(value), which returns a frozen copy of an
+// Array, Object, Set, or struct or class instance, with every such value
+// inside it frozen too. Assigning to an element, property, or field of a
+// frozen value, or adding to or removing from a frozen Set, is an error, so
+// a frozen value can be handed out without copying it first. A value that
+// is frozen already is returned as it is, and other values, which are
+// immutable, are returned unchanged.
+func builtinFreeze(args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, errors.New("freeze expects one value")
+	}
+	return freeze(args[0]), nil
+}
+
+// builtinIsFrozen implements isFrozen(value), which reports whether value
+// is an Array, Object, Set, or instance that freeze returned.
+func builtinIsFrozen(args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, errors.New("isFrozen expects one value")
+	}
+	return NewBoolean(isFrozen(args[0])), nil
+}
+
+func isFrozen(val Value) bool {
+	switch v := val.(type) {
+	case *Array:
+		return v.frozen
+	case *Object:
+		return v.frozen
+	case *Set:
+		return v.frozen
+	case *StructInstance:
+		return v.frozen
+	case *ClassInstance:
+		return v.frozen
+	}
+	return false
+}
+
+func freeze(val Value) Value {
+	if isFrozen(val) {
+		return val
+	}
+	switch v := val.(type) {
+	case *Array:
+		elements := make([]Value, len(v.Elements))
+		for i, el := range v.Elements {
+			elements[i] = freeze(el)
+		}
+		return &Array{Elements: elements, frozen: true}
+	case *Object:
+		props := make([]Property, 0, len(v.Properties))
+		for _, key := range v.Keys() {
+			props = append(props, Property{Key: key, Value: freeze(v.Properties[key])})
+		}
+		obj := NewObject(props...)
+		obj.frozen = true
+		return obj
+	case *Set:
+		elements := v.Elements()
+		for i, el := range elements {
+			elements[i] = freeze(el)
+		}
+		s := NewSet(elements...)
+		s.frozen = true
+		return s
+	case *StructInstance:
+		return &StructInstance{Definition: v.Definition, Fields: freezeFields(v.Fields), frozen: true}
+	case *ClassInstance:
+		return &ClassInstance{Definition: v.Definition, Fields: freezeFields(v.Fields), frozen: true}
+	}
+	return val
+}
+
+func freezeFields(fields map[string]Value) map[string]Value {
+	frozen := make(map[string]Value, len(fields))
+	for name, val := range fields {
+		frozen[name] = freeze(val)
+	}
+	return frozen
+}
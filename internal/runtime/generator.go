@@ -0,0 +1,359 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+)
+
+// generatorBinding is the hidden environment slot through which yield
+// expressions locate the generator whose body they belong to. The parser only
+// accepts yield directly inside `fn name*()` bodies, so the innermost binding
+// is always the right one.
+const generatorBinding = "__generator__"
+
+// Generator is the lazy iterator produced by calling a generator function.
+// The body runs on its own goroutine, started by the first next(), but never
+// concurrently with its caller: next() hands control to the body and blocks
+// until it yields or returns. A started generator stays registered with the
+// runtime's resource tracker until its body returns or it is closed, so one
+// abandoned at a yield is reported and closed by Shutdown.
+type Generator struct {
+	mu      sync.Mutex
+	name    string
+	body    *ast.FunctionDeclaration
+	env     *Environment
+	started bool
+	done    bool
+	resume  chan generatorResume
+	events  chan generatorEvent
+}
+
+type generatorResume struct {
+	value Value
+	stop  bool
+}
+
+type generatorEvent struct {
+	value Value
+	done  bool
+	err   error
+}
+
+// generatorStop unwinds a suspended generator body when close() is called.
+type generatorStop struct{}
+
+// Error implements the error interface for generator stop signals.
+func (g *generatorStop) Error() string { return "generator closed" }
+
+func newGenerator(fn *Function, env *Environment) *Generator {
+	return &Generator{
+		name:   fn.Name,
+		body:   fn.Declaration,
+		env:    env,
+		resume: make(chan generatorResume),
+		events: make(chan generatorEvent),
+	}
+}
+
+// Type implements the Value interface for Generator.
+func (g *Generator) Type() string { return "Generator" }
+
+// Inspect returns a human-readable representation of Generator.
+func (g *Generator) Inspect() string {
+	b := borrowBuilder()
+	b.WriteString("<generator")
+	if g.name != "" {
+		b.WriteByte(' ')
+		b.WriteString(g.name)
+	}
+	b.WriteByte('>')
+	return finishBuilder(b)
+}
+
+// Next resumes the generator, passing sent as the result of the pending yield
+// expression. It reports the next yielded value, or done once the body has
+// returned (in which case the value is the function's return value).
+func (g *Generator) Next(sent Value) (Value, bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.done {
+		return NullValue, true, nil
+	}
+	if !g.started {
+		g.started = true
+		if tracker := g.tracker(); tracker != nil {
+			tracker.addGenerator(g, g.body.Pos())
+		}
+		go g.run()
+	} else {
+		if sent == nil {
+			sent = NullValue
+		}
+		g.resume <- generatorResume{value: sent}
+	}
+	ev := <-g.events
+	if ev.done {
+		g.finish()
+	}
+	return ev.value, ev.done, ev.err
+}
+
+// Close abandons a suspended generator, unwinding its body so finally blocks
+// run and the backing goroutine exits. Closing a finished generator is a no-op.
+func (g *Generator) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.closeLocked()
+}
+
+// release closes g for Shutdown unless a next() or close() is in progress.
+func (g *Generator) release() {
+	if !g.mu.TryLock() {
+		return
+	}
+	defer g.mu.Unlock()
+	_ = g.closeLocked()
+}
+
+func (g *Generator) closeLocked() error {
+	if g.done {
+		return nil
+	}
+	g.finish()
+	if !g.started {
+		return nil
+	}
+	g.resume <- generatorResume{stop: true}
+	ev := <-g.events
+	return ev.err
+}
+
+// finish marks g done and drops it from the resource tracker.
+func (g *Generator) finish() {
+	g.done = true
+	if tracker := g.tracker(); tracker != nil && g.started {
+		tracker.removeGenerator(g)
+	}
+}
+
+func (g *Generator) tracker() *resourceTracker {
+	if g.env.control == nil {
+		return nil
+	}
+	return g.env.control.tracker
+}
+
+func (g *Generator) run() {
+	var result Value = NullValue
+	var err error
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+		g.events <- generatorEvent{value: result, done: true, err: err}
+	}()
+	g.env.Set(generatorBinding, g)
+	if g.body.IsExprBody {
+		if g.body.BodyExpr != nil {
+			result, err = evalExpression(g.body.BodyExpr, g.env)
+		}
+	} else if g.body.Body != nil {
+		result, err = evalBlock(g.body.Body, g.env)
+	}
+	switch sig := err.(type) {
+	case nil:
+		result = NullValue
+	case *returnSignal:
+		result, err = sig.value, nil
+	case *generatorStop:
+		result, err = NullValue, nil
+	case *breakSignal:
+		result, err = nil, errors.New("break outside of loop")
+	case *continueSignal:
+		result, err = nil, errors.New("continue outside of loop")
+	}
+}
+
+// yield is called from the generator goroutine. It publishes value to the
+// consumer and blocks until the next resume.
+func (g *Generator) yield(value Value) (Value, error) {
+	g.events <- generatorEvent{value: value}
+	cmd := <-g.resume
+	if cmd.stop {
+		return nil, &generatorStop{}
+	}
+	return cmd.value, nil
+}
+
+func evalYieldExpression(node *ast.YieldExpression, env *Environment) (Value, error) {
+	var value Value = NullValue
+	if node.Value != nil {
+		val, err := evalExpression(node.Value, env)
+		if err != nil {
+			return nil, err
+		}
+		value = val
+	}
+	binding, ok := env.Get(generatorBinding)
+	if !ok {
+		return nil, errors.New("yield outside of generator")
+	}
+	gen, ok := binding.(*Generator)
+	if !ok {
+		return nil, errors.New("yield outside of generator")
+	}
+	return gen.yield(value)
+}
+
+func generatorProperty(gen *Generator, property string) (Value, bool, error) {
+	switch property {
+	case "next":
+		return newBuiltin("next", func(args []Value) (Value, error) {
+			if len(args) > 1 {
+				return nil, errors.New("next expects at most one argument")
+			}
+			var sent Value = NullValue
+			if len(args) == 1 {
+				sent = args[0]
+			}
+			value, done, err := gen.Next(sent)
+			if err != nil {
+				return nil, err
+			}
+			return NewObject(Property{"value", value}, Property{"done", NewBoolean(done)}), nil
+		}), true, nil
+	case "close":
+		return newBuiltin("close", func(args []Value) (Value, error) {
+			if len(args) != 0 {
+				return nil, errors.New("close takes no arguments")
+			}
+			if err := gen.Close(); err != nil {
+				return nil, err
+			}
+			return NullValue, nil
+		}), true, nil
+	default:
+		return nil, false, fmt.Errorf("unknown generator property %s", property)
+	}
+}
+
+// iterate calls visit for each element of an iterable value. Generators are
+// closed when iteration stops early so their goroutines do not linger.
+func iterate(iterable Value, visit func(Value) (bool, error)) error {
+	switch it := iterable.(type) {
+	case *Array:
+		for _, el := range it.Elements {
+			if cont, err := visit(el); err != nil || !cont {
+				return err
+			}
+		}
+		return nil
+	case *Range:
+		return it.each(visit)
+	case *Set:
+		for _, el := range it.Elements() {
+			if cont, err := visit(el); err != nil || !cont {
+				return err
+			}
+		}
+		return nil
+	case *Bytes:
+		for _, c := range it.Value {
+			if cont, err := visit(NewNumber(float64(c))); err != nil || !cont {
+				return err
+			}
+		}
+		return nil
+	case *Iterator:
+		for {
+			el, ok, err := it.next()
+			if err != nil || !ok {
+				return err
+			}
+			if cont, err := visit(el); err != nil || !cont {
+				return err
+			}
+		}
+	case *String:
+		for _, r := range it.Value {
+			if cont, err := visit(NewString(string(r))); err != nil || !cont {
+				return err
+			}
+		}
+		return nil
+	case *Generator:
+		for {
+			value, done, err := it.Next(NullValue)
+			if err != nil {
+				return err
+			}
+			if done {
+				return nil
+			}
+			cont, err := visit(value)
+			if err != nil || !cont {
+				if closeErr := it.Close(); err == nil {
+					err = closeErr
+				}
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot iterate over %s", iterable.Type())
+	}
+}
+
+func evalForInStatement(stmt *ast.ForInStatement, env *Environment) (Value, error) {
+	iterable, err := evalExpression(stmt.Iterable, env)
+	if err != nil {
+		return nil, err
+	}
+	result := NullValue
+	var exit error
+	err = iterate(iterable, func(item Value) (bool, error) {
+		if err := env.Err(); err != nil {
+			return false, err
+		}
+		loopEnv := newScope(env, stmt.Body)
+		loopEnv.Set(stmt.Binding.Name, item)
+		if stmt.Body == nil {
+			return true, nil
+		}
+		val, err := evalBlock(stmt.Body, loopEnv)
+		if err != nil {
+			switch sig := err.(type) {
+			case *breakSignal:
+				if !sig.targets(stmt.Label) {
+					exit = err
+				}
+				return false, nil
+			case *continueSignal:
+				if !sig.targets(stmt.Label) {
+					exit = err
+					return false, nil
+				}
+				return true, nil
+			case *returnSignal, *generatorStop:
+				exit = err
+				return false, nil
+			default:
+				return false, err
+			}
+		}
+		result = val
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sig, ok := exit.(*returnSignal); ok {
+		return sig.value, sig
+	}
+	if exit != nil {
+		return nil, exit
+	}
+	return result, nil
+}
@@ -0,0 +1,380 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileSystem is the file access used by the fs builtin module. Embedders and
+// test harnesses can replace it to keep scripts away from the host disk.
+type FileSystem interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte) error
+	Exists(name string) (bool, error)
+}
+
+// Clock supplies the current time and sleeping to the time builtin module.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// HostEnv is the process state the os, fs, and path modules see:
+// environment variables and the working directory. Embedders and test
+// harnesses can replace it to keep scripts from reading or changing the
+// host's. Processes started by os.exec inherit it.
+type HostEnv interface {
+	LookupEnv(name string) (string, bool)
+	Setenv(name, value string) error
+	// Environ returns the variables as "name=value" strings.
+	Environ() []string
+	Getwd() (string, error)
+	Chdir(dir string) error
+}
+
+type osFileSystem struct{}
+
+func (osFileSystem) ReadFile(name string) ([]byte, error) {
+	// #nosec G304 -- scripts choose their own files; sandboxed runtimes use a virtual filesystem.
+	return os.ReadFile(name)
+}
+
+func (osFileSystem) WriteFile(name string, data []byte) error {
+	return os.WriteFile(name, data, 0o600)
+}
+
+func (osFileSystem) Exists(name string) (bool, error) {
+	if _, err := os.Stat(name); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+type osHostEnv struct{}
+
+func (osHostEnv) LookupEnv(name string) (string, bool) { return os.LookupEnv(name) }
+func (osHostEnv) Setenv(name, value string) error      { return os.Setenv(name, value) }
+func (osHostEnv) Environ() []string                    { return os.Environ() }
+func (osHostEnv) Getwd() (string, error)               { return os.Getwd() }
+func (osHostEnv) Chdir(dir string) error               { return os.Chdir(dir) }
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time        { return time.Now() }
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// MemoryFileSystem is an in-memory FileSystem. Paths are cleaned with
+// forward slashes, so "a/../b.txt" and "b.txt" name the same file.
+type MemoryFileSystem struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+// NewMemoryFileSystem returns a virtual filesystem seeded with files.
+func NewMemoryFileSystem(files map[string]string) *MemoryFileSystem {
+	m := &MemoryFileSystem{files: make(map[string][]byte, len(files))}
+	for name, contents := range files {
+		m.files[path.Clean(name)] = []byte(contents)
+	}
+	return m
+}
+
+// ReadFile returns the contents of name or an fs.ErrNotExist error.
+func (m *MemoryFileSystem) ReadFile(name string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.files[path.Clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// WriteFile stores data under name, replacing any previous contents.
+func (m *MemoryFileSystem) WriteFile(name string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[path.Clean(name)] = append([]byte(nil), data...)
+	return nil
+}
+
+// Exists reports whether name has been written or seeded.
+func (m *MemoryFileSystem) Exists(name string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.files[path.Clean(name)]
+	return ok, nil
+}
+
+// Files lists the stored paths in lexical order.
+func (m *MemoryFileSystem) Files() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.files))
+	for name := range m.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MemoryHostEnv is a HostEnv that keeps its variables and working directory
+// to itself. Chdir moves it to any directory without checking that the
+// directory exists.
+type MemoryHostEnv struct {
+	mu   sync.RWMutex
+	vars map[string]string
+	dir  string
+}
+
+// NewMemoryHostEnv returns a HostEnv with the variables vars and the working
+// directory dir, which should be absolute.
+func NewMemoryHostEnv(vars map[string]string, dir string) *MemoryHostEnv {
+	m := &MemoryHostEnv{vars: make(map[string]string, len(vars)), dir: filepath.Clean(dir)}
+	for name, value := range vars {
+		m.vars[name] = value
+	}
+	return m
+}
+
+// LookupEnv returns the value of the variable name, if it is set.
+func (m *MemoryHostEnv) LookupEnv(name string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	value, ok := m.vars[name]
+	return value, ok
+}
+
+// Setenv sets the variable name to value.
+func (m *MemoryHostEnv) Setenv(name, value string) error {
+	m.mu.Lock()
+	m.vars[name] = value
+	m.mu.Unlock()
+	return nil
+}
+
+// Environ returns the variables as "name=value" strings sorted by name.
+func (m *MemoryHostEnv) Environ() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	vars := make([]string, 0, len(m.vars))
+	for name, value := range m.vars {
+		vars = append(vars, name+"="+value)
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+// Getwd returns the working directory.
+func (m *MemoryHostEnv) Getwd() (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.dir, nil
+}
+
+// Chdir changes the working directory to dir, relative to the current one.
+func (m *MemoryHostEnv) Chdir(dir string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(m.dir, dir)
+	}
+	m.dir = filepath.Clean(dir)
+	return nil
+}
+
+// FixedClock is a Clock that only moves when Sleep or Advance is called.
+type FixedClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFixedClock returns a clock frozen at start.
+func NewFixedClock(start time.Time) *FixedClock {
+	return &FixedClock{now: start}
+}
+
+// Now returns the clock's current reading.
+func (c *FixedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Sleep advances the clock by d without blocking.
+func (c *FixedClock) Sleep(d time.Duration) { c.Advance(d) }
+
+// Advance moves the clock forward by d.
+func (c *FixedClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// SetFileSystem replaces the filesystem used by the fs module.
+func (r *Runtime) SetFileSystem(fsys FileSystem) {
+	if fsys == nil {
+		fsys = osFileSystem{}
+	}
+	r.fs = fsys
+}
+
+// SetHostEnv replaces the environment variables and working directory used
+// by the os module and path.abs. Relative fs paths and the processes os.exec
+// starts are resolved against the working directory of env, and those
+// processes see only its variables.
+func (r *Runtime) SetHostEnv(env HostEnv) {
+	if env == nil {
+		env = osHostEnv{}
+	}
+	r.hostEnv = env
+}
+
+// resolvePath makes a relative fs path relative to the working directory of
+// an injected HostEnv. With the process's own environment the OS resolves it
+// already, so name is left as the script wrote it.
+func (r *Runtime) resolvePath(name string) (string, error) {
+	if _, ok := r.hostEnv.(osHostEnv); ok || filepath.IsAbs(name) {
+		return name, nil
+	}
+	wd, err := r.hostEnv.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(wd, name), nil
+}
+
+// SetClock replaces the clock used by the time module.
+func (r *Runtime) SetClock(clock Clock) {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	r.clock = clock
+}
+
+func newFSModule(r *Runtime) *Module {
+	return NewModule("fs", map[string]Value{
+		"read": newBuiltin("read", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, errors.New("fs.read expects a path")
+			}
+			name, err := stringArg("fs.read", args[0])
+			if err != nil {
+				return nil, err
+			}
+			if name, err = r.resolvePath(name); err != nil {
+				return nil, err
+			}
+			if err := r.checkPath("fs.read", name); err != nil {
+				return nil, err
+			}
+			data, err := r.fs.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			return NewString(string(data)), nil
+		}),
+		"readBytes": newBuiltin("readBytes", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, errors.New("fs.readBytes expects a path")
+			}
+			name, err := stringArg("fs.readBytes", args[0])
+			if err != nil {
+				return nil, err
+			}
+			if name, err = r.resolvePath(name); err != nil {
+				return nil, err
+			}
+			if err := r.checkPath("fs.readBytes", name); err != nil {
+				return nil, err
+			}
+			data, err := r.fs.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			return NewBytes(data), nil
+		}),
+		"write": newBuiltin("write", func(args []Value) (Value, error) {
+			if len(args) != 2 {
+				return nil, errors.New("fs.write expects a path and contents")
+			}
+			if r.sandboxed {
+				return nil, errors.New("fs.write is disabled in sandboxed mode")
+			}
+			name, err := stringArg("fs.write", args[0])
+			if err != nil {
+				return nil, err
+			}
+			if name, err = r.resolvePath(name); err != nil {
+				return nil, err
+			}
+			if err := r.checkPath("fs.write", name); err != nil {
+				return nil, err
+			}
+			contents := []byte(toString(args[1]))
+			if data, ok := args[1].(*Bytes); ok {
+				contents = data.Value
+			}
+			if err := r.fs.WriteFile(name, contents); err != nil {
+				return nil, err
+			}
+			return NullValue, nil
+		}),
+		"tempFile": r.tempBuiltin("tempFile", false),
+		"tempDir":  r.tempBuiltin("tempDir", true),
+		"exists": newBuiltin("exists", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, errors.New("fs.exists expects a path")
+			}
+			name, err := stringArg("fs.exists", args[0])
+			if err != nil {
+				return nil, err
+			}
+			if name, err = r.resolvePath(name); err != nil {
+				return nil, err
+			}
+			if err := r.checkPath("fs.exists", name); err != nil {
+				return nil, err
+			}
+			ok, err := r.fs.Exists(name)
+			if err != nil {
+				return nil, err
+			}
+			return NewBoolean(ok), nil
+		}),
+	})
+}
+
+func newTimeModule(r *Runtime) *Module {
+	return NewModule("time", map[string]Value{
+		"now": newBuiltin("now", func(args []Value) (Value, error) {
+			if len(args) != 0 {
+				return nil, errors.New("time.now takes no arguments")
+			}
+			return NewNumber(float64(r.clock.Now().UnixMilli())), nil
+		}),
+		"sleep": newBuiltin("sleep", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, errors.New("time.sleep expects a duration in milliseconds")
+			}
+			num, ok := args[0].(*Number)
+			if !ok || num.Value < 0 {
+				return nil, fmt.Errorf("time.sleep expects a non-negative Number, got %s", args[0].Inspect())
+			}
+			if err := r.control.sleep(r.clock, time.Duration(num.Value*float64(time.Millisecond))); err != nil {
+				return nil, err
+			}
+			return NullValue, nil
+		}),
+	})
+}
@@ -0,0 +1,30 @@
+package runtime
+
+// Importer resolves import paths from outside the environment, such as
+// modules loaded from project source files. Import reports found == false
+// for paths it does not handle, which are then looked up in the environment
+// as usual.
+type Importer interface {
+	Import(path []string) (val Value, found bool, err error)
+}
+
+// SetImporter installs imp for every import the program evaluates. It is
+// consulted before the environment, so project modules are found even when a
+// vendored module shares the first segments of their path. Pass nil to
+// resolve imports from the environment alone.
+func (r *Runtime) SetImporter(imp Importer) {
+	r.control.importer = imp
+}
+
+func resolveImport(path []string, env *Environment) (Value, error) {
+	if env.control != nil && env.control.importer != nil {
+		val, found, err := env.control.importer.Import(path)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			return val, nil
+		}
+	}
+	return resolveImportPath(path, env)
+}
@@ -0,0 +1,176 @@
+package runtime
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+)
+
+// ModuleFile is one source file of a module spread across several files.
+type ModuleFile struct {
+	// Name identifies the file in errors and breaks ordering ties, so it
+	// should be stable, such as the path relative to the module root.
+	Name    string
+	Program *ast.Program
+}
+
+// InitOrder returns the order in which the top levels of files run. A file
+// that imports a module declared at the top level of another file runs after
+// that file; files with no such dependency between them run in Name order.
+// Import cycles between files are reported as errors.
+func InitOrder(files []ModuleFile) ([]ModuleFile, error) {
+	sorted := slices.Clone(files)
+	slices.SortStableFunc(sorted, func(a, b ModuleFile) int { return strings.Compare(a.Name, b.Name) })
+	providers := make(map[string][]int)
+	for i, file := range sorted {
+		for _, name := range declaredModules(file.Program) {
+			providers[name] = append(providers[name], i)
+		}
+	}
+	deps := make([][]int, len(sorted))
+	for i, file := range sorted {
+		for _, name := range importedRoots(file.Program) {
+			for _, p := range providers[name] {
+				if p != i && !slices.Contains(deps[i], p) {
+					deps[i] = append(deps[i], p)
+				}
+			}
+		}
+	}
+	done := make([]bool, len(sorted))
+	order := make([]ModuleFile, 0, len(sorted))
+	for len(order) < len(sorted) {
+		next := -1
+		for i := range sorted {
+			if done[i] {
+				continue
+			}
+			ready := true
+			for _, d := range deps[i] {
+				if !done[d] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			return nil, fmt.Errorf("import cycle between files: %s", describeCycle(sorted, deps, done))
+		}
+		done[next] = true
+		order = append(order, sorted[next])
+	}
+	return order, nil
+}
+
+// describeCycle follows unfinished dependencies from the first unfinished
+// file until a file repeats. Every unfinished file waits on another one, so
+// the walk always closes a cycle.
+func describeCycle(files []ModuleFile, deps [][]int, done []bool) string {
+	start := slices.Index(done, false)
+	seen := map[int]int{}
+	var path []int
+	for at := start; ; {
+		if pos, ok := seen[at]; ok {
+			path = append(path[pos:], at)
+			break
+		}
+		seen[at] = len(path)
+		path = append(path, at)
+		for _, d := range deps[at] {
+			if !done[d] {
+				at = d
+				break
+			}
+		}
+	}
+	names := make([]string, len(path))
+	for i, idx := range path {
+		names[i] = files[idx].Name
+	}
+	return strings.Join(names, " -> ")
+}
+
+func declaredModules(program *ast.Program) []string {
+	var names []string
+	if program == nil {
+		return names
+	}
+	for _, item := range program.Items {
+		if module, ok := item.(*ast.ModuleDeclaration); ok && module.Name != nil {
+			names = append(names, module.Name.Name)
+		}
+	}
+	return names
+}
+
+func importedRoots(program *ast.Program) []string {
+	var names []string
+	if program == nil {
+		return names
+	}
+	for _, item := range program.Items {
+		if imp, ok := item.(*ast.ImportDeclaration); ok {
+			if segments := importSegments(imp); len(segments) > 0 {
+				names = append(names, segments[0])
+			}
+		}
+	}
+	return names
+}
+
+// RunModule evaluates files as a single module in the runtime's global
+// environment. The top level of every file runs in InitOrder, and then the
+// fn init() declared by each file runs once, in the same order, so init sees
+// every declaration of the module. init functions are removed from the
+// environment afterwards and are never exported. main is not invoked.
+func (r *Runtime) RunModule(files []ModuleFile) error {
+	return runModuleIn(r.env, files)
+}
+
+// LoadModule evaluates files as a module named name in a fresh scope
+// enclosed by the global environment, exactly as RunModule would, and returns
+// what they declared as the module's exports, as a module declaration does.
+func (r *Runtime) LoadModule(name string, files []ModuleFile) (*Module, error) {
+	env := NewEnclosedEnvironment(r.env)
+	if err := runModuleIn(env, files); err != nil {
+		return nil, err
+	}
+	exports := cloneStore(env.store)
+	delete(exports, "__package__")
+	return NewModule(name, exports), nil
+}
+
+func runModuleIn(env *Environment, files []ModuleFile) error {
+	order, err := InitOrder(files)
+	if err != nil {
+		return err
+	}
+	var inits []*Function
+	var owners []string
+	for _, file := range order {
+		delete(env.store, "init")
+		if _, err := evalProgram(file.Program, env); err != nil {
+			return fmt.Errorf("%s: %w", file.Name, err)
+		}
+		if !AnalyzeMain(file.Program).HasInitFunction {
+			continue
+		}
+		if fn, ok := env.store["init"].(*Function); ok {
+			inits = append(inits, fn)
+			owners = append(owners, file.Name)
+		}
+	}
+	delete(env.store, "init")
+	for i, fn := range inits {
+		if _, err := applyFunction(fn, nil); err != nil {
+			return fmt.Errorf("%s: init: %w", owners[i], err)
+		}
+	}
+	return nil
+}
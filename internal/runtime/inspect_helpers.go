@@ -2,12 +2,77 @@ package runtime
 
 import (
 	"cmp"
+	"errors"
+	"fmt"
 	"maps"
 	"slices"
 	"strings"
 	"sync"
 )
 
+// fieldOrder lists the fields of an instance: the names in order that values
+// holds, in that order, followed by any fields order does not name, sorted.
+func fieldOrder[V any](order []string, values map[string]V) []string {
+	names := make([]string, 0, len(values))
+	for _, name := range order {
+		if _, ok := values[name]; ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == len(values) {
+		return names
+	}
+	var extra []string
+	for name := range values {
+		if !slices.Contains(order, name) {
+			extra = append(extra, name)
+		}
+	}
+	slices.Sort(extra)
+	return append(names, extra...)
+}
+
+// customDisplay renders a struct or class instance through the toString
+// method its type defines. ok is false for values without one.
+func customDisplay(val Value) (text string, ok bool, err error) {
+	var method *Function
+	switch v := val.(type) {
+	case *StructInstance:
+		if v.Definition != nil {
+			method = v.Definition.Methods["toString"]
+		}
+	case *ClassInstance:
+		method, _ = v.Definition.lookupMethod("toString")
+	}
+	if method == nil {
+		return "", false, nil
+	}
+	result, err := applyFunction(bindMethod(method, val), nil)
+	if errors.Is(err, errCallDepth) {
+		// A toString that displays itself would otherwise repeat the prefix
+		// once for every level of the recursion.
+		return "", true, err
+	}
+	if err != nil {
+		return "", true, fmt.Errorf("%s.toString: %w", val.Type(), err)
+	}
+	str, isString := result.(*String)
+	if !isString {
+		return "", true, fmt.Errorf("%s.toString must return a String, got %s", val.Type(), result.Type())
+	}
+	return str.Value, true, nil
+}
+
+// display renders val for print and string interpolation: through its
+// toString method when it has one, so a failing toString is reported, and
+// with Inspect otherwise.
+func display(val Value) (string, error) {
+	if text, ok, err := customDisplay(val); ok {
+		return text, err
+	}
+	return val.Inspect(), nil
+}
+
 const builderMaxReuse = 1 << 12
 
 var builderPool = sync.Pool{New: func() any {
@@ -43,6 +108,11 @@ func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
 }
 
 func inspectFields(name string, fields map[string]Value) string {
+	return inspectOrderedFields(name, sortedKeys(fields), fields)
+}
+
+// inspectOrderedFields renders fields in the order of keys.
+func inspectOrderedFields(name string, keys []string, fields map[string]Value) string {
 	if len(fields) == 0 {
 		if name == "" {
 			return "{}"
@@ -50,8 +120,6 @@ func inspectFields(name string, fields map[string]Value) string {
 		return name + "{}"
 	}
 
-	keys := sortedKeys(fields)
-
 	b := borrowBuilder()
 	if name != "" {
 		b.WriteString(name)
@@ -0,0 +1,216 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+)
+
+// implTargets are the builtin types an impl block may extend. Their methods
+// live in the extension registry, like those of ext fn declarations.
+var implTargets = []string{"Number", "String", "Boolean", "Array"}
+
+func evalInterfaceDeclaration(node *ast.InterfaceDeclaration, env *Environment) (Value, error) {
+	iface := &InterfaceType{Name: node.Name.Name, Methods: make(map[string]int)}
+	for _, method := range node.Methods {
+		if method.Name == nil {
+			continue
+		}
+		iface.Methods[method.Name.Name] = len(method.Params)
+		if method.Default != nil {
+			if iface.Defaults == nil {
+				iface.Defaults = make(map[string]*Function)
+			}
+			iface.Defaults[method.Name.Name] = &Function{Declaration: method.Default, Env: env, Name: method.Name.Name}
+		}
+	}
+	env.Set(iface.Name, iface)
+	return iface, nil
+}
+
+// implTarget is the type an impl block adds methods to.
+type implTarget struct {
+	name string
+	key  any
+	// has reports whether the type already has a method, and define adds one.
+	has    func(name string) bool
+	define func(name string, fn *Function)
+	// shared marks builtin types, whose methods are process-wide like those
+	// of ext fn declarations: an impl replaces methods defined earlier, and
+	// installs every default it does not override.
+	shared bool
+}
+
+func resolveImplTarget(id *ast.Identifier, env *Environment) (implTarget, error) {
+	val, ok := env.Get(id.Name)
+	if !ok {
+		name := normalizeTypeName(id.Name)
+		if !slices.Contains(implTargets, name) {
+			return implTarget{}, fmt.Errorf("unknown type %s", id.Name)
+		}
+		return implTarget{
+			name: name,
+			key:  name,
+			has: func(method string) bool {
+				_, ok := lookupExtension(name, method)
+				return ok
+			},
+			define: func(method string, fn *Function) { registerExtension(name, method, fn) },
+			shared: true,
+		}, nil
+	}
+	switch def := val.(type) {
+	case *StructType:
+		return implTarget{
+			name: def.Name,
+			key:  def,
+			has: func(method string) bool {
+				_, ok := def.Methods[method]
+				return ok
+			},
+			define: func(method string, fn *Function) { def.Methods[method] = fn },
+		}, nil
+	case *ClassType:
+		return implTarget{
+			name: def.Name,
+			key:  def,
+			has: func(method string) bool {
+				_, ok := def.lookupMethod(method)
+				return ok
+			},
+			define: func(method string, fn *Function) { def.Methods[method] = fn },
+		}, nil
+	case *EnumType:
+		if def == resultType || def == optionType {
+			return implTarget{}, fmt.Errorf("cannot implement interfaces for the prelude enum %s", def.Name)
+		}
+		return implTarget{
+			name: def.Name,
+			key:  def,
+			has: func(method string) bool {
+				_, ok := def.Methods[method]
+				return ok
+			},
+			define: func(method string, fn *Function) {
+				if def.Methods == nil {
+					def.Methods = make(map[string]*Function)
+				}
+				def.Methods[method] = fn
+			},
+		}, nil
+	default:
+		return implTarget{}, fmt.Errorf("%s is not a type", id.Name)
+	}
+}
+
+// evalImplDeclaration adds the methods of an impl block, and the defaults of
+// the interface methods it leaves out, to the target type, then records that
+// the type implements the interface. Every check runs before the type is
+// changed, so a rejected impl leaves it as it was.
+func evalImplDeclaration(decl *ast.ImplDeclaration, env *Environment) (Value, error) {
+	if decl.Interface == nil || decl.Target == nil {
+		return nil, errors.New("impl declaration requires an interface and a type")
+	}
+	val, ok := env.Get(decl.Interface.Name)
+	if !ok {
+		return nil, fmt.Errorf("unknown interface %s", decl.Interface.Name)
+	}
+	iface, ok := val.(*InterfaceType)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an interface", decl.Interface.Name)
+	}
+	target, err := resolveImplTarget(decl.Target, env)
+	if err != nil {
+		return nil, err
+	}
+	if iface.implementedBy(target.key) {
+		return nil, fmt.Errorf("%s already implements %s", target.name, iface.Name)
+	}
+
+	bodyEnv := NewEnclosedEnvironment(env)
+	if decl.Body != nil {
+		if _, err := evalBlock(decl.Body, bodyEnv); err != nil {
+			switch err.(type) {
+			case *returnSignal:
+				return nil, errors.New("return not allowed in impl body")
+			case *breakSignal, *continueSignal:
+				return nil, errors.New("loop control not allowed in impl body")
+			default:
+				return nil, err
+			}
+		}
+	}
+	methods := make(map[string]*Function, len(iface.Methods))
+	for name, val := range bodyEnv.store {
+		fn, ok := val.(*Function)
+		if !ok {
+			return nil, fmt.Errorf("impl %s for %s may only define methods, got %s", iface.Name, target.name, name)
+		}
+		arity, ok := iface.Methods[name]
+		if !ok {
+			return nil, fmt.Errorf("%s is not a method of interface %s", name, iface.Name)
+		}
+		if len(fn.Declaration.Params) != arity {
+			return nil, fmt.Errorf("%s.%s takes %d parameters, but interface %s declares %d", target.name, name, len(fn.Declaration.Params), iface.Name, arity)
+		}
+		if !target.shared && target.has(name) {
+			return nil, fmt.Errorf("%s already defines method %s", target.name, name)
+		}
+		methods[name] = fn
+	}
+	for _, name := range sortedKeys(iface.Methods) {
+		if _, ok := methods[name]; ok {
+			continue
+		}
+		if def, ok := iface.Defaults[name]; ok && (target.shared || !target.has(name)) {
+			methods[name] = def
+			continue
+		}
+		if !target.has(name) {
+			return nil, fmt.Errorf("impl %s for %s is missing method %s", iface.Name, target.name, name)
+		}
+	}
+
+	for name, fn := range methods {
+		target.define(name, fn)
+	}
+	iface.addImpl(target.key)
+	return NullValue, nil
+}
+
+func (i *InterfaceType) addImpl(key any) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.impls == nil {
+		i.impls = make(map[any]bool)
+	}
+	i.impls[key] = true
+}
+
+func (i *InterfaceType) implementedBy(key any) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.impls[key]
+}
+
+// hasImpl reports whether an impl block declared that the type of val
+// implements i. A class also implements the interfaces of its superclasses.
+func (i *InterfaceType) hasImpl(val Value) bool {
+	switch v := val.(type) {
+	case *StructInstance:
+		return i.implementedBy(v.Definition)
+	case *ClassInstance:
+		for class := v.Definition; class != nil; class = class.Super {
+			if i.implementedBy(class) {
+				return true
+			}
+		}
+		return false
+	case *EnumInstance:
+		return i.implementedBy(v.Enum)
+	default:
+		return i.implementedBy(normalizeTypeName(val.Type()))
+	}
+}
@@ -0,0 +1,96 @@
+package runtime
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+)
+
+// Values are immutable, so the runtime hands out shared instances for the
+// ones programs produce constantly: small integers such as loop counters and
+// indices, the empty string, and the one-byte strings that iterating or
+// indexing ASCII text yields. The tables are shared by every runtime.
+const (
+	smallIntMin = -128
+	smallIntMax = 1023
+)
+
+var smallInts = func() (table [smallIntMax - smallIntMin + 1]Number) {
+	for i := range table {
+		table[i].Value = float64(i + smallIntMin)
+	}
+	return table
+}()
+
+var byteStrings = func() (table [utf8.RuneSelf]String) {
+	for i := range table {
+		table[i].Value = string(rune(i))
+	}
+	return table
+}()
+
+var emptyString = &String{}
+
+// NewNumber wraps a Go float64 as a Selene number.
+func NewNumber(v float64) Value {
+	if v >= smallIntMin && v <= smallIntMax && v == math.Trunc(v) && (v != 0 || !math.Signbit(v)) {
+		return &smallInts[int(v)-smallIntMin]
+	}
+	return &Number{Value: v}
+}
+
+// NewString wraps a Go string as a Selene string.
+func NewString(v string) Value {
+	switch {
+	case v == "":
+		return emptyString
+	case len(v) == 1 && v[0] < utf8.RuneSelf:
+		return &byteStrings[v[0]]
+	}
+	return &String{Value: v}
+}
+
+// literalValue returns the value of a number literal, or of a string literal
+// without interpolation, parsing it on first use and reusing that value on
+// later evaluations. String literals with the same text share one value.
+// Literals are cached per runtime, keyed by their AST node.
+func (c *runControl) literalValue(lit ast.Expression) (Value, error) {
+	if c != nil {
+		if val, ok := c.literals.Load(lit); ok {
+			return val.(Value), nil
+		}
+	}
+	var val Value
+	switch lit := lit.(type) {
+	case *ast.NumberLiteral:
+		num, err := strconv.ParseFloat(lit.Value, 64)
+		if err != nil {
+			return nil, err
+		}
+		val = NewNumber(num)
+	case *ast.StringLiteral:
+		text, err := processStringChunk(lit.Value, lit.Raw)
+		if err != nil {
+			return nil, err
+		}
+		val = NewString(text)
+		if c != nil {
+			if shared, loaded := c.strings.LoadOrStore(text, val); loaded {
+				val = shared.(Value)
+			}
+		}
+	}
+	if c != nil {
+		c.literals.Store(lit, val)
+	}
+	return val, nil
+}
+
+// isConstantString reports whether lit has no interpolation, so that every
+// evaluation yields the same string.
+func isConstantString(lit *ast.StringLiteral) bool {
+	return !strings.Contains(lit.Value, "${")
+}
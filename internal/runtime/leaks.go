@@ -0,0 +1,315 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cybellereaper/selenelang/internal/token"
+)
+
+// Leak describes a task, channel, suspended generator, or temporary path that
+// was still live when a program finished and Shutdown gave up waiting for it.
+type Leak struct {
+	// Kind is "task", "channel", "generator", "temp file", or "temp
+	// directory".
+	Kind string
+	// Site is the source position of the spawn, channel, or temp call, or of
+	// the generator function's declaration, plus the spawned function for
+	// tasks, the generator, and the path for temporaries. It starts with the
+	// file name when the program was parsed from a named file. A task spawned
+	// indirectly, by a builtin calling spawn, is placed at the declaration of
+	// the function it runs.
+	Site   string
+	Detail string
+	pos    token.Position
+}
+
+// String renders the leak for diagnostics.
+func (l Leak) String() string {
+	return fmt.Sprintf("%s created at %s %s", l.Kind, l.Site, l.Detail)
+}
+
+// LeakError reports the leaks found by Shutdown when a host chooses to fail
+// the run because of them.
+type LeakError struct {
+	Leaks []Leak
+}
+
+// Error implements the error interface for LeakError.
+func (e *LeakError) Error() string {
+	if len(e.Leaks) == 1 {
+		return "program leaked a " + e.Leaks[0].String()
+	}
+	return fmt.Sprintf("program leaked %d tasks or channels", len(e.Leaks))
+}
+
+// resourceTracker records the tasks, channels, and temporary paths a program
+// creates through spawn(), channel(), fs.tempFile(), and fs.tempDir(), and
+// the generators it has started, so Shutdown can report the ones left behind.
+type resourceTracker struct {
+	mu       sync.Mutex
+	nextID   int
+	tasks    map[int]Leak
+	channels map[*ChannelValue]Leak
+	temps    map[string]Leak
+	// generators holds the generators whose body has started and has not
+	// yet returned or been closed.
+	generators map[*Generator]Leak
+	// unjoined holds the tasks spawn() started whose outcome nothing has
+	// waited for yet, running or not.
+	unjoined map[*Task]Leak
+}
+
+func newResourceTracker() *resourceTracker {
+	return &resourceTracker{tasks: make(map[int]Leak), channels: make(map[*ChannelValue]Leak), temps: make(map[string]Leak), generators: make(map[*Generator]Leak), unjoined: make(map[*Task]Leak)}
+}
+
+func (t *resourceTracker) addTask(site token.Position, fn Value) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextID++
+	t.tasks[t.nextID] = taskLeak(site, fn)
+	return t.nextID
+}
+
+func (t *resourceTracker) removeTask(id int) {
+	t.mu.Lock()
+	delete(t.tasks, id)
+	t.mu.Unlock()
+}
+
+func (t *resourceTracker) addUnjoined(task *Task, site token.Position, fn Value) {
+	t.mu.Lock()
+	t.unjoined[task] = taskLeak(site, fn)
+	t.mu.Unlock()
+}
+
+// taskLeak describes the task spawned at site to run fn. Indirect spawns
+// have no site, so they fall back to the declaration of fn where it has one.
+func taskLeak(site token.Position, fn Value) Leak {
+	label := fn.Inspect()
+	if site.Line == 0 {
+		if f, ok := fn.(*Function); ok && f.Declaration != nil {
+			site, label = f.Declaration.Pos(), label+", spawned indirectly"
+		}
+	}
+	return Leak{Kind: "task", Site: formatSite(site) + " (" + label + ")", pos: site}
+}
+
+// joinTask records that something waited for task's outcome.
+func (t *resourceTracker) joinTask(task *Task) {
+	t.mu.Lock()
+	delete(t.unjoined, task)
+	t.mu.Unlock()
+}
+
+func (t *resourceTracker) running() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.tasks)
+}
+
+func (t *resourceTracker) addChannel(ch *ChannelValue, site token.Position) {
+	t.mu.Lock()
+	t.channels[ch] = Leak{Kind: "channel", Site: formatSite(site), pos: site}
+	t.mu.Unlock()
+}
+
+func (t *resourceTracker) removeChannel(ch *ChannelValue) {
+	t.mu.Lock()
+	delete(t.channels, ch)
+	t.mu.Unlock()
+}
+
+func (t *resourceTracker) addGenerator(g *Generator, site token.Position) {
+	t.mu.Lock()
+	t.generators[g] = Leak{Kind: "generator", Site: formatSite(site) + " (" + g.Inspect() + ")", pos: site}
+	t.mu.Unlock()
+}
+
+func (t *resourceTracker) removeGenerator(g *Generator) {
+	t.mu.Lock()
+	delete(t.generators, g)
+	t.mu.Unlock()
+}
+
+// closeGenerators closes the generators left suspended so their goroutines
+// exit. Generators another task is resuming at the moment are left alone.
+func (t *resourceTracker) closeGenerators() {
+	t.mu.Lock()
+	gens := make([]*Generator, 0, len(t.generators))
+	for g := range t.generators {
+		gens = append(gens, g)
+	}
+	t.mu.Unlock()
+	for _, g := range gens {
+		g.release()
+	}
+}
+
+func (t *resourceTracker) addTemp(name, kind string, site token.Position) {
+	t.mu.Lock()
+	t.temps[name] = Leak{Kind: kind, Site: formatSite(site) + " (" + name + ")", pos: site}
+	t.mu.Unlock()
+}
+
+// removeTemp deletes a tracked temporary path. Paths that are no longer
+// tracked were already removed, so closing twice is harmless.
+func (t *resourceTracker) removeTemp(name string) error {
+	t.mu.Lock()
+	_, ok := t.temps[name]
+	delete(t.temps, name)
+	t.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return os.RemoveAll(name)
+}
+
+func (t *resourceTracker) removeTemps() {
+	t.mu.Lock()
+	names := make([]string, 0, len(t.temps))
+	for name := range t.temps {
+		names = append(names, name)
+	}
+	t.mu.Unlock()
+	for _, name := range names {
+		_ = t.removeTemp(name)
+	}
+}
+
+// leaks lists running tasks, open channels that still hold values or have
+// blocked senders or receivers, and suspended generators, ordered by kind and
+// site. Open channels that
+// are merely unreferenced are not leaks; the collector reclaims them.
+func (t *resourceTracker) leaks() []Leak {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var leaks []Leak
+	for _, leak := range t.tasks {
+		leak.Detail = "is still running"
+		leaks = append(leaks, leak)
+	}
+	for ch, leak := range t.channels {
+		buffered, blocked := len(ch.ch), ch.blocked.Load()
+		if buffered == 0 && blocked == 0 {
+			continue
+		}
+		leak.Detail = fmt.Sprintf("was never closed (%d unreceived value(s), %d blocked task(s))", buffered, blocked)
+		leaks = append(leaks, leak)
+	}
+	for _, leak := range t.generators {
+		leak.Detail = "was never finished or closed and has been closed"
+		leaks = append(leaks, leak)
+	}
+	for _, leak := range t.temps {
+		leak.Detail = "was never closed and has been removed"
+		leaks = append(leaks, leak)
+	}
+	sortLeaks(leaks)
+	return leaks
+}
+
+// unreleased lists every unjoined task and unclosed channel, live or not.
+func (t *resourceTracker) unreleased() []Leak {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var leaks []Leak
+	for _, leak := range t.unjoined {
+		leak.Detail = "was never joined"
+		leaks = append(leaks, leak)
+	}
+	for _, leak := range t.channels {
+		leak.Detail = "was never closed"
+		leaks = append(leaks, leak)
+	}
+	sortLeaks(leaks)
+	return leaks
+}
+
+// sortLeaks orders leaks by kind and then by site.
+func sortLeaks(leaks []Leak) {
+	sort.Slice(leaks, func(i, j int) bool {
+		if leaks[i].Kind != leaks[j].Kind {
+			return leaks[i].Kind > leaks[j].Kind
+		}
+		if leaks[i].pos.File != leaks[j].pos.File {
+			return leaks[i].pos.File < leaks[j].pos.File
+		}
+		if leaks[i].pos.Offset != leaks[j].pos.Offset {
+			return leaks[i].pos.Offset < leaks[j].pos.Offset
+		}
+		return leaks[i].Site < leaks[j].Site
+	})
+}
+
+// Shutdown waits up to timeout for tasks started with spawn() to finish and
+// returns the tasks and channels that are still live. Generators left
+// suspended at a yield are reported and closed, running their finally
+// blocks, and temporary files and directories the program never closed are
+// removed and reported too. Hosts
+// call it after Run, RunChunk, or a JIT run returns; a zero timeout reports
+// without waiting.
+func (r *Runtime) Shutdown(timeout time.Duration) []Leak {
+	deadline := time.Now().Add(timeout)
+	for r.tracker.running() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	leaks := r.tracker.leaks()
+	r.tracker.closeGenerators()
+	r.tracker.removeTemps()
+	return leaks
+}
+
+// Unreleased returns the tasks started with spawn() whose outcome nothing
+// waited for, through join, await, a continuation, or a tasks combinator,
+// and the channels that were never closed. Unlike Shutdown it reports them
+// whether or not they are still live, which makes it a stricter check for
+// test runners than for programs, where a finished task nobody joins or an
+// idle open channel does no harm.
+func (r *Runtime) Unreleased() []Leak {
+	return r.tracker.unreleased()
+}
+
+func (r *Runtime) spawnBuiltin() Value {
+	return newSitedBuiltin("spawn", func(site token.Position, args []Value) (Value, error) {
+		if len(args) == 0 {
+			return nil, errors.New("spawn requires a function")
+		}
+		id := r.tracker.addTask(site, args[0])
+		task := startTask(args[0], args[1:], func(error) { r.tracker.removeTask(id) })
+		task.control, task.tracker = r.control, r.tracker
+		r.tracker.addUnjoined(task, site, args[0])
+		return task, nil
+	})
+}
+
+func (r *Runtime) channelBuiltin() Value {
+	return newSitedBuiltin("channel", func(site token.Position, args []Value) (Value, error) {
+		value, err := builtinChannel(args)
+		if err != nil {
+			return nil, err
+		}
+		ch := value.(*ChannelValue)
+		ch.control = r.control
+		ch.onClose = func() { r.tracker.removeChannel(ch) }
+		r.tracker.addChannel(ch, site)
+		return ch, nil
+	})
+}
+
+// formatSite renders site as file:line:column, or line:column when the
+// program was not parsed from a named file.
+func formatSite(site token.Position) string {
+	if site.Line == 0 {
+		return "<unknown>"
+	}
+	if site.File != "" {
+		return site.File + ":" + site.String()
+	}
+	return site.String()
+}
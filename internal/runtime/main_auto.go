@@ -1,16 +1,25 @@
 package runtime
 
-import "github.com/cybellereaper/selenelang/internal/ast"
+import (
+	"errors"
+	"fmt"
+	"math"
 
-// MainAnalysis captures metadata about a program's main function usage.
+	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/token"
+)
+
+// MainAnalysis captures metadata about a program's main and init functions.
 type MainAnalysis struct {
 	HasMainFunction       bool
 	HasTopLevelInvocation bool
+	HasInitFunction       bool
+	HasTopLevelInitCall   bool
 }
 
-// AnalyzeMain inspects the provided program and reports whether it defines a
-// top-level main function and whether that function is already invoked via a
-// top-level expression statement.
+// AnalyzeMain inspects the provided program and reports whether it defines
+// top-level main and init functions and whether either is already invoked
+// via a top-level expression statement.
 func AnalyzeMain(program *ast.Program) MainAnalysis {
 	analysis := MainAnalysis{}
 	if program == nil {
@@ -22,21 +31,45 @@ func AnalyzeMain(program *ast.Program) MainAnalysis {
 			if node.Name != nil && node.Name.Name == "main" {
 				analysis.HasMainFunction = true
 			}
+			if node.Name != nil && node.Name.Name == "init" {
+				analysis.HasInitFunction = true
+			}
 		case *ast.ExpressionStatement:
 			if call, ok := node.Expression.(*ast.CallExpression); ok {
 				if ident, ok := call.Callee.(*ast.Identifier); ok && ident.Name == "main" {
 					analysis.HasTopLevelInvocation = true
 				}
+				if ident, ok := call.Callee.(*ast.Identifier); ok && ident.Name == "init" {
+					analysis.HasTopLevelInitCall = true
+				}
 			}
 		}
 	}
 	return analysis
 }
 
-// InvokeMainIfNeeded executes the runtime's main function when a program
-// defines one but does not call it explicitly at the top level. The provided
-// last value is returned unchanged when no invocation is required.
+// InvokeMainIfNeeded runs the program's init function, then its main
+// function, when the program defines them but does not call them explicitly
+// at the top level. init therefore runs once, after every top-level
+// statement and before main. The provided last value is returned unchanged
+// when main is not invoked.
+//
+// A main that declares one parameter receives the program arguments as an
+// array of strings. A main declared to return Number reports its result as
+// the exit code: nonzero results become an ExitError, and errors escaping
+// main carry a stack trace for FormatError.
 func InvokeMainIfNeeded(env *Environment, analysis MainAnalysis, last Value) (Value, error) {
+	if analysis.HasInitFunction && !analysis.HasTopLevelInitCall && env != nil {
+		if candidate, ok := env.Get("init"); ok {
+			fn, ok := candidate.(*Function)
+			if !ok {
+				return nil, errors.New("init must be a function")
+			}
+			if _, err := applyFunction(fn, nil); err != nil {
+				return nil, err
+			}
+		}
+	}
 	if !analysis.HasMainFunction || analysis.HasTopLevelInvocation || env == nil {
 		return last, nil
 	}
@@ -48,9 +81,39 @@ func InvokeMainIfNeeded(env *Environment, analysis MainAnalysis, last Value) (Va
 	if !ok {
 		return last, nil
 	}
-	result, err := applyFunction(fn, nil)
+	var args []Value
+	if fn.Declaration != nil {
+		switch len(fn.Declaration.Params) {
+		case 0:
+		case 1:
+			args = []Value{env.control.argsArray()}
+		default:
+			return nil, errors.New("main accepts at most one parameter, the argument array")
+		}
+	}
+	result, err := applyFunction(fn, args)
 	if err != nil {
-		return nil, err
+		return nil, withFrame(err, fn, token.Position{})
+	}
+	if fn.Declaration == nil || !declaresNumber(fn.Declaration.ReturnType) {
+		return result, nil
+	}
+	code, ok := result.(*Number)
+	if !ok {
+		return result, nil
+	}
+	if code.Value != math.Trunc(code.Value) {
+		return nil, fmt.Errorf("main returned %s, exit codes must be integers", code.Inspect())
+	}
+	if code.Value < 0 || code.Value > 255 {
+		return nil, fmt.Errorf("main returned %s, exit codes must be between 0 and 255", code.Inspect())
+	}
+	if code.Value != 0 {
+		return nil, &ExitError{Code: int(code.Value)}
 	}
 	return result, nil
 }
+
+func declaresNumber(annotation *ast.TypeAnnotation) bool {
+	return annotation != nil && annotation.Name != nil && annotation.Name.Name == "Number" && !annotation.Nullable
+}
@@ -0,0 +1,109 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// newMathModule binds the math global. Every function takes and returns
+// Numbers; under strict math, a function whose result is NaN or an infinity
+// fails the way an arithmetic operator would.
+func newMathModule(r *Runtime) *Module {
+	exports := map[string]Value{
+		"pi": NewNumber(math.Pi),
+		"e":  NewNumber(math.E),
+		"pow": newBuiltin("pow", func(args []Value) (Value, error) {
+			x, y, err := numberArguments("math.pow", args)
+			if err != nil {
+				return nil, err
+			}
+			return r.mathResult("pow", math.Pow(x, y), x, y)
+		}),
+		"min": newBuiltin("min", func(args []Value) (Value, error) {
+			return extremum("math.min", args, math.Min)
+		}),
+		"max": newBuiltin("max", func(args []Value) (Value, error) {
+			return extremum("math.max", args, math.Max)
+		}),
+		"clamp": newBuiltin("clamp", func(args []Value) (Value, error) {
+			if len(args) != 3 {
+				return nil, fmt.Errorf("math.clamp expects 3 arguments, got %d", len(args))
+			}
+			bounds := make([]float64, 3)
+			for i, arg := range args {
+				num, ok := arg.(*Number)
+				if !ok {
+					return nil, fmt.Errorf("math.clamp expects Numbers, got %s", arg.Type())
+				}
+				bounds[i] = num.Value
+			}
+			x, lo, hi := bounds[0], bounds[1], bounds[2]
+			if lo > hi {
+				return nil, fmt.Errorf("math.clamp: lower bound %s is above upper bound %s", formatNumber(lo), formatNumber(hi))
+			}
+			return NewNumber(math.Max(lo, math.Min(hi, x))), nil
+		}),
+		"random": newBuiltin("random", func(args []Value) (Value, error) {
+			if len(args) != 0 {
+				return nil, errors.New("math.random takes no arguments")
+			}
+			return NewNumber(r.random.float()), nil
+		}),
+	}
+	for name, fn := range map[string]func(float64) float64{
+		"sqrt":  math.Sqrt,
+		"abs":   math.Abs,
+		"floor": math.Floor,
+		"ceil":  math.Ceil,
+		"round": math.Round,
+		"log":   math.Log,
+		"exp":   math.Exp,
+		"sin":   math.Sin,
+		"cos":   math.Cos,
+		"tan":   math.Tan,
+	} {
+		exports[name] = newBuiltin(name, func(args []Value) (Value, error) {
+			x, err := numberArgument("math."+name, args)
+			if err != nil {
+				return nil, err
+			}
+			return r.mathResult(name, fn(x), x)
+		})
+	}
+	return NewModule("math", exports)
+}
+
+// mathResult wraps the result of math.name applied to args, failing under
+// strict math when it is NaN or an infinity.
+func (r *Runtime) mathResult(name string, result float64, args ...float64) (Value, error) {
+	if !r.control.strictMath || !(math.IsNaN(result) || math.IsInf(result, 0)) {
+		return NewNumber(result), nil
+	}
+	formatted := make([]string, len(args))
+	for i, arg := range args {
+		formatted[i] = formatNumber(arg)
+	}
+	return nil, fmt.Errorf("strict math: math.%s(%s) produced %s", name, strings.Join(formatted, ", "), formatNumber(result))
+}
+
+// extremum folds one or more Number arguments with pick.
+func extremum(name string, args []Value, pick func(a, b float64) float64) (Value, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s expects at least one Number", name)
+	}
+	var result float64
+	for i, arg := range args {
+		num, ok := arg.(*Number)
+		if !ok {
+			return nil, fmt.Errorf("%s expects Numbers, got %s", name, arg.Type())
+		}
+		if i == 0 {
+			result = num.Value
+		} else {
+			result = pick(result, num.Value)
+		}
+	}
+	return NewNumber(result), nil
+}
@@ -0,0 +1,146 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Number arithmetic follows IEEE 754: an overflow yields an infinity and an
+// operation without a meaningful result, such as +Inf - +Inf, yields NaN,
+// and both propagate through later arithmetic. Only division and modulo by
+// zero fail. Strict math, enabled with SetStrictMath, makes every arithmetic
+// operator fail instead of producing NaN or an infinity, with an error that
+// try/catch can handle. The checked and saturating builtins give the same
+// control one operation at a time.
+
+// maxSafeInteger is the largest integer a Number holds exactly, along with
+// every integer between it and zero.
+const maxSafeInteger = 1<<53 - 1
+
+// SetStrictMath makes arithmetic operators fail when they would produce NaN
+// or an infinity. Call it before running code.
+func (r *Runtime) SetStrictMath(enabled bool) {
+	r.control.strictMath = enabled
+}
+
+// strictMath reports whether arithmetic in env runs under strict math.
+func (e *Environment) strictMath() bool {
+	return e.control != nil && e.control.strictMath
+}
+
+// checkStrictMath fails when result, the value of left operator right, is
+// NaN or an infinity.
+func checkStrictMath(operator string, left, right, result Value) (Value, error) {
+	num, ok := result.(*Number)
+	if !ok || !(math.IsNaN(num.Value) || math.IsInf(num.Value, 0)) {
+		return result, nil
+	}
+	return nil, fmt.Errorf("strict math: %s %s %s produced %s", left.Inspect(), operator, right.Inspect(), num.Inspect())
+}
+
+func installNumeric(env *Environment) {
+	env.Set("isNaN", newBuiltin("isNaN", func(args []Value) (Value, error) {
+		n, err := numberArgument("isNaN", args)
+		if err != nil {
+			return nil, err
+		}
+		return NewBoolean(math.IsNaN(n)), nil
+	}))
+	env.Set("isFinite", newBuiltin("isFinite", func(args []Value) (Value, error) {
+		n, err := numberArgument("isFinite", args)
+		if err != nil {
+			return nil, err
+		}
+		return NewBoolean(!math.IsNaN(n) && !math.IsInf(n, 0)), nil
+	}))
+	for _, op := range []struct {
+		suffix, operator string
+		apply            func(a, b float64) float64
+	}{
+		{"Add", "+", func(a, b float64) float64 { return a + b }},
+		{"Sub", "-", func(a, b float64) float64 { return a - b }},
+		{"Mul", "*", func(a, b float64) float64 { return a * b }},
+		{"Div", "/", func(a, b float64) float64 { return a / b }},
+	} {
+		env.Set("checked"+op.suffix, newBuiltin("checked"+op.suffix, checkedBuiltin("checked"+op.suffix, op.operator, op.apply)))
+		if op.operator != "/" {
+			env.Set("saturating"+op.suffix, newBuiltin("saturating"+op.suffix, saturatingBuiltin("saturating"+op.suffix, op.operator, op.apply)))
+		}
+	}
+}
+
+// checkedBuiltin returns Result.Ok of a op b, or Result.Err when the result
+// is NaN or infinite, when b is zero for division, or when a and b are
+// integers and the result is beyond the range a Number holds exactly.
+func checkedBuiltin(name, operator string, apply func(a, b float64) float64) BuiltinFunction {
+	return func(args []Value) (Value, error) {
+		a, b, err := numberArguments(name, args)
+		if err != nil {
+			return nil, err
+		}
+		if operator == "/" && b == 0 {
+			return Err(&ErrorValue{Message: "division by zero"}), nil
+		}
+		result := apply(a, b)
+		switch {
+		case math.IsNaN(result) || math.IsInf(result, 0):
+			return Err(&ErrorValue{Message: fmt.Sprintf("%s %s %s produced %s", formatNumber(a), operator, formatNumber(b), formatNumber(result))}), nil
+		case isInteger(a) && isInteger(b) && math.Abs(result) > maxSafeInteger:
+			return Err(&ErrorValue{Message: fmt.Sprintf("%s %s %s overflows the safe integer range", formatNumber(a), operator, formatNumber(b))}), nil
+		}
+		return Ok(NewNumber(result)), nil
+	}
+}
+
+// saturatingBuiltin returns a op b clamped to the safe integer range when a
+// and b are integers, and to the finite Numbers otherwise. A NaN result is
+// an error, since there is no bound to clamp it to.
+func saturatingBuiltin(name, operator string, apply func(a, b float64) float64) BuiltinFunction {
+	return func(args []Value) (Value, error) {
+		a, b, err := numberArguments(name, args)
+		if err != nil {
+			return nil, err
+		}
+		result := apply(a, b)
+		if math.IsNaN(result) {
+			return nil, fmt.Errorf("%s: %s %s %s is NaN", name, formatNumber(a), operator, formatNumber(b))
+		}
+		bound := math.MaxFloat64
+		if isInteger(a) && isInteger(b) {
+			bound = maxSafeInteger
+		}
+		return NewNumber(math.Max(-bound, math.Min(bound, result))), nil
+	}
+}
+
+func numberArgument(name string, args []Value) (float64, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("%s expects 1 argument, got %d", name, len(args))
+	}
+	num, ok := args[0].(*Number)
+	if !ok {
+		return 0, fmt.Errorf("%s expects a Number, got %s", name, args[0].Type())
+	}
+	return num.Value, nil
+}
+
+func numberArguments(name string, args []Value) (float64, float64, error) {
+	if len(args) != 2 {
+		return 0, 0, fmt.Errorf("%s expects 2 arguments, got %d", name, len(args))
+	}
+	a, ok := args[0].(*Number)
+	b, ok2 := args[1].(*Number)
+	if !ok || !ok2 {
+		return 0, 0, errors.New(name + " expects two Numbers")
+	}
+	return a.Value, b.Value, nil
+}
+
+func isInteger(n float64) bool {
+	return n == math.Trunc(n) && !math.IsInf(n, 0)
+}
+
+func formatNumber(n float64) string {
+	return NewNumber(n).Inspect()
+}
@@ -0,0 +1,199 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/cybellereaper/selenelang/internal/token"
+)
+
+// Globber is implemented by filesystems that can list the names matching a
+// pattern, which path.glob needs. Both built-in filesystems implement it.
+type Globber interface {
+	Glob(pattern string) ([]string, error)
+}
+
+func (osFileSystem) Glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+// Glob returns the stored paths matching pattern, using path.Match syntax.
+func (m *MemoryFileSystem) Glob(pattern string) ([]string, error) {
+	pattern = path.Clean(pattern)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, name := range m.Files() {
+		if ok, _ := path.Match(pattern, name); ok {
+			matches = append(matches, name)
+		}
+	}
+	return matches, nil
+}
+
+func newPathModule(r *Runtime) *Module {
+	return NewModule("path", map[string]Value{
+		"join": newBuiltin("join", func(args []Value) (Value, error) {
+			if len(args) == 0 {
+				return nil, errors.New("path.join expects at least one path")
+			}
+			parts := make([]string, len(args))
+			for i, arg := range args {
+				part, err := stringArg("path.join", arg)
+				if err != nil {
+					return nil, err
+				}
+				parts[i] = part
+			}
+			return NewString(filepath.Join(parts...)), nil
+		}),
+		"dir":  pathFunction("dir", filepath.Dir),
+		"base": pathFunction("base", filepath.Base),
+		"ext":  pathFunction("ext", filepath.Ext),
+		"abs": newBuiltin("abs", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, errors.New("path.abs expects a path")
+			}
+			name, err := stringArg("path.abs", args[0])
+			if err != nil {
+				return nil, err
+			}
+			if filepath.IsAbs(name) {
+				return NewString(filepath.Clean(name)), nil
+			}
+			wd, err := r.hostEnv.Getwd()
+			if err != nil {
+				return nil, err
+			}
+			return NewString(filepath.Join(wd, name)), nil
+		}),
+		"rel": newBuiltin("rel", func(args []Value) (Value, error) {
+			if len(args) != 2 {
+				return nil, errors.New("path.rel expects a base path and a target path")
+			}
+			base, err := stringArg("path.rel", args[0])
+			if err != nil {
+				return nil, err
+			}
+			target, err := stringArg("path.rel", args[1])
+			if err != nil {
+				return nil, err
+			}
+			rel, err := filepath.Rel(base, target)
+			if err != nil {
+				return nil, err
+			}
+			return NewString(rel), nil
+		}),
+		"glob": newBuiltin("glob", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, errors.New("path.glob expects a pattern")
+			}
+			pattern, err := stringArg("path.glob", args[0])
+			if err != nil {
+				return nil, err
+			}
+			if pattern, err = r.resolvePath(pattern); err != nil {
+				return nil, err
+			}
+			globber, ok := r.fs.(Globber)
+			if !ok {
+				return nil, errors.New("path.glob is not supported by this filesystem")
+			}
+			matches, err := globber.Glob(pattern)
+			if err != nil {
+				return nil, err
+			}
+			sort.Strings(matches)
+			elements := make([]Value, 0, len(matches))
+			for _, match := range matches {
+				// Matches outside the policy's file roots are left out
+				// rather than failing the whole call.
+				if r.checkPath("path.glob", match) == nil {
+					elements = append(elements, NewString(match))
+				}
+			}
+			return &Array{Elements: elements}, nil
+		}),
+	})
+}
+
+func pathFunction(name string, fn func(string) string) Value {
+	return newBuiltin(name, func(args []Value) (Value, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("path.%s expects a path", name)
+		}
+		value, err := stringArg("path."+name, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return NewString(fn(value)), nil
+	})
+}
+
+// tempBuiltin implements fs.tempFile and fs.tempDir. Each returns an object
+// with the new path and a close method that deletes it, so `using` removes
+// the file or directory when its block ends. Paths still open when the host
+// calls Shutdown are removed then and reported as leaks.
+func (r *Runtime) tempBuiltin(name string, dir bool) Value {
+	builtin := "fs." + name
+	return newSitedBuiltin(name, func(site token.Position, args []Value) (Value, error) {
+		if len(args) > 1 {
+			return nil, fmt.Errorf("%s expects an optional name prefix", builtin)
+		}
+		if r.sandboxed {
+			return nil, fmt.Errorf("%s is disabled in sandboxed mode", builtin)
+		}
+		if _, ok := r.fs.(osFileSystem); !ok {
+			return nil, fmt.Errorf("%s requires the host filesystem", builtin)
+		}
+		prefix := "selene-"
+		if len(args) == 1 {
+			var err error
+			if prefix, err = stringArg(builtin, args[0]); err != nil {
+				return nil, err
+			}
+		}
+		if err := r.checkPath(builtin, os.TempDir()); err != nil {
+			return nil, err
+		}
+		var created string
+		if dir {
+			var err error
+			if created, err = os.MkdirTemp("", prefix+"*"); err != nil {
+				return nil, err
+			}
+		} else {
+			file, err := os.CreateTemp("", prefix+"*")
+			if err != nil {
+				return nil, err
+			}
+			created = file.Name()
+			if err := file.Close(); err != nil {
+				return nil, err
+			}
+		}
+		kind := "temp file"
+		if dir {
+			kind = "temp directory"
+		}
+		r.tracker.addTemp(created, kind, site)
+		return NewObject(
+			Property{"path", NewString(created)},
+			Property{"close", newBuiltin("close", func(args []Value) (Value, error) {
+				if len(args) != 0 {
+					return nil, errors.New("close takes no arguments")
+				}
+				if err := r.tracker.removeTemp(created); err != nil {
+					return nil, err
+				}
+				return NullValue, nil
+			})},
+		), nil
+	})
+}
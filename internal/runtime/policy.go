@@ -0,0 +1,241 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime/metrics"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Policy grants a script precisely scoped capabilities. The zero value
+// restricts nothing; each field narrows one capability. For the slice
+// fields, nil means unrestricted and an empty, non-nil slice allows nothing.
+type Policy struct {
+	// Builtins lists the globals the script may use, such as "print" or
+	// "tasks". A module member such as "os.env" allows only that member of
+	// the module. Globals outside the list are removed from the environment,
+	// except the prelude enums Result and Option, which grant nothing.
+	Builtins []string
+	// FileRoots lists the directories the fs module may touch. Paths are
+	// resolved to absolute form before they are compared.
+	FileRoots []string
+	// Env lists the environment variables os.env and os.setenv may access.
+	Env []string
+	// MaxSteps bounds the loop iterations and function calls the program
+	// may perform across all of its tasks. Zero means no limit.
+	MaxSteps int64
+	// MaxHeapBytes bounds the live Go heap while the program runs. The heap
+	// is shared by the whole host process, so this is a coarse guard against
+	// runaway allocation rather than exact accounting. Zero means no limit.
+	MaxHeapBytes uint64
+	// Timeout bounds the wall-clock time the program may take, counted from
+	// SetPolicy. When it passes, evaluation and blocked operations stop with
+	// a *CancelledError caused by a time *BudgetError. A later SetContext
+	// replaces the deadline, so call that first. Zero means no limit.
+	Timeout time.Duration
+	// Capabilities switches groups of host-affecting builtins on or off.
+	// Capabilities it does not mention stay enabled. A builtin whose
+	// capability is off stays bound but throws a PermissionError, which
+	// scripts can catch, when it is called.
+	Capabilities map[Capability]bool
+}
+
+// BudgetError is returned when a program exhausts a Policy budget. Like
+// CancelledError it cannot be caught by the script.
+type BudgetError struct {
+	Resource string
+	Limit    uint64
+}
+
+// Error implements the error interface for BudgetError.
+func (e *BudgetError) Error() string {
+	if e.Resource == "time" {
+		return fmt.Sprintf("time budget of %s exceeded", time.Duration(e.Limit))
+	}
+	return fmt.Sprintf("%s budget of %d exceeded", e.Resource, e.Limit)
+}
+
+// errPolicy is reported when a script reaches for a capability its policy
+// does not grant.
+func errPolicy(format string, args ...any) error {
+	return fmt.Errorf("policy: "+format, args...)
+}
+
+// heapCheckInterval is how many steps pass between heap samples, and
+// heapCheckBytes how many bytes concatenations may reserve between them.
+const (
+	heapCheckInterval = 1024
+	heapCheckBytes    = 1 << 20
+)
+
+// budget counts evaluation steps and reserved bytes against a Policy.
+type budget struct {
+	steps    atomic.Int64
+	reserved atomic.Uint64
+	maxSteps int64
+	maxHeap  uint64
+}
+
+func (b *budget) step() error {
+	if b == nil {
+		return nil
+	}
+	n := b.steps.Add(1)
+	if b.maxSteps > 0 && n > b.maxSteps {
+		return &BudgetError{Resource: "step", Limit: uint64(b.maxSteps)}
+	}
+	if b.maxHeap > 0 && n%heapCheckInterval == 0 {
+		if live := liveHeapBytes(); live > b.maxHeap {
+			return &BudgetError{Resource: "heap", Limit: b.maxHeap}
+		}
+	}
+	return nil
+}
+
+// reserve charges n bytes an operation is about to allocate. Steps alone
+// would let a loop that doubles a string exhaust memory between two heap
+// samples, so once a megabyte has been reserved since the last sample the heap
+// is sampled again, and the allocation refused if it would pass the budget.
+func (b *budget) reserve(n int) error {
+	if b == nil || b.maxHeap == 0 || b.reserved.Add(uint64(n)) < heapCheckBytes {
+		return nil
+	}
+	b.reserved.Store(0)
+	if live := liveHeapBytes(); live+uint64(n) > b.maxHeap {
+		return &BudgetError{Resource: "heap", Limit: b.maxHeap}
+	}
+	return nil
+}
+
+// reserveConcat reserves the String or Bytes that left operator right
+// builds when operator is +.
+func (e *Environment) reserveConcat(operator string, left, right Value) error {
+	if operator != "+" || e.control == nil || e.control.budget == nil {
+		return nil
+	}
+	switch l := left.(type) {
+	case *String:
+		if r, ok := right.(*String); ok {
+			return e.control.budget.reserve(len(l.Value) + len(r.Value))
+		}
+	case *Bytes:
+		if r, ok := right.(*Bytes); ok {
+			return e.control.budget.reserve(len(l.Value) + len(r.Value))
+		}
+	}
+	return nil
+}
+
+func liveHeapBytes() uint64 {
+	sample := []metrics.Sample{{Name: "/gc/heap/live:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// SetPolicy restricts the runtime to the capabilities p grants. Call it
+// before running a program; globals it removes stay removed.
+func (r *Runtime) SetPolicy(p Policy) error {
+	roots := make([]string, 0, len(p.FileRoots))
+	for _, root := range p.FileRoots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return fmt.Errorf("policy file root %s: %w", root, err)
+		}
+		roots = append(roots, abs)
+	}
+	if p.FileRoots != nil {
+		p.FileRoots = roots
+	}
+	if p.Builtins != nil {
+		if err := restrictBuiltins(r.env, p.Builtins); err != nil {
+			return err
+		}
+	}
+	if p.MaxSteps < 0 {
+		return errors.New("policy MaxSteps must not be negative")
+	}
+	if p.Timeout < 0 {
+		return errors.New("policy Timeout must not be negative")
+	}
+	if err := checkCapabilities(p.Capabilities); err != nil {
+		return err
+	}
+	r.policy = p
+	if p.MaxSteps > 0 || p.MaxHeapBytes > 0 {
+		r.control.budget = &budget{maxSteps: p.MaxSteps, maxHeap: p.MaxHeapBytes}
+	}
+	if p.Timeout > 0 {
+		r.setDeadline(p.Timeout)
+	}
+	return nil
+}
+
+// restrictBuiltins removes every global that allowed does not name and trims
+// modules down to the members listed as "module.member".
+func restrictBuiltins(env *Environment, allowed []string) error {
+	whole := make(map[string]bool)
+	members := make(map[string][]string)
+	for _, name := range allowed {
+		module, member, ok := strings.Cut(name, ".")
+		if _, exists := env.store[module]; !exists {
+			return fmt.Errorf("policy allows unknown builtin %s", name)
+		}
+		if !ok {
+			whole[module] = true
+			continue
+		}
+		mod, isModule := env.store[module].(*Module)
+		if !isModule {
+			return fmt.Errorf("policy allows %s, but %s is not a module", name, module)
+		}
+		if _, exists := mod.Exports[member]; !exists {
+			return fmt.Errorf("policy allows unknown builtin %s", name)
+		}
+		members[module] = append(members[module], member)
+	}
+	for name, val := range env.store {
+		switch {
+		case whole[name] || name == "__package__" || name == resultType.Name || name == optionType.Name:
+		case members[name] != nil:
+			mod := val.(*Module)
+			exports := make(map[string]Value, len(members[name]))
+			for _, member := range members[name] {
+				exports[member] = mod.Exports[member]
+			}
+			env.store[name] = NewModule(mod.Name, exports)
+		default:
+			delete(env.store, name)
+		}
+	}
+	return nil
+}
+
+func (r *Runtime) checkPath(builtin, name string) error {
+	if r.policy.FileRoots == nil {
+		return nil
+	}
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return err
+	}
+	for _, root := range r.policy.FileRoots {
+		if rel, err := filepath.Rel(root, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	return errPolicy("%s may not access %s", builtin, name)
+}
+
+func (r *Runtime) checkEnv(builtin, name string) error {
+	if r.policy.Env == nil || slices.Contains(r.policy.Env, name) {
+		return nil
+	}
+	return errPolicy("%s may not access environment variable %s", builtin, name)
+}
@@ -0,0 +1,70 @@
+package runtime
+
+import (
+	"fmt"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+)
+
+// The prelude enums model expected failures without exceptions. A function
+// returns Result.Ok(value) or Result.Err(error), or Option.Some(value) or
+// Option.None(), and callers either match on the case or apply the postfix
+// ? operator to pass a failure on to their own caller. Every runtime binds
+// them as the globals Result and Option; declarations in a program shadow
+// them.
+var (
+	resultType = newPreludeEnum("Result", []string{"Ok", "Err"}, map[string][]string{"Ok": {"value"}, "Err": {"error"}})
+	optionType = newPreludeEnum("Option", []string{"Some", "None"}, map[string][]string{"Some": {"value"}, "None": nil})
+)
+
+func newPreludeEnum(name string, order []string, cases map[string][]string) *EnumType {
+	enum := &EnumType{Name: name, Cases: cases, CaseOrder: order, Constructors: make(map[string]Value, len(cases))}
+	for caseName, params := range cases {
+		enum.Constructors[caseName] = newEnumConstructor(enum, caseName, params)
+	}
+	return enum
+}
+
+func installPrelude(env *Environment) {
+	env.Set(resultType.Name, resultType)
+	env.Set(optionType.Name, optionType)
+	env.Set(errorClass.Name, errorClass)
+	env.Set(permissionErrorClass.Name, permissionErrorClass)
+}
+
+// Ok returns Result.Ok(val), for builtins that report expected failures as
+// results rather than errors.
+func Ok(val Value) Value { return preludeCase(resultType, "Ok", "value", val) }
+
+// Err returns Result.Err(val).
+func Err(val Value) Value { return preludeCase(resultType, "Err", "error", val) }
+
+// Some returns Option.Some(val).
+func Some(val Value) Value { return preludeCase(optionType, "Some", "value", val) }
+
+// None returns Option.None().
+func None() Value { return &EnumInstance{Enum: optionType, Case: "None"} }
+
+func preludeCase(enum *EnumType, caseName, field string, val Value) Value {
+	return &EnumInstance{Enum: enum, Case: caseName, Fields: map[string]Value{field: val}, Order: []string{field}}
+}
+
+// evalPropagateExpression implements x?. An Ok or Some operand evaluates to
+// the value it wraps; an Err or None operand is returned, unchanged, from
+// the enclosing function.
+func evalPropagateExpression(node *ast.PropagateExpression, env *Environment) (Value, error) {
+	val, err := evalExpression(node.Expression, env)
+	if err != nil {
+		return nil, err
+	}
+	inst, ok := val.(*EnumInstance)
+	if !ok || (inst.Enum != resultType && inst.Enum != optionType) {
+		return nil, fmt.Errorf("? expects a Result or Option, got %s", val.Inspect())
+	}
+	switch inst.Case {
+	case "Ok", "Some":
+		return inst.Fields["value"], nil
+	default:
+		return nil, &returnSignal{value: inst, propagated: true}
+	}
+}
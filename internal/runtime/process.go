@@ -0,0 +1,202 @@
+package runtime
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// ExitError is returned from Run when a script calls os.exit. It unwinds the
+// program like an uncaught error but is not visible to catch clauses; hosts
+// should terminate with Code.
+type ExitError struct {
+	Code int
+}
+
+// Error implements the error interface for ExitError.
+func (e *ExitError) Error() string { return fmt.Sprintf("exit status %d", e.Code) }
+
+// errSandboxed is reported by os builtins that a sandboxed runtime disables.
+func errSandboxed(name string) error {
+	return fmt.Errorf("os.%s is disabled in sandboxed mode", name)
+}
+
+// SetArgs records the program arguments returned by os.args().
+// A main function that declares a parameter receives them as an array.
+func (r *Runtime) SetArgs(args []string) {
+	r.control.args = append([]string(nil), args...)
+}
+
+// UseScriptPrelude defines the bindings a standalone script gets on top of
+// the usual builtins: args, the program arguments as an array of strings,
+// and exit(code), which is os.exit. Call it after SetArgs. Declarations in
+// the script shadow these names.
+func (r *Runtime) UseScriptPrelude() {
+	r.env.Set("args", r.control.argsArray())
+	if val, ok := r.env.Get("os"); ok {
+		if mod, ok := val.(*Module); ok {
+			r.env.Set("exit", newBuiltin("exit", func(args []Value) (Value, error) {
+				// Looked up on each call so an audit log installed later sees it.
+				return applyFunction(mod.Exports["exit"], args)
+			}))
+		}
+	}
+}
+
+// SetSandboxed toggles sandboxed mode. A sandboxed runtime refuses to run
+// external processes, write files, or change the process environment and
+// working directory.
+func (r *Runtime) SetSandboxed(sandboxed bool) {
+	r.sandboxed = sandboxed
+}
+
+func newProcessModule(r *Runtime) *Module {
+	return NewModule("os", map[string]Value{
+		"args": newBuiltin("args", func(args []Value) (Value, error) {
+			if len(args) != 0 {
+				return nil, errors.New("os.args takes no arguments")
+			}
+			return r.control.argsArray(), nil
+		}),
+		"onSignal": r.onSignalBuiltin(),
+		"env": newBuiltin("env", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, errors.New("os.env expects a variable name")
+			}
+			name, err := stringArg("os.env", args[0])
+			if err != nil {
+				return nil, err
+			}
+			if err := r.checkEnv("os.env", name); err != nil {
+				return nil, err
+			}
+			value, ok := r.hostEnv.LookupEnv(name)
+			if !ok {
+				return NullValue, nil
+			}
+			return NewString(value), nil
+		}),
+		"setenv": newBuiltin("setenv", func(args []Value) (Value, error) {
+			if len(args) != 2 {
+				return nil, errors.New("os.setenv expects a name and a value")
+			}
+			if r.sandboxed {
+				return nil, errSandboxed("setenv")
+			}
+			name, err := stringArg("os.setenv", args[0])
+			if err != nil {
+				return nil, err
+			}
+			if err := r.checkEnv("os.setenv", name); err != nil {
+				return nil, err
+			}
+			if err := r.hostEnv.Setenv(name, toString(args[1])); err != nil {
+				return nil, err
+			}
+			return NullValue, nil
+		}),
+		"exit": newBuiltin("exit", func(args []Value) (Value, error) {
+			code := 0
+			if len(args) > 1 {
+				return nil, errors.New("os.exit expects at most one status code")
+			}
+			if len(args) == 1 {
+				num, ok := args[0].(*Number)
+				if !ok || float64(int(num.Value)) != num.Value {
+					return nil, errors.New("os.exit status must be an integer")
+				}
+				code = int(num.Value)
+			}
+			return nil, &ExitError{Code: code}
+		}),
+		"exec": newBuiltin("exec", func(args []Value) (Value, error) {
+			if len(args) == 0 || len(args) > 2 {
+				return nil, errors.New("os.exec expects a command and an optional argument array")
+			}
+			if r.sandboxed {
+				return nil, errSandboxed("exec")
+			}
+			name, err := stringArg("os.exec", args[0])
+			if err != nil {
+				return nil, err
+			}
+			var cmdArgs []string
+			if len(args) == 2 {
+				arr, ok := args[1].(*Array)
+				if !ok {
+					return nil, fmt.Errorf("os.exec arguments must be an Array, got %s", args[1].Type())
+				}
+				for _, el := range arr.Elements {
+					cmdArgs = append(cmdArgs, toString(el))
+				}
+			}
+			return r.runProcess(name, cmdArgs)
+		}),
+		"cwd": newBuiltin("cwd", func(args []Value) (Value, error) {
+			if len(args) != 0 {
+				return nil, errors.New("os.cwd takes no arguments")
+			}
+			wd, err := r.hostEnv.Getwd()
+			if err != nil {
+				return nil, err
+			}
+			return NewString(wd), nil
+		}),
+		"chdir": newBuiltin("chdir", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, errors.New("os.chdir expects a directory")
+			}
+			if r.sandboxed {
+				return nil, errSandboxed("chdir")
+			}
+			dir, err := stringArg("os.chdir", args[0])
+			if err != nil {
+				return nil, err
+			}
+			if err := r.hostEnv.Chdir(dir); err != nil {
+				return nil, err
+			}
+			return NullValue, nil
+		}),
+	})
+}
+
+// runProcess executes name and reports its captured output. A non-zero exit
+// status is part of the result rather than an error. Under an injected
+// HostEnv the process runs in its working directory with its variables.
+func (r *Runtime) runProcess(name string, args []string) (Value, error) {
+	var stdout, stderr bytes.Buffer
+	// #nosec G204 -- running user-chosen commands is the purpose of os.exec; sandboxed runtimes refuse it.
+	cmd := exec.Command(name, args...)
+	if _, ok := r.hostEnv.(osHostEnv); !ok {
+		wd, err := r.hostEnv.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		cmd.Dir, cmd.Env = wd, r.hostEnv.Environ()
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	status := 0
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("os.exec %s: %w", name, err)
+		}
+		status = exitErr.ExitCode()
+	}
+	return NewObject(
+		Property{"stdout", NewString(stdout.String())},
+		Property{"stderr", NewString(stderr.String())},
+		Property{"status", NewNumber(float64(status))},
+	), nil
+}
+
+func stringArg(name string, value Value) (string, error) {
+	str, ok := value.(*String)
+	if !ok {
+		return "", fmt.Errorf("%s expects String, got %s", name, value.Type())
+	}
+	return str.Value, nil
+}
@@ -0,0 +1,252 @@
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	goruntime "runtime"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/cybellereaper/selenelang/internal/token"
+)
+
+// An environment starts out owned by the goroutine that created it and is
+// accessed without locks. Before a function value can run on, or be handed
+// to, another goroutine (spawn, task continuations, channel sends, or a store
+// into an environment that is already shared), share marks its environment
+// and every scope around it as shared, along with the scopes of closures
+// bound there. Shared environments lock on every access, so the interpreter
+// itself never races; whether a script's own read-modify-write sequences
+// interleave safely is what the race check reports.
+
+// share marks e and its outer scopes as reachable from several goroutines.
+func (e *Environment) share() {
+	for env := e; env != nil; env = env.outer {
+		if env.shared.Swap(true) {
+			return
+		}
+		env.mu.RLock()
+		var closures []*Function
+		for _, val := range env.store {
+			if fn, ok := val.(*Function); ok && fn.Env != nil {
+				closures = append(closures, fn)
+			}
+		}
+		for _, val := range env.slots {
+			if fn, ok := val.(*Function); ok && fn.Env != nil {
+				closures = append(closures, fn)
+			}
+		}
+		env.mu.RUnlock()
+		for _, fn := range closures {
+			fn.Env.share()
+		}
+	}
+}
+
+// shareValue shares the environment val closes over, if it is a function.
+func shareValue(val Value) {
+	if fn, ok := val.(*Function); ok && fn.Env != nil {
+		fn.Env.share()
+	}
+}
+
+// Race reports a binding that two tasks assigned without the first
+// assignment being ordered before the second: the second task neither was
+// spawned after it by the task that made it nor ran after that task finished.
+type Race struct {
+	Name string
+	// First and Second are the source positions of the two assignments;
+	// assignments through pointers have no position.
+	First  token.Position
+	Second token.Position
+}
+
+// String renders the race for diagnostics.
+func (r Race) String() string {
+	return fmt.Sprintf("concurrent assignments to %s at %s and %s", r.Name, formatSite(r.First), formatSite(r.Second))
+}
+
+// RaceError reports the races found by a race-checked run when a host
+// chooses to fail on them.
+type RaceError struct {
+	Races []Race
+}
+
+func (e *RaceError) Error() string {
+	return fmt.Sprintf("race check found %d concurrent assignment(s)", len(e.Races))
+}
+
+// SetRaceCheck turns on race checking for the runtime: every assignment to a
+// binding shared between tasks is recorded, and assignments from two tasks
+// that are not ordered by spawn or completion are reported by Races. It
+// slows assignments to shared bindings considerably and is meant for
+// debugging. Call it before running code.
+func (r *Runtime) SetRaceCheck(enabled bool) {
+	if enabled {
+		r.control.race = &raceDetector{strands: make(map[uint64]*strand), writes: make(map[raceKey]raceWrite)}
+	} else {
+		r.control.race = nil
+	}
+}
+
+// Races returns the races found so far, ordered by the position of the
+// later assignment. It is empty unless SetRaceCheck was enabled.
+func (r *Runtime) Races() []Race {
+	d := r.control.race
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	races := append([]Race(nil), d.races...)
+	sort.SliceStable(races, func(i, j int) bool {
+		if races[i].Second.Line != races[j].Second.Line {
+			return races[i].Second.Line < races[j].Second.Line
+		}
+		return races[i].Second.Column < races[j].Second.Column
+	})
+	return races
+}
+
+// noteWrite records an assignment to name in e for the race check.
+func (e *Environment) noteWrite(name string, pos token.Position) {
+	if !e.shared.Load() || e.control == nil || e.control.race == nil {
+		return
+	}
+	e.control.race.write(e, name, pos)
+}
+
+// raceDetector tracks, for every shared binding, the last task to assign it.
+// Tasks are told apart by goroutine: each goroutine running Selene code is a
+// strand, and a strand forked by spawn remembers its parent and the point in
+// the parent's history at which it was forked.
+type raceDetector struct {
+	mu      sync.Mutex
+	strands map[uint64]*strand
+	writes  map[raceKey]raceWrite
+	races   []Race
+	// reported holds the bindings already reported, so a loop that keeps
+	// racing yields one race per binding.
+	reported map[raceKey]bool
+}
+
+type strand struct {
+	parent *strand
+	// forked is the parent's clock when this strand was forked.
+	forked uint64
+	clock  uint64
+	done   bool
+}
+
+type raceKey struct {
+	env  *Environment
+	name string
+}
+
+type raceWrite struct {
+	strand *strand
+	clock  uint64
+	pos    token.Position
+}
+
+// current returns the strand of the calling goroutine, starting a new root
+// strand for a goroutine not seen before, such as the one calling Run.
+// d.mu must be held.
+func (d *raceDetector) current() *strand {
+	id := goroutineID()
+	s, ok := d.strands[id]
+	if !ok {
+		s = &strand{}
+		d.strands[id] = s
+	}
+	return s
+}
+
+// fork starts a strand for work the calling goroutine is about to hand to a
+// new one. The new goroutine calls enter with it.
+func (d *raceDetector) fork() *strand {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	parent := d.current()
+	parent.clock++
+	return &strand{parent: parent, forked: parent.clock}
+}
+
+// forkStrand forks a strand for running fn on another goroutine when race
+// checking is on. The goroutine calls the returned function on entry and the
+// function it returns on exit.
+func forkStrand(fn Value) func() func() {
+	f, ok := fn.(*Function)
+	if !ok || f.Env == nil || f.Env.control == nil || f.Env.control.race == nil {
+		return func() func() { return func() {} }
+	}
+	d := f.Env.control.race
+	s := d.fork()
+	return func() func() { return d.enter(s) }
+}
+
+// enter runs the calling goroutine as s until the returned function is
+// called, which marks s finished.
+func (d *raceDetector) enter(s *strand) func() {
+	id := goroutineID()
+	d.mu.Lock()
+	previous, had := d.strands[id]
+	d.strands[id] = s
+	d.mu.Unlock()
+	return func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		s.done = true
+		if had {
+			d.strands[id] = previous
+		} else {
+			delete(d.strands, id)
+		}
+	}
+}
+
+func (d *raceDetector) write(env *Environment, name string, pos token.Position) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.current()
+	s.clock++
+	key := raceKey{env: env, name: name}
+	if last, ok := d.writes[key]; ok && !last.orderedBefore(s) && !d.reported[key] {
+		if d.reported == nil {
+			d.reported = make(map[raceKey]bool)
+		}
+		d.reported[key] = true
+		d.races = append(d.races, Race{Name: name, First: last.pos, Second: pos})
+	}
+	d.writes[key] = raceWrite{strand: s, clock: s.clock, pos: pos}
+}
+
+// orderedBefore reports whether w is known to happen before anything s does
+// now: s made it, its strand has finished, or s descends from a fork its
+// strand made after it.
+func (w raceWrite) orderedBefore(s *strand) bool {
+	if w.strand == s || w.strand.done {
+		return true
+	}
+	for child := s; child.parent != nil; child = child.parent {
+		if child.parent == w.strand && w.clock <= child.forked {
+			return true
+		}
+	}
+	return false
+}
+
+// goroutineID parses the calling goroutine's ID from its stack header,
+// "goroutine 18 [running]:".
+func goroutineID() uint64 {
+	var buf [64]byte
+	n := goruntime.Stack(buf[:], false)
+	fields := bytes.Fields(buf[:n])
+	if len(fields) < 2 {
+		return 0
+	}
+	id, _ := strconv.ParseUint(string(fields[1]), 10, 64)
+	return id
+}
@@ -0,0 +1,126 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+)
+
+// randomSource is the generator behind the rand module and math.random. It
+// starts from a random seed, so runs differ until rand.seed fixes it; after
+// that, the same sequence of calls yields the same values. Tasks share it
+// under a lock.
+type randomSource struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newRandomSource() *randomSource {
+	return &randomSource{rng: rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))}
+}
+
+func (s *randomSource) seed(seed uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rng = rand.New(rand.NewPCG(seed, 0))
+}
+
+func (s *randomSource) float() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.Float64()
+}
+
+// intN returns a value in [0, n).
+func (s *randomSource) intN(n int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.Int64N(n)
+}
+
+func (s *randomSource) shuffle(values []Value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rng.Shuffle(len(values), func(i, j int) { values[i], values[j] = values[j], values[i] })
+}
+
+func newRandModule(r *Runtime) *Module {
+	return NewModule("rand", map[string]Value{
+		"seed": newBuiltin("seed", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("rand.seed expects 1 argument, got %d", len(args))
+			}
+			n, err := integerArgument("rand.seed", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			r.random.seed(uint64(n))
+			return NullValue, nil
+		}),
+		"int": newBuiltin("int", func(args []Value) (Value, error) {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("rand.int expects 2 arguments, got %d", len(args))
+			}
+			lo, err := integerArgument("rand.int", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			hi, err := integerArgument("rand.int", args, 1)
+			if err != nil {
+				return nil, err
+			}
+			if lo > hi {
+				return nil, fmt.Errorf("rand.int: min %d is above max %d", lo, hi)
+			}
+			return NewNumber(float64(lo + r.random.intN(hi-lo+1))), nil
+		}),
+		"float": newBuiltin("float", func(args []Value) (Value, error) {
+			if len(args) != 0 {
+				return nil, errors.New("rand.float takes no arguments")
+			}
+			return NewNumber(r.random.float()), nil
+		}),
+		"choice": newBuiltin("choice", func(args []Value) (Value, error) {
+			arr, err := arrayArgument("rand.choice", args)
+			if err != nil {
+				return nil, err
+			}
+			if len(arr.Elements) == 0 {
+				return nil, errors.New("rand.choice of an empty Array")
+			}
+			return arr.Elements[r.random.intN(int64(len(arr.Elements)))], nil
+		}),
+		"shuffle": newBuiltin("shuffle", func(args []Value) (Value, error) {
+			arr, err := arrayArgument("rand.shuffle", args)
+			if err != nil {
+				return nil, err
+			}
+			shuffled := append([]Value(nil), arr.Elements...)
+			r.random.shuffle(shuffled)
+			return &Array{Elements: shuffled}, nil
+		}),
+	})
+}
+
+// integerArgument returns args[i] as an integer within the range a Number
+// holds exactly.
+func integerArgument(name string, args []Value, i int) (int64, error) {
+	num, ok := args[i].(*Number)
+	if !ok || !isInteger(num.Value) || math.Abs(num.Value) > maxSafeInteger {
+		return 0, fmt.Errorf("%s expects an integer, got %s", name, args[i].Inspect())
+	}
+	return int64(num.Value), nil
+}
+
+func arrayArgument(name string, args []Value) (*Array, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s expects 1 argument, got %d", name, len(args))
+	}
+	arr, ok := args[0].(*Array)
+	if !ok {
+		return nil, fmt.Errorf("%s expects an Array, got %s", name, args[0].Type())
+	}
+	return arr, nil
+}
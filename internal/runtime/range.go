@@ -0,0 +1,89 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Range is the span of Numbers from From up to To, written from..to, or
+// from..=to when it includes To. Iterating a range counts up from From in
+// steps of one, and a Number is in it when it lies between the bounds, so
+// 2.5 in 0..10 holds although a loop over 0..10 never reaches 2.5.
+type Range struct {
+	From      float64
+	To        float64
+	Inclusive bool
+}
+
+func newRange(operator string, from, to Value) (*Range, error) {
+	l, lok := from.(*Number)
+	r, rok := to.(*Number)
+	if !lok || !rok {
+		return nil, fmt.Errorf("range bounds must be Numbers, got %s %s %s", from.Type(), operator, to.Type())
+	}
+	return &Range{From: l.Value, To: r.Value, Inclusive: operator == "..="}, nil
+}
+
+// Type implements the Value interface for Range.
+func (r *Range) Type() string { return "Range" }
+
+// Inspect returns a human-readable representation of Range.
+func (r *Range) Inspect() string {
+	if r.Inclusive {
+		return formatNumber(r.From) + "..=" + formatNumber(r.To)
+	}
+	return formatNumber(r.From) + ".." + formatNumber(r.To)
+}
+
+// Contains reports whether n lies within the range's bounds.
+func (r *Range) Contains(n float64) bool {
+	if r.Inclusive {
+		return r.From <= n && n <= r.To
+	}
+	return r.From <= n && n < r.To
+}
+
+// each calls visit with From, From+1, and so on while they are in the range.
+func (r *Range) each(visit func(Value) (bool, error)) error {
+	for n := r.From; r.Contains(n); n++ {
+		if cont, err := visit(NewNumber(n)); err != nil || !cont {
+			return err
+		}
+	}
+	return nil
+}
+
+// evalMembership evaluates needle in haystack: whether a Range holds a
+// Number, an Array or Set has an element equal to needle, a String contains
+// needle as a substring, or an Object has needle as a key.
+func evalMembership(needle, haystack Value) (Value, error) {
+	switch h := haystack.(type) {
+	case *Range:
+		n, ok := needle.(*Number)
+		return NewBoolean(ok && h.Contains(n.Value)), nil
+	case *Array:
+		for _, el := range h.Elements {
+			if equals(needle, el) {
+				return TrueValue, nil
+			}
+		}
+		return FalseValue, nil
+	case *Set:
+		return NewBoolean(h.Has(needle)), nil
+	case *String:
+		n, ok := needle.(*String)
+		if !ok {
+			return nil, fmt.Errorf("operator in needs a String to find in a String, got %s", needle.Type())
+		}
+		return NewBoolean(strings.Contains(h.Value, n.Value)), nil
+	case *Object:
+		n, ok := needle.(*String)
+		if !ok {
+			return nil, fmt.Errorf("operator in needs a String key to find in an Object, got %s", needle.Type())
+		}
+		_, found := h.Properties[n.Value]
+		return NewBoolean(found), nil
+	default:
+		return nil, fmt.Errorf("operator in not supported for %s", haystack.Type())
+	}
+}
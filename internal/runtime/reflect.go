@@ -0,0 +1,279 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+)
+
+// annotation is an annotation whose arguments have been evaluated.
+type annotation struct {
+	name string
+	args []Value
+}
+
+// annotatedValue is a declaration that carries annotations, with the value
+// it declared.
+type annotatedValue struct {
+	value       Value
+	annotations []annotation
+}
+
+// annotationRegistry records the annotated declarations a runtime has
+// evaluated, in the order they first ran, for the reflect module.
+type annotationRegistry struct {
+	mu      sync.Mutex
+	byKey   map[any]*annotatedValue
+	ordered []*annotatedValue
+}
+
+// annotationKey identifies the declaration behind val. Functions are keyed
+// by their declaration, so the copies bound to each receiver of a method
+// share the annotations of the method.
+func annotationKey(val Value) any {
+	if fn, ok := val.(*Function); ok && fn.Declaration != nil {
+		return fn.Declaration
+	}
+	return val
+}
+
+// annotate evaluates the arguments of annotations in env and records them
+// against val, the value their declaration produced. It passes err through
+// so declarations can hand it their result directly.
+func annotate(val Value, err error, annotations []*ast.Annotation, env *Environment) (Value, error) {
+	if err != nil || len(annotations) == 0 || env.control == nil {
+		return val, err
+	}
+	evaluated := make([]annotation, len(annotations))
+	for i, a := range annotations {
+		args := make([]Value, len(a.Arguments))
+		for j, arg := range a.Arguments {
+			v, err := evalExpression(arg, env)
+			if err != nil {
+				return nil, fmt.Errorf("annotation @%s: %w", a.Name.Name, err)
+			}
+			args[j] = v
+		}
+		evaluated[i] = annotation{name: a.Name.Name, args: args}
+	}
+	env.control.annotations.record(val, evaluated)
+	return val, nil
+}
+
+// record stores the annotations of val. A declaration that runs again, such
+// as a function declared in a loop, replaces what it recorded before but
+// keeps its place in the order.
+func (r *annotationRegistry) record(val Value, annotations []annotation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := annotationKey(val)
+	if entry, ok := r.byKey[key]; ok {
+		entry.value, entry.annotations = val, annotations
+		return
+	}
+	if r.byKey == nil {
+		r.byKey = make(map[any]*annotatedValue)
+	}
+	entry := &annotatedValue{value: val, annotations: annotations}
+	r.byKey[key] = entry
+	r.ordered = append(r.ordered, entry)
+}
+
+func (r *annotationRegistry) lookup(val Value) []annotation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry, ok := r.byKey[annotationKey(val)]; ok {
+		return entry.annotations
+	}
+	return nil
+}
+
+// annotated returns the values whose declarations carry an annotation
+// called name.
+func (r *annotationRegistry) annotated(name string) []Value {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var values []Value
+	for _, entry := range r.ordered {
+		for _, a := range entry.annotations {
+			if a.name == name {
+				values = append(values, entry.value)
+				break
+			}
+		}
+	}
+	return values
+}
+
+// object presents a as { name, args }. The arguments are copied into a new
+// array so a script cannot change what later lookups return.
+func (a annotation) object() *Object {
+	return NewObject(
+		Property{"name", NewString(a.name)},
+		Property{"args", &Array{Elements: append([]Value(nil), a.args...)}},
+	)
+}
+
+// instanceFields returns the fields of a struct, class, or enum instance in
+// declaration order.
+func instanceFields(val Value) ([]string, map[string]Value, bool) {
+	switch v := val.(type) {
+	case *StructInstance:
+		return fieldOrder(v.Definition.Fields, v.Fields), v.Fields, true
+	case *ClassInstance:
+		return fieldOrder(v.Definition.Fields, v.Fields), v.Fields, true
+	case *EnumInstance:
+		return fieldOrder(v.Order, v.Fields), v.Fields, true
+	}
+	return nil, nil, false
+}
+
+// methodNames returns the sorted names of the methods a struct, class, or
+// enum type, or an instance of one, defines, including those a class
+// inherits.
+func methodNames(val Value) ([]string, bool) {
+	switch v := val.(type) {
+	case *StructInstance:
+		val = v.Definition
+	case *ClassInstance:
+		val = v.Definition
+	case *EnumInstance:
+		val = v.Enum
+	}
+	var methods []map[string]*Function
+	switch v := val.(type) {
+	case *StructType:
+		methods = append(methods, v.Methods)
+	case *ClassType:
+		for class := v; class != nil; class = class.Super {
+			methods = append(methods, class.Methods)
+		}
+	case *EnumType:
+		methods = append(methods, v.Methods)
+	default:
+		return nil, false
+	}
+	var names []string
+	for _, set := range methods {
+		for name := range set {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+	return names, true
+}
+
+func stringNames(names []string) *Array {
+	elements := make([]Value, len(names))
+	for i, name := range names {
+		elements[i] = NewString(name)
+	}
+	return &Array{Elements: elements}
+}
+
+// newReflectModule lets a program inspect its values and declarations: the
+// type and fields of a value, the methods of a type, and the annotations of
+// a declaration. It is enough to write serializers, dependency injection,
+// routers, and test runners in Selene.
+func newReflectModule(r *Runtime) *Module {
+	registry := &r.control.annotations
+	return NewModule("reflect", map[string]Value{
+		"typeOf": newBuiltin("typeOf", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, errors.New("reflect.typeOf expects a value")
+			}
+			return NewString(args[0].Type()), nil
+		}),
+		"fields": newBuiltin("fields", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, errors.New("reflect.fields expects an instance")
+			}
+			names, values, ok := instanceFields(args[0])
+			if !ok {
+				return nil, fmt.Errorf("reflect.fields expects a struct, class, or enum instance, got %s", args[0].Type())
+			}
+			elements := make([]Value, len(names))
+			for i, name := range names {
+				elements[i] = NewObject(Property{"name", NewString(name)}, Property{"value", values[name]})
+			}
+			return &Array{Elements: elements}, nil
+		}),
+		"methods": newBuiltin("methods", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, errors.New("reflect.methods expects a type")
+			}
+			names, ok := methodNames(args[0])
+			if !ok {
+				return nil, fmt.Errorf("reflect.methods expects a struct, class, or enum type or instance, got %s", args[0].Type())
+			}
+			return stringNames(names), nil
+		}),
+		"construct": newBuiltin("construct", func(args []Value) (Value, error) {
+			if len(args) != 2 {
+				return nil, errors.New("reflect.construct expects a type and an Array of arguments")
+			}
+			switch args[0].(type) {
+			case *StructType, *ClassType:
+			default:
+				return nil, fmt.Errorf("reflect.construct expects a struct or class type, got %s", args[0].Inspect())
+			}
+			ctorArgs, ok := args[1].(*Array)
+			if !ok {
+				return nil, fmt.Errorf("reflect.construct expects an Array of arguments, got %s", args[1].Type())
+			}
+			return applyFunction(args[0], slices.Clone(ctorArgs.Elements))
+		}),
+		"has": newBuiltin("has", func(args []Value) (Value, error) {
+			if len(args) != 2 {
+				return nil, errors.New("reflect.has expects a value and a member name")
+			}
+			name, ok := args[1].(*String)
+			if !ok {
+				return nil, fmt.Errorf("reflect.has expects a String name, got %s", args[1].Inspect())
+			}
+			_, found, err := getProperty(args[0], name.Value)
+			return NewBoolean(err == nil && found), nil
+		}),
+		"annotations": newBuiltin("annotations", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, errors.New("reflect.annotations expects a declared value")
+			}
+			annotations := registry.lookup(args[0])
+			elements := make([]Value, len(annotations))
+			for i, a := range annotations {
+				elements[i] = a.object()
+			}
+			return &Array{Elements: elements}, nil
+		}),
+		"annotation": newBuiltin("annotation", func(args []Value) (Value, error) {
+			if len(args) != 2 {
+				return nil, errors.New("reflect.annotation expects a declared value and an annotation name")
+			}
+			name, ok := args[1].(*String)
+			if !ok {
+				return nil, fmt.Errorf("reflect.annotation expects a String name, got %s", args[1].Inspect())
+			}
+			for _, a := range registry.lookup(args[0]) {
+				if a.name == name.Value {
+					return a.object(), nil
+				}
+			}
+			return NullValue, nil
+		}),
+		"annotated": newBuiltin("annotated", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, errors.New("reflect.annotated expects an annotation name")
+			}
+			name, ok := args[0].(*String)
+			if !ok {
+				return nil, fmt.Errorf("reflect.annotated expects a String name, got %s", args[0].Inspect())
+			}
+			return &Array{Elements: registry.annotated(name.Value)}, nil
+		}),
+	})
+}
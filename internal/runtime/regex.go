@@ -0,0 +1,172 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Regex is a compiled regular expression produced by regex.compile. Patterns
+// use Go's RE2 syntax, and match positions are reported in runes so they line
+// up with string indexing.
+type Regex struct {
+	re *regexp.Regexp
+}
+
+// Type implements the Value interface for Regex.
+func (r *Regex) Type() string { return "Regex" }
+
+// Inspect returns a human-readable representation of Regex.
+func (r *Regex) Inspect() string {
+	b := borrowBuilder()
+	b.WriteString("<regex ")
+	b.WriteString(r.re.String())
+	b.WriteByte('>')
+	return finishBuilder(b)
+}
+
+func newRegexModule() *Module {
+	return NewModule("regex", map[string]Value{
+		"compile": newBuiltin("compile", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, errors.New("regex.compile expects a pattern")
+			}
+			pattern, ok := args[0].(*String)
+			if !ok {
+				return nil, fmt.Errorf("regex pattern must be String, got %s", args[0].Type())
+			}
+			re, err := regexp.Compile(pattern.Value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex: %w", err)
+			}
+			return &Regex{re: re}, nil
+		}),
+	})
+}
+
+func regexProperty(r *Regex, property string) (Value, bool, error) {
+	switch property {
+	case "pattern":
+		return NewString(r.re.String()), true, nil
+	case "match":
+		return newBuiltin("match", func(args []Value) (Value, error) {
+			text, err := regexSubject("match", args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return NewBoolean(r.re.MatchString(text)), nil
+		}), true, nil
+	case "find":
+		return newBuiltin("find", func(args []Value) (Value, error) {
+			text, err := regexSubject("find", args, 1)
+			if err != nil {
+				return nil, err
+			}
+			loc := r.re.FindStringSubmatchIndex(text)
+			if loc == nil {
+				return NullValue, nil
+			}
+			return r.matchObject(text, loc), nil
+		}), true, nil
+	case "findAll":
+		return newBuiltin("findAll", func(args []Value) (Value, error) {
+			text, err := regexSubject("findAll", args, 2)
+			if err != nil {
+				return nil, err
+			}
+			limit, err := regexLimit("findAll", args)
+			if err != nil {
+				return nil, err
+			}
+			locs := r.re.FindAllStringSubmatchIndex(text, limit)
+			matches := make([]Value, len(locs))
+			for i, loc := range locs {
+				matches[i] = r.matchObject(text, loc)
+			}
+			return &Array{Elements: matches}, nil
+		}), true, nil
+	case "replace":
+		return newBuiltin("replace", func(args []Value) (Value, error) {
+			if len(args) != 2 {
+				return nil, errors.New("replace expects a string and a replacement")
+			}
+			text, ok := args[0].(*String)
+			if !ok {
+				return nil, fmt.Errorf("replace expects String, got %s", args[0].Type())
+			}
+			repl, ok := args[1].(*String)
+			if !ok {
+				return nil, fmt.Errorf("replacement must be String, got %s", args[1].Type())
+			}
+			return NewString(r.re.ReplaceAllString(text.Value, repl.Value)), nil
+		}), true, nil
+	case "split":
+		return newBuiltin("split", func(args []Value) (Value, error) {
+			text, err := regexSubject("split", args, 2)
+			if err != nil {
+				return nil, err
+			}
+			limit, err := regexLimit("split", args)
+			if err != nil {
+				return nil, err
+			}
+			parts := r.re.Split(text, limit)
+			elements := make([]Value, len(parts))
+			for i, part := range parts {
+				elements[i] = NewString(part)
+			}
+			return &Array{Elements: elements}, nil
+		}), true, nil
+	default:
+		return nil, false, fmt.Errorf("unknown regex property %s", property)
+	}
+}
+
+// matchObject describes a single match: text, rune index, positional groups
+// (null for groups that did not participate), and named groups.
+func (r *Regex) matchObject(text string, loc []int) Value {
+	names := r.re.SubexpNames()
+	groups := make([]Value, 0, len(names)-1)
+	var named []Property
+	for i := 1; i < len(names); i++ {
+		var group Value = NullValue
+		if start, end := loc[2*i], loc[2*i+1]; start >= 0 {
+			group = NewString(text[start:end])
+		}
+		groups = append(groups, group)
+		if names[i] != "" {
+			named = append(named, Property{names[i], group})
+		}
+	}
+	return NewObject(
+		Property{"text", NewString(text[loc[0]:loc[1]])},
+		Property{"index", NewNumber(float64(utf8.RuneCountInString(text[:loc[0]])))},
+		Property{"groups", &Array{Elements: groups}},
+		Property{"named", NewObject(named...)},
+	)
+}
+
+func regexSubject(method string, args []Value, maxArgs int) (string, error) {
+	if len(args) == 0 || len(args) > maxArgs {
+		return "", fmt.Errorf("%s expects a string", method)
+	}
+	text, ok := args[0].(*String)
+	if !ok {
+		return "", fmt.Errorf("%s expects String, got %s", method, args[0].Type())
+	}
+	return text.Value, nil
+}
+
+// regexLimit reads the optional count argument of findAll and split. A
+// missing or negative limit means no limit.
+func regexLimit(method string, args []Value) (int, error) {
+	if len(args) < 2 {
+		return -1, nil
+	}
+	num, ok := args[1].(*Number)
+	if !ok || float64(int(num.Value)) != num.Value {
+		return 0, fmt.Errorf("%s limit must be an integer", method)
+	}
+	return int(num.Value), nil
+}
@@ -0,0 +1,241 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/cybellereaper/selenelang/internal/lexer"
+	"github.com/cybellereaper/selenelang/internal/token"
+)
+
+// BuiltinAPIVersion is the version of the host registration API implemented
+// by Register and Define. Version 1 was NewBuiltin with a bare BuiltinFunction.
+// Host code written against a version keeps compiling as later versions add
+// fields to BuiltinSpec and BuiltinCall.
+const BuiltinAPIVersion = 2
+
+// BuiltinSpec describes a host function exposed to scripts. Zero values
+// keep the behaviour of earlier versions, so new fields never break existing
+// registrations.
+type BuiltinSpec struct {
+	// Name is the global the builtin is bound to.
+	Name string
+	// Call implements the builtin.
+	Call func(call BuiltinCall) (Value, error)
+	// MinArgs is the fewest arguments Call accepts.
+	MinArgs int
+	// MaxArgs is the most arguments Call accepts. Zero means no limit.
+	MaxArgs int
+}
+
+// BuiltinCall carries one invocation of a registered builtin.
+type BuiltinCall struct {
+	// Args holds the arguments, already checked against MinArgs and MaxArgs.
+	Args []Value
+	// Site is the position of the call expression, or the zero position
+	// when the builtin was called indirectly.
+	Site token.Position
+}
+
+// Register binds spec as a global builtin. Under a Policy that lists
+// Builtins, spec.Name must be one of them.
+func (r *Runtime) Register(spec BuiltinSpec) error {
+	if spec.Name == "" {
+		return errors.New("builtin name must not be empty")
+	}
+	if spec.Call == nil {
+		return fmt.Errorf("builtin %s has no Call", spec.Name)
+	}
+	if spec.MinArgs < 0 || spec.MaxArgs < 0 || (spec.MaxArgs > 0 && spec.MaxArgs < spec.MinArgs) {
+		return fmt.Errorf("builtin %s has invalid argument bounds %d..%d", spec.Name, spec.MinArgs, spec.MaxArgs)
+	}
+	if err := r.allowGlobal(spec.Name); err != nil {
+		return err
+	}
+	r.env.Set(spec.Name, newSitedBuiltin(spec.Name, func(site token.Position, args []Value) (Value, error) {
+		if len(args) < spec.MinArgs {
+			return nil, fmt.Errorf("%s expects at least %d arguments, got %d", spec.Name, spec.MinArgs, len(args))
+		}
+		if spec.MaxArgs > 0 && len(args) > spec.MaxArgs {
+			return nil, fmt.Errorf("%s expects at most %d arguments, got %d", spec.Name, spec.MaxArgs, len(args))
+		}
+		return spec.Call(BuiltinCall{Args: args, Site: site})
+	}))
+	return nil
+}
+
+// Define binds a Go value as a global, converting it with ToValue.
+func (r *Runtime) Define(name string, value any) error {
+	v, err := ToValue(value)
+	if err != nil {
+		return fmt.Errorf("define %s: %w", name, err)
+	}
+	if err := r.allowGlobal(name); err != nil {
+		return err
+	}
+	r.env.Set(name, v)
+	return nil
+}
+
+func (r *Runtime) allowGlobal(name string) error {
+	if r.policy.Builtins != nil && !slices.Contains(r.policy.Builtins, name) {
+		return errPolicy("global %s is not allowed", name)
+	}
+	return nil
+}
+
+// ToValue converts a Go value to the Selene value that represents it:
+// nil, bools, strings, and numeric types map to Null, Boolean, String, and
+// Number, slices to Array, and maps with string keys to Object. A Value is
+// returned unchanged. Hosts that build values this way keep working when
+// the runtime's own representation of a type changes.
+func ToValue(value any) (Value, error) {
+	switch v := value.(type) {
+	case nil:
+		return NullValue, nil
+	case Value:
+		return v, nil
+	case bool:
+		return NewBoolean(v), nil
+	case string:
+		return NewString(v), nil
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewNumber(float64(rv.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return NewNumber(float64(rv.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return NewNumber(rv.Float()), nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]Value, rv.Len())
+		for i := range elements {
+			el, err := ToValue(rv.Index(i).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			elements[i] = el
+		}
+		return &Array{Elements: elements}, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("cannot convert %T: map keys must be strings", value)
+		}
+		properties := make(map[string]Value, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			el, err := ToValue(iter.Value().Interface())
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", key, err)
+			}
+			properties[key] = el
+		}
+		return &Object{Properties: properties}, nil
+	}
+	return nil, fmt.Errorf("cannot convert %T to a Selene value", value)
+}
+
+// ModuleFactory builds a registered module for one runtime. It is called by
+// New, so it sees the runtime before any policy or sandbox is applied and
+// should consult them when its members are called rather than when it runs.
+type ModuleFactory func(r *Runtime) *Module
+
+var registeredModules = struct {
+	mu        sync.Mutex
+	names     []string
+	factories map[string]ModuleFactory
+}{factories: make(map[string]ModuleFactory)}
+
+// stdGlobals are the globals New binds itself, which registered modules may
+// not replace.
+var stdGlobals = []string{"print", "input", "readLine", "stdin", "stdout", "stderr", "format", "set", "bytes", "freeze", "isFrozen", "scope", "regex", "spawn", "channel", "os", "fs", "path", "time", "math", "rand", "tasks", "reflect", "Result", "Option", "Error", "PermissionError",
+	"isNaN", "isFinite", "checkedAdd", "checkedSub", "checkedMul", "checkedDiv", "saturatingAdd", "saturatingSub", "saturatingMul"}
+
+// RegisterModule adds a module that every runtime created afterwards binds
+// as the global name, alongside the standard modules. It is meant to be
+// called from the init function of a package linked into the host or loaded
+// as a plugin. Names must be identifiers and may not repeat a standard
+// module or another registration.
+func RegisterModule(name string, factory ModuleFactory) error {
+	if !lexer.IsIdentifier(name) {
+		return fmt.Errorf("module name %q is not an identifier", name)
+	}
+	if factory == nil {
+		return fmt.Errorf("module %s has no factory", name)
+	}
+	if slices.Contains(stdGlobals, name) {
+		return fmt.Errorf("module %s would replace a standard global", name)
+	}
+	registeredModules.mu.Lock()
+	defer registeredModules.mu.Unlock()
+	if _, exists := registeredModules.factories[name]; exists {
+		return fmt.Errorf("module %s is already registered", name)
+	}
+	registeredModules.names = append(registeredModules.names, name)
+	registeredModules.factories[name] = factory
+	return nil
+}
+
+// StandardGlobals returns the names of every global New binds: the standard
+// builtins and modules, followed by the registered modules.
+func StandardGlobals() []string {
+	return append(slices.Clone(stdGlobals), RegisteredModules()...)
+}
+
+// RegisteredModules returns the names passed to RegisterModule, in
+// registration order.
+func RegisteredModules() []string {
+	registeredModules.mu.Lock()
+	defer registeredModules.mu.Unlock()
+	return slices.Clone(registeredModules.names)
+}
+
+// installRegisteredModules binds the registered modules in r's environment.
+func (r *Runtime) installRegisteredModules() {
+	registeredModules.mu.Lock()
+	names := slices.Clone(registeredModules.names)
+	factories := make([]ModuleFactory, len(names))
+	for i, name := range names {
+		factories[i] = registeredModules.factories[name]
+	}
+	registeredModules.mu.Unlock()
+	for i, name := range names {
+		if mod := factories[i](r); mod != nil {
+			r.env.Set(name, mod)
+		}
+	}
+}
+
+var deprecation = struct {
+	mu     sync.Mutex
+	w      io.Writer
+	warned map[string]bool
+}{w: os.Stderr, warned: make(map[string]bool)}
+
+// SetDeprecationOutput directs the warnings printed the first time a process
+// uses each deprecated host API. It defaults to standard error; pass nil to
+// silence them.
+func SetDeprecationOutput(w io.Writer) {
+	deprecation.mu.Lock()
+	defer deprecation.mu.Unlock()
+	deprecation.w = w
+}
+
+func warnDeprecated(api, replacement string) {
+	deprecation.mu.Lock()
+	defer deprecation.mu.Unlock()
+	if deprecation.warned[api] {
+		return
+	}
+	deprecation.warned[api] = true
+	if deprecation.w != nil {
+		fmt.Fprintf(deprecation.w, "selene: %s is deprecated; use %s (builtin API version %d)\n", api, replacement, BuiltinAPIVersion)
+	}
+}
@@ -0,0 +1,357 @@
+package runtime
+
+import "github.com/cybellereaper/selenelang/internal/ast"
+
+// Inside a function body the interpreter stores most locals in slot arrays
+// rather than maps. Before a function first runs, resolveFrame walks its body
+// once, mirroring the environments the evaluator creates, and records for
+// each scope the names it binds and for each identifier the scope and slot it
+// reads. Anything the walk cannot predict falls back to the map-based chain:
+// a function using a construct it does not model gets no frame at all, and
+// regions whose environments it does not track (match cases, condition
+// clauses, nested functions) run on ordinary map environments.
+
+// frameLayout is the resolved shape of one function body.
+type frameLayout struct {
+	call   *scopeLayout
+	scopes map[ast.Node]*scopeLayout
+	refs   map[*ast.Identifier]slotRef
+}
+
+// scopeLayout lists the bindings of one environment inside a frame; the
+// binding for names[i] lives in slots[i].
+type scopeLayout struct {
+	names []string
+	index map[string]int
+	frame *frameLayout
+}
+
+// scopeIndexThreshold is the number of bindings above which a scope indexes
+// its names with a map instead of scanning them.
+const scopeIndexThreshold = 8
+
+func (l *scopeLayout) slot(name string) int {
+	if l.index != nil {
+		if i, ok := l.index[name]; ok {
+			return i
+		}
+		return -1
+	}
+	for i, n := range l.names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// slotRef locates an identifier's binding hops environments out from the one
+// it is evaluated in. A negative slot means the binding is outside the
+// function and is looked up by name from there.
+type slotRef struct {
+	hops int
+	slot int
+}
+
+// frameFor returns the cached layout of decl, resolving it on first use. It
+// returns nil for functions that must run on map environments.
+func (c *runControl) frameFor(decl *ast.FunctionDeclaration) *frameLayout {
+	if c == nil || decl.Generator {
+		return nil
+	}
+	if frame, ok := c.frames.Load(decl); ok {
+		return frame.(*frameLayout)
+	}
+	frame, _ := c.frames.LoadOrStore(decl, resolveFrame(decl))
+	return frame.(*frameLayout)
+}
+
+type resolver struct {
+	frame  *frameLayout
+	scopes []*resolverScope
+	failed bool
+}
+
+type resolverScope struct {
+	layout   *scopeLayout
+	declared map[string]bool
+}
+
+func resolveFrame(decl *ast.FunctionDeclaration) *frameLayout {
+	r := &resolver{frame: &frameLayout{
+		scopes: make(map[ast.Node]*scopeLayout),
+		refs:   make(map[*ast.Identifier]slotRef),
+	}}
+	params := make([]string, 0, len(decl.Params))
+	for _, param := range decl.Params {
+		params = append(params, param.Name.Name)
+	}
+	var body []ast.Statement
+	if !decl.IsExprBody && decl.Body != nil {
+		body = decl.Body.Statements
+	}
+	r.push(nil, params, body)
+	r.frame.call = r.scopes[0].layout
+	if decl.IsExprBody {
+		r.expr(decl.BodyExpr)
+	} else {
+		r.stmts(body)
+	}
+	if r.failed {
+		return nil
+	}
+	return r.frame
+}
+
+// push opens a scope binding the given names up front plus everything the
+// statements declare directly in it. node is the AST node the evaluator
+// passes to newScope for this environment.
+func (r *resolver) push(node ast.Node, bound []string, stmts []ast.Statement) {
+	layout := &scopeLayout{frame: r.frame}
+	scope := &resolverScope{layout: layout, declared: make(map[string]bool, len(bound))}
+	add := func(name string) {
+		if layout.slot(name) < 0 {
+			layout.names = append(layout.names, name)
+		}
+	}
+	for _, name := range bound {
+		add(name)
+		scope.declared[name] = true
+	}
+	for _, stmt := range stmts {
+		collectDeclarations(stmt, add)
+	}
+	if len(layout.names) > scopeIndexThreshold {
+		layout.index = make(map[string]int, len(layout.names))
+		for i, name := range layout.names {
+			layout.index[name] = i
+		}
+	}
+	if node != nil {
+		r.frame.scopes[node] = layout
+	}
+	r.scopes = append(r.scopes, scope)
+}
+
+func (r *resolver) pop() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+// collectDeclarations reports the names stmt binds in the environment it
+// runs in. Bodies of if and while statements that are not blocks run in that
+// same environment.
+func collectDeclarations(stmt ast.Statement, add func(string)) {
+	switch node := stmt.(type) {
+	case *ast.VariableDeclaration:
+		if node.Pattern != nil {
+			for _, id := range ast.PatternBindings(node.Pattern) {
+				add(id.Name)
+			}
+			return
+		}
+		add(node.Name.Name)
+	case *ast.FunctionDeclaration:
+		if !node.IsExtension {
+			add(node.Name.Name)
+		}
+	case *ast.InterfaceDeclaration:
+		add(node.Name.Name)
+	case *ast.StructDeclaration:
+		add(node.Name.Name)
+	case *ast.ClassDeclaration:
+		add(node.Name.Name)
+	case *ast.EnumDeclaration:
+		add(node.Name.Name)
+	case *ast.ContractDeclaration:
+		add(node.Name.Name)
+	case *ast.IfStatement:
+		collectDeclarations(node.Consequence, add)
+		collectDeclarations(node.Alternative, add)
+	case *ast.WhileStatement:
+		collectDeclarations(node.Body, add)
+	}
+}
+
+func (r *resolver) declare(name string) {
+	r.scopes[len(r.scopes)-1].declared[name] = true
+}
+
+// ref resolves id to the innermost scope binding its name. A name that scope
+// only binds further down is left to the map lookup, since an outer binding
+// may still be visible at this point.
+func (r *resolver) ref(id *ast.Identifier) {
+	depth := len(r.scopes) - 1
+	for k := depth; k >= 0; k-- {
+		scope := r.scopes[k]
+		slot := scope.layout.slot(id.Name)
+		if slot < 0 {
+			continue
+		}
+		if scope.declared[id.Name] {
+			r.frame.refs[id] = slotRef{hops: depth - k, slot: slot}
+		}
+		return
+	}
+	r.frame.refs[id] = slotRef{hops: depth + 1, slot: -1}
+}
+
+func (r *resolver) stmts(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		r.stmt(stmt)
+	}
+}
+
+func (r *resolver) stmt(stmt ast.Statement) {
+	for _, a := range ast.Annotations(stmt) {
+		for _, arg := range a.Arguments {
+			r.expr(arg)
+		}
+	}
+	switch node := stmt.(type) {
+	case nil:
+	case *ast.ExpressionStatement:
+		r.expr(node.Expression)
+	case *ast.VariableDeclaration:
+		r.expr(node.Value)
+		if node.Pattern != nil {
+			for _, id := range ast.PatternBindings(node.Pattern) {
+				r.declare(id.Name)
+			}
+			return
+		}
+		r.declare(node.Name.Name)
+	case *ast.FunctionDeclaration:
+		if !node.IsExtension {
+			r.declare(node.Name.Name)
+		}
+	case *ast.InterfaceDeclaration:
+		r.declare(node.Name.Name)
+	case *ast.ImplDeclaration:
+	case *ast.StructDeclaration:
+		r.declare(node.Name.Name)
+	case *ast.ClassDeclaration:
+		r.declare(node.Name.Name)
+	case *ast.EnumDeclaration:
+		r.declare(node.Name.Name)
+	case *ast.ContractDeclaration:
+		r.declare(node.Name.Name)
+	case *ast.BlockStatement:
+		r.block(node, nil)
+	case *ast.IfStatement:
+		r.expr(node.Condition)
+		r.stmt(node.Consequence)
+		r.stmt(node.Alternative)
+	case *ast.WhileStatement:
+		r.expr(node.Condition)
+		r.stmt(node.Body)
+	case *ast.DoWhileStatement:
+		r.block(node.Body, nil)
+		r.expr(node.Condition)
+	case *ast.ForStatement:
+		r.push(node, nil, []ast.Statement{node.Init, node.Body})
+		r.stmt(node.Init)
+		r.expr(node.Condition)
+		r.stmt(node.Body)
+		r.expr(node.Post)
+		r.pop()
+	case *ast.ForInStatement:
+		r.expr(node.Iterable)
+		r.block(node.Body, []string{node.Binding.Name})
+	case *ast.UsingStatement:
+		r.expr(node.Value)
+		var bound []string
+		if node.Name != nil {
+			bound = []string{node.Name.Name}
+		}
+		r.block(node.Body, bound)
+	case *ast.TryStatement:
+		r.block(node.Body, nil)
+		for _, clause := range node.Catches {
+			var bound []string
+			if clause.Identifier != nil {
+				bound = []string{clause.Identifier.Name}
+			}
+			if clause.Type != nil {
+				r.ref(clause.Type.Name)
+			}
+			r.block(clause.Body, bound)
+		}
+		r.block(node.Finally, nil)
+	case *ast.ConditionStatement:
+		for _, clause := range node.Clauses {
+			r.expr(clause.Test)
+		}
+	case *ast.MatchStatement:
+		r.expr(node.Value)
+	case *ast.ReturnStatement:
+		r.expr(node.Value)
+	case *ast.ThrowStatement:
+		r.expr(node.Value)
+	case *ast.BreakStatement, *ast.ContinueStatement, *ast.RethrowStatement:
+	default:
+		r.failed = true
+	}
+}
+
+func (r *resolver) block(block *ast.BlockStatement, bound []string) {
+	if block == nil {
+		return
+	}
+	r.push(block, bound, block.Statements)
+	r.stmts(block.Statements)
+	r.pop()
+}
+
+func (r *resolver) expr(expr ast.Expression) {
+	switch node := expr.(type) {
+	case nil:
+	case *ast.Identifier:
+		r.ref(node)
+	case *ast.NumberLiteral, *ast.StringLiteral, *ast.BooleanLiteral, *ast.NullLiteral:
+	case *ast.ArrayLiteral:
+		for _, element := range node.Elements {
+			r.expr(element)
+		}
+	case *ast.SetLiteral:
+		for _, element := range node.Elements {
+			r.expr(element)
+		}
+	case *ast.ObjectLiteral:
+		for _, pair := range node.Pairs {
+			r.expr(pair.Value)
+		}
+	case *ast.AwaitExpression:
+		r.expr(node.Expression)
+	case *ast.YieldExpression:
+		r.expr(node.Value)
+	case *ast.PrefixExpression:
+		r.expr(node.Right)
+	case *ast.InfixExpression:
+		r.expr(node.Left)
+		r.expr(node.Right)
+	case *ast.AssignmentExpression:
+		r.expr(node.Value)
+		r.expr(node.Target)
+	case *ast.ElvisExpression:
+		r.expr(node.Left)
+		r.expr(node.Right)
+	case *ast.CallExpression:
+		r.expr(node.Callee)
+		for _, arg := range node.Arguments {
+			r.expr(arg)
+		}
+	case *ast.IndexExpression:
+		r.expr(node.Collection)
+		r.expr(node.Index)
+	case *ast.MemberExpression:
+		r.expr(node.Object)
+	case *ast.NonNullAssertion:
+		r.expr(node.Expression)
+	case *ast.PropagateExpression:
+		r.expr(node.Expression)
+	case *ast.IncrementExpression:
+		r.expr(node.Target)
+	default:
+		r.failed = true
+	}
+}
@@ -2,17 +2,22 @@
 package runtime
 
 import (
+	"bytes"
+	"cmp"
 	"errors"
 	"fmt"
 	"maps"
 	"math"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/cybellereaper/selenelang/internal/ast"
 	"github.com/cybellereaper/selenelang/internal/lexer"
 	"github.com/cybellereaper/selenelang/internal/parser"
+	"github.com/cybellereaper/selenelang/internal/printer"
 	"github.com/cybellereaper/selenelang/internal/token"
 )
 
@@ -109,6 +114,9 @@ var FalseValue Value = &Boolean{Value: false}
 type ErrorValue struct {
 	Message string
 	Cause   Value
+	// Stack holds the calls the error unwound through before a catch clause
+	// caught it.
+	Stack []StackFrame
 }
 
 // Type implements the Value interface for ErrorValue.
@@ -133,6 +141,15 @@ func toErrorValue(val Value) *ErrorValue {
 		return v
 	case *String:
 		return &ErrorValue{Message: v.Value}
+	case *ClassInstance:
+		if v.Definition.isError() {
+			cause := v.Fields["cause"]
+			if cause == NullValue {
+				cause = nil
+			}
+			return &ErrorValue{Message: errorText(v), Cause: cause}
+		}
+		return &ErrorValue{Message: v.Inspect(), Cause: val}
 	default:
 		return &ErrorValue{Message: v.Inspect(), Cause: val}
 	}
@@ -141,6 +158,9 @@ func toErrorValue(val Value) *ErrorValue {
 // Array represents an ordered collection of values.
 type Array struct {
 	Elements []Value
+	// frozen marks an Array freeze returned, whose elements cannot be
+	// assigned.
+	frozen bool
 }
 
 // Type implements the Value interface for Array.
@@ -165,23 +185,57 @@ func (a *Array) Inspect() string {
 	return finishBuilder(b)
 }
 
-// Object models a dynamic map of string keys to values.
+// Object models a dynamic map of string keys to values. Its properties keep
+// the order they were added in: Inspect, valuejson, and Keys list them that
+// way, so an object prints the same on every run.
 type Object struct {
 	Properties map[string]Value
+	// keys is the insertion order of Properties. Entries added to the map
+	// directly, which have no recorded order, follow them sorted.
+	keys []string
+	// frozen marks an Object freeze returned, whose properties cannot be
+	// assigned.
+	frozen bool
+}
+
+// Property is one key and value of an Object.
+type Property struct {
+	Key   string
+	Value Value
+}
+
+// NewObject builds an Object from props in order. A repeated key keeps its
+// first position and its last value.
+func NewObject(props ...Property) *Object {
+	obj := &Object{Properties: make(map[string]Value, len(props)), keys: make([]string, 0, len(props))}
+	for _, prop := range props {
+		if _, ok := obj.Properties[prop.Key]; !ok {
+			obj.keys = append(obj.keys, prop.Key)
+		}
+		obj.Properties[prop.Key] = prop.Value
+	}
+	return obj
 }
 
 // Type implements the Value interface for Object.
 func (o *Object) Type() string { return "Object" }
 
+// Keys returns the property names in insertion order.
+func (o *Object) Keys() []string {
+	return fieldOrder(o.keys, o.Properties)
+}
+
 // Inspect returns a human-readable representation of Object.
 func (o *Object) Inspect() string {
-	return inspectFields("", o.Properties)
+	return inspectOrderedFields("", o.Keys(), o.Properties)
 }
 
-// Module captures exported bindings from a loaded module.
+// Module captures exported bindings from a loaded module. Exports declared
+// in a module block keep their declaration order; the rest are sorted.
 type Module struct {
 	Name    string
 	Exports map[string]Value
+	keys    []string
 }
 
 // NewModule constructs a module value with the provided exports.
@@ -193,12 +247,17 @@ func NewModule(name string, exports map[string]Value) *Module {
 	return &Module{Name: name, Exports: clone}
 }
 
+// Keys returns the export names in declaration order.
+func (m *Module) Keys() []string {
+	return fieldOrder(m.keys, m.Exports)
+}
+
 // Type implements the Value interface for Module.
 func (m *Module) Type() string { return "Module" }
 
 // Inspect returns a human-readable representation of Module.
 func (m *Module) Inspect() string {
-	keys := sortedKeys(m.Exports)
+	keys := m.Keys()
 
 	b := borrowBuilder()
 	b.Grow(len(m.Name) + len(keys)*4 + 10)
@@ -239,13 +298,21 @@ func (s *StructType) Inspect() string {
 type StructInstance struct {
 	Definition *StructType
 	Fields     map[string]Value
+	// frozen marks an instance freeze returned, whose fields hold frozen
+	// values.
+	frozen bool
 }
 
 // Type implements the Value interface for StructInstance.
 func (s *StructInstance) Type() string { return s.Definition.Name }
 
 // Inspect returns a human-readable representation of StructInstance.
+// A struct that defines toString is shown by calling it; if that fails, the
+// fields are shown instead.
 func (s *StructInstance) Inspect() string {
+	if text, ok, err := customDisplay(s); ok && err == nil {
+		return text
+	}
 	return inspectFields(s.Definition.Name, s.Fields)
 }
 
@@ -255,7 +322,23 @@ type ClassType struct {
 	Fields  []string
 	Methods map[string]*Function
 	Static  map[string]Value
+	// Getters and Setters are the property accessors the class declares
+	// itself; lookupAccessor finds inherited ones.
+	Getters map[string]*Function
+	Setters map[string]*Function
 	Super   *ClassType
+	// Abstract marks a class that cannot be instantiated. abstract holds
+	// the abstract methods the class and its superclasses declare that no
+	// class in between implements.
+	Abstract bool
+	abstract []string
+	// Contracts are the contracts the class declares it implements, and
+	// invariants the conditions its body states for every instance.
+	Contracts  []*Contract
+	invariants []*ast.ContractInvariant
+	// body is the environment the class body ran in, which holds the
+	// current values of its statics.
+	body *Environment
 }
 
 // Type implements the Value interface for ClassType.
@@ -274,13 +357,24 @@ func (c *ClassType) Inspect() string {
 type ClassInstance struct {
 	Definition *ClassType
 	Fields     map[string]Value
+	// frozen marks an instance freeze returned, whose fields cannot be
+	// assigned.
+	frozen bool
 }
 
 // Type implements the Value interface for ClassInstance.
 func (c *ClassInstance) Type() string { return c.Definition.Name }
 
 // Inspect returns a human-readable representation of ClassInstance.
+// A class that defines or inherits toString is shown by calling it; if that
+// fails, the fields are shown instead.
 func (c *ClassInstance) Inspect() string {
+	if text, ok, err := customDisplay(c); ok && err == nil {
+		return text
+	}
+	if c.Definition.isError() {
+		return inspectError(c)
+	}
 	return inspectFields(c.Definition.Name, c.Fields)
 }
 
@@ -298,16 +392,28 @@ func (c *ClassType) lookupMethod(name string) (*Function, bool) {
 }
 
 func (c *ClassType) lookupStatic(name string) (Value, bool) {
-	if c == nil {
+	owner := c.staticOwner(name)
+	if owner == nil {
 		return nil, false
 	}
-	if val, ok := c.Static[name]; ok {
-		return val, true
+	// Methods can reassign a static variable, so it is read from the body.
+	if owner.body != nil {
+		if val, ok := owner.body.lookupLocal(name); ok {
+			return val, true
+		}
 	}
-	if c.Super != nil {
-		return c.Super.lookupStatic(name)
+	return owner.Static[name], true
+}
+
+// staticOwner returns the class among c and its superclasses that declares
+// the static name, or nil.
+func (c *ClassType) staticOwner(name string) *ClassType {
+	for ; c != nil; c = c.Super {
+		if _, ok := c.Static[name]; ok {
+			return c
+		}
 	}
-	return nil, false
+	return nil
 }
 
 // EnumType describes an enumeration and its cases.
@@ -315,6 +421,11 @@ type EnumType struct {
 	Name         string
 	Cases        map[string][]string
 	Constructors map[string]Value
+	// CaseOrder lists the case names in declaration order, which gives
+	// each case its ordinal.
+	CaseOrder []string
+	// Methods holds the methods the enum body declares and impl blocks add.
+	Methods map[string]*Function
 }
 
 // Type implements the Value interface for EnumType.
@@ -377,6 +488,10 @@ type ChannelValue struct {
 	ch       chan Value
 	capacity int
 	closed   bool
+	// blocked counts tasks currently waiting in send or recv.
+	blocked atomic.Int32
+	onClose func()
+	control *runControl
 }
 
 // Type implements the Value interface for ChannelValue.
@@ -402,12 +517,28 @@ func (c *ChannelValue) send(val Value) error {
 	if c.closed {
 		return errors.New("send on closed channel")
 	}
-	c.ch <- val
-	return nil
+	shareValue(val)
+	c.blocked.Add(1)
+	defer c.blocked.Add(-1)
+	select {
+	case c.ch <- val:
+		return nil
+	case <-c.control.done():
+		return c.control.err()
+	}
 }
 
 func (c *ChannelValue) recv() (Value, error) {
-	v, ok := <-c.ch
+	c.blocked.Add(1)
+	var v Value
+	var ok bool
+	select {
+	case v, ok = <-c.ch:
+	case <-c.control.done():
+		c.blocked.Add(-1)
+		return nil, c.control.err()
+	}
+	c.blocked.Add(-1)
 	if !ok {
 		return NullValue, errors.New("receive on closed channel")
 	}
@@ -420,6 +551,9 @@ func (c *ChannelValue) close() error {
 	}
 	close(c.ch)
 	c.closed = true
+	if c.onClose != nil {
+		c.onClose()
+	}
 	return nil
 }
 
@@ -430,14 +564,22 @@ type taskResult struct {
 
 // Task tracks asynchronous execution state.
 type Task struct {
-	once   sync.Once
-	result taskResult
-	ch     chan taskResult
+	once    sync.Once
+	result  taskResult
+	ch      chan taskResult
+	done    chan struct{}
+	control *runControl
+	tracker *resourceTracker
+
+	contMu        sync.Mutex
+	continuations []func(taskResult)
+	settled       bool
+	draining      bool
 }
 
 // NewTask creates a pending task with synchronization primitives.
 func NewTask() *Task {
-	return &Task{ch: make(chan taskResult, 1)}
+	return &Task{ch: make(chan taskResult, 1), done: make(chan struct{})}
 }
 
 // Type implements the Value interface for Task.
@@ -449,6 +591,16 @@ func (t *Task) Inspect() string { return "<task>" }
 func (t *Task) deliver(val Value, err error) {
 	t.ch <- taskResult{value: val, err: err}
 	close(t.ch)
+	close(t.done)
+	t.settle()
+}
+
+// observe records that something waits for the task's outcome, so
+// Unreleased does not report it.
+func (t *Task) observe() {
+	if t.tracker != nil {
+		t.tracker.joinTask(t)
+	}
 }
 
 func (t *Task) await() taskResult {
@@ -463,16 +615,28 @@ func (t *Task) await() taskResult {
 	return t.result
 }
 
-// Join waits for the task to complete and returns its result.
+// Join waits for the task to complete and returns its result. Waiting stops
+// with a *CancelledError if the spawning runtime's context is done first.
 func (t *Task) Join() (Value, error) {
+	t.observe()
+	select {
+	case <-t.done:
+	case <-t.control.done():
+		return nil, t.control.err()
+	}
 	res := t.await()
 	return res.value, res.err
 }
 
-// Contract captures runtime contract requirements.
+// Contract captures runtime contract requirements. Exports holds the
+// helpers the body defines; requires and invariants are what classes that
+// implement the contract must satisfy, and env is where invariants run.
 type Contract struct {
-	Name    string
-	Exports map[string]Value
+	Name       string
+	Exports    map[string]Value
+	requires   []*ast.FunctionDeclaration
+	invariants []*ast.ContractInvariant
+	env        *Environment
 }
 
 // Type implements the Value interface for Contract.
@@ -496,10 +660,18 @@ func (c *Contract) Inspect() string {
 	return finishBuilder(b)
 }
 
-// InterfaceType records an interface name and required methods.
+// InterfaceType records an interface name, the arity of each of its
+// methods, and the default implementations of some of them.
 type InterfaceType struct {
-	Name    string
-	Methods map[string]int
+	Name     string
+	Methods  map[string]int
+	Defaults map[string]*Function
+
+	mu sync.Mutex
+	// impls holds the types impl blocks declared to implement the
+	// interface: struct, class, and enum definitions, and builtin type
+	// names.
+	impls map[any]bool
 }
 
 // Type implements the Value interface for InterfaceType.
@@ -532,6 +704,12 @@ type Function struct {
 	Env         *Environment
 	Builtin     BuiltinFunction
 	Name        string
+	// sited, when set, is called instead of Builtin for direct calls so the
+	// builtin can record the call's source position.
+	sited func(site token.Position, args []Value) (Value, error)
+	// guarded is the instance a method is bound to when the invariants of
+	// its class must be checked after each call.
+	guarded *ClassInstance
 }
 
 // Type implements the Value interface for Function.
@@ -557,45 +735,97 @@ func NewBoolean(v bool) Value {
 	return FalseValue
 }
 
-// NewNumber wraps a Go float64 as a Selene number.
-func NewNumber(v float64) Value { return &Number{Value: v} }
-
-// NewString wraps a Go string as a Selene string.
-func NewString(v string) Value { return &String{Value: v} }
-
 // NewBuiltin creates a runtime value for a builtin function.
+//
+// Deprecated: NewBuiltin is version 1 of the builtin API. Register a
+// BuiltinSpec with Runtime.Register instead; the first call in a process
+// writes a warning to the deprecation output.
 func NewBuiltin(name string, fn BuiltinFunction) Value {
+	warnDeprecated("runtime.NewBuiltin", "Runtime.Register with a BuiltinSpec")
+	return newBuiltin(name, fn)
+}
+
+func newBuiltin(name string, fn BuiltinFunction) Value {
 	return &Function{Name: name, Builtin: fn}
 }
 
+// newSitedBuiltin creates a builtin that receives the position of the call
+// expression invoking it. Indirect calls, such as passing the builtin to
+// another function, report a zero position.
+func newSitedBuiltin(name string, fn func(site token.Position, args []Value) (Value, error)) Value {
+	return &Function{
+		Name:    name,
+		Builtin: func(args []Value) (Value, error) { return fn(token.Position{}, args) },
+		sited:   fn,
+	}
+}
+
 type returnSignal struct {
 	value Value
+	// propagated marks a return made by the ? operator.
+	propagated bool
 }
 
 // Error implements the error interface for return signals.
 func (r *returnSignal) Error() string { return "return" }
 
-type breakSignal struct{}
+// breakSignal is raised by break. An unlabelled break ends the nearest
+// loop; a labelled one passes through the loops inside the one it names.
+type breakSignal struct {
+	label string
+}
 
 // Error implements the error interface for break signals.
 func (b *breakSignal) Error() string { return "break" }
 
-type continueSignal struct{}
+// targets reports whether the break ends the loop labelled label.
+func (b *breakSignal) targets(label string) bool { return b.label == "" || b.label == label }
+
+// continueSignal is raised by continue, and targets loops the way
+// breakSignal does.
+type continueSignal struct {
+	label string
+}
 
 // Error implements the error interface for continue signals.
 func (c *continueSignal) Error() string { return "continue" }
 
+// targets reports whether the continue goes on to the next iteration of the
+// loop labelled label.
+func (c *continueSignal) targets(label string) bool { return c.label == "" || c.label == label }
+
+// rethrowSignal is raised by rethrow and turned back into the caught error by
+// the try statement whose catch clause it is in.
+type rethrowSignal struct{}
+
+// Error implements the error interface for rethrow signals.
+func (r *rethrowSignal) Error() string { return "rethrow" }
+
 type runtimeError struct {
 	value *ErrorValue
+	// thrown is the value a throw statement threw, which value wraps.
+	thrown Value
 }
 
 // Error exposes the error message stored in the runtime error.
 func (r *runtimeError) Error() string { return r.value.Message }
 
+// caught is the value a catch clause without a type binds: the Error
+// instance that was thrown, or else the ErrorValue wrapping what was.
+func (r *runtimeError) caught() Value {
+	if inst, ok := r.thrown.(*ClassInstance); ok && inst.Definition.isError() {
+		return inst
+	}
+	return r.value
+}
+
 func wrapRuntimeError(err error) *runtimeError {
 	if err == nil {
 		return nil
 	}
+	if traced, ok := err.(*traceError); ok {
+		err = traced.err
+	}
 	if rt, ok := err.(*runtimeError); ok {
 		return rt
 	}
@@ -605,7 +835,20 @@ func wrapRuntimeError(err error) *runtimeError {
 // Environment stores variable bindings with optional outer scopes.
 type Environment struct {
 	store map[string]Value
-	outer *Environment
+	// layout and slots hold the bindings of a scope inside a resolved
+	// function (see resolve.go). Bindings the layout does not list go to
+	// store, which such scopes only allocate when needed.
+	layout  *scopeLayout
+	slots   []Value
+	outer   *Environment
+	control *runControl
+	// depth counts the function calls active on the goroutine that created
+	// the environment; scopes inherit it and calls add one to it.
+	depth int32
+	// shared is set once the environment is reachable from more than one
+	// goroutine (see share); from then on every access holds mu.
+	shared atomic.Bool
+	mu     sync.RWMutex
 }
 
 // NewEnvironment creates a fresh environment with no outer scope.
@@ -617,13 +860,64 @@ func NewEnvironment() *Environment {
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.control = outer.control
+	env.depth = outer.depth
 	return env
 }
 
+func newSlotEnvironment(outer *Environment, layout *scopeLayout) *Environment {
+	return &Environment{layout: layout, slots: make([]Value, len(layout.names)), outer: outer, control: outer.control, depth: outer.depth}
+}
+
+// newScope creates the child environment the evaluator enters for node,
+// using the slot layout the resolver assigned it when outer belongs to a
+// resolved function.
+func newScope(outer *Environment, node ast.Node) *Environment {
+	if outer.layout != nil {
+		if layout, ok := outer.layout.frame.scopes[node]; ok {
+			return newSlotEnvironment(outer, layout)
+		}
+	}
+	return NewEnclosedEnvironment(outer)
+}
+
+func (e *Environment) lookupLocal(name string) (Value, bool) {
+	if e.shared.Load() {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+	}
+	if e.layout != nil {
+		if i := e.layout.slot(name); i >= 0 && e.slots[i] != nil {
+			return e.slots[i], true
+		}
+	}
+	val, ok := e.store[name]
+	return val, ok
+}
+
+func (e *Environment) assignLocal(name string, val Value) bool {
+	if e.shared.Load() {
+		shareValue(val)
+		e.mu.Lock()
+		defer e.mu.Unlock()
+	}
+	if e.layout != nil {
+		if i := e.layout.slot(name); i >= 0 && e.slots[i] != nil {
+			e.slots[i] = val
+			return true
+		}
+	}
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+	return false
+}
+
 // Get looks up a value in the environment chain.
 func (e *Environment) Get(name string) (Value, bool) {
 	for env := e; env != nil; env = env.outer {
-		if val, ok := env.store[name]; ok {
+		if val, ok := env.lookupLocal(name); ok {
 			return val, true
 		}
 	}
@@ -632,23 +926,49 @@ func (e *Environment) Get(name string) (Value, bool) {
 
 // Set stores a binding in the current environment scope.
 func (e *Environment) Set(name string, val Value) Value {
+	if e.shared.Load() {
+		shareValue(val)
+		e.mu.Lock()
+		defer e.mu.Unlock()
+	}
+	if e.layout != nil {
+		if i := e.layout.slot(name); i >= 0 {
+			e.slots[i] = val
+			return val
+		}
+	}
+	if e.store == nil {
+		e.store = make(map[string]Value)
+	}
 	e.store[name] = val
 	return val
 }
 
 // Snapshot returns a copy of the environment bindings.
 func (e *Environment) Snapshot() map[string]Value {
-	if len(e.store) == 0 {
+	if e.shared.Load() {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+	}
+	if len(e.store) == 0 && e.layout == nil {
 		return make(map[string]Value)
 	}
-	return maps.Clone(e.store)
+	out := maps.Clone(e.store)
+	if out == nil {
+		out = make(map[string]Value)
+	}
+	for i, val := range e.slots {
+		if val != nil {
+			out[e.layout.names[i]] = val
+		}
+	}
+	return out
 }
 
 // Assign updates an existing binding in the environment chain.
 func (e *Environment) Assign(name string, val Value) (Value, error) {
 	for env := e; env != nil; env = env.outer {
-		if _, ok := env.store[name]; ok {
-			env.store[name] = val
+		if env.assignLocal(name, val) {
 			return val, nil
 		}
 	}
@@ -657,13 +977,96 @@ func (e *Environment) Assign(name string, val Value) (Value, error) {
 
 func (e *Environment) resolve(name string) (*Environment, bool) {
 	for env := e; env != nil; env = env.outer {
-		if _, ok := env.store[name]; ok {
+		if _, ok := env.lookupLocal(name); ok {
 			return env, true
 		}
 	}
 	return nil, false
 }
 
+// resolved returns the environment and slot the resolver assigned id, or
+// false when id has none or the chain does not have the expected shape.
+// A negative slot means id is looked up by name from the returned
+// environment.
+func (e *Environment) resolved(id *ast.Identifier) (*Environment, int, bool) {
+	if e.layout == nil {
+		return nil, 0, false
+	}
+	ref, ok := e.layout.frame.refs[id]
+	if !ok {
+		return nil, 0, false
+	}
+	target := e
+	for range ref.hops {
+		if target = target.outer; target == nil {
+			return nil, 0, false
+		}
+	}
+	if ref.slot >= 0 && (target.layout == nil || ref.slot >= len(target.slots) || target.layout.names[ref.slot] != id.Name) {
+		return nil, 0, false
+	}
+	return target, ref.slot, true
+}
+
+// getIdentifier looks up id, taking the slot the resolver assigned it when
+// there is one and walking the chain by name otherwise.
+func (e *Environment) getIdentifier(id *ast.Identifier) (Value, bool) {
+	target, slot, ok := e.resolved(id)
+	if !ok {
+		return e.Get(id.Name)
+	}
+	if slot < 0 {
+		return target.Get(id.Name)
+	}
+	if val := target.slotValue(slot); val != nil {
+		return val, true
+	}
+	return e.Get(id.Name)
+}
+
+func (e *Environment) slotValue(slot int) Value {
+	if e.shared.Load() {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+	}
+	return e.slots[slot]
+}
+
+// setSlot overwrites a bound slot, reporting false when it is not bound.
+func (e *Environment) setSlot(slot int, val Value) bool {
+	if e.shared.Load() {
+		shareValue(val)
+		e.mu.Lock()
+		defer e.mu.Unlock()
+	}
+	if e.slots[slot] == nil {
+		return false
+	}
+	e.slots[slot] = val
+	return true
+}
+
+// assignIdentifier is Assign for an identifier the resolver may have
+// assigned a slot.
+func (e *Environment) assignIdentifier(id *ast.Identifier, val Value) (Value, error) {
+	target, slot, ok := e.resolved(id)
+	if ok && slot >= 0 && target.setSlot(slot, val) {
+		target.noteWrite(id.Name, id.Pos())
+		return val, nil
+	}
+	from := e
+	if ok && slot < 0 {
+		from = target
+	}
+	for env := from; env != nil; env = env.outer {
+		if env.assignLocal(id.Name, val) {
+			env.noteWrite(id.Name, id.Pos())
+			return val, nil
+		}
+	}
+	return nil, fmt.Errorf("undefined variable %s", id.Name)
+}
+
 // Pointer references a binding inside an environment chain.
 type Pointer struct {
 	env  *Environment
@@ -685,7 +1088,7 @@ func (p *Pointer) get() (Value, error) {
 	if p == nil || p.env == nil {
 		return nil, errors.New("dereference of nil pointer")
 	}
-	if val, ok := p.env.store[p.name]; ok {
+	if val, ok := p.env.lookupLocal(p.name); ok {
 		return val, nil
 	}
 	return nil, fmt.Errorf("dangling pointer to %s", p.name)
@@ -695,26 +1098,67 @@ func (p *Pointer) set(val Value) error {
 	if p == nil || p.env == nil {
 		return errors.New("assignment to nil pointer")
 	}
-	if _, ok := p.env.store[p.name]; !ok {
+	if !p.env.assignLocal(p.name, val) {
 		return fmt.Errorf("dangling pointer to %s", p.name)
 	}
-	p.env.store[p.name] = val
+	p.env.noteWrite(p.name, token.Position{})
 	return nil
 }
 
 // Runtime executes Selene programs and holds the global environment.
 type Runtime struct {
-	env *Environment
+	env       *Environment
+	sandboxed bool
+	fs        FileSystem
+	hostEnv   HostEnv
+	clock     Clock
+	tracker   *resourceTracker
+	control   *runControl
+	policy    Policy
+	audit     *AuditLog
+	signals   signalHandlers
+	random    *randomSource
+	stdio     *stdio
+	vmHook    func(VMStep) error
+	// noFolding is set by SetOptimizationLevel(0).
+	noFolding bool
 }
 
 // New constructs a runtime with built-in functions installed.
 func New() *Runtime {
 	env := NewEnvironment()
-	env.Set("print", NewBuiltin("print", builtinPrint))
-	env.Set("format", NewBuiltin("format", builtinFormat))
-	env.Set("spawn", NewBuiltin("spawn", builtinSpawn))
-	env.Set("channel", NewBuiltin("channel", builtinChannel))
-	return &Runtime{env: env}
+	env.Set("format", newBuiltin("format", builtinFormat))
+	env.Set("set", newBuiltin("set", builtinSet))
+	env.Set("bytes", newBuiltin("bytes", builtinBytes))
+	env.Set("freeze", newBuiltin("freeze", builtinFreeze))
+	env.Set("isFrozen", newBuiltin("isFrozen", builtinIsFrozen))
+	env.Set("scope", newBuiltin("scope", builtinScope))
+	env.Set("regex", newRegexModule())
+	installPrelude(env)
+	installNumeric(env)
+	rt := &Runtime{env: env, fs: osFileSystem{}, hostEnv: osHostEnv{}, clock: systemClock{}, tracker: newResourceTracker(), control: &runControl{maxDepth: DefaultMaxCallDepth}, random: newRandomSource()}
+	rt.control.tracker = rt.tracker
+	env.control = rt.control
+	rt.SetStdio(nil, nil, nil)
+	env.Set("print", rt.printBuiltin())
+	env.Set("input", rt.inputBuiltin("input", true))
+	env.Set("readLine", rt.inputBuiltin("readLine", false))
+	env.Set("stdin", newStdinModule(rt))
+	env.Set("stdout", newOutputModule(rt, "stdout"))
+	env.Set("stderr", newOutputModule(rt, "stderr"))
+	env.Set("spawn", rt.spawnBuiltin())
+	env.Set("channel", rt.channelBuiltin())
+	env.Set("os", newProcessModule(rt))
+	env.Set("fs", newFSModule(rt))
+	env.Set("path", newPathModule(rt))
+	env.Set("time", newTimeModule(rt))
+	env.Set("math", newMathModule(rt))
+	env.Set("rand", newRandModule(rt))
+	env.Set("tasks", newTasksModule(rt))
+	env.Set("reflect", newReflectModule(rt))
+	rt.installRegisteredModules()
+	rt.installAudit()
+	return rt
 }
 
 // Environment returns the runtime's global environment.
@@ -749,7 +1193,10 @@ func evalProgramItem(item ast.ProgramItem, env *Environment) (Value, error) {
 	case ast.Statement:
 		val, err := evalStatement(node, env)
 		if err != nil {
-			if _, ok := err.(*returnSignal); ok {
+			if ret, ok := err.(*returnSignal); ok {
+				if ret.propagated {
+					return nil, fmt.Errorf("%s propagated by ? outside of function", ret.value.Inspect())
+				}
 				return nil, errors.New("return outside of function")
 			}
 			if _, ok := err.(*breakSignal); ok {
@@ -797,6 +1244,9 @@ func evalStatement(stmt ast.Statement, env *Environment) (Value, error) {
 				return nil, err
 			}
 		}
+		if node.Pattern != nil {
+			return val, destructure(node.Pattern, val, env)
+		}
 		env.Set(node.Name.Name, val)
 		return val, nil
 	case *ast.FunctionDeclaration:
@@ -806,32 +1256,36 @@ func evalStatement(stmt ast.Statement, env *Environment) (Value, error) {
 				return nil, errors.New("extension function requires receiver type")
 			}
 			registerExtension(node.Receiver.Name.Name, node.Name.Name, fn)
-			return fn, nil
+			return annotate(fn, nil, node.Annotations, env)
+		}
+		if node.Accessor != "" || node.Abstract {
+			// The enclosing class declaration records its accessors and
+			// abstract methods.
+			return NullValue, nil
 		}
 		env.Set(node.Name.Name, fn)
-		return fn, nil
+		return annotate(fn, nil, node.Annotations, env)
 	case *ast.InterfaceDeclaration:
-		methods := make(map[string]int)
-		for _, method := range node.Methods {
-			if method.Name != nil {
-				methods[method.Name.Name] = len(method.Params)
-			}
-		}
-		iface := &InterfaceType{Name: node.Name.Name, Methods: methods}
-		env.Set(node.Name.Name, iface)
-		return iface, nil
+		val, err := evalInterfaceDeclaration(node, env)
+		return annotate(val, err, node.Annotations, env)
+	case *ast.ImplDeclaration:
+		return evalImplDeclaration(node, env)
 	case *ast.ImportDeclaration:
 		return evalImportDeclaration(node, env)
 	case *ast.StructDeclaration:
-		return evalStructDeclaration(node, env)
+		val, err := evalStructDeclaration(node, env)
+		return annotate(val, err, node.Annotations, env)
 	case *ast.ClassDeclaration:
-		return evalClassDeclaration(node, env)
+		val, err := evalClassDeclaration(node, env)
+		return annotate(val, err, node.Annotations, env)
 	case *ast.EnumDeclaration:
-		return evalEnumDeclaration(node, env)
+		val, err := evalEnumDeclaration(node, env)
+		return annotate(val, err, node.Annotations, env)
 	case *ast.ContractDeclaration:
-		return evalContractDeclaration(node, env)
+		val, err := evalContractDeclaration(node, env)
+		return annotate(val, err, node.Annotations, env)
 	case *ast.BlockStatement:
-		blockEnv := NewEnclosedEnvironment(env)
+		blockEnv := newScope(env, node)
 		return evalBlock(node, blockEnv)
 	case *ast.MatchStatement:
 		return evalMatchStatement(node, env)
@@ -839,8 +1293,12 @@ func evalStatement(stmt ast.Statement, env *Environment) (Value, error) {
 		return evalIfStatement(node, env)
 	case *ast.WhileStatement:
 		return evalWhileStatement(node, env)
+	case *ast.DoWhileStatement:
+		return evalDoWhileStatement(node, env)
 	case *ast.ForStatement:
 		return evalForStatement(node, env)
+	case *ast.ForInStatement:
+		return evalForInStatement(node, env)
 	case *ast.UsingStatement:
 		return evalUsingStatement(node, env)
 	case *ast.TryStatement:
@@ -850,7 +1308,7 @@ func evalStatement(stmt ast.Statement, env *Environment) (Value, error) {
 		if err != nil {
 			return nil, err
 		}
-		return NullValue, &runtimeError{value: toErrorValue(val)}
+		return NullValue, &runtimeError{value: toErrorValue(val), thrown: val}
 	case *ast.ConditionStatement:
 		return evalConditionStatement(node, env)
 	case *ast.ReturnStatement:
@@ -864,9 +1322,11 @@ func evalStatement(stmt ast.Statement, env *Environment) (Value, error) {
 		}
 		return val, &returnSignal{value: val}
 	case *ast.BreakStatement:
-		return NullValue, &breakSignal{}
+		return NullValue, &breakSignal{label: node.Label}
 	case *ast.ContinueStatement:
-		return NullValue, &continueSignal{}
+		return NullValue, &continueSignal{label: node.Label}
+	case *ast.RethrowStatement:
+		return NullValue, &rethrowSignal{}
 	default:
 		return nil, fmt.Errorf("runtime does not support statement %T", stmt)
 	}
@@ -877,6 +1337,11 @@ func ExecuteStatement(stmt ast.Statement, env *Environment) (Value, error) {
 	return evalStatement(stmt, env)
 }
 
+// EvaluateExpression evaluates an expression node in the provided environment.
+func EvaluateExpression(expr ast.Expression, env *Environment) (Value, error) {
+	return evalExpression(expr, env)
+}
+
 func evalBlock(block *ast.BlockStatement, env *Environment) (Value, error) {
 	result := NullValue
 	for _, stmt := range block.Statements {
@@ -919,6 +1384,9 @@ func evalIfStatement(stmt *ast.IfStatement, env *Environment) (Value, error) {
 func evalWhileStatement(stmt *ast.WhileStatement, env *Environment) (Value, error) {
 	result := NullValue
 	for {
+		if err := env.Err(); err != nil {
+			return nil, err
+		}
 		if stmt.Condition != nil {
 			cond, err := evalExpression(stmt.Condition, env)
 			if err != nil {
@@ -935,8 +1403,14 @@ func evalWhileStatement(stmt *ast.WhileStatement, env *Environment) (Value, erro
 		if err != nil {
 			switch sig := err.(type) {
 			case *breakSignal:
+				if !sig.targets(stmt.Label) {
+					return NullValue, err
+				}
 				return result, nil
 			case *continueSignal:
+				if !sig.targets(stmt.Label) {
+					return NullValue, err
+				}
 				continue
 			case *returnSignal:
 				return sig.value, err
@@ -949,8 +1423,49 @@ func evalWhileStatement(stmt *ast.WhileStatement, env *Environment) (Value, erro
 	return result, nil
 }
 
+// evalDoWhileStatement runs the body, then tests the condition to decide
+// whether to run it again; continue goes on to the test.
+func evalDoWhileStatement(stmt *ast.DoWhileStatement, env *Environment) (Value, error) {
+	result := NullValue
+	for {
+		if err := env.Err(); err != nil {
+			return nil, err
+		}
+		if stmt.Body != nil {
+			val, err := evalStatement(stmt.Body, env)
+			switch sig := err.(type) {
+			case nil:
+				result = val
+			case *breakSignal:
+				if !sig.targets(stmt.Label) {
+					return NullValue, err
+				}
+				return result, nil
+			case *continueSignal:
+				if !sig.targets(stmt.Label) {
+					return NullValue, err
+				}
+			case *returnSignal:
+				return sig.value, err
+			default:
+				return nil, err
+			}
+		}
+		if stmt.Condition == nil {
+			return result, nil
+		}
+		cond, err := evalExpression(stmt.Condition, env)
+		if err != nil {
+			return nil, err
+		}
+		if !isTruthy(cond) {
+			return result, nil
+		}
+	}
+}
+
 func evalForStatement(stmt *ast.ForStatement, env *Environment) (Value, error) {
-	loopEnv := NewEnclosedEnvironment(env)
+	loopEnv := newScope(env, stmt)
 	if stmt.Init != nil {
 		if _, err := evalStatement(stmt.Init, loopEnv); err != nil {
 			switch err.(type) {
@@ -966,6 +1481,9 @@ func evalForStatement(stmt *ast.ForStatement, env *Environment) (Value, error) {
 
 	result := NullValue
 	for {
+		if err := loopEnv.Err(); err != nil {
+			return nil, err
+		}
 		if stmt.Condition != nil {
 			cond, err := evalExpression(stmt.Condition, loopEnv)
 			if err != nil {
@@ -981,8 +1499,14 @@ func evalForStatement(stmt *ast.ForStatement, env *Environment) (Value, error) {
 			if err != nil {
 				switch sig := err.(type) {
 				case *breakSignal:
+					if !sig.targets(stmt.Label) {
+						return NullValue, err
+					}
 					return result, nil
 				case *continueSignal:
+					if !sig.targets(stmt.Label) {
+						return NullValue, err
+					}
 					if stmt.Post != nil {
 						if _, err := evalExpression(stmt.Post, loopEnv); err != nil {
 							return nil, err
@@ -1012,22 +1536,30 @@ func evalForStatement(stmt *ast.ForStatement, env *Environment) (Value, error) {
 func evalExpression(expr ast.Expression, env *Environment) (Value, error) {
 	switch node := expr.(type) {
 	case *ast.Identifier:
-		if val, ok := env.Get(node.Name); ok {
+		if val, ok := env.getIdentifier(node); ok {
 			return val, nil
 		}
 		return nil, fmt.Errorf("undefined identifier %s", node.Name)
 	case *ast.NumberLiteral:
-		num, err := strconv.ParseFloat(node.Value, 64)
+		num, err := env.control.literalValue(node)
 		if err != nil {
 			return nil, fmt.Errorf("invalid number literal %q", node.Value)
 		}
-		return NewNumber(num), nil
+		return num, nil
 	case *ast.StringLiteral:
+		if isConstantString(node) {
+			return env.control.literalValue(node)
+		}
 		return renderStringLiteral(node, env)
 	case *ast.BooleanLiteral:
 		return NewBoolean(node.Value), nil
 	case *ast.NullLiteral:
 		return NullValue, nil
+	case *ast.OldExpression:
+		if snapshot, ok := env.Get(oldBinding(node.Index)); ok {
+			return snapshot, nil
+		}
+		return nil, errors.New("old can only be used in a postcondition")
 	case *ast.AwaitExpression:
 		val, err := evalExpression(node.Expression, env)
 		if err != nil {
@@ -1074,83 +1606,33 @@ func evalExpression(expr ast.Expression, env *Environment) (Value, error) {
 		if err != nil {
 			return nil, err
 		}
+		if node.Operator == "&&" || node.Operator == "||" {
+			// The right operand is only evaluated when the left one does
+			// not settle the result, which is whichever operand did.
+			if isTruthy(left) == (node.Operator == "||") {
+				return left, nil
+			}
+			return evalExpression(node.Right, env)
+		}
 		right, err := evalExpression(node.Right, env)
 		if err != nil {
 			return nil, err
 		}
-		return evalInfixExpression(node.Operator, left, right)
-	case *ast.AssignmentExpression:
-		switch target := node.Target.(type) {
-		case *ast.Identifier:
-			right, err := evalExpression(node.Value, env)
-			if err != nil {
-				return nil, err
-			}
-			var result Value
-			if node.Operator == token.ASSIGN {
-				result = right
-			} else {
-				current, ok := env.Get(target.Name)
-				if !ok {
-					return nil, fmt.Errorf("undefined variable %s", target.Name)
-				}
-				result, err = applyAugmentedAssignment(node.Operator, current, right)
-				if err != nil {
-					return nil, err
-				}
-			}
-			if _, err := env.Assign(target.Name, result); err != nil {
-				return nil, err
-			}
-			return result, nil
-		case *ast.PrefixExpression:
-			if target.Operator != "*" {
-				return nil, errors.New("unsupported assignment target")
-			}
-			ptrRef, err := evalExpression(target.Right, env)
-			if err != nil {
-				return nil, err
-			}
-			pointer, ok := ptrRef.(*Pointer)
-			if !ok {
-				return nil, errors.New("assignment target is not a pointer")
-			}
-			value, err := evalExpression(node.Value, env)
-			if err != nil {
-				return nil, err
-			}
-			result := value
-			if node.Operator != token.ASSIGN {
-				current, err := pointer.get()
-				if err != nil {
-					return nil, err
-				}
-				result, err = applyAugmentedAssignment(node.Operator, current, value)
-				if err != nil {
-					return nil, err
-				}
-			}
-			if err := pointer.set(result); err != nil {
-				return nil, err
-			}
-			return result, nil
-		default:
-			return nil, errors.New("unsupported assignment target")
-		}
-	case *ast.CallExpression:
-		callee, err := evalExpression(node.Callee, env)
-		if err != nil {
+		if err := env.reserveConcat(node.Operator, left, right); err != nil {
 			return nil, err
 		}
-		args := make([]Value, 0, len(node.Arguments))
-		for _, arg := range node.Arguments {
-			val, err := evalExpression(arg, env)
-			if err != nil {
-				return nil, err
-			}
-			args = append(args, val)
+		result, err := evalInfixExpression(node.Operator, left, right)
+		if err != nil || !env.strictMath() {
+			return result, err
 		}
-		return applyFunction(callee, args)
+		return checkStrictMath(node.Operator, left, right, result)
+	case *ast.AssignmentExpression:
+		return evalAssignment(node, env)
+	case *ast.IncrementExpression:
+		return evalIncrement(node, env)
+	case *ast.CallExpression, *ast.IndexExpression, *ast.MemberExpression:
+		val, _, err := evalChain(node, env)
+		return val, err
 	case *ast.ArrayLiteral:
 		elements := make([]Value, 0, len(node.Elements))
 		for _, el := range node.Elements {
@@ -1161,32 +1643,26 @@ func evalExpression(expr ast.Expression, env *Environment) (Value, error) {
 			elements = append(elements, val)
 		}
 		return &Array{Elements: elements}, nil
+	case *ast.SetLiteral:
+		set := NewSet()
+		for _, el := range node.Elements {
+			val, err := evalExpression(el, env)
+			if err != nil {
+				return nil, err
+			}
+			set.add(val)
+		}
+		return set, nil
 	case *ast.ObjectLiteral:
-		props := make(map[string]Value)
-		for _, pair := range node.Pairs {
+		props := make([]Property, len(node.Pairs))
+		for i, pair := range node.Pairs {
 			val, err := evalExpression(pair.Value, env)
 			if err != nil {
 				return nil, err
 			}
-			props[pair.Key] = val
-		}
-		return &Object{Properties: props}, nil
-	case *ast.IndexExpression:
-		collection, err := evalExpression(node.Collection, env)
-		if err != nil {
-			return nil, err
+			props[i] = Property{Key: pair.Key, Value: val}
 		}
-		indexVal, err := evalExpression(node.Index, env)
-		if err != nil {
-			return nil, err
-		}
-		return evalIndexExpression(collection, indexVal)
-	case *ast.MemberExpression:
-		objectVal, err := evalExpression(node.Object, env)
-		if err != nil {
-			return nil, err
-		}
-		return evalMemberExpression(objectVal, node.Property, node.Optional)
+		return NewObject(props...), nil
 	case *ast.ElvisExpression:
 		left, err := evalExpression(node.Left, env)
 		if err != nil {
@@ -1196,6 +1672,8 @@ func evalExpression(expr ast.Expression, env *Environment) (Value, error) {
 			return left, nil
 		}
 		return evalExpression(node.Right, env)
+	case *ast.YieldExpression:
+		return evalYieldExpression(node, env)
 	case *ast.NonNullAssertion:
 		val, err := evalExpression(node.Expression, env)
 		if err != nil {
@@ -1205,11 +1683,68 @@ func evalExpression(expr ast.Expression, env *Environment) (Value, error) {
 			return nil, errors.New("encountered null in non-null assertion")
 		}
 		return val, nil
+	case *ast.PropagateExpression:
+		return evalPropagateExpression(node, env)
 	default:
 		return nil, fmt.Errorf("runtime does not support expression %T", expr)
 	}
 }
 
+// evalChain evaluates a call, index, or member access. skip reports that an
+// optional ?. or ?[ in the postfix chain expr ends found null, in which case
+// the rest of the chain is not evaluated and expr is null: a?.b.c(d) calls
+// nothing and does not evaluate d when a is null.
+func evalChain(expr ast.Expression, env *Environment) (Value, bool, error) {
+	switch node := expr.(type) {
+	case *ast.CallExpression:
+		callee, skip, err := evalChain(node.Callee, env)
+		if err != nil || skip {
+			return callee, skip, err
+		}
+		args := make([]Value, 0, len(node.Arguments))
+		for _, arg := range node.Arguments {
+			val, err := evalExpression(arg, env)
+			if err != nil {
+				return nil, false, err
+			}
+			args = append(args, val)
+		}
+		if fn, ok := callee.(*Function); ok && fn.sited != nil {
+			val, err := fn.sited(node.Pos(), args)
+			return val, false, withFrame(err, callee, node.Pos())
+		}
+		val, err := applyFunctionAt(env.depth, callee, args)
+		return val, false, withFrame(err, callee, node.Pos())
+	case *ast.IndexExpression:
+		collection, skip, err := evalChain(node.Collection, env)
+		if err != nil || skip {
+			return collection, skip, err
+		}
+		if _, isNull := collection.(*Null); isNull && node.Optional {
+			return NullValue, true, nil
+		}
+		indexVal, err := evalExpression(node.Index, env)
+		if err != nil {
+			return nil, false, err
+		}
+		val, err := evalIndexExpression(collection, indexVal)
+		return val, false, err
+	case *ast.MemberExpression:
+		objectVal, skip, err := evalChain(node.Object, env)
+		if err != nil || skip {
+			return objectVal, skip, err
+		}
+		if _, isNull := objectVal.(*Null); isNull && node.Optional {
+			return NullValue, true, nil
+		}
+		val, err := evalMemberExpression(objectVal, node.Property, node.Optional)
+		return val, false, err
+	default:
+		val, err := evalExpression(expr, env)
+		return val, false, err
+	}
+}
+
 func evalPrefixExpression(operator string, right Value) (Value, error) {
 	switch operator {
 	case "!":
@@ -1242,6 +1777,12 @@ func evalInfixExpression(operator string, left, right Value) (Value, error) {
 			return NewNumber(l.Value + r.Value), nil
 		case *String:
 			return NewString(l.Value + toString(right)), nil
+		case *Bytes:
+			r, ok := right.(*Bytes)
+			if !ok {
+				return nil, fmt.Errorf("cannot add %s to Bytes", right.Type())
+			}
+			return NewBytes(append(append(make([]byte, 0, len(l.Value)+len(r.Value)), l.Value...), r.Value...)), nil
 		default:
 			if _, ok := right.(*String); ok {
 				return NewString(toString(left) + toString(right)), nil
@@ -1306,10 +1847,6 @@ func evalInfixExpression(operator string, left, right Value) (Value, error) {
 		return compareNumbers(operator, left, right)
 	case ">=":
 		return compareNumbers(operator, left, right)
-	case "&&":
-		return NewBoolean(isTruthy(left) && isTruthy(right)), nil
-	case "||":
-		return NewBoolean(isTruthy(left) || isTruthy(right)), nil
 	case "is":
 		return evalIsOperator(left, right)
 	case "!is":
@@ -1322,26 +1859,39 @@ func evalInfixExpression(operator string, left, right Value) (Value, error) {
 			return nil, errors.New("operator is must return Boolean")
 		}
 		return NewBoolean(!boolVal.Value), nil
+	case "in":
+		return evalMembership(left, right)
+	case "..", "..=":
+		return newRange(operator, left, right)
 	default:
 		return nil, fmt.Errorf("unknown infix operator %s", operator)
 	}
 }
 
-func applyAugmentedAssignment(op token.Type, current, update Value) (Value, error) {
+func applyAugmentedAssignment(op token.Type, current, update Value, env *Environment) (Value, error) {
+	var operator string
 	switch op {
 	case token.PLUS_ASSIGN:
-		return evalInfixExpression("+", current, update)
+		operator = "+"
 	case token.MINUS_ASSIGN:
-		return evalInfixExpression("-", current, update)
+		operator = "-"
 	case token.STAR_ASSIGN:
-		return evalInfixExpression("*", current, update)
+		operator = "*"
 	case token.SLASH_ASSIGN:
-		return evalInfixExpression("/", current, update)
+		operator = "/"
 	case token.PERCENT_ASSIGN:
-		return evalInfixExpression("%", current, update)
+		operator = "%"
 	default:
 		return nil, fmt.Errorf("unsupported assignment operator %s", op)
 	}
+	if err := env.reserveConcat(operator, current, update); err != nil {
+		return nil, err
+	}
+	result, err := evalInfixExpression(operator, current, update)
+	if err != nil || !env.strictMath() {
+		return result, err
+	}
+	return checkStrictMath(operator, current, update, result)
 }
 
 func compareNumbers(operator string, left, right Value) (Value, error) {
@@ -1382,6 +1932,12 @@ func equals(left, right Value) bool {
 	case *String:
 		r, ok := right.(*String)
 		return ok && l.Value == r.Value
+	case *Bytes:
+		r, ok := right.(*Bytes)
+		return ok && bytes.Equal(l.Value, r.Value)
+	case *Range:
+		r, ok := right.(*Range)
+		return ok && *l == *r
 	default:
 		return left == right
 	}
@@ -1403,6 +1959,10 @@ func isTruthy(val Value) bool {
 		return v.Value != ""
 	case *Array:
 		return len(v.Elements) > 0
+	case *Set:
+		return v.Len() > 0
+	case *Bytes:
+		return len(v.Value) > 0
 	case *Object:
 		return len(v.Properties) > 0
 	default:
@@ -1439,6 +1999,19 @@ func evalIndexExpression(collection, index Value) (Value, error) {
 			return nil, fmt.Errorf("string index %d out of range", idx)
 		}
 		return NewString(string(runes[idx])), nil
+	case *Bytes:
+		num, ok := index.(*Number)
+		if !ok {
+			return nil, fmt.Errorf("bytes index must be Number, got %s", index.Type())
+		}
+		idx := int(num.Value)
+		if float64(idx) != num.Value {
+			return nil, errors.New("bytes index must be integer")
+		}
+		if idx < 0 || idx >= len(col.Value) {
+			return nil, fmt.Errorf("bytes index %d out of range", idx)
+		}
+		return NewNumber(float64(col.Value[idx])), nil
 	default:
 		return nil, fmt.Errorf("cannot index into %s", collection.Type())
 	}
@@ -1468,20 +2041,25 @@ func evalMatchStatement(match *ast.MatchStatement, env *Environment) (Value, err
 	}
 
 	for _, clause := range match.Cases {
-		caseEnv := NewEnclosedEnvironment(env)
-		matched, err := matchPattern(clause.Pattern, target, caseEnv)
-		if err != nil {
-			return nil, err
-		}
-		if !matched {
-			continue
-		}
-		if clause.Body == nil {
-			return NullValue, nil
+		for _, pattern := range clause.Patterns {
+			caseEnv := NewEnclosedEnvironment(env)
+			matched, err := matchPattern(pattern, target, caseEnv)
+			if err != nil {
+				return nil, err
+			}
+			if !matched {
+				continue
+			}
+			if clause.Body == nil {
+				return NullValue, nil
+			}
+			return evalStatement(clause.Body, caseEnv)
 		}
-		return evalStatement(clause.Body, caseEnv)
 	}
 
+	if match.Else != nil {
+		return evalStatement(match.Else, NewEnclosedEnvironment(env))
+	}
 	return NullValue, nil
 }
 
@@ -1498,6 +2076,10 @@ func matchPattern(pattern ast.Pattern, value Value, env *Environment) (bool, err
 		if err != nil {
 			return false, err
 		}
+		if rng, ok := expected.(*Range); ok {
+			n, isNumber := value.(*Number)
+			return isNumber && rng.Contains(n.Value), nil
+		}
 		return equals(expected, value), nil
 	case *ast.ObjectPattern:
 		obj, ok := value.(*Object)
@@ -1507,11 +2089,54 @@ func matchPattern(pattern ast.Pattern, value Value, env *Environment) (bool, err
 		return matchObjectPattern(p, obj, env)
 	case *ast.StructPattern:
 		return matchStructPatternRuntime(p, value, env)
+	case *ast.ArrayPattern:
+		arr, ok := value.(*Array)
+		if !ok {
+			return false, nil
+		}
+		return matchArrayPattern(p, arr, env)
 	default:
 		return false, fmt.Errorf("unsupported pattern %T", pattern)
 	}
 }
 
+func matchArrayPattern(pattern *ast.ArrayPattern, value *Array, env *Environment) (bool, error) {
+	elements := value.Elements
+	if len(elements) < len(pattern.Elements) || (!pattern.HasRest && len(elements) != len(pattern.Elements)) {
+		return false, nil
+	}
+	for i, el := range pattern.Elements {
+		matched, err := matchPattern(el, elements[i], env)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	if pattern.Rest != nil {
+		rest := slices.Clone(elements[len(pattern.Elements):])
+		env.Set(pattern.Rest.Name, &Array{Elements: rest})
+	}
+	return true, nil
+}
+
+// destructure binds the names of a destructuring declaration. The pattern is
+// matched in a scratch scope first, so a value that does not fit binds
+// nothing.
+func destructure(pattern ast.Pattern, value Value, env *Environment) error {
+	scratch := NewEnclosedEnvironment(env)
+	matched, err := matchPattern(pattern, value, scratch)
+	if err != nil {
+		return err
+	}
+	if !matched {
+		return fmt.Errorf("cannot destructure %s with pattern %s", value.Inspect(), printer.Node(pattern))
+	}
+	for _, id := range ast.PatternBindings(pattern) {
+		val, _ := scratch.lookupLocal(id.Name)
+		env.Set(id.Name, val)
+	}
+	return nil
+}
+
 func matchObjectPattern(pattern *ast.ObjectPattern, value *Object, env *Environment) (bool, error) {
 	for _, pair := range pattern.Pairs {
 		propName := pair.Key
@@ -1617,6 +2242,14 @@ func getProperty(object Value, property string) (Value, bool, error) {
 			return bindMethod(fn, obj), true, nil
 		}
 		return nil, false, fmt.Errorf("unknown array property %s", property)
+	case *ErrorValue:
+		return errorValueProperty(obj, property)
+	case *Set:
+		return setProperty(obj, property)
+	case *Bytes:
+		return bytesProperty(obj, property)
+	case *Iterator:
+		return iteratorProperty(obj, property)
 	case *String:
 		if property == "length" {
 			return NewNumber(float64(len(obj.Value))), true, nil
@@ -1647,6 +2280,13 @@ func getProperty(object Value, property string) (Value, bool, error) {
 		}
 		return nil, false, nil
 	case *ClassInstance:
+		if getter, ok := obj.Definition.lookupAccessor(property, "get"); ok {
+			val, err := applyFunction(bindMethod(getter, obj), nil)
+			if err != nil {
+				return nil, false, err
+			}
+			return val, true, nil
+		}
 		if val, ok := obj.Fields[property]; ok {
 			return val, true, nil
 		}
@@ -1666,18 +2306,25 @@ func getProperty(object Value, property string) (Value, bool, error) {
 		}
 		return nil, false, nil
 	case *EnumType:
-		val, ok := obj.Constructors[property]
-		return val, ok, nil
+		if val, ok := obj.Constructors[property]; ok {
+			return val, true, nil
+		}
+		return enumTypeProperty(obj, property)
 	case *EnumInstance:
 		if property == "case" {
 			return NewString(obj.Case), true, nil
 		}
-		val, ok := obj.Fields[property]
-		return val, ok, nil
+		if val, ok := obj.Fields[property]; ok {
+			return val, true, nil
+		}
+		if method, ok := obj.Enum.Methods[property]; ok {
+			return bindMethod(method, obj), true, nil
+		}
+		return enumInstanceProperty(obj, property)
 	case *ChannelValue:
 		switch property {
 		case "send":
-			return NewBuiltin("send", func(args []Value) (Value, error) {
+			return newBuiltin("send", func(args []Value) (Value, error) {
 				if len(args) != 1 {
 					return nil, errors.New("send expects a single argument")
 				}
@@ -1687,14 +2334,14 @@ func getProperty(object Value, property string) (Value, bool, error) {
 				return NullValue, nil
 			}), true, nil
 		case "recv":
-			return NewBuiltin("recv", func(args []Value) (Value, error) {
+			return newBuiltin("recv", func(args []Value) (Value, error) {
 				if len(args) != 0 {
 					return nil, errors.New("recv takes no arguments")
 				}
 				return obj.recv()
 			}), true, nil
 		case "close":
-			return NewBuiltin("close", func(args []Value) (Value, error) {
+			return newBuiltin("close", func(args []Value) (Value, error) {
 				if len(args) != 0 {
 					return nil, errors.New("close takes no arguments")
 				}
@@ -1706,16 +2353,14 @@ func getProperty(object Value, property string) (Value, bool, error) {
 		default:
 			return nil, false, fmt.Errorf("unknown channel property %s", property)
 		}
+	case *Generator:
+		return generatorProperty(obj, property)
+	case *Scope:
+		return scopeProperty(obj, property)
+	case *Regex:
+		return regexProperty(obj, property)
 	case *Task:
-		if property == "join" {
-			return NewBuiltin("join", func(args []Value) (Value, error) {
-				if len(args) != 0 {
-					return nil, errors.New("join takes no arguments")
-				}
-				return obj.Join()
-			}), true, nil
-		}
-		return nil, false, fmt.Errorf("unknown task property %s", property)
+		return taskProperty(obj, property)
 	default:
 		if fn, ok := lookupExtension(object.Type(), property); ok {
 			return bindMethod(fn, object), true, nil
@@ -1729,7 +2374,7 @@ func bindMethod(fn *Function, self Value) Value {
 		return NullValue
 	}
 	if fn.Builtin != nil {
-		return NewBuiltin(fn.Name, func(args []Value) (Value, error) {
+		return newBuiltin(fn.Name, func(args []Value) (Value, error) {
 			allArgs := append([]Value{self}, args...)
 			return fn.Builtin(allArgs)
 		})
@@ -1737,7 +2382,11 @@ func bindMethod(fn *Function, self Value) Value {
 	boundEnv := NewEnclosedEnvironment(fn.Env)
 	boundEnv.Set("self", self)
 	boundEnv.Set("this", self)
-	return &Function{Declaration: fn.Declaration, Env: boundEnv, Name: fn.Name}
+	bound := &Function{Declaration: fn.Declaration, Env: boundEnv, Name: fn.Name}
+	if instance, ok := self.(*ClassInstance); ok && guardsInvariants(fn.Name) && instance.Definition.hasInvariants() {
+		bound.guarded = instance
+	}
+	return bound
 }
 
 func cloneStore(store map[string]Value) map[string]Value {
@@ -1767,6 +2416,15 @@ func evalModuleDeclaration(module *ast.ModuleDeclaration, env *Environment) (Val
 	}
 	exports := cloneStore(moduleEnv.store)
 	moduleVal := &Module{Name: module.Name.Name, Exports: exports}
+	if module.Body != nil {
+		for _, stmt := range module.Body.Statements {
+			collectDeclarations(stmt, func(name string) {
+				if !slices.Contains(moduleVal.keys, name) {
+					moduleVal.keys = append(moduleVal.keys, name)
+				}
+			})
+		}
+	}
 	env.Set(module.Name.Name, moduleVal)
 	return moduleVal, nil
 }
@@ -1778,14 +2436,18 @@ func ExecuteModuleDeclaration(module *ast.ModuleDeclaration, env *Environment) (
 }
 
 func evalImportDeclaration(imp *ast.ImportDeclaration, env *Environment) (Value, error) {
-	if len(imp.Path) == 0 {
+	segments := importSegments(imp)
+	if len(segments) == 0 {
 		return nil, errors.New("import path cannot be empty")
 	}
-	val, err := resolveImportPath(imp.Path, env)
+	val, err := resolveImport(segments, env)
 	if err != nil {
+		if imp.PathLiteral != "" {
+			return nil, fmt.Errorf("import %q: %w", imp.PathLiteral, err)
+		}
 		return nil, err
 	}
-	name := imp.Path[len(imp.Path)-1].Name
+	name := segments[len(segments)-1]
 	if imp.Alias != nil {
 		name = imp.Alias.Name
 	}
@@ -1793,23 +2455,44 @@ func evalImportDeclaration(imp *ast.ImportDeclaration, env *Environment) (Value,
 	return val, nil
 }
 
-func resolveImportPath(path []*ast.Identifier, env *Environment) (Value, error) {
+// importSegments returns the module path an import refers to. String-literal
+// paths are split on "/" so segments may contain characters such as "-" that
+// are not valid in identifier paths.
+func importSegments(imp *ast.ImportDeclaration) []string {
+	if imp.PathLiteral != "" {
+		parts := strings.Split(imp.PathLiteral, "/")
+		segments := make([]string, 0, len(parts))
+		for _, part := range parts {
+			if part != "" {
+				segments = append(segments, part)
+			}
+		}
+		return segments
+	}
+	segments := make([]string, 0, len(imp.Path))
+	for _, ident := range imp.Path {
+		segments = append(segments, ident.Name)
+	}
+	return segments
+}
+
+func resolveImportPath(path []string, env *Environment) (Value, error) {
 	var current Value
 	var ok bool
 	for i, segment := range path {
 		if i == 0 {
-			current, ok = env.Get(segment.Name)
+			current, ok = env.Get(segment)
 			if !ok {
-				return nil, fmt.Errorf("unknown import %s", segment.Name)
+				return nil, fmt.Errorf("unknown import %s", segment)
 			}
 			continue
 		}
-		val, found, err := getProperty(current, segment.Name)
+		val, found, err := getProperty(current, segment)
 		if err != nil {
 			return nil, err
 		}
 		if !found {
-			return nil, fmt.Errorf("%s has no property %s", current.Type(), segment.Name)
+			return nil, fmt.Errorf("%s has no property %s", current.Type(), segment)
 		}
 		current = val
 	}
@@ -1884,12 +2567,10 @@ func evalClassDeclaration(decl *ast.ClassDeclaration, env *Environment) (Value,
 		for name, method := range superType.Methods {
 			classType.Methods[name] = method
 		}
-		for name, val := range superType.Static {
-			classType.Static[name] = val
-		}
 	}
 
 	bodyEnv := NewEnclosedEnvironment(env)
+	classType.body = bodyEnv
 	if decl.Body != nil {
 		if _, err := evalBlock(decl.Body, bodyEnv); err != nil {
 			switch err.(type) {
@@ -1902,14 +2583,26 @@ func evalClassDeclaration(decl *ast.ClassDeclaration, env *Environment) (Value,
 			}
 		}
 	}
+	// Only members marked static become statics. Other bindings in the
+	// body stay visible to its methods without joining the class.
+	statics := staticNames(decl.Body)
 	for name, val := range bodyEnv.store {
-		switch v := val.(type) {
-		case *Function:
-			classType.Methods[name] = v
-		default:
-			classType.Static[name] = v
+		if statics[name] {
+			classType.Static[name] = val
+		} else if fn, ok := val.(*Function); ok {
+			classType.Methods[name] = fn
 		}
 	}
+	if err := declareAccessors(classType, decl.Body, bodyEnv); err != nil {
+		return nil, err
+	}
+	if err := declareAbstractMethods(classType, decl); err != nil {
+		return nil, err
+	}
+	if err := declareContracts(classType, decl, env); err != nil {
+		return nil, err
+	}
+	classType.invariants = decl.Invariants
 	env.Set(classType.Name, classType)
 	return classType, nil
 }
@@ -1935,6 +2628,20 @@ func evalEnumDeclaration(decl *ast.EnumDeclaration, env *Environment) (Value, er
 		}
 		enumType.Cases[c.Name.Name] = params
 		enumType.Constructors[c.Name.Name] = newEnumConstructor(enumType, c.Name.Name, params)
+		enumType.CaseOrder = append(enumType.CaseOrder, c.Name.Name)
+	}
+	for _, method := range decl.Methods {
+		if method.Name == nil {
+			continue
+		}
+		if enumType.Methods == nil {
+			enumType.Methods = make(map[string]*Function)
+		}
+		fn := &Function{Declaration: method, Env: env, Name: method.Name.Name}
+		if _, err := annotate(fn, nil, method.Annotations, env); err != nil {
+			return nil, err
+		}
+		enumType.Methods[method.Name.Name] = fn
 	}
 	env.Set(enumType.Name, enumType)
 	return enumType, nil
@@ -1957,7 +2664,16 @@ func evalContractDeclaration(decl *ast.ContractDeclaration, env *Environment) (V
 			}
 		}
 	}
-	contract := &Contract{Name: decl.Name.Name, Exports: cloneStore(bodyEnv.store)}
+	contract := &Contract{
+		Name:       decl.Name.Name,
+		Exports:    cloneStore(bodyEnv.store),
+		requires:   contractRequirements(decl.Body),
+		invariants: decl.Invariants,
+		env:        bodyEnv,
+	}
+	for _, required := range contract.requires {
+		delete(contract.Exports, required.Name.Name)
+	}
 	env.Set(contract.Name, contract)
 	return contract, nil
 }
@@ -1980,6 +2696,12 @@ func instantiateStruct(structType *StructType, args []Value) (Value, error) {
 }
 
 func instantiateClass(classType *ClassType, args []Value) (Value, error) {
+	if classType.Abstract {
+		return nil, fmt.Errorf("cannot instantiate abstract class %s", classType.Name)
+	}
+	if classType == errorClass && len(args) == 1 {
+		args = []Value{args[0], NullValue}
+	}
 	if len(args) != len(classType.Fields) {
 		return nil, fmt.Errorf("expected %d arguments to %s, got %d", len(classType.Fields), classType.Name, len(args))
 	}
@@ -1987,12 +2709,24 @@ func instantiateClass(classType *ClassType, args []Value) (Value, error) {
 	for i, name := range classType.Fields {
 		fields[name] = args[i]
 	}
+	if classType.isError() {
+		errorFields(fields)
+	}
 	instance := &ClassInstance{Definition: classType, Fields: fields}
 	if init, ok := classType.lookupMethod("init"); ok {
 		if err := callInitializer(init, instance); err != nil {
 			return nil, err
 		}
 	}
+	checked := classType.body.contractsEnabled()
+	if checked {
+		if err := checkInvariants(instance, ""); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkContracts(instance, checked); err != nil {
+		return nil, err
+	}
 	return instance, nil
 }
 
@@ -2003,7 +2737,7 @@ func callInitializer(fn *Function, self Value) error {
 }
 
 func newEnumConstructor(enum *EnumType, caseName string, params []string) Value {
-	return NewBuiltin(caseName, func(args []Value) (Value, error) {
+	return newBuiltin(caseName, func(args []Value) (Value, error) {
 		if len(args) != len(params) {
 			return nil, fmt.Errorf("expected %d arguments to %s.%s, got %d", len(params), enum.Name, caseName, len(args))
 		}
@@ -2017,13 +2751,52 @@ func newEnumConstructor(enum *EnumType, caseName string, params []string) Value
 	})
 }
 
-func enforceContract(block *ast.ContractBlock, env *Environment, result Value, fnName string) error {
+// enforcePreconditions checks the requires clauses of block before a call
+// runs its body, and takes the snapshots the old expressions in its
+// postconditions refer to.
+func enforcePreconditions(block *ast.ContractBlock, env *Environment, fnName string) ([]Value, error) {
+	for _, clause := range block.Clauses {
+		if clause.Kind != "requires" {
+			continue
+		}
+		condition, err := evalExpression(clause.Condition, env)
+		if err != nil {
+			return nil, err
+		}
+		if !isTruthy(condition) {
+			return nil, fmt.Errorf("precondition violated in %s: %s", cmp.Or(fnName, "<anonymous>"), printer.Node(clause.Condition))
+		}
+	}
+	snapshots := make([]Value, len(block.Olds))
+	for i, old := range block.Olds {
+		value, err := evalExpression(old.Value, env)
+		if err != nil {
+			return nil, err
+		}
+		snapshots[i] = value
+	}
+	return snapshots, nil
+}
+
+// oldBinding is the name a postcondition's environment binds the snapshot
+// of an old expression under, which no identifier can spell.
+func oldBinding(index int) string {
+	return "old#" + strconv.Itoa(index)
+}
+
+func enforceContract(block *ast.ContractBlock, env *Environment, result Value, fnName string, snapshots []Value) error {
 	if block == nil {
 		return nil
 	}
 	for _, clause := range block.Clauses {
+		if clause.Kind == "requires" {
+			continue
+		}
 		clauseEnv := NewEnclosedEnvironment(env)
 		clauseEnv.Set("result", result)
+		for i, snapshot := range snapshots {
+			clauseEnv.Set(oldBinding(i), snapshot)
+		}
 		guardSatisfied := true
 		if clause.Guard != nil {
 			guard, err := evalExpression(clause.Guard, clauseEnv)
@@ -2040,6 +2813,9 @@ func enforceContract(block *ast.ContractBlock, env *Environment, result Value, f
 			return err
 		}
 		if !isTruthy(condition) {
+			if clause.Kind == "ensures" {
+				return fmt.Errorf("postcondition violated in %s: %s", cmp.Or(fnName, "<anonymous>"), printer.Node(clause.Condition))
+			}
 			if fnName != "" {
 				return fmt.Errorf("contract violation in %s", fnName)
 			}
@@ -2049,7 +2825,16 @@ func enforceContract(block *ast.ContractBlock, env *Environment, result Value, f
 	return nil
 }
 
+// applyFunction calls fn on behalf of a builtin or of the runtime itself, as
+// for getters and initializers, where the caller's depth is not at hand.
 func applyFunction(fn Value, args []Value) (Value, error) {
+	return applyFunctionAt(unknownDepth, fn, args)
+}
+
+// applyFunctionAt calls fn from a caller callerDepth calls deep. A call that
+// would pass the runtime's maximum call depth fails with a catchable error
+// instead of exhausting the goroutine's stack.
+func applyFunctionAt(callerDepth int32, fn Value, args []Value) (Value, error) {
 	switch callable := fn.(type) {
 	case *Function:
 		if callable.Builtin != nil {
@@ -2062,36 +2847,60 @@ func applyFunction(fn Value, args []Value) (Value, error) {
 			return nil, fmt.Errorf("expected %d arguments, got %d", len(callable.Declaration.Params), len(args))
 		}
 
-		callEnv := NewEnclosedEnvironment(callable.Env)
+		var callEnv *Environment
+		if frame := callable.Env.control.frameFor(callable.Declaration); frame != nil {
+			callEnv = newSlotEnvironment(callable.Env, frame.call)
+		} else {
+			callEnv = NewEnclosedEnvironment(callable.Env)
+		}
+		if err := callEnv.Err(); err != nil {
+			return nil, err
+		}
+		if callerDepth == unknownDepth && callEnv.control != nil {
+			// Such calls can nest without passing through a call expression,
+			// as a getter that reads itself does, so they are counted as well.
+			callerDepth = callable.Env.depth + callEnv.control.unknownCalls.Add(1)
+			defer callEnv.control.unknownCalls.Add(-1)
+		}
+		if err := callEnv.enterCall(callerDepth); err != nil {
+			return nil, err
+		}
 		for i, param := range callable.Declaration.Params {
 			callEnv.Set(param.Name.Name, args[i])
 		}
+		var snapshots []Value
+		checked := callEnv.contractsEnabled()
+		if checked && callable.Declaration.Contract != nil {
+			var err error
+			if snapshots, err = enforcePreconditions(callable.Declaration.Contract, callEnv, callable.Name); err != nil {
+				return nil, err
+			}
+		}
+		if callable.Declaration.Generator {
+			return newGenerator(callable, callEnv), nil
+		}
 
 		var result Value = NullValue
 		var err error
-		if callable.Declaration.IsExprBody {
+		if body := callable.Env.control.compiledBody(callable); body != nil {
+			result, err = exitFunctionBody(body(callEnv))
+		} else if callable.Declaration.IsExprBody {
 			if callable.Declaration.BodyExpr != nil {
 				result, err = evalExpression(callable.Declaration.BodyExpr, callEnv)
 			}
 		} else if callable.Declaration.Body != nil {
-			result, err = evalBlock(callable.Declaration.Body, callEnv)
-			if err != nil {
-				switch sig := err.(type) {
-				case *returnSignal:
-					result = sig.value
-					err = nil
-				case *breakSignal:
-					err = errors.New("break outside of loop")
-				case *continueSignal:
-					err = errors.New("continue outside of loop")
-				}
-			}
+			result, err = exitFunctionBody(evalBlock(callable.Declaration.Body, callEnv))
 		}
 		if err != nil {
 			return nil, err
 		}
-		if callable.Declaration.Contract != nil {
-			if err := enforceContract(callable.Declaration.Contract, callEnv, result, callable.Name); err != nil {
+		if checked && callable.Declaration.Contract != nil {
+			if err := enforceContract(callable.Declaration.Contract, callEnv, result, callable.Name, snapshots); err != nil {
+				return nil, err
+			}
+		}
+		if checked && callable.guarded != nil {
+			if err := checkInvariants(callable.guarded, callable.Name); err != nil {
 				return nil, err
 			}
 		}
@@ -2105,13 +2914,18 @@ func applyFunction(fn Value, args []Value) (Value, error) {
 	}
 }
 
-func builtinPrint(args []Value) (Value, error) {
-	parts := make([]string, len(args))
-	for i, arg := range args {
-		parts[i] = arg.Inspect()
+// exitFunctionBody turns the return signal that ends a function body into
+// its result, and a break or continue that escaped every loop into an error.
+func exitFunctionBody(result Value, err error) (Value, error) {
+	switch sig := err.(type) {
+	case *returnSignal:
+		return sig.value, nil
+	case *breakSignal:
+		return nil, errors.New("break outside of loop")
+	case *continueSignal:
+		return nil, errors.New("continue outside of loop")
 	}
-	fmt.Println(strings.Join(parts, " "))
-	return NullValue, nil
+	return result, err
 }
 
 func builtinFormat(args []Value) (Value, error) {
@@ -2132,13 +2946,21 @@ func builtinFormat(args []Value) (Value, error) {
 				i++
 				continue
 			}
-			if i+1 < len(tmpl.Value) && tmpl.Value[i+1] == '}' {
+			if rest := tmpl.Value[i+1:]; strings.HasPrefix(rest, "}") || strings.HasPrefix(rest, ":") {
+				end := strings.IndexByte(rest, '}')
+				if end < 0 {
+					return nil, errors.New("unterminated format placeholder")
+				}
 				if argIndex >= len(args) {
 					return nil, errors.New("not enough arguments for format string")
 				}
-				builder.WriteString(toString(args[argIndex]))
+				segment, err := applyFormatSpec(args[argIndex], strings.TrimPrefix(rest[:end], ":"), true)
+				if err != nil {
+					return nil, err
+				}
+				builder.WriteString(segment)
 				argIndex++
-				i++
+				i += end + 1
 				continue
 			}
 		}
@@ -2152,23 +2974,32 @@ func builtinFormat(args []Value) (Value, error) {
 	return NewString(builder.String()), nil
 }
 
-func builtinSpawn(args []Value) (Value, error) {
-	if len(args) == 0 {
-		return nil, errors.New("spawn requires a function")
-	}
-	fn := args[0]
-	callArgs := args[1:]
+// startTask runs fn on its own goroutine. When finished is non-nil it is
+// called with the task's error before the result is delivered.
+func startTask(fn Value, callArgs []Value, finished func(error)) *Task {
 	task := NewTask()
+	shareValue(fn)
+	for _, arg := range callArgs {
+		shareValue(arg)
+	}
+	enter := forkStrand(fn)
 	go func() {
+		exit := enter()
+		var result Value = NullValue
+		var err error
 		defer func() {
 			if r := recover(); r != nil {
-				task.deliver(NullValue, fmt.Errorf("panic: %v", r))
+				result, err = NullValue, fmt.Errorf("panic: %v", r)
+			}
+			exit()
+			if finished != nil {
+				finished(err)
 			}
+			task.deliver(result, err)
 		}()
-		result, err := applyFunction(fn, callArgs)
-		task.deliver(result, err)
+		result, err = applyFunctionAt(0, fn, callArgs)
 	}()
-	return task, nil
+	return task
 }
 
 func builtinChannel(args []Value) (Value, error) {
@@ -2219,6 +3050,11 @@ func renderStringLiteral(lit *ast.StringLiteral, env *Environment) (Value, error
 			if err != nil {
 				return nil, err
 			}
+			if text, ok, err := customDisplay(value); err != nil {
+				return nil, err
+			} else if ok {
+				value = NewString(text)
+			}
 			segment, err := applyFormatSpec(value, formatSpec, lit.Format)
 			if err != nil {
 				return nil, err
@@ -2248,39 +3084,7 @@ func processStringChunk(chunk string, raw bool) (string, error) {
 	if raw {
 		return strings.ReplaceAll(chunk, "\\$", "$"), nil
 	}
-	return decodeEscapes(chunk)
-}
-
-func decodeEscapes(input string) (string, error) {
-	var builder strings.Builder
-	for i := 0; i < len(input); i++ {
-		ch := input[i]
-		if ch != '\\' {
-			builder.WriteByte(ch)
-			continue
-		}
-		i++
-		if i >= len(input) {
-			return "", errors.New("unterminated escape sequence")
-		}
-		switch input[i] {
-		case 'n':
-			builder.WriteByte('\n')
-		case 'r':
-			builder.WriteByte('\r')
-		case 't':
-			builder.WriteByte('\t')
-		case '"':
-			builder.WriteByte('"')
-		case '\\':
-			builder.WriteByte('\\')
-		case '$':
-			builder.WriteByte('$')
-		default:
-			builder.WriteByte(input[i])
-		}
-	}
-	return builder.String(), nil
+	return lexer.Unescape(chunk)
 }
 
 func extractPlaceholder(source string, start int) (int, string, error) {
@@ -2383,7 +3187,11 @@ func applyFormatSpec(val Value, spec string, allow bool) (string, error) {
 		if strings.HasPrefix(spec, "%") {
 			return fmt.Sprintf(spec, valueToInterface(val)), nil
 		}
-		return "", fmt.Errorf("unknown format specifier %q", spec)
+		parsed, err := parseFormatSpec(spec)
+		if err != nil {
+			return "", err
+		}
+		return parsed.apply(val)
 	}
 }
 
@@ -2402,7 +3210,13 @@ func valueToInterface(val Value) interface{} {
 	}
 }
 
+// implementsInterface reports whether val's type has an impl of iface or,
+// failing that, whether val has every method of iface with the declared
+// arity.
 func implementsInterface(val Value, iface *InterfaceType) bool {
+	if iface.hasImpl(val) {
+		return true
+	}
 	for name, arity := range iface.Methods {
 		prop, ok, err := getProperty(val, name)
 		if err != nil || !ok {
@@ -2447,6 +3261,9 @@ func evalIsOperator(left, right Value) (Value, error) {
 }
 
 func isInstanceOfClass(val Value, class *ClassType) bool {
+	if _, ok := val.(*ErrorValue); ok {
+		return class == errorClass
+	}
 	inst, ok := val.(*ClassInstance)
 	if !ok {
 		return false
@@ -2494,12 +3311,15 @@ func evalUsingStatement(stmt *ast.UsingStatement, env *Environment) (Value, erro
 	if err != nil {
 		return nil, err
 	}
-	blockEnv := NewEnclosedEnvironment(env)
+	blockEnv := newScope(env, stmt.Body)
 	if stmt.Name != nil {
 		blockEnv.Set(stmt.Name.Name, resource)
 	}
 	result, execErr := evalBlock(stmt.Body, blockEnv)
-	if closeErr := closer(); closeErr != nil {
+	release := env.control.cleanup()
+	closeErr := closer()
+	release()
+	if closeErr != nil {
 		if execErr == nil {
 			execErr = closeErr
 		}
@@ -2511,14 +3331,13 @@ func evalUsingStatement(stmt *ast.UsingStatement, env *Environment) (Value, erro
 }
 
 func evalTryStatement(stmt *ast.TryStatement, env *Environment) (Value, error) {
-	tryEnv := NewEnclosedEnvironment(env)
+	tryEnv := newScope(env, stmt.Body)
 	result, err := evalBlock(stmt.Body, tryEnv)
 
 	switch err.(type) {
-	case *returnSignal, *breakSignal, *continueSignal:
+	case *returnSignal, *breakSignal, *continueSignal, *rethrowSignal, *generatorStop, *ExitError, *CancelledError, *BudgetError:
 		if stmt.Finally != nil {
-			finalEnv := NewEnclosedEnvironment(env)
-			if finalResult, finalErr := evalBlock(stmt.Finally, finalEnv); finalErr != nil {
+			if finalResult, finalErr := evalFinally(stmt.Finally, env); finalErr != nil {
 				return finalResult, finalErr
 			}
 		}
@@ -2526,25 +3345,30 @@ func evalTryStatement(stmt *ast.TryStatement, env *Environment) (Value, error) {
 	case nil:
 		// no error
 	default:
+		frames := StackTrace(err)
 		runtimeErr := wrapRuntimeError(err)
-		if stmt.Catch != nil {
-			catchEnv := NewEnclosedEnvironment(env)
-			if stmt.Catch.Identifier != nil {
-				catchEnv.Set(stmt.Catch.Identifier.Name, runtimeErr.value)
+		clause, bound, matchErr := catchClause(stmt.Catches, runtimeErr, env)
+		switch {
+		case matchErr != nil:
+			err = matchErr
+		case clause == nil:
+			err = runtimeErr
+		case clause.Body == nil:
+			err = nil
+		default:
+			catchEnv := newScope(env, clause.Body)
+			if clause.Identifier != nil {
+				catchEnv.Set(clause.Identifier.Name, withStack(bound, frames))
 			}
-			if stmt.Catch.Body != nil {
-				result, err = evalBlock(stmt.Catch.Body, catchEnv)
-			} else {
-				err = nil
+			result, err = evalBlock(clause.Body, catchEnv)
+			if _, ok := err.(*rethrowSignal); ok {
+				err = runtimeErr
 			}
-		} else {
-			err = runtimeErr
 		}
 	}
 
 	if stmt.Finally != nil {
-		finalEnv := NewEnclosedEnvironment(env)
-		finalResult, finalErr := evalBlock(stmt.Finally, finalEnv)
+		finalResult, finalErr := evalFinally(stmt.Finally, env)
 		if finalErr != nil {
 			return finalResult, finalErr
 		}
@@ -2555,6 +3379,50 @@ func evalTryStatement(stmt *ast.TryStatement, env *Environment) (Value, error) {
 	return result, err
 }
 
+// catchClause returns the first of clauses that handles caught, and the value
+// it binds: the Error for a clause without a type, which handles everything,
+// and the thrown value itself for a clause with one, which handles values of
+// that type the way is tests them. A type name that is not bound, such as
+// String, matches the runtime's name for the value's type.
+func catchClause(clauses []*ast.CatchClause, caught *runtimeError, env *Environment) (*ast.CatchClause, Value, error) {
+	thrown := caught.thrown
+	if thrown == nil {
+		thrown = caught.value
+	}
+	for _, clause := range clauses {
+		if clause.Type == nil || clause.Type.Name == nil {
+			return clause, caught.caught(), nil
+		}
+		typeVal, ok := env.getIdentifier(clause.Type.Name)
+		if !ok {
+			if thrown.Type() == clause.Type.Name.Name {
+				return clause, thrown, nil
+			}
+			continue
+		}
+		switch typeVal.(type) {
+		case *ClassType, *StructType, *EnumType, *InterfaceType:
+		default:
+			return nil, nil, fmt.Errorf("catch clause type %s is a %s, not a type", clause.Type.Name.Name, typeVal.Type())
+		}
+		matched, err := evalIsOperator(thrown, typeVal)
+		if err != nil {
+			return nil, nil, err
+		}
+		if isTruthy(matched) {
+			return clause, thrown, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// evalFinally runs a finally block as cleanup, so an Interrupt arriving
+// before or during it does not cut it short.
+func evalFinally(block *ast.BlockStatement, env *Environment) (Value, error) {
+	defer env.control.cleanup()()
+	return evalBlock(block, newScope(env, block))
+}
+
 func evalConditionStatement(stmt *ast.ConditionStatement, env *Environment) (Value, error) {
 	for _, clause := range stmt.Clauses {
 		cond, err := evalExpression(clause.Test, env)
@@ -16,6 +16,7 @@ func TestInspectOutputs(t *testing.T) {
 		{"array", &Array{Elements: []Value{NewNumber(1), NewString("two")}}, "[1, two]"},
 		{"array empty", &Array{}, "[]"},
 		{"object", &Object{Properties: map[string]Value{"b": TrueValue, "a": NewNumber(2)}}, "{a: 2, b: true}"},
+		{"object ordered", NewObject(Property{"b", TrueValue}, Property{"a", NewNumber(2)}, Property{"b", FalseValue}), "{b: false, a: 2}"},
 		{"object empty", &Object{}, "{}"},
 		{"module", module, "<module math [Pi]>"},
 		{"struct type", structType, "<struct Point>"},
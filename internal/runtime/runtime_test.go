@@ -1,12 +1,24 @@
 package runtime
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/cybellereaper/selenelang/internal/ast"
 	"github.com/cybellereaper/selenelang/internal/lexer"
 	"github.com/cybellereaper/selenelang/internal/parser"
+	"github.com/cybellereaper/selenelang/internal/printer"
 )
 
 func resetExtensions() {
@@ -87,7 +99,7 @@ fn main() {
 `)
 	rt := New()
 	var calls int
-	rt.Environment().Set("record", NewBuiltin("record", func(args []Value) (Value, error) {
+	rt.Environment().Set("record", newBuiltin("record", func(args []Value) (Value, error) {
 		calls++
 		return NullValue, nil
 	}))
@@ -109,7 +121,7 @@ main();
 `)
 	rt := New()
 	var calls int
-	rt.Environment().Set("record", NewBuiltin("record", func(args []Value) (Value, error) {
+	rt.Environment().Set("record", newBuiltin("record", func(args []Value) (Value, error) {
 		calls++
 		return NullValue, nil
 	}))
@@ -129,7 +141,7 @@ fn main() {
 `)
 	rt := New()
 	var calls int
-	rt.Environment().Set("record", NewBuiltin("record", func(args []Value) (Value, error) {
+	rt.Environment().Set("record", newBuiltin("record", func(args []Value) (Value, error) {
 		calls++
 		return NullValue, nil
 	}))
@@ -145,6 +157,710 @@ fn main() {
 	}
 }
 
+func TestLogicalOperatorsShortCircuit(t *testing.T) {
+	const src = `
+let user = null;
+let calls = 0;
+fn touch(value: Any) { calls += 1; return value; }
+[user != null && user.name, user || "guest", 0 && touch(1), "x" || touch(2), 1 && touch("y"), null || touch(0), calls, false && 1 / 0]
+`
+	const want = "[false, guest, 0, x, y, 0, 2, false]"
+	result, err := New().Run(parseProgram(t, src))
+	if err != nil || result.Inspect() != want {
+		t.Fatalf("interpreter: expected %s, got %v (%v)", want, result, err)
+	}
+	rt := New()
+	chunk, err := rt.Compile(parseProgram(t, src))
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if result, err := rt.RunChunk(chunk); err != nil || result.Inspect() != want {
+		t.Fatalf("vm: expected %s, got %v (%v)", want, result, err)
+	}
+}
+
+func TestCompoundAssignmentsReachMembersAndElements(t *testing.T) {
+	const src = `
+class Counter(count: Number) {}
+var counter = Counter(1);
+counter.count += 1;
+counter.count++;
+var scores = [1, 2, 3];
+let before = scores;
+scores[1] *= 10;
+scores[0]++;
+var grid = [[0, 0], [0, 0]];
+grid[1][0] = 7;
+var config = {retries: 1, ports: [80, 443]};
+config.retries += 2;
+config.ports[1]--;
+config.host = "local";
+var i = 5;
+let old = i++;
+[counter.count, scores, before, grid, config, old, i--, i]
+`
+	const want = "[3, [2, 20, 3], [1, 2, 3], [[0, 0], [7, 0]], {retries: 3, ports: [80, 442], host: local}, 5, 6, 5]"
+	result, err := New().Run(parseProgram(t, src))
+	if err != nil || result.Inspect() != want {
+		t.Fatalf("interpreter: expected %s, got %v (%v)", want, result, err)
+	}
+	rt := New()
+	chunk, err := rt.Compile(parseProgram(t, src))
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if result, err := rt.RunChunk(chunk); err != nil || result.Inspect() != want {
+		t.Fatalf("vm: expected %s, got %v (%v)", want, result, err)
+	}
+	for src, msg := range map[string]string{
+		`var s = "a"; s++;`:                         "operator ++ requires a Number, got String",
+		`var a = [1]; a[1] = 2;`:                    "array index 1 out of range",
+		`fn f(): Array { return [1]; } f()[0] = 2;`: "cannot assign to an element of a temporary Array",
+		`5++;`: "unsupported assignment target",
+	} {
+		if _, err := New().Run(parseProgram(t, src)); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("%s: expected %q, got %v", src, msg, err)
+		}
+	}
+}
+
+func TestRangesIterateMatchAndTestMembership(t *testing.T) {
+	const src = `
+var seen = "";
+for (i in 0..3) { seen += i; }
+for (i in 1..=2) { seen += " " + i * 10; }
+fn grade(score: Number): String {
+    match score {
+        90..=100 => return "A";
+        60..90 => return "B";
+        _ => return "F";
+    }
+}
+let x = 10;
+[seen, 0..3, grade(100), grade(89.5), grade(59), x in 0..10, x in 0..=10, 2.5 in 0..10,
+    2 in [1, 2], "b" in #{"a"}, "ell" in "hello", "k" in {k: 1}, 0..3 == 0..3, 0..3 == 0..=3]
+`
+	const want = "[012 10 20, 0..3, A, B, F, false, true, true, true, false, true, true, true, false]"
+	result, err := New().Run(parseProgram(t, src))
+	if err != nil || result.Inspect() != want {
+		t.Fatalf("interpreter: expected %s, got %v (%v)", want, result, err)
+	}
+	rt := New()
+	chunk, err := rt.Compile(parseProgram(t, src))
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if result, err := rt.RunChunk(chunk); err != nil || result.Inspect() != want {
+		t.Fatalf("vm: expected %s, got %v (%v)", want, result, err)
+	}
+	for src, msg := range map[string]string{
+		`"a"..3;`:     "range bounds must be Numbers, got String .. Number",
+		`1 in 5;`:     "operator in not supported for Number",
+		`1 in "one";`: "operator in needs a String to find in a String, got Number",
+	} {
+		if _, err := New().Run(parseProgram(t, src)); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("%s: expected %q, got %v", src, msg, err)
+		}
+	}
+}
+
+func TestOptionalChainsShortCircuitCallsAndIndexing(t *testing.T) {
+	const src = `
+class User(name: String) {
+    fn greet(): String { return "hi " + self.name; }
+}
+var calls = 0;
+fn count(): Number { calls += 1; return calls; }
+var user = null;
+let skipped = [user?.greet(), user?.name.length, user?[count()].x.y(count()), calls];
+user = User("ada");
+let rows = [[1, 2], null];
+[skipped, user?.greet(), user?.name.length, rows?[0]?[1], rows[1]?[0]]
+`
+	const want = "[[null, null, null, 0], hi ada, 3, 2, null]"
+	result, err := New().Run(parseProgram(t, src))
+	if err != nil || result.Inspect() != want {
+		t.Fatalf("interpreter: expected %s, got %v (%v)", want, result, err)
+	}
+	rt := New()
+	chunk, err := rt.Compile(parseProgram(t, src))
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if result, err := rt.RunChunk(chunk); err != nil || result.Inspect() != want {
+		t.Fatalf("vm: expected %s, got %v (%v)", want, result, err)
+	}
+	for src, msg := range map[string]string{
+		`let rows = [null]; rows[0][1];`:    "cannot index into Null",
+		`let rows = [[1]]; rows?[0]?[3];`:   "array index 3 out of range",
+		`var rows = [[1]]; rows?[0] = [2];`: "cannot assign through ?[",
+	} {
+		if _, err := New().Run(parseProgram(t, src)); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("%s: expected %q, got %v", src, msg, err)
+		}
+	}
+}
+
+func TestTypedCatchClausesMatchThrownValuesAndRethrow(t *testing.T) {
+	const src = `
+class NetworkError(code: Number) {}
+class DiskError(path: String) {}
+fn attempt(err: Any): String {
+    try {
+        throw err;
+    } catch (e: NetworkError) {
+        return "network " + e.code;
+    } catch (e: String) {
+        return "text " + e;
+    } catch (e) {
+        return "other";
+    }
+}
+fn relay(): String {
+    try {
+        try { throw NetworkError(500); } catch (e: NetworkError) { rethrow; }
+    } catch (e: NetworkError) {
+        return "relayed " + e.code;
+    }
+}
+var escaped = "";
+try {
+    try { throw DiskError("/tmp"); } catch (e: NetworkError) { escaped = "caught"; }
+} catch (e: DiskError) {
+    escaped = "escaped " + e.path;
+}
+[attempt(NetworkError(503)), attempt("boom"), attempt(DiskError("/")), relay(), escaped]
+`
+	const want = "[network 503, text boom, other, relayed 500, escaped /tmp]"
+	result, err := New().Run(parseProgram(t, src))
+	if err != nil || result.Inspect() != want {
+		t.Fatalf("interpreter: expected %s, got %v (%v)", want, result, err)
+	}
+	rt := New()
+	chunk, err := rt.Compile(parseProgram(t, src))
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if result, err := rt.RunChunk(chunk); err != nil || result.Inspect() != want {
+		t.Fatalf("vm: expected %s, got %v (%v)", want, result, err)
+	}
+	for src, msg := range map[string]string{
+		`try { throw "x"; } catch (e: Number) {}`:               "x",
+		`let limit = 3; try { throw "x"; } catch (e: limit) {}`: "catch clause type limit is a Number, not a type",
+	} {
+		if _, err := New().Run(parseProgram(t, src)); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("%s: expected %q, got %v", src, msg, err)
+		}
+	}
+}
+
+func TestErrorClassesCarryMessageCauseAndStack(t *testing.T) {
+	const src = `
+class NetworkError(code: Number) : Error {
+    fn init() { self.message = "failed with " + self.code; }
+}
+class TimeoutError(message: String, cause: Any) : Error {}
+fn fetch(code: Number) { throw NetworkError(code); }
+fn load() { fetch(503); }
+var seen = [];
+try { load(); } catch (e: NetworkError) {
+    seen = ["${e}", e.code, e.stack, e is Error];
+}
+var wrapped = "";
+try { throw TimeoutError("slow", Error("closed")); } catch (e) {
+    wrapped = "${e} / ${e.cause.message}";
+}
+var raised = "";
+try { [1][5]; } catch (e: Error) { raised = e.message; }
+let plain = Error("plain");
+[seen, wrapped, raised, "${plain}"]
+`
+	const want = "[[<error NetworkError: failed with 503>, 503, [at fetch (called from 7:13), at load (called from 9:7)], true], " +
+		"<error TimeoutError: slow : <error closed>> / closed, array index 5 out of range, <error plain>]"
+	result, err := New().Run(parseProgram(t, src))
+	if err != nil || result.Inspect() != want {
+		t.Fatalf("interpreter: expected %s, got %v (%v)", want, result, err)
+	}
+	rt := New()
+	chunk, err := rt.Compile(parseProgram(t, src))
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if result, err := rt.RunChunk(chunk); err != nil || result.Inspect() != want {
+		t.Fatalf("vm: expected %s, got %v (%v)", want, result, err)
+	}
+	if _, err := New().Run(parseProgram(t, "class Lost(path: String) : Error {}\nthrow Lost(\"a\");\n")); err == nil || err.Error() != "Lost" {
+		t.Fatalf("expected an uncaught error reported as Lost, got %v", err)
+	}
+}
+
+func TestLabelledBreakAndContinueLeaveOuterLoops(t *testing.T) {
+	const src = `
+var found = "";
+outer: for (var i = 0; i < 4; i++) {
+    var j = 0;
+    while (j < 4) {
+        j++;
+        if (j == 2) { continue outer; }
+        if (i == 3) { break outer; }
+        found = found + i + j + " ";
+    }
+}
+rows: for (r in 1..=3) {
+    for (c in 1..=3) {
+        if (c > r) { continue rows; }
+        if (r == 3) { break rows; }
+        found = found + r + c + " ";
+    }
+}
+fn first(grid: Array): Number {
+    search: for (row in grid) {
+        for (cell in row) {
+            if (cell < 0) { break search; }
+            if (cell > 0) { return cell; }
+        }
+    }
+    return 0;
+}
+[found, first([[0, 0], [0, 7]]), first([[0, -1], [5]])]
+`
+	const want = "[01 11 21 11 21 22 , 7, 0]"
+	result, err := New().Run(parseProgram(t, src))
+	if err != nil || result.Inspect() != want {
+		t.Fatalf("interpreter: expected %s, got %v (%v)", want, result, err)
+	}
+	rt := New()
+	chunk, err := rt.Compile(parseProgram(t, src))
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if result, err := rt.RunChunk(chunk); err != nil || result.Inspect() != want {
+		t.Fatalf("vm: expected %s, got %v (%v)", want, result, err)
+	}
+}
+
+func TestDoWhileLoopsTestTheConditionAfterTheBody(t *testing.T) {
+	const src = `
+var passes = 0;
+do { passes++; } while (false);
+var seen = "";
+var i = 0;
+rows: do {
+    i++;
+    if (i == 2) { continue; }
+    var j = 0;
+    do {
+        j++;
+        if (i == 4) { break rows; }
+        seen = seen + i + j + " ";
+    } while (j < 2);
+} while (i < 10);
+[passes, seen, i]
+`
+	const want = "[1, 11 12 31 32 , 4]"
+	result, err := New().Run(parseProgram(t, src))
+	if err != nil || result.Inspect() != want {
+		t.Fatalf("interpreter: expected %s, got %v (%v)", want, result, err)
+	}
+	rt := New()
+	chunk, err := rt.Compile(parseProgram(t, src))
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if result, err := rt.RunChunk(chunk); err != nil || result.Inspect() != want {
+		t.Fatalf("vm: expected %s, got %v (%v)", want, result, err)
+	}
+}
+
+func TestMatchArmsWithSeveralPatternsAndElse(t *testing.T) {
+	const src = `
+fn describe(v: Any): String {
+    match v {
+        0, 1 => { return "small"; }
+        [x, 0], [0, x] => { return "axis " + x; }
+        else => { return "other"; }
+    }
+    return "unreached";
+}
+var seen = "none";
+match 5 { 1, 2 => { seen = "low"; } }
+[describe(1), describe([5, 0]), describe([0, 7]), describe(42), seen]
+`
+	const want = "[small, axis 5, axis 7, other, none]"
+	result, err := New().Run(parseProgram(t, src))
+	if err != nil || result.Inspect() != want {
+		t.Fatalf("interpreter: expected %s, got %v (%v)", want, result, err)
+	}
+	rt := New()
+	chunk, err := rt.Compile(parseProgram(t, src))
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if result, err := rt.RunChunk(chunk); err != nil || result.Inspect() != want {
+		t.Fatalf("vm: expected %s, got %v (%v)", want, result, err)
+	}
+}
+
+func TestRuntimeCompileFoldsConstantExpressions(t *testing.T) {
+	program := parseProgram(t, `
+let label = "n=" + (2 + 3) * 4;
+record(label, "abc".length, -(1 + 1));
+fn broken() { return 1 / 0; }
+`)
+	rt := New()
+	var got []string
+	rt.Environment().Set("record", newBuiltin("record", func(args []Value) (Value, error) {
+		for _, arg := range args {
+			got = append(got, arg.Inspect())
+		}
+		return NullValue, nil
+	}))
+	chunk, err := rt.Compile(program)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	item, _ := chunk.ProgramItem(0)
+	if lit, ok := item.(*ast.VariableDeclaration).Value.(*ast.StringLiteral); !ok || lit.Value != "n=20" {
+		t.Fatalf("expected initializer folded to \"n=20\", got %#v", item.(*ast.VariableDeclaration).Value)
+	}
+	if _, err := rt.RunChunk(chunk); err != nil {
+		t.Fatalf("RunChunk failed: %v", err)
+	}
+	if strings.Join(got, ",") != "n=20,3,-2" {
+		t.Fatalf("unexpected values %v", got)
+	}
+	if _, err := evalExpression(&ast.CallExpression{Callee: &ast.Identifier{Name: "broken"}}, rt.Environment()); err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Fatalf("expected the unfolded division to fail at run time, got %v", err)
+	}
+}
+
+func TestGeneratorsSuspendAndResume(t *testing.T) {
+	got := runRecording(t, `
+fn count*(limit: Number) {
+    var i = 0;
+    try {
+        while i < limit {
+            let sent = yield i;
+            if sent != null { record("sent", sent); }
+            i += 1;
+        }
+    } finally {
+        record("cleanup", i);
+    }
+    return "done";
+}
+
+fn main() {
+    let gen = count(2);
+    record(gen.next().value);
+    record(gen.next("hi").value);
+    let last = gen.next();
+    record(last.value, last.done);
+    record(gen.next().done);
+    for (n in count(5)) {
+        if n == 1 { break; }
+        record("loop", n);
+    }
+    for (let ch in "ab") { record(ch); }
+}
+`)
+	want := []string{
+		"0", "sent hi", "1", "cleanup 2", "done true", "true",
+		"loop 0", "cleanup 1", "a", "b",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected generator trace:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestGeneratorCloseStopsSuspendedBody(t *testing.T) {
+	got := runRecording(t, `
+fn ticks*() {
+    var i = 0;
+    while true {
+        yield i;
+        i += 1;
+    }
+}
+
+let gen = ticks();
+record(gen.next().value);
+gen.close();
+record(gen.next().done);
+`)
+	if want := []string{"0", "true"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected trace %v, want %v", got, want)
+	}
+}
+
+func TestScopeWaitsForChildTasks(t *testing.T) {
+	got := runRecording(t, `
+fn work(out: Channel, n: Number) {
+    out.send(n * 2);
+}
+
+fn body(s: Scope) {
+    let out = channel(3);
+    s.spawn(work, out, 1);
+    s.spawn(work, out, 2);
+    s.spawn(work, out, 3);
+    return out;
+}
+
+let results = scope(body);
+results.close();
+var total = 0;
+try {
+    while true {
+        total += await results;
+    }
+} catch (err) {
+    record(total);
+}
+`)
+	if want := []string{"12"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected trace %v, want %v", got, want)
+	}
+}
+
+func TestScopeCancelsSiblingsAndAggregatesErrors(t *testing.T) {
+	got := runRecording(t, `
+fn fail(message: String) {
+    throw message;
+}
+
+fn watch(s: Scope) {
+    while !s.cancelled() {
+    }
+    record("observed cancel");
+}
+
+fn body(s: Scope) {
+    s.spawn(watch, s);
+    s.spawn(fail, "boom");
+}
+
+try {
+    scope(body);
+} catch (err) {
+    record(err);
+}
+
+fn both(s: Scope) {
+    let first = s.spawn(fail, "one");
+    try {
+        await first;
+    } catch (err) {
+    }
+    throw "two";
+}
+
+try {
+    scope(both);
+} catch (err) {
+    record(err);
+}
+`)
+	want := []string{"observed cancel", "<error boom>", "<error scope failed with 2 errors: one; two>"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected trace %v, want %v", got, want)
+	}
+}
+
+func TestRegexModule(t *testing.T) {
+	got := runRecording(t, `
+let date = regex.compile("(?P<year>\\d{4})-(\\d{2})");
+record(date.match("released 2024-05"), date.match("soon"));
+let found = date.find("née 1999-12");
+record(found.text, found.index, found.groups[1], found.named.year);
+record(date.find("nothing"));
+record(date.findAll("2020-01 2021-02 2022-03", 2).length);
+record(regex.compile("\\s*,\\s*").split("a , b,c"));
+record(date.replace("2024-05", "$2/$year"));
+`)
+	want := []string{"true false", "1999-12 4 12 1999", "null", "2", "[a, b, c]", "05/2024"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected trace %v, want %v", got, want)
+	}
+}
+
+func TestProcessModule(t *testing.T) {
+	t.Setenv("SELENE_TEST_PROCESS", "set")
+	source := `
+let argv = os.args();
+record(argv.length, argv[0]);
+record(os.env("SELENE_TEST_PROCESS"), os.env("SELENE_TEST_PROCESS_MISSING"));
+let result = os.exec("sh", ["-c", "echo out; echo err >&2; exit 3"]);
+record(result.stdout, result.stderr, result.status);
+try {
+    os.exit(4);
+} catch (err) {
+    record("caught exit");
+} finally {
+    record("finally ran");
+}
+record("unreachable");
+`
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	program := parseProgram(t, source)
+	rt := New()
+	rt.SetArgs([]string{"alpha", "beta"})
+	var lines []string
+	rt.Environment().Set("record", newBuiltin("record", func(args []Value) (Value, error) {
+		parts := make([]string, len(args))
+		for i, arg := range args {
+			parts[i] = strings.TrimSpace(arg.Inspect())
+		}
+		lines = append(lines, strings.Join(parts, " "))
+		return NullValue, nil
+	}))
+	_, err := rt.Run(program)
+	var exit *ExitError
+	if !errors.As(err, &exit) || exit.Code != 4 {
+		t.Fatalf("expected exit status 4, got %v", err)
+	}
+	want := []string{"2 alpha", "set null", "out err 3", "finally ran"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected trace %v, want %v", lines, want)
+	}
+}
+
+func TestSandboxedRuntimeRefusesExec(t *testing.T) {
+	program := parseProgram(t, `os.exec("sh", ["-c", "true"]);`)
+	rt := New()
+	rt.SetSandboxed(true)
+	if _, err := rt.Run(program); err == nil || !strings.Contains(err.Error(), "sandboxed") {
+		t.Fatalf("expected sandbox error, got %v", err)
+	}
+}
+
+func TestInjectedHostEnvReachesProcessesAndFSPaths(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	t.Setenv("SELENE_TEST_HOST_SECRET", "leaked")
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "work"), 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	program := parseProgram(t, `
+os.chdir("work");
+fs.write("notes.txt", "hi");
+let result = os.exec("sh", ["-c", "pwd; echo \"$SELENE_TEST_HOST_VAR$SELENE_TEST_HOST_SECRET\""]);
+print(result.stdout);
+`)
+	rt := New()
+	vfs := NewMemoryFileSystem(nil)
+	rt.SetFileSystem(vfs)
+	rt.SetHostEnv(NewMemoryHostEnv(map[string]string{"SELENE_TEST_HOST_VAR": "private"}, root))
+	var out strings.Builder
+	rt.SetStdio(nil, &out, nil)
+	if _, err := rt.Run(program); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	work := filepath.Join(root, "work")
+	if want := work + "\nprivate\n\n"; out.String() != want {
+		t.Fatalf("expected the process to see only the host env, got %q, want %q", out.String(), want)
+	}
+	if files := vfs.Files(); len(files) != 1 || files[0] != filepath.ToSlash(filepath.Join(work, "notes.txt")) {
+		t.Fatalf("expected the write relative to the virtual cwd, got %v", files)
+	}
+}
+
+func TestFSModuleUsesInjectedFileSystem(t *testing.T) {
+	program := parseProgram(t, `
+fs.write("out/../report.txt", fs.read("input.txt") + "!");
+`)
+	rt := New()
+	vfs := NewMemoryFileSystem(map[string]string{"input.txt": "hi"})
+	rt.SetFileSystem(vfs)
+	if _, err := rt.Run(program); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	data, err := vfs.ReadFile("report.txt")
+	if err != nil || string(data) != "hi!" {
+		t.Fatalf("expected report.txt to contain hi!, got %q (%v)", data, err)
+	}
+	if files := vfs.Files(); strings.Join(files, ",") != "input.txt,report.txt" {
+		t.Fatalf("unexpected files %v", files)
+	}
+}
+
+func runRecording(t *testing.T, source string) []string {
+	t.Helper()
+	program := parseProgram(t, source)
+	rt := New()
+	var mu sync.Mutex
+	var lines []string
+	rt.Environment().Set("record", newBuiltin("record", func(args []Value) (Value, error) {
+		parts := make([]string, len(args))
+		for i, arg := range args {
+			parts[i] = arg.Inspect()
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, strings.Join(parts, " "))
+		return NullValue, nil
+	}))
+	if _, err := rt.Run(program); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	return lines
+}
+
+func TestRegisterChecksArgumentsAndPassesCallSite(t *testing.T) {
+	rt := New()
+	var sites []int
+	err := rt.Register(BuiltinSpec{Name: "pair", MinArgs: 1, MaxArgs: 2, Call: func(call BuiltinCall) (Value, error) {
+		sites = append(sites, call.Site.Line)
+		return NewNumber(float64(len(call.Args))), nil
+	}})
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	if err := rt.Define("settings", map[string]any{"retries": 3, "tags": []string{"a", "b"}, "debug": true}); err != nil {
+		t.Fatalf("define failed: %v", err)
+	}
+	val, err := rt.Run(parseProgram(t, "\npair(settings.retries) + pair(1, settings.tags[1]) + settings.tags.length;"))
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if val.Inspect() != "5" || len(sites) != 2 || sites[0] != 2 {
+		t.Fatalf("unexpected result %s with call sites %v", val.Inspect(), sites)
+	}
+	if _, err := rt.Run(parseProgram(t, "pair(1, 2, 3);")); err == nil || !strings.Contains(err.Error(), "pair expects at most 2 arguments, got 3") {
+		t.Fatalf("expected an arity error, got %v", err)
+	}
+
+	if err := rt.SetPolicy(Policy{Builtins: []string{"pair"}}); err != nil {
+		t.Fatalf("SetPolicy returned error: %v", err)
+	}
+	if err := rt.Register(BuiltinSpec{Name: "extra", Call: func(BuiltinCall) (Value, error) { return NullValue, nil }}); err == nil {
+		t.Fatal("expected the policy to refuse an unlisted builtin")
+	}
+	if err := rt.Define("bad", map[int]string{1: "x"}); err == nil {
+		t.Fatal("expected a map with non-string keys to be rejected")
+	}
+}
+
+func TestNewBuiltinWarnsOncePerProcess(t *testing.T) {
+	var out bytes.Buffer
+	SetDeprecationOutput(&out)
+	defer SetDeprecationOutput(os.Stderr)
+	deprecation.mu.Lock()
+	delete(deprecation.warned, "runtime.NewBuiltin")
+	deprecation.mu.Unlock()
+
+	noop := func([]Value) (Value, error) { return NullValue, nil }
+	NewBuiltin("a", noop)
+	NewBuiltin("b", noop)
+	if got := strings.Count(out.String(), "runtime.NewBuiltin is deprecated"); got != 1 {
+		t.Fatalf("expected one deprecation warning, got %d:\n%s", got, out.String())
+	}
+}
+
 func parseProgram(t *testing.T, source string) *ast.Program {
 	t.Helper()
 	l := lexer.New(source)
@@ -155,3 +871,1840 @@ func parseProgram(t *testing.T, source string) *ast.Program {
 	}
 	return program
 }
+
+func TestShutdownReportsLeakedTasksAndChannels(t *testing.T) {
+	program := parseProgram(t, `
+fn wait(ch: Channel) { ch.recv(); }
+fn quick(n: Number) { return n; }
+let done = channel(1);
+done.send(1);
+done.close();
+spawn(quick, 1);
+let stuck = channel();
+spawn(wait, stuck);
+`)
+	rt := New()
+	if _, err := rt.Run(program); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	leaks := rt.Shutdown(50 * time.Millisecond)
+	if len(leaks) != 2 {
+		t.Fatalf("expected a leaked task and channel, got %v", leaks)
+	}
+	if leaks[0].Kind != "task" || leaks[0].Site != "9:1 (<fn wait>)" {
+		t.Fatalf("unexpected task leak %+v", leaks[0])
+	}
+	if leaks[1].Kind != "channel" || leaks[1].Site != "8:13" || !strings.Contains(leaks[1].Detail, "1 blocked task") {
+		t.Fatalf("unexpected channel leak %+v", leaks[1])
+	}
+	if err := (&LeakError{Leaks: leaks}); !strings.Contains(err.Error(), "2 tasks or channels") {
+		t.Fatalf("unexpected leak error %q", err.Error())
+	}
+}
+
+func TestShutdownNamesSourceFilesAndIndirectSpawns(t *testing.T) {
+	l := lexer.New(`
+let stuck = channel();
+fn hang() { stuck.recv(); }
+fn pick() { return hang; }
+let started = spawn(pick).then(spawn);
+`)
+	l.SetFile("pool.selene")
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("unexpected parse errors: %v", errs)
+	}
+	rt := New()
+	if _, err := rt.Run(program); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	leaks := rt.Shutdown(50 * time.Millisecond)
+	if len(leaks) != 3 {
+		t.Fatalf("expected two running tasks and a blocked channel, got %v", leaks)
+	}
+	if leaks[0].Site != "pool.selene:3:1 (<fn hang>, spawned indirectly)" {
+		t.Fatalf("expected the indirect spawn at the declaration of hang, got %+v", leaks[0])
+	}
+	if leaks[1].Site != "pool.selene:5:15 (<fn spawn>)" {
+		t.Fatalf("unexpected continuation leak %+v", leaks[1])
+	}
+	if leaks[2].Kind != "channel" || leaks[2].Site != "pool.selene:2:13" {
+		t.Fatalf("unexpected channel leak %+v", leaks[2])
+	}
+}
+
+func TestShutdownReportsAndClosesSuspendedGenerators(t *testing.T) {
+	program := parseProgram(t, `
+let cleaned = "";
+fn count*() {
+    let i = 0;
+    try {
+        while true { yield i; i += 1; }
+    } finally {
+        cleaned = cleaned + "${i};";
+    }
+}
+fn once*() { yield 1; }
+let idle = count();
+let abandoned = count();
+abandoned.next();
+abandoned.next();
+let drained = once();
+drained.next();
+drained.next();
+let closed = count();
+closed.next();
+closed.close();
+`)
+	rt := New()
+	if _, err := rt.Run(program); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	leaks := rt.Shutdown(0)
+	if len(leaks) != 1 {
+		t.Fatalf("expected one suspended generator, got %v", leaks)
+	}
+	if leaks[0].Kind != "generator" || leaks[0].Site != "3:1 (<generator count>)" {
+		t.Fatalf("unexpected generator leak %+v", leaks[0])
+	}
+	cleaned, _ := rt.env.Get("cleaned")
+	if got := cleaned.Inspect(); got != "0;1;" {
+		t.Fatalf("expected the closed and abandoned generators to clean up, got %s", got)
+	}
+	if leaks := rt.Shutdown(0); len(leaks) != 0 {
+		t.Fatalf("expected Shutdown to have closed the generator, got %v", leaks)
+	}
+}
+
+func TestUnreleasedReportsUnjoinedTasksAndUnclosedChannels(t *testing.T) {
+	program := parseProgram(t, `
+fn quick(n: Number) { return n; }
+let joined = spawn(quick, 1);
+await joined;
+spawn(quick, 2);
+let closed = channel();
+closed.close();
+let idle = channel();
+`)
+	rt := New()
+	if _, err := rt.Run(program); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if leaks := rt.Shutdown(time.Second); len(leaks) != 0 {
+		t.Fatalf("expected no live leaks, got %v", leaks)
+	}
+	leaks := rt.Unreleased()
+	if len(leaks) != 2 {
+		t.Fatalf("expected an unjoined task and an unclosed channel, got %v", leaks)
+	}
+	if leaks[0].Kind != "task" || leaks[0].Site != "5:1 (<fn quick>)" || leaks[0].Detail != "was never joined" {
+		t.Fatalf("unexpected task leak %+v", leaks[0])
+	}
+	if leaks[1].Kind != "channel" || leaks[1].Site != "8:12" || leaks[1].Detail != "was never closed" {
+		t.Fatalf("unexpected channel leak %+v", leaks[1])
+	}
+}
+
+func TestSetContextCancelsLoopsAndBlockedTasks(t *testing.T) {
+	sources := map[string]string{
+		"loop": `
+while true {
+    try { let x = 1; } catch (err) { print("swallowed"); }
+}
+`,
+		"await": `
+fn forever(ch: Channel) { return ch.recv(); }
+let ch = channel();
+await spawn(forever, ch);
+`,
+	}
+	for name, source := range sources {
+		program := parseProgram(t, source)
+		rt := New()
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		rt.SetContext(ctx)
+		_, err := rt.Run(program)
+		cancel()
+		var cancelled *CancelledError
+		if !errors.As(err, &cancelled) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("%s: expected a deadline cancellation, got %v", name, err)
+		}
+	}
+}
+
+func TestInterruptRunsCleanupAndSignalHandlers(t *testing.T) {
+	program := parseProgram(t, `
+fn note(msg: String) { record(msg); }
+fn release() { note("released"); }
+fn onInt(name: String) { note("handler ${name}"); }
+fn main() {
+    os.onSignal("int", onInt);
+    using resource = { close: release } {
+        try {
+            ready();
+            while true { time.sleep(5); }
+        } finally {
+            note("finally");
+            time.sleep(1);
+        }
+    }
+}
+`)
+	rt := New()
+	var mu sync.Mutex
+	var lines []string
+	rt.Environment().Set("record", newBuiltin("record", func(args []Value) (Value, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, args[0].Inspect())
+		return NullValue, nil
+	}))
+	started := make(chan struct{})
+	rt.Environment().Set("ready", newBuiltin("ready", func(args []Value) (Value, error) {
+		close(started)
+		return NullValue, nil
+	}))
+	result := make(chan error, 1)
+	go func() {
+		_, err := rt.Run(program)
+		result <- err
+	}()
+	<-started
+	task := rt.HandleSignal(os.Interrupt)
+	if task == nil {
+		t.Fatalf("expected the registered handler to run")
+	}
+	if _, err := task.Join(); err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+	if rt.HandleSignal(syscall.SIGHUP) != nil {
+		t.Fatalf("expected no handler for an unregistered signal")
+	}
+	rt.Interrupt()
+	err := <-result
+	var cancelled *CancelledError
+	if !errors.As(err, &cancelled) || !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("expected an interrupted cancellation, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got := strings.Join(lines, ","); got != "handler int,finally,released" {
+		t.Fatalf("unexpected cleanup order %s", got)
+	}
+}
+
+func TestPathModuleAndTempHelpers(t *testing.T) {
+	lines := runRecording(t, `
+record(path.join("a", "b", "../c.txt"));
+record(path.dir("a/b/c.txt"), path.base("a/b/c.txt"), path.ext("a/b/c.txt"));
+record(path.rel("a/b", "a/c/d"));
+var kept = "";
+using dir = fs.tempDir("selene-test-") {
+    let file = path.join(dir.path, "notes.txt");
+    fs.write(file, "hi");
+    fs.write(path.join(dir.path, "other.md"), "");
+    record(path.glob(path.join(dir.path, "*.txt")).length, fs.exists(file));
+    kept = dir.path;
+}
+record(fs.exists(kept));
+`)
+	want := []string{"a/c.txt", "a/b c.txt .txt", "../c/d", "1 true", "false"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected output:\n%s", strings.Join(lines, "\n"))
+	}
+
+	rt := New()
+	rt.SetFileSystem(NewMemoryFileSystem(map[string]string{"src/a.selene": "", "src/b.selene": "", "README.md": ""}))
+	val, err := rt.Run(parseProgram(t, `path.glob("src/*.selene");`))
+	if err != nil || val.Inspect() != "[src/a.selene, src/b.selene]" {
+		t.Fatalf("unexpected glob over a memory filesystem: %v (%v)", val, err)
+	}
+	if _, err := rt.Run(parseProgram(t, `fs.tempFile();`)); err == nil || !strings.Contains(err.Error(), "requires the host filesystem") {
+		t.Fatalf("expected temp files to need the host filesystem, got %v", err)
+	}
+
+	rt = New()
+	val, err = rt.Run(parseProgram(t, `fs.tempFile("leaked-").path;`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	leaked := val.(*String).Value
+	leaks := rt.Shutdown(0)
+	if len(leaks) != 1 || leaks[0].Kind != "temp file" {
+		t.Fatalf("expected the unclosed temp file to be reported, got %v", leaks)
+	}
+	if _, err := os.Stat(leaked); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected Shutdown to remove %s, got %v", leaked, err)
+	}
+}
+
+func TestTasksModuleCombinators(t *testing.T) {
+	lines := runRecording(t, `
+fn slow(n: Number, ms: Number) { time.sleep(ms); return n; }
+fn boom(msg: String) { throw msg; }
+record(await tasks.all([spawn(slow, 1, 20), spawn(slow, 2, 1), 3]));
+record(await tasks.any([spawn(boom, "x"), spawn(slow, 7, 5)]));
+record(await tasks.within(spawn(slow, 4, 1), 1000));
+try { await tasks.within(spawn(slow, 9, 500), 10); } catch (err) { record(err); }
+try { await tasks.all([spawn(boom, "bad"), spawn(slow, 1, 500)]); } catch (err) { record(err); }
+try { await tasks.any([spawn(boom, "a"), spawn(boom, "b")]); } catch (err) { record(err); }
+`)
+	want := []string{
+		"[1, 2, 3]",
+		"7",
+		"4",
+		"<error task did not finish within 10ms>",
+		"<error bad>",
+		"<error all 2 tasks failed: a; b>",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected output:\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func TestTaskContinuations(t *testing.T) {
+	lines := runRecording(t, `
+fn slow(n: Number) { time.sleep(5); return n; }
+fn boom(msg: String) { throw msg; }
+fn double(n: Number) { return n * 2; }
+fn later(n: Number) { return spawn(slow, n + 1); }
+fn recover(err: Error) { return "recovered ${err}"; }
+fn cleanup() { record("cleanup"); }
+record(await spawn(slow, 1).then(double).then(later));
+record(await spawn(boom, "bad").then(double).catch(recover));
+record(await spawn(slow, 5).catch(recover).finally(cleanup));
+try { await spawn(boom, "again").finally(cleanup); } catch (err) { record(err); }
+`)
+	want := []string{"3", "recovered <error bad>", "cleanup", "5", "cleanup", "<error again>"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected output:\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func TestTaskContinuationsRunInAttachOrder(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		lines := runRecording(t, `
+fn slow() { time.sleep(1); return 0; }
+fn a(v: Number) { record("a"); }
+fn b(v: Number) { record("b"); }
+fn c(v: Number) { record("c"); }
+fn after(v: Number) { record("after"); }
+fn main() {
+    let base = spawn(slow);
+    let pending = [base.then(a), base.then(b), base.then(c)];
+    await tasks.all(pending);
+    await base.then(after);
+}
+`)
+		if got := strings.Join(lines, " "); got != "a b c after" {
+			t.Fatalf("continuations ran out of order: %s", got)
+		}
+	}
+}
+
+func TestPolicyScopesCapabilities(t *testing.T) {
+	t.Setenv("SELENE_POLICY_OK", "yes")
+	rt := New()
+	rt.SetFileSystem(NewMemoryFileSystem(map[string]string{"/data/in.txt": "hi", "/secret.txt": "no"}))
+	if err := rt.SetPolicy(Policy{
+		Builtins:  []string{"fs", "os.env"},
+		FileRoots: []string{"/data"},
+		Env:       []string{"SELENE_POLICY_OK"},
+	}); err != nil {
+		t.Fatalf("SetPolicy returned error: %v", err)
+	}
+	run := func(source string) (Value, error) {
+		return rt.Run(parseProgram(t, source))
+	}
+	if val, err := run(`fs.read("/data/in.txt") + os.env("SELENE_POLICY_OK");`); err != nil || val.Inspect() != "hiyes" {
+		t.Fatalf("expected granted capabilities to work, got %v (%v)", val, err)
+	}
+	if val, err := run(`Option.Some(Result.Ok(1));`); err != nil || val.Inspect() != "Option.Some(Result.Ok(1))" {
+		t.Fatalf("expected the prelude enums to survive the policy, got %v (%v)", val, err)
+	}
+	denied := map[string]string{
+		`fs.read("/data/../secret.txt");`: "fs.read may not access /data/../secret.txt",
+		`os.env("HOME");`:                 "may not access environment variable HOME",
+		`os.exec("true");`:                "",
+		`print("hi");`:                    "undefined identifier print",
+	}
+	for source, want := range denied {
+		if _, err := run(source); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected error containing %q, got %v", source, want, err)
+		}
+	}
+	if err := New().SetPolicy(Policy{Builtins: []string{"os.nope"}}); err == nil {
+		t.Fatalf("expected unknown builtin to be rejected")
+	}
+}
+
+func TestAuditLogRecordsHostCalls(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	rt := New()
+	rt.SetSandboxed(true)
+	rt.SetClock(NewFixedClock(start))
+	rt.SetFileSystem(NewMemoryFileSystem(map[string]string{"/in.txt": "hi"}))
+	var out bytes.Buffer
+	log := NewAuditLog(&out)
+	rt.SetAuditLog(log)
+	_, err := rt.Run(parseProgram(t, `
+let text = fs.read("/in.txt");
+try { os.exec("rm", ["-rf", "/"]); } catch (err) { print("refused"); }
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	entries := log.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %+v", entries)
+	}
+	read, exec := entries[0], entries[1]
+	if read.Op != "fs.read" || read.Site != "2:12" || !read.Time.Equal(start) || read.Error != "" || len(read.Args) != 1 || read.Args[0] != "/in.txt" {
+		t.Fatalf("unexpected fs.read entry: %+v", read)
+	}
+	if exec.Op != "os.exec" || exec.Site != "3:7" || len(exec.Args) != 2 || !strings.Contains(exec.Error, "sandbox") {
+		t.Fatalf("unexpected os.exec entry: %+v", exec)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 JSON lines, got %q", out.String())
+	}
+	var decoded AuditEntry
+	if err := json.Unmarshal([]byte(lines[1]), &decoded); err != nil || decoded.Op != "os.exec" || decoded.Error != exec.Error {
+		t.Fatalf("unexpected JSON entry %s (%v)", lines[1], err)
+	}
+	if err := log.Err(); err != nil {
+		t.Fatalf("unexpected write error: %v", err)
+	}
+}
+
+func TestPolicyStepBudgetCannotBeCaught(t *testing.T) {
+	rt := New()
+	if err := rt.SetPolicy(Policy{MaxSteps: 1000}); err != nil {
+		t.Fatalf("SetPolicy returned error: %v", err)
+	}
+	_, err := rt.Run(parseProgram(t, `
+while true {
+    try { let x = 1; } catch (err) { print("swallowed"); }
+}
+`))
+	var budget *BudgetError
+	if !errors.As(err, &budget) || budget.Resource != "step" {
+		t.Fatalf("expected a step budget error, got %v", err)
+	}
+}
+
+func TestPolicyTimeoutStopsLoopsAndBlockedReceives(t *testing.T) {
+	for _, src := range []string{
+		"while true { try { let x = 1; } catch (err) {} }",
+		"let ch = channel(); ch.recv();",
+	} {
+		rt := New()
+		if err := rt.SetPolicy(Policy{Timeout: 20 * time.Millisecond}); err != nil {
+			t.Fatalf("SetPolicy returned error: %v", err)
+		}
+		_, err := rt.Run(parseProgram(t, src))
+		var budget *BudgetError
+		if !errors.As(err, &budget) || budget.Resource != "time" || err.Error() != "execution cancelled: time budget of 20ms exceeded" {
+			t.Fatalf("%s: expected a time budget error, got %v", src, err)
+		}
+	}
+	if err := New().SetPolicy(Policy{Timeout: -time.Second}); err == nil {
+		t.Fatalf("expected a negative timeout to be rejected")
+	}
+}
+
+func TestPolicyHeapBudgetStopsDoublingStrings(t *testing.T) {
+	rt := New()
+	if err := rt.SetPolicy(Policy{MaxHeapBytes: 64 << 20}); err != nil {
+		t.Fatalf("SetPolicy returned error: %v", err)
+	}
+	_, err := rt.Run(parseProgram(t, `var s = "x"; while true { s += s; }`))
+	var budget *BudgetError
+	if !errors.As(err, &budget) || budget.Resource != "heap" {
+		t.Fatalf("expected a heap budget error, got %v", err)
+	}
+}
+
+func TestPolicyCapabilitiesRaisePermissionErrors(t *testing.T) {
+	rt := New()
+	rt.SetFileSystem(NewMemoryFileSystem(map[string]string{"/in.txt": "hi"}))
+	log := NewAuditLog(nil)
+	rt.SetAuditLog(log)
+	if err := rt.SetPolicy(Policy{Capabilities: map[Capability]bool{CapabilityFS: false, CapabilityEnv: true}}); err != nil {
+		t.Fatalf("SetPolicy returned error: %v", err)
+	}
+	val, err := rt.Run(parseProgram(t, `
+var result = "";
+try { fs.read("/in.txt"); } catch (e: PermissionError) { result = e.capability + ": " + e.message; }
+try { path.glob("*"); } catch (e: Error) { result = result + "; " + (e is PermissionError); }
+result;
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "fs: fs.read needs the fs capability, which this run denies; true"; val.Inspect() != want {
+		t.Fatalf("expected %q, got %q", want, val.Inspect())
+	}
+	if entries := log.Entries(); len(entries) != 2 || !strings.Contains(entries[0].Error, "PermissionError") {
+		t.Fatalf("expected refused calls to be audited, got %+v", entries)
+	}
+	if _, err := rt.Run(parseProgram(t, `os.env("HOME");`)); err != nil {
+		t.Fatalf("expected an enabled capability to work, got %v", err)
+	}
+	if _, err := rt.Run(parseProgram(t, `fs.exists("/in.txt");`)); err == nil || err.Error() != "PermissionError: fs.exists needs the fs capability, which this run denies" {
+		t.Fatalf("expected an uncaught PermissionError, got %v", err)
+	}
+	if err := New().SetPolicy(Policy{Capabilities: map[Capability]bool{"gpu": false}}); err == nil {
+		t.Fatalf("expected an unknown capability to be rejected")
+	}
+}
+
+func TestMaxCallDepthRaisesCatchableErrors(t *testing.T) {
+	rt := New()
+	rt.SetMaxCallDepth(200)
+	val, err := rt.Run(parseProgram(t, `
+fn down(n: Number): Number { return 1 + down(n - 1); }
+class Loop() {
+    get again(): Number => self.again;
+}
+var caught = "";
+try { down(0); } catch (e) { caught = e.message; }
+try { Loop().again; } catch (e) { caught = caught + "; " + e.message; }
+let task = spawn(down, 0);
+try { task.join(); } catch (e) { caught = caught + "; " + e.message; }
+fn count(n: Number): Number { if n == 0 { return 0; } return 1 + count(n - 1); }
+caught + "; " + count(150);
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "maximum recursion depth exceeded; maximum recursion depth exceeded; maximum recursion depth exceeded; 150"
+	if val.Inspect() != want {
+		t.Fatalf("expected %q, got %q", want, val.Inspect())
+	}
+	program := parseProgram(t, `fn down(n: Number): Number { return 1 + down(n - 1); } down(0);`)
+	chunk, err := rt.Compile(program)
+	if err != nil {
+		t.Fatalf("Compile returned error: %v", err)
+	}
+	if _, err := rt.RunChunk(chunk); err == nil || err.Error() != "maximum recursion depth exceeded" {
+		t.Fatalf("expected the VM to stop at the depth limit, got %v", err)
+	}
+}
+
+func TestSetContractsFalseSkipsClausesAndInvariants(t *testing.T) {
+	program := parseProgram(t, `
+contract Positive {
+    fn value(): Number;
+    invariant self.n > 0;
+}
+fn half(n: Number): Number
+    contract {
+        requires(n > 0);
+        ensures(result > 0);
+    }
+{
+    return n / 2;
+}
+class Counter(n: Number) : implements Positive {
+    invariant self.n >= 0;
+    fn value(): Number => self.n;
+    fn drop() { self.n = -1; }
+}
+let counter = Counter(-1);
+counter.drop();
+let results = [half(-4), counter.value()];
+`)
+	rt := New()
+	rt.SetContracts(false)
+	if _, err := rt.Run(program); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got, _ := rt.Environment().Get("results"); got == nil || got.Inspect() != "[-2, -1]" {
+		t.Fatalf("expected [-2, -1], got %v", got)
+	}
+	src := `class Empty() : implements Positive {} Empty();`
+	if _, err := rt.Run(parseProgram(t, src)); err == nil || !strings.Contains(err.Error(), "missing method value") {
+		t.Fatalf("expected required methods to be checked with contracts off, got %v", err)
+	}
+}
+
+func TestOptimizationLevelZeroSkipsConstantFolding(t *testing.T) {
+	rt := New()
+	if err := rt.SetOptimizationLevel(2); err == nil {
+		t.Fatalf("expected level 2 to be rejected")
+	}
+	for level, want := range map[int]string{0: "1 + 2", 1: "3"} {
+		if err := rt.SetOptimizationLevel(level); err != nil {
+			t.Fatalf("SetOptimizationLevel(%d): %v", level, err)
+		}
+		program := parseProgram(t, "let x = 1 + 2;")
+		if _, err := rt.Compile(program); err != nil {
+			t.Fatalf("compile at level %d: %v", level, err)
+		}
+		if got := printer.Node(program.Items[0]); !strings.Contains(got, want) {
+			t.Fatalf("level %d: expected the compiled program to contain %q, got %q", level, want, got)
+		}
+	}
+}
+
+func TestRunModuleOrdersFilesByImportsAndRunsInit(t *testing.T) {
+	files := []ModuleFile{
+		{Name: "a_app.selene", Program: parseProgram(t, `
+import shapes.area;
+fn init() { trace = trace + "init a;"; }
+let total = area(2);
+trace = trace + "top a;";
+`)},
+		{Name: "c_log.selene", Program: parseProgram(t, `
+var trace = "";
+fn init() { trace = trace + "init c;"; }
+`)},
+		{Name: "b_shapes.selene", Program: parseProgram(t, `
+import log;
+module shapes {
+    fn area(side: Number): Number => side * side;
+}
+`)},
+		{Name: "d_log.selene", Program: parseProgram(t, `module log { let level = "info"; }`)},
+	}
+	order, err := InitOrder(files)
+	if err != nil {
+		t.Fatalf("InitOrder returned error: %v", err)
+	}
+	var names []string
+	for _, file := range order {
+		names = append(names, file.Name)
+	}
+	if got := strings.Join(names, ","); got != "c_log.selene,d_log.selene,b_shapes.selene,a_app.selene" {
+		t.Fatalf("unexpected init order %s", got)
+	}
+	rt := New()
+	if err := rt.RunModule(files); err != nil {
+		t.Fatalf("RunModule returned error: %v", err)
+	}
+	env := rt.Environment()
+	if trace, _ := env.Get("trace"); trace == nil || trace.Inspect() != "top a;init c;init a;" {
+		t.Fatalf("unexpected trace %v", trace)
+	}
+	if _, ok := env.Get("init"); ok {
+		t.Fatalf("expected init to be removed from the module environment")
+	}
+
+	cycle := []ModuleFile{
+		{Name: "x.selene", Program: parseProgram(t, "import y;\nmodule x { }\n")},
+		{Name: "y.selene", Program: parseProgram(t, "import x;\nmodule y { }\n")},
+	}
+	if _, err := InitOrder(cycle); err == nil || !strings.Contains(err.Error(), "x.selene -> y.selene -> x.selene") {
+		t.Fatalf("expected import cycle error, got %v", err)
+	}
+}
+
+func TestRunInvokesInitBeforeMain(t *testing.T) {
+	rt := New()
+	val, err := rt.Run(parseProgram(t, `
+var trace = "top;";
+fn init() { trace = trace + "init;"; }
+fn main(): String { return trace + "main;"; }
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if val.Inspect() != "top;init;main;" {
+		t.Fatalf("expected init to run after the top level and before main, got %s", val.Inspect())
+	}
+	rt = New()
+	val, err = rt.Run(parseProgram(t, `
+var calls = 0;
+fn init() { calls = calls + 1; }
+init();
+calls;
+`))
+	if err != nil || val.Inspect() != "1" {
+		t.Fatalf("expected an explicit init() call to suppress the implicit one, got %v (%v)", val, err)
+	}
+}
+
+func TestMainReturnsExitCodeAndReceivesArgs(t *testing.T) {
+	rt := New()
+	rt.SetArgs([]string{"alpha", "beta"})
+	_, err := rt.Run(parseProgram(t, `
+fn main(args: Array): Number {
+    return args.length + 1;
+}
+`))
+	var exit *ExitError
+	if !errors.As(err, &exit) || exit.Code != 3 {
+		t.Fatalf("expected exit code 3 from main, got %v", err)
+	}
+	val, err := New().Run(parseProgram(t, `fn main(): Number { return 0; }`))
+	if err != nil || val.Inspect() != "0" {
+		t.Fatalf("expected a zero exit code to finish normally, got %v (%v)", val, err)
+	}
+	if _, err := New().Run(parseProgram(t, `fn main(): Number { return 1.5; }`)); err == nil || !strings.Contains(err.Error(), "exit codes must be integers") {
+		t.Fatalf("expected a fractional exit code to be rejected, got %v", err)
+	}
+	for _, code := range []string{"300", "-1", "100000000000000000000"} {
+		_, err := New().Run(parseProgram(t, `fn main(): Number { return `+code+`; }`))
+		if err == nil || !strings.Contains(err.Error(), "between 0 and 255") || errors.As(err, &exit) {
+			t.Fatalf("expected exit code %s to be rejected, got %v", code, err)
+		}
+	}
+}
+
+func TestUncaughtErrorsCarryStackTrace(t *testing.T) {
+	rt := New()
+	val, err := rt.Run(parseProgram(t, `
+fn inner(x: Number) {
+    throw "boom ${x}";
+}
+fn outer() {
+    inner(2);
+}
+fn main() {
+    try { outer(); } catch (e) { }
+    outer();
+}
+`))
+	if err == nil || err.Error() != "boom 2" {
+		t.Fatalf("expected the thrown message unchanged, got %v (%v)", err, val)
+	}
+	frames := StackTrace(err)
+	if len(frames) != 3 || frames[0].Function != "inner" || frames[0].Site.Line != 6 || frames[1].Function != "outer" || frames[2].Function != "main" {
+		t.Fatalf("unexpected stack trace %v", frames)
+	}
+	want := "boom 2\n    at inner (called from 6:5)\n    at outer (called from 10:5)\n    at main"
+	if got := FormatError(err); got != want {
+		t.Fatalf("unexpected formatted error:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSlotResolvedLocalsKeepScopingRules(t *testing.T) {
+	const source = `
+let x = "global";
+fn later() { return laterGlobal; }
+fn scopes(n: Number) {
+    record(x);
+    let x = "local";
+    {
+        record(x);
+        let x = "block";
+        record(x);
+    }
+    record(x);
+    let total = 0;
+    for (let i = 0; i < n; i += 1) {
+        let i2 = i * 2;
+        total += i2;
+    }
+    for (item in [1, 2]) { total = total + item; }
+    let p = &total;
+    *p = *p + 100;
+    try { throw "boom"; } catch (e) { record(e); }
+    fn counter() {
+        total += 1;
+        return total;
+    }
+    counter();
+    record(counter(), total, n);
+}
+let laterGlobal = "declared later";
+fn main() {
+    scopes(3);
+    scopes(0);
+    record(later());
+}
+`
+	for _, item := range parseProgram(t, source).Items {
+		if fn, ok := item.(*ast.FunctionDeclaration); ok && resolveFrame(fn) == nil {
+			t.Fatalf("expected %s to resolve to slots", fn.Name.Name)
+		}
+	}
+	lines := runRecording(t, source)
+	want := []string{
+		"global", "local", "block", "local", "<error boom>", "111 111 3",
+		"global", "local", "block", "local", "<error boom>", "105 105 0",
+		"declared later",
+	}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected output %q", lines)
+	}
+}
+
+func TestSmallValuesAndLiteralsAreShared(t *testing.T) {
+	if NewNumber(42) != NewNumber(42) || NewString("a") != NewString("a") || NewString("") != NewString("") {
+		t.Fatalf("expected small integers and one-byte strings to be shared")
+	}
+	if NewNumber(0.5) == NewNumber(0.5) || NewNumber(5000) == NewNumber(5000) {
+		t.Fatalf("expected other numbers to be allocated")
+	}
+	if negZero := NewNumber(math.Copysign(0, -1)); negZero.Inspect() != "-0" {
+		t.Fatalf("expected negative zero to keep its sign, got %s", negZero.Inspect())
+	}
+	lines := runRecording(t, `
+fn label() { return "repeated label"; }
+fn same() { return "repeated label"; }
+record(label() == same(), 1.5 + 1.5, "tab\there", "n=${1 + 1}");
+`)
+	if strings.Join(lines, "|") != "true 3 tab\there n=2" {
+		t.Fatalf("unexpected output %q", lines)
+	}
+	rt := New()
+	program := parseProgram(t, `fn label() { return "repeated label"; } fn same() { return "repeated label"; }`)
+	if _, err := rt.Run(program); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	label, _ := rt.Environment().Get("label")
+	same, _ := rt.Environment().Get("same")
+	a, errA := applyFunction(label, nil)
+	b, errB := applyFunction(same, nil)
+	if errA != nil || errB != nil || a != b {
+		t.Fatalf("expected equal string literals to share one value, got %p and %p", a, b)
+	}
+}
+
+func TestSpawnedTasksShareEnvironmentsSafely(t *testing.T) {
+	lines := runRecording(t, `
+let log = [];
+fn worker(id: Number, done: Channel) {
+    let local = 0;
+    for (let i = 0; i < 500; i += 1) { local += 1; }
+    done.send(local + id);
+}
+let done = channel(4);
+for (id in [1, 2, 3, 4]) { spawn(worker, id, done); }
+let total = 0;
+for (id in [1, 2, 3, 4]) { total += await done; }
+record(total);
+`)
+	if strings.Join(lines, "|") != "2010" {
+		t.Fatalf("unexpected output %q", lines)
+	}
+}
+
+func TestRaceCheckReportsUnorderedAssignments(t *testing.T) {
+	run := func(source string) []Race {
+		t.Helper()
+		rt := New()
+		rt.SetRaceCheck(true)
+		if _, err := rt.Run(parseProgram(t, source)); err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		rt.Shutdown(time.Second)
+		return rt.Races()
+	}
+	races := run(`
+let shared = 0;
+let gate = channel();
+fn writer(ch: Channel) {
+    shared = 1;
+    await ch;
+}
+let task = spawn(writer, gate);
+while (shared == 0) {}
+shared = 2;
+gate.send(null);
+await task;
+`)
+	if len(races) != 1 || races[0].Name != "shared" || races[0].First.Line != 5 || races[0].Second.Line != 10 {
+		t.Fatalf("expected one race on shared, got %v", races)
+	}
+	races = run(`
+let shared = 0;
+fn writer() { shared += 1; }
+shared = 1;
+await spawn(writer);
+shared = 3;
+let second = spawn(writer);
+await second;
+`)
+	if len(races) != 0 {
+		t.Fatalf("expected assignments ordered by spawn and await to pass, got %v", races)
+	}
+}
+
+func TestScriptPreludeDefinesArgsAndExit(t *testing.T) {
+	rt := New()
+	rt.SetArgs([]string{"alpha", "beta"})
+	rt.UseScriptPrelude()
+	_, err := rt.Run(parseProgram(t, `exit(args.length + 1);`))
+	var exit *ExitError
+	if !errors.As(err, &exit) || exit.Code != 3 {
+		t.Fatalf("expected exit status 3, got %v", err)
+	}
+	rt = New()
+	rt.UseScriptPrelude()
+	val, err := rt.Run(parseProgram(t, "let args = 5;\nargs;"))
+	if err != nil || val.Inspect() != "5" {
+		t.Fatalf("expected a script's own binding to shadow the prelude, got %v (%v)", val, err)
+	}
+}
+
+func TestDecodedChunksAreVerifiedBeforeRunning(t *testing.T) {
+	compile := func(rt *Runtime, source string) *Chunk {
+		t.Helper()
+		chunk, err := rt.Compile(parseProgram(t, source))
+		if err != nil {
+			t.Fatalf("Compile returned error: %v", err)
+		}
+		data, err := chunk.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary returned error: %v", err)
+		}
+		decoded := &Chunk{}
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("UnmarshalBinary returned error: %v", err)
+		}
+		return decoded
+	}
+	rt := New()
+	chunk := compile(rt, "let a = 1;\nlet b = a + 1;\nb;")
+	if err := rt.VerifyChunk(chunk); err != nil {
+		t.Fatalf("expected a compiled chunk to verify, got %v", err)
+	}
+	for name, code := range map[string][]byte{
+		"unknown opcode":     {9},
+		"truncated operand":  {byte(OpEvalItem), 0},
+		"index out of range": {byte(OpEvalItem), 0, 0, byte(OpEvalItem), 0, 1, byte(OpEvalItem), 0, 7, byte(OpReturn)},
+		"missing return":     {byte(OpEvalItem), 0, 0, byte(OpEvalItem), 0, 1, byte(OpEvalItem), 0, 2},
+		"early return":       {byte(OpEvalItem), 0, 0, byte(OpReturn), byte(OpEvalItem), 0, 1, byte(OpEvalItem), 0, 2, byte(OpReturn)},
+		"skipped item":       {byte(OpEvalItem), 0, 0, byte(OpEvalItem), 0, 2, byte(OpReturn)},
+	} {
+		tampered := &Chunk{code: code, items: chunk.items, decoded: true}
+		var verr *VerifyError
+		if _, err := rt.RunChunk(tampered); !errors.As(err, &verr) {
+			t.Fatalf("%s: expected a verify error, got %v", name, err)
+		}
+	}
+
+	sandboxed := New()
+	sandboxed.SetSandboxed(true)
+	var printed []string
+	sandboxed.Environment().Set("print", newBuiltin("print", func(args []Value) (Value, error) {
+		printed = append(printed, args[0].Inspect())
+		return NullValue, nil
+	}))
+	source := "print(\"before\");\nfn run() { os.exec(\"sh\", [\"-c\", \"true\"]); }\n"
+	_, err := sandboxed.RunChunk(compile(sandboxed, source))
+	if err == nil || !strings.Contains(err.Error(), "os.exec is not available") || len(printed) != 0 {
+		t.Fatalf("expected the sandbox to refuse the chunk before it ran, got %v (printed %v)", err, printed)
+	}
+
+	restricted := New()
+	if err := restricted.SetPolicy(Policy{Builtins: []string{"os.env"}}); err != nil {
+		t.Fatalf("SetPolicy returned error: %v", err)
+	}
+	if err := restricted.VerifyChunk(compile(restricted, `os.setenv("A", "b");`)); err == nil || !strings.Contains(err.Error(), "os.setenv") {
+		t.Fatalf("expected the policy to forbid os.setenv, got %v", err)
+	}
+	if err := restricted.VerifyChunk(compile(restricted, `os.env("HOME");`)); err != nil {
+		t.Fatalf("expected os.env to stay allowed, got %v", err)
+	}
+}
+
+func TestSetsDeduplicateAndCombine(t *testing.T) {
+	rt := New()
+	val, err := rt.Run(parseProgram(t, `
+let seen = #{1, 2, 2, "a", -0, 0};
+seen.add(3, 1);
+let removed = seen.remove(2);
+let again = seen.remove(2);
+let other = set([3, 4, "a"]);
+var count = 0;
+for (x in seen) { count = count + 1; }
+var empty = "truthy";
+if (!#{}) { empty = "falsy"; }
+[seen, seen.size, removed, again, seen.has("a"), seen.has(2),
+ seen.union(other), seen.intersection(other), seen.difference(other), count, empty];
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `[#{1, a, -0, 3}, 4, true, false, true, false, #{1, a, -0, 3, 4}, #{a, 3}, #{1, -0}, 4, falsy]`
+	if val.Inspect() != want {
+		t.Fatalf("unexpected result %s, want %s", val.Inspect(), want)
+	}
+
+	big := NewSet()
+	for i := 0; i < 100; i++ {
+		big.Add(NewNumber(float64(i)))
+	}
+	for i := 0; i < 100; i += 2 {
+		big.Remove(NewNumber(float64(i)))
+	}
+	if big.Len() != 50 || !big.Has(NewNumber(99)) || big.Has(NewNumber(98)) || big.Elements()[0].Inspect() != "1" {
+		t.Fatalf("unexpected set after removals: %s", big.Inspect())
+	}
+	if NewSet(NewNumber(math.NaN()), NewNumber(math.NaN())).Len() != 1 {
+		t.Fatalf("expected NaN to be a single member")
+	}
+}
+
+func TestFreezeMakesCollectionsDeeplyImmutable(t *testing.T) {
+	val, err := New().Run(parseProgram(t, `
+var source = {name: "svc", ports: [80, 443], tags: #{"a"}};
+let config = freeze(source);
+source.name = "changed";
+var failures = "";
+var alias = config;
+try { alias.name = "x"; } catch (e) { failures = failures + e.message + "; "; }
+var ports = config.ports;
+try { ports[0] = 1; } catch (e) { failures = failures + e.message + "; "; }
+try { ports[1] += 1; } catch (e) { failures = failures + e.message + "; "; }
+try { config.tags.add("b"); } catch (e) { failures = failures + e.message + "; "; }
+try { config.tags.remove("a"); } catch (e) { failures = failures + e.message; }
+[config, isFrozen(config), isFrozen(config.ports), isFrozen(config.tags), isFrozen(source), isFrozen(1),
+ isFrozen(freeze(config)), freeze("text"), failures]
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "[{name: svc, ports: [80, 443], tags: #{a}}, true, true, true, false, false, true, text, " +
+		"cannot assign to property name of a frozen Object; cannot assign to an element of a frozen Array; " +
+		"cannot assign to an element of a frozen Array; cannot add to a frozen Set; cannot remove from a frozen Set]"
+	if val.Inspect() != want {
+		t.Fatalf("unexpected result %s, want %s", val.Inspect(), want)
+	}
+}
+
+func TestFreezeMakesInstancesImmutable(t *testing.T) {
+	val, err := New().Run(parseProgram(t, `
+struct Pair(left: Array, right: Number) {}
+class Counter(count: Number, seen: Array) {
+    fn bump() { self.count = self.count + 1; }
+}
+let counter = Counter(1, [1]);
+let frozen = freeze(counter);
+counter.bump();
+var failures = "";
+try { frozen.count = 2; } catch (e) { failures = failures + e.message + "; "; }
+try { frozen.bump(); } catch (e) { failures = failures + e.message + "; "; }
+try { frozen.seen[0] = 2; } catch (e) { failures = failures + e.message + "; "; }
+let pair = freeze(Pair([1], 2));
+try { pair.left[0] = 2; } catch (e) { failures = failures + e.message; }
+[frozen.count, counter.count, isFrozen(frozen), isFrozen(counter), isFrozen(pair), failures]
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "[1, 2, true, false, true, cannot assign to property count of a frozen Counter; " +
+		"cannot assign to property count of a frozen Counter; cannot assign to an element of a frozen Array; " +
+		"cannot assign to an element of a frozen Array]"
+	if val.Inspect() != want {
+		t.Fatalf("unexpected result %s, want %s", val.Inspect(), want)
+	}
+}
+
+func TestRegisteredModulesAreBoundInNewRuntimes(t *testing.T) {
+	err := RegisterModule("greeter", func(r *Runtime) *Module {
+		return NewModule("greeter", map[string]Value{
+			"hello": newBuiltin("hello", func(args []Value) (Value, error) {
+				return NewString("hello, " + args[0].Inspect()), nil
+			}),
+		})
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := RegisterModule("greeter", func(r *Runtime) *Module { return nil }); err == nil {
+		t.Fatalf("expected a second registration of greeter to fail")
+	}
+	if err := RegisterModule("os", func(r *Runtime) *Module { return nil }); err == nil {
+		t.Fatalf("expected registering over a standard module to fail")
+	}
+	if err := RegisterModule("not-a-name", func(r *Runtime) *Module { return nil }); err == nil {
+		t.Fatalf("expected a non-identifier module name to fail")
+	}
+
+	val, err := New().Run(parseProgram(t, `greeter.hello("selene");`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if val.Inspect() != "hello, selene" {
+		t.Fatalf("unexpected result %s", val.Inspect())
+	}
+}
+
+func TestArrayPatternsMatchAndDestructure(t *testing.T) {
+	rt := New()
+	val, err := rt.Run(parseProgram(t, `
+fn shape(items: Array) {
+    match items {
+        [] => return "empty";
+        [only] => return "one " + only;
+        [a, [b, c], ...] => return "nested " + (a + b + c);
+        [head, ...tail] => return "head " + head + " tail " + tail.length;
+    }
+}
+let [first, second, ...rest] = [1, 2, 3, 4];
+var [x, y] = ["a", "b"];
+[shape([]), shape([7]), shape([1, [2, 3], 4]), shape([1, 2, 3]), shape("no"), first, second, rest, x + y];
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `[empty, one 7, nested 6, head 1 tail 2, null, 1, 2, [3, 4], ab]`
+	if val.Inspect() != want {
+		t.Fatalf("unexpected result %s, want %s", val.Inspect(), want)
+	}
+
+	_, err = rt.Run(parseProgram(t, `let [p, q] = [1, 2, 3];`))
+	if err == nil || !strings.Contains(err.Error(), "cannot destructure [1, 2, 3] with pattern [p, q]") {
+		t.Fatalf("expected a destructuring error, got %v", err)
+	}
+	if _, ok := rt.Environment().Get("p"); ok {
+		t.Fatalf("a failed destructuring must not bind any names")
+	}
+}
+
+func TestImplBlocksRegisterInterfaceMethods(t *testing.T) {
+	rt := New()
+	val, err := rt.Run(parseProgram(t, `
+interface Shape {
+    fn area(): Number;
+    fn describe(): String => this.name() + " of area " + this.area();
+    fn name(): String => "shape";
+}
+struct Square(side: Number) {}
+class Circle(r: Number) {
+    fn name(): String => "circle";
+}
+class Ring(r: Number): Circle {}
+enum Tile {
+    Empty;
+    Full(size: Number);
+}
+impl Shape for Square {
+    fn area(): Number => this.side * this.side;
+    fn name(): String => "square";
+}
+impl Shape for Circle {
+    fn area(): Number => 3 * this.r * this.r;
+}
+impl Shape for Tile {
+    fn area(): Number {
+        match this {
+            Full(size) => return size;
+            other => return 0;
+        }
+    }
+}
+impl Shape for String {
+    fn area(): Number => this.length;
+}
+struct Blob(area: Number) {}
+[Square(2).describe(), Circle(1).describe(), Ring(2).describe(), Tile.Full(5).describe(), "abc".describe(),
+ Square(1) is Shape, Ring(1) is Shape, Tile.Empty() is Shape, "x" is Shape, 5 is Shape, Blob(1) is Shape];
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `[square of area 4, circle of area 3, circle of area 12, shape of area 5, shape of area 3, true, true, true, true, false, false]`
+	if val.Inspect() != want {
+		t.Fatalf("unexpected result %s, want %s", val.Inspect(), want)
+	}
+
+	rejected := map[string]string{
+		`struct A(x: Number) {} impl Shape for A { fn name(): String => "a"; }`:                        "impl Shape for A is missing method area",
+		`struct B(x: Number) {} impl Shape for B { fn area(): Number => 1; fn size() => 1; }`:          "size is not a method of interface Shape",
+		`struct C(x: Number) {} impl Shape for C { fn area(scale: Number): Number => scale; }`:         "C.area takes 1 parameters, but interface Shape declares 0",
+		`impl Shape for Square { fn area(): Number => 0; }`:                                            "Square already implements Shape",
+		`struct D(x: Number) { fn area(): Number => 1; } impl Shape for D { fn area(): Number => 2; }`: "D already defines method area",
+		`impl Shape for Nothing {}`: "unknown type Nothing",
+		`impl Square for Circle {}`: "Square is not an interface",
+		`impl Shape for Result {}`:  "cannot implement interfaces for the prelude enum Result",
+	}
+	for source, want := range rejected {
+		if _, err := rt.Run(parseProgram(t, source)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected error containing %q, got %v", source, want, err)
+		}
+	}
+	if val, err := rt.Run(parseProgram(t, `D(1).area();`)); err != nil || val.Inspect() != "1" {
+		t.Fatalf("expected a rejected impl to leave the type unchanged, got %v (%v)", val, err)
+	}
+}
+
+func TestReflectReadsAnnotations(t *testing.T) {
+	rt := New()
+	val, err := rt.Run(parseProgram(t, `
+let prefix = "/api";
+@route(prefix + "/users", "GET")
+fn users() => "users";
+@deprecated
+class Legacy() {
+    @test
+    fn works() => true;
+}
+@route(prefix + "/health", "GET")
+@test
+fn health() => "ok";
+var routes = "";
+for (handler in reflect.annotated("route")) {
+    routes += reflect.annotation(handler, "route").args[0] + " " + handler() + ";";
+}
+[routes, reflect.annotations(Legacy), reflect.annotations(Legacy().works), reflect.annotation(users, "test"),
+ reflect.annotated("test").length, reflect.annotations(prefix)];
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `[/api/users users;/api/health ok;, [{name: deprecated, args: []}], [{name: test, args: []}], null, 2, []]`
+	if val.Inspect() != want {
+		t.Fatalf("unexpected result %s, want %s", val.Inspect(), want)
+	}
+
+	_, err = rt.Run(parseProgram(t, `@route(missing) fn lost() {}`))
+	if err == nil || !strings.Contains(err.Error(), "annotation @route: undefined identifier missing") {
+		t.Fatalf("expected an annotation argument error, got %v", err)
+	}
+}
+
+func TestReflectInspectsValues(t *testing.T) {
+	rt := New()
+	val, err := rt.Run(parseProgram(t, `
+struct Point(x: Number, y: Number) {
+    fn norm() => this.x * this.x + this.y * this.y;
+}
+class Animal(name: String) {
+    fn speak() => "...";
+}
+class Dog(name: String): Animal {
+    fn fetch() => "ball";
+}
+enum Shape { Circle(r: Number); }
+let dog = reflect.construct(Dog, ["rex"]);
+[reflect.typeOf(Point(1, 2)), reflect.typeOf(Point), reflect.typeOf("s"), reflect.fields(Point(3, 4)),
+ reflect.fields(Shape.Circle(2)), reflect.methods(Dog), reflect.methods(Point(0, 0)), dog.name + " " + dog.speak(),
+ reflect.has(dog, "fetch"), reflect.has(dog, "fly"), reflect.has("abc", "length")];
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `[Point, Struct, String, [{name: x, value: 3}, {name: y, value: 4}], [{name: r, value: 2}], [fetch, speak], [norm], rex ..., true, false, true]`
+	if val.Inspect() != want {
+		t.Fatalf("unexpected result %s, want %s", val.Inspect(), want)
+	}
+
+	rejected := map[string]string{
+		`reflect.fields(1);`:              "reflect.fields expects a struct, class, or enum instance, got Number",
+		`reflect.methods("x");`:           "reflect.methods expects a struct, class, or enum type or instance, got String",
+		`reflect.construct(Shape, []);`:   "reflect.construct expects a struct or class type",
+		`reflect.construct(Point, [1]);`:  "expected 2 arguments to Point, got 1",
+		`reflect.construct(Point, 1, 2);`: "reflect.construct expects a type and an Array of arguments",
+	}
+	for source, want := range rejected {
+		if _, err := rt.Run(parseProgram(t, source)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected error containing %q, got %v", source, want, err)
+		}
+	}
+}
+
+func TestPropagateReturnsErrAndNoneEarly(t *testing.T) {
+	rt := New()
+	val, err := rt.Run(parseProgram(t, `
+fn parsePort(text: String) {
+    if (text == "") {
+        return Result.Err("empty");
+    }
+    return Result.Ok(text.length * 1000);
+}
+fn describe(text: String) {
+    let port = parsePort(text)?;
+    print("unreachable for empty input");
+    return Result.Ok(port + 1);
+}
+fn first(items: Array) {
+    if (items.length == 0) {
+        return Option.None();
+    }
+    return Option.Some(items[0]);
+}
+fn firstTwice(items: Array) {
+    return Option.Some([first(items)?, first(items)?]);
+}
+fn label(result: Result) {
+    match result {
+        Ok(v) => return "ok " + v;
+        Err(e) => return "err " + e;
+    }
+}
+[label(describe("ab")), label(describe("")), firstTwice([7]), firstTwice([])];
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `[ok 2001, err empty, Option.Some([7, 7]), Option.None]`
+	if val.Inspect() != want {
+		t.Fatalf("unexpected result %s, want %s", val.Inspect(), want)
+	}
+
+	_, err = rt.Run(parseProgram(t, `parsePort("x")? + 1;`))
+	if err != nil {
+		t.Fatalf("unexpected error unwrapping Ok at top level: %v", err)
+	}
+	_, err = rt.Run(parseProgram(t, `parsePort("")?;`))
+	if err == nil || !strings.Contains(err.Error(), "Result.Err(empty) propagated by ? outside of function") {
+		t.Fatalf("expected a top-level propagation error, got %v", err)
+	}
+	_, err = rt.Run(parseProgram(t, `fn f() { return 5?; } f();`))
+	if err == nil || !strings.Contains(err.Error(), "? expects a Result or Option, got 5") {
+		t.Fatalf("expected a type error, got %v", err)
+	}
+}
+
+func TestStrictMathRaisesCatchableErrors(t *testing.T) {
+	source := `
+let big = 9007199254740991;
+let huge = big;
+for (let i = 0; i < 20; i = i + 1) { huge = huge * big; }
+record(huge, isFinite(huge), isNaN(huge - huge));
+record(checkedAdd(big, 1), checkedMul(2, 3), checkedDiv(1, 0));
+record(saturatingAdd(big, 10), saturatingSub(-big, 10), saturatingMul(1.5, huge));
+`
+	lines := runRecording(t, source)
+	want := []string{
+		"+Inf false true",
+		"Result.Err(<error 9007199254740991 + 1 overflows the safe integer range>) Result.Ok(6) Result.Err(<error division by zero>)",
+		"9007199254740991 -9007199254740991 " + NewNumber(math.MaxFloat64).Inspect(),
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected output:\n%s", strings.Join(lines, "\n"))
+	}
+
+	rt := New()
+	rt.SetStrictMath(true)
+	var caught []string
+	rt.Environment().Set("record", newBuiltin("record", func(args []Value) (Value, error) {
+		caught = append(caught, args[0].Inspect())
+		return NullValue, nil
+	}))
+	_, err := rt.Run(parseProgram(t, `
+let big = 9007199254740991;
+let huge = big;
+try {
+    for (let i = 0; i < 20; i = i + 1) { huge *= big; }
+} catch (err) {
+    record(err);
+}
+record(huge * 0);
+huge - huge * big;
+`))
+	if len(caught) != 2 || !strings.HasPrefix(caught[0], "<error strict math: ") || !strings.HasSuffix(caught[0], " * 9007199254740991 produced +Inf>") || caught[1] != "0" {
+		t.Fatalf("expected the overflow to be caught, got %q", caught)
+	}
+	if err == nil || !strings.Contains(err.Error(), "produced +Inf") {
+		t.Fatalf("expected an uncaught strict math error, got %v", err)
+	}
+}
+
+func TestMathModule(t *testing.T) {
+	source := `
+record(math.sqrt(16), math.pow(2, 10), math.abs(-3), math.floor(2.7), math.ceil(2.1), math.round(2.5));
+record(math.min(3, 1, 2), math.max(3, 1, 2), math.clamp(12, 0, 10), math.clamp(-1, 0, 10));
+record(math.log(math.e), math.exp(0), math.sin(0), math.cos(0), math.tan(0), math.pi > 3.14);
+let r = math.random();
+record(r >= 0 && r < 1, math.sqrt(-1));
+`
+	want := "4 1024 3 2 3 3\n1 3 10 0\n1 1 0 1 0 true\ntrue NaN"
+	if got := strings.Join(runRecording(t, source), "\n"); got != want {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+
+	rt := New()
+	var lines []string
+	rt.Environment().Set("record", newBuiltin("record", func(args []Value) (Value, error) {
+		parts := make([]string, len(args))
+		for i, arg := range args {
+			parts[i] = arg.Inspect()
+		}
+		lines = append(lines, strings.Join(parts, " "))
+		return NullValue, nil
+	}))
+	chunk, err := rt.Compile(parseProgram(t, source))
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if _, err := rt.RunChunk(chunk); err != nil {
+		t.Fatalf("RunChunk failed: %v", err)
+	}
+	if got := strings.Join(lines, "\n"); got != want {
+		t.Fatalf("expected the VM to agree with the interpreter, got:\n%s", got)
+	}
+
+	strict := New()
+	strict.SetStrictMath(true)
+	if _, err := strict.Run(parseProgram(t, `math.log(0);`)); err == nil || !strings.Contains(err.Error(), "math.log(0) produced -Inf") {
+		t.Fatalf("expected strict math to reject math.log(0), got %v", err)
+	}
+	if _, err := strict.Run(parseProgram(t, `math.clamp(1, 5, 0);`)); err == nil || !strings.Contains(err.Error(), "lower bound 5 is above upper bound 0") {
+		t.Fatalf("expected inverted clamp bounds to be rejected, got %v", err)
+	}
+}
+
+func TestRandModuleIsReproducibleAfterSeed(t *testing.T) {
+	source := `
+rand.seed(42);
+record(rand.int(1, 6), rand.int(1, 6), rand.int(1, 6), rand.float(), math.random());
+record(rand.choice(["a", "b", "c"]), rand.shuffle([1, 2, 3, 4, 5]));
+let original = [1, 2, 3];
+let shuffled = rand.shuffle(original);
+record(original, rand.int(7, 7));
+`
+	first := runRecording(t, source)
+	second := runRecording(t, source)
+	if strings.Join(first, "\n") != strings.Join(second, "\n") {
+		t.Fatalf("expected seeded runs to agree:\n%s\n---\n%s", strings.Join(first, "\n"), strings.Join(second, "\n"))
+	}
+	if len(first) != 3 || first[2] != "[1, 2, 3] 7" {
+		t.Fatalf("expected shuffle to leave its argument alone, got %q", first)
+	}
+	for _, roll := range strings.Fields(first[0])[:3] {
+		if roll < "1" || roll > "6" || len(roll) != 1 {
+			t.Fatalf("expected rolls between 1 and 6, got %q", first[0])
+		}
+	}
+
+	for source, want := range map[string]string{
+		`rand.int(6, 1);`:     "min 6 is above max 1",
+		`rand.int(1.5, 2);`:   "rand.int expects an integer, got 1.5",
+		`rand.choice([]);`:    "rand.choice of an empty Array",
+		`rand.shuffle("ab");`: "rand.shuffle expects an Array, got String",
+	} {
+		if _, err := New().Run(parseProgram(t, source)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %q, got %v", source, want, err)
+		}
+	}
+}
+
+func TestBytesValues(t *testing.T) {
+	program := parseProgram(t, `
+let header = bytes([137, 80, 78, 71]);
+let body = bytes("héllo");
+let data = header + body;
+record(data.length, data[0], data[4], data.slice(1, 4).toString(), data.slice(8).hex());
+record(body, bytes([0, 255]), bytes("hi") == bytes([104, 105]), #{bytes("a"), bytes("a")}.size);
+let sum = 0;
+for (let b in header) { sum = sum + b; }
+record(sum, body.toString(), header.toArray(), bytes().length, bytes(header) == header);
+fs.write("image.bin", data);
+record(fs.readBytes("image.bin") == data, fs.read("input.txt"));
+`)
+	rt := New()
+	vfs := NewMemoryFileSystem(map[string]string{"input.txt": "text"})
+	rt.SetFileSystem(vfs)
+	var lines []string
+	rt.Environment().Set("record", newBuiltin("record", func(args []Value) (Value, error) {
+		parts := make([]string, len(args))
+		for i, arg := range args {
+			parts[i] = arg.Inspect()
+		}
+		lines = append(lines, strings.Join(parts, " "))
+		return NullValue, nil
+	}))
+	if _, err := rt.Run(program); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	want := []string{
+		"10 137 104 PNG 6c6f",
+		`b"héllo" b"\x00\xff" true 1`,
+		"366 héllo [137, 80, 78, 71] 0 true",
+		"true text",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected output:\n%s", strings.Join(lines, "\n"))
+	}
+	if written, _ := vfs.ReadFile("image.bin"); string(written) != "\x89PNGh\xc3\xa9llo" {
+		t.Fatalf("expected fs.write to store the raw bytes, got %q", written)
+	}
+
+	for source, want := range map[string]string{
+		`bytes([256]);`:            "element 0 is 256, not a Number from 0 to 255",
+		`bytes([1, 2])[2];`:        "bytes index 2 out of range",
+		`bytes("ab").slice(2, 1);`: "slice start 2 is after end 1",
+		`bytes([255]).toString();`: "bytes are not valid UTF-8",
+		`bytes("a") + "b";`:        "cannot add String to Bytes",
+	} {
+		if _, err := New().Run(parseProgram(t, source)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %q, got %v", source, want, err)
+		}
+	}
+}
+
+func TestStdioStreams(t *testing.T) {
+	program := parseProgram(t, `
+let name = input("name? ");
+let first = readLine();
+let count = 0;
+for (let line in stdin.lines()) { count = count + 1; stdout.write(line, ";"); }
+print("", name, first, count, readLine());
+stdout.setBuffered(true);
+stdout.write("held", bytes([33]));
+check();
+stdout.flush();
+stderr.write("oops");
+`)
+	rt := New()
+	var out, errOut bytes.Buffer
+	rt.SetStdio(strings.NewReader("ada\r\nfirst\nb\nc"), &out, &errOut)
+	var held string
+	rt.Environment().Set("check", newBuiltin("check", func(args []Value) (Value, error) {
+		held = out.String()
+		return NullValue, nil
+	}))
+	if _, err := rt.Run(program); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if want := "name? b;c; ada first 2 null\n"; held != want {
+		t.Fatalf("expected buffered output to be held back, got %q", held)
+	}
+	if want := "name? b;c; ada first 2 null\nheld!"; out.String() != want {
+		t.Fatalf("unexpected stdout %q", out.String())
+	}
+	if errOut.String() != "oops" {
+		t.Fatalf("unexpected stderr %q", errOut.String())
+	}
+}
+
+func TestObjectsAndModulesKeepDeclarationOrder(t *testing.T) {
+	val, err := New().Run(parseProgram(t, `
+module shapes {
+    let zeta = 1;
+    fn area(): Number { return 2; }
+    struct Box(w: Number) {}
+}
+let point = {y: 2, x: 1, label: "p"};
+[shapes, point, regex.compile("(?P<b>.)(?P<a>.)").find("xy").named];
+`))
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	want := "[<module shapes [zeta, area, Box]>, {y: 2, x: 1, label: p}, {b: x, a: y}]"
+	if val.Inspect() != want {
+		t.Fatalf("expected %s, got %s", want, val.Inspect())
+	}
+	obj := val.(*Array).Elements[1].(*Object)
+	obj.Properties["extra"] = NullValue
+	if keys := strings.Join(obj.Keys(), ","); keys != "y,x,label,extra" {
+		t.Fatalf("expected properties added directly to come last, got %s", keys)
+	}
+}
+
+func TestHeredocsStripIndentation(t *testing.T) {
+	program := parseProgram(t, `
+let table = "users";
+fn query(limit: Number): String {
+    return <<~SQL
+        select *
+          from ${table}
+        limit ${limit}\t-- max
+        SQL;
+}
+let pattern = <<~'RE'
+    \d+ of \${n}
+    RE;
+[query(3), pattern];
+`)
+	result, err := New().Run(program)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if want := "[select *\n  from users\nlimit 3\t-- max, \\d+ of ${n}]"; result.Inspect() != want {
+		t.Fatalf("expected %s, got %s", want, result.Inspect())
+	}
+}
+
+func TestFormatSpecsPadAndFormatNumbers(t *testing.T) {
+	program := parseProgram(t, `
+let pi = 3.14159;
+let name = "selene";
+[
+    f"[${pi:>8.2f}] [${name:*^10}] [${name:.3}] [${-42:+06d}]",
+    f"[${1234567.891:,.2f}] [${255:#x}] [${10:#06b}] [${65535:_X}] [${0.256:.1%}] [${pi:.3}]",
+    format("{:>5}|{:<4}|{}|{:,d}", 1.5, "ab", "plain", 1000000)
+]
+`)
+	result, err := New().Run(program)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	want := "[[    3.14] [**selene**] [sel] [-00042], " +
+		"[1,234,567.89] [0xff] [0b1010] [FFFF] [25.6%] [3.14], " +
+		"  1.5|ab  |plain|1,000,000]"
+	if result.Inspect() != want {
+		t.Fatalf("expected %s, got %s", want, result.Inspect())
+	}
+	for src, msg := range map[string]string{
+		`f"${1.5:d}"`:      `format specifier "d" needs an integer, got 1.5`,
+		`f"${true:,}"`:     `format specifier "," needs a Number, got Boolean`,
+		`f"${1:>5q}"`:      `unknown format specifier ">5q"`,
+		`format("{:x", 1)`: "unterminated format placeholder",
+	} {
+		if _, err := New().Run(parseProgram(t, src+";")); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("%s: expected %q, got %v", src, msg, err)
+		}
+	}
+}
+
+func TestToStringCustomizesDisplay(t *testing.T) {
+	program := parseProgram(t, `
+struct Money(cents: Number) {
+    fn toString(): String => "$" + self.cents / 100;
+}
+class Animal(name: String) {
+    fn toString(): String { return "animal " + self.name; }
+}
+class Dog(name: String) : Animal {}
+struct Broken(x: Number) {
+    fn toString(): Number => self.x;
+}
+let price = Money(250);
+print(price, [price, Dog("rex")], "cost: ${price}", "total " + price);
+print(Broken(1));
+`)
+	rt := New()
+	var out bytes.Buffer
+	rt.SetStdio(nil, &out, nil)
+	_, err := rt.Run(program)
+	if want := "$2.5 [$2.5, animal rex] cost: $2.5 total $2.5\n"; out.String() != want {
+		t.Fatalf("expected %q, got %q", want, out.String())
+	}
+	if err == nil || !strings.Contains(err.Error(), "Broken.toString must return a String, got Number") {
+		t.Fatalf("expected print to report the bad toString, got %v", err)
+	}
+	ctor, _ := rt.Environment().Get("Broken")
+	broken, err := applyFunction(ctor, []Value{NewNumber(1)})
+	if err != nil {
+		t.Fatalf("construct Broken: %v", err)
+	}
+	if broken.Inspect() != "Broken{x: 1}" {
+		t.Fatalf("expected Inspect to fall back to the fields, got %s", broken.Inspect())
+	}
+}
+
+func TestClassAccessorsRunOnReadAndAssignment(t *testing.T) {
+	program := parseProgram(t, `
+class Temperature(celsius: Number) {
+    get fahrenheit(): Number => self.celsius * 9 / 5 + 32;
+    set fahrenheit(value: Number) {
+        if value < -459.67 { throw "below absolute zero"; }
+        self.celsius = (value - 32) * 5 / 9;
+    }
+    get kelvin(): Number => self.celsius + 273.15;
+}
+class Reading(celsius: Number) : Temperature {}
+let t = Temperature(100);
+let before = t.fahrenheit;
+t.fahrenheit = 32;
+t.celsius += 10;
+let after = t.fahrenheit;
+let inherited = Reading(0).fahrenheit;
+`)
+	rt := New()
+	if _, err := rt.Run(program); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	for name, want := range map[string]string{"before": "212", "after": "50", "inherited": "32"} {
+		if got, _ := rt.Environment().Get(name); got == nil || got.Inspect() != want {
+			t.Fatalf("expected %s to be %s, got %v", name, want, got)
+		}
+	}
+
+	for src, want := range map[string]string{
+		"t.fahrenheit = -500;": "below absolute zero",
+		"t.kelvin = 0;":        "Temperature.kelvin has a getter but no setter",
+		"t.missing = 1;":       "Temperature has no field missing",
+		"class Bad(x: Number) { get x(): Number => 1; }": "Bad.x is a field and cannot also have a get accessor",
+	} {
+		if _, err := rt.Run(parseProgram(t, src)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q from %s, got %v", want, src, err)
+		}
+	}
+}
+
+func TestStaticClassMembers(t *testing.T) {
+	program := parseProgram(t, `
+class Counter(count: Number) {
+    static let origin = 0;
+    static var created = 0;
+    let step = 1;
+    static fn zero(): Counter => Counter(origin);
+    fn init() { created += 1; }
+    fn next(): Counter => Counter(self.count + step);
+}
+class Tally(count: Number) : Counter {}
+let next = Counter.zero().next().count;
+Tally(5);
+Counter.created += 10;
+let created = Tally.created;
+`)
+	rt := New()
+	if _, err := rt.Run(program); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	for name, want := range map[string]string{"next": "1", "created": "13"} {
+		if got, _ := rt.Environment().Get(name); got == nil || got.Inspect() != want {
+			t.Fatalf("expected %s to be %s, got %v", name, want, got)
+		}
+	}
+	_, err := rt.Run(parseProgram(t, "Counter.step;"))
+	if err == nil || !strings.Contains(err.Error(), "step") {
+		t.Fatalf("expected a body local not to become a static, got %v", err)
+	}
+}
+
+func TestAbstractClassesRequireImplementations(t *testing.T) {
+	program := parseProgram(t, `
+abstract class Shape(name: String) {
+    abstract fn area(): Number;
+    fn describe(): String => self.name + " " + self.area();
+}
+class Square(name: String, side: Number) : Shape {
+    override fn area(): Number => self.side * self.side;
+}
+let described = Square("square", 3).describe();
+`)
+	rt := New()
+	if _, err := rt.Run(program); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got, _ := rt.Environment().Get("described"); got == nil || got.Inspect() != "square 9" {
+		t.Fatalf("expected the subclass to implement area, got %v", got)
+	}
+
+	for src, want := range map[string]string{
+		`Shape("blank");`:                                     "cannot instantiate abstract class Shape",
+		`class Blank(name: String) : Shape {}`:                "Blank must implement the abstract method area it inherits",
+		`class Loose(x: Number) { abstract fn f(): Number; }`: "Loose.f is abstract, so Loose must be an abstract class",
+	} {
+		if _, err := rt.Run(parseProgram(t, src)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q from %s, got %v", want, src, err)
+		}
+	}
+}
+
+func TestEnumMethodsAndCaseBuiltins(t *testing.T) {
+	program := parseProgram(t, `
+enum Color {
+    Red;
+    Green;
+    Rgb(r: Number, g: Number, b: Number);
+    fn hex(): String {
+        match self {
+            Red() => return "#f00";
+            Green() => return "#0f0";
+            Rgb(r, g, b) => return "rgb(${r}, ${g}, ${b})";
+        }
+    }
+}
+let green = Color.Green();
+let summary = [green.hex(), Color.Rgb(1, 2, 3).hex(), green.name, green.ordinal, Color.Rgb(0, 0, 0).ordinal];
+let cases = Color.cases();
+let parsed = [Color.parse("Red"), Color.parse("Rgb"), Color.parse("Blue")];
+`)
+	rt := New()
+	if _, err := rt.Run(program); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	for name, want := range map[string]string{
+		"summary": "[#0f0, rgb(1, 2, 3), Green, 1, 2]",
+		"cases":   "[Red, Green, Rgb]",
+		"parsed":  "[Option.Some(Color.Red), Option.None, Option.None]",
+	} {
+		if got, _ := rt.Environment().Get(name); got == nil || got.Inspect() != want {
+			t.Fatalf("expected %s to be %s, got %v", name, want, got)
+		}
+	}
+	if _, err := rt.Run(parseProgram(t, `Color.parse(1);`)); err == nil || !strings.Contains(err.Error(), "Color.parse expects a String, got Number") {
+		t.Fatalf("expected parse to reject a non-string, got %v", err)
+	}
+}
+
+func TestClassesFulfilContracts(t *testing.T) {
+	program := parseProgram(t, `
+contract Transferable {
+    fn transfer(amount: Number): Boolean;
+    invariant self.balance >= minimum();
+    fn minimum(): Number => 0;
+}
+class Account(balance: Number) : implements Transferable {
+    fn transfer(amount: Number): Boolean => amount <= self.balance;
+}
+class Savings(balance: Number) : Account {}
+let account = Account(10);
+let ok = [account.transfer(5), Savings(3).transfer(5), Transferable.minimum()];
+`)
+	rt := New()
+	if _, err := rt.Run(program); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got, _ := rt.Environment().Get("ok"); got == nil || got.Inspect() != "[true, false, 0]" {
+		t.Fatalf("expected [true, false, 0], got %v", got)
+	}
+	for src, want := range map[string]string{
+		`Account(-1);`:           "Account violates invariant of contract Transferable: self.balance >= minimum()",
+		`Savings(-1);`:           "Savings violates invariant of contract Transferable: self.balance >= minimum()",
+		`Transferable.transfer;`: "Contract has no property transfer",
+		`class Empty() : implements Transferable {} Empty();`:                                                                    "Empty does not fulfil contract Transferable: missing method transfer",
+		`class Wide(balance: Number) : implements Transferable { fn transfer(a: Number, b: Number): Boolean => true; } Wide(1);`: "Wide.transfer takes 2 parameters, but contract Transferable requires 1",
+		`class Wrong() : implements Account {}`:                                                                                  "Account is not a contract",
+	} {
+		if _, err := rt.Run(parseProgram(t, src)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected an error containing %q, got %v", src, want, err)
+		}
+	}
+}
+
+func TestPreconditionsOldValuesAndClassInvariants(t *testing.T) {
+	program := parseProgram(t, `
+fn withdraw(balance: Number, amount: Number): Number
+    contract {
+        requires(amount > 0);
+        ensures(result == old(balance) - amount);
+    }
+{
+    return balance - amount;
+}
+class Tally(n: Number) {}
+fn bump(tally: Tally, by: Number)
+    contract {
+        ensures(tally.n == old(tally.n) + by);
+    }
+{
+    tally.n = tally.n + by;
+}
+class Counter(count: Number) {
+    let floor = 0;
+    invariant self.count >= floor;
+    fn add(step: Number) { self.count = self.count + step; }
+    fn _reset() { self.count = -1; }
+    fn restart() { self._reset(); self.count = 0; }
+}
+class Limited(count: Number) : Counter {
+    invariant self.count < 10;
+}
+let counter = Counter(2);
+counter.restart();
+let results = [withdraw(10, 3), bump(Tally(1), 2), counter.count];
+`)
+	rt := New()
+	if _, err := rt.Run(program); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got, _ := rt.Environment().Get("results"); got == nil || got.Inspect() != "[7, 3, 0]" {
+		t.Fatalf("expected [7, 3, 0], got %v", got)
+	}
+	for src, want := range map[string]string{
+		`withdraw(10, 0);`: "precondition violated in withdraw: amount > 0",
+		`fn lose(tally: Tally) contract { ensures(tally.n == old(tally.n)); } { tally.n = 0; } lose(Tally(1));`: "postcondition violated in lose: tally.n == old(tally.n)",
+		`counter.add(-5);`:   "Counter.add broke invariant of Counter: self.count >= floor",
+		`Counter(-1);`:       "Counter violates invariant of Counter: self.count >= floor",
+		`Limited(5).add(5);`: "Limited.add broke invariant of Limited: self.count < 10",
+		`Limited(-1);`:       "Limited violates invariant of Counter: self.count >= floor",
+	} {
+		if _, err := rt.Run(parseProgram(t, src)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected an error containing %q, got %v", src, want, err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// errScopeCancelled is delivered by tasks that a cancelled scope refused to
+// start. It is not reported as a failure of the scope itself.
+var errScopeCancelled = errors.New("scope cancelled")
+
+// Scope groups tasks started through s.spawn so that scope() can wait for
+// all of them before returning. Running Selene code cannot be pre-empted, so
+// cancellation is cooperative: once a task fails the scope stops starting new
+// tasks and s.cancelled() reports true for the remaining ones to observe.
+type Scope struct {
+	mu        sync.Mutex
+	wg        sync.WaitGroup
+	exited    bool
+	cancelled bool
+	errs      []error
+}
+
+// Type implements the Value interface for Scope.
+func (s *Scope) Type() string { return "Scope" }
+
+// Inspect returns a human-readable representation of Scope.
+func (s *Scope) Inspect() string { return "<scope>" }
+
+// Spawn starts fn as a child task of the scope. Spawning into a cancelled
+// scope returns a task that has already failed without running fn.
+func (s *Scope) Spawn(fn Value, args []Value) (*Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.exited {
+		return nil, errors.New("spawn on a scope that has already exited")
+	}
+	if s.cancelled {
+		task := NewTask()
+		task.deliver(NullValue, errScopeCancelled)
+		return task, nil
+	}
+	s.wg.Add(1)
+	return startTask(fn, args, func(err error) {
+		if err != nil && !errors.Is(err, errScopeCancelled) {
+			s.fail(err)
+		}
+		s.wg.Done()
+	}), nil
+}
+
+// Cancel marks the scope as cancelled without recording an error.
+func (s *Scope) Cancel() {
+	s.mu.Lock()
+	s.cancelled = true
+	s.mu.Unlock()
+}
+
+// Cancelled reports whether the scope has been cancelled.
+func (s *Scope) Cancelled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cancelled
+}
+
+func (s *Scope) fail(err error) {
+	s.mu.Lock()
+	s.errs = append(s.errs, err)
+	s.cancelled = true
+	s.mu.Unlock()
+}
+
+// wait blocks until every child task has finished and returns the combined
+// failure, if any. A single failure is returned unchanged so thrown values
+// still reach catch clauses intact.
+func (s *Scope) wait() error {
+	s.wg.Wait()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exited = true
+	switch len(s.errs) {
+	case 0:
+		return nil
+	case 1:
+		return s.errs[0]
+	}
+	messages := make([]string, len(s.errs))
+	for i, err := range s.errs {
+		messages[i] = err.Error()
+	}
+	return fmt.Errorf("scope failed with %d errors: %s", len(s.errs), strings.Join(messages, "; "))
+}
+
+func builtinScope(args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, errors.New("scope expects a single function")
+	}
+	scope := &Scope{}
+	result, err := applyFunction(args[0], []Value{scope})
+	if err != nil {
+		scope.fail(err)
+	}
+	if err := scope.wait(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func scopeProperty(scope *Scope, property string) (Value, bool, error) {
+	switch property {
+	case "spawn":
+		return newBuiltin("spawn", func(args []Value) (Value, error) {
+			if len(args) == 0 {
+				return nil, errors.New("spawn requires a function")
+			}
+			task, err := scope.Spawn(args[0], args[1:])
+			if err != nil {
+				return nil, err
+			}
+			return task, nil
+		}), true, nil
+	case "cancel":
+		return newBuiltin("cancel", func(args []Value) (Value, error) {
+			if len(args) != 0 {
+				return nil, errors.New("cancel takes no arguments")
+			}
+			scope.Cancel()
+			return NullValue, nil
+		}), true, nil
+	case "cancelled":
+		return newBuiltin("cancelled", func(args []Value) (Value, error) {
+			if len(args) != 0 {
+				return nil, errors.New("cancelled takes no arguments")
+			}
+			return NewBoolean(scope.Cancelled()), nil
+		}), true, nil
+	default:
+		return nil, false, fmt.Errorf("unknown scope property %s", property)
+	}
+}
@@ -0,0 +1,292 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+)
+
+// Set is an unordered collection of distinct values. Elements compare the
+// way == does: numbers, strings, bytes, ranges, booleans, and null by value,
+// everything else by identity. The one exception is NaN, which is a single member although
+// NaN != NaN. Iteration and Inspect follow insertion order. Sets can be
+// shared between tasks, so every access locks.
+type Set struct {
+	mu sync.Mutex
+	// elements holds the members in insertion order; removed members leave
+	// a nil hole until enough accumulate to compact.
+	elements []Value
+	index    map[setKey]int
+	holes    int
+	// frozen marks a Set freeze returned, which add and remove refuse to
+	// change.
+	frozen bool
+}
+
+// setKey identifies a set member. Value types, Bytes and Ranges included,
+// are keyed by their contents and everything else by its pointer, matching
+// equals.
+type setKey struct {
+	kind byte
+	num  float64
+	str  string
+	ref  Value
+}
+
+const (
+	setKeyNull byte = iota
+	setKeyBoolean
+	setKeyNumber
+	setKeyNaN
+	setKeyString
+	setKeyBytes
+	setKeyRange
+	setKeyRef
+)
+
+func keyOf(val Value) setKey {
+	switch v := val.(type) {
+	case *Null:
+		return setKey{kind: setKeyNull}
+	case *Boolean:
+		if v.Value {
+			return setKey{kind: setKeyBoolean, num: 1}
+		}
+		return setKey{kind: setKeyBoolean}
+	case *Number:
+		if math.IsNaN(v.Value) {
+			return setKey{kind: setKeyNaN}
+		}
+		// 0 and -0 are equal, so they are the same member.
+		return setKey{kind: setKeyNumber, num: v.Value + 0}
+	case *String:
+		return setKey{kind: setKeyString, str: v.Value}
+	case *Bytes:
+		return setKey{kind: setKeyBytes, str: string(v.Value)}
+	case *Range:
+		to := strconv.FormatFloat(v.To+0, 'g', -1, 64)
+		if v.Inclusive {
+			to = "=" + to
+		}
+		return setKey{kind: setKeyRange, num: v.From + 0, str: to}
+	default:
+		return setKey{kind: setKeyRef, ref: val}
+	}
+}
+
+// NewSet returns a set holding the distinct values of elements.
+func NewSet(elements ...Value) *Set {
+	s := &Set{index: make(map[setKey]int, len(elements))}
+	for _, el := range elements {
+		s.add(el)
+	}
+	return s
+}
+
+// Type implements the Value interface for Set.
+func (s *Set) Type() string { return "Set" }
+
+// Inspect returns a human-readable representation of Set.
+func (s *Set) Inspect() string {
+	elements := s.Elements()
+	if len(elements) == 0 {
+		return "#{}"
+	}
+	b := borrowBuilder()
+	b.WriteString("#{")
+	for i, el := range elements {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(el.Inspect())
+	}
+	b.WriteByte('}')
+	return finishBuilder(b)
+}
+
+// Elements returns a snapshot of the members in insertion order.
+func (s *Set) Elements() []Value {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elements := make([]Value, 0, len(s.index))
+	for _, el := range s.elements {
+		if el != nil {
+			elements = append(elements, el)
+		}
+	}
+	return elements
+}
+
+// Len reports the number of members.
+func (s *Set) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.index)
+}
+
+// Has reports whether val is a member.
+func (s *Set) Has(val Value) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.index[keyOf(val)]
+	return ok
+}
+
+// Add inserts val, reporting whether it was not already a member.
+func (s *Set) Add(val Value) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(val)
+}
+
+func (s *Set) add(val Value) bool {
+	key := keyOf(val)
+	if _, ok := s.index[key]; ok {
+		return false
+	}
+	s.index[key] = len(s.elements)
+	s.elements = append(s.elements, val)
+	return true
+}
+
+// Remove deletes val, reporting whether it was a member.
+func (s *Set) Remove(val Value) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := keyOf(val)
+	i, ok := s.index[key]
+	if !ok {
+		return false
+	}
+	delete(s.index, key)
+	s.elements[i] = nil
+	s.holes++
+	if s.holes > len(s.index) {
+		s.compact()
+	}
+	return true
+}
+
+// compact closes the holes Remove leaves, keeping insertion order.
+func (s *Set) compact() {
+	live := s.elements[:0]
+	for _, el := range s.elements {
+		if el != nil {
+			s.index[keyOf(el)] = len(live)
+			live = append(live, el)
+		}
+	}
+	clear(s.elements[len(live):])
+	s.elements = live
+	s.holes = 0
+}
+
+// builtinSet implements set(), which builds a set from the elements of an
+// optional iterable.
+func builtinSet(args []Value) (Value, error) {
+	switch len(args) {
+	case 0:
+		return NewSet(), nil
+	case 1:
+		s := NewSet()
+		err := iterate(args[0], func(val Value) (bool, error) {
+			s.add(val)
+			return true, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		return nil, errors.New("set expects at most one iterable")
+	}
+}
+
+// setProperty resolves the members of a set value: size and its methods.
+func setProperty(s *Set, property string) (Value, bool, error) {
+	switch property {
+	case "size":
+		return NewNumber(float64(s.Len())), true, nil
+	case "add":
+		return newBuiltin("add", func(args []Value) (Value, error) {
+			if s.frozen {
+				return nil, errors.New("cannot add to a frozen Set")
+			}
+			for _, arg := range args {
+				s.Add(arg)
+			}
+			return s, nil
+		}), true, nil
+	case "remove":
+		return newBuiltin("remove", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, errors.New("remove expects one value")
+			}
+			if s.frozen {
+				return nil, errors.New("cannot remove from a frozen Set")
+			}
+			return NewBoolean(s.Remove(args[0])), nil
+		}), true, nil
+	case "has":
+		return newBuiltin("has", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, errors.New("has expects one value")
+			}
+			return NewBoolean(s.Has(args[0])), nil
+		}), true, nil
+	case "union", "intersection", "difference":
+		return newBuiltin(property, func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("%s expects one set or other iterable", property)
+			}
+			other, err := asSet(args[0])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", property, err)
+			}
+			return combineSets(property, s, other), nil
+		}), true, nil
+	case "toArray":
+		return newBuiltin("toArray", func(args []Value) (Value, error) {
+			if len(args) != 0 {
+				return nil, errors.New("toArray takes no arguments")
+			}
+			return &Array{Elements: s.Elements()}, nil
+		}), true, nil
+	}
+	if fn, ok := lookupExtension(s.Type(), property); ok {
+		return bindMethod(fn, s), true, nil
+	}
+	return nil, false, fmt.Errorf("unknown set property %s", property)
+}
+
+// asSet returns val as a set, building one from any other iterable.
+func asSet(val Value) (*Set, error) {
+	if s, ok := val.(*Set); ok {
+		return s, nil
+	}
+	built, err := builtinSet([]Value{val})
+	if err != nil {
+		return nil, err
+	}
+	return built.(*Set), nil
+}
+
+// combineSets returns a new set: the union, intersection, or difference of a
+// and b. Members keep the order they have in a, followed for a union by
+// those only b has.
+func combineSets(op string, a, b *Set) *Set {
+	result := NewSet()
+	for _, el := range a.Elements() {
+		if op == "union" || b.Has(el) == (op == "intersection") {
+			result.add(el)
+		}
+	}
+	if op == "union" {
+		for _, el := range b.Elements() {
+			result.add(el)
+		}
+	}
+	return result
+}
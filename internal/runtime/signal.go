@@ -0,0 +1,117 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/cybellereaper/selenelang/internal/token"
+)
+
+// signalNames maps the names os.onSignal accepts to the signals hosts
+// forward through HandleSignal.
+var signalNames = map[string]os.Signal{
+	"int":  os.Interrupt,
+	"term": syscall.SIGTERM,
+	"hup":  syscall.SIGHUP,
+}
+
+// Signals lists the signals scripts can handle with os.onSignal, for hosts
+// to pass to signal.Notify.
+func Signals() []os.Signal {
+	names := signalNameList()
+	signals := make([]os.Signal, len(names))
+	for i, name := range names {
+		signals[i] = signalNames[name]
+	}
+	return signals
+}
+
+func signalNameList() []string {
+	names := make([]string, 0, len(signalNames))
+	for name := range signalNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// signalHandlers holds the functions registered with os.onSignal.
+type signalHandlers struct {
+	mu       sync.Mutex
+	handlers map[string]Value
+}
+
+func (h *signalHandlers) set(name string, fn Value) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if fn == nil {
+		delete(h.handlers, name)
+		return
+	}
+	if h.handlers == nil {
+		h.handlers = make(map[string]Value)
+	}
+	h.handlers[name] = fn
+}
+
+func (h *signalHandlers) get(name string) (Value, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fn, ok := h.handlers[name]
+	return fn, ok
+}
+
+func (r *Runtime) onSignalBuiltin() Value {
+	return newBuiltin("onSignal", func(args []Value) (Value, error) {
+		if len(args) != 2 {
+			return nil, errors.New("os.onSignal expects a signal name and a handler")
+		}
+		name, err := stringArg("os.onSignal", args[0])
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := signalNames[name]; !ok {
+			return nil, fmt.Errorf("os.onSignal: unknown signal %q (expected one of %s)", name, strings.Join(signalNameList(), ", "))
+		}
+		switch handler := args[1].(type) {
+		case *Null:
+			r.signals.set(name, nil)
+		case *Function:
+			r.signals.set(name, handler)
+		default:
+			return nil, fmt.Errorf("os.onSignal expects a function or null handler, got %s", args[1].Type())
+		}
+		return NullValue, nil
+	})
+}
+
+// HandleSignal runs the handler the program registered for sig with
+// os.onSignal on a new task and returns it. A handler that declares a
+// parameter receives the signal name, such as "int". HandleSignal returns
+// nil when the program registered no handler for sig; the host then applies
+// its default, which for `selene run` is to call Interrupt.
+func (r *Runtime) HandleSignal(sig os.Signal) *Task {
+	for name, candidate := range signalNames {
+		if candidate != sig {
+			continue
+		}
+		fn, ok := r.signals.get(name)
+		if !ok {
+			return nil
+		}
+		var args []Value
+		if f, ok := fn.(*Function); ok && f.Declaration != nil && len(f.Declaration.Params) == 1 {
+			args = []Value{NewString(name)}
+		}
+		id := r.tracker.addTask(token.Position{}, fn)
+		task := startTask(fn, args, func(error) { r.tracker.removeTask(id) })
+		task.control, task.tracker = r.control, r.tracker
+		return task
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package runtime
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// stdio holds the standard streams of a runtime. Writes to stdout are
+// flushed as they happen unless a script turns on buffering with
+// stdout.setBuffered(true), which suits filters that write many small
+// pieces; buffered output is flushed by stdout.flush(), before every read
+// from stdin so prompts show up, and by Flush. Writes to stderr are never
+// held back. Tasks share the streams, so every access locks.
+type stdio struct {
+	mu       sync.Mutex
+	in       *bufio.Reader
+	out      *bufio.Writer
+	errOut   *bufio.Writer
+	buffered bool
+}
+
+func newStdio(in io.Reader, out, errOut io.Writer) *stdio {
+	return &stdio{in: bufio.NewReader(in), out: bufio.NewWriter(out), errOut: bufio.NewWriter(errOut)}
+}
+
+// SetStdio replaces the streams behind print, input, and the stdin, stdout,
+// and stderr modules. A nil stream keeps the process's own. Call it before
+// running code.
+func (r *Runtime) SetStdio(in io.Reader, out, errOut io.Writer) {
+	if in == nil {
+		in = os.Stdin
+	}
+	if out == nil {
+		out = os.Stdout
+	}
+	if errOut == nil {
+		errOut = os.Stderr
+	}
+	r.stdio = newStdio(in, out, errOut)
+}
+
+// Flush writes out anything a script left in the stdout buffer. Hosts call
+// it once the program is done, whichever way it finished.
+func (r *Runtime) Flush() error {
+	r.stdio.mu.Lock()
+	defer r.stdio.mu.Unlock()
+	return r.stdio.out.Flush()
+}
+
+func (s *stdio) write(toErr bool, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w := s.out
+	if toErr {
+		w = s.errOut
+	}
+	if _, err := w.WriteString(text); err != nil {
+		return err
+	}
+	if toErr || !s.buffered {
+		return w.Flush()
+	}
+	return nil
+}
+
+// readLine returns the next line of input without its line ending, and
+// false at the end of input.
+func (s *stdio) readLine() (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.out.Flush(); err != nil {
+		return "", false, err
+	}
+	line, err := s.in.ReadString('\n')
+	if errors.Is(err, io.EOF) {
+		if line == "" {
+			return "", false, nil
+		}
+		err = nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), true, nil
+}
+
+func (s *stdio) readAll() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.out.Flush(); err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(s.in)
+	return string(data), err
+}
+
+// Iterator is a lazy sequence produced by a builtin, such as the lines of
+// stdin. for-in draws from it until it is exhausted, and next() returns
+// the same {value, done} objects a generator's does.
+type Iterator struct {
+	name string
+	next func() (Value, bool, error)
+}
+
+// Type implements the Value interface for Iterator.
+func (it *Iterator) Type() string { return "Iterator" }
+
+// Inspect returns a human-readable representation of Iterator.
+func (it *Iterator) Inspect() string { return "<iterator " + it.name + ">" }
+
+func iteratorProperty(it *Iterator, property string) (Value, bool, error) {
+	if property != "next" {
+		return nil, false, fmt.Errorf("unknown iterator property %s", property)
+	}
+	return newBuiltin("next", func(args []Value) (Value, error) {
+		if len(args) != 0 {
+			return nil, errors.New("next takes no arguments")
+		}
+		value, ok, err := it.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			value = NullValue
+		}
+		return NewObject(Property{"value", value}, Property{"done", NewBoolean(!ok)}), nil
+	}), true, nil
+}
+
+func (r *Runtime) printBuiltin() Value {
+	return newBuiltin("print", func(args []Value) (Value, error) {
+		parts := make([]string, len(args))
+		for i, arg := range args {
+			text, err := display(arg)
+			if err != nil {
+				return nil, err
+			}
+			parts[i] = text
+		}
+		return NullValue, r.stdio.write(false, strings.Join(parts, " ")+"\n")
+	})
+}
+
+// inputBuiltin implements input(prompt) and readLine(): the prompt, if any,
+// is written to stdout, and the next line of stdin is returned, or null at
+// the end of input.
+func (r *Runtime) inputBuiltin(name string, prompt bool) Value {
+	return newBuiltin(name, func(args []Value) (Value, error) {
+		switch {
+		case prompt && len(args) == 1:
+			if err := r.stdio.write(false, toString(args[0])); err != nil {
+				return nil, err
+			}
+		case len(args) != 0:
+			if prompt {
+				return nil, errors.New("input expects at most a prompt")
+			}
+			return nil, errors.New("readLine takes no arguments")
+		}
+		line, ok, err := r.stdio.readLine()
+		if err != nil || !ok {
+			return NullValue, err
+		}
+		return NewString(line), nil
+	})
+}
+
+func newStdinModule(r *Runtime) *Module {
+	return NewModule("stdin", map[string]Value{
+		"readLine": r.inputBuiltin("readLine", false),
+		"read": newBuiltin("read", func(args []Value) (Value, error) {
+			if len(args) != 0 {
+				return nil, errors.New("stdin.read takes no arguments")
+			}
+			text, err := r.stdio.readAll()
+			if err != nil {
+				return nil, err
+			}
+			return NewString(text), nil
+		}),
+		"lines": newBuiltin("lines", func(args []Value) (Value, error) {
+			if len(args) != 0 {
+				return nil, errors.New("stdin.lines takes no arguments")
+			}
+			return &Iterator{name: "stdin.lines", next: func() (Value, bool, error) {
+				line, ok, err := r.stdio.readLine()
+				if err != nil || !ok {
+					return nil, false, err
+				}
+				return NewString(line), true, nil
+			}}, nil
+		}),
+	})
+}
+
+// newOutputModule binds stdout or stderr. write prints its arguments with
+// no separator or trailing newline; Bytes are written as they are.
+func newOutputModule(r *Runtime, name string) *Module {
+	toErr := name == "stderr"
+	exports := map[string]Value{
+		"write": newBuiltin("write", func(args []Value) (Value, error) {
+			var b strings.Builder
+			for _, arg := range args {
+				if data, ok := arg.(*Bytes); ok {
+					b.Write(data.Value)
+					continue
+				}
+				text, err := display(arg)
+				if err != nil {
+					return nil, err
+				}
+				b.WriteString(text)
+			}
+			return NullValue, r.stdio.write(toErr, b.String())
+		}),
+		"flush": newBuiltin("flush", func(args []Value) (Value, error) {
+			if len(args) != 0 {
+				return nil, fmt.Errorf("%s.flush takes no arguments", name)
+			}
+			if toErr {
+				return NullValue, nil
+			}
+			return NullValue, r.Flush()
+		}),
+	}
+	if !toErr {
+		exports["setBuffered"] = newBuiltin("setBuffered", func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, errors.New("stdout.setBuffered expects a Boolean")
+			}
+			enabled, ok := args[0].(*Boolean)
+			if !ok {
+				return nil, fmt.Errorf("stdout.setBuffered expects a Boolean, got %s", args[0].Type())
+			}
+			r.stdio.mu.Lock()
+			r.stdio.buffered = enabled.Value
+			r.stdio.mu.Unlock()
+			if !enabled.Value {
+				return NullValue, r.Flush()
+			}
+			return NullValue, nil
+		})
+	}
+	return NewModule(name, exports)
+}
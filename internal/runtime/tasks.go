@@ -0,0 +1,317 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cybellereaper/selenelang/internal/token"
+)
+
+// newTasksModule provides combinators over tasks. Each returns a new Task, so
+// scripts write `await tasks.all(list)`. Like await, non-Task elements count
+// as tasks that have already completed with that value.
+func newTasksModule(r *Runtime) *Module {
+	return NewModule("tasks", map[string]Value{
+		"all": newBuiltin("all", func(args []Value) (Value, error) {
+			elements, err := taskList("tasks.all", args)
+			if err != nil {
+				return nil, err
+			}
+			return r.combine(func() (Value, error) { return awaitAll(elements) }), nil
+		}),
+		"any": newBuiltin("any", func(args []Value) (Value, error) {
+			elements, err := taskList("tasks.any", args)
+			if err != nil {
+				return nil, err
+			}
+			if len(elements) == 0 {
+				return nil, errors.New("tasks.any expects at least one task")
+			}
+			return r.combine(func() (Value, error) { return awaitAny(elements) }), nil
+		}),
+		"within": newBuiltin("within", func(args []Value) (Value, error) {
+			if len(args) != 2 {
+				return nil, errors.New("tasks.within expects a task and a timeout in milliseconds")
+			}
+			num, ok := args[1].(*Number)
+			if !ok || num.Value < 0 {
+				return nil, fmt.Errorf("tasks.within expects a non-negative Number timeout, got %s", args[1].Inspect())
+			}
+			timeout := time.Duration(num.Value * float64(time.Millisecond))
+			return r.combine(func() (Value, error) { return awaitWithin(args[0], timeout, num.Value) }), nil
+		}),
+	})
+}
+
+// combine runs wait on its own goroutine and exposes its outcome as a Task
+// tied to the runtime's context.
+func (r *Runtime) combine(wait func() (Value, error)) *Task {
+	task := NewTask()
+	task.control, task.tracker = r.control, r.tracker
+	go func() {
+		val, err := wait()
+		if err != nil {
+			val = NullValue
+		}
+		task.deliver(val, err)
+	}()
+	return task
+}
+
+func taskList(name string, args []Value) ([]Value, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s expects an array of tasks", name)
+	}
+	arr, ok := args[0].(*Array)
+	if !ok {
+		return nil, fmt.Errorf("%s expects an array of tasks, got %s", name, args[0].Type())
+	}
+	return append([]Value(nil), arr.Elements...), nil
+}
+
+type indexedResult struct {
+	index int
+	value Value
+	err   error
+}
+
+// joinEach joins every element concurrently and streams the outcomes in
+// completion order. The channel is buffered so stragglers never block once
+// the caller has stopped listening.
+func joinEach(elements []Value) <-chan indexedResult {
+	results := make(chan indexedResult, len(elements))
+	for i, el := range elements {
+		go func() {
+			task, ok := el.(*Task)
+			if !ok {
+				results <- indexedResult{index: i, value: el}
+				return
+			}
+			val, err := task.Join()
+			results <- indexedResult{index: i, value: val, err: err}
+		}()
+	}
+	return results
+}
+
+// awaitAll resolves to the results in input order, failing with the first
+// error to arrive without waiting for the remaining tasks.
+func awaitAll(elements []Value) (Value, error) {
+	values := make([]Value, len(elements))
+	results := joinEach(elements)
+	for range elements {
+		res := <-results
+		if res.err != nil {
+			return nil, res.err
+		}
+		values[res.index] = res.value
+	}
+	return &Array{Elements: values}, nil
+}
+
+// awaitAny resolves to the first successful result. It fails only when every
+// task fails, reporting all of their errors.
+func awaitAny(elements []Value) (Value, error) {
+	errs := make([]string, len(elements))
+	results := joinEach(elements)
+	for range elements {
+		res := <-results
+		if res.err == nil {
+			return res.value, nil
+		}
+		errs[res.index] = res.err.Error()
+	}
+	return nil, fmt.Errorf("all %d tasks failed: %s", len(elements), strings.Join(errs, "; "))
+}
+
+// awaitWithin resolves to the task's result, or fails if it has not finished
+// after timeout. The task itself keeps running.
+func awaitWithin(value Value, timeout time.Duration, millis float64) (Value, error) {
+	task, ok := value.(*Task)
+	if !ok {
+		return value, nil
+	}
+	task.observe()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-task.done:
+		return task.Join()
+	case <-timer.C:
+		return nil, fmt.Errorf("task did not finish within %gms", millis)
+	}
+}
+
+// onSettled arranges for fn to receive the task's result once it completes.
+// Callbacks run one at a time, in the order they were attached, on a
+// goroutine separate from both the task and the caller.
+func (t *Task) onSettled(fn func(taskResult)) {
+	t.contMu.Lock()
+	defer t.contMu.Unlock()
+	t.continuations = append(t.continuations, fn)
+	if t.settled && !t.draining {
+		t.draining = true
+		go t.drain()
+	}
+}
+
+// settle marks the task complete and starts any waiting callbacks. deliver
+// calls it after the result is available.
+func (t *Task) settle() {
+	t.contMu.Lock()
+	defer t.contMu.Unlock()
+	t.settled = true
+	if len(t.continuations) > 0 && !t.draining {
+		t.draining = true
+		go t.drain()
+	}
+}
+
+func (t *Task) drain() {
+	res := t.await()
+	for {
+		t.contMu.Lock()
+		if len(t.continuations) == 0 {
+			t.draining = false
+			t.contMu.Unlock()
+			return
+		}
+		next := t.continuations[0]
+		t.continuations = t.continuations[1:]
+		t.contMu.Unlock()
+		next(res)
+	}
+}
+
+// continueWith returns a task that completes with step's outcome once t has
+// completed. A Task returned by step is awaited before the new task settles,
+// so continuations can themselves start asynchronous work.
+func (t *Task) continueWith(site token.Position, fn Value, step func(taskResult) (Value, error)) *Task {
+	t.observe()
+	next := NewTask()
+	next.control, next.tracker = t.control, t.tracker
+	id := -1
+	if t.tracker != nil {
+		id = t.tracker.addTask(site, fn)
+	}
+	finish := func(val Value, err error) {
+		if id >= 0 {
+			t.tracker.removeTask(id)
+		}
+		if err != nil {
+			val = NullValue
+		}
+		next.deliver(val, err)
+	}
+	shareValue(fn)
+	enter := forkStrand(fn)
+	t.onSettled(func(res taskResult) {
+		exit := enter()
+		val, err := runStep(step, res)
+		exit()
+		if inner, ok := val.(*Task); ok && err == nil {
+			inner.onSettled(func(res taskResult) { finish(res.value, res.err) })
+			return
+		}
+		finish(val, err)
+	})
+	return next
+}
+
+func runStep(step func(taskResult) (Value, error), res taskResult) (val Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			val, err = NullValue, fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return step(res)
+}
+
+// uncatchable reports errors that continuations pass along untouched, the
+// same ones catch clauses cannot intercept.
+func uncatchable(err error) bool {
+	var exit *ExitError
+	var cancelled *CancelledError
+	var budget *BudgetError
+	return errors.As(err, &exit) || errors.As(err, &cancelled) || errors.As(err, &budget)
+}
+
+func taskProperty(t *Task, property string) (Value, bool, error) {
+	switch property {
+	case "join":
+		return newBuiltin("join", func(args []Value) (Value, error) {
+			if len(args) != 0 {
+				return nil, errors.New("join takes no arguments")
+			}
+			return t.Join()
+		}), true, nil
+	case "then":
+		return newSitedBuiltin("then", func(site token.Position, args []Value) (Value, error) {
+			fn, err := continuationArg("then", args)
+			if err != nil {
+				return nil, err
+			}
+			return t.continueWith(site, fn, func(res taskResult) (Value, error) {
+				if res.err != nil {
+					return nil, res.err
+				}
+				return applyFunctionAt(0, fn, []Value{res.value})
+			}), nil
+		}), true, nil
+	case "catch":
+		return newSitedBuiltin("catch", func(site token.Position, args []Value) (Value, error) {
+			fn, err := continuationArg("catch", args)
+			if err != nil {
+				return nil, err
+			}
+			return t.continueWith(site, fn, func(res taskResult) (Value, error) {
+				if res.err == nil || uncatchable(res.err) {
+					return res.value, res.err
+				}
+				return applyFunctionAt(0, fn, []Value{wrapRuntimeError(res.err).caught()})
+			}), nil
+		}), true, nil
+	case "finally":
+		return newSitedBuiltin("finally", func(site token.Position, args []Value) (Value, error) {
+			fn, err := continuationArg("finally", args)
+			if err != nil {
+				return nil, err
+			}
+			return t.continueWith(site, fn, func(res taskResult) (Value, error) {
+				val, err := applyFunctionAt(0, fn, nil)
+				if err != nil {
+					return nil, err
+				}
+				cleanup, ok := val.(*Task)
+				if !ok {
+					return res.value, res.err
+				}
+				// Let asynchronous cleanup finish, then restore the original
+				// outcome unless the cleanup itself failed.
+				restored := NewTask()
+				cleanup.onSettled(func(done taskResult) {
+					if done.err != nil {
+						restored.deliver(NullValue, done.err)
+						return
+					}
+					restored.deliver(res.value, res.err)
+				})
+				return restored, nil
+			}), nil
+		}), true, nil
+	default:
+		return nil, false, fmt.Errorf("unknown task property %s", property)
+	}
+}
+
+func continuationArg(name string, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s expects a single function", name)
+	}
+	if _, ok := args[0].(*Function); !ok {
+		return nil, fmt.Errorf("%s expects a function, got %s", name, args[0].Type())
+	}
+	return args[0], nil
+}
@@ -0,0 +1,117 @@
+package runtime
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+)
+
+// CompiledBody runs a function body in the environment of one call, with the
+// parameters already bound. It behaves like the interpreted body, including
+// the return signal a return statement produces.
+type CompiledBody func(env *Environment) (Value, error)
+
+// FunctionCompiler compiles the body of a function declaration.
+type FunctionCompiler func(decl *ast.FunctionDeclaration) (CompiledBody, error)
+
+// TierStat reports how often a function ran under tiering and whether it was
+// promoted to its compiled body.
+type TierStat struct {
+	Name     string
+	Line     int
+	Calls    int64
+	Promoted bool
+	// Err is why compiling the function failed, in which case it kept
+	// running in the interpreter.
+	Err error
+}
+
+// tiering counts calls to each function declaration and compiles the ones
+// that reach threshold. Counts are kept per declaration, so every closure
+// created from one declaration shares its count and compiled body.
+type tiering struct {
+	threshold int64
+	compile   FunctionCompiler
+	functions sync.Map // *ast.FunctionDeclaration -> *tierEntry
+}
+
+type tierEntry struct {
+	name  string
+	calls atomic.Int64
+	body  atomic.Pointer[CompiledBody]
+	err   atomic.Pointer[error]
+}
+
+// SetTiering runs functions in the interpreter until they have been called
+// threshold times and then switches them to the body compile returns for
+// them. A threshold below one disables tiering. Call it before running code.
+func (r *Runtime) SetTiering(threshold int, compile FunctionCompiler) {
+	if threshold < 1 || compile == nil {
+		r.control.tiering = nil
+		return
+	}
+	r.control.tiering = &tiering{threshold: int64(threshold), compile: compile}
+}
+
+// TierStats lists the functions that have run since SetTiering, the most
+// called first.
+func (r *Runtime) TierStats() []TierStat {
+	t := r.control.tiering
+	if t == nil {
+		return nil
+	}
+	var stats []TierStat
+	t.functions.Range(func(key, value any) bool {
+		entry := value.(*tierEntry)
+		stat := TierStat{Name: entry.name, Line: key.(*ast.FunctionDeclaration).Pos().Line, Calls: entry.calls.Load(), Promoted: entry.body.Load() != nil}
+		if err := entry.err.Load(); err != nil {
+			stat.Err = *err
+		}
+		stats = append(stats, stat)
+		return true
+	})
+	slices.SortFunc(stats, func(a, b TierStat) int {
+		return cmp.Or(cmp.Compare(b.Calls, a.Calls), cmp.Compare(a.Line, b.Line), cmp.Compare(a.Name, b.Name))
+	})
+	return stats
+}
+
+// compiledBody returns the compiled body fn runs under tiering, or nil when
+// it is interpreted.
+func (c *runControl) compiledBody(fn *Function) CompiledBody {
+	if c == nil || c.tiering == nil || fn.Declaration.Generator {
+		return nil
+	}
+	return c.tiering.body(fn)
+}
+
+// body counts a call to fn and returns its compiled body once it has been
+// promoted, or nil while it should still be interpreted.
+func (t *tiering) body(fn *Function) CompiledBody {
+	value, ok := t.functions.Load(fn.Declaration)
+	if !ok {
+		name := fn.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+		value, _ = t.functions.LoadOrStore(fn.Declaration, &tierEntry{name: name})
+	}
+	entry := value.(*tierEntry)
+	if body := entry.body.Load(); body != nil {
+		entry.calls.Add(1)
+		return *body
+	}
+	if entry.calls.Add(1) != t.threshold {
+		return nil
+	}
+	body, err := t.compile(fn.Declaration)
+	if err != nil {
+		entry.err.Store(&err)
+		return nil
+	}
+	entry.body.Store(&body)
+	return body
+}
@@ -0,0 +1,131 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/cybellereaper/selenelang/internal/token"
+)
+
+// maxTraceFrames bounds the frames kept for one error so runaway recursion
+// does not produce an unbounded trace.
+const maxTraceFrames = 64
+
+// DefaultMaxCallDepth is the number of nested function calls a runtime
+// allows unless SetMaxCallDepth says otherwise. Each call takes a few
+// kilobytes of Go stack, so this stays well clear of Go's own limit.
+const DefaultMaxCallDepth = 10000
+
+// SetMaxCallDepth bounds how deeply function calls may nest in each task.
+// A call past the bound fails with "maximum recursion depth exceeded", which
+// scripts can catch. Zero or less removes the bound, so unbounded recursion
+// crashes the process with a Go stack overflow again.
+func (r *Runtime) SetMaxCallDepth(depth int) {
+	r.control.maxDepth = int32(min(depth, math.MaxInt32))
+}
+
+// unknownDepth is the caller depth of a call whose caller is not known. Such
+// a call counts from the depth of the environment its function was defined
+// in, plus the calls of its kind in progress.
+const unknownDepth = -1
+
+// errCallDepth is reported by a call that would pass the maximum call depth.
+var errCallDepth = errors.New("maximum recursion depth exceeded")
+
+// enterCall sets the depth of e, a new call environment, to one more than
+// callerDepth and checks it against the runtime's maximum.
+func (e *Environment) enterCall(callerDepth int32) error {
+	e.depth = callerDepth + 1
+	if e.control != nil && e.control.maxDepth > 0 && e.depth > e.control.maxDepth {
+		return errCallDepth
+	}
+	return nil
+}
+
+// StackFrame is one call in the stack trace of an error that escaped it.
+type StackFrame struct {
+	Function string
+	// Site is the position of the call, or the zero Position when the
+	// runtime made the call itself, as it does for main.
+	Site token.Position
+}
+
+// String renders the frame as it appears in FormatError.
+func (f StackFrame) String() string {
+	if f.Site.Line == 0 {
+		return "at " + f.Function
+	}
+	return fmt.Sprintf("at %s (called from %s)", f.Function, f.Site)
+}
+
+// traceError records the calls a catchable error unwound through. It keeps
+// the message of the error it wraps, and catch clauses see only that error.
+type traceError struct {
+	err     error
+	frames  []StackFrame
+	omitted int
+}
+
+func (e *traceError) Error() string { return e.err.Error() }
+
+func (e *traceError) Unwrap() error { return e.err }
+
+// withFrame adds the call of fn at site to err's trace. Control flow signals
+// and errors scripts cannot catch pass through untouched.
+func withFrame(err error, fn Value, site token.Position) error {
+	switch err.(type) {
+	case nil, *returnSignal, *breakSignal, *continueSignal, *generatorStop:
+		return err
+	}
+	if uncatchable(err) {
+		return err
+	}
+	name := "<fn>"
+	if f, ok := fn.(*Function); ok && f.Name != "" {
+		name = f.Name
+	}
+	frame := StackFrame{Function: name, Site: site}
+	traced, ok := err.(*traceError)
+	if !ok {
+		return &traceError{err: err, frames: []StackFrame{frame}}
+	}
+	// Tasks can hand the same error to several joiners, so extend a copy.
+	next := &traceError{err: traced.err, omitted: traced.omitted}
+	if len(traced.frames) >= maxTraceFrames {
+		next.frames, next.omitted = traced.frames, traced.omitted+1
+		return next
+	}
+	next.frames = append(append(make([]StackFrame, 0, len(traced.frames)+1), traced.frames...), frame)
+	return next
+}
+
+// StackTrace returns the calls err unwound through before reaching the
+// caller of Run, innermost first, or nil when err carries no trace.
+func StackTrace(err error) []StackFrame {
+	var traced *traceError
+	if !errors.As(err, &traced) {
+		return nil
+	}
+	return append([]StackFrame(nil), traced.frames...)
+}
+
+// FormatError renders err followed by its stack trace, one indented line per
+// frame.
+func FormatError(err error) string {
+	var traced *traceError
+	if !errors.As(err, &traced) {
+		return err.Error()
+	}
+	var b strings.Builder
+	b.WriteString(err.Error())
+	for _, frame := range traced.frames {
+		b.WriteString("\n    ")
+		b.WriteString(frame.String())
+	}
+	if traced.omitted > 0 {
+		fmt.Fprintf(&b, "\n    ... %d more", traced.omitted)
+	}
+	return b.String()
+}
@@ -0,0 +1,370 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// The canonical JSON encoding of a value is one object whose "kind" names
+// its shape:
+//
+//	{"kind":"null"}
+//	{"kind":"boolean","value":true}
+//	{"kind":"number","value":1.5}          NaN and the infinities are the strings "NaN", "Infinity", "-Infinity"
+//	{"kind":"string","value":"text"}
+//	{"kind":"bytes","value":"aGk="}          base64
+//	{"kind":"array","elements":[...]}
+//	{"kind":"set","elements":[...]}        in insertion order
+//	{"kind":"object","properties":{...},"keys":[...]}   keys in insertion order
+//	{"kind":"error","message":"...","cause":{...}}
+//	{"kind":"struct","type":"Point","fields":[{"name":"x","value":{...}}, ...]}
+//	{"kind":"class","type":"Account","fields":[...]}
+//	{"kind":"enum","type":"Option","case":"Some","fields":[...]}
+//	{"kind":"opaque","type":"Function","inspect":"<fn main>"}
+//
+// Fields are listed in declaration order, so equal values always encode to
+// the same bytes. Values with no data representation, such as functions,
+// channels, tasks, and types, encode as opaque: they can be shown, but not
+// decoded.
+
+// MarshalValue encodes val in the canonical JSON encoding. Tools that show,
+// record, compare, or transmit values share this encoding rather than
+// parsing Inspect output. A value that contains itself cannot be encoded.
+func MarshalValue(val Value) ([]byte, error) {
+	e := &valueEncoder{visiting: make(map[Value]bool)}
+	return e.encode(val)
+}
+
+// UnmarshalValue decodes data produced by MarshalValue. Struct, class, and
+// enum values are bound to the type of that name in env, when env is not
+// nil and defines one, so that methods keep working; otherwise they get a
+// detached definition that carries only the name and fields. Opaque values
+// cannot be decoded.
+func UnmarshalValue(data []byte, env *Environment) (Value, error) {
+	d := &valueDecoder{env: env, detached: make(map[string]Value)}
+	return d.decode(data)
+}
+
+type wireValue struct {
+	Kind       string                     `json:"kind"`
+	Type       string                     `json:"type,omitempty"`
+	Case       string                     `json:"case,omitempty"`
+	Value      json.RawMessage            `json:"value,omitempty"`
+	Elements   []json.RawMessage          `json:"elements,omitempty"`
+	Properties map[string]json.RawMessage `json:"properties,omitempty"`
+	Keys       []string                   `json:"keys,omitempty"`
+	Fields     []wireField                `json:"fields,omitempty"`
+	Message    *string                    `json:"message,omitempty"`
+	Cause      json.RawMessage            `json:"cause,omitempty"`
+	Inspect    string                     `json:"inspect,omitempty"`
+}
+
+type wireField struct {
+	Name  string          `json:"name"`
+	Value json.RawMessage `json:"value"`
+}
+
+type valueEncoder struct {
+	visiting map[Value]bool
+}
+
+func (e *valueEncoder) encode(val Value) ([]byte, error) {
+	w, err := e.wire(val)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// Inspect output such as <fn main> stays readable.
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(w); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func (e *valueEncoder) wire(val Value) (wireValue, error) {
+	switch v := val.(type) {
+	case nil, *Null:
+		return wireValue{Kind: "null"}, nil
+	case *Boolean:
+		return wireValue{Kind: "boolean", Value: json.RawMessage(strconv.FormatBool(v.Value))}, nil
+	case *Number:
+		return wireValue{Kind: "number", Value: encodeNumber(v.Value)}, nil
+	case *String:
+		text, err := json.Marshal(v.Value)
+		return wireValue{Kind: "string", Value: text}, err
+	case *Bytes:
+		data, err := json.Marshal(v.Value)
+		return wireValue{Kind: "bytes", Value: data}, err
+	}
+	if e.visiting[val] {
+		return wireValue{}, fmt.Errorf("cannot encode %s: it contains itself", val.Type())
+	}
+	e.visiting[val] = true
+	defer delete(e.visiting, val)
+	switch v := val.(type) {
+	case *Array:
+		elements, err := e.list(v.Elements)
+		return wireValue{Kind: "array", Elements: elements}, err
+	case *Set:
+		elements, err := e.list(v.Elements())
+		return wireValue{Kind: "set", Elements: elements}, err
+	case *Object:
+		keys := v.Keys()
+		properties := make(map[string]json.RawMessage, len(keys))
+		for _, key := range keys {
+			data, err := e.encode(v.Properties[key])
+			if err != nil {
+				return wireValue{}, fmt.Errorf("property %s: %w", key, err)
+			}
+			properties[key] = data
+		}
+		return wireValue{Kind: "object", Properties: properties, Keys: keys}, nil
+	case *ErrorValue:
+		w := wireValue{Kind: "error", Message: &v.Message}
+		if v.Cause != nil {
+			cause, err := e.encode(v.Cause)
+			if err != nil {
+				return wireValue{}, fmt.Errorf("cause: %w", err)
+			}
+			w.Cause = cause
+		}
+		return w, nil
+	case *StructInstance:
+		fields, err := e.fields(v.Definition.Fields, v.Fields)
+		return wireValue{Kind: "struct", Type: v.Definition.Name, Fields: fields}, err
+	case *ClassInstance:
+		fields, err := e.fields(v.Definition.Fields, v.Fields)
+		return wireValue{Kind: "class", Type: v.Definition.Name, Fields: fields}, err
+	case *EnumInstance:
+		fields, err := e.fields(v.Order, v.Fields)
+		return wireValue{Kind: "enum", Type: v.Enum.Name, Case: v.Case, Fields: fields}, err
+	default:
+		return wireValue{Kind: "opaque", Type: val.Type(), Inspect: val.Inspect()}, nil
+	}
+}
+
+func (e *valueEncoder) list(values []Value) ([]json.RawMessage, error) {
+	elements := make([]json.RawMessage, len(values))
+	for i, el := range values {
+		data, err := e.encode(el)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		elements[i] = data
+	}
+	return elements, nil
+}
+
+// fields encodes values in the order fieldOrder gives them.
+func (e *valueEncoder) fields(order []string, values map[string]Value) ([]wireField, error) {
+	names := fieldOrder(order, values)
+	fields := make([]wireField, 0, len(names))
+	for _, name := range names {
+		val := values[name]
+		data, err := e.encode(val)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		fields = append(fields, wireField{Name: name, Value: data})
+	}
+	return fields, nil
+}
+
+func encodeNumber(v float64) json.RawMessage {
+	switch {
+	case math.IsNaN(v):
+		return json.RawMessage(`"NaN"`)
+	case math.IsInf(v, 1):
+		return json.RawMessage(`"Infinity"`)
+	case math.IsInf(v, -1):
+		return json.RawMessage(`"-Infinity"`)
+	}
+	return json.RawMessage(strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+type valueDecoder struct {
+	env *Environment
+	// detached holds the definitions made up for types env does not
+	// define, so that values of one type share a definition.
+	detached map[string]Value
+}
+
+func (d *valueDecoder) decode(data []byte) (Value, error) {
+	var w wireValue
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&w); err != nil {
+		return nil, fmt.Errorf("decode value: %w", err)
+	}
+	switch w.Kind {
+	case "null":
+		return NullValue, nil
+	case "boolean":
+		var b bool
+		if err := json.Unmarshal(w.Value, &b); err != nil {
+			return nil, fmt.Errorf("decode boolean: %w", err)
+		}
+		return NewBoolean(b), nil
+	case "number":
+		return decodeNumber(w.Value)
+	case "string":
+		var s string
+		if err := json.Unmarshal(w.Value, &s); err != nil {
+			return nil, fmt.Errorf("decode string: %w", err)
+		}
+		return NewString(s), nil
+	case "bytes":
+		var data []byte
+		if err := json.Unmarshal(w.Value, &data); err != nil {
+			return nil, fmt.Errorf("decode bytes: %w", err)
+		}
+		return NewBytes(data), nil
+	case "array":
+		elements, err := d.list(w.Elements)
+		if err != nil {
+			return nil, err
+		}
+		return &Array{Elements: elements}, nil
+	case "set":
+		elements, err := d.list(w.Elements)
+		if err != nil {
+			return nil, err
+		}
+		return NewSet(elements...), nil
+	case "object":
+		props := make([]Property, 0, len(w.Properties))
+		order := make([]string, 0, len(w.Keys))
+		listed := make(map[string]bool, len(w.Keys))
+		for _, key := range w.Keys {
+			if !listed[key] {
+				listed[key] = true
+				order = append(order, key)
+			}
+		}
+		for _, key := range fieldOrder(order, w.Properties) {
+			val, err := d.decode(w.Properties[key])
+			if err != nil {
+				return nil, fmt.Errorf("property %s: %w", key, err)
+			}
+			props = append(props, Property{key, val})
+		}
+		return NewObject(props...), nil
+	case "error":
+		if w.Message == nil {
+			return nil, errors.New("decode error: missing message")
+		}
+		value := &ErrorValue{Message: *w.Message}
+		if len(w.Cause) > 0 {
+			cause, err := d.decode(w.Cause)
+			if err != nil {
+				return nil, fmt.Errorf("cause: %w", err)
+			}
+			value.Cause = cause
+		}
+		return value, nil
+	case "struct", "class", "enum":
+		return d.instance(w)
+	case "opaque":
+		return nil, fmt.Errorf("cannot decode opaque %s value", w.Type)
+	default:
+		return nil, fmt.Errorf("decode value: unknown kind %q", w.Kind)
+	}
+}
+
+func (d *valueDecoder) list(data []json.RawMessage) ([]Value, error) {
+	values := make([]Value, len(data))
+	for i, el := range data {
+		val, err := d.decode(el)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		values[i] = val
+	}
+	return values, nil
+}
+
+func (d *valueDecoder) instance(w wireValue) (Value, error) {
+	if w.Type == "" {
+		return nil, fmt.Errorf("decode %s: missing type", w.Kind)
+	}
+	names := make([]string, len(w.Fields))
+	fields := make(map[string]Value, len(w.Fields))
+	for i, field := range w.Fields {
+		val, err := d.decode(field.Value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		names[i] = field.Name
+		fields[field.Name] = val
+	}
+	switch w.Kind {
+	case "struct":
+		def, ok := d.lookup(w.Type).(*StructType)
+		if !ok {
+			def = d.detach(w.Type, &StructType{Name: w.Type, Fields: names}).(*StructType)
+		}
+		return &StructInstance{Definition: def, Fields: fields}, nil
+	case "class":
+		def, ok := d.lookup(w.Type).(*ClassType)
+		if !ok {
+			def = d.detach(w.Type, &ClassType{Name: w.Type, Fields: names}).(*ClassType)
+		}
+		return &ClassInstance{Definition: def, Fields: fields}, nil
+	default:
+		if w.Case == "" {
+			return nil, fmt.Errorf("decode enum %s: missing case", w.Type)
+		}
+		def, ok := d.lookup(w.Type).(*EnumType)
+		if ok {
+			if _, known := def.Cases[w.Case]; !known {
+				return nil, fmt.Errorf("decode enum %s: unknown case %s", w.Type, w.Case)
+			}
+		} else {
+			def = d.detach(w.Type, &EnumType{Name: w.Type, Cases: map[string][]string{}}).(*EnumType)
+			def.Cases[w.Case] = names
+		}
+		return &EnumInstance{Enum: def, Case: w.Case, Fields: fields, Order: names}, nil
+	}
+}
+
+func (d *valueDecoder) lookup(name string) Value {
+	if d.env == nil {
+		return nil
+	}
+	val, _ := d.env.Get(name)
+	return val
+}
+
+func (d *valueDecoder) detach(name string, def Value) Value {
+	if existing, ok := d.detached[name]; ok && existing.Type() == def.Type() {
+		return existing
+	}
+	d.detached[name] = def
+	return def
+}
+
+func decodeNumber(data json.RawMessage) (Value, error) {
+	var text string
+	if json.Unmarshal(data, &text) == nil {
+		switch text {
+		case "NaN":
+			return NewNumber(math.NaN()), nil
+		case "Infinity":
+			return NewNumber(math.Inf(1)), nil
+		case "-Infinity":
+			return NewNumber(math.Inf(-1)), nil
+		}
+		return nil, fmt.Errorf("decode number: unknown value %q", text)
+	}
+	v, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return nil, fmt.Errorf("decode number: %w", err)
+	}
+	return NewNumber(v), nil
+}
@@ -0,0 +1,92 @@
+package runtime
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestMarshalValueRoundTrips(t *testing.T) {
+	rt := New()
+	val, err := rt.Run(parseProgram(t, `
+struct Point(x: Number, y: Number) {}
+enum Status {
+    Ready;
+    Failed(reason: String, code: Number);
+}
+[Point(1, -2), Status.Failed("disk", 7), Status.Ready(), {b: #{1, "one"}, a: null}, [true, 0.5], bytes([104, 105, 0])];
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := MarshalValue(val)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	for _, want := range []string{
+		`{"kind":"struct","type":"Point","fields":[{"name":"x","value":{"kind":"number","value":1}},{"name":"y","value":{"kind":"number","value":-2}}]}`,
+		`{"kind":"enum","type":"Status","case":"Failed","fields":[{"name":"reason","value":{"kind":"string","value":"disk"}},{"name":"code","value":{"kind":"number","value":7}}]}`,
+		`{"kind":"object","properties":{"a":{"kind":"null"},"b":{"kind":"set","elements":[{"kind":"number","value":1},{"kind":"string","value":"one"}]}},"keys":["b","a"]}`,
+		`{"kind":"bytes","value":"aGkA"}`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("expected %s in %s", want, data)
+		}
+	}
+	again, err := MarshalValue(val)
+	if err != nil || string(again) != string(data) {
+		t.Fatalf("expected a stable encoding, got %s then %s (%v)", data, again, err)
+	}
+
+	decoded, err := UnmarshalValue(data, rt.Environment())
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if decoded.Inspect() != val.Inspect() {
+		t.Fatalf("unexpected round trip %s, want %s", decoded.Inspect(), val.Inspect())
+	}
+	point := decoded.(*Array).Elements[0].(*StructInstance)
+	if def, _ := rt.Environment().Get("Point"); point.Definition != def {
+		t.Fatalf("expected the decoded struct to use the Point in scope")
+	}
+
+	detached, err := UnmarshalValue(data, nil)
+	if err != nil {
+		t.Fatalf("unmarshal without an environment: %v", err)
+	}
+	if detached.Inspect() != val.Inspect() {
+		t.Fatalf("unexpected detached round trip %s, want %s", detached.Inspect(), val.Inspect())
+	}
+}
+
+func TestMarshalValueEdgeCases(t *testing.T) {
+	wrapped := &ErrorValue{Message: "outer", Cause: &ErrorValue{Message: "inner"}}
+	numbers := &Array{Elements: []Value{NewNumber(math.NaN()), NewNumber(math.Inf(-1)), NewNumber(math.Copysign(0, -1))}}
+	for _, val := range []Value{wrapped, numbers} {
+		data, err := MarshalValue(val)
+		if err != nil {
+			t.Fatalf("marshal %s: %v", val.Inspect(), err)
+		}
+		decoded, err := UnmarshalValue(data, nil)
+		if err != nil {
+			t.Fatalf("unmarshal %s: %v", data, err)
+		}
+		if decoded.Inspect() != val.Inspect() {
+			t.Fatalf("unexpected round trip %s, want %s", decoded.Inspect(), val.Inspect())
+		}
+	}
+
+	cyclic := &Array{}
+	cyclic.Elements = []Value{cyclic}
+	if _, err := MarshalValue(cyclic); err == nil || !strings.Contains(err.Error(), "contains itself") {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+
+	data, err := MarshalValue(&Function{Name: "main"})
+	if err != nil || string(data) != `{"kind":"opaque","type":"Function","inspect":"<fn main>"}` {
+		t.Fatalf("unexpected function encoding %s (%v)", data, err)
+	}
+	if _, err := UnmarshalValue(data, nil); err == nil {
+		t.Fatalf("expected opaque values to be rejected")
+	}
+}
@@ -0,0 +1,181 @@
+package runtime
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+)
+
+// VerifyError reports a chunk the verifier refused to run.
+type VerifyError struct {
+	// Offset is the instruction the problem was found at, or -1 when it
+	// concerns the chunk as a whole.
+	Offset  int
+	Message string
+}
+
+func (e *VerifyError) Error() string {
+	if e.Offset < 0 {
+		return "invalid chunk: " + e.Message
+	}
+	return fmt.Sprintf("invalid chunk at %04d: %s", e.Offset, e.Message)
+}
+
+// sandboxDisabled lists the module members a sandboxed runtime refuses to
+// call. The builtins check r.sandboxed themselves; the verifier uses the list
+// to refuse chunks that reach for them before anything runs.
+var sandboxDisabled = map[string][]string{
+	"os": {"exec", "setenv", "chdir"},
+	"fs": {"write", "tempFile", "tempDir"},
+}
+
+// VerifyChunk checks that chunk is safe to hand to RunChunk, which trusts the
+// chunks it is given. Chunks decoded from the bytecode cache or any other
+// outside source are verified by RunChunk itself; chunks from Compile are
+// well formed by construction.
+//
+// The VM has no jumps or operand stack: a chunk is a sequence of OpEvalItem
+// instructions, each naming a program item, ended by OpReturn. The verifier
+// checks that every opcode is known and complete, that item indices are in
+// range, and that the code ends with its only OpReturn, so execution can
+// neither run off the end nor skip items. It then walks every item, refusing
+// missing nodes the evaluator would trip over and, when the runtime is
+// sandboxed or its policy trims modules, member references such as os.exec
+// that the runtime would refuse. A declaration that shadows a module name is
+// not told apart from the module.
+func (r *Runtime) VerifyChunk(chunk *Chunk) error {
+	if chunk == nil || len(chunk.code) == 0 {
+		return &VerifyError{Offset: -1, Message: "no instructions"}
+	}
+	referenced := make([]bool, len(chunk.items))
+	ip := 0
+	for ip < len(chunk.code) {
+		op := OpCode(chunk.code[ip])
+		switch op {
+		case OpEvalItem:
+			if ip+2 >= len(chunk.code) {
+				return &VerifyError{Offset: ip, Message: "truncated OpEvalItem"}
+			}
+			index := int(binary.BigEndian.Uint16(chunk.code[ip+1 : ip+3]))
+			if index >= len(chunk.items) {
+				return &VerifyError{Offset: ip, Message: fmt.Sprintf("program item %d out of range (chunk has %d)", index, len(chunk.items))}
+			}
+			referenced[index] = true
+			ip += 3
+		case OpReturn:
+			if ip != len(chunk.code)-1 {
+				return &VerifyError{Offset: ip, Message: "OpReturn before the end of the code"}
+			}
+			ip++
+		default:
+			return &VerifyError{Offset: ip, Message: fmt.Sprintf("unknown opcode %d", op)}
+		}
+	}
+	if OpCode(chunk.code[len(chunk.code)-1]) != OpReturn {
+		return &VerifyError{Offset: len(chunk.code) - 1, Message: "code does not end with OpReturn"}
+	}
+	forbidden := r.forbiddenMember()
+	for i, item := range chunk.items {
+		if !referenced[i] {
+			return &VerifyError{Offset: -1, Message: fmt.Sprintf("program item %d is never evaluated", i)}
+		}
+		if err := verifyNode(reflect.ValueOf(item), forbidden); err != "" {
+			return &VerifyError{Offset: -1, Message: fmt.Sprintf("program item %d: %s", i, err)}
+		}
+	}
+	return nil
+}
+
+// forbiddenMember returns a report of whether code may not reference a
+// module member in this runtime, or nil when nothing is forbidden.
+func (r *Runtime) forbiddenMember() func(module, member string) bool {
+	// A policy that lists some members of a module removes the rest.
+	listed := make(map[string][]string)
+	whole := make(map[string]bool)
+	for _, name := range r.policy.Builtins {
+		if module, member, ok := strings.Cut(name, "."); ok {
+			listed[module] = append(listed[module], member)
+		} else {
+			whole[name] = true
+		}
+	}
+	for module := range whole {
+		delete(listed, module)
+	}
+	if !r.sandboxed && len(listed) == 0 {
+		return nil
+	}
+	sandboxed := r.sandboxed
+	return func(module, member string) bool {
+		if sandboxed && slices.Contains(sandboxDisabled[module], member) {
+			return true
+		}
+		allowed, trimmed := listed[module]
+		return trimmed && !slices.Contains(allowed, member)
+	}
+}
+
+var (
+	identifierType = reflect.TypeOf(&ast.Identifier{})
+	memberType     = reflect.TypeOf(&ast.MemberExpression{})
+)
+
+// verifyNode walks v, an AST node or a field of one, and returns a
+// description of the first problem it finds. Nodes are walked by reflection
+// so that every type gob can decode is covered without a case per type.
+// Optional children are nil pointers, which the evaluator checks for;
+// elements of statement, expression, and item lists never are.
+func verifyNode(v reflect.Value, forbidden func(module, member string) bool) string {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return ""
+		}
+		return verifyNode(v.Elem(), forbidden)
+	case reflect.Pointer:
+		if v.IsNil() {
+			return ""
+		}
+		if v.Type() == memberType && forbidden != nil {
+			member := v.Interface().(*ast.MemberExpression)
+			if id, ok := member.Object.(*ast.Identifier); ok && forbidden(id.Name, member.Property) {
+				return fmt.Sprintf("%s.%s is not available in this runtime", id.Name, member.Property)
+			}
+		}
+		if v.Type() == identifierType {
+			return ""
+		}
+		return verifyNode(v.Elem(), forbidden)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			if err := verifyNode(v.Field(i), forbidden); err != "" {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			elem := v.Index(i)
+			if elem.Kind() == reflect.Interface && elem.IsNil() {
+				return fmt.Sprintf("missing %s in a list", elem.Type().Name())
+			}
+			if err := verifyNode(elem, forbidden); err != "" {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := verifyNode(iter.Value(), forbidden); err != "" {
+				return err
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,298 @@
+// Package selfupdate replaces the running selene executable with a published
+// release. Each release channel is described by a JSON manifest listing one
+// binary per platform with its SHA-256 checksum. A manifest is only trusted
+// once its Ed25519 signature verifies against the release key built into the
+// binary, and a download is only installed once its checksum matches. Both
+// are fetched over https alone.
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// DefaultEndpoint serves one manifest per channel, such as stable.json.
+const DefaultEndpoint = "https://github.com/cybellereaper/selenelang/releases/download/channels"
+
+// Channels lists the release channels in the order the CLI documents them.
+var Channels = []string{"stable", "nightly"}
+
+// maxManifestSize bounds how much of a manifest response is read, and
+// maxSignatureSize how much of its signature.
+const (
+	maxManifestSize  = 1 << 20
+	maxSignatureSize = 1 << 10
+)
+
+// releaseKey is the base64-encoded Ed25519 public key channel manifests are
+// signed with. Release builds set it with
+// -ldflags "-X github.com/cybellereaper/selenelang/internal/selfupdate.releaseKey=<key>";
+// a build without it cannot update itself.
+var releaseKey string
+
+// Manifest describes the current release on a channel.
+type Manifest struct {
+	Version string  `json:"version"`
+	Channel string  `json:"channel"`
+	Assets  []Asset `json:"assets"`
+}
+
+// Asset is one platform binary. URL may be relative to the manifest.
+type Asset struct {
+	OS     string `json:"os"`
+	Arch   string `json:"arch"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// Updater fetches manifests and installs releases. The zero value uses
+// DefaultEndpoint, http.DefaultClient, the release key built into the
+// binary, and the platform selene was built for.
+type Updater struct {
+	Endpoint string
+	Client   *http.Client
+	// PublicKey verifies manifest signatures in place of the release key.
+	PublicKey ed25519.PublicKey
+	GOOS      string
+	GOARCH    string
+}
+
+// Latest fetches the manifest for channel and checks its signature, which is
+// served next to it with a .sig suffix as the base64-encoded Ed25519
+// signature of the manifest's bytes.
+func (u *Updater) Latest(ctx context.Context, channel string) (Manifest, error) {
+	if !slices.Contains(Channels, channel) {
+		return Manifest{}, fmt.Errorf("unknown release channel %q (expected %s)", channel, strings.Join(Channels, " or "))
+	}
+	key, err := u.publicKey()
+	if err != nil {
+		return Manifest{}, err
+	}
+	manifestURL := u.manifestURL(channel)
+	data, err := u.fetch(ctx, manifestURL, maxManifestSize)
+	if err != nil {
+		return Manifest{}, err
+	}
+	encoded, err := u.fetch(ctx, manifestURL+".sig", maxSignatureSize)
+	if err != nil {
+		return Manifest{}, err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil || !ed25519.Verify(key, data, sig) {
+		return Manifest{}, fmt.Errorf("%s is not signed with the release key", manifestURL)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("decode %s: %w", manifestURL, err)
+	}
+	if manifest.Version == "" {
+		return Manifest{}, fmt.Errorf("%s does not name a version", manifestURL)
+	}
+	if manifest.Channel != "" && manifest.Channel != channel {
+		return Manifest{}, fmt.Errorf("%s describes the %s channel, not %s", manifestURL, manifest.Channel, channel)
+	}
+	for i, asset := range manifest.Assets {
+		resolved, err := resolveURL(manifestURL, asset.URL)
+		if err != nil {
+			return Manifest{}, err
+		}
+		manifest.Assets[i].URL = resolved
+	}
+	return manifest, nil
+}
+
+// Asset returns the binary in m built for the updater's platform.
+func (u *Updater) Asset(m Manifest) (Asset, error) {
+	goos, goarch := u.platform()
+	for _, asset := range m.Assets {
+		if asset.OS == goos && asset.Arch == goarch {
+			return asset, nil
+		}
+	}
+	return Asset{}, fmt.Errorf("release %s has no binary for %s/%s", m.Version, goos, goarch)
+}
+
+// Install downloads asset, verifies its checksum, and replaces the file at
+// target with it. The new binary is written next to target and renamed over
+// it, so target is either the old or the new executable, never a partial one.
+func (u *Updater) Install(ctx context.Context, asset Asset, target string) error {
+	want, err := hex.DecodeString(asset.SHA256)
+	if err != nil || len(want) != sha256.Size {
+		return fmt.Errorf("release binary %s has no valid sha256 checksum", asset.URL)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	body, err := u.get(ctx, asset.URL)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".update-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	installed := false
+	defer func() {
+		if !installed {
+			os.Remove(tmpName)
+		}
+	}()
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("download %s: %w", asset.URL, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if got := hash.Sum(nil); !bytes.Equal(got, want) {
+		return fmt.Errorf("checksum mismatch for %s: got %x, want %s", asset.URL, got, asset.SHA256)
+	}
+	if err := os.Chmod(tmpName, info.Mode().Perm()|0o111); err != nil {
+		return err
+	}
+	if err := replace(tmpName, target); err != nil {
+		return err
+	}
+	installed = true
+	return nil
+}
+
+// Executable returns the path of the running binary with symlinks resolved,
+// which is the file Install should replace.
+func Executable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+// replace renames src over dst. Windows refuses to overwrite a running
+// executable but allows renaming it, so the old binary is moved aside first
+// and left for the next update to remove.
+func replace(src, dst string) error {
+	if runtime.GOOS != "windows" {
+		return os.Rename(src, dst)
+	}
+	old := dst + ".old"
+	_ = os.Remove(old)
+	if err := os.Rename(dst, old); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		_ = os.Rename(old, dst)
+		return err
+	}
+	return nil
+}
+
+// publicKey returns the key manifests must be signed with.
+func (u *Updater) publicKey() (ed25519.PublicKey, error) {
+	if u.PublicKey != nil {
+		return u.PublicKey, nil
+	}
+	if releaseKey == "" {
+		return nil, errors.New("this selene build has no release signing key; install releases manually")
+	}
+	key, err := base64.StdEncoding.DecodeString(releaseKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("this selene build has an invalid release signing key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// fetch reads the body at target, failing if it is longer than limit.
+func (u *Updater) fetch(ctx context.Context, target string, limit int64) ([]byte, error) {
+	body, err := u.get(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", target, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("fetch %s: response is larger than %d bytes", target, limit)
+	}
+	return data, nil
+}
+
+// get requests target, which must be an https URL, so a mirror or the
+// network path cannot swap the manifest or binary in transit.
+func (u *Updater) get(ctx context.Context, target string) (io.ReadCloser, error) {
+	if parsed, err := url.Parse(target); err != nil || parsed.Scheme != "https" {
+		return nil, fmt.Errorf("refusing to fetch %s: updates are only downloaded over https", target)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := u.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetch %s: %s", target, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func (u *Updater) manifestURL(channel string) string {
+	endpoint := u.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	return strings.TrimSuffix(endpoint, "/") + "/" + channel + ".json"
+}
+
+func (u *Updater) platform() (string, string) {
+	goos, goarch := u.GOOS, u.GOARCH
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+	if goarch == "" {
+		goarch = runtime.GOARCH
+	}
+	return goos, goarch
+}
+
+func resolveURL(base, ref string) (string, error) {
+	if ref == "" {
+		return "", errors.New("release manifest lists a binary without a url")
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("release manifest url %q: %w", ref, err)
+	}
+	return baseURL.ResolveReference(refURL).String(), nil
+}
@@ -0,0 +1,144 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpdaterInstallsVerifiedRelease(t *testing.T) {
+	binary := []byte("#!/bin/sh\necho new selene\n")
+	sum := sha256.Sum256(binary)
+	checksum := hex.EncodeToString(sum[:])
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	manifest := fmt.Sprintf(`{"version":"1.2.0","channel":"stable","assets":[
+		{"os":"linux","arch":"amd64","url":"bin/selene","sha256":%q},
+		{"os":"plan9","arch":"amd64","url":"bin/selene","sha256":"00"}]}`, checksum)
+	server := releaseServer(t, manifest, signManifest(private, manifest), binary)
+
+	updater := &Updater{Endpoint: server.URL + "/", Client: server.Client(), PublicKey: public, GOOS: "linux", GOARCH: "amd64"}
+	ctx := context.Background()
+	latest, err := updater.Latest(ctx, "stable")
+	if err != nil {
+		t.Fatalf("Latest returned error: %v", err)
+	}
+	if latest.Version != "1.2.0" {
+		t.Fatalf("expected version 1.2.0, got %q", latest.Version)
+	}
+	asset, err := updater.Asset(latest)
+	if err != nil {
+		t.Fatalf("Asset returned error: %v", err)
+	}
+	if asset.URL != server.URL+"/bin/selene" {
+		t.Fatalf("expected the asset url to resolve against the manifest, got %q", asset.URL)
+	}
+
+	target := filepath.Join(t.TempDir(), "selene")
+	if err := os.WriteFile(target, []byte("old"), 0o755); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	if err := updater.Install(ctx, asset, target); err != nil {
+		t.Fatalf("Install returned error: %v", err)
+	}
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("read target: %v", err)
+	}
+	if string(data) != string(binary) {
+		t.Fatalf("expected the new binary to be installed, got %q", data)
+	}
+
+	tampered := asset
+	tampered.SHA256 = strings.Repeat("0", 64)
+	if err := os.WriteFile(target, []byte("old"), 0o755); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	err = updater.Install(ctx, tampered, target)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "old" {
+		t.Fatalf("expected a failed install to leave the binary alone, got %q", data)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(target))
+	if len(entries) != 1 {
+		t.Fatalf("expected the partial download to be removed, found %d files", len(entries))
+	}
+
+	if _, err := updater.Latest(ctx, "beta"); err == nil {
+		t.Fatalf("expected an unknown channel to be rejected")
+	}
+	if _, err := (&Updater{GOOS: "darwin", GOARCH: "arm64"}).Asset(latest); err == nil {
+		t.Fatalf("expected a missing platform to be reported")
+	}
+}
+
+func TestUpdaterRejectsUnsignedManifestsAndPlainHTTP(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	manifest := `{"version":"1.2.0","channel":"stable","assets":[]}`
+	ctx := context.Background()
+
+	forged := releaseServer(t, manifest, signManifest(private, `{"version":"1.1.0"}`), nil)
+	updater := &Updater{Endpoint: forged.URL, Client: forged.Client(), PublicKey: public}
+	if _, err := updater.Latest(ctx, "stable"); err == nil || !strings.Contains(err.Error(), "not signed with the release key") {
+		t.Fatalf("expected a bad signature to be rejected, got %v", err)
+	}
+
+	signed := releaseServer(t, manifest, signManifest(private, manifest), nil)
+	if _, err := (&Updater{Endpoint: signed.URL, Client: signed.Client()}).Latest(ctx, "stable"); err == nil || !strings.Contains(err.Error(), "no release signing key") {
+		t.Fatalf("expected a build without a release key to refuse updates, got %v", err)
+	}
+
+	plain := httptest.NewServer(http.NotFoundHandler())
+	defer plain.Close()
+	updater = &Updater{Endpoint: plain.URL, Client: plain.Client(), PublicKey: public}
+	if _, err := updater.Latest(ctx, "stable"); err == nil || !strings.Contains(err.Error(), "only downloaded over https") {
+		t.Fatalf("expected a plain http endpoint to be rejected, got %v", err)
+	}
+	target := filepath.Join(t.TempDir(), "selene")
+	if err := os.WriteFile(target, []byte("old"), 0o755); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	asset := Asset{URL: plain.URL + "/selene", SHA256: strings.Repeat("0", 64)}
+	if err := updater.Install(ctx, asset, target); err == nil || !strings.Contains(err.Error(), "only downloaded over https") {
+		t.Fatalf("expected a plain http binary to be rejected, got %v", err)
+	}
+}
+
+// releaseServer serves a channel manifest for stable, its signature, and
+// binary at bin/selene over TLS.
+func releaseServer(t *testing.T, manifest, signature string, binary []byte) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stable.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, manifest)
+	})
+	mux.HandleFunc("/stable.json.sig", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, signature)
+	})
+	mux.HandleFunc("/bin/selene", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(binary)
+	})
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func signManifest(key ed25519.PrivateKey, manifest string) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(manifest)))
+}
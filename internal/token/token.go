@@ -12,16 +12,35 @@ type Token struct {
 	Literal string
 	Pos     Position
 	End     Position
+	// Doc holds the /// comments directly above the token, one line per
+	// comment with the marker and a single following space removed. A blank
+	// line or an ordinary comment in between discards them.
+	Doc string
+	// Heredoc holds the tag of a heredoc string, such as SQL for <<~SQL.
+	Heredoc string
+}
+
+// Comment is a // or /* */ comment, including doc comments. The lexer skips
+// comments rather than returning them as tokens and records them in source
+// order for tools that reproduce source, such as the formatter.
+type Comment struct {
+	// Text is the comment as written, markers included.
+	Text string
+	Pos  Position
+	End  Position
 }
 
 // Position describes a location within a source file.
 type Position struct {
+	// File names the source file, when the lexer was given its name.
+	File   string
 	Offset int
 	Line   int
 	Column int
 }
 
-// String returns the human-readable line and column for the position.
+// String returns the human-readable line and column for the position. The
+// file name is left to the caller, which usually reports it once.
 func (p Position) String() string {
 	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
@@ -53,11 +72,14 @@ const (
 	STAR_ASSIGN    Type = "*="
 	SLASH_ASSIGN   Type = "/="
 	PERCENT_ASSIGN Type = "%="
+	INCREMENT      Type = "++"
+	DECREMENT      Type = "--"
 	BANG           Type = "!"
 	QUESTION       Type = "?"
 	COLON          Type = ":"
 	ELVIS          Type = "?:"
 	SAFE_DOT       Type = "?."
+	SAFE_INDEX     Type = "?["
 	NON_NULL       Type = "!!"
 	AMPERSAND      Type = "&"
 	EQ             Type = "=="
@@ -71,17 +93,22 @@ const (
 	ARROW          Type = "=>"
 	IS             Type = "is"
 	NOT_IS         Type = "!is"
+	DOTDOT         Type = ".."
+	DOTDOT_EQ      Type = "..="
 
 	// Delimiters
-	COMMA     Type = ","
-	DOT       Type = "."
-	SEMICOLON Type = ";"
-	LPAREN    Type = "("
-	RPAREN    Type = ")"
-	LBRACE    Type = "{"
-	RBRACE    Type = "}"
-	LBRACKET  Type = "["
-	RBRACKET  Type = "]"
+	COMMA      Type = ","
+	DOT        Type = "."
+	SEMICOLON  Type = ";"
+	LPAREN     Type = "("
+	RPAREN     Type = ")"
+	LBRACE     Type = "{"
+	RBRACE     Type = "}"
+	LBRACKET   Type = "["
+	RBRACKET   Type = "]"
+	HASH_BRACE Type = "#{"
+	ELLIPSIS   Type = "..."
+	AT         Type = "@"
 )
 
 const (
@@ -101,9 +128,11 @@ const (
 	AS        Type = "as"
 	PACKAGE   Type = "package"
 	INTERFACE Type = "interface"
+	IMPL      Type = "impl"
 	IF        Type = "if"
 	ELSE      Type = "else"
 	WHILE     Type = "while"
+	DO        Type = "do"
 	FOR       Type = "for"
 	RETURN    Type = "return"
 	BREAK     Type = "break"
@@ -117,6 +146,8 @@ const (
 	EXT       Type = "ext"
 	CONDITION Type = "condition"
 	WHEN      Type = "when"
+	YIELD     Type = "yield"
+	IN        Type = "in"
 )
 
 var keywords = map[string]Type{
@@ -135,9 +166,11 @@ var keywords = map[string]Type{
 	"as":        AS,
 	"package":   PACKAGE,
 	"interface": INTERFACE,
+	"impl":      IMPL,
 	"if":        IF,
 	"else":      ELSE,
 	"while":     WHILE,
+	"do":        DO,
 	"for":       FOR,
 	"return":    RETURN,
 	"break":     BREAK,
@@ -155,6 +188,8 @@ var keywords = map[string]Type{
 	"ext":       EXT,
 	"condition": CONDITION,
 	"when":      WHEN,
+	"yield":     YIELD,
+	"in":        IN,
 }
 
 // LookupIdent identifies reserved keywords.
@@ -7,7 +7,7 @@ func TestLookupIdentRecognizesKeywords(t *testing.T) {
 		LET, VAR, FN, ASYNC, CONTRACT, RETURNS, CLASS, STRUCT, ENUM, MATCH,
 		MODULE, IMPORT, AS, PACKAGE, INTERFACE, IF, ELSE, WHILE, FOR, RETURN,
 		BREAK, CONTINUE, AWAIT, TRY, CATCH, FINALLY, THROW, USING, EXT,
-		CONDITION, WHEN,
+		CONDITION, WHEN, YIELD, IN,
 	}
 	for _, kw := range keywords {
 		t.Run(string(kw), func(t *testing.T) {
@@ -0,0 +1,147 @@
+package toolchain
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/cybellereaper/selenelang/internal/project"
+	"github.com/cybellereaper/selenelang/internal/runtime"
+)
+
+// projectImporter resolves imports under the manifest module path from the
+// project's source directory: `import myproject.utils.math;` loads
+// src/utils/math.selene, or every .selene file directly inside src/utils/math
+// when that is a directory. Each module is evaluated once and cached, and
+// segments past the module name select its exports.
+type projectImporter struct {
+	rt     *runtime.Runtime
+	root   string
+	prefix []string
+
+	mu      sync.Mutex
+	modules map[string]*runtime.Module
+	loading []string
+}
+
+func newProjectImporter(rt *runtime.Runtime, root, modulePath string) *projectImporter {
+	return &projectImporter{
+		rt:      rt,
+		root:    root,
+		prefix:  strings.Split(modulePath, "/"),
+		modules: make(map[string]*runtime.Module),
+	}
+}
+
+// Import implements runtime.Importer.
+func (p *projectImporter) Import(path []string) (runtime.Value, bool, error) {
+	if len(path) <= len(p.prefix) || !slices.Equal(path[:len(p.prefix)], p.prefix) {
+		return nil, false, nil
+	}
+	rest := path[len(p.prefix):]
+	for n := len(rest); n > 0; n-- {
+		source, files, err := p.sources(rest[:n])
+		if err != nil {
+			return nil, false, err
+		}
+		if files == nil {
+			continue
+		}
+		module, err := p.load(source, rest[n-1], files)
+		if err != nil {
+			return nil, false, err
+		}
+		var val runtime.Value = module
+		for _, member := range rest[n:] {
+			mod, ok := val.(*runtime.Module)
+			if !ok {
+				return nil, false, fmt.Errorf("%s: %s is not a module", source, member)
+			}
+			if val, ok = mod.Exports[member]; !ok {
+				return nil, false, fmt.Errorf("%s: module %s has no export %s", source, mod.Name, member)
+			}
+		}
+		return val, true, nil
+	}
+	return nil, false, nil
+}
+
+// sources returns the project-relative name of the module at segments and
+// the files it consists of, or nil files when no such module exists.
+func (p *projectImporter) sources(segments []string) (string, []string, error) {
+	rel := filepath.Join(append([]string{project.SourceDirectory}, segments...)...)
+	file, err := project.ResolveUnderRoot(p.root, rel+".selene")
+	if err != nil {
+		return "", nil, err
+	}
+	if info, err := os.Stat(file); err == nil && !info.IsDir() {
+		return filepath.ToSlash(rel + ".selene"), []string{file}, nil
+	}
+	dir, err := project.ResolveUnderRoot(p.root, rel)
+	if err != nil {
+		return "", nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil, nil
+		}
+		return "", nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".selene" {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return filepath.ToSlash(rel), files, nil
+}
+
+func (p *projectImporter) load(source, name string, files []string) (*runtime.Module, error) {
+	p.mu.Lock()
+	if module, ok := p.modules[source]; ok {
+		p.mu.Unlock()
+		return module, nil
+	}
+	if i := slices.Index(p.loading, source); i >= 0 {
+		chain := append(slices.Clone(p.loading[i:]), source)
+		p.mu.Unlock()
+		return nil, fmt.Errorf("import cycle: %s", strings.Join(chain, " -> "))
+	}
+	p.loading = append(p.loading, source)
+	p.mu.Unlock()
+
+	module, err := p.evaluate(name, files)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i := slices.Index(p.loading, source); i >= 0 {
+		p.loading = slices.Delete(p.loading, i, i+1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.modules[source] = module
+	return module, nil
+}
+
+func (p *projectImporter) evaluate(name string, files []string) (*runtime.Module, error) {
+	moduleFiles := make([]runtime.ModuleFile, 0, len(files))
+	for _, file := range files {
+		rel, err := filepath.Rel(p.root, file)
+		if err != nil {
+			return nil, err
+		}
+		program, _, err := ParseFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.ToSlash(rel), err)
+		}
+		moduleFiles = append(moduleFiles, runtime.ModuleFile{Name: filepath.ToSlash(rel), Program: program})
+	}
+	return p.rt.LoadModule(name, moduleFiles)
+}
@@ -4,17 +4,18 @@ package toolchain
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
-	"maps"
 	"os"
 	"path/filepath"
-	"slices"
-	"sort"
 	"strings"
+	"time"
 
 	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/cache"
 	"github.com/cybellereaper/selenelang/internal/lexer"
 	"github.com/cybellereaper/selenelang/internal/parser"
+	"github.com/cybellereaper/selenelang/internal/perf"
 	"github.com/cybellereaper/selenelang/internal/project"
 	"github.com/cybellereaper/selenelang/internal/runtime"
 )
@@ -24,52 +25,175 @@ import (
 // and auxiliary tooling) can reuse the same entry point without duplicating the
 // lexing/parsing pipeline.
 func ParseFile(filename string) (*ast.Program, string, error) {
+	_, source, err := readSource(filename)
+	if err != nil {
+		return nil, "", err
+	}
+	program, err := parseSource(filename, source)
+	if err != nil {
+		return nil, "", err
+	}
+	return program, source, nil
+}
+
+// CompileFile parses and compiles a Selene source file to bytecode. When the
+// file belongs to a Selene project the compiled chunk is stored under
+// .selene-cache/bytecode keyed by the file name, which the chunk's source
+// positions record, the source contents, compiler version, and optimization
+// level, and later calls reuse it without lexing or parsing the file again.
+// Cache failures never surface as errors; the file is simply compiled afresh.
+func CompileFile(rt *runtime.Runtime, filename string) (*runtime.Chunk, error) {
+	root, source, err := readSource(filename)
+	if err != nil {
+		return nil, err
+	}
+	cacheable := hasManifest(root)
+	key := cache.Key([]byte(runtime.CompilerVersion), []byte{byte(rt.OptimizationLevel())}, []byte(filename), []byte(source))
+	if cacheable {
+		if data, err := cache.Read(root, bytecodeNamespace, key); err == nil {
+			chunk := &runtime.Chunk{}
+			if err := chunk.UnmarshalBinary(data); err == nil {
+				perf.Hit(perf.BytecodeCache)
+				return chunk, nil
+			}
+		}
+		perf.Miss(perf.BytecodeCache)
+	}
+	program, err := parseSource(filename, source)
+	if err != nil {
+		return nil, err
+	}
+	compileStart := time.Now()
+	chunk, err := rt.Compile(program)
+	perf.Since(perf.PhaseCompile, compileStart)
+	if err != nil {
+		return nil, err
+	}
+	if cacheable {
+		if data, err := chunk.MarshalBinary(); err == nil {
+			_ = cache.Write(root, bytecodeNamespace, key, data)
+		}
+	}
+	return chunk, nil
+}
+
+const bytecodeNamespace = "bytecode"
+
+// StreamThreshold is the size in bytes above which ExecuteFile lexes a
+// source file straight from disk instead of reading it into memory first.
+const StreamThreshold = 8 << 20
+
+func readSource(filename string) (string, string, error) {
+	root, rel, err := locateSource(filename)
+	if err != nil {
+		return "", "", err
+	}
+	resolved, err := project.ResolveUnderRoot(root, rel)
+	if err != nil {
+		return "", "", err
+	}
+	content, err := project.ReadFile(root, rel)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read %s: %w", resolved, err)
+	}
+	return root, string(content), nil
+}
+
+// locateSource returns the project root containing filename and the path of
+// filename relative to it.
+func locateSource(filename string) (string, string, error) {
 	absPath, err := filepath.Abs(filename)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to resolve %s: %w", filename, err)
+		return "", "", fmt.Errorf("failed to resolve %s: %w", filename, err)
 	}
 	root, err := project.FindRoot(filepath.Dir(absPath))
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
 			root = filepath.Dir(absPath)
 		} else {
-			return nil, "", fmt.Errorf("unable to determine Selene project root for %s: %w", absPath, err)
+			return "", "", fmt.Errorf("unable to determine Selene project root for %s: %w", absPath, err)
 		}
 	}
 	rel, err := filepath.Rel(root, absPath)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to relativise %s: %w", absPath, err)
+		return "", "", fmt.Errorf("failed to relativise %s: %w", absPath, err)
 	}
 	rel = filepath.Clean(rel)
 	if rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
-		return nil, "", fmt.Errorf("refusing to read file outside project root: %s", absPath)
+		return "", "", fmt.Errorf("refusing to read file outside project root: %s", absPath)
 	}
-	resolved, err := project.ResolveUnderRoot(root, rel)
+	return root, rel, nil
+}
+
+// parseFileStreaming parses filename like ParseFile, except that a file larger
+// than StreamThreshold is lexed as it is read so its text is never held in
+// memory whole.
+func parseFileStreaming(filename string) (*ast.Program, error) {
+	root, rel, err := locateSource(filename)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
-	content, err := project.ReadFile(root, rel)
+	file, err := project.Open(root, rel)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to read %s: %w", resolved, err)
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	if info.Size() <= StreamThreshold {
+		content, err := io.ReadAll(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+		return parseSource(filename, string(content))
 	}
-	source := string(content)
+	defer perf.Since(perf.PhaseParse, time.Now())
+	l := lexer.NewReader(file)
+	l.SetFile(filename)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if err := l.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, fmt.Errorf("parse error:\n%s", strings.Join(errs, "\n"))
+	}
+	return program, nil
+}
+
+func parseSource(filename, source string) (*ast.Program, error) {
+	defer perf.Since(perf.PhaseParse, time.Now())
 	l := lexer.New(source)
+	l.SetFile(filename)
 	p := parser.New(l)
 	program := p.ParseProgram()
 	if errs := p.Errors(); len(errs) > 0 {
-		return nil, "", fmt.Errorf("parse error:\n%s", strings.Join(errs, "\n"))
+		return nil, fmt.Errorf("parse error:\n%s", strings.Join(errs, "\n"))
 	}
-	return program, source, nil
+	return program, nil
+}
+
+func hasManifest(root string) bool {
+	path, err := project.ResolveUnderRoot(root, project.ManifestName)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
 }
 
 // ExecuteFile parses and runs a Selene source file within the provided runtime.
 // It is a light wrapper around ParseFile and runtime.Run that ensures consistent
-// error formatting across tooling entry points.
+// error formatting across tooling entry points. Files above StreamThreshold are
+// lexed straight from disk.
 func ExecuteFile(rt *runtime.Runtime, filename string) error {
-	program, _, err := ParseFile(filename)
+	program, err := parseFileStreaming(filename)
 	if err != nil {
 		return err
 	}
+	defer perf.Since(perf.PhaseRun, time.Now())
 	if _, err := rt.Run(program); err != nil {
 		return fmt.Errorf("runtime error: %w", err)
 	}
@@ -78,8 +202,10 @@ func ExecuteFile(rt *runtime.Runtime, filename string) error {
 
 // LoadDependencies wires vendored modules recorded in selene.toml/selene.lock
 // into the provided runtime so that imports work when evaluating a standalone
-// entry point. The logic mirrors the CLI implementation but is exposed as a
-// reusable helper for tests and additional tooling commands.
+// entry point. Inside a workspace the lockfile and vendor directory of the
+// workspace root are used. When the manifest names a module path, imports
+// beneath it are resolved from the project's src directory on first use. The logic mirrors the CLI implementation but is
+// exposed as a reusable helper for tests and additional tooling commands.
 func LoadDependencies(rt *runtime.Runtime, entry string) error {
 	abs, err := filepath.Abs(entry)
 	if err != nil {
@@ -96,21 +222,27 @@ func LoadDependencies(rt *runtime.Runtime, entry string) error {
 	if err != nil {
 		return err
 	}
+	if manifest.Project.Module != "" {
+		rt.SetImporter(newProjectImporter(rt, root, manifest.Project.Module))
+	}
 	if len(manifest.Dependencies) == 0 {
 		return nil
 	}
-	lockfile, err := project.LoadLockfile(root)
+	lockRoot, err := project.FindWorkspaceRoot(root)
+	if err != nil {
+		return err
+	}
+	lockfile, err := project.LoadLockfile(lockRoot)
 	if err != nil {
 		return err
 	}
 	modules := project.SortedModules(manifest.Dependencies)
 	for _, module := range modules {
-		dep := manifest.Dependencies[module]
 		locked, ok := lockfile.Lookup(module)
 		if !ok {
 			return fmt.Errorf("dependency %s is not recorded in selene.lock", module)
 		}
-		vendorPath, err := project.ResolveUnderRoot(root, locked.Vendor)
+		vendorPath, err := project.ResolveUnderRoot(lockRoot, locked.Vendor)
 		if err != nil {
 			return err
 		}
@@ -118,7 +250,7 @@ func LoadDependencies(rt *runtime.Runtime, entry string) error {
 			return fmt.Errorf("%s: %w", module, err)
 		}
 		if err := loadVendoredModule(rt, module, vendorPath); err != nil {
-			return fmt.Errorf("%s@%s: %w", module, dep.Version, err)
+			return fmt.Errorf("%s@%s: %w", module, locked.Version, err)
 		}
 	}
 	return nil
@@ -132,16 +264,27 @@ func loadVendoredModule(rt *runtime.Runtime, modulePath, vendorPath string) erro
 	if len(files) == 0 {
 		return fmt.Errorf("no .selene files found in %s", vendorPath)
 	}
-	depRuntime := runtime.New()
+	moduleFiles := make([]runtime.ModuleFile, 0, len(files))
 	for _, file := range files {
-		if err := ExecuteFile(depRuntime, file); err != nil {
+		program, _, err := ParseFile(file)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(vendorPath, file)
+		if err != nil {
 			return err
 		}
+		moduleFiles = append(moduleFiles, runtime.ModuleFile{Name: filepath.ToSlash(rel), Program: program})
+	}
+	depRuntime := runtime.New()
+	if err := depRuntime.RunModule(moduleFiles); err != nil {
+		return fmt.Errorf("runtime error: %w", err)
 	}
 	exports := depRuntime.Environment().Snapshot()
-	for _, builtin := range []string{"print", "format", "spawn", "channel", "__package__"} {
+	for _, builtin := range runtime.StandardGlobals() {
 		delete(exports, builtin)
 	}
+	delete(exports, "__package__")
 	moduleVal := runtime.NewModule(lastSegment(modulePath), exports)
 	attachModule(rt.Environment(), modulePath, moduleVal)
 	return nil
@@ -167,16 +310,18 @@ func attachModule(env *runtime.Environment, modulePath string, moduleVal *runtim
 	} else {
 		env.Set(rootName, current)
 	}
-	env.Set(segments[len(segments)-1], moduleVal)
+	// Leaf segments such as "my-lib" cannot be referenced as identifiers; those
+	// modules are reachable through an aliased string import instead.
+	if leaf := segments[len(segments)-1]; lexer.IsIdentifier(leaf) {
+		env.Set(leaf, moduleVal)
+	}
 }
 
 func mergeModules(target, source *runtime.Module) {
 	if target.Exports == nil {
 		target.Exports = make(map[string]runtime.Value)
 	}
-	keys := slices.Collect(maps.Keys(source.Exports))
-	sort.Strings(keys)
-	for _, name := range keys {
+	for _, name := range source.Keys() {
 		val := source.Exports[name]
 		if existing, ok := target.Exports[name]; ok {
 			tMod, tOK := existing.(*runtime.Module)
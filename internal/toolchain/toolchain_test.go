@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
+	"github.com/cybellereaper/selenelang/internal/cache"
 	"github.com/cybellereaper/selenelang/internal/project"
 	"github.com/cybellereaper/selenelang/internal/runtime"
 )
@@ -273,3 +276,250 @@ func writeFile(t *testing.T, path, contents string) {
 		t.Fatalf("failed to write file %s: %v", path, err)
 	}
 }
+
+func TestCompileFileReusesCachedChunk(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "selene.toml"), "[project]\nmodule = \"example.com/app\"\n")
+	entry := filepath.Join(root, "app.selene")
+	writeFile(t, entry, "let answer = 40 + 2;\n")
+
+	first, err := CompileFile(runtime.New(), entry)
+	if err != nil {
+		t.Fatalf("CompileFile returned error: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(root, cache.DirName, bytecodeNamespace))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected a single cached chunk, got %d entries (%v)", len(entries), err)
+	}
+
+	rt := runtime.New()
+	second, err := CompileFile(rt, entry)
+	if err != nil {
+		t.Fatalf("CompileFile returned error on cache hit: %v", err)
+	}
+	if first.Disassemble() != second.Disassemble() {
+		t.Fatalf("cached chunk differs from compiled chunk:\n%s\nvs\n%s", first.Disassemble(), second.Disassemble())
+	}
+	if _, err := rt.RunChunk(second); err != nil {
+		t.Fatalf("running cached chunk failed: %v", err)
+	}
+	if val, ok := rt.Environment().Get("answer"); !ok || val.Inspect() != "42" {
+		t.Fatalf("expected cached chunk to bind answer = 42, got %v", val)
+	}
+
+	writeFile(t, entry, "let answer = 1;\n")
+	if _, err := CompileFile(runtime.New(), entry); err != nil {
+		t.Fatalf("CompileFile returned error after edit: %v", err)
+	}
+	entries, _ = os.ReadDir(filepath.Join(root, cache.DirName, bytecodeNamespace))
+	if len(entries) != 2 {
+		t.Fatalf("expected edited source to produce a new cache entry, got %d", len(entries))
+	}
+}
+
+func TestCompileFileSkipsCacheOutsideProjects(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	entry := filepath.Join(root, "script.selene")
+	writeFile(t, entry, "let answer = 42;\n")
+	if _, err := CompileFile(runtime.New(), entry); err != nil {
+		t.Fatalf("CompileFile returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, cache.DirName)); !os.IsNotExist(err) {
+		t.Fatalf("expected no cache directory for standalone scripts, got %v", err)
+	}
+}
+
+func TestLoadDependenciesExportsOnlyModuleDeclarations(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "selene.toml"), `
+[project]
+module = "example.com/app"
+
+[dependencies]
+"github.com/example/geo" = { version = "v1.0.0", source = "https://example.com/geo.git" }
+`)
+	vendorPath := filepath.Join(root, "vendor", "github.com", "example", "geo@v1.0.0")
+	writeFile(t, filepath.Join(vendorPath, "lib.selene"), "let origin: Number = 0;\nfn shift(n: Number): Number => n + 1;\n")
+	checksum, err := project.HashDirectory(vendorPath)
+	if err != nil {
+		t.Fatalf("failed to hash vendor directory: %v", err)
+	}
+	writeFile(t, filepath.Join(root, "selene.lock"), fmt.Sprintf(`[[dependency]]
+module = "github.com/example/geo"
+version = "v1.0.0"
+checksum = "%s"
+vendor = "vendor/github.com/example/geo@v1.0.0"
+
+`, checksum))
+	entry := filepath.Join(root, "app.selene")
+	writeFile(t, entry, "// entry point placeholder\n")
+
+	rt := runtime.New()
+	if err := LoadDependencies(rt, entry); err != nil {
+		t.Fatalf("LoadDependencies returned error: %v", err)
+	}
+	geoVal, ok := rt.Environment().Get("geo")
+	if !ok {
+		t.Fatalf("expected dependency module to be bound by final segment")
+	}
+	geo, ok := geoVal.(*runtime.Module)
+	if !ok {
+		t.Fatalf("expected geo binding to be a module, got %T", geoVal)
+	}
+	keys := geo.Keys()
+	slices.Sort(keys)
+	if got := strings.Join(keys, ","); got != "origin,shift" {
+		t.Fatalf("expected only the module's own declarations to be exported, got %s", got)
+	}
+}
+
+func TestLoadDependenciesSupportsHyphenatedModules(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "selene.toml"), `
+[project]
+module = "example.com/app"
+
+[dependencies]
+"github.com/example/my-lib" = { version = "v1.0.0", source = "https://example.com/my-lib.git" }
+`)
+	vendorPath := filepath.Join(root, "vendor", "github.com", "example", "my-lib@v1.0.0")
+	writeFile(t, filepath.Join(vendorPath, "lib.selene"), "let answer: Number = 42;\n")
+	checksum, err := project.HashDirectory(vendorPath)
+	if err != nil {
+		t.Fatalf("failed to hash vendor directory: %v", err)
+	}
+	writeFile(t, filepath.Join(root, "selene.lock"), fmt.Sprintf(`[[dependency]]
+module = "github.com/example/my-lib"
+version = "v1.0.0"
+checksum = "%s"
+vendor = "vendor/github.com/example/my-lib@v1.0.0"
+
+`, checksum))
+	entry := filepath.Join(root, "app.selene")
+	writeFile(t, entry, "import mylib \"github.com/example/my-lib\";\nlet result = mylib.answer;\n")
+
+	rt := runtime.New()
+	if err := LoadDependencies(rt, entry); err != nil {
+		t.Fatalf("LoadDependencies returned error: %v", err)
+	}
+	if _, ok := rt.Environment().Get("my-lib"); ok {
+		t.Fatalf("expected non-identifier leaf segment not to be bound directly")
+	}
+	if err := ExecuteFile(rt, entry); err != nil {
+		t.Fatalf("ExecuteFile returned error: %v", err)
+	}
+	if val, ok := rt.Environment().Get("result"); !ok || val.Inspect() != "42" {
+		t.Fatalf("expected aliased import to resolve hyphenated module, got %v", val)
+	}
+}
+
+func TestLoadDependenciesInitializesFilesInImportOrder(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "selene.toml"), `
+[project]
+module = "example.com/app"
+
+[dependencies]
+"github.com/example/geo" = { version = "v1.0.0" }
+`)
+	vendorPath := filepath.Join(root, "vendor", "github.com", "example", "geo@v1.0.0")
+	writeFile(t, filepath.Join(vendorPath, "api.selene"), `
+import shapes.square;
+var unit = 0;
+fn init() { unit = square(3); }
+`)
+	writeFile(t, filepath.Join(vendorPath, "shapes.selene"), "module shapes {\n    fn square(n: Number): Number => n * n;\n}\n")
+	checksum, err := project.HashDirectory(vendorPath)
+	if err != nil {
+		t.Fatalf("failed to hash vendor directory: %v", err)
+	}
+	writeFile(t, filepath.Join(root, "selene.lock"), fmt.Sprintf(`[[dependency]]
+module = "github.com/example/geo"
+version = "v1.0.0"
+checksum = "%s"
+vendor = "vendor/github.com/example/geo@v1.0.0"
+
+`, checksum))
+	entry := filepath.Join(root, "app.selene")
+	writeFile(t, entry, "// entry point placeholder\n")
+
+	rt := runtime.New()
+	if err := LoadDependencies(rt, entry); err != nil {
+		t.Fatalf("LoadDependencies returned error: %v", err)
+	}
+	geo, _ := rt.Environment().Get("geo")
+	module, ok := geo.(*runtime.Module)
+	if !ok {
+		t.Fatalf("expected geo module, got %v", geo)
+	}
+	if unit := module.Exports["unit"]; unit == nil || unit.Inspect() != "9" {
+		t.Fatalf("expected init to run after shapes.selene, got %v", unit)
+	}
+	if _, ok := module.Exports["init"]; ok {
+		t.Fatalf("expected init not to be exported")
+	}
+}
+
+func TestLoadDependenciesResolvesProjectImports(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "selene.toml"), "[project]\nmodule = \"myproject\"\n")
+	writeFile(t, filepath.Join(root, "src", "utils", "math.selene"), `
+package utils;
+var loads = 0;
+fn square(n: Number): Number => n * n;
+fn init() { loads = loads + 1; }
+`)
+	writeFile(t, filepath.Join(root, "src", "shapes", "area.selene"), "import myproject.utils.math;\nfn tile(side: Number): Number => math.square(side);\n")
+	writeFile(t, filepath.Join(root, "src", "shapes", "names.selene"), "let unit = \"cm\";\n")
+	entry := filepath.Join(root, "src", "main.selene")
+	writeFile(t, entry, `
+import myproject.utils.math;
+import myproject.utils.math.square;
+import myproject.shapes;
+let result = square(3) + shapes.tile(2);
+let loads = math.loads;
+let unit = shapes.unit;
+`)
+
+	rt := runtime.New()
+	if err := LoadDependencies(rt, entry); err != nil {
+		t.Fatalf("LoadDependencies returned error: %v", err)
+	}
+	if err := ExecuteFile(rt, entry); err != nil {
+		t.Fatalf("ExecuteFile returned error: %v", err)
+	}
+	for name, want := range map[string]string{"result": "13", "loads": "1", "unit": "cm"} {
+		if val, ok := rt.Environment().Get(name); !ok || val.Inspect() != want {
+			t.Fatalf("expected %s = %s, got %v", name, want, val)
+		}
+	}
+	direct, _ := rt.Environment().Get("math")
+	shapes, _ := rt.Environment().Get("shapes")
+	if shapesModule, ok := shapes.(*runtime.Module); !ok || shapesModule.Exports["math"] != direct {
+		t.Fatalf("expected both imports of myproject.utils.math to share one evaluation")
+	}
+
+	writeFile(t, filepath.Join(root, "src", "a.selene"), "import myproject.b;\n")
+	writeFile(t, filepath.Join(root, "src", "b.selene"), "import myproject.a;\n")
+	writeFile(t, entry, "import myproject.a;\n")
+	rt = runtime.New()
+	if err := LoadDependencies(rt, entry); err != nil {
+		t.Fatalf("LoadDependencies returned error: %v", err)
+	}
+	err := ExecuteFile(rt, entry)
+	if err == nil || !strings.Contains(err.Error(), "import cycle: src/a.selene -> src/b.selene -> src/a.selene") {
+		t.Fatalf("expected import cycle error, got %v", err)
+	}
+}
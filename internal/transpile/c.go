@@ -0,0 +1,1006 @@
+package transpile
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/consteval"
+	"github.com/cybellereaper/selenelang/internal/lexer"
+	"github.com/cybellereaper/selenelang/internal/parser"
+	"github.com/cybellereaper/selenelang/internal/token"
+)
+
+// CHeaderName is the file name generated C sources include for their
+// runtime support.
+const CHeaderName = "selene.h"
+
+//go:embed selene.h
+var cHeader string
+
+// CHeader returns the runtime support header that C produced by ToC
+// includes as CHeaderName. It is C99 and depends only on the standard
+// library.
+func CHeader() string {
+	return cHeader
+}
+
+// ToC converts a Selene program into a C99 translation unit. Values keep
+// Selene's dynamic typing and semantics through the reference-counted
+// runtime in CHeader. Top-level functions, variables, control flow, strings,
+// and arrays are translated; other constructs become calls to
+// sl_unsupported, which stops the program with a message when reached.
+func ToC(program *ast.Program) (string, error) {
+	e := &cEmitter{
+		functions: make(map[string]*ast.FunctionDeclaration),
+		globals:   make(map[string]bool),
+		topScope:  &cScope{declared: make(map[string]bool), global: true},
+	}
+	e.commentQueue = commentQueue{out: e, render: cComment, comments: program.Comments}
+	var globalNames []string
+	for _, item := range program.Items {
+		switch node := item.(type) {
+		case *ast.FunctionDeclaration:
+			if name := identName(node.Name); name != "" && !node.IsExtension {
+				e.functions[name] = node
+			}
+		case *ast.VariableDeclaration:
+			if name := identName(node.Name); name != "" && !e.globals[name] {
+				e.globals[name] = true
+				globalNames = append(globalNames, name)
+			}
+		}
+	}
+
+	for _, item := range program.Items {
+		e.emitProgramItem(item)
+	}
+	e.out = &e.top
+	e.flushComments(token.Position{Offset: math.MaxInt})
+
+	var b strings.Builder
+	b.WriteString("// Code generated by selene transpile. DO NOT EDIT.\n")
+	fmt.Fprintf(&b, "#include %q\n\n", CHeaderName)
+	if len(globalNames) > 0 {
+		for _, name := range globalNames {
+			fmt.Fprintf(&b, "static sl_value %s;\n", cVariable(name))
+		}
+		b.WriteString("\n")
+	}
+	var prototypes []string
+	for _, item := range program.Items {
+		if fn, ok := item.(*ast.FunctionDeclaration); ok && e.functions[identName(fn.Name)] == fn {
+			prototypes = append(prototypes, e.cSignature(fn)+";\n")
+		}
+	}
+	if len(prototypes) > 0 {
+		b.WriteString(strings.Join(prototypes, ""))
+		b.WriteString("\n")
+	}
+	if e.decls.b.Len() > 0 {
+		b.WriteString(strings.TrimRight(e.decls.b.String(), "\n"))
+		b.WriteString("\n\n")
+	}
+	b.WriteString("static void sl_top(void) {\n")
+	b.WriteString(strings.TrimRight(e.top.b.String(), "\n"))
+	if e.top.b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n\n")
+	b.WriteString(e.cMain(program))
+	return b.String(), nil
+}
+
+// cStream is one of the two parts of the output that items are written to:
+// declarations at file scope and the statements run by sl_top.
+type cStream struct {
+	b         strings.Builder
+	lastBlank bool
+}
+
+type cEmitter struct {
+	decls, top cStream
+	out        *cStream
+	indent     int
+	commentQueue
+	// functions maps the names of top-level functions to their
+	// declarations; they are the only functions calls can reach.
+	functions map[string]*ast.FunctionDeclaration
+	// globals holds the names of top-level variables.
+	globals  map[string]bool
+	topScope *cScope
+	scopes   []*cScope
+	loops    []*cLoop
+	temps    int
+	labels   int
+	// inTop is set while emitting the body of sl_top, which returns void.
+	inTop bool
+}
+
+// cScope tracks the variables a C block declares so they can be released
+// when control leaves it.
+type cScope struct {
+	names    []string
+	declared map[string]bool
+	// global marks the top-level scope, whose variables live at file scope
+	// and are never released.
+	global bool
+}
+
+// cLoop records how break and continue leave the innermost loop. depth is
+// the number of scopes outside the loop body, and continueTo is empty when
+// continue jumps to next, at the end of the body. A labelled loop can also
+// be left from a loop nested in it, by a goto to next or to end, after it;
+// each C label is written only when a jump uses it.
+type cLoop struct {
+	depth      int
+	continueTo string
+	label      string
+	next, end  string
+	// nextUsed and endUsed record that a jump went to next or end.
+	nextUsed, endUsed bool
+}
+
+func (e *cEmitter) line(parts ...string) {
+	text := strings.Join(parts, "")
+	if text == "" {
+		e.out.b.WriteByte('\n')
+		e.out.lastBlank = true
+		return
+	}
+	e.out.b.WriteString(strings.Repeat("    ", e.indent))
+	e.out.b.WriteString(text)
+	e.out.b.WriteByte('\n')
+	e.out.lastBlank = false
+}
+
+func (e *cEmitter) ensureBlankLine() {
+	if e.out.b.Len() == 0 || e.out.lastBlank {
+		return
+	}
+	e.out.b.WriteByte('\n')
+	e.out.lastBlank = true
+}
+
+func (e *cEmitter) commentLine(text string) { e.line(text) }
+
+// lastLine reports on the stream being written.
+func (e *cEmitter) lastLine() (*strings.Builder, bool) { return &e.out.b, e.out.lastBlank }
+
+// cComment renders a Selene comment as a C comment; C99 line comments share
+// Selene's syntax.
+func cComment(text string) string { return text }
+
+func (e *cEmitter) emitProgramItem(item ast.ProgramItem) {
+	if fn, ok := item.(*ast.FunctionDeclaration); ok && e.functions[identName(fn.Name)] == fn {
+		e.out = &e.decls
+		e.flushComments(item.Pos())
+		e.emitFunction(fn)
+		e.trailingComments(fn)
+		e.ensureBlankLine()
+		return
+	}
+	e.out = &e.top
+	e.inTop = true
+	e.indent = 1
+	e.scopes = []*cScope{e.topScope}
+	e.flushComments(item.Pos())
+	switch node := item.(type) {
+	case *ast.PackageDeclaration:
+	case ast.Statement:
+		e.emitStatement(node)
+	default:
+		e.unsupportedStmt(fmt.Sprintf("program item %T", item))
+		e.trailingComments(item)
+	}
+	e.inTop = false
+	e.indent = 0
+}
+
+func (e *cEmitter) cSignature(fn *ast.FunctionDeclaration) string {
+	params := make([]string, 0, len(fn.Params))
+	for i, param := range fn.Params {
+		params = append(params, "sl_value "+cParameter(param, i))
+	}
+	if len(params) == 0 {
+		params = append(params, "void")
+	}
+	return fmt.Sprintf("static sl_value %s(%s)", cFunction(identName(fn.Name)), strings.Join(params, ", "))
+}
+
+func cParameter(param ast.Parameter, index int) string {
+	if name := identName(param.Name); name != "" {
+		return cVariable(name)
+	}
+	return fmt.Sprintf("sl_arg%d", index)
+}
+
+func (e *cEmitter) emitFunction(fn *ast.FunctionDeclaration) {
+	e.line(e.cSignature(fn) + " {")
+	e.indent = 1
+	// Temporaries and labels are numbered per C function, and sl_top
+	// is written in pieces around the functions.
+	temps, labels := e.temps, e.labels
+	e.temps, e.labels = 0, 0
+	defer func() { e.temps, e.labels = temps, labels }()
+	scope := &cScope{declared: make(map[string]bool)}
+	for i, param := range fn.Params {
+		name := cParameter(param, i)
+		scope.names = append(scope.names, name)
+		if id := identName(param.Name); id != "" {
+			scope.declared[id] = true
+		}
+	}
+	e.scopes = []*cScope{scope}
+	switch {
+	case fn.Generator || fn.Async || fn.Contract != nil:
+		kind := "generator"
+		if fn.Async {
+			kind = "async"
+		} else if fn.Contract != nil {
+			kind = "contract"
+		}
+		e.releaseScopes(0)
+		e.line(fmt.Sprintf("return sl_unsupported(%s);", cString(kind+" function "+identName(fn.Name))))
+	case fn.IsExprBody:
+		value := "sl_null()"
+		if fn.BodyExpr != nil {
+			value = e.expr(fn.BodyExpr)
+		}
+		e.line("sl_value sl_ret = ", value, ";")
+		e.releaseScopes(0)
+		e.line("return sl_ret;")
+	default:
+		if fn.Body != nil {
+			e.emitStatements(fn.Body.Statements)
+			e.flushComments(fn.Body.End())
+		}
+		e.releaseScopes(0)
+		e.line("return sl_null();")
+	}
+	e.scopes = nil
+	e.indent = 0
+	e.line("}")
+}
+
+// cMain produces the C entry point: it runs the top-level statements, then
+// init and main when the program declares them and does not call them
+// itself, as the interpreter does.
+func (e *cEmitter) cMain(program *ast.Program) string {
+	calls := make(map[string]bool)
+	for _, item := range program.Items {
+		if stmt, ok := item.(*ast.ExpressionStatement); ok {
+			if call, ok := stmt.Expression.(*ast.CallExpression); ok {
+				if callee, ok := call.Callee.(*ast.Identifier); ok {
+					calls[callee.Name] = true
+				}
+			}
+		}
+	}
+	var b strings.Builder
+	b.WriteString("int main(int argc, char **argv) {\n")
+	b.WriteString("    sl_top();\n")
+	if fn := e.functions["init"]; fn != nil && !calls["init"] {
+		fmt.Fprintf(&b, "    sl_release(%s());\n", cFunction("init"))
+	}
+	fn := e.functions["main"]
+	if fn == nil || calls["main"] || len(fn.Params) > 1 {
+		b.WriteString("    (void)argc;\n    (void)argv;\n    return 0;\n}\n")
+		return b.String()
+	}
+	call := cFunction("main") + "()"
+	if len(fn.Params) == 1 {
+		call = cFunction("main") + "(sl_args(argc, argv))"
+	} else {
+		b.WriteString("    (void)argc;\n    (void)argv;\n")
+	}
+	if declaresNumber(fn.ReturnType) {
+		fmt.Fprintf(&b, "    return sl_exit_code(%s);\n}\n", call)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "    sl_release(%s);\n    return 0;\n}\n", call)
+	return b.String()
+}
+
+func declaresNumber(t *ast.TypeAnnotation) bool {
+	if t == nil || t.Name == nil || t.Nullable {
+		return false
+	}
+	switch t.Name.Name {
+	case "Number", "Int", "Integer", "Float":
+		return true
+	}
+	return false
+}
+
+func (e *cEmitter) emitStatements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		e.emitStatement(stmt)
+	}
+}
+
+func (e *cEmitter) emitStatement(stmt ast.Statement) {
+	e.flushComments(stmt.Pos())
+	defer e.trailingComments(stmt)
+	switch node := stmt.(type) {
+	case *ast.VariableDeclaration:
+		e.emitVariable(node)
+	case *ast.ExpressionStatement:
+		if node.Expression != nil {
+			e.line("sl_release(", e.expr(node.Expression), ");")
+		}
+	case *ast.BlockStatement:
+		e.line("{")
+		e.emitBlock(node)
+		e.line("}")
+	case *ast.IfStatement:
+		e.line("if (sl_test(", e.expr(node.Condition), ")) {")
+		e.emitBranch(node.Consequence)
+		if node.Alternative != nil {
+			e.line("} else {")
+			e.emitBranch(node.Alternative)
+		}
+		e.line("}")
+	case *ast.WhileStatement:
+		e.line("for (;;) {")
+		e.indent++
+		if node.Condition != nil {
+			e.line("if (!sl_test(", e.expr(node.Condition), ")) break;")
+		}
+		e.indent--
+		loop := e.emitLoopBody(node.Label, node.Body, "continue;")
+		e.line("}")
+		e.endLoop(loop)
+	case *ast.DoWhileStatement:
+		e.emitDoWhile(node)
+	case *ast.ForStatement:
+		e.emitFor(node)
+	case *ast.ForInStatement:
+		e.emitForIn(node)
+	case *ast.ReturnStatement:
+		e.emitReturn(node)
+	case *ast.BreakStatement:
+		e.emitJump(node.Label, true)
+	case *ast.ContinueStatement:
+		e.emitJump(node.Label, false)
+	case *ast.ThrowStatement:
+		value := "sl_null()"
+		if node.Value != nil {
+			value = e.expr(node.Value)
+		}
+		e.line("sl_throw(", value, ");")
+	default:
+		e.unsupportedStmt(fmt.Sprintf("statement %T", stmt))
+	}
+}
+
+func (e *cEmitter) emitVariable(node *ast.VariableDeclaration) {
+	if node.Pattern != nil {
+		e.unsupportedStmt("destructuring")
+		return
+	}
+	name := identName(node.Name)
+	if name == "" {
+		return
+	}
+	value := "sl_null()"
+	if node.Value != nil {
+		value = e.expr(node.Value)
+	}
+	scope := e.scopes[len(e.scopes)-1]
+	target := cVariable(name)
+	switch {
+	case scope.declared[name]:
+		e.line("sl_release(sl_assign(&", target, ", ", value, "));")
+		return
+	case scope.global:
+		e.line(target, " = ", value, ";")
+	default:
+		if e.visible(name) {
+			// The initializer may read the variable being shadowed,
+			// which C would already resolve to the new one.
+			value = e.hoist(value)
+		}
+		e.line("sl_value ", target, " = ", value, ";")
+		scope.names = append(scope.names, target)
+	}
+	scope.declared[name] = true
+}
+
+// emitBlock writes the statements of block in a new scope, without braces.
+func (e *cEmitter) emitBlock(block *ast.BlockStatement) {
+	e.pushScope()
+	e.emitStatements(block.Statements)
+	e.flushComments(block.End())
+	e.popScope()
+}
+
+// emitBranch writes stmt as the body of a braced C block.
+func (e *cEmitter) emitBranch(stmt ast.Statement) {
+	if block, ok := stmt.(*ast.BlockStatement); ok {
+		e.emitBlock(block)
+		return
+	}
+	e.pushScope()
+	if stmt != nil {
+		e.emitStatement(stmt)
+	}
+	e.popScope()
+}
+
+// emitJump writes a break, or a continue, of the loop labelled label or,
+// without a label, of the innermost loop.
+func (e *cEmitter) emitJump(label string, isBreak bool) {
+	target := len(e.loops) - 1
+	for label != "" && target >= 0 && e.loops[target].label != label {
+		target--
+	}
+	if target < 0 {
+		e.unsupportedStmt("break or continue outside a loop")
+		return
+	}
+	loop := e.loops[target]
+	e.releaseScopes(loop.depth)
+	inner := target == len(e.loops)-1
+	switch {
+	case isBreak && inner:
+		e.line("break;")
+	case isBreak:
+		loop.endUsed = true
+		e.line("goto ", loop.end, ";")
+	case inner && loop.continueTo != "":
+		e.line(loop.continueTo)
+	default:
+		loop.nextUsed = true
+		e.line("goto ", loop.next, ";")
+	}
+}
+
+// pushLoop starts a loop labelled label, whose body is inside the current
+// scopes. next is the C label continue goes to, or "" for a new one.
+func (e *cEmitter) pushLoop(label, continueTo, next string) *cLoop {
+	loop := &cLoop{depth: len(e.scopes), continueTo: continueTo, label: label, next: next}
+	if label != "" {
+		e.labels++
+		if loop.next == "" {
+			loop.next = fmt.Sprintf("sl_next%d", e.labels)
+		}
+		loop.end = fmt.Sprintf("sl_end%d", e.labels)
+	}
+	e.loops = append(e.loops, loop)
+	return loop
+}
+
+// popLoop ends the innermost loop's body, writing next if a jump from a
+// nested loop went to it.
+func (e *cEmitter) popLoop() *cLoop {
+	loop := e.loops[len(e.loops)-1]
+	e.loops = e.loops[:len(e.loops)-1]
+	if loop.nextUsed {
+		e.indent++
+		e.line(loop.next, ": ;")
+		e.indent--
+	}
+	return loop
+}
+
+// endLoop writes the end label of loop after it, if a break went to it.
+func (e *cEmitter) endLoop(loop *cLoop) {
+	if loop.endUsed {
+		e.line(loop.end, ": ;")
+	}
+}
+
+func (e *cEmitter) emitLoopBody(label string, body ast.Statement, continueTo string) *cLoop {
+	e.pushLoop(label, continueTo, "")
+	e.emitBranch(body)
+	return e.popLoop()
+}
+
+func (e *cEmitter) emitFor(node *ast.ForStatement) {
+	e.line("{")
+	e.pushScope()
+	switch init := node.Init.(type) {
+	case nil:
+	case *ast.VariableDeclaration:
+		e.emitVariable(init)
+	case *ast.ExpressionStatement:
+		e.line("sl_release(", e.expr(init.Expression), ");")
+	default:
+		e.unsupportedStmt("for-init")
+	}
+	e.line("for (;;) {")
+	e.indent++
+	if node.Condition != nil {
+		e.line("if (!sl_test(", e.expr(node.Condition), ")) break;")
+	}
+	e.indent--
+	label, continueTo := "", "continue;"
+	if node.Post != nil {
+		// The post expression runs after next, so continue jumps to it.
+		e.labels++
+		label, continueTo = fmt.Sprintf("sl_next%d", e.labels), ""
+	}
+	loop := e.pushLoop(node.Label, continueTo, label)
+	e.emitBranch(node.Body)
+	e.popLoop()
+	if node.Post != nil {
+		e.indent++
+		e.line("sl_release(", e.expr(node.Post), ");")
+		e.indent--
+	}
+	e.line("}")
+	e.endLoop(loop)
+	e.popScope()
+	e.line("}")
+}
+
+// emitDoWhile tests the condition at the end of the body, after a next
+// label that continue jumps to.
+func (e *cEmitter) emitDoWhile(node *ast.DoWhileStatement) {
+	e.labels++
+	next := fmt.Sprintf("sl_next%d", e.labels)
+	e.line("for (;;) {")
+	loop := e.pushLoop(node.Label, "", next)
+	e.emitBranch(node.Body)
+	e.popLoop()
+	e.indent++
+	e.line("if (!sl_test(", e.expr(node.Condition), ")) break;")
+	e.indent--
+	e.line("}")
+	e.endLoop(loop)
+}
+
+func (e *cEmitter) emitForIn(node *ast.ForInStatement) {
+	e.line("{")
+	e.pushScope()
+	iterable := e.hoist(e.expr(node.Iterable))
+	e.scopes[len(e.scopes)-1].names = append(e.scopes[len(e.scopes)-1].names, iterable)
+	e.labels++
+	index := fmt.Sprintf("sl_i%d", e.labels)
+	e.line(fmt.Sprintf("for (size_t %s = 0; %s < sl_count(%s); %s++) {", index, index, iterable, index))
+	e.pushLoop(node.Label, "continue;", "")
+	e.pushScope()
+	if name := identName(node.Binding); name != "" {
+		binding := cVariable(name)
+		e.line(fmt.Sprintf("sl_value %s = sl_at(%s, %s);", binding, iterable, index))
+		scope := e.scopes[len(e.scopes)-1]
+		scope.names = append(scope.names, binding)
+		scope.declared[name] = true
+	}
+	if node.Body != nil {
+		e.emitStatements(node.Body.Statements)
+		e.flushComments(node.Body.End())
+	}
+	e.popScope()
+	loop := e.popLoop()
+	e.line("}")
+	e.endLoop(loop)
+	e.popScope()
+	e.line("}")
+}
+
+func (e *cEmitter) emitReturn(node *ast.ReturnStatement) {
+	if e.inTop {
+		if node.Value != nil {
+			e.line("sl_release(", e.expr(node.Value), ");")
+		}
+		e.releaseScopes(0)
+		e.line("return;")
+		return
+	}
+	value := "sl_null()"
+	if node.Value != nil {
+		value = e.expr(node.Value)
+	}
+	e.line("{")
+	e.indent++
+	e.line("sl_value sl_ret = ", value, ";")
+	e.releaseScopes(0)
+	e.line("return sl_ret;")
+	e.indent--
+	e.line("}")
+}
+
+func (e *cEmitter) pushScope() {
+	e.scopes = append(e.scopes, &cScope{declared: make(map[string]bool)})
+	e.indent++
+}
+
+// popScope releases the variables of the innermost scope and closes it.
+func (e *cEmitter) popScope() {
+	e.releaseScopes(len(e.scopes) - 1)
+	e.scopes = e.scopes[:len(e.scopes)-1]
+	e.indent--
+}
+
+// releaseScopes writes releases for the variables of every scope from depth
+// inward, innermost first, for control leaving them.
+func (e *cEmitter) releaseScopes(depth int) {
+	for i := len(e.scopes) - 1; i >= depth; i-- {
+		names := e.scopes[i].names
+		for j := len(names) - 1; j >= 0; j-- {
+			e.line("sl_release(", names[j], ");")
+		}
+	}
+}
+
+func (e *cEmitter) visible(name string) bool {
+	for _, scope := range e.scopes {
+		if scope.declared[name] {
+			return true
+		}
+	}
+	return e.globals[name]
+}
+
+func (e *cEmitter) unsupportedStmt(feature string) {
+	e.line("sl_unsupported(", cString(feature), ");")
+}
+
+func unsupported(feature string) string {
+	return "sl_unsupported(" + cString(feature) + ")"
+}
+
+// hoist stores the value of code in a new temporary and returns its name,
+// fixing when code runs relative to the expressions written after it.
+func (e *cEmitter) hoist(code string) string {
+	if isTemporary(code) {
+		return code
+	}
+	e.temps++
+	name := fmt.Sprintf("sl_t%d", e.temps)
+	e.line("sl_value ", name, " = ", code, ";")
+	return name
+}
+
+// operands translates exprs so that they run left to right, as in the
+// interpreter, even though C leaves the order of function arguments
+// unspecified. Each operand that is followed by one with side effects is
+// hoisted into a temporary.
+func isTemporary(code string) bool {
+	digits := strings.TrimPrefix(code, "sl_t")
+	return digits != code && strings.Trim(digits, "0123456789") == ""
+}
+
+func (e *cEmitter) operands(exprs []ast.Expression) []string {
+	out := make([]string, len(exprs))
+	for i, expr := range exprs {
+		code := e.expr(expr)
+		if !isPlain(expr) && !allPlain(exprs[i+1:]) {
+			code = e.hoist(code)
+		}
+		out[i] = code
+	}
+	return out
+}
+
+// isPlain reports whether expr is a literal whose translation can run at any
+// point without changing the result.
+func isPlain(expr ast.Expression) bool {
+	switch node := expr.(type) {
+	case *ast.NumberLiteral, *ast.BooleanLiteral, *ast.NullLiteral:
+		return true
+	case *ast.StringLiteral:
+		_, err := consteval.Eval(node, nil)
+		return err == nil
+	}
+	return false
+}
+
+func allPlain(exprs []ast.Expression) bool {
+	for _, expr := range exprs {
+		if !isPlain(expr) {
+			return false
+		}
+	}
+	return true
+}
+
+var cInfixFunctions = map[string]string{
+	"+": "sl_add", "-": "sl_sub", "*": "sl_mul", "/": "sl_div", "%": "sl_mod",
+	"==": "sl_eq", "!=": "sl_ne", "<": "sl_lt", "<=": "sl_le", ">": "sl_gt", ">=": "sl_ge",
+}
+
+var cPrefixFunctions = map[string]string{"!": "sl_not", "-": "sl_neg", "+": "sl_pos"}
+
+var cAugmentedFunctions = map[token.Type]string{
+	token.PLUS_ASSIGN: "sl_add", token.MINUS_ASSIGN: "sl_sub", token.STAR_ASSIGN: "sl_mul",
+	token.SLASH_ASSIGN: "sl_div", token.PERCENT_ASSIGN: "sl_mod",
+}
+
+// expr translates expr into a C expression yielding a new reference. Any
+// statements it needs first are written before it returns, so the result
+// must be evaluated exactly once, after them.
+func (e *cEmitter) expr(expr ast.Expression) string {
+	switch node := expr.(type) {
+	case *ast.Identifier:
+		if e.visible(node.Name) {
+			return "sl_retain(" + cVariable(node.Name) + ")"
+		}
+		if e.functions[node.Name] != nil {
+			return unsupported("function value " + node.Name)
+		}
+		return unsupported("identifier " + node.Name)
+	case *ast.NumberLiteral:
+		v, err := strconv.ParseFloat(node.Value, 64)
+		if err != nil {
+			return unsupported("number literal " + node.Value)
+		}
+		text := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(text, ".e") {
+			text += ".0"
+		}
+		return "sl_number(" + text + ")"
+	case *ast.StringLiteral:
+		return e.stringLiteral(node)
+	case *ast.BooleanLiteral:
+		if node.Value {
+			return "sl_bool(1)"
+		}
+		return "sl_bool(0)"
+	case *ast.NullLiteral:
+		return "sl_null()"
+	case *ast.ArrayLiteral:
+		elements := e.operands(node.Elements)
+		return fmt.Sprintf("sl_array_of(%s)", strings.Join(append([]string{strconv.Itoa(len(elements))}, elements...), ", "))
+	case *ast.PrefixExpression:
+		fn, ok := cPrefixFunctions[node.Operator]
+		if !ok {
+			return unsupported("operator " + node.Operator)
+		}
+		return fmt.Sprintf("%s(%s)", fn, e.expr(node.Right))
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			// Like the interpreter, evaluate the right operand only when
+			// the left one does not settle the result.
+			left := e.hoist(e.expr(node.Left))
+			test := "sl_truthy(" + left + ")"
+			if node.Operator == "||" {
+				test = "!" + test
+			}
+			e.line("if (", test, ") {")
+			e.indent++
+			e.line("sl_release(", left, ");")
+			e.line(left, " = ", e.expr(node.Right), ";")
+			e.indent--
+			e.line("}")
+			return left
+		}
+		fn, ok := cInfixFunctions[node.Operator]
+		if !ok {
+			return unsupported("operator " + node.Operator)
+		}
+		args := e.operands([]ast.Expression{node.Left, node.Right})
+		return fmt.Sprintf("%s(%s, %s)", fn, args[0], args[1])
+	case *ast.ElvisExpression:
+		left := e.hoist(e.expr(node.Left))
+		e.line("if (!sl_truthy(", left, ")) {")
+		e.indent++
+		e.line("sl_release(", left, ");")
+		e.line(left, " = ", e.expr(node.Right), ";")
+		e.indent--
+		e.line("}")
+		return left
+	case *ast.AssignmentExpression:
+		target, ok := node.Target.(*ast.Identifier)
+		if !ok || !e.visible(target.Name) {
+			return unsupported("assignment target")
+		}
+		name := cVariable(target.Name)
+		value := e.expr(node.Value)
+		if node.Operator == token.ASSIGN {
+			return fmt.Sprintf("sl_assign(&%s, %s)", name, value)
+		}
+		fn, ok := cAugmentedFunctions[node.Operator]
+		if !ok {
+			return unsupported("assignment")
+		}
+		if !isPlain(node.Value) {
+			// The interpreter reads the variable after evaluating the
+			// right-hand side.
+			value = e.hoist(value)
+		}
+		return fmt.Sprintf("sl_assign(&%s, %s(sl_retain(%s), %s))", name, fn, name, value)
+	case *ast.IncrementExpression:
+		target, ok := node.Target.(*ast.Identifier)
+		if !ok || !e.visible(target.Name) {
+			return unsupported("increment target")
+		}
+		name := cVariable(target.Name)
+		old := e.hoist("sl_retain(" + name + ")")
+		delta := "1.0"
+		if node.Operator == "--" {
+			delta = "-1.0"
+		}
+		e.line("sl_release(sl_assign(&", name, ", sl_increment(\"", node.Operator, "\", sl_retain(", name, "), ", delta, ")));")
+		return old
+	case *ast.CallExpression:
+		return e.call(node)
+	case *ast.IndexExpression:
+		if node.Optional {
+			return unsupported("optional index")
+		}
+		args := e.operands([]ast.Expression{node.Collection, node.Index})
+		return fmt.Sprintf("sl_index(%s, %s)", args[0], args[1])
+	case *ast.MemberExpression:
+		if node.Property != "length" || node.Optional {
+			return unsupported("member ." + node.Property)
+		}
+		return "sl_length(" + e.expr(node.Object) + ")"
+	default:
+		return unsupported(fmt.Sprintf("expression %T", expr))
+	}
+}
+
+func (e *cEmitter) call(node *ast.CallExpression) string {
+	callee, ok := node.Callee.(*ast.Identifier)
+	if !ok {
+		return unsupported("call of a computed function")
+	}
+	if callee.Name == "print" && !e.visible("print") && e.functions["print"] == nil {
+		args := e.operands(node.Arguments)
+		return fmt.Sprintf("sl_print(%s)", strings.Join(append([]string{strconv.Itoa(len(args))}, args...), ", "))
+	}
+	fn := e.functions[callee.Name]
+	if fn == nil || e.visible(callee.Name) {
+		return unsupported("call to " + callee.Name)
+	}
+	if len(fn.Params) != len(node.Arguments) {
+		return unsupported(fmt.Sprintf("call to %s with %d arguments", callee.Name, len(node.Arguments)))
+	}
+	return fmt.Sprintf("%s(%s)", cFunction(callee.Name), strings.Join(e.operands(node.Arguments), ", "))
+}
+
+// stringLiteral builds a string, concatenating the values of interpolated
+// expressions the way `+` does.
+func (e *cEmitter) stringLiteral(lit *ast.StringLiteral) string {
+	if v, err := consteval.Eval(lit, nil); err == nil {
+		return cStringValue(v.String)
+	}
+	chunks, placeholders, err := splitInterpolation(lit.Value)
+	if err != nil {
+		return unsupported(err.Error())
+	}
+	text, err := decodeChunk(chunks[0], lit.Raw)
+	if err != nil {
+		return unsupported(err.Error())
+	}
+	result := e.hoist(cStringValue(text))
+	for i, placeholder := range placeholders {
+		value, err := placeholderExpression(placeholder)
+		if err != nil {
+			e.line(result, " = sl_concat(", result, ", ", unsupported(err.Error()), ");")
+		} else {
+			e.line(result, " = sl_concat(", result, ", ", e.expr(value), ");")
+		}
+		if chunk := chunks[i+1]; chunk != "" {
+			text, err := decodeChunk(chunk, lit.Raw)
+			if err != nil {
+				return unsupported(err.Error())
+			}
+			e.line(result, " = sl_concat(", result, ", ", cStringValue(text), ");")
+		}
+	}
+	return result
+}
+
+// splitInterpolation cuts a string literal's source into the text around
+// each `${...}` placeholder and the placeholders' contents.
+func splitInterpolation(raw string) (chunks, placeholders []string, err error) {
+	last := 0
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '\\' {
+			i++
+			continue
+		}
+		if raw[i] != '$' || i+1 >= len(raw) || raw[i+1] != '{' {
+			continue
+		}
+		depth := 1
+		end := -1
+		for j := i + 2; j < len(raw) && end < 0; j++ {
+			switch raw[j] {
+			case '\\':
+				j++
+			case '{':
+				depth++
+			case '}':
+				if depth--; depth == 0 {
+					end = j
+				}
+			}
+		}
+		if end < 0 {
+			return nil, nil, errors.New("unterminated interpolation expression")
+		}
+		chunks = append(chunks, raw[last:i])
+		placeholders = append(placeholders, raw[i+2:end])
+		last = end + 1
+		i = end
+	}
+	return append(chunks, raw[last:]), placeholders, nil
+}
+
+func decodeChunk(chunk string, raw bool) (string, error) {
+	v, err := consteval.Eval(&ast.StringLiteral{Value: chunk, Raw: raw}, nil)
+	if err != nil {
+		return "", err
+	}
+	return v.String, nil
+}
+
+// placeholderExpression parses the expression inside `${...}`. Format
+// specifications such as `${x:.2f}` are not translated.
+func placeholderExpression(src string) (ast.Expression, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 || len(program.Items) != 1 {
+		return nil, fmt.Errorf("interpolation %q", src)
+	}
+	stmt, ok := program.Items[0].(*ast.ExpressionStatement)
+	if !ok {
+		return nil, fmt.Errorf("interpolation %q", src)
+	}
+	return stmt.Expression, nil
+}
+
+func cStringValue(s string) string {
+	return fmt.Sprintf("sl_string_from(%s, %d)", cString(s), len(s))
+}
+
+// cString quotes s as a C string literal. Bytes outside printable ASCII use
+// octal escapes, which unlike hex escapes cannot swallow the next character.
+func cString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c == '?':
+			// Avoid forming trigraphs.
+			b.WriteString(`\?`)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// cVariable and cFunction prefix Selene names so they cannot collide with C
+// keywords or the standard library.
+func cVariable(name string) string { return "v_" + cIdentifier(name) }
+
+func cFunction(name string) string { return "fn_" + cIdentifier(name) }
+
+func cIdentifier(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r == '_' || r < 0x80 && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			continue
+		}
+		fmt.Fprintf(&b, "_u%04x", r)
+	}
+	return b.String()
+}
+
+func identName(id *ast.Identifier) string {
+	if id == nil {
+		return ""
+	}
+	return id.Name
+}
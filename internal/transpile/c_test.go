@@ -0,0 +1,177 @@
+package transpile
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cybellereaper/selenelang/internal/lexer"
+	"github.com/cybellereaper/selenelang/internal/parser"
+)
+
+// buildC transpiles source to C and compiles it with the system compiler,
+// skipping the test when there is none.
+func buildC(t *testing.T, source string) string {
+	t.Helper()
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler available")
+	}
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+	out, err := ToC(program)
+	if err != nil {
+		t.Fatalf("ToC returned error: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, CHeaderName), []byte(CHeader()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(dir, "main.c")
+	if err := os.WriteFile(src, []byte(out), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(dir, "main")
+	cmd := exec.Command(cc, "-std=c99", "-pedantic", "-Wall", "-Wextra", "-Werror", "-o", bin, src, "-lm")
+	if msg, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("compiling transpiled C failed: %v\n%s\n%s", err, msg, out)
+	}
+	return bin
+}
+
+func TestToCMatchesInterpreterOutput(t *testing.T) {
+	bin := buildC(t, `
+let count = 0;
+fn bump(by: Number): Number {
+    count += by;
+    return count;
+}
+fn init() {
+    print("init", count);
+}
+fn main(args: Array): Number {
+    let x = 1;
+    {
+        let x = x + 1; // shadows
+        print("inner", x);
+    }
+    print("outer", x, bump(2), bump(3), args.length);
+    let joined = "";
+    for (var i = 0; i < 5; i++) {
+        if (i == 1) { continue; }
+        if (i == 4) { break; }
+        joined = joined + i;
+    }
+    for (c in "hé") { print(c, null ?: "fallback"); }
+    print(joined, [1, "b", null][1] == "b" && !false, 3 >= 4 || null, 0 || "zero", false && bump(100));
+    print(f"count=${count}!", 10 / 4, 2 - 5 * 2, "héllo".length, 0.1 + 0.2);
+    return 7;
+}
+`)
+	var stdout strings.Builder
+	cmd := exec.Command(bin, "one", "two")
+	cmd.Stdout = &stdout
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 7 {
+		t.Fatalf("expected exit code 7 from main, got %v", err)
+	}
+	const expected = "init 0\n" +
+		"inner 2\n" +
+		"outer 1 2 5 2\n" +
+		"h fallback\n" +
+		"é fallback\n" +
+		"023 true null zero false\n" +
+		"count=5! 2.5 -8 6 0.30000000000000004\n"
+	if got := stdout.String(); got != expected {
+		t.Fatalf("unexpected output:\n--- got ---\n%s\n--- want ---\n%s", got, expected)
+	}
+}
+
+func TestToCReportsRuntimeErrorsAndUnsupportedCode(t *testing.T) {
+	cases := map[string]string{
+		`print("a"); print([1][3]);`:                 "runtime error: array index 3 out of range\n",
+		`print("a"); print(1 - "x");`:                "runtime error: operator - requires Number on right, got String\n",
+		`print("a"); try { print(1); } catch (e) {}`: "runtime error: selene transpiler: unsupported statement *ast.TryStatement\n",
+	}
+	for source, want := range cases {
+		bin := buildC(t, source)
+		var stdout, stderr strings.Builder
+		cmd := exec.Command(bin)
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err == nil {
+			t.Fatalf("expected %q to fail", source)
+		}
+		if stdout.String() != "a\n" || stderr.String() != want {
+			t.Fatalf("%s: got stdout %q, stderr %q; want stderr %q", source, stdout.String(), stderr.String(), want)
+		}
+	}
+}
+
+func TestToCJumpsOutOfLabelledLoops(t *testing.T) {
+	bin := buildC(t, `
+fn main(args: Array): Number {
+    var found = "";
+    outer: for (var i = 0; i < 4; i++) {
+        var j = 0;
+        while (j < 4) {
+            j++;
+            if (j == 2) { continue outer; }
+            if (i == 3) { break outer; }
+            found = found + i + j + " ";
+        }
+    }
+    rows: for (r in [1, 2, 3]) {
+        for (c in [1, 2, 3]) {
+            if (c > r) { continue rows; }
+            if (r == 3) { break rows; }
+            found = found + r + c + " ";
+        }
+    }
+    print(found);
+    return 0;
+}
+`)
+	out, err := exec.Command(bin).Output()
+	if err != nil {
+		t.Fatalf("running transpiled program failed: %v", err)
+	}
+	if want := "01 11 21 11 21 22 \n"; string(out) != want {
+		t.Fatalf("unexpected output %q, want %q", out, want)
+	}
+}
+
+func TestToCTestsDoWhileConditionsAfterTheBody(t *testing.T) {
+	bin := buildC(t, `
+fn main(args: Array): Number {
+    var seen = "";
+    var i = 0;
+    rows: do {
+        i++;
+        if (i == 2) { continue; }
+        var j = 0;
+        do {
+            j++;
+            if (i == 4) { break rows; }
+            seen = seen + i + j + " ";
+        } while (j < 2);
+    } while (i < 10);
+    do { seen = seen + "once"; } while (false);
+    print(seen);
+    return 0;
+}
+`)
+	out, err := exec.Command(bin).Output()
+	if err != nil {
+		t.Fatalf("running transpiled program failed: %v", err)
+	}
+	if want := "11 12 31 32 once\n"; string(out) != want {
+		t.Fatalf("unexpected output %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,65 @@
+package transpile
+
+import (
+	"strings"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/token"
+)
+
+// commentOutput is the generated code a commentQueue writes comments into.
+type commentOutput interface {
+	// commentLine writes text on a line of its own at the current indent.
+	commentLine(text string)
+	// lastLine returns the builder holding the line just written and
+	// whether that line is blank.
+	lastLine() (*strings.Builder, bool)
+}
+
+// commentQueue interleaves a program's comments with the code an emitter
+// writes. Emitters embed it so comment placement is decided in one place
+// for every target language.
+type commentQueue struct {
+	out commentOutput
+	// render converts a Selene comment into the target language.
+	render func(text string) string
+	// comments are the program's comments; those before next have been
+	// written.
+	comments []token.Comment
+	next     int
+}
+
+// flushComments writes the comments that start before pos, each on its own
+// line.
+func (q *commentQueue) flushComments(pos token.Position) {
+	for q.next < len(q.comments) && q.comments[q.next].Pos.Offset < pos.Offset {
+		q.out.commentLine(q.render(q.comments[q.next].Text))
+		q.next++
+	}
+}
+
+// trailingComments appends the comments on the line where node ends to the
+// line just written for it.
+func (q *commentQueue) trailingComments(node ast.Node) {
+	end := node.End()
+	b, blank := q.out.lastLine()
+	if end.Line == 0 || blank {
+		return
+	}
+	line := end.Line
+	if end.Column == 0 {
+		// The lexer reports the position of a newline as column 0 of
+		// the following line.
+		line--
+	}
+	for q.next < len(q.comments) {
+		c := q.comments[q.next]
+		if c.Pos.Offset < end.Offset || c.Pos.Line != line {
+			return
+		}
+		written := strings.TrimSuffix(b.String(), "\n")
+		b.Reset()
+		b.WriteString(written + " " + q.render(c.Text) + "\n")
+		q.next++
+	}
+}
@@ -0,0 +1,577 @@
+/*
+ * selene.h - runtime support for C generated by `selene transpile --lang c`.
+ *
+ * Values are tagged unions. Strings and arrays live on the heap with a
+ * reference count; every function below that takes an sl_value consumes the
+ * reference it is given unless its comment says the argument is borrowed,
+ * and every sl_value it returns is a new reference owned by the caller.
+ * Reference cycles between arrays are never freed.
+ *
+ * The header is self-contained C99 and only needs the standard library.
+ */
+#ifndef SELENE_H
+#define SELENE_H
+
+#include <math.h>
+#include <stdarg.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+typedef enum { SL_NULL, SL_BOOLEAN, SL_NUMBER, SL_STRING, SL_ARRAY } sl_kind;
+
+typedef struct sl_string sl_string;
+typedef struct sl_array sl_array;
+
+typedef struct {
+    sl_kind kind;
+    union {
+        int boolean;
+        double number;
+        sl_string *string;
+        sl_array *array;
+    } as;
+} sl_value;
+
+struct sl_string {
+    long refs;
+    size_t len;
+    char data[];
+};
+
+struct sl_array {
+    long refs;
+    size_t len;
+    size_t cap;
+    sl_value *items;
+};
+
+static inline void sl_release(sl_value v);
+static inline sl_value sl_inspect(sl_value v);
+
+/* sl_panic reports a runtime error the way the interpreter would and exits. */
+static void sl_panic(const char *format, ...) {
+    va_list args;
+    fflush(stdout);
+    va_start(args, format);
+    fputs("runtime error: ", stderr);
+    vfprintf(stderr, format, args);
+    fputc('\n', stderr);
+    va_end(args);
+    exit(1);
+}
+
+static inline sl_value sl_unsupported(const char *feature) {
+    sl_panic("selene transpiler: unsupported %s", feature);
+    return (sl_value){SL_NULL, {0}};
+}
+
+static inline void *sl_alloc(size_t size) {
+    void *p = malloc(size);
+    if (p == NULL) {
+        sl_panic("out of memory");
+    }
+    return p;
+}
+
+static inline sl_value sl_null(void) { return (sl_value){SL_NULL, {0}}; }
+
+static inline sl_value sl_bool(int b) {
+    sl_value v = {SL_BOOLEAN, {0}};
+    v.as.boolean = b != 0;
+    return v;
+}
+
+static inline sl_value sl_number(double n) {
+    sl_value v = {SL_NUMBER, {0}};
+    v.as.number = n;
+    return v;
+}
+
+static inline sl_string *sl_string_new(size_t len) {
+    sl_string *s = sl_alloc(sizeof(sl_string) + len + 1);
+    s->refs = 1;
+    s->len = len;
+    s->data[len] = '\0';
+    return s;
+}
+
+/* sl_string_from copies len bytes of data into a new string. */
+static inline sl_value sl_string_from(const char *data, size_t len) {
+    sl_value v = {SL_STRING, {0}};
+    v.as.string = sl_string_new(len);
+    memcpy(v.as.string->data, data, len);
+    return v;
+}
+
+static inline sl_value sl_array_new(size_t cap) {
+    sl_value v = {SL_ARRAY, {0}};
+    v.as.array = sl_alloc(sizeof(sl_array));
+    v.as.array->refs = 1;
+    v.as.array->len = 0;
+    v.as.array->cap = cap;
+    v.as.array->items = cap > 0 ? sl_alloc(cap * sizeof(sl_value)) : NULL;
+    return v;
+}
+
+static inline void sl_array_append(sl_array *a, sl_value item) {
+    if (a->len == a->cap) {
+        a->cap = a->cap == 0 ? 4 : a->cap * 2;
+        a->items = realloc(a->items, a->cap * sizeof(sl_value));
+        if (a->items == NULL) {
+            sl_panic("out of memory");
+        }
+    }
+    a->items[a->len++] = item;
+}
+
+/* sl_array_of builds an array from n element values. */
+static inline sl_value sl_array_of(int n, ...) {
+    sl_value v = sl_array_new((size_t)n);
+    va_list args;
+    va_start(args, n);
+    for (int i = 0; i < n; i++) {
+        sl_array_append(v.as.array, va_arg(args, sl_value));
+    }
+    va_end(args);
+    return v;
+}
+
+/* sl_retain returns a new reference to the borrowed value v. */
+static inline sl_value sl_retain(sl_value v) {
+    if (v.kind == SL_STRING) {
+        v.as.string->refs++;
+    } else if (v.kind == SL_ARRAY) {
+        v.as.array->refs++;
+    }
+    return v;
+}
+
+static inline void sl_release(sl_value v) {
+    if (v.kind == SL_STRING) {
+        if (--v.as.string->refs == 0) {
+            free(v.as.string);
+        }
+    } else if (v.kind == SL_ARRAY) {
+        sl_array *a = v.as.array;
+        if (--a->refs == 0) {
+            for (size_t i = 0; i < a->len; i++) {
+                sl_release(a->items[i]);
+            }
+            free(a->items);
+            free(a);
+        }
+    }
+}
+
+/* sl_assign stores v in *slot, releasing the old value, and returns v. */
+static inline sl_value sl_assign(sl_value *slot, sl_value v) {
+    sl_value old = *slot;
+    *slot = v;
+    sl_release(old);
+    return sl_retain(v);
+}
+
+static inline const char *sl_type_name(sl_value v) {
+    switch (v.kind) {
+    case SL_BOOLEAN:
+        return "Boolean";
+    case SL_NUMBER:
+        return "Number";
+    case SL_STRING:
+        return "String";
+    case SL_ARRAY:
+        return "Array";
+    default:
+        return "Null";
+    }
+}
+
+/* sl_truthy reports whether the borrowed v counts as true. */
+static inline int sl_truthy(sl_value v) {
+    switch (v.kind) {
+    case SL_BOOLEAN:
+        return v.as.boolean;
+    case SL_NUMBER:
+        return v.as.number != 0;
+    case SL_STRING:
+        return v.as.string->len > 0;
+    case SL_ARRAY:
+        return v.as.array->len > 0;
+    default:
+        return 0;
+    }
+}
+
+/* sl_test is sl_truthy for a value the caller owns. */
+static inline int sl_test(sl_value v) {
+    int result = sl_truthy(v);
+    sl_release(v);
+    return result;
+}
+
+/*
+ * sl_format_number writes n in the shortest decimal form that reads back
+ * as n, without an exponent, matching how the interpreter prints numbers.
+ */
+static inline sl_value sl_format_number(double n) {
+    if (isnan(n)) {
+        return sl_string_from("NaN", 3);
+    }
+    if (isinf(n)) {
+        return n > 0 ? sl_string_from("+Inf", 4) : sl_string_from("-Inf", 4);
+    }
+    char sci[40];
+    for (int precision = 0; precision < 17; precision++) {
+        snprintf(sci, sizeof sci, "%.*e", precision, n);
+        if (strtod(sci, NULL) == n) {
+            break;
+        }
+    }
+    char digits[24];
+    size_t ndigits = 0;
+    const char *p = sci;
+    int negative = *p == '-';
+    if (negative) {
+        p++;
+    }
+    for (; *p != 'e'; p++) {
+        if (*p != '.') {
+            digits[ndigits++] = *p;
+        }
+    }
+    int exponent = atoi(p + 1);
+    while (ndigits > 1 && digits[ndigits - 1] == '0') {
+        ndigits--;
+    }
+    if (ndigits == 1 && digits[0] == '0') {
+        exponent = 0;
+    }
+    size_t cap = ndigits + (size_t)(exponent < 0 ? -exponent : exponent) + 4;
+    char *out = sl_alloc(cap);
+    size_t len = 0;
+    if (negative) {
+        out[len++] = '-';
+    }
+    if (exponent < 0) {
+        out[len++] = '0';
+        out[len++] = '.';
+        for (int i = -1; i > exponent; i--) {
+            out[len++] = '0';
+        }
+        memcpy(out + len, digits, ndigits);
+        len += ndigits;
+    } else {
+        for (size_t i = 0; i < ndigits || (int)i <= exponent; i++) {
+            if ((int)i == exponent + 1) {
+                out[len++] = '.';
+            }
+            out[len++] = i < ndigits ? digits[i] : '0';
+        }
+    }
+    sl_value v = sl_string_from(out, len);
+    free(out);
+    return v;
+}
+
+static inline void sl_buffer_append(sl_value *buf, const char *data, size_t len) {
+    sl_string *old = buf->as.string;
+    sl_string *s = sl_string_new(old->len + len);
+    memcpy(s->data, old->data, old->len);
+    memcpy(s->data + old->len, data, len);
+    free(old);
+    buf->as.string = s;
+}
+
+/* sl_inspect renders the borrowed v as the interpreter prints it. */
+static inline sl_value sl_inspect(sl_value v) {
+    switch (v.kind) {
+    case SL_BOOLEAN:
+        return v.as.boolean ? sl_string_from("true", 4) : sl_string_from("false", 5);
+    case SL_NUMBER:
+        return sl_format_number(v.as.number);
+    case SL_STRING:
+        return sl_retain(v);
+    case SL_ARRAY: {
+        sl_value buf = sl_string_from("[", 1);
+        for (size_t i = 0; i < v.as.array->len; i++) {
+            if (i > 0) {
+                sl_buffer_append(&buf, ", ", 2);
+            }
+            sl_value item = sl_inspect(v.as.array->items[i]);
+            sl_buffer_append(&buf, item.as.string->data, item.as.string->len);
+            sl_release(item);
+        }
+        sl_buffer_append(&buf, "]", 1);
+        return buf;
+    }
+    default:
+        return sl_string_from("null", 4);
+    }
+}
+
+/* sl_print writes n values separated by spaces and ends the line. */
+static inline sl_value sl_print(int n, ...) {
+    va_list args;
+    va_start(args, n);
+    for (int i = 0; i < n; i++) {
+        sl_value arg = va_arg(args, sl_value);
+        sl_value text = sl_inspect(arg);
+        if (i > 0) {
+            fputc(' ', stdout);
+        }
+        fwrite(text.as.string->data, 1, text.as.string->len, stdout);
+        sl_release(text);
+        sl_release(arg);
+    }
+    va_end(args);
+    fputc('\n', stdout);
+    return sl_null();
+}
+
+/* sl_throw reports an uncaught thrown value and exits. */
+static inline sl_value sl_throw(sl_value v) {
+    sl_value text = sl_inspect(v);
+    sl_panic("uncaught exception: %s", text.as.string->data);
+    return sl_null();
+}
+
+static inline sl_value sl_concat(sl_value left, sl_value right) {
+    sl_value l = sl_inspect(left);
+    sl_value r = sl_inspect(right);
+    sl_value out = sl_string_from(l.as.string->data, l.as.string->len);
+    sl_buffer_append(&out, r.as.string->data, r.as.string->len);
+    sl_release(l);
+    sl_release(r);
+    sl_release(left);
+    sl_release(right);
+    return out;
+}
+
+static inline sl_value sl_add(sl_value left, sl_value right) {
+    if (left.kind == SL_NUMBER) {
+        if (right.kind != SL_NUMBER) {
+            sl_panic("cannot add %s to Number", sl_type_name(right));
+        }
+        return sl_number(left.as.number + right.as.number);
+    }
+    if (left.kind == SL_STRING || right.kind == SL_STRING) {
+        return sl_concat(left, right);
+    }
+    sl_panic("operator + not supported for %s", sl_type_name(left));
+    return sl_null();
+}
+
+static inline void sl_check_numbers(const char *op, sl_value left, sl_value right) {
+    if (left.kind != SL_NUMBER) {
+        sl_panic("operator %s not supported for %s", op, sl_type_name(left));
+    }
+    if (right.kind != SL_NUMBER) {
+        sl_panic("operator %s requires Number on right, got %s", op, sl_type_name(right));
+    }
+}
+
+/* sl_increment adds delta to the Number v for the ++ and -- operators. */
+static inline sl_value sl_increment(const char *op, sl_value v, double delta) {
+    if (v.kind != SL_NUMBER) {
+        sl_panic("operator %s requires a Number, got %s", op, sl_type_name(v));
+    }
+    return sl_number(v.as.number + delta);
+}
+
+static inline sl_value sl_sub(sl_value left, sl_value right) {
+    sl_check_numbers("-", left, right);
+    return sl_number(left.as.number - right.as.number);
+}
+
+static inline sl_value sl_mul(sl_value left, sl_value right) {
+    sl_check_numbers("*", left, right);
+    return sl_number(left.as.number * right.as.number);
+}
+
+static inline sl_value sl_div(sl_value left, sl_value right) {
+    sl_check_numbers("/", left, right);
+    if (right.as.number == 0) {
+        sl_panic("division by zero");
+    }
+    return sl_number(left.as.number / right.as.number);
+}
+
+static inline sl_value sl_mod(sl_value left, sl_value right) {
+    sl_check_numbers("%", left, right);
+    if (right.as.number == 0) {
+        sl_panic("modulo by zero");
+    }
+    return sl_number(fmod(left.as.number, right.as.number));
+}
+
+static inline sl_value sl_neg(sl_value v) {
+    if (v.kind != SL_NUMBER) {
+        sl_panic("cannot negate %s", sl_type_name(v));
+    }
+    return sl_number(-v.as.number);
+}
+
+static inline sl_value sl_pos(sl_value v) {
+    if (v.kind != SL_NUMBER) {
+        sl_panic("cannot apply unary + to %s", sl_type_name(v));
+    }
+    return v;
+}
+
+static inline sl_value sl_not(sl_value v) { return sl_bool(!sl_test(v)); }
+
+static inline int sl_equal(sl_value left, sl_value right) {
+    int result = 0;
+    if (left.kind == right.kind) {
+        switch (left.kind) {
+        case SL_NULL:
+            result = 1;
+            break;
+        case SL_BOOLEAN:
+            result = left.as.boolean == right.as.boolean;
+            break;
+        case SL_NUMBER:
+            result = left.as.number == right.as.number;
+            break;
+        case SL_STRING:
+            result = left.as.string->len == right.as.string->len &&
+                     memcmp(left.as.string->data, right.as.string->data, left.as.string->len) == 0;
+            break;
+        case SL_ARRAY:
+            result = left.as.array == right.as.array;
+            break;
+        }
+    }
+    sl_release(left);
+    sl_release(right);
+    return result;
+}
+
+static inline sl_value sl_eq(sl_value left, sl_value right) { return sl_bool(sl_equal(left, right)); }
+
+static inline sl_value sl_ne(sl_value left, sl_value right) { return sl_bool(!sl_equal(left, right)); }
+
+static inline void sl_check_comparison(const char *op, sl_value left, sl_value right) {
+    if (left.kind != SL_NUMBER || right.kind != SL_NUMBER) {
+        sl_panic("operator %s requires Number operands", op);
+    }
+}
+
+static inline sl_value sl_lt(sl_value left, sl_value right) {
+    sl_check_comparison("<", left, right);
+    return sl_bool(left.as.number < right.as.number);
+}
+
+static inline sl_value sl_le(sl_value left, sl_value right) {
+    sl_check_comparison("<=", left, right);
+    return sl_bool(left.as.number <= right.as.number);
+}
+
+static inline sl_value sl_gt(sl_value left, sl_value right) {
+    sl_check_comparison(">", left, right);
+    return sl_bool(left.as.number > right.as.number);
+}
+
+static inline sl_value sl_ge(sl_value left, sl_value right) {
+    sl_check_comparison(">=", left, right);
+    return sl_bool(left.as.number >= right.as.number);
+}
+
+/* sl_utf8_next returns the length of the UTF-8 sequence starting at s. */
+static inline size_t sl_utf8_next(const sl_string *s, size_t at) {
+    unsigned char c = (unsigned char)s->data[at];
+    size_t n = c < 0x80 ? 1 : c < 0xE0 ? 2 : c < 0xF0 ? 3 : 4;
+    return at + n > s->len ? s->len - at : n;
+}
+
+static inline size_t sl_rune_count(const sl_string *s) {
+    size_t count = 0;
+    for (size_t at = 0; at < s->len; at += sl_utf8_next(s, at)) {
+        count++;
+    }
+    return count;
+}
+
+/* sl_count is the number of elements a for-in loop visits in borrowed v. */
+static inline size_t sl_count(sl_value v) {
+    if (v.kind == SL_ARRAY) {
+        return v.as.array->len;
+    }
+    if (v.kind == SL_STRING) {
+        return sl_rune_count(v.as.string);
+    }
+    sl_panic("cannot iterate over %s", sl_type_name(v));
+    return 0;
+}
+
+/* sl_at returns element i of the borrowed array or string v. */
+static inline sl_value sl_at(sl_value v, size_t i) {
+    if (v.kind == SL_ARRAY) {
+        return sl_retain(v.as.array->items[i]);
+    }
+    size_t at = 0;
+    while (i-- > 0) {
+        at += sl_utf8_next(v.as.string, at);
+    }
+    return sl_string_from(v.as.string->data + at, sl_utf8_next(v.as.string, at));
+}
+
+static inline sl_value sl_index(sl_value collection, sl_value index) {
+    const char *what = collection.kind == SL_ARRAY ? "array" : "string";
+    if (collection.kind != SL_ARRAY && collection.kind != SL_STRING) {
+        sl_panic("cannot index into %s", sl_type_name(collection));
+    }
+    if (index.kind != SL_NUMBER) {
+        sl_panic("%s index must be Number, got %s", what, sl_type_name(index));
+    }
+    double n = index.as.number;
+    if (n != floor(n)) {
+        sl_panic("%s index must be integer", what);
+    }
+    if (n < 0 || n >= (double)sl_count(collection)) {
+        sl_panic("%s index %.0f out of range", what, n);
+    }
+    sl_value item = sl_at(collection, (size_t)n);
+    sl_release(collection);
+    return item;
+}
+
+static inline sl_value sl_length(sl_value v) {
+    size_t len;
+    if (v.kind == SL_ARRAY) {
+        len = v.as.array->len;
+    } else if (v.kind == SL_STRING) {
+        len = v.as.string->len;
+    } else {
+        sl_panic("unknown property length on %s", sl_type_name(v));
+        return sl_null();
+    }
+    sl_release(v);
+    return sl_number((double)len);
+}
+
+/* sl_args wraps the process arguments after the program name as an array. */
+static inline sl_value sl_args(int argc, char **argv) {
+    sl_value v = sl_array_new(argc > 1 ? (size_t)(argc - 1) : 0);
+    for (int i = 1; i < argc; i++) {
+        sl_array_append(v.as.array, sl_string_from(argv[i], strlen(argv[i])));
+    }
+    return v;
+}
+
+/* sl_exit_code converts the value main returned into a process status. */
+static inline int sl_exit_code(sl_value v) {
+    int code = 0;
+    if (v.kind == SL_NUMBER) {
+        if (v.as.number != trunc(v.as.number)) {
+            sl_value text = sl_format_number(v.as.number);
+            sl_panic("main returned %s, exit codes must be integers", text.as.string->data);
+        }
+        code = (int)v.as.number;
+    }
+    sl_release(v);
+    return code;
+}
+
+#endif /* SELENE_H */
@@ -1,21 +1,24 @@
-// Package transpile converts Selene programs into Go source code.
+// Package transpile converts Selene programs into Go or C source code.
 package transpile
 
 import (
 	"fmt"
 	"maps"
+	"math"
 	"slices"
 	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/consteval"
 	"github.com/cybellereaper/selenelang/internal/token"
 )
 
 // ToGo converts a Selene program into Go source code.
 func ToGo(program *ast.Program) (string, error) {
 	pkgName := "main"
+	var pkgPos *token.Position
 	imports := make(map[string]string)
 	items := make([]ast.ProgramItem, 0, len(program.Items))
 	for _, item := range program.Items {
@@ -24,6 +27,8 @@ func ToGo(program *ast.Program) (string, error) {
 			if node.Name != nil && node.Name.Name != "" {
 				pkgName = node.Name.Name
 			}
+			pos := node.Pos()
+			pkgPos = &pos
 		case *ast.ImportDeclaration:
 			path := node.PathLiteral
 			if path == "" {
@@ -44,7 +49,14 @@ func ToGo(program *ast.Program) (string, error) {
 	}
 
 	emitter := &goEmitter{}
+	emitter.commentQueue = commentQueue{out: emitter, render: goComment, comments: program.Comments}
 	emitter.writeLine("// Code generated by selene transpile. DO NOT EDIT.")
+	if pkgPos != nil && len(program.Comments) > 0 && program.Comments[0].Pos.Offset < pkgPos.Offset {
+		// A blank line keeps the generated-code marker out of the
+		// package comment.
+		emitter.ensureBlankLine()
+		emitter.flushComments(*pkgPos)
+	}
 	emitter.writeLine(fmt.Sprintf("package %s", pkgName))
 	emitter.writeLine("")
 
@@ -69,6 +81,7 @@ func ToGo(program *ast.Program) (string, error) {
 	for _, item := range items {
 		emitter.emitProgramItem(item)
 	}
+	emitter.flushComments(token.Position{Offset: math.MaxInt})
 
 	if emitter.usesElvis {
 		emitter.ensureBlankLine()
@@ -103,6 +116,7 @@ type goEmitter struct {
 	needsHelper bool
 	usesElvis   bool
 	lastBlank   bool
+	commentQueue
 }
 
 func (e *goEmitter) writeLine(parts ...string) {
@@ -131,7 +145,21 @@ func (e *goEmitter) ensureBlankLine() {
 	e.lastBlank = true
 }
 
+func (e *goEmitter) commentLine(text string) { e.writeLine(text) }
+
+func (e *goEmitter) lastLine() (*strings.Builder, bool) { return &e.builder, e.lastBlank }
+
+// goComment renders a Selene comment as a Go comment. Doc comments lose
+// their third slash so gofmt and godoc treat them as ordinary doc comments.
+func goComment(text string) string {
+	if strings.HasPrefix(text, "///") && !strings.HasPrefix(text, "////") {
+		return "//" + text[3:]
+	}
+	return text
+}
+
 func (e *goEmitter) emitProgramItem(item ast.ProgramItem) {
+	e.flushComments(item.Pos())
 	switch node := item.(type) {
 	case ast.Statement:
 		e.emitStatement(node)
@@ -144,6 +172,9 @@ func (e *goEmitter) emitProgramItem(item ast.ProgramItem) {
 	default:
 		e.unsupportedStmt(fmt.Sprintf("program item %T", item))
 	}
+	if _, ok := item.(ast.Statement); !ok {
+		e.trailingComments(item)
+	}
 	e.ensureBlankLine()
 }
 
@@ -154,10 +185,16 @@ func (e *goEmitter) emitStatements(stmts []ast.Statement) {
 }
 
 func (e *goEmitter) emitStatement(stmt ast.Statement) {
+	e.flushComments(stmt.Pos())
+	defer e.trailingComments(stmt)
 	switch node := stmt.(type) {
 	case *ast.FunctionDeclaration:
 		e.emitFunction(node)
 	case *ast.VariableDeclaration:
+		if node.Pattern != nil {
+			e.unsupportedStmt("destructuring")
+			return
+		}
 		name := "value"
 		if node.Name != nil && node.Name.Name != "" {
 			name = node.Name.Name
@@ -175,6 +212,7 @@ func (e *goEmitter) emitStatement(stmt ast.Statement) {
 		e.writeLine("{")
 		e.indent++
 		e.emitStatements(node.Statements)
+		e.flushComments(node.End())
 		e.indent--
 		e.writeLine("}")
 	case *ast.ReturnStatement:
@@ -201,11 +239,20 @@ func (e *goEmitter) emitStatement(stmt ast.Statement) {
 		if node.Condition != nil {
 			cond = e.expression(node.Condition)
 		}
+		e.writeLabel(node.Label, node.Body)
 		e.writeLine("for ", cond, " {")
 		e.indent++
 		e.emitBranch(node.Body)
 		e.indent--
 		e.writeLine("}")
+	case *ast.DoWhileStatement:
+		// The first pass skips the condition; continue still tests it.
+		e.writeLabel(node.Label, node.Body)
+		e.writeLine("for doFirst := true; doFirst || ", e.expression(node.Condition), "; doFirst = false {")
+		e.indent++
+		e.emitBranch(node.Body)
+		e.indent--
+		e.writeLine("}")
 	case *ast.ForStatement:
 		init := e.forInit(node.Init)
 		cond := ""
@@ -216,26 +263,49 @@ func (e *goEmitter) emitStatement(stmt ast.Statement) {
 		if node.Post != nil {
 			post = e.expression(node.Post)
 		}
+		e.writeLabel(node.Label, node.Body)
 		e.writeLine("for ", init, "; ", cond, "; ", post, " {")
 		e.indent++
 		e.emitBranch(node.Body)
 		e.indent--
 		e.writeLine("}")
 	case *ast.BreakStatement:
-		e.writeLine("break")
+		e.writeLine(strings.TrimSpace("break " + node.Label))
 	case *ast.ContinueStatement:
-		e.writeLine("continue")
+		e.writeLine(strings.TrimSpace("continue " + node.Label))
 	default:
 		e.unsupportedStmt(fmt.Sprintf("statement %T", stmt))
 	}
 }
 
+// writeLabel writes the label of a loop whose body jumps to it. Go rejects
+// labels that nothing uses, so the label is left out otherwise.
+func (e *goEmitter) writeLabel(label string, body ast.Statement) {
+	if label == "" || body == nil {
+		return
+	}
+	used := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BreakStatement:
+			used = used || n.Label == label
+		case *ast.ContinueStatement:
+			used = used || n.Label == label
+		}
+		return !used
+	})
+	if used {
+		e.writeLine(label, ":")
+	}
+}
+
 func (e *goEmitter) emitBranch(stmt ast.Statement) {
 	if stmt == nil {
 		return
 	}
 	if block, ok := stmt.(*ast.BlockStatement); ok {
 		e.emitStatements(block.Statements)
+		e.flushComments(block.End())
 		return
 	}
 	e.emitStatement(stmt)
@@ -274,6 +344,7 @@ func (e *goEmitter) emitFunction(fn *ast.FunctionDeclaration) {
 		}
 	} else if fn.Body != nil {
 		e.emitStatements(fn.Body.Statements)
+		e.flushComments(fn.Body.End())
 	} else {
 		e.writeLine("return nil")
 	}
@@ -322,6 +393,9 @@ func (e *goEmitter) expression(expr ast.Expression) string {
 	case *ast.NumberLiteral:
 		return node.Value
 	case *ast.StringLiteral:
+		if v, err := consteval.Eval(node, nil); err == nil {
+			return strconv.Quote(v.String)
+		}
 		return strconv.Quote(node.Value)
 	case *ast.BooleanLiteral:
 		if node.Value {
@@ -345,7 +419,14 @@ func (e *goEmitter) expression(expr ast.Expression) string {
 	case *ast.PrefixExpression:
 		return fmt.Sprintf("(%s%s)", node.Operator, e.expression(node.Right))
 	case *ast.InfixExpression:
+		switch node.Operator {
+		case "..", "..=", "in":
+			e.needsHelper = true
+			return fmt.Sprintf("seleneUnsupported(\"operator %s\")", node.Operator)
+		}
 		return fmt.Sprintf("(%s %s %s)", e.expression(node.Left), mapOperator(node.Operator), e.expression(node.Right))
+	case *ast.IncrementExpression:
+		return e.expression(node.Target) + node.Operator
 	case *ast.AssignmentExpression:
 		target := e.expression(node.Target)
 		value := e.expression(node.Value)
@@ -364,6 +445,10 @@ func (e *goEmitter) expression(expr ast.Expression) string {
 		}
 		return fmt.Sprintf("%s(%s)", e.expression(node.Callee), strings.Join(args, ", "))
 	case *ast.IndexExpression:
+		if node.Optional {
+			e.needsHelper = true
+			return "seleneUnsupported(\"optional access\")"
+		}
 		return fmt.Sprintf("%s[%s]", e.expression(node.Collection), e.expression(node.Index))
 	case *ast.MemberExpression:
 		if node.Optional {
@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/lexer"
+	"github.com/cybellereaper/selenelang/internal/parser"
 )
 
 func TestToGoProducesDeterministicProgram(t *testing.T) {
@@ -45,3 +47,41 @@ func TestToGoProducesDeterministicProgram(t *testing.T) {
 		t.Fatalf("transpiled output contains excessive blank lines: %q", out)
 	}
 }
+
+func TestToGoKeepsComments(t *testing.T) {
+	source := "// Tools.\npackage tools\n\n/// Says hello.\nfn main() {\n    // greet\n    print(\"hi\"); // inline\n    /* done */\n}\n// end\n"
+	program := parser.New(lexer.New(source)).ParseProgram()
+	out, err := ToGo(program)
+	if err != nil {
+		t.Fatalf("ToGo returned error: %v", err)
+	}
+	const expected = "// Code generated by selene transpile. DO NOT EDIT.\n\n" +
+		"// Tools.\npackage tools\n\n" +
+		"// Says hello.\nfunc main() {\n\t// greet\n\tprint(\"hi\") // inline\n\t/* done */\n}\n\n" +
+		"// end\n"
+	if out != expected {
+		t.Fatalf("unexpected transpiled output:\n--- got ---\n%s\n--- want ---\n%s", out, expected)
+	}
+}
+
+func TestToGoDecodesStringEscapes(t *testing.T) {
+	program := parser.New(lexer.New(`fn main() { print("caf\u{e9}\x21\t\0"); }`)).ParseProgram()
+	out, err := ToGo(program)
+	if err != nil {
+		t.Fatalf("ToGo returned error: %v", err)
+	}
+	if want := `print("café!\t\x00")`; !strings.Contains(out, want) {
+		t.Fatalf("expected %s in:\n%s", want, out)
+	}
+}
+
+func TestToGoWritesLoopLabelsThatAreUsed(t *testing.T) {
+	program := parser.New(lexer.New("fn main() {\n    outer: while (true) {\n        inner: while (true) {\n            break outer;\n        }\n    }\n}\n")).ParseProgram()
+	out, err := ToGo(program)
+	if err != nil {
+		t.Fatalf("ToGo returned error: %v", err)
+	}
+	if !strings.Contains(out, "\touter:\n\tfor true {") || !strings.Contains(out, "break outer\n") || strings.Contains(out, "inner:") {
+		t.Fatalf("expected only the used label in:\n%s", out)
+	}
+}
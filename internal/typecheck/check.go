@@ -0,0 +1,865 @@
+package typecheck
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+)
+
+type checker struct {
+	info *Info
+	// globals are the names bound before the program runs, or nil when
+	// identifiers are not checked.
+	globals map[string]bool
+	// inModule is set while the body of a module declaration is checked.
+	inModule bool
+	imports  []imported
+}
+
+// imported is an import and the entry it binds, to report it if nothing
+// looks the entry up.
+type imported struct {
+	node  *ast.ImportDeclaration
+	name  string
+	entry *entry
+}
+
+func (c *checker) report(err *Error) {
+	c.info.Errors = append(c.info.Errors, err)
+}
+
+func (c *checker) mismatch(node ast.Node, format string, args ...any) {
+	c.report(&Error{Kind: Mismatch, Node: node, Message: fmt.Sprintf(format, args...)})
+}
+
+// undefined reports id if identifiers are checked and nothing binds it.
+func (c *checker) undefined(id *ast.Identifier, s *scope) {
+	if c.globals == nil || c.globals[id.Name] || s.bound(id.Name) {
+		return
+	}
+	c.report(&Error{Kind: Undefined, Node: id, Name: id.Name, Message: "undefined identifier " + id.Name})
+}
+
+func (c *checker) unusedImports() {
+	for _, imp := range c.imports {
+		if !imp.entry.used {
+			c.report(&Error{Kind: UnusedImport, Node: imp.node, Name: imp.name, Message: fmt.Sprintf("%s is imported but never used", imp.name)})
+		}
+	}
+}
+
+// hoist declares the functions and types of a block before its statements
+// are checked, so that a call may come before the declaration it calls.
+// Types are declared first because signatures refer to them. Imports are
+// declared here too, so that annotations may name what they import.
+func (c *checker) hoist(stmts []ast.Statement, s *scope) {
+	for _, stmt := range stmts {
+		for _, name := range declaredNames(stmt) {
+			s.hoisted[name] = true
+		}
+		switch node := stmt.(type) {
+		case *ast.ImportDeclaration:
+			e := &entry{}
+			name := importName(node)
+			s.declare(name, e)
+			if !c.inModule && name != "" {
+				c.imports = append(c.imports, imported{node: node, name: name, entry: e})
+			}
+		case *ast.StructDeclaration:
+			s.declare(identName(node.Name), &entry{def: &definition{name: identName(node.Name), kind: "struct"}})
+		case *ast.ClassDeclaration:
+			def := &definition{name: identName(node.Name), kind: "class", params: identNames(node.TypeParams), abstract: node.Abstract, contracts: identNames(node.Implements)}
+			if node.SuperClass != nil {
+				def.super = node.SuperClass.Name
+			}
+			s.declare(def.name, &entry{def: def})
+		case *ast.EnumDeclaration:
+			s.declare(identName(node.Name), &entry{def: &definition{name: identName(node.Name), kind: "enum", params: identNames(node.TypeParams)}})
+		case *ast.InterfaceDeclaration:
+			s.declare(identName(node.Name), &entry{def: &definition{name: identName(node.Name), kind: "interface"}})
+		case *ast.ContractDeclaration:
+			s.declare(identName(node.Name), &entry{def: &definition{name: identName(node.Name), kind: "contract"}})
+		}
+	}
+	for _, stmt := range stmts {
+		switch node := stmt.(type) {
+		case *ast.StructDeclaration:
+			c.members(s.definition(identName(node.Name)), node.Params, node.Body, s)
+		case *ast.ClassDeclaration:
+			c.members(s.definition(identName(node.Name)), node.Params, node.Body, s)
+		case *ast.EnumDeclaration:
+			def := s.definition(identName(node.Name))
+			if def == nil {
+				continue
+			}
+			inner := newScope(s)
+			inner.declareParams(def.params)
+			def.cases = make(map[string][]field, len(node.Cases))
+			for _, ec := range node.Cases {
+				fields := make([]field, 0, len(ec.Params))
+				for _, param := range ec.Params {
+					fields = append(fields, field{name: identName(param.Name), typ: c.typeOf(param.Type, inner)})
+				}
+				def.cases[identName(ec.Name)] = fields
+			}
+			for _, fn := range node.Methods {
+				sig, _ := c.signature(fn, s, def)
+				def.addMethod(identName(fn.Name), sig)
+			}
+		case *ast.FunctionDeclaration:
+			if node.Accessor != "" {
+				continue
+			}
+			if !node.IsExtension {
+				sig, _ := c.signature(node, s, nil)
+				s.declare(identName(node.Name), &entry{fn: sig})
+				continue
+			}
+			if node.Receiver != nil && node.Receiver.Name != nil {
+				if def := s.definition(node.Receiver.Name.Name); def != nil {
+					sig, _ := c.signature(node, s, nil)
+					def.addMethod(identName(node.Name), sig)
+				}
+			}
+		case *ast.ContractDeclaration:
+			// The methods of a contract are the functions it requires.
+			def := s.definition(identName(node.Name))
+			if def == nil || node.Body == nil {
+				continue
+			}
+			for _, stmt := range node.Body.Statements {
+				if fn, ok := stmt.(*ast.FunctionDeclaration); ok && fn.Body == nil && !fn.IsExprBody {
+					sig, _ := c.signature(fn, s, def)
+					def.addMethod(identName(fn.Name), sig)
+				}
+			}
+		case *ast.ImplDeclaration:
+			if node.Target == nil || node.Body == nil {
+				continue
+			}
+			if def := s.definition(node.Target.Name); def != nil {
+				for _, stmt := range node.Body.Statements {
+					if fn, ok := stmt.(*ast.FunctionDeclaration); ok && !fn.IsExtension {
+						sig, _ := c.signature(fn, s, def)
+						def.addMethod(identName(fn.Name), sig)
+					}
+				}
+			}
+		}
+	}
+}
+
+// members records the constructor parameters and the methods of a struct
+// or class.
+func (c *checker) members(def *definition, params []ast.Parameter, body *ast.BlockStatement, s *scope) {
+	if def == nil {
+		return
+	}
+	inner := newScope(s)
+	inner.declareParams(def.params)
+	def.fields = def.fields[:0]
+	for _, param := range params {
+		def.fields = append(def.fields, field{name: identName(param.Name), typ: c.typeOf(param.Type, inner)})
+	}
+	if body == nil {
+		return
+	}
+	for _, stmt := range body.Statements {
+		// Accessors are read and assigned like fields, not called, and static
+		// methods are called on the type, so neither are methods.
+		if fn, ok := stmt.(*ast.FunctionDeclaration); ok && !fn.IsExtension && fn.Accessor == "" && !fn.Static {
+			sig, _ := c.signature(fn, s, def)
+			def.addMethod(identName(fn.Name), sig)
+			if fn.Abstract {
+				if def.abstractMethods == nil {
+					def.abstractMethods = make(map[string]bool)
+				}
+				def.abstractMethods[identName(fn.Name)] = true
+			}
+		}
+	}
+}
+
+// signature describes fn, declared as a method of owner when owner is not
+// nil. It also returns the scope that binds fn's type parameters, for
+// checking its body.
+func (c *checker) signature(fn *ast.FunctionDeclaration, s *scope, owner *definition) (*signature, *scope) {
+	inner := newScope(s)
+	sig := &signature{name: identName(fn.Name)}
+	switch {
+	case owner != nil:
+		inner.declareParams(owner.params)
+		sig.typeParams = append(sig.typeParams, owner.params...)
+		sig.receiver = owner.self()
+		sig.name = owner.name + "." + sig.name
+	case fn.IsExtension && fn.Receiver != nil && fn.Receiver.Name != nil:
+		// An extension of a generic type introduces the type parameters
+		// its receiver names: ext fn Box<T>.get(): T.
+		for _, arg := range fn.Receiver.TypeArgs {
+			if arg.Name == nil || len(arg.TypeArgs) > 0 || inner.lookup(arg.Name.Name) != nil || builtinTypes[canonical(arg.Name.Name)] {
+				continue
+			}
+			inner.declareParams([]string{arg.Name.Name})
+			sig.typeParams = append(sig.typeParams, arg.Name.Name)
+		}
+		sig.receiver = c.typeOf(fn.Receiver, inner)
+		sig.name = fn.Receiver.Name.Name + "." + sig.name
+	}
+	own := identNames(fn.TypeParams)
+	inner.declareParams(own)
+	sig.typeParams = append(sig.typeParams, own...)
+	for _, param := range fn.Params {
+		sig.params = append(sig.params, field{name: identName(param.Name), typ: c.typeOf(param.Type, inner)})
+	}
+	sig.result = c.typeOf(fn.ReturnType, inner)
+	return sig, inner
+}
+
+// typeOf converts an annotation to the type it names. Any is not a
+// constraint, so it converts to nil like a missing annotation.
+func (c *checker) typeOf(ann *ast.TypeAnnotation, s *scope) *Type {
+	if ann == nil || ann.Name == nil || ann.Name.Name == "Any" {
+		return nil
+	}
+	t := &Type{Name: ann.Name.Name, Nullable: ann.Nullable}
+	if e := s.lookup(t.Name); e != nil && e.param {
+		t.Param = true
+	}
+	for _, arg := range ann.TypeArgs {
+		t.Args = append(t.Args, c.typeOf(arg, s))
+	}
+	return t
+}
+
+// annotation reports type arguments given to a type that declares a
+// different number of type parameters. A generic type written without any,
+// as in `fn unwrap(box: Box)`, leaves them unknown and is accepted.
+func (c *checker) annotation(ann *ast.TypeAnnotation, s *scope) {
+	if ann == nil || ann.Name == nil {
+		return
+	}
+	for _, arg := range ann.TypeArgs {
+		c.annotation(arg, s)
+	}
+	got := len(ann.TypeArgs)
+	if got == 0 {
+		return
+	}
+	e := s.lookup(ann.Name.Name)
+	var want int
+	switch {
+	case e == nil:
+		return
+	case e.param:
+		want = 0
+	case e.def != nil:
+		want = len(e.def.params)
+	default:
+		return
+	}
+	if got != want {
+		c.report(&Error{
+			Kind:    TypeArgumentCount,
+			Node:    ann,
+			Message: fmt.Sprintf("wrong number of type arguments for %s: want %d, got %d", ann.Name.Name, want, got),
+			Type:    ann.Name.Name,
+			Want:    want,
+			Got:     got,
+		})
+	}
+}
+
+func (c *checker) block(stmts []ast.Statement, s *scope) {
+	c.hoist(stmts, s)
+	for _, stmt := range stmts {
+		c.stmt(stmt, s)
+	}
+}
+
+func (c *checker) stmt(stmt ast.Statement, s *scope) {
+	for _, a := range ast.Annotations(stmt) {
+		for _, arg := range a.Arguments {
+			c.expr(arg, s)
+		}
+	}
+	switch node := stmt.(type) {
+	case *ast.BlockStatement:
+		c.block(node.Statements, newScope(s))
+	case *ast.ExpressionStatement:
+		c.expr(node.Expression, s)
+	case *ast.IfStatement:
+		c.expr(node.Condition, s)
+		c.stmt(node.Consequence, s)
+		c.stmt(node.Alternative, s)
+	case *ast.WhileStatement:
+		c.expr(node.Condition, s)
+		c.stmt(node.Body, s)
+	case *ast.DoWhileStatement:
+		if node.Body != nil {
+			c.block(node.Body.Statements, newScope(s))
+		}
+		c.expr(node.Condition, s)
+	case *ast.ForStatement:
+		inner := newScope(s)
+		c.stmt(node.Init, inner)
+		c.expr(node.Condition, inner)
+		c.expr(node.Post, inner)
+		c.stmt(node.Body, inner)
+	case *ast.ForInStatement:
+		c.expr(node.Iterable, s)
+		inner := newScope(s)
+		inner.declare(identName(node.Binding), &entry{})
+		if node.Body != nil {
+			c.block(node.Body.Statements, inner)
+		}
+	case *ast.ReturnStatement:
+		c.returned(s, c.expr(node.Value, s), node.Value)
+	case *ast.ThrowStatement:
+		c.expr(node.Value, s)
+	case *ast.UsingStatement:
+		t := c.expr(node.Value, s)
+		inner := newScope(s)
+		inner.declare(identName(node.Name), &entry{typ: t})
+		if node.Body != nil {
+			c.block(node.Body.Statements, inner)
+		}
+	case *ast.TryStatement:
+		if node.Body != nil {
+			c.block(node.Body.Statements, newScope(s))
+		}
+		for _, clause := range node.Catches {
+			if clause.Body == nil {
+				continue
+			}
+			c.annotation(clause.Type, s)
+			inner := newScope(s)
+			inner.declare(identName(clause.Identifier), &entry{typ: c.typeOf(clause.Type, s)})
+			c.block(clause.Body.Statements, inner)
+		}
+		if node.Finally != nil {
+			c.block(node.Finally.Statements, newScope(s))
+		}
+	case *ast.ConditionStatement:
+		for _, clause := range node.Clauses {
+			c.expr(clause.Test, s)
+			c.stmt(clause.Body, s)
+		}
+		c.stmt(node.Else, s)
+	case *ast.MatchStatement:
+		c.expr(node.Value, s)
+		c.arms(node)
+		for _, mc := range node.Cases {
+			for _, pattern := range mc.Patterns {
+				c.pattern(pattern, s)
+			}
+			inner := newScope(s)
+			if len(mc.Patterns) > 0 {
+				for _, id := range ast.PatternBindings(mc.Patterns[0]) {
+					inner.declare(id.Name, &entry{})
+				}
+			}
+			c.stmt(mc.Body, inner)
+		}
+		c.stmt(node.Else, s)
+	case *ast.VariableDeclaration:
+		c.variable(node, s)
+	case *ast.FunctionDeclaration:
+		c.function(node, s, nil)
+	case *ast.ClassDeclaration:
+		if node.SuperClass != nil {
+			c.expr(node.SuperClass, s)
+		}
+		for _, name := range node.Implements {
+			c.expr(name, s)
+		}
+		def := s.definition(identName(node.Name))
+		c.typeBody(def, node.Params, node.Body, node.Invariants, s)
+		c.inheritance(node, def, s)
+		c.contracts(node, def, s)
+	case *ast.StructDeclaration:
+		def := s.definition(identName(node.Name))
+		c.typeBody(def, node.Params, node.Body, nil, s)
+	case *ast.EnumDeclaration:
+		inner := newScope(s)
+		inner.declareParams(identNames(node.TypeParams))
+		for _, ec := range node.Cases {
+			for _, param := range ec.Params {
+				c.annotation(param.Type, inner)
+			}
+		}
+		def := s.definition(identName(node.Name))
+		for _, fn := range node.Methods {
+			c.function(fn, s, def)
+		}
+	case *ast.InterfaceDeclaration:
+		for _, method := range node.Methods {
+			if method.Default != nil {
+				c.function(method.Default, receiverScope(s), nil)
+				continue
+			}
+			for _, param := range method.Params {
+				c.annotation(param.Type, s)
+			}
+			c.annotation(method.ReturnType, s)
+		}
+	case *ast.ImplDeclaration:
+		// The target may be a builtin type such as Number, which is not a
+		// value, so neither name is reported undefined.
+		if node.Interface != nil {
+			s.lookup(node.Interface.Name)
+		}
+		var def *definition
+		if node.Target != nil {
+			def = s.definition(node.Target.Name)
+		}
+		if node.Body != nil {
+			c.methods(def, node.Body.Statements, receiverScope(s))
+		}
+	case *ast.ContractDeclaration:
+		body := newScope(s)
+		if node.Body != nil {
+			c.block(node.Body.Statements, body)
+		}
+		// Invariants see the body's helpers and the instance being checked.
+		inner := receiverScope(body)
+		for _, invariant := range node.Invariants {
+			c.expr(invariant.Condition, inner)
+		}
+	}
+}
+
+func (c *checker) variable(node *ast.VariableDeclaration, s *scope) {
+	c.annotation(node.Type, s)
+	declared := c.typeOf(node.Type, s)
+	value := c.expr(node.Value, s)
+	if node.Pattern != nil {
+		c.pattern(node.Pattern, s)
+		for _, id := range ast.PatternBindings(node.Pattern) {
+			s.declare(id.Name, &entry{})
+		}
+		return
+	}
+	if declared != nil && len(declared.Args) > 0 && !c.compatible(declared, value, s) {
+		c.mismatch(node.Value, "%s is declared %s, but its value is %s", identName(node.Name), declared, value)
+	}
+	t := declared
+	if t == nil && !node.Mutable {
+		t = value
+	}
+	s.declare(identName(node.Name), &entry{typ: t})
+}
+
+// pattern checks the literals in p and looks up the names its struct
+// patterns match on. Those may name enum cases rather than values, so they
+// are not reported undefined.
+func (c *checker) pattern(p ast.Pattern, s *scope) {
+	switch node := p.(type) {
+	case *ast.LiteralPattern:
+		c.expr(node.Value, s)
+	case *ast.StructPattern:
+		if node.Name != nil {
+			s.lookup(node.Name.Name)
+		}
+		for _, field := range node.Fields {
+			c.pattern(field, s)
+		}
+	case *ast.ObjectPattern:
+		for _, pair := range node.Pairs {
+			c.pattern(pair.Value, s)
+		}
+	case *ast.ArrayPattern:
+		for _, el := range node.Elements {
+			c.pattern(el, s)
+		}
+	}
+}
+
+// arms reports the arms of a match that never run: those after an arm
+// with a pattern that is a name, which binds every value, and those whose
+// literals earlier arms match already. An else arm after a name never runs
+// either.
+func (c *checker) arms(match *ast.MatchStatement) {
+	var catchAll *ast.MatchCase
+	seen := make(map[string]*ast.MatchCase)
+	for i := range match.Cases {
+		mc := &match.Cases[i]
+		if catchAll != nil {
+			c.unreachable(mc, "the arm on line %d already matches every value", catchAll.Pos().Line)
+			continue
+		}
+		// matched collects the earlier arms that match mc's patterns, and
+		// fresh records that one of the patterns can match something new.
+		var matched []*ast.MatchCase
+		fresh := false
+		for _, pattern := range mc.Patterns {
+			switch p := pattern.(type) {
+			case *ast.IdentifierPattern:
+				catchAll, fresh = mc, true
+			case *ast.LiteralPattern:
+				key := literalKey(p.Value)
+				first, ok := seen[key]
+				switch {
+				case key == "":
+					fresh = true
+				case !ok:
+					seen[key], fresh = mc, true
+				case first != mc && !slices.Contains(matched, first):
+					matched = append(matched, first)
+				}
+			default:
+				fresh = true
+			}
+		}
+		if fresh {
+			continue
+		}
+		switch {
+		case len(matched) == 1:
+			c.unreachable(mc, "the arm on line %d already matches this value", matched[0].Pos().Line)
+		case len(matched) > 1:
+			c.unreachable(mc, "earlier arms already match these values")
+		}
+	}
+	if match.Else != nil && catchAll != nil {
+		c.unreachable(match.Else, "the arm on line %d already matches every value", catchAll.Pos().Line)
+	}
+}
+
+func (c *checker) unreachable(node ast.Node, format string, args ...any) {
+	c.report(&Error{Kind: UnreachableArm, Node: node, Message: fmt.Sprintf(format, args...)})
+}
+
+// literalKey identifies the value of a literal pattern, so that 1 and 1.0
+// compare equal. It returns "" for a string that interpolates, whose value
+// is not known.
+func literalKey(lit ast.Expression) string {
+	switch node := lit.(type) {
+	case *ast.NumberLiteral:
+		if f, err := strconv.ParseFloat(node.Value, 64); err == nil {
+			return "number " + strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		return "number " + node.Value
+	case *ast.StringLiteral:
+		if strings.Contains(node.Value, "${") {
+			return ""
+		}
+		return "string " + node.Value
+	case *ast.BooleanLiteral:
+		return "boolean " + strconv.FormatBool(node.Value)
+	case *ast.NullLiteral:
+		return "null"
+	}
+	return ""
+}
+
+// returned checks a value returned from the function s is in against the
+// function's declared return type.
+func (c *checker) returned(s *scope, t *Type, value ast.Expression) {
+	fn := s.function()
+	if fn == nil || fn.result == nil || len(fn.result.Args) == 0 || c.compatible(fn.result, t, s) {
+		return
+	}
+	c.mismatch(value, "%s is declared to return %s, but returns %s", fn.name, fn.result, t)
+}
+
+// typeBody checks the constructor parameters, body, and invariants of a
+// struct or class.
+func (c *checker) typeBody(def *definition, params []ast.Parameter, body *ast.BlockStatement, invariants []*ast.ContractInvariant, s *scope) {
+	inner := newScope(s)
+	if def != nil {
+		inner.declareParams(def.params)
+	}
+	for i, param := range params {
+		c.annotation(param.Type, inner)
+		var t *Type
+		if def != nil && i < len(def.fields) {
+			t = def.fields[i].typ
+		}
+		inner.declare(identName(param.Name), &entry{typ: t})
+	}
+	if body != nil {
+		c.methods(def, body.Statements, inner)
+	}
+	if len(invariants) > 0 {
+		// Invariants see the body's bindings and the instance being checked.
+		inner = receiverScope(inner)
+		if def != nil {
+			this := &entry{typ: def.self()}
+			inner.declare("this", this)
+			inner.declare("self", this)
+		}
+		for _, invariant := range invariants {
+			c.expr(invariant.Condition, inner)
+		}
+	}
+}
+
+// inheritance checks a class against its superclasses: an abstract method
+// needs an abstract class, a concrete class must implement every abstract
+// method it inherits, and an override must replace a superclass method with
+// the same parameters and result.
+func (c *checker) inheritance(node *ast.ClassDeclaration, def *definition, s *scope) {
+	if def == nil {
+		return
+	}
+	var stmts []ast.Statement
+	if node.Body != nil {
+		stmts = node.Body.Statements
+	}
+	for _, stmt := range stmts {
+		fn, ok := stmt.(*ast.FunctionDeclaration)
+		if !ok || fn.IsExtension || fn.Accessor != "" || fn.Static {
+			continue
+		}
+		name := identName(fn.Name)
+		if fn.Abstract && !def.abstract {
+			c.report(&Error{Kind: Abstract, Node: fn, Name: def.name, Message: fmt.Sprintf("%s.%s is abstract, so %s must be an abstract class", def.name, name, def.name)})
+		}
+		if !fn.Override {
+			continue
+		}
+		base := c.superMethod(def, name, s)
+		if base == nil {
+			c.report(&Error{Kind: Override, Node: fn, Name: def.name + "." + name, Message: fmt.Sprintf("%s.%s overrides nothing: no superclass of %s declares %s", def.name, name, def.name, name)})
+			continue
+		}
+		if drift := c.drift(def.methods[name], base, "overrides", s); drift != "" {
+			c.report(&Error{Kind: Override, Node: fn, Name: def.name + "." + name, Message: drift})
+		}
+	}
+	if !def.abstract {
+		if method, owner := c.unimplemented(def, s); method != "" {
+			c.report(&Error{Kind: Abstract, Node: node.Name, Name: def.name, Message: fmt.Sprintf("%s must implement the abstract method %s of %s", def.name, method, owner)})
+		}
+	}
+}
+
+// contracts checks a class against the contracts it implements: each must
+// name a contract, and unless the class is abstract, it or a superclass must
+// define every function the contracts of its class chain require, with the
+// same parameters and result.
+func (c *checker) contracts(node *ast.ClassDeclaration, def *definition, s *scope) {
+	if def == nil {
+		return
+	}
+	for _, name := range node.Implements {
+		if e := s.lookup(name.Name); e != nil && (e.def == nil || e.def.kind != "contract") {
+			c.report(&Error{Kind: Contract, Node: name, Name: def.name, Message: name.Name + " is not a contract"})
+		}
+	}
+	if def.abstract {
+		return
+	}
+	d := def
+	for depth := 0; d != nil && depth < maxSuperclasses; depth++ {
+		for _, contractName := range d.contracts {
+			contract := s.definition(contractName)
+			if contract == nil || contract.kind != "contract" {
+				continue
+			}
+			for _, name := range slices.Sorted(maps.Keys(contract.methods)) {
+				sig := c.classMethod(def, name, s)
+				if sig == nil {
+					c.report(&Error{Kind: Contract, Node: node.Name, Name: def.name, Message: fmt.Sprintf("%s does not fulfil contract %s: missing method %s", def.name, contractName, name)})
+					continue
+				}
+				if drift := c.drift(sig, contract.methods[name], "implements", s); drift != "" {
+					c.report(&Error{Kind: Contract, Node: node.Name, Name: def.name, Message: drift})
+				}
+			}
+		}
+		d = s.definition(d.super)
+	}
+}
+
+// superMethod finds the method name of the nearest superclass of def that
+// declares it.
+func (c *checker) superMethod(def *definition, name string, s *scope) *signature {
+	return c.classMethod(s.definition(def.super), name, s)
+}
+
+// classMethod finds the method name of def or, failing that, of the nearest
+// superclass that declares it.
+func (c *checker) classMethod(def *definition, name string, s *scope) *signature {
+	for depth := 0; def != nil && depth < maxSuperclasses; depth++ {
+		if sig, ok := def.methods[name]; ok {
+			return sig
+		}
+		def = s.definition(def.super)
+	}
+	return nil
+}
+
+// drift describes how a method's signature differs from the method it
+// overrides or the contract function it implements, as relation says, or
+// returns "" if they agree.
+func (c *checker) drift(sig, base *signature, relation string, s *scope) string {
+	if sig == nil {
+		return ""
+	}
+	if len(sig.params) != len(base.params) {
+		return fmt.Sprintf("%s takes %d parameters but %s %s, which takes %d", sig.name, len(sig.params), relation, base.name, len(base.params))
+	}
+	for i, param := range sig.params {
+		if !c.compatible(base.params[i].typ, param.typ, s) {
+			return fmt.Sprintf("parameter %s of %s is %s but %s declares %s", param.name, sig.name, param.typ, base.name, base.params[i].typ)
+		}
+	}
+	if !c.compatible(base.result, sig.result, s) {
+		return fmt.Sprintf("%s returns %s but %s %s, which returns %s", sig.name, sig.result, relation, base.name, base.result)
+	}
+	return ""
+}
+
+// unimplemented returns an abstract method that def inherits without any
+// class from def up implementing it, and the class that declares it.
+func (c *checker) unimplemented(def *definition, s *scope) (string, string) {
+	implemented := make(map[string]bool)
+	d := def
+	for depth := 0; d != nil && depth < maxSuperclasses; depth++ {
+		for _, name := range slices.Sorted(maps.Keys(d.methods)) {
+			switch {
+			case !d.abstractMethods[name]:
+				implemented[name] = true
+			case d != def && !implemented[name]:
+				return name, d.name
+			}
+		}
+		d = s.definition(d.super)
+	}
+	return "", ""
+}
+
+// methods checks the statements of a type body, treating its functions as
+// methods of def.
+func (c *checker) methods(def *definition, stmts []ast.Statement, s *scope) {
+	c.hoist(stmts, s)
+	for _, stmt := range stmts {
+		if fn, ok := stmt.(*ast.FunctionDeclaration); ok && !fn.IsExtension && def != nil {
+			if fn.Static {
+				c.function(fn, s, nil)
+			} else {
+				c.function(fn, s, def)
+			}
+			continue
+		}
+		c.stmt(stmt, s)
+	}
+}
+
+func (c *checker) function(fn *ast.FunctionDeclaration, s *scope, owner *definition) {
+	sig, inner := c.signature(fn, s, owner)
+	c.annotation(fn.Receiver, inner)
+	for _, param := range fn.Params {
+		c.annotation(param.Type, inner)
+	}
+	c.annotation(fn.ReturnType, inner)
+	inner.fn = sig
+	if owner != nil || fn.IsExtension {
+		this := &entry{typ: sig.receiver}
+		inner.declare("this", this)
+		inner.declare("self", this)
+	}
+	for i, param := range fn.Params {
+		inner.declare(identName(param.Name), &entry{typ: sig.params[i].typ})
+	}
+	if fn.Contract != nil {
+		contract := newScope(inner)
+		contract.declare("result", &entry{typ: sig.result})
+		for _, clause := range fn.Contract.Clauses {
+			if clause.Kind == "requires" {
+				c.expr(clause.Condition, inner)
+				continue
+			}
+			c.expr(clause.Guard, contract)
+			c.expr(clause.Condition, contract)
+		}
+	}
+	if fn.Body != nil {
+		c.block(fn.Body.Statements, inner)
+	}
+	if fn.BodyExpr != nil {
+		c.returned(inner, c.expr(fn.BodyExpr, inner), fn.BodyExpr)
+	}
+}
+
+// receiverScope binds this and self, of unknown type, for the methods of an
+// impl block or interface. Methods of a type the checker knows rebind them
+// to the type.
+func receiverScope(s *scope) *scope {
+	inner := newScope(s)
+	this := &entry{}
+	inner.declare("this", this)
+	inner.declare("self", this)
+	return inner
+}
+
+// declaredNames returns the names stmt binds in the block it is in.
+func declaredNames(stmt ast.Statement) []string {
+	switch node := stmt.(type) {
+	case *ast.VariableDeclaration:
+		if node.Pattern == nil {
+			return []string{identName(node.Name)}
+		}
+		var names []string
+		for _, id := range ast.PatternBindings(node.Pattern) {
+			names = append(names, id.Name)
+		}
+		return names
+	case *ast.FunctionDeclaration:
+		if !node.IsExtension {
+			return []string{identName(node.Name)}
+		}
+	case *ast.ClassDeclaration:
+		return []string{identName(node.Name)}
+	case *ast.StructDeclaration:
+		return []string{identName(node.Name)}
+	case *ast.EnumDeclaration:
+		return []string{identName(node.Name)}
+	case *ast.InterfaceDeclaration:
+		return []string{identName(node.Name)}
+	case *ast.ContractDeclaration:
+		return []string{identName(node.Name)}
+	case *ast.ImportDeclaration:
+		return []string{importName(node)}
+	}
+	return nil
+}
+
+// importName returns the name an import binds: its alias, or the last
+// segment of its path.
+func importName(imp *ast.ImportDeclaration) string {
+	if imp.Alias != nil {
+		return imp.Alias.Name
+	}
+	if imp.PathLiteral != "" {
+		segments := strings.FieldsFunc(imp.PathLiteral, func(r rune) bool { return r == '/' })
+		if len(segments) == 0 {
+			return ""
+		}
+		return segments[len(segments)-1]
+	}
+	if len(imp.Path) == 0 {
+		return ""
+	}
+	return identName(imp.Path[len(imp.Path)-1])
+}
+
+func identName(id *ast.Identifier) string {
+	if id == nil {
+		return ""
+	}
+	return id.Name
+}
+
+func identNames(ids []*ast.Identifier) []string {
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		names = append(names, identName(id))
+	}
+	return names
+}
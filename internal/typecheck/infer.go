@@ -0,0 +1,276 @@
+package typecheck
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/lexer"
+	"github.com/cybellereaper/selenelang/internal/token"
+)
+
+// expr checks expr and returns its type, recording it in Info.Types.
+func (c *checker) expr(expr ast.Expression, s *scope) *Type {
+	if expr == nil {
+		return nil
+	}
+	t := c.infer(expr, s)
+	if t != nil {
+		c.info.Types[expr] = t
+	}
+	return t
+}
+
+func (c *checker) infer(expr ast.Expression, s *scope) *Type {
+	switch node := expr.(type) {
+	case *ast.NumberLiteral:
+		return named("Number")
+	case *ast.StringLiteral:
+		if strings.Contains(node.Value, "${") {
+			c.interpolated(node.Value, s)
+		}
+		return named("String")
+	case *ast.BooleanLiteral:
+		return named("Boolean")
+	case *ast.NullLiteral:
+		return named("Null")
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			c.expr(el, s)
+		}
+		return named("Array")
+	case *ast.SetLiteral:
+		for _, el := range node.Elements {
+			c.expr(el, s)
+		}
+		return named("Set")
+	case *ast.ObjectLiteral:
+		for _, pair := range node.Pairs {
+			c.expr(pair.Value, s)
+		}
+		return named("Object")
+	case *ast.Identifier:
+		if e := s.lookup(node.Name); e != nil {
+			return e.typ
+		}
+		c.undefined(node, s)
+	case *ast.PrefixExpression:
+		c.expr(node.Right, s)
+		switch node.Operator {
+		case "!":
+			return named("Boolean")
+		case "-":
+			return named("Number")
+		}
+	case *ast.InfixExpression:
+		left, right := c.expr(node.Left, s), c.expr(node.Right, s)
+		switch node.Operator {
+		case "==", "!=", "<", "<=", ">", ">=", "is", "!is", "in":
+			return named("Boolean")
+		case "..", "..=":
+			return named("Range")
+		case "&&", "||":
+			// The result is one of the operands, not necessarily a Boolean.
+			if hasName(left, "Boolean") && hasName(right, "Boolean") {
+				return named("Boolean")
+			}
+		case "-", "*", "/", "%":
+			return named("Number")
+		case "+":
+			switch {
+			case hasName(left, "String") || hasName(right, "String"):
+				return named("String")
+			case hasName(left, "Number") && hasName(right, "Number"):
+				return named("Number")
+			}
+		}
+	case *ast.AssignmentExpression:
+		c.expr(node.Target, s)
+		return c.expr(node.Value, s)
+	case *ast.IncrementExpression:
+		c.expr(node.Target, s)
+		return named("Number")
+	case *ast.ElvisExpression:
+		c.expr(node.Left, s)
+		c.expr(node.Right, s)
+	case *ast.CallExpression:
+		return c.call(node, s)
+	case *ast.IndexExpression:
+		c.expr(node.Collection, s)
+		c.expr(node.Index, s)
+	case *ast.MemberExpression:
+		return c.member(c.expr(node.Object, s), node.Property, s)
+	case *ast.NonNullAssertion:
+		if t := c.expr(node.Expression, s); t != nil {
+			nonNull := *t
+			nonNull.Nullable = false
+			return &nonNull
+		}
+	case *ast.PropagateExpression:
+		// x? evaluates to the value an Ok or Some wraps.
+		t := c.expr(node.Expression, s)
+		if t != nil && !t.Param && len(t.Args) == 1 {
+			if e := s.lookup(t.Name); e != nil && e == preludeEntry(s, t.Name) {
+				return t.Args[0]
+			}
+		}
+	case *ast.OldExpression:
+		// old(x) has the type x had, which is the type x has.
+		return c.expr(node.Value, s)
+	case *ast.AwaitExpression:
+		c.expr(node.Expression, s)
+	case *ast.YieldExpression:
+		c.expr(node.Value, s)
+	}
+	return nil
+}
+
+// interpolated looks up the names a string's ${...} placeholders may use,
+// so that an import used only there counts as used. The runtime parses the
+// placeholders when the string is evaluated; the checker does not, so it
+// looks up every identifier in the text and reports none of them undefined.
+func (c *checker) interpolated(text string, s *scope) {
+	lex := lexer.New(text)
+	for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
+		if tok.Type == token.IDENT {
+			s.lookup(tok.Literal)
+		}
+	}
+}
+
+// preludeEntry returns the entry the prelude binds name to, so a program's
+// own Result can be told from the prelude's.
+func preludeEntry(s *scope, name string) *entry {
+	for ; s.outer != nil; s = s.outer {
+	}
+	return s.names[name]
+}
+
+func hasName(t *Type, name string) bool {
+	return t != nil && !t.Param && !t.Nullable && canonical(t.Name) == name
+}
+
+// member returns the type of a field of a struct or class value.
+func (c *checker) member(object *Type, property string, s *scope) *Type {
+	if object == nil || object.Param {
+		return nil
+	}
+	def := s.definition(object.Name)
+	if def == nil {
+		return nil
+	}
+	for _, f := range def.fields {
+		if f.name == property {
+			return substitute(f.typ, bindArgs(def.params, object.Args), paramSet(def.params))
+		}
+	}
+	return nil
+}
+
+func (c *checker) call(call *ast.CallExpression, s *scope) *Type {
+	var sig *signature
+	var receiver *Type
+	switch callee := call.Callee.(type) {
+	case *ast.Identifier:
+		c.expr(callee, s)
+		if e := s.lookup(callee.Name); e != nil {
+			switch {
+			case e.fn != nil:
+				sig = e.fn
+			case e.def != nil:
+				sig = e.def.constructor()
+				if e.def.abstract {
+					c.report(&Error{Kind: Abstract, Node: call, Name: e.def.name, Message: "cannot instantiate abstract class " + e.def.name})
+				}
+			}
+		}
+	case *ast.MemberExpression:
+		if id, ok := callee.Object.(*ast.Identifier); ok {
+			if def := s.definition(id.Name); def != nil {
+				sig = def.enumCase(callee.Property)
+			}
+		}
+		if sig == nil {
+			receiver = c.expr(callee.Object, s)
+			sig = c.method(receiver, callee.Property, s)
+		}
+	default:
+		c.expr(call.Callee, s)
+	}
+	args := make([]*Type, len(call.Arguments))
+	for i, arg := range call.Arguments {
+		args[i] = c.expr(arg, s)
+	}
+	if sig == nil {
+		return nil
+	}
+	return c.instantiate(call, sig, receiver, args, s)
+}
+
+// method finds the method a call on a value of type receiver invokes,
+// looking through the superclasses of a class. An inherited method is not
+// bound to the receiver's type arguments, which belong to the subclass.
+func (c *checker) method(receiver *Type, name string, s *scope) *signature {
+	if receiver == nil || receiver.Param {
+		return nil
+	}
+	def := s.definition(receiver.Name)
+	for depth := 0; def != nil && depth < maxSuperclasses; depth++ {
+		// A field of the same name holds the value the call invokes.
+		for _, f := range def.fields {
+			if f.name == name {
+				return nil
+			}
+		}
+		if sig, ok := def.methods[name]; ok {
+			if depth > 0 {
+				inherited := *sig
+				inherited.receiver = nil
+				return &inherited
+			}
+			return sig
+		}
+		def = s.definition(def.super)
+	}
+	return nil
+}
+
+// maxSuperclasses bounds how far method lookups climb a class hierarchy, so
+// a cycle of superclasses cannot hang the checker.
+const maxSuperclasses = 32
+
+// instantiate infers the type arguments of a call from its receiver and
+// arguments, reports a wrong number of arguments and arguments that
+// contradict the type arguments, and returns the type of the call's result.
+func (c *checker) instantiate(call *ast.CallExpression, sig *signature, receiver *Type, args []*Type, s *scope) *Type {
+	if want, got := len(sig.params), len(args); want != got {
+		c.report(&Error{
+			Kind:    ArgumentCount,
+			Node:    call,
+			Message: fmt.Sprintf("wrong number of arguments to %s: want %d, got %d", sig.name, want, got),
+			Name:    sig.name,
+			Want:    want,
+			Got:     got,
+		})
+	}
+	b := &binder{c: c, s: s, sig: sig, params: paramSet(sig.typeParams), bound: make(map[string]*Type), origin: make(map[string]string)}
+	if receiver != nil && sig.receiver != nil {
+		b.match(sig.receiver, receiver, "the receiver")
+	}
+	for i, param := range sig.params {
+		if i >= len(args) {
+			break
+		}
+		if msg := b.unify(param.typ, args[i], fmt.Sprintf("argument %d", i+1)); msg != "" {
+			c.mismatch(call.Arguments[i], "%s", msg)
+		}
+	}
+	if len(sig.typeParams) > 0 {
+		inst := &Instance{Params: sig.typeParams, Args: make([]*Type, len(sig.typeParams))}
+		for i, param := range sig.typeParams {
+			inst.Args[i] = b.bound[param]
+		}
+		c.info.Instances[call] = inst
+	}
+	return substitute(sig.result, b.bound, b.params)
+}
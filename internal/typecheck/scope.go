@@ -0,0 +1,157 @@
+package typecheck
+
+// definition is a struct, class, enum, or interface declaration, or one of
+// the prelude enums.
+type definition struct {
+	name   string
+	kind   string
+	params []string
+	// fields are the constructor parameters of a struct or class, and cases
+	// the payload of each enum case, typed in terms of params.
+	fields  []field
+	cases   map[string][]field
+	methods map[string]*signature
+	super   string
+	// abstract marks an abstract class, and abstractMethods the methods of
+	// a class that are declared abstract.
+	abstract        bool
+	abstractMethods map[string]bool
+	// contracts names the contracts a class declares it implements. The
+	// methods of a contract are the functions it requires.
+	contracts []string
+}
+
+type field struct {
+	name string
+	typ  *Type
+}
+
+// self is the type of this inside the definition: the definition applied to
+// its own type parameters.
+func (d *definition) self() *Type {
+	t := named(d.name)
+	for _, param := range d.params {
+		t.Args = append(t.Args, &Type{Name: param, Param: true})
+	}
+	return t
+}
+
+func (d *definition) constructor() *signature {
+	if d.kind != "struct" && d.kind != "class" {
+		return nil
+	}
+	return &signature{name: d.name, typeParams: d.params, params: d.fields, result: d.self()}
+}
+
+func (d *definition) enumCase(name string) *signature {
+	params, ok := d.cases[name]
+	if !ok {
+		return nil
+	}
+	return &signature{name: d.name + "." + name, typeParams: d.params, params: params, result: d.self()}
+}
+
+func (d *definition) addMethod(name string, sig *signature) {
+	if d.methods == nil {
+		d.methods = make(map[string]*signature)
+	}
+	d.methods[name] = sig
+}
+
+// preludeDefinitions describes the Result and Option enums every runtime
+// binds, with the type parameter of the value they wrap.
+func preludeDefinitions() []*definition {
+	value := []field{{name: "value", typ: &Type{Name: "T", Param: true}}}
+	return []*definition{
+		{name: "Result", kind: "enum", params: []string{"T"}, cases: map[string][]field{"Ok": value, "Err": {{name: "error"}}}},
+		{name: "Option", kind: "enum", params: []string{"T"}, cases: map[string][]field{"Some": value, "None": nil}},
+	}
+}
+
+// signature is what a call checks its arguments against: a function,
+// method, constructor, or enum case.
+type signature struct {
+	name       string
+	typeParams []string
+	params     []field
+	result     *Type
+	// receiver is the type a method is declared on, in terms of typeParams.
+	// A method call binds them from the type of its receiver first.
+	receiver *Type
+}
+
+// scope binds names to what the checker knows of them. A name bound to an
+// entry with nothing set is a value of unknown type; it still shadows the
+// names of outer scopes.
+type scope struct {
+	outer *scope
+	names map[string]*entry
+	// hoisted holds the names the statements of the scope's block declare,
+	// so that a function may refer to a variable declared after it.
+	hoisted map[string]bool
+	// fn is the function whose body the scope is in, for return statements.
+	fn *signature
+}
+
+type entry struct {
+	typ   *Type
+	fn    *signature
+	def   *definition
+	param bool
+	// used is set once a lookup finds the entry, for reporting unused
+	// imports.
+	used bool
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{outer: outer, names: make(map[string]*entry), hoisted: make(map[string]bool)}
+}
+
+func (s *scope) lookup(name string) *entry {
+	for ; s != nil; s = s.outer {
+		if e, ok := s.names[name]; ok {
+			e.used = true
+			return e
+		}
+	}
+	return nil
+}
+
+// bound reports whether name is declared in s or an enclosing scope, by a
+// statement that has been checked or one later in the same block.
+func (s *scope) bound(name string) bool {
+	for ; s != nil; s = s.outer {
+		if _, ok := s.names[name]; ok || s.hoisted[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *scope) function() *signature {
+	for ; s != nil; s = s.outer {
+		if s.fn != nil {
+			return s.fn
+		}
+	}
+	return nil
+}
+
+func (s *scope) definition(name string) *definition {
+	if e := s.lookup(name); e != nil {
+		return e.def
+	}
+	return nil
+}
+
+func (s *scope) declare(name string, e *entry) {
+	if name != "" && name != "_" {
+		s.names[name] = e
+	}
+}
+
+func (s *scope) declareParams(params []string) {
+	for _, param := range params {
+		s.declare(param, &entry{param: true})
+	}
+}
@@ -0,0 +1,239 @@
+// Package typecheck verifies the declarations and calls of a Selene program
+// without running it. Functions, classes, and enums may declare type
+// parameters (`fn first<T>(items: Array, fallback: T): T`, `enum Box<T>`).
+// Check confirms that every annotation gives a generic type as many type
+// arguments as it declares, infers the type arguments of each call to a
+// generic function, constructor, method, or enum case from the call's
+// arguments, and reports arguments, initializers, and return values that
+// contradict them. The language server shows the diagnostics and, on hover,
+// the types it inferred.
+//
+// Check also reports calls to a declared function, constructor, method, or
+// enum case with the wrong number of arguments, match arms that an earlier
+// arm leaves unreachable, imports the program never uses, abstract classes
+// that are instantiated or left unimplemented, and override methods that
+// replace nothing or whose signature drifted from the method they replace.
+// Given the names the runtime binds, Config.Check reports identifiers that
+// nothing declares as well.
+//
+// Selene is dynamically typed and the runtime ignores annotations, so the
+// checker reports only what it can show from the source: an expression whose
+// type it cannot tell, a name it does not know, and Any are compatible with
+// every type. Parameters and declarations with a plain annotation such as
+// Number are left to the runtime; only annotations that use type parameters
+// or type arguments are checked.
+package typecheck
+
+import (
+	"strings"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+)
+
+// Type is the static type of an expression: a named type with its type
+// arguments, such as Box<Number>, or a type parameter such as T. A nil *Type
+// is a type the checker cannot tell.
+type Type struct {
+	// Name is the type's name as written; Int and Number name the same type.
+	Name string
+	// Args are the type arguments. A nil element is one that is not known,
+	// as for Option.None().
+	Args []*Type
+	// Param marks a type parameter, seen inside the declaration of it.
+	Param    bool
+	Nullable bool
+}
+
+// String formats t the way it would be annotated, with _ for type arguments
+// that are not known. Arguments are left out when none of them is.
+func (t *Type) String() string {
+	if t == nil {
+		return "_"
+	}
+	var b strings.Builder
+	b.WriteString(t.Name)
+	if known(t.Args) {
+		parts := make([]string, len(t.Args))
+		for i, arg := range t.Args {
+			parts[i] = arg.String()
+		}
+		b.WriteString("<" + strings.Join(parts, ", ") + ">")
+	}
+	if t.Nullable {
+		b.WriteString("?")
+	}
+	return b.String()
+}
+
+func known(types []*Type) bool {
+	for _, t := range types {
+		if t != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorKind classifies what is wrong with a declaration, call, or name.
+type ErrorKind int
+
+const (
+	// TypeArgumentCount is an annotation that gives a type more or fewer
+	// type arguments than it declares, such as Box<Number, String> for
+	// enum Box<T>.
+	TypeArgumentCount ErrorKind = iota
+	// Mismatch is an argument, initializer, or return value whose type
+	// contradicts a type parameter or type argument, such as pair(1, "one")
+	// for fn pair<T>(a: T, b: T).
+	Mismatch
+	// Undefined is an identifier that neither the program nor the runtime
+	// binds. Only Config.Check with Globals set reports it.
+	Undefined
+	// ArgumentCount is a call that passes a declared function, constructor,
+	// method, or enum case more or fewer arguments than its parameters.
+	ArgumentCount
+	// UnreachableArm is a match arm that never runs, because an earlier arm
+	// binds every value or matches the same literal.
+	UnreachableArm
+	// UnusedImport is an import whose name the program never refers to.
+	UnusedImport
+	// Abstract is a call that instantiates an abstract class, a concrete
+	// class that leaves an inherited abstract method unimplemented, or an
+	// abstract method in a class not declared abstract.
+	Abstract
+	// Override is a method marked override that no superclass declares, or
+	// whose parameters or result contradict the method it overrides.
+	Override
+	// Contract is a class that implements something other than a contract,
+	// or that lacks a function a contract requires or defines it with other
+	// parameters or result.
+	Contract
+)
+
+// Error describes a problem the checker found. Node is the annotation,
+// expression, match arm, or import at fault. For TypeArgumentCount, Type
+// names the type and Want and Got count its type parameters and the type
+// arguments given. For ArgumentCount, Name names the callee and Want and Got
+// count its parameters and the arguments given. For Undefined and
+// UnusedImport, Name is the identifier; for Abstract and Contract, the
+// class; and for Override, the method, as Class.method.
+type Error struct {
+	Kind    ErrorKind
+	Node    ast.Node
+	Message string
+	Type    string
+	Name    string
+	Want    int
+	Got     int
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Instance records the type arguments a call instantiates a generic
+// declaration with. Args[i] is the argument for Params[i], or nil when it
+// could not be inferred.
+type Instance struct {
+	Params []string
+	Args   []*Type
+}
+
+// Info is what Check learns about a program.
+type Info struct {
+	// Errors lists the problems found, in the order found.
+	Errors []*Error
+	// Types holds the type of each expression whose type the checker could
+	// tell.
+	Types map[ast.Expression]*Type
+	// Instances holds the type arguments of each call to a generic
+	// function, constructor, method, or enum case.
+	Instances map[*ast.CallExpression]*Instance
+}
+
+// Config selects the checks that depend on where a program runs.
+type Config struct {
+	// Globals are the names bound before the program runs, such as print
+	// and the standard modules. When it is nil, identifiers are not checked,
+	// since a program may share its namespace with files the checker does
+	// not see.
+	Globals []string
+}
+
+// Check verifies the declarations and calls in program with the zero
+// Config, which does not check identifiers.
+func Check(program *ast.Program) *Info {
+	return Config{}.Check(program)
+}
+
+// Check verifies the declarations and calls in program.
+func (cfg Config) Check(program *ast.Program) *Info {
+	c := &checker{info: &Info{
+		Types:     make(map[ast.Expression]*Type),
+		Instances: make(map[*ast.CallExpression]*Instance),
+	}}
+	if program == nil {
+		return c.info
+	}
+	if cfg.Globals != nil {
+		c.globals = make(map[string]bool, len(cfg.Globals))
+		for _, name := range cfg.Globals {
+			c.globals[name] = true
+		}
+	}
+	prelude := newScope(nil)
+	for _, def := range preludeDefinitions() {
+		prelude.names[def.name] = &entry{def: def}
+	}
+	global := newScope(prelude)
+	var stmts []ast.Statement
+	for _, item := range program.Items {
+		switch node := item.(type) {
+		case ast.Statement:
+			stmts = append(stmts, node)
+		case *ast.ModuleDeclaration:
+			global.hoisted[identName(node.Name)] = true
+		case *ast.PackageDeclaration:
+			global.hoisted["__package__"] = true
+		}
+	}
+	c.hoist(stmts, global)
+	for _, item := range program.Items {
+		switch node := item.(type) {
+		case ast.Statement:
+			c.stmt(node, global)
+		case *ast.ModuleDeclaration:
+			// The names a module imports are among its exports, so they
+			// are not reported unused.
+			if node.Body != nil {
+				c.inModule = true
+				c.block(node.Body.Statements, newScope(global))
+				c.inModule = false
+			}
+		}
+	}
+	c.unusedImports()
+	return c.info
+}
+
+// builtinTypes are the runtime's own types, by canonical name.
+var builtinTypes = map[string]bool{
+	"Number": true, "String": true, "Boolean": true, "Null": true,
+	"Array": true, "Object": true, "Set": true, "Bytes": true, "Iterator": true,
+	"Range": true,
+}
+
+// canonical returns the name the runtime gives the type name names, so that
+// Int and Number compare equal.
+func canonical(name string) string {
+	switch name {
+	case "Int", "Integer", "Float":
+		return "Number"
+	case "Bool":
+		return "Boolean"
+	default:
+		return name
+	}
+}
+
+func named(name string) *Type { return &Type{Name: name} }
+
+func isNull(t *Type) bool { return t != nil && canonical(t.Name) == "Null" }
@@ -0,0 +1,302 @@
+package typecheck_test
+
+import (
+	"testing"
+
+	"github.com/cybellereaper/selenelang/internal/ast"
+	"github.com/cybellereaper/selenelang/internal/lexer"
+	"github.com/cybellereaper/selenelang/internal/parser"
+	"github.com/cybellereaper/selenelang/internal/typecheck"
+)
+
+func parse(t *testing.T, src string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parse errors: %v\n%s", errs, src)
+	}
+	return program
+}
+
+const generics = `
+enum Box<T> { Full(value: T); Empty; }
+
+fn pair<T>(a: T, b: T): Array => [a, b];
+fn unwrap<T>(box: Box<T>, fallback: T): T => fallback;
+
+class Stack<T>(items: Array) {
+    fn push(item: T): Stack<T> {
+        return this;
+    }
+}
+`
+
+func TestCheckReportsMisinstantiations(t *testing.T) {
+	program := parse(t, generics+`
+let mixed = pair(1, "one");
+let wrong: Box<Number> = Box.Full("x");
+let extra: Box<Number, String> = Box.Empty();
+let other = unwrap(Option.Some(1), 2);
+let stack: Stack<Number> = Stack([]);
+stack.push("x");
+fn label(): Box<String> { return Box.Full(1); }
+fn unknown<T>(value: T): T { return 5; }
+`)
+	want := []struct {
+		kind    typecheck.ErrorKind
+		line    int
+		message string
+	}{
+		{typecheck.Mismatch, 13, "T is Number from argument 1 of pair, but String from argument 2"},
+		{typecheck.Mismatch, 14, "wrong is declared Box<Number>, but its value is Box<String>"},
+		{typecheck.TypeArgumentCount, 15, "wrong number of type arguments for Box: want 1, got 2"},
+		{typecheck.Mismatch, 16, "argument 1 of unwrap must be Box<T>, got Option<Number>"},
+		{typecheck.Mismatch, 18, "T is Number from the receiver of Stack.push, but String from argument 1"},
+		{typecheck.Mismatch, 19, "label is declared to return Box<String>, but returns Box<Number>"},
+	}
+	errs := typecheck.Check(program).Errors
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), errs)
+	}
+	for i, w := range want {
+		if errs[i].Kind != w.kind || errs[i].Node.Pos().Line != w.line || errs[i].Message != w.message {
+			t.Fatalf("error %d: expected %q on line %d, got %q on line %d", i, w.message, w.line, errs[i].Message, errs[i].Node.Pos().Line)
+		}
+	}
+	if count := errs[2]; count.Type != "Box" || count.Want != 1 || count.Got != 2 {
+		t.Fatalf("unexpected type argument count details %+v", count)
+	}
+}
+
+func TestCheckInfersTypeArguments(t *testing.T) {
+	program := parse(t, generics+`
+let full = Box.Full(3);
+let first = unwrap(full, 4);
+let none = Option.None();
+fn twice(): Result<String> {
+    let word = Result.Ok("hi")?;
+    return Result.Ok(word + word);
+}
+let fine = pair(null, 1);
+`)
+	info := typecheck.Check(program)
+	if len(info.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v", info.Errors)
+	}
+	types := make(map[string]string)
+	for _, item := range program.Items {
+		if decl, ok := item.(*ast.VariableDeclaration); ok {
+			types[decl.Name.Name] = info.Types[decl.Value].String()
+		}
+	}
+	for name, want := range map[string]string{"full": "Box<Number>", "first": "Number", "none": "Option", "fine": "Array"} {
+		if types[name] != want {
+			t.Fatalf("expected %s to be %s, got %s", name, want, types[name])
+		}
+	}
+	first := program.Items[len(program.Items)-4].(*ast.VariableDeclaration).Value.(*ast.CallExpression)
+	inst := info.Instances[first]
+	if inst == nil || len(inst.Params) != 1 || inst.Params[0] != "T" || inst.Args[0].String() != "Number" {
+		t.Fatalf("unexpected instance %+v", inst)
+	}
+}
+
+func TestCheckReportsUndefinedNamesArityAndDeadCode(t *testing.T) {
+	program := parse(t, generics+`
+import path;
+import fs as files;
+import time;
+
+fn total(a: Number, b: Number): Number => a + b + later;
+let later = 1;
+total(1);
+Box.Full(1, 2);
+Stack([]).push();
+print(missing, "${time.now()}");
+enum Shape { Circle(radius: Number); Square(side: Number); }
+match Shape.Circle(1) {
+    Circle(r) => print(r);
+    "a" => print("a");
+    "a" => print("again");
+    other => print(other);
+    Square(s) => print(s);
+}
+module tools {
+    import path;
+}
+`)
+	want := []struct {
+		kind    typecheck.ErrorKind
+		line    int
+		message string
+	}{
+		{typecheck.ArgumentCount, 19, "wrong number of arguments to total: want 2, got 1"},
+		{typecheck.ArgumentCount, 20, "wrong number of arguments to Box.Full: want 1, got 2"},
+		{typecheck.ArgumentCount, 21, "wrong number of arguments to Stack.push: want 1, got 0"},
+		{typecheck.Undefined, 22, "undefined identifier missing"},
+		{typecheck.UnreachableArm, 27, "the arm on line 26 already matches this value"},
+		{typecheck.UnreachableArm, 29, "the arm on line 28 already matches every value"},
+		{typecheck.UnusedImport, 13, "path is imported but never used"},
+		{typecheck.UnusedImport, 14, "files is imported but never used"},
+	}
+	errs := typecheck.Config{Globals: []string{"print"}}.Check(program).Errors
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), errs)
+	}
+	for i, w := range want {
+		if errs[i].Kind != w.kind || errs[i].Node.Pos().Line != w.line || errs[i].Message != w.message {
+			t.Fatalf("error %d: expected %q on line %d, got %q on line %d", i, w.message, w.line, errs[i].Message, errs[i].Node.Pos().Line)
+		}
+	}
+	if count := errs[0]; count.Name != "total" || count.Want != 2 || count.Got != 1 {
+		t.Fatalf("unexpected argument count details %+v", count)
+	}
+	if unused := errs[7]; unused.Name != "files" {
+		t.Fatalf("unexpected unused import details %+v", unused)
+	}
+	for _, err := range typecheck.Check(program).Errors {
+		if err.Kind == typecheck.Undefined {
+			t.Fatalf("expected identifiers to be left unchecked without globals, got %v", err)
+		}
+	}
+}
+
+func TestCheckReportsUnreachableArmsWithSeveralPatterns(t *testing.T) {
+	program := parse(t, `match 3 {
+    1, 2 => print("low");
+    "a" => print("a");
+    2, 1 => print("again");
+    "a", 1 => print("both");
+    1, 4 => print("four");
+    n => print(n);
+    else => print("never");
+}
+`)
+	want := []struct {
+		line    int
+		message string
+	}{
+		{4, "the arm on line 2 already matches this value"},
+		{5, "earlier arms already match these values"},
+		{8, "the arm on line 7 already matches every value"},
+	}
+	errs := typecheck.Config{Globals: []string{"print"}}.Check(program).Errors
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), errs)
+	}
+	for i, w := range want {
+		if errs[i].Kind != typecheck.UnreachableArm || errs[i].Node.Pos().Line != w.line || errs[i].Message != w.message {
+			t.Fatalf("error %d: expected %q on line %d, got %q on line %d", i, w.message, w.line, errs[i].Message, errs[i].Node.Pos().Line)
+		}
+	}
+}
+
+func TestCheckReportsAbstractClassesAndOverrides(t *testing.T) {
+	program := parse(t, `abstract class Shape(name: String) {
+    abstract fn area(): Number;
+    fn describe(): String => this.name;
+}
+class Square(name: String, side: Number) : Shape {
+    override fn area(): Number => this.side * this.side;
+    override fn describe(): String => "square";
+}
+class Blank(name: String) : Shape {}
+class Circle(name: String, r: Number) : Shape {
+    override fn area(): String => "round";
+    override fn radius(): Number => this.r;
+    override fn describe(loud: Boolean): String => "circle";
+}
+class Loose(x: Number) { abstract fn f(): Number; }
+let square = Square("a", 2);
+let shape = Shape("b");
+`)
+	want := []struct {
+		kind    typecheck.ErrorKind
+		line    int
+		message string
+	}{
+		{typecheck.Abstract, 9, "Blank must implement the abstract method area of Shape"},
+		{typecheck.Override, 11, "Circle.area returns String but overrides Shape.area, which returns Number"},
+		{typecheck.Override, 12, "Circle.radius overrides nothing: no superclass of Circle declares radius"},
+		{typecheck.Override, 13, "Circle.describe takes 1 parameters but overrides Shape.describe, which takes 0"},
+		{typecheck.Abstract, 15, "Loose.f is abstract, so Loose must be an abstract class"},
+		{typecheck.Abstract, 17, "cannot instantiate abstract class Shape"},
+	}
+	errs := typecheck.Check(program).Errors
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), errs)
+	}
+	for i, w := range want {
+		if errs[i].Kind != w.kind || errs[i].Node.Pos().Line != w.line || errs[i].Message != w.message {
+			t.Fatalf("error %d: expected %q on line %d, got %q on line %d", i, w.message, w.line, errs[i].Message, errs[i].Node.Pos().Line)
+		}
+	}
+}
+
+func TestCheckReportsUnfulfilledContracts(t *testing.T) {
+	program := parse(t, `contract Transferable {
+    fn transfer(amount: Number): Boolean;
+    fn balance(): Number;
+    invariant self.balance() >= 0;
+}
+class Account(total: Number) : implements Transferable {
+    fn transfer(amount: Number): Boolean => amount <= this.total;
+    fn balance(): Number => this.total;
+}
+class Savings(total: Number) : Account implements Transferable {}
+abstract class Partial(total: Number) : implements Transferable {}
+class Empty(total: Number) : implements Transferable {}
+class Wide(total: Number) : implements Transferable {
+    fn transfer(amount: Number, note: String): Boolean => true;
+    fn balance(): String => "none";
+}
+class Wrong(total: Number) : implements Account {}
+`)
+	want := []struct {
+		line    int
+		message string
+	}{
+		{12, "Empty does not fulfil contract Transferable: missing method balance"},
+		{12, "Empty does not fulfil contract Transferable: missing method transfer"},
+		{13, "Wide.balance returns String but implements Transferable.balance, which returns Number"},
+		{13, "Wide.transfer takes 2 parameters but implements Transferable.transfer, which takes 1"},
+		{17, "Account is not a contract"},
+	}
+	errs := typecheck.Check(program).Errors
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), errs)
+	}
+	for i, w := range want {
+		if errs[i].Kind != typecheck.Contract || errs[i].Node.Pos().Line != w.line || errs[i].Message != w.message {
+			t.Fatalf("error %d: expected %q on line %d, got %q on line %d", i, w.message, w.line, errs[i].Message, errs[i].Node.Pos().Line)
+		}
+	}
+}
+
+func TestCheckResolvesNamesInPreconditionsAndInvariants(t *testing.T) {
+	program := parse(t, `fn withdraw(balance: Number, amount: Number): Number
+    contract {
+        requires(amount <= balance);
+        ensures(result == old(balance) - amount);
+        requires(result > 0);
+    }
+{
+    return balance - amount;
+}
+class Counter(count: Number) {
+    let floor = 0;
+    invariant self.count >= floor && self.count < ceiling;
+}
+`)
+	want := []string{"undefined identifier result", "undefined identifier ceiling"}
+	errs := typecheck.Config{Globals: []string{"print"}}.Check(program).Errors
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), errs)
+	}
+	for i, message := range want {
+		if errs[i].Kind != typecheck.Undefined || errs[i].Message != message {
+			t.Fatalf("error %d: expected %q, got %q", i, message, errs[i].Message)
+		}
+	}
+}
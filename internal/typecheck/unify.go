@@ -0,0 +1,164 @@
+package typecheck
+
+import "fmt"
+
+// binder infers the type arguments of one call.
+type binder struct {
+	c      *checker
+	s      *scope
+	sig    *signature
+	params map[string]bool
+	bound  map[string]*Type
+	// origin describes where each bound type argument was inferred from.
+	origin map[string]string
+}
+
+// unify checks an argument of type actual against a parameter of type
+// pattern, binding the type parameters pattern uses. It returns a
+// description of the mismatch, or "" if there is none. A parameter with a
+// plain annotation such as Number is not checked.
+func (b *binder) unify(pattern, actual *Type, where string) string {
+	if pattern == nil || (!pattern.Param && len(pattern.Args) == 0) {
+		return ""
+	}
+	conflict, ok := b.match(pattern, actual, where)
+	if conflict != "" {
+		return conflict
+	}
+	if !ok {
+		return fmt.Sprintf("%s of %s must be %s, got %s", where, b.sig.name, pattern, actual)
+	}
+	return ""
+}
+
+// match binds the type parameters in pattern to the matching parts of
+// actual. It returns a description of the first type parameter bound to two
+// incompatible types, or false if the types are otherwise incompatible. Null
+// is left out of inference, since any parameter may be passed null.
+func (b *binder) match(pattern, actual *Type, where string) (string, bool) {
+	if pattern == nil || actual == nil || isNull(actual) {
+		return "", true
+	}
+	if pattern.Param && b.params[pattern.Name] {
+		if pattern.Nullable && actual.Nullable {
+			nonNull := *actual
+			nonNull.Nullable = false
+			actual = &nonNull
+		}
+		prev := b.bound[pattern.Name]
+		if prev == nil {
+			b.bound[pattern.Name] = actual
+			b.origin[pattern.Name] = where
+			return "", true
+		}
+		if !b.c.compatible(prev, actual, b.s) {
+			return fmt.Sprintf("%s is %s from %s of %s, but %s from %s", pattern.Name, prev, b.origin[pattern.Name], b.sig.name, actual, where), true
+		}
+		if b.c.subclass(prev, actual, b.s) {
+			b.bound[pattern.Name] = actual
+		}
+		return "", true
+	}
+	if pattern.Param || actual.Param {
+		return "", true
+	}
+	if canonical(pattern.Name) != canonical(actual.Name) {
+		return "", b.c.compatible(pattern, actual, b.s)
+	}
+	if len(pattern.Args) == len(actual.Args) {
+		for i := range pattern.Args {
+			if conflict, ok := b.match(pattern.Args[i], actual.Args[i], where); conflict != "" || !ok {
+				return conflict, ok
+			}
+		}
+	}
+	return "", true
+}
+
+// compatible reports whether a value of one type may stand where the other
+// is expected. Types the checker cannot tell apart, such as interfaces and
+// names it does not know, are compatible with everything.
+func (c *checker) compatible(a, b *Type, s *scope) bool {
+	if a == nil || b == nil || a.Param || b.Param || isNull(a) || isNull(b) {
+		return true
+	}
+	if canonical(a.Name) == canonical(b.Name) {
+		if len(a.Args) == len(b.Args) {
+			for i := range a.Args {
+				if !c.compatible(a.Args[i], b.Args[i], s) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	if !c.concrete(a, s) || !c.concrete(b, s) {
+		return true
+	}
+	return c.subclass(a, b, s) || c.subclass(b, a, s)
+}
+
+// concrete reports whether t names a builtin type or a struct, class, or
+// enum, whose values no other such type shares.
+func (c *checker) concrete(t *Type, s *scope) bool {
+	if e := s.lookup(t.Name); e != nil {
+		return e.def != nil && e.def.kind != "interface" && e.def.kind != "contract"
+	}
+	return builtinTypes[canonical(t.Name)]
+}
+
+// subclass reports whether sub names a class that extends super.
+func (c *checker) subclass(sub, super *Type, s *scope) bool {
+	def := s.definition(sub.Name)
+	for depth := 0; def != nil && depth < maxSuperclasses; depth++ {
+		if def.super == super.Name {
+			return true
+		}
+		def = s.definition(def.super)
+	}
+	return false
+}
+
+// substitute replaces the type parameters in t that are among params with
+// their bound types. A parameter that was not bound becomes unknown.
+func substitute(t *Type, bound map[string]*Type, params map[string]bool) *Type {
+	if t == nil {
+		return nil
+	}
+	if t.Param && params[t.Name] {
+		arg := bound[t.Name]
+		if arg != nil && t.Nullable && !arg.Nullable {
+			nullable := *arg
+			nullable.Nullable = true
+			return &nullable
+		}
+		return arg
+	}
+	if len(t.Args) == 0 {
+		return t
+	}
+	out := *t
+	out.Args = make([]*Type, len(t.Args))
+	for i, arg := range t.Args {
+		out.Args[i] = substitute(arg, bound, params)
+	}
+	return &out
+}
+
+func bindArgs(params []string, args []*Type) map[string]*Type {
+	bound := make(map[string]*Type, len(params))
+	for i, param := range params {
+		if i < len(args) {
+			bound[param] = args[i]
+		}
+	}
+	return bound
+}
+
+func paramSet(params []string) map[string]bool {
+	set := make(map[string]bool, len(params))
+	for _, param := range params {
+		set[param] = true
+	}
+	return set
+}
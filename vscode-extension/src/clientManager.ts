@@ -144,11 +144,10 @@ export class SeleneClientManager {
         { scheme: 'file', language: 'selene' },
         { scheme: 'untitled', language: 'selene' },
       ],
-      synchronize: this.watcher
-        ? {
-          fileEvents: this.watcher,
-        }
-        : undefined,
+      synchronize: {
+        configurationSection: 'selene',
+        fileEvents: this.watcher,
+      },
       outputChannel: this.output,
     };
 